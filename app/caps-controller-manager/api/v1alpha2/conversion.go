@@ -0,0 +1,20 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha2
+
+import (
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	infrav1alpha3 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+)
+
+// markConversionDataLost reports the hub fields preserved on down-conversion which can't be restored.
+//
+// Conversion doesn't fail, as the object would become unreadable in any version.
+func markConversionDataLost(obj conditions.Setter, err error) {
+	conditions.MarkFalse(obj, infrav1alpha3.ConditionConversionDataRestored, infrav1alpha3.ConversionDataInvalidReason, clusterv1.ConditionSeverityWarning,
+		"Failed to restore the fields not supported by %s: %s.", GroupVersion, err)
+}
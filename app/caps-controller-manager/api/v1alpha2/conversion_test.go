@@ -0,0 +1,62 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha2_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	utilconversion "sigs.k8s.io/cluster-api/util/conversion"
+
+	"github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha2"
+	"github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+)
+
+// TestFuzzyConversion makes sure that the hub (v1alpha3) objects survive the round-trip through v1alpha2 without losing data.
+func TestFuzzyConversion(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha2.AddToScheme(scheme))
+	require.NoError(t, v1alpha3.AddToScheme(scheme))
+
+	t.Run("for MetalCluster", utilconversion.FuzzTestFunc(scheme, &v1alpha3.MetalCluster{}, &v1alpha2.MetalCluster{}))
+	t.Run("for MetalMachine", utilconversion.FuzzTestFunc(scheme, &v1alpha3.MetalMachine{}, &v1alpha2.MetalMachine{}))
+	t.Run("for MetalMachineTemplate", utilconversion.FuzzTestFunc(scheme, &v1alpha3.MetalMachineTemplate{}, &v1alpha2.MetalMachineTemplate{}))
+}
+
+func TestConversionDataLost(t *testing.T) {
+	t.Parallel()
+
+	src := &v1alpha2.MetalMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "worker-1",
+			Annotations: map[string]string{
+				utilconversion.DataAnnotation: "{",
+			},
+		},
+	}
+
+	var dst v1alpha3.MetalMachine
+
+	// the object is still converted, the data loss is reported in the condition
+	require.NoError(t, src.ConvertTo(&dst))
+	assert.Equal(t, "worker-1", dst.Name)
+	assert.True(t, conditions.IsFalse(&dst, v1alpha3.ConditionConversionDataRestored))
+	assert.Equal(t, v1alpha3.ConversionDataInvalidReason, conditions.GetReason(&dst, v1alpha3.ConditionConversionDataRestored))
+
+	// template has no status, so the conversion fails
+	template := &v1alpha2.MetalMachineTemplate{
+		ObjectMeta: metav1.ObjectMeta{
+			Annotations: map[string]string{
+				utilconversion.DataAnnotation: "{",
+			},
+		},
+	}
+
+	assert.Error(t, template.ConvertTo(&v1alpha3.MetalMachineTemplate{}))
+}
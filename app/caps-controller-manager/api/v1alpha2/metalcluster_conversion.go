@@ -6,8 +6,6 @@
 package v1alpha2
 
 import (
-	"log"
-
 	apiconversion "k8s.io/apimachinery/pkg/conversion"
 	utilconversion "sigs.k8s.io/cluster-api/util/conversion"
 	"sigs.k8s.io/controller-runtime/pkg/conversion"
@@ -24,8 +22,6 @@ func (src *MetalCluster) ConvertTo(dstRaw conversion.Hub) error {
 	}
 
 	// Manually convert Spec.APIEndpoints to Spec.ControlPlaneEndpoint.
-	log.Printf("length of spec endpoints: %d", len(src.Spec.APIEndpoints))
-
 	if len(src.Spec.APIEndpoints) > 0 {
 		endpoint := src.Spec.APIEndpoints[0]
 		dst.Spec.ControlPlaneEndpoint.Host = endpoint.Host
@@ -38,8 +34,16 @@ func (src *MetalCluster) ConvertTo(dstRaw conversion.Hub) error {
 
 	// Manually restore data.
 	restored := &infrav1alpha3.MetalCluster{}
-	if ok, err := utilconversion.UnmarshalData(src, restored); err != nil || !ok {
-		return err
+
+	ok, err := utilconversion.UnmarshalData(src, restored)
+	if err != nil {
+		markConversionDataLost(dst, err)
+
+		return nil
+	}
+
+	if ok {
+		dst.Status.Conditions = restored.Status.Conditions
 	}
 
 	return nil
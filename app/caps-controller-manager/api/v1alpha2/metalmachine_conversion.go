@@ -23,8 +23,21 @@ func (src *MetalMachine) ConvertTo(dstRaw conversion.Hub) error {
 
 	// Manually restore data from annotations
 	restored := &infrav1alpha3.MetalMachine{}
-	if ok, err := utilconversion.UnmarshalData(src, restored); err != nil || !ok {
-		return err
+
+	ok, err := utilconversion.UnmarshalData(src, restored)
+	if err != nil {
+		markConversionDataLost(dst, err)
+
+		return nil
+	}
+
+	if ok {
+		dst.Spec.ServerClassRef = restored.Spec.ServerClassRef
+		dst.Spec.Network = restored.Spec.Network
+		dst.Spec.EnvironmentRef = restored.Spec.EnvironmentRef
+		dst.Spec.InstallDisk = restored.Spec.InstallDisk
+		dst.Status.StaticAddresses = restored.Status.StaticAddresses
+		dst.Status.Conditions = restored.Status.Conditions
 	}
 
 	return nil
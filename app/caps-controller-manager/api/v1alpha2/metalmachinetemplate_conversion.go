@@ -20,11 +20,18 @@ func (src *MetalMachineTemplate) ConvertTo(dstRaw conversion.Hub) error {
 	}
 
 	// Manually restore data from annotations
+	//
+	// MetalMachineTemplate has no status to report the data loss, so the conversion fails instead.
 	restored := &infrav1alpha3.MetalMachineTemplate{}
 	if ok, err := utilconversion.UnmarshalData(src, restored); err != nil || !ok {
 		return err
 	}
 
+	dst.Spec.Template.Spec.ServerClassRef = restored.Spec.Template.Spec.ServerClassRef
+	dst.Spec.Template.Spec.Network = restored.Spec.Template.Spec.Network
+	dst.Spec.Template.Spec.EnvironmentRef = restored.Spec.Template.Spec.EnvironmentRef
+	dst.Spec.Template.Spec.InstallDisk = restored.Spec.Template.Spec.InstallDisk
+
 	return nil
 }
 
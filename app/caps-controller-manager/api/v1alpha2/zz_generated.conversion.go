@@ -250,6 +250,7 @@ func autoConvert_v1alpha2_MetalClusterStatus_To_v1alpha3_MetalClusterStatus(in *
 
 func autoConvert_v1alpha3_MetalClusterStatus_To_v1alpha2_MetalClusterStatus(in *v1alpha3.MetalClusterStatus, out *MetalClusterStatus, s conversion.Scope) error {
 	out.Ready = in.Ready
+	// WARNING: in.Conditions requires manual conversion: does not exist in peer-type
 	return nil
 }
 
@@ -337,6 +338,9 @@ func autoConvert_v1alpha3_MetalMachineSpec_To_v1alpha2_MetalMachineSpec(in *v1al
 	out.ProviderID = (*string)(unsafe.Pointer(in.ProviderID))
 	out.ServerRef = (*v1.ObjectReference)(unsafe.Pointer(in.ServerRef))
 	// WARNING: in.ServerClassRef requires manual conversion: does not exist in peer-type
+	// WARNING: in.Network requires manual conversion: does not exist in peer-type
+	// WARNING: in.EnvironmentRef requires manual conversion: does not exist in peer-type
+	// WARNING: in.InstallDisk requires manual conversion: does not exist in peer-type
 	return nil
 }
 
@@ -349,8 +353,10 @@ func autoConvert_v1alpha2_MetalMachineStatus_To_v1alpha3_MetalMachineStatus(in *
 
 func autoConvert_v1alpha3_MetalMachineStatus_To_v1alpha2_MetalMachineStatus(in *v1alpha3.MetalMachineStatus, out *MetalMachineStatus, s conversion.Scope) error {
 	out.Ready = in.Ready
+	// WARNING: in.StaticAddresses requires manual conversion: does not exist in peer-type
 	// WARNING: in.FailureReason requires manual conversion: does not exist in peer-type
 	// WARNING: in.FailureMessage requires manual conversion: does not exist in peer-type
+	// WARNING: in.Conditions requires manual conversion: does not exist in peer-type
 	return nil
 }
 
@@ -0,0 +1,18 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha3
+
+import (
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+// ConditionConversionDataRestored reports whether the fields which can't be represented in the older API versions
+// were restored when the object written in the older version was converted back.
+//
+// The condition is only set when the restore fails, so that the lost fields are visible instead of being silently dropped.
+const ConditionConversionDataRestored clusterv1.ConditionType = "ConversionDataRestored"
+
+// ConversionDataInvalidReason is used when the preserved conversion data annotation can't be decoded.
+const ConversionDataInvalidReason = "ConversionDataInvalid"
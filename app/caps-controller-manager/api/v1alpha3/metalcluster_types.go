@@ -24,6 +24,10 @@ type MetalClusterSpec struct {
 // MetalClusterStatus defines the observed state of MetalCluster.
 type MetalClusterStatus struct {
 	Ready bool `json:"ready"`
+
+	// Conditions defines current service state of the MetalCluster.
+	// +optional
+	Conditions []capiv1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -43,6 +47,14 @@ type MetalCluster struct {
 	Status MetalClusterStatus `json:"status,omitempty"`
 }
 
+func (c *MetalCluster) GetConditions() capiv1.Conditions {
+	return c.Status.Conditions
+}
+
+func (c *MetalCluster) SetConditions(conditions capiv1.Conditions) {
+	c.Status.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // MetalClusterList contains a list of MetalCluster.
@@ -0,0 +1,26 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha3
+
+// StaticAddressesAssigned returns true if every interface in spec.network got the address claimed.
+func (m *MetalMachine) StaticAddressesAssigned() bool {
+	if m.Spec.Network == nil {
+		return true
+	}
+
+	assigned := make(map[string]struct{}, len(m.Status.StaticAddresses))
+
+	for _, address := range m.Status.StaticAddresses {
+		assigned[address.Interface] = struct{}{}
+	}
+
+	for _, iface := range m.Spec.Network.Interfaces {
+		if _, ok := assigned[iface.Name]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
@@ -7,7 +7,11 @@ package v1alpha3
 import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/errors"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
 )
 
 const (
@@ -25,12 +29,60 @@ type MetalMachineSpec struct {
 
 	ServerRef      *corev1.ObjectReference `json:"serverRef,omitempty"`
 	ServerClassRef *corev1.ObjectReference `json:"serverClassRef,omitempty"`
+
+	// Network configures static addresses claimed from IPAM pools (Cluster API IPAM contract).
+	// +optional
+	Network *MetalMachineNetwork `json:"network,omitempty"`
+
+	// EnvironmentRef overrides the environment of the ServerClass, e.g. to install a different Talos version
+	// with the machines of a MachineDeployment sharing the ServerClass.
+	// +optional
+	EnvironmentRef *corev1.ObjectReference `json:"environmentRef,omitempty"`
+	// InstallDisk selects the disk Talos is installed to, overriding the install disk of the ServerClass config patches.
+	//
+	// Only the servers with a matching disk in the hardware inventory are allocated.
+	// +optional
+	InstallDisk *metalv1alpha1.DiskSelector `json:"installDisk,omitempty"`
+}
+
+// MetalMachineNetwork configures static addressing of the machine.
+type MetalMachineNetwork struct {
+	// Interfaces to configure with the addresses claimed from IPAM pools.
+	Interfaces []StaticInterface `json:"interfaces"`
+	// Nameservers to configure on the machine.
+	// +optional
+	Nameservers []string `json:"nameservers,omitempty"`
+}
+
+// StaticInterface is a network interface configured with the address claimed from the IPAM pool.
+type StaticInterface struct {
+	// Name of the interface, e.g. `eth0`.
+	Name string `json:"name"`
+	// AddressFromPool references the IPAM pool (e.g. `InClusterIPPool`) to claim the address from.
+	AddressFromPool corev1.TypedLocalObjectReference `json:"addressFromPool"`
+}
+
+// StaticAddress is the address claimed from the IPAM pool for the interface.
+type StaticAddress struct {
+	// Interface name.
+	Interface string `json:"interface"`
+	// Address is the IP address.
+	Address string `json:"address"`
+	// Prefix is the subnet prefix length.
+	Prefix int `json:"prefix"`
+	// Gateway of the subnet.
+	// +optional
+	Gateway string `json:"gateway,omitempty"`
 }
 
 // MetalMachineStatus defines the observed state of MetalMachine.
 type MetalMachineStatus struct {
 	Ready bool `json:"ready"`
 
+	// StaticAddresses claimed for the interfaces in spec.network.
+	// +optional
+	StaticAddresses []StaticAddress `json:"staticAddresses,omitempty"`
+
 	// FailureReason will be set in the event that there is a terminal problem
 	// reconciling the Machine and will contain a succinct value suitable
 	// for machine interpretation.
@@ -68,8 +120,20 @@ type MetalMachineStatus struct {
 	// controller's output.
 	// +optional
 	FailureMessage *string `json:"failureMessage,omitempty"`
+
+	// Conditions defines current service state of the MetalMachine.
+	// +optional
+	Conditions []clusterv1.Condition `json:"conditions,omitempty"`
 }
 
+// ConditionServerAllocated reports whether a server was allocated to the MetalMachine from the ServerClass.
+//
+// The condition is false while the MetalMachine waits for a server in the exhausted ServerClass.
+const ConditionServerAllocated clusterv1.ConditionType = "ServerAllocated"
+
+// ServerClassExhaustedReason is used when the ServerClass has no servers available for the MetalMachine.
+const ServerClassExhaustedReason = "ServerClassExhausted"
+
 // +kubebuilder:object:root=true
 // +kubebuilder:resource:path=metalmachines,scope=Namespaced,categories=cluster-api
 // +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="MetalMachine ready status"
@@ -88,6 +152,30 @@ type MetalMachine struct {
 	Status MetalMachineStatus `json:"status,omitempty"`
 }
 
+func (m *MetalMachine) GetConditions() clusterv1.Conditions {
+	return m.Status.Conditions
+}
+
+func (m *MetalMachine) SetConditions(conditions clusterv1.Conditions) {
+	m.Status.Conditions = conditions
+}
+
+// OwnerMachineRef returns the owner reference to the CAPI Machine, nil if there is none.
+func (m *MetalMachine) OwnerMachineRef() *metav1.OwnerReference {
+	for i, ref := range m.OwnerReferences {
+		gv, err := schema.ParseGroupVersion(ref.APIVersion)
+		if err != nil {
+			continue
+		}
+
+		if ref.Kind == "Machine" && gv.Group == clusterv1.GroupVersion.Group {
+			return &m.OwnerReferences[i]
+		}
+	}
+
+	return nil
+}
+
 // +kubebuilder:object:root=true
 
 // MetalMachineList contains a list of MetalMachine.
@@ -7,6 +7,9 @@ package v1alpha3
 import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
 )
 
 // ServerBindingMetalMachineRefField is a reference to a field matching server binding to a metal machine.
@@ -16,6 +19,52 @@ const ServerBindingMetalMachineRefField = "spec.metalMachineRef.name"
 type ServerBindingSpec struct {
 	ServerClassRef  *corev1.ObjectReference `json:"serverClassRef,omitempty"`
 	MetalMachineRef corev1.ObjectReference  `json:"metalMachineRef"`
+	// Set of config patches to apply to the machine configuration of this allocation only, applied last.
+	// +optional
+	ConfigPatches []metalv1alpha1.ConfigPatches `json:"configPatches,omitempty"`
+	// Config patches stored in ConfigMaps and Secrets, applied after configPatches in the listed order.
+	// +optional
+	ConfigPatchesFrom []metalv1alpha1.ConfigPatchesRef `json:"configPatchesFrom,omitempty"`
+	// EnvironmentRef is the environment override of the MetalMachine.
+	// +optional
+	EnvironmentRef *corev1.ObjectReference `json:"environmentRef,omitempty"`
+	// InstallDisk is the disk Talos is installed to, picked with the MetalMachine install disk selector on allocation.
+	// +optional
+	InstallDisk string `json:"installDisk,omitempty"`
+}
+
+// Provenance records the software the server was provisioned with.
+type Provenance struct {
+	// Environment the server was booted with.
+	// +optional
+	Environment string `json:"environment,omitempty"`
+	// Kernel and initramfs of the environment, with the checksums of the downloaded assets.
+	// +optional
+	Kernel metalv1alpha1.Asset `json:"kernel,omitempty"`
+	// +optional
+	Initrd metalv1alpha1.Asset `json:"initrd,omitempty"`
+	// InstallImage is the installer image from the machine configuration.
+	// +optional
+	InstallImage string `json:"installImage,omitempty"`
+	// InstallImageDigest is the digest of the installer image, only known if the image is pinned by digest.
+	// +optional
+	InstallImageDigest string `json:"installImageDigest,omitempty"`
+	// BootstrapDataSecretRef is the bootstrap data secret (with the resource version) the machine configuration was generated from.
+	// +optional
+	BootstrapDataSecretRef *corev1.ObjectReference `json:"bootstrapDataSecretRef,omitempty"`
+	// CredentialsSHA256 is the checksum of the credentials (CAs, tokens and encryption secrets) in the bootstrap data
+	// the machine configuration was generated from.
+	// +optional
+	CredentialsSHA256 string `json:"credentialsSHA256,omitempty"`
+	// ConfigSHA256 is the checksum of the machine configuration served to the server.
+	// +optional
+	ConfigSHA256 string `json:"configSHA256,omitempty"`
+	// ConfigGeneration is incremented each time a different machine configuration is served to the server.
+	// +optional
+	ConfigGeneration int64 `json:"configGeneration,omitempty"`
+	// ConfigServedAt is the last time the machine configuration was served.
+	// +optional
+	ConfigServedAt metav1.Time `json:"configServedAt,omitempty"`
 }
 
 // ServerBindingState defines the observed state of ServerBinding.
@@ -23,8 +72,27 @@ type ServerBindingState struct {
 	// Ready is true when matching server is found.
 	// +optional
 	Ready bool `json:"ready"`
+	// Provenance of the software installed on the server, recorded when the machine configuration is served.
+	// +optional
+	Provenance *Provenance `json:"provenance,omitempty"`
+	// Conditions defines current service state of the ServerBinding.
+	// +optional
+	Conditions []clusterv1.Condition `json:"conditions,omitempty"`
 }
 
+// ConditionCredentialsUpToDate reports whether the server runs the credentials of the current bootstrap data.
+//
+// The condition is false if the cluster credentials were rotated after the machine configuration was served to the server.
+const ConditionCredentialsUpToDate clusterv1.ConditionType = "CredentialsUpToDate"
+
+// ServerBinding CredentialsUpToDate condition reasons.
+const (
+	// CredentialsRotatedReason is used when the credentials in the bootstrap data changed since the configuration was served.
+	CredentialsRotatedReason = "CredentialsRotated"
+	// CredentialsUnknownReason is used when the configuration was served before Sidero started recording the credentials.
+	CredentialsUnknownReason = "CredentialsUnknown"
+)
+
 // +kubebuilder:object:root=true
 // +kubebuilder:resource:scope=Cluster
 // +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.ready",description="ServerBinding ready status"
@@ -32,6 +100,7 @@ type ServerBindingState struct {
 // +kubebuilder:printcolumn:name="ServerClass",type="string",priority=1,JSONPath=".spec.serverClassRef.name",description="Server Class"
 // +kubebuilder:printcolumn:name="MetalMachine",type="string",priority=1,JSONPath=".spec.metalMachineRef.name",description="Metal Machine"
 // +kubebuilder:printcolumn:name="Cluster",type="string",priority=1,JSONPath=".metadata.labels.cluster\\.x-k8s\\.io/cluster-name",description="Cluster to which this ServerBinding belongs"
+// +kubebuilder:printcolumn:name="Credentials",type="string",priority=1,JSONPath=".status.conditions[?(@.type==\"CredentialsUpToDate\")].status",description="Server runs the current cluster credentials"
 // +kubebuilder:storageversion
 // +kubebuilder:subresource:status
 
@@ -47,6 +116,14 @@ type ServerBinding struct {
 	Status ServerBindingState `json:"status,omitempty"`
 }
 
+func (sb *ServerBinding) GetConditions() clusterv1.Conditions {
+	return sb.Status.Conditions
+}
+
+func (sb *ServerBinding) SetConditions(conditions clusterv1.Conditions) {
+	sb.Status.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // ServerBindingList contains a list of ServerBinding.
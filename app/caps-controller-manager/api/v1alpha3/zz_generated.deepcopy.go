@@ -11,7 +11,10 @@ package v1alpha3
 import (
 	v1 "k8s.io/api/core/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	apiv1alpha3 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/errors"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -20,7 +23,7 @@ func (in *MetalCluster) DeepCopyInto(out *MetalCluster) {
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	out.Spec = in.Spec
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalCluster.
@@ -92,6 +95,13 @@ func (in *MetalClusterSpec) DeepCopy() *MetalClusterSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetalClusterStatus) DeepCopyInto(out *MetalClusterStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]apiv1alpha3.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalClusterStatus.
@@ -163,6 +173,33 @@ func (in *MetalMachineList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetalMachineNetwork) DeepCopyInto(out *MetalMachineNetwork) {
+	*out = *in
+	if in.Interfaces != nil {
+		in, out := &in.Interfaces, &out.Interfaces
+		*out = make([]StaticInterface, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Nameservers != nil {
+		in, out := &in.Nameservers, &out.Nameservers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalMachineNetwork.
+func (in *MetalMachineNetwork) DeepCopy() *MetalMachineNetwork {
+	if in == nil {
+		return nil
+	}
+	out := new(MetalMachineNetwork)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetalMachineSpec) DeepCopyInto(out *MetalMachineSpec) {
 	*out = *in
@@ -181,6 +218,21 @@ func (in *MetalMachineSpec) DeepCopyInto(out *MetalMachineSpec) {
 		*out = new(v1.ObjectReference)
 		**out = **in
 	}
+	if in.Network != nil {
+		in, out := &in.Network, &out.Network
+		*out = new(MetalMachineNetwork)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.EnvironmentRef != nil {
+		in, out := &in.EnvironmentRef, &out.EnvironmentRef
+		*out = new(v1.ObjectReference)
+		**out = **in
+	}
+	if in.InstallDisk != nil {
+		in, out := &in.InstallDisk, &out.InstallDisk
+		*out = new(v1alpha1.DiskSelector)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalMachineSpec.
@@ -196,6 +248,11 @@ func (in *MetalMachineSpec) DeepCopy() *MetalMachineSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MetalMachineStatus) DeepCopyInto(out *MetalMachineStatus) {
 	*out = *in
+	if in.StaticAddresses != nil {
+		in, out := &in.StaticAddresses, &out.StaticAddresses
+		*out = make([]StaticAddress, len(*in))
+		copy(*out, *in)
+	}
 	if in.FailureReason != nil {
 		in, out := &in.FailureReason, &out.FailureReason
 		*out = new(errors.MachineStatusError)
@@ -206,6 +263,13 @@ func (in *MetalMachineStatus) DeepCopyInto(out *MetalMachineStatus) {
 		*out = new(string)
 		**out = **in
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]apiv1alpha3.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetalMachineStatus.
@@ -324,13 +388,36 @@ func (in *MetalMachineTemplateStatus) DeepCopy() *MetalMachineTemplateStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Provenance) DeepCopyInto(out *Provenance) {
+	*out = *in
+	out.Kernel = in.Kernel
+	out.Initrd = in.Initrd
+	if in.BootstrapDataSecretRef != nil {
+		in, out := &in.BootstrapDataSecretRef, &out.BootstrapDataSecretRef
+		*out = new(v1.ObjectReference)
+		**out = **in
+	}
+	in.ConfigServedAt.DeepCopyInto(&out.ConfigServedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Provenance.
+func (in *Provenance) DeepCopy() *Provenance {
+	if in == nil {
+		return nil
+	}
+	out := new(Provenance)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServerBinding) DeepCopyInto(out *ServerBinding) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerBinding.
@@ -392,6 +479,23 @@ func (in *ServerBindingSpec) DeepCopyInto(out *ServerBindingSpec) {
 		**out = **in
 	}
 	out.MetalMachineRef = in.MetalMachineRef
+	if in.ConfigPatches != nil {
+		in, out := &in.ConfigPatches, &out.ConfigPatches
+		*out = make([]v1alpha1.ConfigPatches, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ConfigPatchesFrom != nil {
+		in, out := &in.ConfigPatchesFrom, &out.ConfigPatchesFrom
+		*out = make([]v1alpha1.ConfigPatchesRef, len(*in))
+		copy(*out, *in)
+	}
+	if in.EnvironmentRef != nil {
+		in, out := &in.EnvironmentRef, &out.EnvironmentRef
+		*out = new(v1.ObjectReference)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerBindingSpec.
@@ -407,6 +511,18 @@ func (in *ServerBindingSpec) DeepCopy() *ServerBindingSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ServerBindingState) DeepCopyInto(out *ServerBindingState) {
 	*out = *in
+	if in.Provenance != nil {
+		in, out := &in.Provenance, &out.Provenance
+		*out = new(Provenance)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]apiv1alpha3.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerBindingState.
@@ -418,3 +534,34 @@ func (in *ServerBindingState) DeepCopy() *ServerBindingState {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StaticAddress) DeepCopyInto(out *StaticAddress) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StaticAddress.
+func (in *StaticAddress) DeepCopy() *StaticAddress {
+	if in == nil {
+		return nil
+	}
+	out := new(StaticAddress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StaticInterface) DeepCopyInto(out *StaticInterface) {
+	*out = *in
+	in.AddressFromPool.DeepCopyInto(&out.AddressFromPool)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StaticInterface.
+func (in *StaticInterface) DeepCopy() *StaticInterface {
+	if in == nil {
+		return nil
+	}
+	out := new(StaticInterface)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,140 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	"github.com/talos-systems/sidero/app/caps-controller-manager/pkg/constants"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// holdGhost returns true if the ghost MetalMachine should be left alone for now, as deleting it releases the server.
+//
+// Machines disappear without running the finalizers when the cluster is moved with `clusterctl move`, which pauses the cluster first,
+// so the ghost path doesn't run for paused clusters, for clusters which are gone or being deleted, and while the provisioning is frozen.
+func (r *MetalMachineReconciler) holdGhost(ctx context.Context, logger logr.Logger, metalMachine *infrav1.MetalMachine) (bool, error) {
+	clusterName, ok := metalMachine.Labels[capiv1.ClusterLabelName]
+	if !ok {
+		logger.Info("metalmachine has no cluster label, not releasing the server")
+
+		return true, nil
+	}
+
+	var cluster capiv1.Cluster
+
+	if err := r.Get(ctx, types.NamespacedName{Namespace: metalMachine.Namespace, Name: clusterName}, &cluster); err != nil {
+		if apierrors.IsNotFound(err) {
+			logger.Info("cluster is gone, not releasing the server", "cluster", clusterName)
+
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	if !cluster.DeletionTimestamp.IsZero() {
+		logger.Info("cluster is being deleted, not releasing the server", "cluster", clusterName)
+
+		return true, nil
+	}
+
+	if annotations.IsPaused(&cluster, metalMachine) {
+		logger.Info("reconciliation is paused, not releasing the server", "cluster", clusterName)
+
+		return true, nil
+	}
+
+	freeze, err := metalv1alpha1.ActiveFreeze(ctx, r.Client)
+	if err != nil {
+		return false, err
+	}
+
+	if freeze != nil {
+		logger.Info("provisioning is frozen, not releasing the server", "freeze", freeze.Name)
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// isGhost returns true if the owner Machine of the MetalMachine no longer exists,
+// e.g. when it was force-deleted with the finalizers stripped.
+//
+// The Machine is looked up bypassing the cache, so that a Machine missing from the cache (yet) isn't taken for a deleted one.
+// Machine recreated with the same name is a different object, so the UID of the owner reference is compared as well.
+func (r *MetalMachineReconciler) isGhost(ctx context.Context, metalMachine *infrav1.MetalMachine) (bool, error) {
+	ref := metalMachine.OwnerMachineRef()
+	if ref == nil {
+		return false, nil
+	}
+
+	reader := r.APIReader
+	if reader == nil {
+		reader = r.Client
+	}
+
+	var machine capiv1.Machine
+
+	if err := reader.Get(ctx, types.NamespacedName{Namespace: metalMachine.Namespace, Name: ref.Name}, &machine); err != nil {
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	return ref.UID != "" && machine.UID != ref.UID, nil
+}
+
+// reconcileGhost runs the regular release flow for the MetalMachine whose owner Machine is gone:
+// the MetalMachine is deleted, which in turn deletes the ServerBinding, so that the server is wiped and released.
+func (r *MetalMachineReconciler) reconcileGhost(ctx context.Context, logger logr.Logger, metalMachine *infrav1.MetalMachine) (_ ctrl.Result, err error) {
+	if metalMachine.ObjectMeta.DeletionTimestamp.IsZero() {
+		ref := metalMachine.OwnerMachineRef()
+
+		logger.Info("owner machine is gone, deleting metalmachine", "machine", ref.Name)
+
+		r.Recorder.Event(metalMachine, corev1.EventTypeWarning, "Ghost MetalMachine",
+			fmt.Sprintf("Owner machine %q no longer exists, deleting the metal machine to release the server.", ref.Name))
+
+		if err = r.Delete(ctx, metalMachine); err != nil && !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{RequeueAfter: constants.DefaultRequeueAfter}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(metalMachine, r)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	defer func() {
+		if e := patchHelper.Patch(ctx, metalMachine); e != nil {
+			logger.Error(e, "failed to patch metalMachine")
+
+			if err == nil {
+				err = e
+			}
+		}
+	}()
+
+	logger.Info("deleting metalmachine of the deleted machine")
+
+	return r.reconcileDelete(ctx, metalMachine)
+}
@@ -0,0 +1,206 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	"github.com/talos-systems/sidero/app/caps-controller-manager/controllers"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+func TestGhostMetalMachine(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, capiv1.AddToScheme(scheme))
+	require.NoError(t, infrav1.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+
+	metalMachine := func(deleted bool) *infrav1.MetalMachine {
+		m := &infrav1.MetalMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "default",
+				Name:            "worker-1",
+				ResourceVersion: "1",
+				Labels:          map[string]string{capiv1.ClusterLabelName: "management"},
+				Finalizers:      []string{infrav1.MachineFinalizer},
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: capiv1.GroupVersion.String(),
+						Kind:       "Machine",
+						Name:       "worker-1",
+						UID:        "6e3c2b4e-0000-0000-0000-000000000001",
+					},
+				},
+			},
+			Spec: infrav1.MetalMachineSpec{
+				ServerRef: &corev1.ObjectReference{Kind: "Server", Name: "server-1"},
+			},
+		}
+
+		if deleted {
+			now := metav1.Now()
+			m.DeletionTimestamp = &now
+		}
+
+		return m
+	}
+
+	cluster := func(paused bool) *capiv1.Cluster {
+		return &capiv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "management"},
+			Spec:       capiv1.ClusterSpec{Paused: paused},
+		}
+	}
+
+	serverBinding := &infrav1.ServerBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "server-1"},
+		Spec: infrav1.ServerBindingSpec{
+			MetalMachineRef: corev1.ObjectReference{Namespace: "default", Name: "worker-1"},
+		},
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "worker-1"}}
+
+	t.Run("deleted machine", func(t *testing.T) {
+		t.Parallel()
+
+		c := fake.NewFakeClientWithScheme(scheme, metalMachine(false), serverBinding.DeepCopy(), cluster(false))
+		recorder := record.NewFakeRecorder(10)
+
+		r := &controllers.MetalMachineReconciler{
+			Client:   c,
+			Log:      log.NullLogger{},
+			Scheme:   scheme,
+			Recorder: recorder,
+		}
+
+		_, err := r.Reconcile(req)
+		require.NoError(t, err)
+
+		err = c.Get(ctx, req.NamespacedName, &infrav1.MetalMachine{})
+		assert.True(t, apierrors.IsNotFound(err))
+
+		assert.Len(t, recorder.Events, 1)
+	})
+
+	t.Run("recreated machine", func(t *testing.T) {
+		t.Parallel()
+
+		c := fake.NewFakeClientWithScheme(scheme, metalMachine(false), serverBinding.DeepCopy(), cluster(false), &capiv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "worker-1",
+				UID:       "6e3c2b4e-0000-0000-0000-000000000002",
+			},
+		})
+
+		r := &controllers.MetalMachineReconciler{
+			Client:   c,
+			Log:      log.NullLogger{},
+			Scheme:   scheme,
+			Recorder: record.NewFakeRecorder(10),
+		}
+
+		_, err := r.Reconcile(req)
+		require.NoError(t, err)
+
+		err = c.Get(ctx, req.NamespacedName, &infrav1.MetalMachine{})
+		assert.True(t, apierrors.IsNotFound(err))
+	})
+
+	t.Run("deleting", func(t *testing.T) {
+		t.Parallel()
+
+		c := fake.NewFakeClientWithScheme(scheme, metalMachine(true), serverBinding.DeepCopy(), cluster(false))
+
+		r := &controllers.MetalMachineReconciler{
+			Client:   c,
+			Log:      log.NullLogger{},
+			Scheme:   scheme,
+			Recorder: record.NewFakeRecorder(10),
+		}
+
+		// first the server binding is deleted, so that the server is released
+		_, err := r.Reconcile(req)
+		require.NoError(t, err)
+
+		err = c.Get(ctx, types.NamespacedName{Name: "server-1"}, &infrav1.ServerBinding{})
+		assert.True(t, apierrors.IsNotFound(err))
+
+		_, err = r.Reconcile(req)
+		require.NoError(t, err)
+
+		var m infrav1.MetalMachine
+
+		require.NoError(t, c.Get(ctx, req.NamespacedName, &m))
+		assert.Empty(t, m.Finalizers)
+	})
+
+	for _, tt := range []struct {
+		name    string
+		objects []runtime.Object
+	}{
+		{
+			name:    "paused cluster",
+			objects: []runtime.Object{cluster(true)},
+		},
+		{
+			name: "frozen",
+			objects: []runtime.Object{
+				cluster(false),
+				&metalv1alpha1.Freeze{
+					ObjectMeta: metav1.ObjectMeta{Name: "incident"},
+					Spec:       metalv1alpha1.FreezeSpec{Frozen: true},
+				},
+			},
+		},
+		{
+			name: "cluster gone",
+		},
+	} {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			c := fake.NewFakeClientWithScheme(scheme, append(tt.objects, metalMachine(false), serverBinding.DeepCopy())...)
+
+			r := &controllers.MetalMachineReconciler{
+				Client:   c,
+				Log:      log.NullLogger{},
+				Scheme:   scheme,
+				Recorder: record.NewFakeRecorder(10),
+			}
+
+			// the server is not released, the metal machine is kept and requeued
+			result, err := r.Reconcile(req)
+			require.NoError(t, err)
+			assert.NotZero(t, result.RequeueAfter)
+
+			require.NoError(t, c.Get(ctx, req.NamespacedName, &infrav1.MetalMachine{}))
+			require.NoError(t, c.Get(ctx, types.NamespacedName{Name: "server-1"}, &infrav1.ServerBinding{}))
+		})
+	}
+}
@@ -0,0 +1,162 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+)
+
+// Cluster API IPAM contract resources.
+//
+// Cluster API v0.3 doesn't ship IPAM types, so claims and addresses are handled as unstructured objects;
+// CRDs are installed with the IPAM provider.
+var (
+	ipAddressClaimGVK = schema.GroupVersionKind{Group: "ipam.cluster.x-k8s.io", Version: "v1alpha1", Kind: "IPAddressClaim"}
+	ipAddressGVK      = schema.GroupVersionKind{Group: "ipam.cluster.x-k8s.io", Version: "v1alpha1", Kind: "IPAddress"}
+)
+
+// ipAddressClaimName returns the name of the claim for the interface.
+func ipAddressClaimName(metalMachine *infrav1.MetalMachine, iface infrav1.StaticInterface) string {
+	return fmt.Sprintf("%s-%s", metalMachine.Name, iface.Name)
+}
+
+// reconcileIPAddresses claims the addresses for the interfaces in spec.network from the IPAM pools
+// and records the allocated addresses in the status.
+//
+// Claims are owned by the MetalMachine, so addresses are released when the MetalMachine is deleted.
+// It returns false if some of the addresses are not allocated by the IPAM provider yet.
+func (r *MetalMachineReconciler) reconcileIPAddresses(ctx context.Context, cluster *capiv1.Cluster, metalMachine *infrav1.MetalMachine) (bool, error) {
+	if metalMachine.Spec.Network == nil {
+		metalMachine.Status.StaticAddresses = nil
+
+		return true, nil
+	}
+
+	addresses := make([]infrav1.StaticAddress, 0, len(metalMachine.Spec.Network.Interfaces))
+
+	for _, iface := range metalMachine.Spec.Network.Interfaces {
+		claim, err := r.ensureIPAddressClaim(ctx, cluster, metalMachine, iface)
+		if err != nil {
+			return false, err
+		}
+
+		addressName, _, err := unstructured.NestedString(claim.Object, "status", "addressRef", "name")
+		if err != nil {
+			return false, err
+		}
+
+		if addressName == "" {
+			continue
+		}
+
+		ipAddress := &unstructured.Unstructured{}
+		ipAddress.SetGroupVersionKind(ipAddressGVK)
+
+		if err = r.Get(ctx, types.NamespacedName{Namespace: metalMachine.Namespace, Name: addressName}, ipAddress); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return false, err
+		}
+
+		address, err := staticAddress(iface.Name, ipAddress)
+		if err != nil {
+			return false, fmt.Errorf("ipaddress %q: %w", addressName, err)
+		}
+
+		addresses = append(addresses, address)
+	}
+
+	metalMachine.Status.StaticAddresses = addresses
+
+	return metalMachine.StaticAddressesAssigned(), nil
+}
+
+func (r *MetalMachineReconciler) ensureIPAddressClaim(ctx context.Context, cluster *capiv1.Cluster, metalMachine *infrav1.MetalMachine, iface infrav1.StaticInterface) (*unstructured.Unstructured, error) {
+	claim := &unstructured.Unstructured{}
+	claim.SetGroupVersionKind(ipAddressClaimGVK)
+
+	key := types.NamespacedName{Namespace: metalMachine.Namespace, Name: ipAddressClaimName(metalMachine, iface)}
+
+	err := r.Get(ctx, key, claim)
+	if err == nil {
+		return claim, nil
+	}
+
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	claim = &unstructured.Unstructured{}
+	claim.SetGroupVersionKind(ipAddressClaimGVK)
+	claim.SetNamespace(key.Namespace)
+	claim.SetName(key.Name)
+	claim.SetLabels(map[string]string{
+		capiv1.ClusterLabelName: cluster.Name,
+	})
+	claim.SetOwnerReferences([]metav1.OwnerReference{
+		*metav1.NewControllerRef(metalMachine, infrav1.GroupVersion.WithKind("MetalMachine")),
+	})
+
+	poolRef := map[string]interface{}{
+		"kind": iface.AddressFromPool.Kind,
+		"name": iface.AddressFromPool.Name,
+	}
+
+	if iface.AddressFromPool.APIGroup != nil {
+		poolRef["apiGroup"] = *iface.AddressFromPool.APIGroup
+	}
+
+	if err = unstructured.SetNestedMap(claim.Object, poolRef, "spec", "poolRef"); err != nil {
+		return nil, err
+	}
+
+	if err = r.Create(ctx, claim); err != nil {
+		return nil, err
+	}
+
+	r.Log.Info("created ip address claim", "metalmachine", metalMachine.Name, "claim", claim.GetName(), "pool", iface.AddressFromPool.Name)
+
+	return claim, nil
+}
+
+func staticAddress(iface string, ipAddress *unstructured.Unstructured) (infrav1.StaticAddress, error) {
+	address, _, err := unstructured.NestedString(ipAddress.Object, "spec", "address")
+	if err != nil {
+		return infrav1.StaticAddress{}, err
+	}
+
+	if address == "" {
+		return infrav1.StaticAddress{}, fmt.Errorf("address is empty")
+	}
+
+	prefix, _, err := unstructured.NestedInt64(ipAddress.Object, "spec", "prefix")
+	if err != nil {
+		return infrav1.StaticAddress{}, err
+	}
+
+	gateway, _, err := unstructured.NestedString(ipAddress.Object, "spec", "gateway")
+	if err != nil {
+		return infrav1.StaticAddress{}, err
+	}
+
+	return infrav1.StaticAddress{
+		Interface: iface,
+		Address:   address,
+		Prefix:    int(prefix),
+		Gateway:   gateway,
+	}, nil
+}
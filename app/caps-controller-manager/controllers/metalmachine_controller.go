@@ -8,6 +8,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
@@ -23,6 +24,7 @@ import (
 	"k8s.io/utils/pointer"
 	capiv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -30,6 +32,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 
 	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	"github.com/talos-systems/sidero/app/caps-controller-manager/internal/metrics"
 	"github.com/talos-systems/sidero/app/caps-controller-manager/pkg/constants"
 	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
 )
@@ -39,9 +42,11 @@ var ErrNoServersInServerClass = errors.New("no servers available in serverclass"
 // MetalMachineReconciler reconciles a MetalMachine object.
 type MetalMachineReconciler struct {
 	client.Client
-	Log      logr.Logger
-	Scheme   *runtime.Scheme
-	Recorder record.EventRecorder
+	// APIReader reads bypassing the cache, the client is used if not set.
+	APIReader client.Reader
+	Log       logr.Logger
+	Scheme    *runtime.Scheme
+	Recorder  record.EventRecorder
 }
 
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metalmachines,verbs=get;list;watch;create;update;patch;delete
@@ -51,8 +56,12 @@ type MetalMachineReconciler struct {
 // +kubebuilder:rbac:groups=metal.sidero.dev,resources=serverclasses/status,verbs=get;list;watch;
 // +kubebuilder:rbac:groups=metal.sidero.dev,resources=servers,verbs=get;list;watch;
 // +kubebuilder:rbac:groups=metal.sidero.dev,resources=servers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=environments,verbs=get;list;watch;
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=freezes,verbs=get;list;watch;
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddressclaims,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=ipam.cluster.x-k8s.io,resources=ipaddresses,verbs=get;list;watch
 
 func (r *MetalMachineReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, err error) {
 	ctx := context.Background()
@@ -71,6 +80,27 @@ func (r *MetalMachineReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, err
 	}
 
 	machine, err := util.GetOwnerMachine(ctx, r.Client, metalMachine.ObjectMeta)
+	if apierrors.IsNotFound(err) || (err == nil && machine != nil && metalMachine.OwnerMachineRef().UID != machine.UID) {
+		// owner Machine might be force-deleted (finalizer stripped), leaving the server allocated forever
+		held, holdErr := r.holdGhost(ctx, logger, metalMachine)
+		if holdErr != nil {
+			return ctrl.Result{}, holdErr
+		}
+
+		if held {
+			return ctrl.Result{RequeueAfter: constants.DefaultRequeueAfter}, nil
+		}
+
+		ghost, ghostErr := r.isGhost(ctx, metalMachine)
+		if ghostErr != nil {
+			return ctrl.Result{}, ghostErr
+		}
+
+		if ghost {
+			return r.reconcileGhost(ctx, logger, metalMachine)
+		}
+	}
+
 	if err != nil {
 		r.Log.Error(err, "Failed to get machine")
 
@@ -130,6 +160,19 @@ func (r *MetalMachineReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, err
 
 	controllerutil.AddFinalizer(metalMachine, infrav1.MachineFinalizer)
 
+	// Claim static addresses before picking up a server, so that the machine config is complete
+	// by the time the server boots.
+	assigned, err := r.reconcileIPAddresses(ctx, cluster, metalMachine)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if !assigned {
+		logger.Info("waiting for IPAM provider to allocate static addresses")
+
+		return ctrl.Result{RequeueAfter: constants.DefaultRequeueAfter}, nil
+	}
+
 	// If server ref is already provided, server binding controller is going to reconcile matching server binding
 	// if server binding is missing, need to pick up a server
 	if metalMachine.Spec.ServerRef == nil {
@@ -137,6 +180,18 @@ func (r *MetalMachineReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, err
 			return ctrl.Result{}, fmt.Errorf("either a server or serverclass ref must be supplied")
 		}
 
+		// servers are not allocated while the provisioning plane is frozen
+		freeze, err := metalv1alpha1.ActiveFreeze(ctx, r.Client)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if freeze != nil {
+			logger.Info("provisioning is frozen, not allocating a server", "freeze", freeze.Name)
+
+			return ctrl.Result{RequeueAfter: constants.DefaultRequeueAfter}, nil
+		}
+
 		serverResource, err := r.fetchServerFromClass(ctx, logger, metalMachine.Spec.ServerClassRef, metalMachine)
 		if err != nil {
 			if errors.Is(err, ErrNoServersInServerClass) {
@@ -233,13 +288,24 @@ func (r *MetalMachineReconciler) fetchServerFromClass(ctx context.Context, logge
 	}
 
 	if len(serverClassResource.Status.ServersAvailable) == 0 {
+		markServerClassExhausted(metalMachine, serverClassResource.Name)
+
 		return nil, ErrNoServersInServerClass
 	}
 
+	availServers := serverClassResource.Status.ServersAvailable
+
+	if serverClassResource.Spec.SpreadBy != "" {
+		availServers, err = r.spreadServers(ctx, serverClassResource.Spec.SpreadBy, availServers, metalMachine)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Fetch server from available list
 	// NB: we added this loop to double check that an available server isn't "in use" because
 	//     we saw raciness between server selection and it being removed from the ServersAvailable list.
-	for _, availServer := range serverClassResource.Status.ServersAvailable {
+	for _, availServer := range availServers {
 		serverObj := &metalv1alpha1.Server{}
 
 		namespacedName := types.NamespacedName{
@@ -259,6 +325,24 @@ func (r *MetalMachineReconciler) fetchServerFromClass(ctx context.Context, logge
 			continue
 		}
 
+		// server is held by the canary run
+		if serverObj.CanaryHeld() != "" {
+			continue
+		}
+
+		ok, err := r.checkFirmware(ctx, logger, serverClassResource, serverObj, metalMachine)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		if !r.checkInstallDisk(logger, serverObj, metalMachine) {
+			continue
+		}
+
 		if err := r.createServerBinding(ctx, serverClassResource, serverObj, metalMachine); err != nil {
 			// the server we picked was updated by another metalmachine before we finished.
 			// move on to the next one.
@@ -271,12 +355,82 @@ func (r *MetalMachineReconciler) fetchServerFromClass(ctx context.Context, logge
 
 		logger.Info("allocated new server", "metalmachine", metalMachine.Name, "server", serverObj.Name, "serverclass", serverClassResource.Name)
 
+		metrics.AllocationDuration.WithLabelValues(serverClassResource.Name).Observe(time.Since(metalMachine.CreationTimestamp.Time).Seconds())
+
+		conditions.MarkTrue(metalMachine, infrav1.ConditionServerAllocated)
+
 		return serverObj, nil
 	}
 
+	markServerClassExhausted(metalMachine, serverClassResource.Name)
+
 	return nil, ErrNoServersInServerClass
 }
 
+// markServerClassExhausted records that the MetalMachine waits for a server in the exhausted ServerClass.
+//
+// The failure is counted once per MetalMachine, and not on every requeue while it waits.
+func markServerClassExhausted(metalMachine *infrav1.MetalMachine, serverClass string) {
+	if conditions.GetReason(metalMachine, infrav1.ConditionServerAllocated) != infrav1.ServerClassExhaustedReason {
+		metrics.AllocationFailures.WithLabelValues(serverClass).Inc()
+	}
+
+	conditions.MarkFalse(metalMachine, infrav1.ConditionServerAllocated, infrav1.ServerClassExhaustedReason, capiv1.ConditionSeverityWarning,
+		"no servers available in serverclass %q", serverClass)
+}
+
+// spreadServers orders the available servers to spread the servers of the cluster by the label.
+func (r *MetalMachineReconciler) spreadServers(ctx context.Context, label string, availServers []string, metalMachine *infrav1.MetalMachine) ([]string, error) {
+	clusterName, ok := metalMachine.Labels[capiv1.ClusterLabelName]
+	if !ok {
+		return availServers, nil
+	}
+
+	servers := make([]metalv1alpha1.Server, 0, len(availServers))
+
+	for _, availServer := range availServers {
+		var server metalv1alpha1.Server
+
+		if err := r.Get(ctx, types.NamespacedName{Name: availServer}, &server); err != nil {
+			return nil, err
+		}
+
+		servers = append(servers, server)
+	}
+
+	var serverBindingList infrav1.ServerBindingList
+
+	if err := r.List(ctx, &serverBindingList, client.MatchingLabels{capiv1.ClusterLabelName: clusterName}); err != nil {
+		return nil, err
+	}
+
+	allocated := make([]metalv1alpha1.Server, 0, len(serverBindingList.Items))
+
+	for _, serverBinding := range serverBindingList.Items {
+		var server metalv1alpha1.Server
+
+		if err := r.Get(ctx, types.NamespacedName{Name: serverBinding.Name}, &server); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		allocated = append(allocated, server)
+	}
+
+	servers = metalv1alpha1.SpreadServers(servers, label, allocated)
+
+	result := make([]string, 0, len(servers))
+
+	for _, server := range servers {
+		result = append(result, server.Name)
+	}
+
+	return result, nil
+}
+
 func (r *MetalMachineReconciler) patchProviderID(ctx context.Context, cluster *capiv1.Cluster, metalMachine *infrav1.MetalMachine) error {
 	kubeconfigSecret := &corev1.Secret{}
 
@@ -344,6 +498,72 @@ func (r *MetalMachineReconciler) patchProviderID(ctx context.Context, cluster *c
 	return nil
 }
 
+// checkFirmware verifies that the server meets the firmware requirements of the environment it is going to boot.
+//
+// Environment is picked the same way as in Sidero: from the server, then from the metal machine, then from the server class,
+// then the default one.
+func (r *MetalMachineReconciler) checkFirmware(ctx context.Context, logger logr.Logger, serverClass *metalv1alpha1.ServerClass, serverObj *metalv1alpha1.Server, metalMachine *infrav1.MetalMachine) (bool, error) {
+	envName := metalv1alpha1.EnvironmentDefault
+
+	switch {
+	case serverObj.Spec.EnvironmentRef != nil:
+		envName = serverObj.Spec.EnvironmentRef.Name
+	case metalMachine.Spec.EnvironmentRef != nil:
+		envName = metalMachine.Spec.EnvironmentRef.Name
+	case serverClass.Spec.EnvironmentRef != nil:
+		envName = serverClass.Spec.EnvironmentRef.Name
+	}
+
+	var env metalv1alpha1.Environment
+
+	if err := r.Get(ctx, types.NamespacedName{Name: envName}, &env); err != nil {
+		if apierrors.IsNotFound(err) {
+			// no environment, no requirements
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	checkErr := env.Spec.Firmware.Check(serverObj)
+	if checkErr == nil {
+		return true, nil
+	}
+
+	serverRef, err := reference.GetReference(r.Scheme, serverObj)
+	if err != nil {
+		return false, err
+	}
+
+	if env.Spec.Firmware.Blocking() {
+		logger.Info("skipping server not meeting firmware requirements", "server", serverObj.Name, "environment", envName, "error", checkErr.Error())
+		r.Recorder.Event(serverRef, corev1.EventTypeWarning, "Server Allocation",
+			fmt.Sprintf("Server skipped for metal machine %q: environment %q firmware requirements are not met: %s.", metalMachine.Name, envName, checkErr))
+
+		return false, nil
+	}
+
+	r.Recorder.Event(serverRef, corev1.EventTypeWarning, "Server Allocation",
+		fmt.Sprintf("Environment %q firmware requirements are not met: %s.", envName, checkErr))
+
+	return true, nil
+}
+
+// checkInstallDisk verifies that the server has the install disk selected by the metal machine.
+func (r *MetalMachineReconciler) checkInstallDisk(logger logr.Logger, serverObj *metalv1alpha1.Server, metalMachine *infrav1.MetalMachine) bool {
+	if metalMachine.Spec.InstallDisk == nil {
+		return true
+	}
+
+	if _, ok := serverObj.InstallDisk(metalMachine.Spec.InstallDisk); ok {
+		return true
+	}
+
+	logger.Info("skipping server without matching install disk", "server", serverObj.Name)
+
+	return false
+}
+
 // createServerBinding updates a server to mark it as "in use" via ServerBinding resource.
 func (r *MetalMachineReconciler) createServerBinding(ctx context.Context, serverClass *metalv1alpha1.ServerClass, serverObj *metalv1alpha1.Server, metalMachine *infrav1.MetalMachine) error {
 	serverRef, err := reference.GetReference(r.Scheme, serverObj)
@@ -374,6 +594,8 @@ func (r *MetalMachineReconciler) createServerBinding(ctx context.Context, server
 		serverBinding.Labels[label] = value
 	}
 
+	setMachineOverrides(&serverBinding, metalMachine, serverObj)
+
 	err = r.Create(ctx, &serverBinding)
 	if err == nil {
 		r.Recorder.Event(serverRef, corev1.EventTypeNormal, "Server Allocation", fmt.Sprintf("Server as allocated via serverclass %q for metal machine %q.", serverClass.Name, metalMachine.Name))
@@ -396,3 +618,14 @@ func (r *MetalMachineReconciler) fetchServerClass(ctx context.Context, classRef
 
 	return serverClassResource, nil
 }
+
+// setMachineOverrides copies the environment and the install disk overrides of the metal machine to the server binding.
+func setMachineOverrides(serverBinding *infrav1.ServerBinding, metalMachine *infrav1.MetalMachine, serverObj *metalv1alpha1.Server) {
+	if metalMachine.Spec.EnvironmentRef != nil {
+		serverBinding.Spec.EnvironmentRef = metalMachine.Spec.EnvironmentRef.DeepCopy()
+	}
+
+	if metalMachine.Spec.InstallDisk != nil {
+		serverBinding.Spec.InstallDisk, _ = serverObj.InstallDisk(metalMachine.Spec.InstallDisk)
+	}
+}
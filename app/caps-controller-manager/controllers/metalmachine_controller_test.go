@@ -0,0 +1,112 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/pointer"
+	capiv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	"github.com/talos-systems/sidero/app/caps-controller-manager/controllers"
+	"github.com/talos-systems/sidero/app/caps-controller-manager/internal/metrics"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+func TestAllocationFailures(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, capiv1.AddToScheme(scheme))
+	require.NoError(t, infrav1.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+
+	const serverClass = "allocation-failures"
+
+	labels := map[string]string{capiv1.ClusterLabelName: "management"}
+
+	c := fake.NewFakeClientWithScheme(scheme,
+		&capiv1.Cluster{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "management"},
+			Status:     capiv1.ClusterStatus{InfrastructureReady: true},
+		},
+		&capiv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "worker-1",
+				UID:       "6e3c2b4e-0000-0000-0000-000000000001",
+				Labels:    labels,
+			},
+			Spec: capiv1.MachineSpec{
+				ClusterName: "management",
+				Bootstrap:   capiv1.Bootstrap{DataSecretName: pointer.StringPtr("worker-1")},
+			},
+		},
+		&infrav1.MetalMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "default",
+				Name:            "worker-1",
+				ResourceVersion: "1",
+				Labels:          labels,
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: capiv1.GroupVersion.String(),
+						Kind:       "Machine",
+						Name:       "worker-1",
+						UID:        "6e3c2b4e-0000-0000-0000-000000000001",
+					},
+				},
+			},
+			Spec: infrav1.MetalMachineSpec{
+				ServerClassRef: &corev1.ObjectReference{Kind: "ServerClass", Name: serverClass},
+			},
+		},
+		&metalv1alpha1.ServerClass{
+			ObjectMeta: metav1.ObjectMeta{Name: serverClass},
+		},
+	)
+
+	r := &controllers.MetalMachineReconciler{
+		Client:   c,
+		Log:      log.NullLogger{},
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "worker-1"}}
+
+	// the machine is requeued while the class is exhausted, but it is counted once
+	for i := 0; i < 3; i++ {
+		result, err := r.Reconcile(req)
+		require.NoError(t, err)
+		assert.NotZero(t, result.RequeueAfter)
+	}
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.AllocationFailures.WithLabelValues(serverClass)))
+
+	var metalMachine infrav1.MetalMachine
+
+	require.NoError(t, c.Get(ctx, req.NamespacedName, &metalMachine))
+
+	assert.True(t, conditions.IsFalse(&metalMachine, infrav1.ConditionServerAllocated))
+	assert.Equal(t, infrav1.ServerClassExhaustedReason, conditions.GetReason(&metalMachine, infrav1.ConditionServerAllocated))
+}
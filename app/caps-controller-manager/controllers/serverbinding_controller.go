@@ -159,7 +159,7 @@ func (r *ServerBindingReconciler) reconcileTransition(logger logr.Logger, req ct
 	serverBinding.Name = req.Name
 	serverBinding.Labels = map[string]string{}
 
-	found := false
+	var found *infrav1.MetalMachine
 
 	for _, metalMachine := range metalMachineList.Items {
 		if !metalMachine.DeletionTimestamp.IsZero() {
@@ -168,7 +168,7 @@ func (r *ServerBindingReconciler) reconcileTransition(logger logr.Logger, req ct
 
 		if metalMachine.Spec.ServerRef != nil {
 			if metalMachine.Spec.ServerRef.Name == serverBinding.Name && metalMachine.Spec.ServerRef.Namespace == serverBinding.Namespace {
-				found = true
+				found = metalMachine.DeepCopy()
 
 				serverBinding.Spec.MetalMachineRef = corev1.ObjectReference{
 					Kind:      metalMachine.Kind,
@@ -190,7 +190,7 @@ func (r *ServerBindingReconciler) reconcileTransition(logger logr.Logger, req ct
 		}
 	}
 
-	if !found {
+	if found == nil {
 		logger.Info("no matching metalmachine found")
 
 		return ctrl.Result{}, nil
@@ -218,6 +218,8 @@ func (r *ServerBindingReconciler) reconcileTransition(logger logr.Logger, req ct
 		}
 	}
 
+	setMachineOverrides(&serverBinding, found, &server)
+
 	logger.Info("creating missing server binding", "metalmachine", serverBinding.Spec.MetalMachineRef.Name)
 
 	err = r.Create(ctx, &serverBinding)
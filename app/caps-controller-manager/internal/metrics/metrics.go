@@ -0,0 +1,44 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package metrics defines CAPI provider Prometheus metrics.
+//
+// Metrics are registered with the controller-runtime registry, so they are exposed
+// on the manager metrics endpoint (--metrics-addr).
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+var (
+	// AllocationDuration is the time from MetalMachine creation to the server allocation.
+	AllocationDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "caps_server_allocation_duration_seconds",
+			Help:    "Time from MetalMachine creation until a server is allocated from the ServerClass.",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 14),
+		},
+		[]string{"serverclass"},
+	)
+
+	// AllocationFailures is the number of MetalMachines which found the ServerClass exhausted.
+	//
+	// MetalMachines are counted once while they wait for a server, and not on every retry.
+	AllocationFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "caps_server_allocation_failures_total",
+			Help: "Number of MetalMachines which found a ServerClass without available servers.",
+		},
+		[]string{"serverclass"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		AllocationDuration,
+		AllocationFailures,
+	)
+}
@@ -25,6 +25,7 @@ import (
 	infrav1alpha3 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
 	"github.com/talos-systems/sidero/app/caps-controller-manager/controllers"
 	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/internal/dryrun"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -52,12 +53,14 @@ func main() {
 		metricsAddr          string
 		enableLeaderElection bool
 		webhookPort          int
+		dryRun               bool
 	)
 
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", true,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
 	flag.IntVar(&webhookPort, "webhook-port", 0, "Webhook Server port, disabled by default. When enabled, the manager will only work as webhook server, no reconcilers are installed.")
+	flag.BoolVar(&dryRun, "dry-run", false, "Log the actions the controllers would take (server allocations, changes to the resources) without executing them.")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(func(o *zap.Options) {
@@ -80,14 +83,22 @@ func main() {
 		BurstSize: 100,
 	})
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	options := ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
 		LeaderElection:     enableLeaderElection,
 		LeaderElectionID:   "controller-leader-election-capm",
 		Port:               webhookPort,
 		EventBroadcaster:   broadcaster,
-	})
+	}
+
+	if dryRun {
+		setupLog.Info("running in the dry-run mode, no changes are made")
+
+		options.NewClient = dryrun.NewClientFunc(ctrl.Log.WithName("dry-run"))
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
@@ -120,10 +131,11 @@ func main() {
 		}
 
 		if err = (&controllers.MetalMachineReconciler{
-			Client:   mgr.GetClient(),
-			Log:      ctrl.Log.WithName("controllers").WithName("MetalMachine"),
-			Scheme:   mgr.GetScheme(),
-			Recorder: recorder,
+			Client:    mgr.GetClient(),
+			APIReader: mgr.GetAPIReader(),
+			Log:       ctrl.Log.WithName("controllers").WithName("MetalMachine"),
+			Scheme:    mgr.GetScheme(),
+			Recorder:  recorder,
 		}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: 10}); err != nil {
 			setupLog.Error(err, "unable to create controller", "controller", "MetalMachine")
 			os.Exit(1)
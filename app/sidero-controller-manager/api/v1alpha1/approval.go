@@ -0,0 +1,66 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Approved returns true if the server passed the approval gate.
+func (s *ApprovalStatus) Approved() bool {
+	return s != nil && s.State == ApprovalStateApproved
+}
+
+// RecordApproval records the approval of the ApproveAction in the status and evaluates the approval gate.
+//
+// Each approver is counted once, so `required: 2` means two different users, the second approval of the same user is rejected.
+// Recording the approval of the same action again is a no-op.
+// Once the server is approved, the status is final.
+func (s *Server) RecordApproval(approval ServerApproval, action string, required int, now time.Time) error {
+	if s.Status.Approval == nil {
+		s.Status.Approval = &ApprovalStatus{
+			State: ApprovalStatePending,
+		}
+	}
+
+	status := s.Status.Approval
+
+	for _, recorded := range status.Approvals {
+		if recorded.Action == action {
+			return nil
+		}
+	}
+
+	if status.Approved() {
+		return fmt.Errorf("server is already approved")
+	}
+
+	if approval.Approver == "" {
+		return fmt.Errorf("approver is not recorded")
+	}
+
+	for _, recorded := range status.Approvals {
+		if recorded.Approver == approval.Approver {
+			return fmt.Errorf("server is already approved by %q", approval.Approver)
+		}
+	}
+
+	status.Required = required
+	status.Approvals = append(status.Approvals, RecordedApproval{
+		ServerApproval: approval,
+		Action:         action,
+		Time:           metav1.NewTime(now),
+	})
+
+	if len(status.Approvals) >= required {
+		status.State = ApprovalStateApproved
+		status.ApprovedAt = &metav1.Time{Time: now}
+	}
+
+	return nil
+}
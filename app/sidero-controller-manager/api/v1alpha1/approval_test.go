@@ -0,0 +1,63 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+func TestRecordApproval(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	var server metalv1alpha1.Server
+
+	require.NoError(t, server.RecordApproval(metalv1alpha1.ServerApproval{Approver: "alice", Comment: "CHG-1234"}, "approve-1", 2, now))
+	require.NotNil(t, server.Status.Approval)
+	assert.Equal(t, metalv1alpha1.ApprovalStatePending, server.Status.Approval.State)
+	assert.Equal(t, 2, server.Status.Approval.Required)
+	assert.False(t, server.Status.Approval.Approved())
+
+	require.Len(t, server.Status.Approval.Approvals, 1)
+	assert.Equal(t, "alice", server.Status.Approval.Approvals[0].Approver)
+	assert.Equal(t, "CHG-1234", server.Status.Approval.Approvals[0].Comment)
+	assert.Equal(t, "approve-1", server.Status.Approval.Approvals[0].Action)
+	assert.Equal(t, now, server.Status.Approval.Approvals[0].Time.Time)
+
+	// same action is recorded once
+	require.NoError(t, server.RecordApproval(metalv1alpha1.ServerApproval{Approver: "alice"}, "approve-1", 2, now.Add(time.Minute)))
+	assert.Len(t, server.Status.Approval.Approvals, 1)
+
+	// same approver is rejected
+	assert.EqualError(t, server.RecordApproval(metalv1alpha1.ServerApproval{Approver: "alice"}, "approve-2", 2, now), `server is already approved by "alice"`)
+	assert.EqualError(t, server.RecordApproval(metalv1alpha1.ServerApproval{}, "approve-3", 2, now), "approver is not recorded")
+	assert.Len(t, server.Status.Approval.Approvals, 1)
+
+	require.NoError(t, server.RecordApproval(metalv1alpha1.ServerApproval{Approver: "bob"}, "approve-4", 2, now.Add(time.Hour)))
+	assert.True(t, server.Status.Approval.Approved())
+	assert.Equal(t, now.Add(time.Hour), server.Status.Approval.ApprovedAt.Time)
+	require.Len(t, server.Status.Approval.Approvals, 2)
+	assert.Equal(t, now, server.Status.Approval.Approvals[0].Time.Time)
+
+	// approved status is final
+	assert.EqualError(t, server.RecordApproval(metalv1alpha1.ServerApproval{Approver: "carol"}, "approve-5", 3, now.Add(2*time.Hour)), "server is already approved")
+	assert.True(t, server.Status.Approval.Approved())
+	assert.Len(t, server.Status.Approval.Approvals, 2)
+}
+
+func TestApprovalStatusNil(t *testing.T) {
+	t.Parallel()
+
+	var status *metalv1alpha1.ApprovalStatus
+
+	assert.False(t, status.Approved())
+}
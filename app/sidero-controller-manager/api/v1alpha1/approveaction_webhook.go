@@ -0,0 +1,78 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/mutate-metal-sidero-dev-v1alpha1-approveaction,mutating=true,failurePolicy=fail,sideEffects=None,admissionReviewVersions=v1beta1,groups=metal.sidero.dev,resources=approveactions,versions=v1alpha1,name=mapproveaction.metal.sidero.dev
+
+// ApproveActionWebhookPath is the path of the ApproveAction mutating webhook.
+const ApproveActionWebhookPath = "/mutate-metal-sidero-dev-v1alpha1-approveaction"
+
+// ApproverWebhook records the authenticated user who creates the ApproveAction as the approver.
+//
+// Any approver set by the user is overwritten, and the approver can't be changed later.
+type ApproverWebhook struct{}
+
+var _ admission.Handler = &ApproverWebhook{}
+
+// SetupApproverWebhookWithManager registers the ApproveAction mutating webhook.
+func SetupApproverWebhookWithManager(mgr ctrl.Manager) {
+	mgr.GetWebhookServer().Register(ApproveActionWebhookPath, &webhook.Admission{Handler: &ApproverWebhook{}})
+}
+
+// Handle implements admission.Handler.
+func (w *ApproverWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	var action ApproveAction
+
+	if err := json.Unmarshal(req.Object.Raw, &action); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	switch req.Operation { //nolint:exhaustive
+	case admissionv1beta1.Create:
+		if req.UserInfo.Username == "" {
+			return admission.Denied("approver is not authenticated")
+		}
+
+		if action.Annotations == nil {
+			action.Annotations = map[string]string{}
+		}
+
+		action.Annotations[ApproveActionApproverAnnotation] = req.UserInfo.Username
+
+		marshaled, err := json.Marshal(&action)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, err)
+		}
+
+		return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+	case admissionv1beta1.Update:
+		var old ApproveAction
+
+		if err := json.Unmarshal(req.OldObject.Raw, &old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+
+		if action.Approver() != old.Approver() {
+			return admission.Denied("approver can't be changed")
+		}
+
+		if action.Spec != old.Spec {
+			return admission.Denied("approval can't be changed")
+		}
+	}
+
+	return admission.Allowed("")
+}
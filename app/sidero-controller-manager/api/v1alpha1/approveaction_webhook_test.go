@@ -0,0 +1,133 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	authenticationv1 "k8s.io/api/authentication/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+func TestApproverWebhook(t *testing.T) {
+	t.Parallel()
+
+	action := func(approver, serverRef string) runtime.RawExtension {
+		a := metalv1alpha1.ApproveAction{
+			ObjectMeta: metav1.ObjectMeta{Name: "approve-1"},
+			Spec:       metalv1alpha1.ServerActionSpec{ServerRef: serverRef},
+		}
+
+		if approver != "" {
+			a.Annotations = map[string]string{metalv1alpha1.ApproveActionApproverAnnotation: approver}
+		}
+
+		raw, err := json.Marshal(&a)
+		require.NoError(t, err)
+
+		return runtime.RawExtension{Raw: raw}
+	}
+
+	for name, tc := range map[string]struct {
+		operation admissionv1beta1.Operation
+		username  string
+		object    runtime.RawExtension
+		oldObject runtime.RawExtension
+
+		expectedAllowed bool
+		expectedPatch   bool
+	}{
+		"create": {
+			operation:       admissionv1beta1.Create,
+			username:        "alice",
+			object:          action("", "server-1"),
+			expectedAllowed: true,
+			expectedPatch:   true,
+		},
+		"create with approver": {
+			operation:       admissionv1beta1.Create,
+			username:        "alice",
+			object:          action("bob", "server-1"),
+			expectedAllowed: true,
+			expectedPatch:   true,
+		},
+		"create by the same user": {
+			operation:       admissionv1beta1.Create,
+			username:        "alice",
+			object:          action("alice", "server-1"),
+			expectedAllowed: true,
+		},
+		"create unauthenticated": {
+			operation: admissionv1beta1.Create,
+			object:    action("", "server-1"),
+		},
+		"update": {
+			operation:       admissionv1beta1.Update,
+			username:        "bob",
+			object:          action("alice", "server-1"),
+			oldObject:       action("alice", "server-1"),
+			expectedAllowed: true,
+		},
+		"update approver": {
+			operation: admissionv1beta1.Update,
+			username:  "bob",
+			object:    action("bob", "server-1"),
+			oldObject: action("alice", "server-1"),
+		},
+		"update server": {
+			operation: admissionv1beta1.Update,
+			username:  "alice",
+			object:    action("alice", "server-2"),
+			oldObject: action("alice", "server-1"),
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			resp := (&metalv1alpha1.ApproverWebhook{}).Handle(context.Background(), admission.Request{
+				AdmissionRequest: admissionv1beta1.AdmissionRequest{
+					Operation: tc.operation,
+					UserInfo:  authenticationv1.UserInfo{Username: tc.username},
+					Object:    tc.object,
+					OldObject: tc.oldObject,
+				},
+			})
+
+			assert.Equal(t, tc.expectedAllowed, resp.Allowed)
+
+			if !tc.expectedPatch {
+				assert.Empty(t, resp.Patches)
+
+				return
+			}
+
+			patch, err := json.Marshal(resp.Patches)
+			require.NoError(t, err)
+
+			decoded, err := jsonpatch.DecodePatch(patch)
+			require.NoError(t, err)
+
+			patched, err := decoded.Apply(tc.object.Raw)
+			require.NoError(t, err)
+
+			var action metalv1alpha1.ApproveAction
+
+			require.NoError(t, json.Unmarshal(patched, &action))
+			assert.Equal(t, tc.username, action.Approver())
+		})
+	}
+}
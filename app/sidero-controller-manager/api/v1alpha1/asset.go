@@ -0,0 +1,40 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1
+
+import "time"
+
+// WarrantyState is the state of the server warranty at some point in time.
+type WarrantyState int
+
+// Warranty states.
+const (
+	// WarrantyUnknown is the state of the servers without the warranty end date.
+	WarrantyUnknown WarrantyState = iota
+	WarrantyValid
+	WarrantyExpiring
+	WarrantyExpired
+)
+
+// Warranty returns the warranty state of the server at the time, the warranty is expiring if it ends within the window.
+//
+// Second value is the time the warranty state changes next (zero if it doesn't change anymore).
+func (a *AssetInformation) Warranty(now time.Time, window time.Duration) (WarrantyState, time.Time) {
+	if a == nil || a.WarrantyEnd == nil {
+		return WarrantyUnknown, time.Time{}
+	}
+
+	end := a.WarrantyEnd.Time
+	expiring := end.Add(-window)
+
+	switch {
+	case !now.Before(end):
+		return WarrantyExpired, time.Time{}
+	case !now.Before(expiring):
+		return WarrantyExpiring, end
+	default:
+		return WarrantyValid, expiring
+	}
+}
@@ -0,0 +1,72 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+func TestWarranty(t *testing.T) {
+	t.Parallel()
+
+	end := time.Date(2026, 6, 30, 0, 0, 0, 0, time.UTC)
+	window := 30 * 24 * time.Hour
+
+	asset := &v1alpha1.AssetInformation{
+		WarrantyEnd: &metav1.Time{Time: end},
+	}
+
+	for name, tc := range map[string]struct {
+		asset *v1alpha1.AssetInformation
+		now   time.Time
+
+		expectedState v1alpha1.WarrantyState
+		expectedNext  time.Time
+	}{
+		"no asset": {
+			now:           end,
+			expectedState: v1alpha1.WarrantyUnknown,
+		},
+		"no warranty end": {
+			asset:         &v1alpha1.AssetInformation{VendorCaseURL: "https://support.example.com/cases/1"},
+			now:           end,
+			expectedState: v1alpha1.WarrantyUnknown,
+		},
+		"valid": {
+			asset:         asset,
+			now:           end.Add(-60 * 24 * time.Hour),
+			expectedState: v1alpha1.WarrantyValid,
+			expectedNext:  end.Add(-window),
+		},
+		"expiring": {
+			asset:         asset,
+			now:           end.Add(-window),
+			expectedState: v1alpha1.WarrantyExpiring,
+			expectedNext:  end,
+		},
+		"expired": {
+			asset:         asset,
+			now:           end,
+			expectedState: v1alpha1.WarrantyExpired,
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			state, next := tc.asset.Warranty(tc.now, window)
+
+			assert.Equal(t, tc.expectedState, state)
+			assert.Equal(t, tc.expectedNext, next)
+		})
+	}
+}
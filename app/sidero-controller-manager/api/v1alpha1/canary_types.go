@@ -0,0 +1,222 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1
+
+import (
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ServerCanaryAnnotation holds the idle server for the canary run (the value is the name of the Canary):
+// the server is not available for allocation until the run is done.
+const ServerCanaryAnnotation = "metal.sidero.dev/canary"
+
+// ServerCanaryInstallAnnotation allocates the server held by the canary to the canary run,
+// so that the server is booted into the environment and served the scratch machine configuration of the Canary.
+const ServerCanaryInstallAnnotation = "metal.sidero.dev/canary-install"
+
+// Phases of the canary run, the phase is empty between the runs.
+const (
+	CanaryPhaseWiping     = "Wiping"
+	CanaryPhaseInstalling = "Installing"
+	CanaryPhaseVerifying  = "Verifying"
+	CanaryPhaseReleasing  = "Releasing"
+)
+
+// Results of the canary run.
+const (
+	CanaryResultSucceeded = "Succeeded"
+	CanaryResultFailed    = "Failed"
+)
+
+// Steps of the canary run.
+const (
+	// CanaryStepWipe is the power on, PXE boot into the agent, registration and wipe of the server.
+	CanaryStepWipe = "wipe"
+	// CanaryStepInstall is the PXE boot into the environment until the scratch machine configuration is served.
+	CanaryStepInstall = "install"
+	// CanaryStepVerify is the installation until the installed system accepts the connections.
+	CanaryStepVerify = "verify"
+	// CanaryStepRelease is the PXE boot into the agent and the wipe after the server is released.
+	CanaryStepRelease = "release"
+)
+
+// Defaults of the canary runs.
+const (
+	DefaultCanaryInterval   = 24 * time.Hour
+	DefaultCanaryTimeout    = time.Hour
+	DefaultCanaryVerifyPort = 50000
+)
+
+// CanarySpec defines the canary periodically taking an idle server through the provisioning cycle.
+type CanarySpec struct {
+	// ServerSelector selects the servers the canary runs on, any accepted server if not set.
+	//
+	// Only the idle servers (clean and not allocated) are picked, in turn.
+	// +optional
+	ServerSelector *metav1.LabelSelector `json:"serverSelector,omitempty"`
+	// Interval between the starts of the runs, 24h if not set.
+	// +optional
+	Interval *metav1.Duration `json:"interval,omitempty"`
+	// Timeout of the run, the run fails if it's not done in time, 1h if not set.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+	// ConfigFrom is the scratch machine configuration installed on the server.
+	//
+	// The configuration should not join any cluster, the server is wiped and released once it's verified.
+	ConfigFrom ObjectKeyRef `json:"configFrom"`
+	// EnvironmentRef is the environment the server is booted into to install the configuration.
+	// The environment of the server takes precedence, the default environment is used if neither is set.
+	// +optional
+	EnvironmentRef *corev1.ObjectReference `json:"environmentRef,omitempty"`
+	// VerifyPort is the TCP port on the server addresses which accepts the connections once the configuration is installed,
+	// the Talos API port if not set.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	VerifyPort int32 `json:"verifyPort,omitempty"`
+	// Suspend stops starting the new runs, the run in progress is completed.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+}
+
+// CanaryStep is the completed step of the canary run.
+type CanaryStep struct {
+	Name     string          `json:"name"`
+	Duration metav1.Duration `json:"duration"`
+}
+
+// CanaryRun describes the canary run.
+type CanaryRun struct {
+	// ServerRef is the name of the Server the run is on.
+	ServerRef string `json:"serverRef"`
+	// StartTime is the time the run started.
+	StartTime metav1.Time `json:"startTime"`
+	// CompletionTime is the time the run succeeded or failed.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Result of the run: Succeeded or Failed.
+	// +optional
+	Result string `json:"result,omitempty"`
+	// Message describes the failure of the run, or the last verification error of the run in progress.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Steps completed in the run.
+	// +optional
+	Steps []CanaryStep `json:"steps,omitempty"`
+}
+
+// CanaryStatus defines the observed state of the Canary.
+type CanaryStatus struct {
+	// Phase of the run in progress: Wiping, Installing, Verifying or Releasing, empty between the runs.
+	// +optional
+	Phase string `json:"phase,omitempty"`
+	// PhaseStartTime is the time the phase of the run in progress started.
+	// +optional
+	PhaseStartTime *metav1.Time `json:"phaseStartTime,omitempty"`
+	// ConfigServedTime is the time the scratch machine configuration was served to the server in the run in progress.
+	// +optional
+	ConfigServedTime *metav1.Time `json:"configServedTime,omitempty"`
+	// Run is the run in progress.
+	// +optional
+	Run *CanaryRun `json:"run,omitempty"`
+	// LastRun is the last completed run.
+	// +optional
+	LastRun *CanaryRun `json:"lastRun,omitempty"`
+	// LastSuccessTime is the completion time of the last succeeded run.
+	// +optional
+	LastSuccessTime *metav1.Time `json:"lastSuccessTime,omitempty"`
+	// ConsecutiveFailures is the number of the failed runs since the last succeeded run.
+	// +optional
+	ConsecutiveFailures int `json:"consecutiveFailures,omitempty"`
+	// Message describes why the run is not started, e.g. there are no idle servers.
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="phase of the run in progress"
+// +kubebuilder:printcolumn:name="Server",type="string",JSONPath=".status.run.serverRef",description="the server of the run in progress"
+// +kubebuilder:printcolumn:name="Last Result",type="string",JSONPath=".status.lastRun.result",description="result of the last run"
+// +kubebuilder:printcolumn:name="Last Success",type="date",JSONPath=".status.lastSuccessTime",description="completion time of the last succeeded run"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// Canary is the Schema for the canaries API.
+//
+// Canary periodically takes an idle server through the provisioning cycle (power on, PXE boot, registration, wipe,
+// install of the scratch machine configuration, verification and release), so that the broken provisioning path
+// is discovered before the servers are allocated.
+type Canary struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   CanarySpec   `json:"spec,omitempty"`
+	Status CanaryStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// CanaryList contains a list of Canary.
+type CanaryList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Canary `json:"items"`
+}
+
+// IntervalDuration returns the interval between the runs.
+func (c *Canary) IntervalDuration() time.Duration {
+	if c.Spec.Interval == nil || c.Spec.Interval.Duration <= 0 {
+		return DefaultCanaryInterval
+	}
+
+	return c.Spec.Interval.Duration
+}
+
+// TimeoutDuration returns the timeout of the run.
+func (c *Canary) TimeoutDuration() time.Duration {
+	if c.Spec.Timeout == nil || c.Spec.Timeout.Duration <= 0 {
+		return DefaultCanaryTimeout
+	}
+
+	return c.Spec.Timeout.Duration
+}
+
+// Port returns the TCP port verified on the server addresses.
+func (c *Canary) Port() int {
+	if c.Spec.VerifyPort == 0 {
+		return DefaultCanaryVerifyPort
+	}
+
+	return int(c.Spec.VerifyPort)
+}
+
+// NextRunTime returns the time the next run is due.
+func (c *Canary) NextRunTime() time.Time {
+	if c.Status.LastRun == nil {
+		return time.Time{}
+	}
+
+	return c.Status.LastRun.StartTime.Add(c.IntervalDuration())
+}
+
+// CanaryHeld returns the name of the Canary holding the server for the run, empty if the server is not held.
+func (s *Server) CanaryHeld() string {
+	return s.Annotations[ServerCanaryAnnotation]
+}
+
+// CanaryInstallRequested returns true if the server is allocated to the canary run to install the scratch machine configuration.
+func (s *Server) CanaryInstallRequested() bool {
+	_, ok := s.Annotations[ServerCanaryInstallAnnotation]
+
+	return ok && s.CanaryHeld() != ""
+}
+
+func init() {
+	SchemeBuilder.Register(&Canary{}, &CanaryList{})
+}
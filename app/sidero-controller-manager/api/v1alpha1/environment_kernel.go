@@ -0,0 +1,156 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"unicode"
+
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+// Kernel args Sidero requires to boot Talos.
+const (
+	// KernelArgTalosPlatform should be set to KernelArgTalosPlatformMetal.
+	KernelArgTalosPlatform      = "talos.platform"
+	KernelArgTalosPlatformMetal = "metal"
+	// KernelArgTalosConfig should point to the Sidero metadata server.
+	KernelArgTalosConfig = "talos.config"
+)
+
+// ConditionKernelArgsValid is the Environment status condition reporting the kernel args validation result.
+//
+// Environments created before the validation was introduced are not rejected, but the problems are reported in the condition message.
+const ConditionKernelArgsValid = "KernelArgsValid"
+
+// KernelArgsReasonInvalid is set when the kernel args validation fails.
+const KernelArgsReasonInvalid = "InvalidKernelArgs"
+
+// KernelArgsCondition validates the kernel args and returns the status condition.
+func (env *Environment) KernelArgsCondition() AssetCondition {
+	condition := AssetCondition{
+		Type:   ConditionKernelArgsValid,
+		Status: "True",
+	}
+
+	if errs := env.ValidateKernelArgs(); len(errs) > 0 {
+		condition.Status = "False"
+		condition.Reason = KernelArgsReasonInvalid
+		condition.Message = errs.ToAggregate().Error()
+	}
+
+	return condition
+}
+
+// kernelArgConsole is the only kernel arg which can be repeated (once per console device).
+const kernelArgConsole = "console"
+
+// KernelCmdline returns the kernel command line the servers are booted with.
+func (spec *EnvironmentSpec) KernelCmdline() string {
+	return strings.Join(spec.Kernel.Args, " ")
+}
+
+// ValidateKernelArgs checks the kernel args for the mistakes which would silently break the boot.
+//
+// Duplicate args (the kernel and Talos might pick different values) and conflicting console settings
+// are rejected, Talos platform and the machine config URL are required.
+func ValidateKernelArgs(path *field.Path, args []string) field.ErrorList {
+	var (
+		errs     field.ErrorList
+		seen     = map[string]int{}
+		consoles = map[string]int{}
+		values   = map[string]string{}
+	)
+
+	for i, arg := range args {
+		if arg == "" || strings.IndexFunc(arg, unicode.IsSpace) != -1 {
+			errs = append(errs, field.Invalid(path.Index(i), arg, "kernel argument can't be empty or contain whitespace, use a separate list item for each argument"))
+
+			continue
+		}
+
+		key, value := splitKernelArg(arg)
+
+		if key == "" {
+			errs = append(errs, field.Invalid(path.Index(i), arg, "kernel argument key is empty"))
+
+			continue
+		}
+
+		if key == kernelArgConsole {
+			device := strings.SplitN(value, ",", 2)[0]
+
+			if device == "" {
+				errs = append(errs, field.Invalid(path.Index(i), arg, "console device is empty"))
+
+				continue
+			}
+
+			if prev, ok := consoles[device]; ok {
+				if args[prev] == arg {
+					errs = append(errs, field.Duplicate(path.Index(i), arg))
+				} else {
+					errs = append(errs, field.Invalid(path.Index(i), arg, fmt.Sprintf("conflicting settings for console %q with %q", device, args[prev])))
+				}
+
+				continue
+			}
+
+			consoles[device] = i
+
+			continue
+		}
+
+		if prev, ok := seen[key]; ok {
+			if args[prev] == arg {
+				errs = append(errs, field.Duplicate(path.Index(i), arg))
+			} else {
+				errs = append(errs, field.Invalid(path.Index(i), arg, fmt.Sprintf("conflicts with %q", args[prev])))
+			}
+
+			continue
+		}
+
+		seen[key] = i
+		values[key] = value
+	}
+
+	if platform, ok := values[KernelArgTalosPlatform]; !ok {
+		errs = append(errs, field.Required(path, fmt.Sprintf("%s=%s is required", KernelArgTalosPlatform, KernelArgTalosPlatformMetal)))
+	} else if platform != KernelArgTalosPlatformMetal {
+		errs = append(errs, field.Invalid(path.Index(seen[KernelArgTalosPlatform]), args[seen[KernelArgTalosPlatform]],
+			fmt.Sprintf("only %q platform is supported", KernelArgTalosPlatformMetal)))
+	}
+
+	if config, ok := values[KernelArgTalosConfig]; !ok {
+		errs = append(errs, field.Required(path, fmt.Sprintf("%s pointing to the Sidero metadata server is required", KernelArgTalosConfig)))
+	} else if u, err := url.Parse(config); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, field.Invalid(path.Index(seen[KernelArgTalosConfig]), args[seen[KernelArgTalosConfig]], "machine config URL should be an HTTP(S) URL"))
+	}
+
+	return errs
+}
+
+// splitKernelArg splits the kernel arg into the key and the value.
+//
+// Dashes and underscores are equivalent in the kernel parameter names.
+func splitKernelArg(arg string) (key, value string) {
+	parts := strings.SplitN(arg, "=", 2)
+
+	key = strings.ReplaceAll(parts[0], "-", "_")
+
+	if len(parts) > 1 {
+		value = parts[1]
+	}
+
+	return key, value
+}
+
+// ValidateKernelArgs checks the kernel args of the environment.
+func (env *Environment) ValidateKernelArgs() field.ErrorList {
+	return ValidateKernelArgs(field.NewPath("spec", "kernel", "args"), env.Spec.Kernel.Args)
+}
@@ -0,0 +1,113 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+func TestValidateKernelArgs(t *testing.T) {
+	t.Parallel()
+
+	required := []string{"talos.platform=metal", "talos.config=http://172.24.0.2:8081/configdata?uuid="}
+
+	for name, tc := range map[string]struct {
+		args     []string
+		expected []string
+	}{
+		"valid": {
+			args: append([]string{"console=tty0", "console=ttyS1,115200n8", "earlyprintk=ttyS1,115200n8", "slab_nomerge=", "quiet"}, required...),
+		},
+		"empty": {
+			expected: []string{
+				"spec.kernel.args: Required value: talos.platform=metal is required",
+				"spec.kernel.args: Required value: talos.config pointing to the Sidero metadata server is required",
+			},
+		},
+		"whitespace": {
+			args: append([]string{"pti=on slab_nomerge=", ""}, required...),
+			expected: []string{
+				`spec.kernel.args[0]: Invalid value: "pti=on slab_nomerge=": kernel argument can't be empty or contain whitespace, use a separate list item for each argument`,
+				`spec.kernel.args[1]: Invalid value: "": kernel argument can't be empty or contain whitespace, use a separate list item for each argument`,
+			},
+		},
+		"empty key": {
+			args:     append([]string{"=on"}, required...),
+			expected: []string{`spec.kernel.args[0]: Invalid value: "=on": kernel argument key is empty`},
+		},
+		"duplicate": {
+			args:     append([]string{"pti=on", "pti=on"}, required...),
+			expected: []string{`spec.kernel.args[1]: Duplicate value: "pti=on"`},
+		},
+		"conflict": {
+			args:     append([]string{"nvme_core.io_timeout=30", "nvme-core.io-timeout=4294967295"}, required...),
+			expected: []string{`spec.kernel.args[1]: Invalid value: "nvme-core.io-timeout=4294967295": conflicts with "nvme_core.io_timeout=30"`},
+		},
+		"console conflict": {
+			args: append([]string{"console=ttyS0", "console=ttyS0,115200n8", "console=ttyS0", "console="}, required...),
+			expected: []string{
+				`spec.kernel.args[1]: Invalid value: "console=ttyS0,115200n8": conflicting settings for console "ttyS0" with "console=ttyS0"`,
+				`spec.kernel.args[2]: Duplicate value: "console=ttyS0"`,
+				`spec.kernel.args[3]: Invalid value: "console=": console device is empty`,
+			},
+		},
+		"wrong platform": {
+			args: []string{"talos.platform=aws", "talos.config=ftp://172.24.0.2/configdata"},
+			expected: []string{
+				`spec.kernel.args[0]: Invalid value: "talos.platform=aws": only "metal" platform is supported`,
+				`spec.kernel.args[1]: Invalid value: "talos.config=ftp://172.24.0.2/configdata": machine config URL should be an HTTP(S) URL`,
+			},
+		},
+		"conflicting config": {
+			args: append(append([]string(nil), required...), "talos.config=http://10.5.0.1:8081/configdata?uuid="),
+			expected: []string{
+				`spec.kernel.args[2]: Invalid value: "talos.config=http://10.5.0.1:8081/configdata?uuid=": conflicts with "talos.config=http://172.24.0.2:8081/configdata?uuid="`,
+			},
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			errs := metalv1alpha1.ValidateKernelArgs(field.NewPath("spec", "kernel", "args"), tc.args)
+
+			actual := make([]string, 0, len(errs))
+
+			for _, err := range errs {
+				actual = append(actual, err.Error())
+			}
+
+			assert.Equal(t, append([]string{}, tc.expected...), actual)
+		})
+	}
+}
+
+func TestEnvironmentDefaultKernelArgs(t *testing.T) {
+	t.Parallel()
+
+	env := metalv1alpha1.Environment{
+		Spec: *metalv1alpha1.EnvironmentDefaultSpec("v0.11.5", "2001:db8::2", 8081),
+	}
+
+	assert.Empty(t, env.ValidateKernelArgs())
+	assert.Equal(t, "True", env.KernelArgsCondition().Status)
+	require.NoError(t, env.ValidateCreate())
+
+	env.Spec.Kernel.Args = append(env.Spec.Kernel.Args, "console=ttyS0,115200n8")
+
+	condition := env.KernelArgsCondition()
+	assert.Equal(t, "False", condition.Status)
+	assert.Equal(t, metalv1alpha1.KernelArgsReasonInvalid, condition.Reason)
+	assert.Contains(t, condition.Message, `conflicting settings for console "ttyS0" with "console=ttyS0"`)
+	assert.NoError(t, env.ValidateUpdate(env.DeepCopy()), "kernel args are not changed")
+	assert.Error(t, env.ValidateUpdate(&metalv1alpha1.Environment{}))
+}
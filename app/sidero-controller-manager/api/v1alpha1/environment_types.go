@@ -6,7 +6,10 @@ package v1alpha1
 
 import (
 	"fmt"
+	"net"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/talos-systems/talos/pkg/machinery/kernel"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -15,6 +18,12 @@ import (
 // EnvironmentDefault is an automatically created Environment.
 const EnvironmentDefault = "default"
 
+// EnvironmentAPIEndpointAnnotation records the Sidero API endpoint (host:port) the default Environment was generated for.
+//
+// If Sidero is started with another endpoint (e.g. after pivoting from the bootstrap cluster), the default Environment
+// kernel args are updated to point to the new endpoint.
+const EnvironmentAPIEndpointAnnotation = "metal.sidero.dev/api-endpoint"
+
 type Asset struct {
 	URL    string `json:"url,omitempty"`
 	SHA512 string `json:"sha512,omitempty"`
@@ -30,21 +39,243 @@ type Initrd struct {
 	Asset `json:",inline"`
 }
 
+// FirmwareEnforcement defines the action when the server firmware doesn't meet the requirements.
+type FirmwareEnforcement string
+
+// Firmware enforcement modes.
+const (
+	// FirmwareEnforcementBlock prevents allocation of the servers (default).
+	FirmwareEnforcementBlock FirmwareEnforcement = "block"
+	// FirmwareEnforcementWarn allocates the servers, but records a warning event.
+	FirmwareEnforcementWarn FirmwareEnforcement = "warn"
+)
+
+// BIOSRequirement defines the minimum BIOS version for the matching servers.
+type BIOSRequirement struct {
+	// System manufacturer of the servers the requirement applies to, shell pattern, empty matches any.
+	// +optional
+	Manufacturer string `json:"manufacturer,omitempty"`
+	// System product name of the servers the requirement applies to, shell pattern, empty matches any.
+	// +optional
+	ProductName string `json:"productName,omitempty"`
+	// Minimum BIOS version, versions are compared segment by segment (numeric segments are compared as numbers).
+	// +optional
+	MinVersion string `json:"minVersion,omitempty"`
+	// Minimum BIOS release date in `YYYY-MM-DD` format.
+	// +optional
+	MinReleaseDate string `json:"minReleaseDate,omitempty"`
+}
+
+// FirmwareRequirements defines the firmware the servers should have to boot the environment.
+type FirmwareRequirements struct {
+	// Action when the requirements are not met: `block` or `warn`.
+	// +kubebuilder:validation:Enum=block;warn
+	// +optional
+	Enforcement FirmwareEnforcement `json:"enforcement,omitempty"`
+	// BIOS requirements, all the requirements matching the server should be met.
+	// +optional
+	BIOS []BIOSRequirement `json:"bios,omitempty"`
+}
+
+// DefaultImageFactoryURL is the public Talos Image Factory.
+const DefaultImageFactoryURL = "https://factory.talos.dev"
+
+// ImageFactory defines the kernel and initramfs built by the Talos Image Factory from a schematic.
+type ImageFactory struct {
+	// Schematic ID returned by the Image Factory for the image customization (e.g. with system extensions).
+	// +kubebuilder:validation:Pattern=`^[0-9a-f]{64}$`
+	SchematicID string `json:"schematicID"`
+	// Talos version, e.g. `v1.6.0`.
+	TalosVersion string `json:"talosVersion"`
+	// Architecture of the assets: `amd64` (default) or `arm64`.
+	// +kubebuilder:validation:Enum=amd64;arm64
+	// +optional
+	Arch string `json:"arch,omitempty"`
+	// Image Factory URL, defaults to https://factory.talos.dev.
+	// +optional
+	URL string `json:"url,omitempty"`
+}
+
+func (f *ImageFactory) assetURL(name string) string {
+	base := f.URL
+	if base == "" {
+		base = DefaultImageFactoryURL
+	}
+
+	arch := f.Arch
+	if arch == "" {
+		arch = "amd64"
+	}
+
+	return fmt.Sprintf("%s/image/%s/%s/%s", strings.TrimRight(base, "/"), f.SchematicID, f.TalosVersion, fmt.Sprintf(name, arch))
+}
+
 // EnvironmentSpec defines the desired state of Environment.
 type EnvironmentSpec struct {
 	Kernel Kernel `json:"kernel,omitempty"`
 	Initrd Initrd `json:"initrd,omitempty"`
+	// Image Factory schematic to download the kernel and initramfs from, if the kernel and initrd URLs are not set.
+	//
+	// Kernel and initrd `sha512` fields still pin the checksums of the assets.
+	// +optional
+	ImageFactory *ImageFactory `json:"imageFactory,omitempty"`
+	// Minimum firmware the servers should have to be allocated with this environment.
+	// +optional
+	Firmware *FirmwareRequirements `json:"firmware,omitempty"`
+	// Set of config patches to apply to the machine configuration of the servers booted with this environment.
+	// +optional
+	ConfigPatches []ConfigPatches `json:"configPatches,omitempty"`
+	// Config patches stored in ConfigMaps and Secrets, applied after configPatches in the listed order.
+	// +optional
+	ConfigPatchesFrom []ConfigPatchesRef `json:"configPatchesFrom,omitempty"`
+}
+
+// KernelAsset returns the kernel asset, resolving the Image Factory URL if needed.
+func (spec *EnvironmentSpec) KernelAsset() Asset {
+	asset := spec.Kernel.Asset
+
+	if asset.URL == "" && spec.ImageFactory != nil {
+		asset.URL = spec.ImageFactory.assetURL("kernel-%s")
+	}
+
+	return asset
+}
+
+// InitrdAsset returns the initrd asset, resolving the Image Factory URL if needed.
+func (spec *EnvironmentSpec) InitrdAsset() Asset {
+	asset := spec.Initrd.Asset
+
+	if asset.URL == "" && spec.ImageFactory != nil {
+		asset.URL = spec.ImageFactory.assetURL("initramfs-%s.xz")
+	}
+
+	return asset
 }
 
+// Asset condition reasons.
+const (
+	// AssetReasonDownloading is set while the asset is being downloaded.
+	AssetReasonDownloading = "Downloading"
+	// AssetReasonVerified is set when the checksum of the asset matches the one in the spec.
+	AssetReasonVerified = "Verified"
+	// AssetReasonDownloaded is set when the checksum is not pinned in the spec, the checksum of the asset is recorded in the condition.
+	AssetReasonDownloaded = "Downloaded"
+	// AssetReasonChecksumMismatch is set when the checksum of the asset doesn't match the one in the spec.
+	AssetReasonChecksumMismatch = "ChecksumMismatch"
+	// AssetReasonFailed is set when the asset download failed.
+	AssetReasonFailed = "DownloadFailed"
+)
+
+// AssetCondition is the state of the environment asset.
+//
+// URL is the resolved URL of the asset, and SHA512 is the checksum of the downloaded asset.
 type AssetCondition struct {
 	Asset  `json:",inline"`
 	Status string `json:"status"`
 	Type   string `json:"type"`
+	// Reason for the condition's last transition.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+	// Human-readable message with the details.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Bytes downloaded so far.
+	// +optional
+	DownloadedBytes int64 `json:"downloadedBytes,omitempty"`
+	// Size of the asset, if known.
+	// +optional
+	TotalBytes int64 `json:"totalBytes,omitempty"`
+}
+
+// Site replication states.
+const (
+	// ReplicationPending is set while the asset waits for the transfer window.
+	ReplicationPending = "Pending"
+	// ReplicationTransferring is set while the asset is uploaded to the site cache.
+	ReplicationTransferring = "Transferring"
+	// ReplicationReplicated is set when the site cache has the asset with the matching checksum.
+	ReplicationReplicated = "Replicated"
+	// ReplicationFailed is set when the transfer failed, it's retried with backoff.
+	ReplicationFailed = "Failed"
+)
+
+// SiteReplication is the state of the environment asset in the SiteCache.
+//
+// URL is the resolved URL of the asset, and SHA512 is the checksum of the replicated (downloaded) asset.
+type SiteReplication struct {
+	// Site is the name of the SiteCache.
+	Site  string `json:"site"`
+	Asset `json:",inline"`
+	State string `json:"state"`
+	// Human-readable message with the details.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Bytes the site cache has so far, including the bytes transferred before the transfer was interrupted.
+	// +optional
+	TransferredBytes int64 `json:"transferredBytes,omitempty"`
+	// Size of the asset.
+	// +optional
+	TotalBytes int64 `json:"totalBytes,omitempty"`
+	// Last time the asset was transferred to the site cache.
+	// +optional
+	LastTransferTime *metav1.Time `json:"lastTransferTime,omitempty"`
 }
 
 // EnvironmentStatus defines the observed state of Environment.
 type EnvironmentStatus struct {
 	Conditions []AssetCondition `json:"conditions,omitempty"`
+	// Replication state of the assets in the site caches.
+	// +optional
+	Replicas []SiteReplication `json:"replicas,omitempty"`
+}
+
+// AssetReady returns true if the asset was downloaded from the URL and matches the checksum (if pinned).
+func (env *Environment) AssetReady(asset Asset) bool {
+	return env.ReadyAsset(asset) != nil
+}
+
+// IsReady returns true if both the kernel and initrd are downloaded and verified, so the servers can boot the environment.
+func (env *Environment) IsReady() bool {
+	return env.AssetReady(env.Spec.KernelAsset()) && env.AssetReady(env.Spec.InitrdAsset())
+}
+
+// ReadyAsset returns the condition of the downloaded (and verified) asset, or nil if the asset is not ready.
+func (env *Environment) ReadyAsset(asset Asset) *AssetCondition {
+	for i, condition := range env.Status.Conditions {
+		if condition.Type == "Ready" && condition.Status == "True" && condition.URL == asset.URL &&
+			(asset.SHA512 == "" || strings.EqualFold(condition.SHA512, asset.SHA512)) {
+			return &env.Status.Conditions[i]
+		}
+	}
+
+	return nil
+}
+
+// ReplicatedTo returns true if both the kernel and initrd are ready and replicated to the SiteCache.
+func (env *Environment) ReplicatedTo(site string) bool {
+	for _, asset := range []Asset{env.Spec.KernelAsset(), env.Spec.InitrdAsset()} {
+		ready := env.ReadyAsset(asset)
+		if ready == nil {
+			return false
+		}
+
+		replicated := false
+
+		for _, replica := range env.Status.Replicas {
+			if replica.Site == site && replica.URL == ready.URL && strings.EqualFold(replica.SHA512, ready.SHA512) &&
+				replica.State == ReplicationReplicated {
+				replicated = true
+
+				break
+			}
+		}
+
+		if !replicated {
+			return false
+		}
+	}
+
+	return true
 }
 
 // +kubebuilder:object:root=true
@@ -52,6 +283,8 @@ type EnvironmentStatus struct {
 // +kubebuilder:resource:scope=Cluster
 // +kubebuilder:printcolumn:name="Kernel",type="string",JSONPath=".spec.kernel.url",description="the kernel for the environment"
 // +kubebuilder:printcolumn:name="Initrd",type="string",JSONPath=".spec.initrd.url",description="the initrd for the environment"
+// +kubebuilder:printcolumn:name="Schematic",type="string",JSONPath=".spec.imageFactory.schematicID",description="the Image Factory schematic of the environment",priority=1
+// +kubebuilder:printcolumn:name="Talos",type="string",JSONPath=".spec.imageFactory.talosVersion",description="the Talos version of the Image Factory assets",priority=1
 // +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status",description="indicates the readiness of the environment"
 
 // Environment is the Schema for the environments API.
@@ -78,7 +311,7 @@ func EnvironmentDefaultSpec(talosRelease, apiEndpoint string, apiPort uint16) *E
 	args = append(args, kernel.DefaultArgs...)
 	args = append(args, "console=tty0", "console=ttyS0", "earlyprintk=ttyS0")
 	args = append(args, "initrd=initramfs.xz", "talos.platform=metal")
-	args = append(args, fmt.Sprintf("talos.config=http://%s:%d/configdata?uuid=", apiEndpoint, apiPort))
+	args = append(args, fmt.Sprintf("talos.config=http://%s/configdata?uuid=", net.JoinHostPort(apiEndpoint, strconv.Itoa(int(apiPort)))))
 	sort.Strings(args)
 
 	return &EnvironmentSpec{
@@ -0,0 +1,55 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1
+
+import (
+	"reflect"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-metal-sidero-dev-v1alpha1-environment,mutating=false,failurePolicy=fail,sideEffects=None,admissionReviewVersions=v1beta1,groups=metal.sidero.dev,resources=environments,versions=v1alpha1,name=venvironment.metal.sidero.dev
+
+var _ webhook.Validator = &Environment{}
+
+// SetupWebhookWithManager registers the Environment validating webhook.
+func (env *Environment) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(env).
+		Complete()
+}
+
+// ValidateCreate implements webhook.Validator.
+func (env *Environment) ValidateCreate() error {
+	return env.validate()
+}
+
+// ValidateUpdate implements webhook.Validator.
+//
+// Environments with invalid kernel args created before the validation was introduced can still be updated
+// (e.g. labeled), as long as the kernel args are not changed.
+func (env *Environment) ValidateUpdate(old runtime.Object) error {
+	if oldEnv, ok := old.(*Environment); ok && reflect.DeepEqual(oldEnv.Spec.Kernel.Args, env.Spec.Kernel.Args) {
+		return nil
+	}
+
+	return env.validate()
+}
+
+// ValidateDelete implements webhook.Validator.
+func (env *Environment) ValidateDelete() error {
+	return nil
+}
+
+func (env *Environment) validate() error {
+	if errs := env.ValidateKernelArgs(); len(errs) > 0 {
+		return apierrors.NewInvalid(GroupVersion.WithKind("Environment").GroupKind(), env.Name, errs)
+	}
+
+	return nil
+}
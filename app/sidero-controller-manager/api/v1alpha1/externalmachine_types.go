@@ -0,0 +1,110 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ExternalMachineSpec defines the machine not managed by Sidero (e.g. a virtual machine managed by another CAPI provider)
+// which is served the environment and the machine configuration.
+type ExternalMachineSpec struct {
+	// MACs are the MAC addresses of the machine network interfaces, the machine is identified by any of them.
+	// +kubebuilder:validation:MinItems=1
+	MACs []string `json:"macs"`
+	// EnvironmentRef is the environment the machine is booted into, the default environment is used if not set.
+	// +optional
+	EnvironmentRef *corev1.ObjectReference `json:"environmentRef,omitempty"`
+	// MachineRef is the CAPI Machine the machine configuration is taken from (bootstrap data secret),
+	// the namespace defaults to `default`.
+	//
+	// The machine configuration is not served if not set.
+	// +optional
+	MachineRef *corev1.ObjectReference `json:"machineRef,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="MACs",type="string",JSONPath=".spec.macs",description="MAC addresses of the machine"
+// +kubebuilder:printcolumn:name="Environment",type="string",JSONPath=".spec.environmentRef.name",description="environment the machine is booted into"
+// +kubebuilder:printcolumn:name="Machine",type="string",JSONPath=".spec.machineRef.name",description="machine the configuration is taken from"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ExternalMachine is the Schema for the externalmachines API.
+//
+// External machines are only served if Sidero is started with `--external-machines`.
+type ExternalMachine struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec ExternalMachineSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// ExternalMachineList contains a list of ExternalMachine.
+type ExternalMachineList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ExternalMachine `json:"items"`
+}
+
+// HasMAC returns true if the MAC address is one of the machine MAC addresses.
+func (m *ExternalMachine) HasMAC(mac net.HardwareAddr) bool {
+	for _, s := range m.Spec.MACs {
+		if normalizeMAC(s) == normalizeMAC(mac.String()) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LookupExternalMachine returns the external machine with the MAC address, or nil if the MAC address is not allowlisted.
+//
+// MAC address allowlisted by several external machines is an error, as the machine configuration would be ambiguous.
+func LookupExternalMachine(ctx context.Context, reader client.Reader, mac net.HardwareAddr) (*ExternalMachine, error) {
+	var machines ExternalMachineList
+
+	if err := reader.List(ctx, &machines); err != nil {
+		return nil, err
+	}
+
+	var matched []*ExternalMachine
+
+	for i := range machines.Items {
+		if machines.Items[i].HasMAC(mac) {
+			matched = append(matched, &machines.Items[i])
+		}
+	}
+
+	switch len(matched) {
+	case 0:
+		return nil, nil
+	case 1:
+		return matched[0], nil
+	default:
+		names := make([]string, 0, len(matched))
+
+		for _, m := range matched {
+			names = append(names, m.Name)
+		}
+
+		sort.Strings(names)
+
+		return nil, fmt.Errorf("MAC address %s is allowlisted by several external machines: %v", mac, names)
+	}
+}
+
+func init() {
+	SchemeBuilder.Register(&ExternalMachine{}, &ExternalMachineList{})
+}
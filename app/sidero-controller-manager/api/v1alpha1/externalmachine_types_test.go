@@ -0,0 +1,89 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+func TestLookupExternalMachine(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	newMachine := func(name string, macs ...string) *v1alpha1.ExternalMachine {
+		return &v1alpha1.ExternalMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1alpha1.ExternalMachineSpec{MACs: macs},
+		}
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme,
+		newMachine("vm-1", "52:54:00:12:34:56", "52-54-00-12-34-57"),
+		newMachine("vm-2", "52:54:00:AB:CD:EF"),
+		newMachine("vm-3", "52:54:00:ab:cd:ef"),
+	)
+
+	for name, tc := range map[string]struct {
+		mac           string
+		expected      string
+		expectedError bool
+	}{
+		"first mac": {
+			mac:      "52:54:00:12:34:56",
+			expected: "vm-1",
+		},
+		"second mac": {
+			mac:      "52:54:00:12:34:57",
+			expected: "vm-1",
+		},
+		"not allowlisted": {
+			mac: "52:54:00:12:34:58",
+		},
+		"ambiguous": {
+			mac:           "52:54:00:ab:cd:ef",
+			expectedError: true,
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			mac, err := net.ParseMAC(tc.mac)
+			require.NoError(t, err)
+
+			machine, err := v1alpha1.LookupExternalMachine(context.Background(), c, mac)
+
+			if tc.expectedError {
+				assert.Error(t, err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			if tc.expected == "" {
+				assert.Nil(t, machine)
+
+				return
+			}
+
+			require.NotNil(t, machine)
+			assert.Equal(t, tc.expected, machine.Name)
+		})
+	}
+}
@@ -0,0 +1,196 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/hashicorp/go-multierror"
+)
+
+// smbiosDateLayout is the format of the BIOS release date in SMBIOS.
+const smbiosDateLayout = "01/02/2006"
+
+// firmwareDateLayout is the format of the release date in the requirements.
+const firmwareDateLayout = "2006-01-02"
+
+// Blocking returns true if the servers not meeting the requirements should not be allocated.
+func (r *FirmwareRequirements) Blocking() bool {
+	return r != nil && r.Enforcement != FirmwareEnforcementWarn
+}
+
+// Check the server against the firmware requirements.
+//
+// Error lists all the requirements which are not met.
+func (r *FirmwareRequirements) Check(server *Server) error {
+	if r == nil {
+		return nil
+	}
+
+	var result *multierror.Error
+
+	for _, req := range r.BIOS {
+		if !req.Applies(server) {
+			continue
+		}
+
+		if err := req.Check(server.Spec.BIOS); err != nil {
+			result = multierror.Append(result, err)
+		}
+	}
+
+	if result != nil {
+		result.ErrorFormat = func(errs []error) string {
+			messages := make([]string, 0, len(errs))
+
+			for _, err := range errs {
+				messages = append(messages, err.Error())
+			}
+
+			return strings.Join(messages, "; ")
+		}
+	}
+
+	return result.ErrorOrNil()
+}
+
+// Applies returns true if the requirement applies to the server.
+func (req *BIOSRequirement) Applies(server *Server) bool {
+	var manufacturer, productName string
+
+	if sysInfo := server.Spec.SystemInformation; sysInfo != nil {
+		manufacturer, productName = sysInfo.Manufacturer, sysInfo.ProductName
+	}
+
+	for _, pair := range [][2]string{
+		{req.Manufacturer, manufacturer},
+		{req.ProductName, productName},
+	} {
+		if pair[0] == "" {
+			continue
+		}
+
+		if matched, err := path.Match(pair[0], pair[1]); err != nil || !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Check the BIOS against the requirement.
+func (req *BIOSRequirement) Check(bios *BIOSInformation) error {
+	if bios == nil {
+		return fmt.Errorf("BIOS information is not available")
+	}
+
+	if req.MinVersion != "" && CompareVersions(bios.Version, req.MinVersion) < 0 {
+		return fmt.Errorf("BIOS version %q is older than %q", bios.Version, req.MinVersion)
+	}
+
+	if req.MinReleaseDate != "" {
+		minDate, err := time.Parse(firmwareDateLayout, req.MinReleaseDate)
+		if err != nil {
+			return fmt.Errorf("invalid minimum BIOS release date %q: %w", req.MinReleaseDate, err)
+		}
+
+		date, err := time.Parse(smbiosDateLayout, bios.ReleaseDate)
+		if err != nil {
+			return fmt.Errorf("unknown BIOS release date %q", bios.ReleaseDate)
+		}
+
+		if date.Before(minDate) {
+			return fmt.Errorf("BIOS release date %s is older than %s", date.Format(firmwareDateLayout), req.MinReleaseDate)
+		}
+	}
+
+	return nil
+}
+
+// CompareVersions compares firmware versions, returning -1, 0 or 1.
+//
+// Versions are split into numeric and non-numeric segments (`P3.50` is `P`, `3`, `50`),
+// numeric segments are compared as numbers, others as case-insensitive strings.
+func CompareVersions(a, b string) int {
+	sa, sb := versionSegments(a), versionSegments(b)
+
+	for i := 0; i < len(sa) || i < len(sb); i++ {
+		if i >= len(sa) {
+			return -1
+		}
+
+		if i >= len(sb) {
+			return 1
+		}
+
+		na, errA := strconv.ParseUint(sa[i], 10, 64)
+		nb, errB := strconv.ParseUint(sb[i], 10, 64)
+
+		switch {
+		case errA == nil && errB == nil:
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+
+				return 1
+			}
+		default:
+			if c := strings.Compare(strings.ToLower(sa[i]), strings.ToLower(sb[i])); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return 0
+}
+
+func versionSegments(version string) []string {
+	var (
+		segments []string
+		current  strings.Builder
+		digits   bool
+	)
+
+	flush := func() {
+		if current.Len() > 0 {
+			segments = append(segments, current.String())
+			current.Reset()
+		}
+	}
+
+	for _, r := range version {
+		switch {
+		case unicode.IsDigit(r):
+			if !digits {
+				flush()
+			}
+
+			digits = true
+
+			current.WriteRune(r)
+		case unicode.IsLetter(r):
+			if digits {
+				flush()
+			}
+
+			digits = false
+
+			current.WriteRune(r)
+		default:
+			// separators
+			flush()
+		}
+	}
+
+	flush()
+
+	return segments
+}
@@ -0,0 +1,118 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+func TestCompareVersions(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		a, b     string
+		expected int
+	}{
+		{"2.10.2", "2.10.2", 0},
+		{"2.10.2", "2.9.0", 1},
+		{"2.9", "2.10", -1},
+		{"1.4", "1.4.1", -1},
+		{"F20", "F9", 1},
+		{"P3.50", "p3.50", 0},
+		{"P3.40", "P3.50", -1},
+		{"U30 v2.54", "U30 v2.60", -1},
+	} {
+		assert.Equal(t, tc.expected, metalv1alpha1.CompareVersions(tc.a, tc.b), "%q vs %q", tc.a, tc.b)
+		assert.Equal(t, -tc.expected, metalv1alpha1.CompareVersions(tc.b, tc.a), "%q vs %q", tc.b, tc.a)
+	}
+}
+
+func TestFirmwareRequirements(t *testing.T) {
+	t.Parallel()
+
+	server := func(manufacturer string, bios *metalv1alpha1.BIOSInformation) *metalv1alpha1.Server {
+		return &metalv1alpha1.Server{
+			Spec: metalv1alpha1.ServerSpec{
+				SystemInformation: &metalv1alpha1.SystemInformation{
+					Manufacturer: manufacturer,
+					ProductName:  "PowerEdge R640",
+				},
+				BIOS: bios,
+			},
+		}
+	}
+
+	requirements := &metalv1alpha1.FirmwareRequirements{
+		BIOS: []metalv1alpha1.BIOSRequirement{
+			{
+				Manufacturer: "Dell*",
+				MinVersion:   "2.10.2",
+			},
+			{
+				ProductName:    "PowerEdge*",
+				MinReleaseDate: "2021-01-01",
+			},
+		},
+	}
+
+	for name, tc := range map[string]struct {
+		server   *metalv1alpha1.Server
+		expected []string
+	}{
+		"up to date": {
+			server: server("Dell Inc.", &metalv1alpha1.BIOSInformation{Version: "2.11.2", ReleaseDate: "03/15/2021"}),
+		},
+		"old version": {
+			server:   server("Dell Inc.", &metalv1alpha1.BIOSInformation{Version: "2.9.4", ReleaseDate: "03/15/2021"}),
+			expected: []string{`BIOS version "2.9.4" is older than "2.10.2"`},
+		},
+		"old version and date": {
+			server: server("Dell Inc.", &metalv1alpha1.BIOSInformation{Version: "2.9.4", ReleaseDate: "11/20/2020"}),
+			expected: []string{
+				`BIOS version "2.9.4" is older than "2.10.2"`,
+				`BIOS release date 2020-11-20 is older than 2021-01-01`,
+			},
+		},
+		"other manufacturer": {
+			server:   server("Supermicro", &metalv1alpha1.BIOSInformation{Version: "1.0", ReleaseDate: "11/20/2020"}),
+			expected: []string{`BIOS release date 2020-11-20 is older than 2021-01-01`},
+		},
+		"no BIOS information": {
+			server: server("Dell Inc.", nil),
+			expected: []string{
+				`BIOS information is not available`,
+				`BIOS information is not available`,
+			},
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := requirements.Check(tc.server)
+
+			if tc.expected == nil {
+				assert.NoError(t, err)
+
+				return
+			}
+
+			for _, expected := range tc.expected {
+				assert.Contains(t, err.Error(), expected)
+			}
+		})
+	}
+
+	var noRequirements *metalv1alpha1.FirmwareRequirements
+
+	assert.NoError(t, noRequirements.Check(server("Dell Inc.", nil)))
+	assert.False(t, noRequirements.Blocking())
+	assert.True(t, requirements.Blocking())
+}
@@ -0,0 +1,80 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1
+
+import (
+	"context"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FreezeSpec defines the break-glass freeze of the provisioning plane.
+type FreezeSpec struct {
+	// Frozen stops all the mutating operations: servers are not powered on/off or PXE booted,
+	// agents are not instructed to wipe the disks or set up the BMC, and servers are not allocated to the machines.
+	//
+	// Status, metrics, the machine configuration and the boot assets are still served.
+	// +optional
+	Frozen bool `json:"frozen,omitempty"`
+	// Reason of the freeze, e.g. the incident reference.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Frozen",type="boolean",JSONPath=".spec.frozen",description="mutating operations are stopped"
+// +kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".spec.reason",description="reason of the freeze"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// Freeze is the Schema for the freezes API.
+//
+// Provisioning plane is frozen while any of the Freeze resources is frozen.
+type Freeze struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec FreezeSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// FreezeList contains a list of Freeze.
+type FreezeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Freeze `json:"items"`
+}
+
+// ActiveFreeze returns the first (by name) Freeze which is frozen, or nil if the provisioning plane is not frozen.
+func ActiveFreeze(ctx context.Context, reader client.Reader) (*Freeze, error) {
+	var freezes FreezeList
+
+	if err := reader.List(ctx, &freezes); err != nil {
+		// CRD is not installed yet, e.g. while the providers are being upgraded
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	sort.Slice(freezes.Items, func(i, j int) bool { return freezes.Items[i].Name < freezes.Items[j].Name })
+
+	for i := range freezes.Items {
+		if freezes.Items[i].Spec.Frozen {
+			return &freezes.Items[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+func init() {
+	SchemeBuilder.Register(&Freeze{}, &FreezeList{})
+}
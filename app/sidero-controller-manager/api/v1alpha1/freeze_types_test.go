@@ -0,0 +1,64 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+func TestActiveFreeze(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	newFreeze := func(name string, frozen bool) *v1alpha1.Freeze {
+		return &v1alpha1.Freeze{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec:       v1alpha1.FreezeSpec{Frozen: frozen, Reason: "incident"},
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		objects  []runtime.Object
+		expected string
+	}{
+		"none": {},
+		"lifted": {
+			objects: []runtime.Object{newFreeze("incident", false)},
+		},
+		"frozen": {
+			objects:  []runtime.Object{newFreeze("lifted", false), newFreeze("outage", true), newFreeze("upgrade", true)},
+			expected: "outage",
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			freeze, err := v1alpha1.ActiveFreeze(context.Background(), fake.NewFakeClientWithScheme(scheme, tc.objects...))
+			require.NoError(t, err)
+
+			if tc.expected == "" {
+				assert.Nil(t, freeze)
+
+				return
+			}
+
+			require.NotNil(t, freeze)
+			assert.Equal(t, tc.expected, freeze.Name)
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// Location labels set on the Server from the chassis location detected by the agent.
+const (
+	// ChassisLabel is the serial number of the blade enclosure or the multi-node chassis.
+	ChassisLabel = "metal.sidero.dev/chassis"
+	// ChassisSlotLabel is the slot (node) of the server in the chassis.
+	ChassisSlotLabel = "metal.sidero.dev/chassis-slot"
+)
+
+// ChassisLocation is the location of the server in the chassis detected by the agent (SMBIOS, IPMI FRU).
+type ChassisLocation struct {
+	// SerialNumber of the chassis.
+	// +optional
+	SerialNumber string `json:"serialNumber,omitempty"`
+	// AssetTag of the chassis.
+	// +optional
+	AssetTag string `json:"assetTag,omitempty"`
+	// Slot of the server in the chassis, e.g. `Slot 3` or `Node 2`.
+	// +optional
+	Slot string `json:"slot,omitempty"`
+}
+
+// Labels returns the location labels, the values are sanitized to be valid label values.
+//
+// Values which are not detected (or can't be converted to the label values) are skipped.
+func (l *ChassisLocation) Labels() map[string]string {
+	labels := map[string]string{}
+
+	if l == nil {
+		return labels
+	}
+
+	for key, value := range map[string]string{
+		ChassisLabel:     l.SerialNumber,
+		ChassisSlotLabel: l.Slot,
+	} {
+		if value = LabelValue(value); value != "" {
+			labels[key] = value
+		}
+	}
+
+	return labels
+}
+
+var invalidLabelChars = regexp.MustCompile(`[^-A-Za-z0-9_.]+`)
+
+// LabelValue converts the string to the label value replacing the invalid characters, empty if it can't be converted.
+func LabelValue(s string) string {
+	s = strings.Trim(invalidLabelChars.ReplaceAllString(strings.TrimSpace(s), "-"), "-_.")
+
+	if len(s) > validation.LabelValueMaxLength {
+		s = strings.TrimRight(s[:validation.LabelValueMaxLength], "-_.")
+	}
+
+	if len(validation.IsValidLabelValue(s)) > 0 {
+		return ""
+	}
+
+	return s
+}
+
+// SpreadServers orders the servers to spread the allocations by the label.
+//
+// Servers with the label values (domains) which have the fewest allocated servers come first,
+// servers without the label come last, the order is otherwise preserved.
+func SpreadServers(servers []Server, label string, allocated []Server) []Server {
+	counts := map[string]int{}
+
+	for _, server := range allocated {
+		if value, ok := server.Labels[label]; ok {
+			counts[value]++
+		}
+	}
+
+	result := append([]Server(nil), servers...)
+
+	sort.SliceStable(result, func(i, j int) bool {
+		a, aok := result[i].Labels[label]
+		b, bok := result[j].Labels[label]
+
+		if aok != bok {
+			return aok
+		}
+
+		return counts[a] < counts[b]
+	})
+
+	return result
+}
@@ -0,0 +1,81 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+func TestChassisLocationLabels(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, map[string]string{
+		metalv1alpha1.ChassisLabel:     "S2-CHASSIS-0042",
+		metalv1alpha1.ChassisSlotLabel: "Node-2",
+	}, (&metalv1alpha1.ChassisLocation{SerialNumber: " S2/CHASSIS 0042 ", Slot: "Node 2"}).Labels())
+
+	assert.Equal(t, map[string]string{
+		metalv1alpha1.ChassisSlotLabel: "3",
+	}, (&metalv1alpha1.ChassisLocation{SerialNumber: "???", Slot: "3"}).Labels())
+
+	assert.Empty(t, (*metalv1alpha1.ChassisLocation)(nil).Labels())
+
+	assert.Len(t, metalv1alpha1.LabelValue(strings.Repeat("a", 62)+"-b"), 62)
+}
+
+func TestSpreadServers(t *testing.T) {
+	t.Parallel()
+
+	server := func(name, chassis string) metalv1alpha1.Server {
+		s := metalv1alpha1.Server{ObjectMeta: metav1.ObjectMeta{Name: name}}
+
+		if chassis != "" {
+			s.Labels = map[string]string{metalv1alpha1.ChassisLabel: chassis}
+		}
+
+		return s
+	}
+
+	names := func(servers []metalv1alpha1.Server) []string {
+		result := make([]string, 0, len(servers))
+
+		for _, s := range servers {
+			result = append(result, s.Name)
+		}
+
+		return result
+	}
+
+	available := []metalv1alpha1.Server{
+		server("a-1", "a"),
+		server("unlabeled", ""),
+		server("a-2", "a"),
+		server("b-1", "b"),
+		server("c-1", "c"),
+	}
+
+	allocated := []metalv1alpha1.Server{
+		server("a-0", "a"),
+		server("a-3", "a"),
+		server("b-0", "b"),
+		server("other", ""),
+	}
+
+	assert.Equal(t, []string{"c-1", "b-1", "a-1", "a-2", "unlabeled"},
+		names(metalv1alpha1.SpreadServers(available, metalv1alpha1.ChassisLabel, allocated)))
+
+	// nothing allocated yet, labeled servers first
+	assert.Equal(t, []string{"a-1", "a-2", "b-1", "c-1", "unlabeled"},
+		names(metalv1alpha1.SpreadServers(available, metalv1alpha1.ChassisLabel, nil)))
+
+	// input is not modified
+	assert.Equal(t, "unlabeled", available[1].Name)
+}
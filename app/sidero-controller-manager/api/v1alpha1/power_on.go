@@ -0,0 +1,152 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1
+
+import (
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+// PowerOnPolicy orders the power on of the servers, e.g. after a site outage the storage servers
+// are powered on and become healthy before the compute servers.
+type PowerOnPolicy struct {
+	// After lists the dependencies which should be satisfied before the server is powered on.
+	// +optional
+	After []PowerOnDependency `json:"after,omitempty"`
+	// Timeout after which the server is powered on even if the dependencies are not satisfied,
+	// counted since the server started waiting for the (current set of) unsatisfied dependencies.
+	// Server waits indefinitely if not set.
+	// +optional
+	Timeout *metav1.Duration `json:"timeout,omitempty"`
+}
+
+// PowerOnDependency is satisfied when the accepted servers of the ServerClass are powered on and healthy.
+type PowerOnDependency struct {
+	// ServerClass which servers are powered on first.
+	ServerClass string `json:"serverClass"`
+	// HealthCondition is the Server condition type which should be True on the servers of the class (health signal),
+	// e.g. set by the external health checks of the storage cluster.
+	// If not set, servers are only required to be powered on.
+	// +optional
+	HealthCondition clusterv1.ConditionType `json:"healthCondition,omitempty"`
+	// MinReady is the number of the servers of the class which should be powered on and healthy,
+	// all accepted servers of the class if not set.
+	// +optional
+	MinReady int `json:"minReady,omitempty"`
+}
+
+// PendingDependency is the unsatisfied dependency with the number of the servers ready.
+type PendingDependency struct {
+	PowerOnDependency
+
+	Ready    int
+	Required int
+}
+
+func (d PendingDependency) String() string {
+	return fmt.Sprintf("ServerClass %q (%d/%d ready)", d.ServerClass, d.Ready, d.Required)
+}
+
+// ResolvePowerOnPolicy resolves the power on policy for the server.
+//
+// Server power on policy takes precedence, otherwise the policy of the first (by name) matching ServerClass is used.
+func ResolvePowerOnPolicy(server *Server, serverClasses []ServerClass) (*PowerOnPolicy, error) {
+	if server.Spec.PowerOnPolicy != nil {
+		return server.Spec.PowerOnPolicy, nil
+	}
+
+	serverClasses = append([]ServerClass(nil), serverClasses...)
+	sort.Slice(serverClasses, func(i, j int) bool { return serverClasses[i].Name < serverClasses[j].Name })
+
+	for i := range serverClasses {
+		if serverClasses[i].Spec.PowerOnPolicy == nil {
+			continue
+		}
+
+		matches, err := FilterServers([]Server{*server}, serverClasses[i].SelectorFilter(), serverClasses[i].QualifiersFilter())
+		if err != nil {
+			return nil, err
+		}
+
+		if len(matches) > 0 {
+			return serverClasses[i].Spec.PowerOnPolicy, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Pending returns the dependencies of the server which are not satisfied.
+//
+// The server itself is never counted as a dependency, so that the servers of the class don't wait for each other.
+func (p *PowerOnPolicy) Pending(server *Server, servers []Server, serverClasses []ServerClass) ([]PendingDependency, error) {
+	classes := make(map[string]*ServerClass, len(serverClasses))
+
+	for i := range serverClasses {
+		classes[serverClasses[i].Name] = &serverClasses[i]
+	}
+
+	var pending []PendingDependency
+
+	for _, dependency := range p.After {
+		serverClass, ok := classes[dependency.ServerClass]
+		if !ok {
+			return nil, fmt.Errorf("power on dependency ServerClass %q not found", dependency.ServerClass)
+		}
+
+		matches, err := FilterServers(servers,
+			AcceptedServerFilter,
+			func(s Server) (bool, error) { return s.Name != server.Name, nil },
+			serverClass.SelectorFilter(),
+			serverClass.QualifiersFilter(),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		required := dependency.MinReady
+		if required == 0 || required > len(matches) {
+			required = len(matches)
+		}
+
+		ready := 0
+
+		for i := range matches {
+			if matches[i].Status.Power != "on" {
+				continue
+			}
+
+			if dependency.HealthCondition != "" && !hasTrueCondition(&matches[i], dependency.HealthCondition) {
+				continue
+			}
+
+			ready++
+		}
+
+		if ready < required {
+			pending = append(pending, PendingDependency{
+				PowerOnDependency: dependency,
+				Ready:             ready,
+				Required:          required,
+			})
+		}
+	}
+
+	return pending, nil
+}
+
+func hasTrueCondition(server *Server, conditionType clusterv1.ConditionType) bool {
+	for _, condition := range server.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+
+	return false
+}
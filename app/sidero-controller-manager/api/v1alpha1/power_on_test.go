@@ -0,0 +1,158 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+func TestResolvePowerOnPolicy(t *testing.T) {
+	t.Parallel()
+
+	classPolicy := &metalv1alpha1.PowerOnPolicy{
+		After: []metalv1alpha1.PowerOnDependency{{ServerClass: "storage"}},
+	}
+	serverPolicy := &metalv1alpha1.PowerOnPolicy{}
+
+	serverClasses := []metalv1alpha1.ServerClass{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "z-all"},
+			Spec: metalv1alpha1.ServerClassSpec{
+				PowerOnPolicy: &metalv1alpha1.PowerOnPolicy{},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "compute"},
+			Spec: metalv1alpha1.ServerClassSpec{
+				Selector:      metav1.LabelSelector{MatchLabels: map[string]string{"role": "compute"}},
+				PowerOnPolicy: classPolicy,
+			},
+		},
+	}
+
+	server := metalv1alpha1.Server{
+		ObjectMeta: metav1.ObjectMeta{Name: "compute-1", Labels: map[string]string{"role": "compute"}},
+	}
+
+	policy, err := metalv1alpha1.ResolvePowerOnPolicy(&server, serverClasses)
+	require.NoError(t, err)
+	assert.Same(t, classPolicy, policy)
+
+	server.Spec.PowerOnPolicy = serverPolicy
+
+	policy, err = metalv1alpha1.ResolvePowerOnPolicy(&server, serverClasses)
+	require.NoError(t, err)
+	assert.Same(t, serverPolicy, policy)
+
+	policy, err = metalv1alpha1.ResolvePowerOnPolicy(&metalv1alpha1.Server{}, serverClasses[1:])
+	require.NoError(t, err)
+	assert.Nil(t, policy)
+}
+
+func TestPowerOnPolicyPending(t *testing.T) {
+	t.Parallel()
+
+	serverClasses := []metalv1alpha1.ServerClass{
+		{
+			ObjectMeta: metav1.ObjectMeta{Name: "storage"},
+			Spec: metalv1alpha1.ServerClassSpec{
+				Selector: metav1.LabelSelector{MatchLabels: map[string]string{"role": "storage"}},
+			},
+		},
+	}
+
+	storage := func(name string, accepted bool, power string, healthy corev1.ConditionStatus) metalv1alpha1.Server {
+		server := metalv1alpha1.Server{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"role": "storage"}},
+			Spec:       metalv1alpha1.ServerSpec{Accepted: accepted},
+			Status:     metalv1alpha1.ServerStatus{Power: power},
+		}
+
+		if healthy != "" {
+			server.Status.Conditions = clusterv1.Conditions{{Type: "CephHealthy", Status: healthy}}
+		}
+
+		return server
+	}
+
+	servers := []metalv1alpha1.Server{
+		storage("storage-1", true, "on", corev1.ConditionTrue),
+		storage("storage-2", true, "on", corev1.ConditionFalse),
+		storage("storage-3", true, "off", ""),
+		// not accepted servers are not waited for
+		storage("storage-4", false, "off", ""),
+	}
+
+	compute := &metalv1alpha1.Server{ObjectMeta: metav1.ObjectMeta{Name: "compute-1"}}
+
+	for name, tc := range map[string]struct {
+		server     *metalv1alpha1.Server
+		dependency metalv1alpha1.PowerOnDependency
+
+		expectedPending []metalv1alpha1.PendingDependency
+		expectedErr     string
+	}{
+		"powered on": {
+			server:     compute,
+			dependency: metalv1alpha1.PowerOnDependency{ServerClass: "storage"},
+			expectedPending: []metalv1alpha1.PendingDependency{
+				{PowerOnDependency: metalv1alpha1.PowerOnDependency{ServerClass: "storage"}, Ready: 2, Required: 3},
+			},
+		},
+		"healthy": {
+			server:     compute,
+			dependency: metalv1alpha1.PowerOnDependency{ServerClass: "storage", HealthCondition: "CephHealthy"},
+			expectedPending: []metalv1alpha1.PendingDependency{
+				{PowerOnDependency: metalv1alpha1.PowerOnDependency{ServerClass: "storage", HealthCondition: "CephHealthy"}, Ready: 1, Required: 3},
+			},
+		},
+		"min ready": {
+			server:     compute,
+			dependency: metalv1alpha1.PowerOnDependency{ServerClass: "storage", MinReady: 2},
+		},
+		"min ready more than servers": {
+			server:     compute,
+			dependency: metalv1alpha1.PowerOnDependency{ServerClass: "storage", HealthCondition: "CephHealthy", MinReady: 10},
+			expectedPending: []metalv1alpha1.PendingDependency{
+				{PowerOnDependency: metalv1alpha1.PowerOnDependency{ServerClass: "storage", HealthCondition: "CephHealthy", MinReady: 10}, Ready: 1, Required: 3},
+			},
+		},
+		"server itself": {
+			server:     &servers[2],
+			dependency: metalv1alpha1.PowerOnDependency{ServerClass: "storage"},
+		},
+		"missing class": {
+			server:      compute,
+			dependency:  metalv1alpha1.PowerOnDependency{ServerClass: "ceph"},
+			expectedErr: `power on dependency ServerClass "ceph" not found`,
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			policy := metalv1alpha1.PowerOnPolicy{After: []metalv1alpha1.PowerOnDependency{tc.dependency}}
+
+			pending, err := policy.Pending(tc.server, servers, serverClasses)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedPending, pending)
+		})
+	}
+}
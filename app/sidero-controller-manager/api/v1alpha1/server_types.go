@@ -8,8 +8,10 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strings"
 
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
@@ -104,6 +106,12 @@ func (a *SystemInformation) PartialEqual(b *SystemInformation) bool {
 	return PartialEqual(a, b)
 }
 
+type BIOSInformation struct {
+	Vendor      string `json:"vendor,omitempty"`
+	Version     string `json:"version,omitempty"`
+	ReleaseDate string `json:"releaseDate,omitempty"`
+}
+
 type CPUInformation struct {
 	Manufacturer string `json:"manufacturer,omitempty"`
 	Version      string `json:"version,omitempty"`
@@ -113,6 +121,19 @@ func (a *CPUInformation) PartialEqual(b *CPUInformation) bool {
 	return PartialEqual(a, b)
 }
 
+// AssetInformation is the asset management metadata of the server, it is not discovered by Sidero.
+type AssetInformation struct {
+	// PurchaseDate is the date the server was purchased.
+	// +optional
+	PurchaseDate *metav1.Time `json:"purchaseDate,omitempty"`
+	// WarrantyEnd is the date the vendor warranty (support contract) of the server ends.
+	// +optional
+	WarrantyEnd *metav1.Time `json:"warrantyEnd,omitempty"`
+	// VendorCaseURL is the URL of the vendor support case (or the asset page) of the server.
+	// +optional
+	VendorCaseURL string `json:"vendorCaseURL,omitempty"`
+}
+
 func PartialEqual(a, b interface{}) bool {
 	old := reflect.ValueOf(a)
 	new := reflect.ValueOf(b)
@@ -143,17 +164,219 @@ func PartialEqual(a, b interface{}) bool {
 	return true
 }
 
+// ServerReinventoryAnnotation requests the accepted idle server to be booted into the agent to refresh the hardware inventory.
+//
+// The annotation is removed once the agent reports the inventory.
+const ServerReinventoryAnnotation = "metal.sidero.dev/reinventory"
+
+// ServerBootFromDiskAnnotation requests the server which booted into the agent unexpectedly (see ConditionUnexpectedAgentBoot)
+// to be rebooted back from disk.
+//
+// The annotation is removed once the agent is instructed to reboot.
+const ServerBootFromDiskAnnotation = "metal.sidero.dev/boot-from-disk"
+
+// ServerUUIDLabel is set to the SMBIOS UUID of the machine merged into the server with a different name
+// by the identity webhook, so that the machine is resolved to the server (see LookupServer).
+const ServerUUIDLabel = "metal.sidero.dev/uuid"
+
+// ServerReusableAnnotation marks the server as reusable: disks with the existing data are wiped without the confirmation.
+const ServerReusableAnnotation = "metal.sidero.dev/reusable"
+
+// ServerConfirmWipeAnnotation confirms the wipe of the disks with the existing data (see ConditionWipeConfirmed).
+//
+// The annotation is removed once the server is wiped.
+const ServerConfirmWipeAnnotation = "metal.sidero.dev/confirm-wipe"
+
+// ServerPowerHoldAnnotation keeps the server powered off by the PowerAction (the value is the name of the action):
+// the server is not powered on by Sidero until it's powered on with another PowerAction, or the annotation is removed.
+const ServerPowerHoldAnnotation = "metal.sidero.dev/power-hold"
+
+// SMART health states of the disks.
+const (
+	SMARTHealthPassed = "Passed"
+	SMARTHealthFailed = "Failed"
+)
+
+// NetworkInterface is a physical network interface.
+type NetworkInterface struct {
+	Name string `json:"name"`
+	MAC  string `json:"mac,omitempty"`
+	// SpeedMbps is the link speed, zero if the link is down.
+	// +optional
+	SpeedMbps uint32 `json:"speedMbps,omitempty"`
+	// +optional
+	PCIAddress string `json:"pciAddress,omitempty"`
+	// +optional
+	Driver string `json:"driver,omitempty"`
+	// +optional
+	FirmwareVersion string `json:"firmwareVersion,omitempty"`
+	// LinkAggregation is set if the switch port the interface is connected to is aggregated (LACP),
+	// so the installed system needs a bond on the interface to have network.
+	// +optional
+	LinkAggregation *LinkAggregation `json:"linkAggregation,omitempty"`
+}
+
+// LinkAggregation is the link aggregation detected on the switch port.
+type LinkAggregation struct {
+	// Source of the detection: "lacp" (LACPDUs sent by the switch) or "lldp" (aggregation advertised via LLDP).
+	Source string `json:"source"`
+	// Partner is the switch LACP system ID, or LLDP system name (chassis ID).
+	// +optional
+	Partner string `json:"partner,omitempty"`
+	// Port is the switch port.
+	// +optional
+	Port string `json:"port,omitempty"`
+}
+
+// PCIDevice is a device on the PCI bus.
+type PCIDevice struct {
+	PCIAddress string `json:"pciAddress"`
+	VendorID   string `json:"vendorId,omitempty"`
+	DeviceID   string `json:"deviceId,omitempty"`
+	// +optional
+	Driver string `json:"driver,omitempty"`
+}
+
+// DiskInformation is a disk with its SMART health.
+type DiskInformation struct {
+	DeviceName string `json:"deviceName"`
+	Model      string `json:"model,omitempty"`
+	Serial     string `json:"serial,omitempty"`
+	// Type is hdd, ssd, nvme, sd or unknown.
+	Type string `json:"type,omitempty"`
+	// Size in bytes.
+	Size uint64 `json:"size,omitempty"`
+	// +optional
+	FirmwareVersion string `json:"firmwareVersion,omitempty"`
+	// SMARTHealth is either Passed or Failed, empty if the health is not available.
+	// +optional
+	SMARTHealth string `json:"smartHealth,omitempty"`
+}
+
+// HardwareInventory is the hardware reported by the agent.
+type HardwareInventory struct {
+	// +optional
+	NetworkInterfaces []NetworkInterface `json:"networkInterfaces,omitempty"`
+	// GPUs are the display controllers found on the PCI bus.
+	// +optional
+	GPUs []PCIDevice `json:"gpus,omitempty"`
+	// +optional
+	Disks []DiskInformation `json:"disks,omitempty"`
+	// BMCFirmwareVersion is the firmware revision reported by the BMC.
+	// +optional
+	BMCFirmwareVersion string `json:"bmcFirmwareVersion,omitempty"`
+	// Chassis is the location of the server in the blade enclosure or the multi-node chassis.
+	// +optional
+	Chassis *ChassisLocation `json:"chassis,omitempty"`
+	// UpdatedAt is the time the inventory was last reported.
+	UpdatedAt metav1.Time `json:"updatedAt"`
+}
+
+// DHCPReservation is the lease reserved for the interface of the server, served by the Sidero DHCP server.
+type DHCPReservation struct {
+	// Interface is the MAC address of the interface, or the name of the interface in the hardware inventory.
+	Interface string `json:"interface"`
+	// Address in the CIDR notation, e.g. 172.24.0.10/24.
+	Address string `json:"address"`
+	// Gateway is the default gateway.
+	// +optional
+	Gateway string `json:"gateway,omitempty"`
+	// +optional
+	DNSServers []string `json:"dnsServers,omitempty"`
+	// +optional
+	NTPServers []string `json:"ntpServers,omitempty"`
+	// DomainSearch is the list of the DNS search domains.
+	// +optional
+	DomainSearch []string `json:"domainSearch,omitempty"`
+	// MTU of the interface.
+	// +kubebuilder:validation:Minimum=68
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	MTU uint32 `json:"mtu,omitempty"`
+	// LeaseDuration defaults to 1 hour.
+	// +optional
+	LeaseDuration *metav1.Duration `json:"leaseDuration,omitempty"`
+}
+
+// Approval gate states.
+const (
+	// ApprovalStatePending means the server is waiting for approvals to be accepted.
+	ApprovalStatePending = "PendingApproval"
+	// ApprovalStateApproved means the server got enough approvals and was accepted.
+	ApprovalStateApproved = "Approved"
+)
+
+// ServerApproval is an approval to accept the server.
+type ServerApproval struct {
+	// Approver is the authenticated user who created the ApproveAction.
+	Approver string `json:"approver"`
+	// Comment, e.g. the change request reference.
+	// +optional
+	Comment string `json:"comment,omitempty"`
+}
+
+// RecordedApproval is the approval observed by Sidero.
+type RecordedApproval struct {
+	ServerApproval `json:",inline"`
+	// Action is the name of the ApproveAction.
+	Action string `json:"action"`
+	// Time the approval was observed.
+	Time metav1.Time `json:"time"`
+}
+
+// ApprovalStatus is the state of the approval gate.
+type ApprovalStatus struct {
+	// State is either PendingApproval or Approved.
+	State string `json:"state"`
+	// Required is the number of distinct approvers required.
+	Required int `json:"required"`
+	// Approvals observed so far, in order.
+	// +optional
+	Approvals []RecordedApproval `json:"approvals,omitempty"`
+	// ApprovedAt is the time the server was approved.
+	// +optional
+	ApprovedAt *metav1.Time `json:"approvedAt,omitempty"`
+	// Grandfathered is set if the server was accepted before Sidero required approvals.
+	// +optional
+	Grandfathered bool `json:"grandfathered,omitempty"`
+}
+
 // ServerSpec defines the desired state of Server.
 type ServerSpec struct {
 	EnvironmentRef    *corev1.ObjectReference `json:"environmentRef,omitempty"`
 	Hostname          string                  `json:"hostname,omitempty"`
 	SystemInformation *SystemInformation      `json:"system,omitempty"`
 	CPU               *CPUInformation         `json:"cpu,omitempty"`
+	BIOS              *BIOSInformation        `json:"bios,omitempty"`
 	BMC               *BMC                    `json:"bmc,omitempty"`
 	ManagementAPI     *ManagementAPI          `json:"managementApi,omitempty"`
 	ConfigPatches     []ConfigPatches         `json:"configPatches,omitempty"`
-	Accepted          bool                    `json:"accepted"`
-	PXEBootAlways     bool                    `json:"pxeBootAlways,omitempty"`
+	// Config patches stored in ConfigMaps and Secrets, applied after configPatches in the listed order.
+	// +optional
+	ConfigPatchesFrom []ConfigPatchesRef `json:"configPatchesFrom,omitempty"`
+	Accepted          bool               `json:"accepted"`
+	PXEBootAlways     bool               `json:"pxeBootAlways,omitempty"`
+	// Policy for wiping the disks when the server is released.
+	// Takes precedence over the wipe policy of the ServerClass.
+	// +optional
+	WipePolicy *WipePolicy `json:"wipePolicy,omitempty"`
+	// ConsoleCapture enables capturing the serial console via IPMI Serial-over-LAN while the server is wiped or provisioned.
+	// +optional
+	ConsoleCapture bool `json:"consoleCapture,omitempty"`
+	// Asset management metadata of the server (warranty).
+	// +optional
+	Asset *AssetInformation `json:"asset,omitempty"`
+	// Policy ordering the power on of the server after the servers it depends on.
+	// Takes precedence over the power on policy of the ServerClass.
+	// +optional
+	PowerOnPolicy *PowerOnPolicy `json:"powerOnPolicy,omitempty"`
+	// DHCP leases reserved for the interfaces of the server, served when the Sidero DHCP server is enabled.
+	// +optional
+	DHCPReservations []DHCPReservation `json:"dhcpReservations,omitempty"`
+	// Installer image pinned to the digest.
+	// Takes precedence over the install image of the ServerClass.
+	// +optional
+	InstallImage *InstallImage `json:"installImage,omitempty"`
 }
 
 const (
@@ -161,6 +384,46 @@ const (
 	ConditionPowerCycle clusterv1.ConditionType = "PowerCycle"
 	// ConditionPXEBooted is used to record the fact that server got PXE booted.
 	ConditionPXEBooted clusterv1.ConditionType = "PXEBooted"
+	// ConditionWarrantyValid reports whether the warranty of the server is not near the expiry.
+	ConditionWarrantyValid clusterv1.ConditionType = "WarrantyValid"
+	// ConditionPowerOnDependenciesReady reports whether the power on dependencies of the server are satisfied.
+	ConditionPowerOnDependenciesReady clusterv1.ConditionType = "PowerOnDependenciesReady"
+	// ConditionUnexpectedAgentBoot is set when the allocated (installed) server booted into the agent,
+	// the disks of the server are not wiped, and the server is only booted from disk afterwards.
+	ConditionUnexpectedAgentBoot clusterv1.ConditionType = "UnexpectedAgentBoot"
+	// ConditionWipeConfirmed is false while the agent found the existing data on the disks to be wiped
+	// and waits for the wipe confirmation.
+	ConditionWipeConfirmed clusterv1.ConditionType = "WipeConfirmed"
+	// ConditionWipePolicyResolved is false when the server has no wipe policy, it wasn't allocated from a ServerClass,
+	// and the matching ServerClasses have different wipe policies: the server is not wiped until the policy is set on the server.
+	ConditionWipePolicyResolved clusterv1.ConditionType = "WipePolicyResolved"
+)
+
+// AgentBootedReason is used for the UnexpectedAgentBoot condition when the allocated server booted into the agent.
+const AgentBootedReason = "AgentBooted"
+
+// DataFoundReason is used for the WipeConfirmed condition when the disks to be wiped have the existing data.
+const DataFoundReason = "DataFound"
+
+// ConflictingWipePoliciesReason is used for the WipePolicyResolved condition when the matching ServerClasses disagree on the wipe policy.
+const ConflictingWipePoliciesReason = "ConflictingWipePolicies"
+
+// Server PowerOnDependenciesReady condition reasons.
+const (
+	// WaitingForDependenciesReason is used when the server is not powered on until the dependencies are satisfied.
+	WaitingForDependenciesReason = "WaitingForDependencies"
+	// DependenciesTimedOutReason is used when the server is powered on as the dependencies were not satisfied in time.
+	DependenciesTimedOutReason = "DependenciesTimedOut"
+	// InvalidPowerOnPolicyReason is used when the server is not powered on as the policy refers to a missing ServerClass.
+	InvalidPowerOnPolicyReason = "InvalidPolicy"
+)
+
+// Server WarrantyValid condition reasons.
+const (
+	// WarrantyExpiringReason is used when the warranty ends within the warranty expiry window.
+	WarrantyExpiringReason = "WarrantyExpiring"
+	// WarrantyExpiredReason is used when the warranty has ended.
+	WarrantyExpiredReason = "WarrantyExpired"
 )
 
 // ServerStatus defines the observed state of Server.
@@ -183,8 +446,32 @@ type ServerStatus struct {
 	// Addresses lists discovered node IPs.
 	Addresses []corev1.NodeAddress `json:"addresses,omitempty"`
 
+	// WipeSkippedDisks lists the disks which were not wiped on the last wipe.
+	// +optional
+	WipeSkippedDisks []SkippedDisk `json:"wipeSkippedDisks,omitempty"`
+
 	// Power is the current power state of the server: "on", "off" or "unknown".
 	Power string `json:"power,omitempty"`
+
+	// ServerClass is the name of the ServerClass the server was allocated from.
+	//
+	// The wipe policy of the class is used when the released server is wiped, the name is cleared once the server is wiped.
+	// +optional
+	ServerClass string `json:"serverClass,omitempty"`
+
+	// Released is set when the server allocated by Sidero is released, and cleared once the server is wiped.
+	//
+	// The data on the disks of the released server is of its own allocation, so the wipe doesn't need the confirmation.
+	// +optional
+	Released bool `json:"released,omitempty"`
+
+	// Approval is the state of the approval gate, set only when Sidero requires approvals.
+	// +optional
+	Approval *ApprovalStatus `json:"approval,omitempty"`
+
+	// Inventory is the extended hardware inventory reported by the agent.
+	// +optional
+	Inventory *HardwareInventory `json:"inventory,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -195,6 +482,12 @@ type ServerStatus struct {
 // +kubebuilder:printcolumn:name="Allocated",type="boolean",JSONPath=".status.inUse",description="indicates that the server has been allocated"
 // +kubebuilder:printcolumn:name="Clean",type="boolean",JSONPath=".status.isClean",description="indicates if the server is clean or not"
 // +kubebuilder:printcolumn:name="Power",type="string",JSONPath=".status.power",description="display the current power status"
+// +kubebuilder:printcolumn:name="Approval",type="string",priority=1,JSONPath=".status.approval.state",description="approval gate state"
+// +kubebuilder:printcolumn:name="Manufacturer",type="string",priority=1,JSONPath=".spec.system.manufacturer",description="system manufacturer"
+// +kubebuilder:printcolumn:name="Product",type="string",priority=1,JSONPath=".spec.system.productName",description="system product name"
+// +kubebuilder:printcolumn:name="Serial",type="string",priority=1,JSONPath=".spec.system.serialNumber",description="system serial number"
+// +kubebuilder:printcolumn:name="CPU",type="string",priority=1,JSONPath=".spec.cpu.version",description="CPU version"
+// +kubebuilder:printcolumn:name="BIOS",type="string",priority=1,JSONPath=".spec.bios.version",description="BIOS version"
 
 // Server is the Schema for the servers API.
 type Server struct {
@@ -213,6 +506,99 @@ func (s *Server) SetConditions(conditions clusterv1.Conditions) {
 	s.Status.Conditions = conditions
 }
 
+// LookupServer finds the server of the machine with the SMBIOS UUID.
+//
+// Server is usually named after the UUID, otherwise the machine might have been merged into the server with the ServerUUIDLabel.
+func LookupServer(ctx context.Context, reader client.Reader, uuid string) (*Server, error) {
+	var server Server
+
+	err := reader.Get(ctx, types.NamespacedName{Name: uuid}, &server)
+	if err == nil {
+		return &server, nil
+	}
+
+	if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	var servers ServerList
+
+	if listErr := reader.List(ctx, &servers, client.MatchingLabels{ServerUUIDLabel: uuid}); listErr != nil {
+		return nil, listErr
+	}
+
+	switch len(servers.Items) {
+	case 0:
+		return nil, err
+	case 1:
+		return &servers.Items[0], nil
+	default:
+		return nil, fmt.Errorf("machine %s is merged into several servers", uuid)
+	}
+}
+
+// BootFromDiskRequested returns true if the boot from disk was requested with the annotation.
+func (s *Server) BootFromDiskRequested() bool {
+	_, ok := s.Annotations[ServerBootFromDiskAnnotation]
+
+	return ok
+}
+
+// WipeConfirmed returns true if the wipe of the disks with the existing data doesn't need the confirmation
+// according to the wipe policy, the server was released by Sidero, or the wipe was confirmed with the annotations.
+func (s *Server) WipeConfirmed(policy *WipePolicy) bool {
+	if policy != nil && policy.Confirmation == WipeConfirmationNever {
+		return true
+	}
+
+	if s.Status.Released {
+		return true
+	}
+
+	for _, annotation := range []string{ServerReusableAnnotation, ServerConfirmWipeAnnotation} {
+		if _, ok := s.Annotations[annotation]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// PowerHeld returns true if the server is kept powered off with the annotation.
+func (s *Server) PowerHeld() bool {
+	_, ok := s.Annotations[ServerPowerHoldAnnotation]
+
+	return ok
+}
+
+// ReinventoryRequested returns true if the hardware inventory refresh was requested with the annotation.
+func (s *Server) ReinventoryRequested() bool {
+	_, ok := s.Annotations[ServerReinventoryAnnotation]
+
+	return ok
+}
+
+// InstallDisk returns the device name of the first disk in the hardware inventory matching the selector.
+//
+// Until the inventory is reported, only the selector with the exact device name (no pattern) matches.
+func (s *Server) InstallDisk(selector *DiskSelector) (string, bool) {
+	if s.Status.Inventory == nil || len(s.Status.Inventory.Disks) == 0 {
+		if selector.DeviceName != "" && selector.Model == "" && selector.Serial == "" && !strings.ContainsAny(selector.DeviceName, `*?[\`) {
+			return selector.DeviceName, true
+		}
+
+		return "", false
+	}
+
+	for _, disk := range s.Status.Inventory.Disks {
+		if selector.Match(disk.DeviceName, disk.Model, disk.Serial) {
+			return disk.DeviceName, true
+		}
+	}
+
+	return "", false
+}
+
 // +kubebuilder:object:root=true
 
 // ServerList contains a list of Server.
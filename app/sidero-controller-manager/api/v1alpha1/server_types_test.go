@@ -8,6 +8,8 @@ package v1alpha1_test
 import (
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+
 	"github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
 )
 
@@ -68,3 +70,62 @@ func Test_PartialEqual(t *testing.T) {
 		})
 	}
 }
+
+func TestServerInstallDisk(t *testing.T) {
+	t.Parallel()
+
+	inventory := &v1alpha1.HardwareInventory{
+		Disks: []v1alpha1.DiskInformation{
+			{DeviceName: "/dev/sda", Model: "ST8000NM0055", Serial: "ZA1ABC"},
+			{DeviceName: "/dev/nvme0n1", Model: "Samsung SSD 970", Serial: "S4EWNX0N"},
+			{DeviceName: "/dev/nvme1n1", Model: "Samsung SSD 970", Serial: "S4EWNX1N"},
+		},
+	}
+
+	for name, tc := range map[string]struct {
+		inventory *v1alpha1.HardwareInventory
+		selector  v1alpha1.DiskSelector
+
+		expectedDisk string
+	}{
+		"device name": {
+			inventory:    inventory,
+			selector:     v1alpha1.DiskSelector{DeviceName: "/dev/sda"},
+			expectedDisk: "/dev/sda",
+		},
+		"first match": {
+			inventory:    inventory,
+			selector:     v1alpha1.DiskSelector{Model: "Samsung*"},
+			expectedDisk: "/dev/nvme0n1",
+		},
+		"no match": {
+			inventory: inventory,
+			selector:  v1alpha1.DiskSelector{DeviceName: "/dev/sd*", Model: "Samsung*"},
+		},
+		"no inventory": {
+			selector:     v1alpha1.DiskSelector{DeviceName: "/dev/vda"},
+			expectedDisk: "/dev/vda",
+		},
+		"no inventory pattern": {
+			selector: v1alpha1.DiskSelector{DeviceName: "/dev/nvme*"},
+		},
+		"no inventory model": {
+			selector: v1alpha1.DiskSelector{DeviceName: "/dev/vda", Model: "QEMU*"},
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server := &v1alpha1.Server{
+				Status: v1alpha1.ServerStatus{Inventory: tc.inventory},
+			}
+
+			disk, ok := server.InstallDisk(&tc.selector)
+
+			assert.Equal(t, tc.expectedDisk != "", ok)
+			assert.Equal(t, tc.expectedDisk, disk)
+		})
+	}
+}
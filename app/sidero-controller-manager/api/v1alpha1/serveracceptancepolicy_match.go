@@ -0,0 +1,140 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1
+
+import (
+	"fmt"
+	"net"
+	"path"
+	"strings"
+)
+
+// Validate the policy criteria.
+func (p *ServerAcceptancePolicy) Validate() error {
+	spec := &p.Spec
+
+	if len(spec.MACPrefixes) == 0 && len(spec.Manufacturers) == 0 && len(spec.ProductNames) == 0 && len(spec.SerialNumbers) == 0 && len(spec.Subnets) == 0 {
+		return fmt.Errorf("policy should have at least one criterion")
+	}
+
+	for _, prefix := range spec.MACPrefixes {
+		normalized := normalizeMAC(prefix)
+
+		if normalized == "" || len(normalized) > 12 || strings.Trim(normalized, "0123456789abcdef") != "" {
+			return fmt.Errorf("invalid MAC prefix %q", prefix)
+		}
+	}
+
+	for _, patterns := range [][]string{spec.Manufacturers, spec.ProductNames, spec.SerialNumbers} {
+		for _, pattern := range patterns {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+			}
+		}
+	}
+
+	for _, subnet := range spec.Subnets {
+		if _, _, err := net.ParseCIDR(subnet); err != nil {
+			return fmt.Errorf("invalid subnet %q: %w", subnet, err)
+		}
+	}
+
+	return nil
+}
+
+// Matches returns true if the server matches all the policy criteria.
+//
+// Policy should be validated first, invalid criteria don't match.
+func (p *ServerAcceptancePolicy) Matches(s *Server) bool {
+	spec := &p.Spec
+
+	if len(spec.MACPrefixes) == 0 && len(spec.Manufacturers) == 0 && len(spec.ProductNames) == 0 && len(spec.SerialNumbers) == 0 && len(spec.Subnets) == 0 {
+		return false
+	}
+
+	if len(spec.MACPrefixes) > 0 && !matchesMAC(spec.MACPrefixes, s) {
+		return false
+	}
+
+	var sysInfo SystemInformation
+
+	if s.Spec.SystemInformation != nil {
+		sysInfo = *s.Spec.SystemInformation
+	}
+
+	if len(spec.Manufacturers) > 0 && !matchesPattern(spec.Manufacturers, sysInfo.Manufacturer) {
+		return false
+	}
+
+	if len(spec.ProductNames) > 0 && !matchesPattern(spec.ProductNames, sysInfo.ProductName) {
+		return false
+	}
+
+	if len(spec.SerialNumbers) > 0 && !matchesPattern(spec.SerialNumbers, sysInfo.SerialNumber) {
+		return false
+	}
+
+	if len(spec.Subnets) > 0 && !matchesSubnet(spec.Subnets, s.Annotations[ServerRegistrationAddressAnnotation]) {
+		return false
+	}
+
+	return true
+}
+
+// normalizeMAC returns lowercase hex digits of the MAC address (or prefix) without the separators.
+func normalizeMAC(mac string) string {
+	return strings.NewReplacer(":", "", "-", "", ".", "").Replace(strings.ToLower(mac))
+}
+
+func matchesMAC(prefixes []string, s *Server) bool {
+	if s.Status.Inventory == nil {
+		return false
+	}
+
+	for _, iface := range s.Status.Inventory.NetworkInterfaces {
+		mac := normalizeMAC(iface.MAC)
+		if mac == "" {
+			continue
+		}
+
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(mac, normalizeMAC(prefix)) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func matchesPattern(patterns []string, value string) bool {
+	if value == "" {
+		return false
+	}
+
+	for _, pattern := range patterns {
+		if ok, err := path.Match(pattern, value); err == nil && ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchesSubnet(subnets []string, address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+
+	for _, subnet := range subnets {
+		_, ipNet, err := net.ParseCIDR(subnet)
+		if err == nil && ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,162 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+func TestServerAcceptancePolicyValidate(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		spec        metalv1alpha1.ServerAcceptancePolicySpec
+		expectError bool
+	}{
+		"valid": {
+			spec: metalv1alpha1.ServerAcceptancePolicySpec{
+				MACPrefixes:   []string{"d0:50:99", "D0-50-99-D3-33-60"},
+				Manufacturers: []string{"Supermicro*"},
+				SerialNumbers: []string{"S[0-9]*"},
+				Subnets:       []string{"172.24.0.0/24", "2001:db8::/64"},
+			},
+		},
+		"empty": {
+			expectError: true,
+		},
+		"dry run only": {
+			spec:        metalv1alpha1.ServerAcceptancePolicySpec{DryRun: true},
+			expectError: true,
+		},
+		"invalid mac": {
+			spec:        metalv1alpha1.ServerAcceptancePolicySpec{MACPrefixes: []string{"xx:50:99"}},
+			expectError: true,
+		},
+		"mac too long": {
+			spec:        metalv1alpha1.ServerAcceptancePolicySpec{MACPrefixes: []string{"d0:50:99:d3:33:60:00"}},
+			expectError: true,
+		},
+		"invalid pattern": {
+			spec:        metalv1alpha1.ServerAcceptancePolicySpec{SerialNumbers: []string{"S[0-9"}},
+			expectError: true,
+		},
+		"invalid subnet": {
+			spec:        metalv1alpha1.ServerAcceptancePolicySpec{Subnets: []string{"172.24.0.0"}},
+			expectError: true,
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			policy := metalv1alpha1.ServerAcceptancePolicy{Spec: tc.spec}
+
+			err := policy.Validate()
+			if tc.expectError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestServerAcceptancePolicyMatches(t *testing.T) {
+	t.Parallel()
+
+	server := metalv1alpha1.Server{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "4c4c4544-0035-5410-8056-c7c04f4d4d32",
+			Annotations: map[string]string{
+				metalv1alpha1.ServerRegistrationAddressAnnotation: "172.24.0.10",
+			},
+		},
+		Spec: metalv1alpha1.ServerSpec{
+			SystemInformation: &metalv1alpha1.SystemInformation{
+				Manufacturer: "Supermicro",
+				ProductName:  "SYS-5019D-FN8TP",
+				SerialNumber: "S1234567",
+			},
+		},
+		Status: metalv1alpha1.ServerStatus{
+			Inventory: &metalv1alpha1.HardwareInventory{
+				NetworkInterfaces: []metalv1alpha1.NetworkInterface{
+					{Name: "eth0", MAC: "AC:1F:6B:00:00:01"},
+					{Name: "eth1", MAC: "d0:50:99:d3:33:60"},
+				},
+			},
+		},
+	}
+
+	for name, tc := range map[string]struct {
+		spec     metalv1alpha1.ServerAcceptancePolicySpec
+		expected bool
+	}{
+		"empty": {},
+		"oui": {
+			spec:     metalv1alpha1.ServerAcceptancePolicySpec{MACPrefixes: []string{"ac-1f-6b"}},
+			expected: true,
+		},
+		"full mac": {
+			spec:     metalv1alpha1.ServerAcceptancePolicySpec{MACPrefixes: []string{"00:00:00", "D0:50:99:D3:33:60"}},
+			expected: true,
+		},
+		"other oui": {
+			spec: metalv1alpha1.ServerAcceptancePolicySpec{MACPrefixes: []string{"00:00:00"}},
+		},
+		"vendor and serial": {
+			spec: metalv1alpha1.ServerAcceptancePolicySpec{
+				Manufacturers: []string{"Dell*", "Supermicro*"},
+				ProductNames:  []string{"SYS-*"},
+				SerialNumbers: []string{"S1*"},
+			},
+			expected: true,
+		},
+		"serial mismatch": {
+			spec: metalv1alpha1.ServerAcceptancePolicySpec{
+				Manufacturers: []string{"Supermicro"},
+				SerialNumbers: []string{"X*"},
+			},
+		},
+		"subnet": {
+			spec:     metalv1alpha1.ServerAcceptancePolicySpec{Subnets: []string{"10.0.0.0/8", "172.24.0.0/24"}},
+			expected: true,
+		},
+		"subnet mismatch": {
+			spec: metalv1alpha1.ServerAcceptancePolicySpec{Subnets: []string{"172.25.0.0/24"}},
+		},
+		"all": {
+			spec: metalv1alpha1.ServerAcceptancePolicySpec{
+				MACPrefixes:   []string{"d0:50:99"},
+				Manufacturers: []string{"Supermicro"},
+				Subnets:       []string{"172.24.0.0/16"},
+			},
+			expected: true,
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			policy := metalv1alpha1.ServerAcceptancePolicy{Spec: tc.spec}
+
+			assert.Equal(t, tc.expected, policy.Matches(&server))
+		})
+	}
+
+	// no inventory and registration address yet
+	bare := metalv1alpha1.Server{}
+
+	assert.False(t, (&metalv1alpha1.ServerAcceptancePolicy{Spec: metalv1alpha1.ServerAcceptancePolicySpec{MACPrefixes: []string{"d0:50:99"}}}).Matches(&bare))
+	assert.False(t, (&metalv1alpha1.ServerAcceptancePolicy{Spec: metalv1alpha1.ServerAcceptancePolicySpec{Subnets: []string{"0.0.0.0/0"}}}).Matches(&bare))
+	assert.False(t, (&metalv1alpha1.ServerAcceptancePolicy{Spec: metalv1alpha1.ServerAcceptancePolicySpec{SerialNumbers: []string{"*"}}}).Matches(&bare))
+}
@@ -0,0 +1,98 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+)
+
+// ServerAcceptedByPolicyAnnotation records the ServerAcceptancePolicy which accepted the server.
+//
+// Servers with the annotation are never auto-accepted again, so un-accepting such a server manually sticks.
+const ServerAcceptedByPolicyAnnotation = "metal.sidero.dev/accepted-by-policy"
+
+// ServerRegistrationAddressAnnotation records the IP address the server registered with Sidero API from.
+const ServerRegistrationAddressAnnotation = "metal.sidero.dev/registration-address"
+
+// ServerAcceptancePolicySpec defines the criteria to auto-accept the servers.
+//
+// Server should match all the specified criteria, each criterion matches if any of the listed values matches.
+// Policy without any criteria doesn't match any server.
+type ServerAcceptancePolicySpec struct {
+	// MACPrefixes are the MAC address prefixes (e.g. OUI `d0:50:99`) or full MAC addresses,
+	// matched against the network interfaces in the hardware inventory.
+	// +optional
+	MACPrefixes []string `json:"macPrefixes,omitempty"`
+	// Manufacturers are the glob patterns matched against the SMBIOS system manufacturer.
+	// +optional
+	Manufacturers []string `json:"manufacturers,omitempty"`
+	// ProductNames are the glob patterns matched against the SMBIOS product name.
+	// +optional
+	ProductNames []string `json:"productNames,omitempty"`
+	// SerialNumbers are the glob patterns matched against the SMBIOS system serial number.
+	// +optional
+	SerialNumbers []string `json:"serialNumbers,omitempty"`
+	// Subnets are the CIDRs matched against the address the server registered with Sidero API from.
+	// +optional
+	Subnets []string `json:"subnets,omitempty"`
+	// DryRun records the matching servers in the events and status without accepting them.
+	// +optional
+	DryRun bool `json:"dryRun,omitempty"`
+}
+
+// ConditionPolicyValid reports whether the ServerAcceptancePolicy criteria are valid.
+const ConditionPolicyValid clusterv1.ConditionType = "PolicyValid"
+
+// ServerAcceptancePolicyStatus defines the observed state of ServerAcceptancePolicy.
+type ServerAcceptancePolicyStatus struct {
+	// AcceptedServers are the servers accepted by the policy.
+	// +optional
+	AcceptedServers []string `json:"acceptedServers,omitempty"`
+	// DryRunMatches are the servers which would be accepted if the policy was not in dry-run mode.
+	// +optional
+	DryRunMatches []string `json:"dryRunMatches,omitempty"`
+
+	// Conditions defines current service state of the ServerAcceptancePolicy.
+	Conditions []clusterv1.Condition `json:"conditions,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="Dry Run",type="boolean",JSONPath=".spec.dryRun",description="only record the matching servers"
+// +kubebuilder:printcolumn:name="Accepted",type="string",JSONPath=".status.acceptedServers",description="the servers accepted by the policy"
+
+// ServerAcceptancePolicy is the Schema for the serveracceptancepolicies API.
+//
+// ServerAcceptancePolicy auto-accepts the registered servers matching the criteria.
+type ServerAcceptancePolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ServerAcceptancePolicySpec   `json:"spec,omitempty"`
+	Status ServerAcceptancePolicyStatus `json:"status,omitempty"`
+}
+
+func (p *ServerAcceptancePolicy) GetConditions() clusterv1.Conditions {
+	return p.Status.Conditions
+}
+
+func (p *ServerAcceptancePolicy) SetConditions(conditions clusterv1.Conditions) {
+	p.Status.Conditions = conditions
+}
+
+// +kubebuilder:object:root=true
+
+// ServerAcceptancePolicyList contains a list of ServerAcceptancePolicy.
+type ServerAcceptancePolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ServerAcceptancePolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ServerAcceptancePolicy{}, &ServerAcceptancePolicyList{})
+}
@@ -0,0 +1,293 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Phases of the server actions.
+const (
+	ActionPhasePending   = "Pending"
+	ActionPhaseSucceeded = "Succeeded"
+	ActionPhaseFailed    = "Failed"
+)
+
+// ServerActionSpec defines the server the action is taken on.
+type ServerActionSpec struct {
+	// ServerRef is the name of the Server.
+	ServerRef string `json:"serverRef"`
+	// Reason of the action, recorded in the events of the Server.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+}
+
+// ServerActionStatus defines the observed state of the server action.
+type ServerActionStatus struct {
+	// Phase of the action: Pending, Succeeded or Failed.
+	// +optional
+	Phase string `json:"phase,omitempty"`
+	// Message describes the outcome of the action.
+	// +optional
+	Message string `json:"message,omitempty"`
+	// CompletionTime is the time the action succeeded or failed.
+	// +optional
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// Done returns true if the action succeeded or failed, actions are taken once.
+func (status *ServerActionStatus) Done() bool {
+	return status.Phase == ActionPhaseSucceeded || status.Phase == ActionPhaseFailed
+}
+
+// ServerAction is implemented by the server action kinds.
+//
+// Each action is a dedicated kind, so that the actions are granted with the RBAC rules on the kinds,
+// without granting the write access to the Server resources.
+// +kubebuilder:object:generate=false
+type ServerAction interface {
+	runtime.Object
+	metav1.Object
+
+	ActionSpec() *ServerActionSpec
+	ActionStatus() *ServerActionStatus
+}
+
+// Power operations of the PowerAction.
+const (
+	PowerOn    = "on"
+	PowerOff   = "off"
+	PowerCycle = "cycle"
+)
+
+// PowerActionSpec defines the power management operation.
+type PowerActionSpec struct {
+	ServerActionSpec `json:",inline"`
+
+	// Operation is `on`, `off` or `cycle`.
+	//
+	// Server powered off with the action is kept powered off until it's powered on (or cycled) with another action.
+	// +kubebuilder:validation:Enum=on;off;cycle
+	Operation string `json:"operation"`
+}
+
+// WipeActionSpec defines the wipe of the server.
+type WipeActionSpec struct {
+	ServerActionSpec `json:",inline"`
+
+	// Confirm the wipe of the disks with the existing data (see the wipe confirmation).
+	// +optional
+	Confirm bool `json:"confirm,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Server",type="string",JSONPath=".spec.serverRef",description="the server to accept"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="phase of the action"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// AcceptAction is the Schema for the acceptactions API.
+//
+// AcceptAction accepts the server (`.spec.accepted`).
+type AcceptAction struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ServerActionSpec   `json:"spec,omitempty"`
+	Status ServerActionStatus `json:"status,omitempty"`
+}
+
+// ActionSpec implements ServerAction.
+func (action *AcceptAction) ActionSpec() *ServerActionSpec {
+	return &action.Spec
+}
+
+// ActionStatus implements ServerAction.
+func (action *AcceptAction) ActionStatus() *ServerActionStatus {
+	return &action.Status
+}
+
+// +kubebuilder:object:root=true
+
+// AcceptActionList contains a list of AcceptAction.
+type AcceptActionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AcceptAction `json:"items"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Server",type="string",JSONPath=".spec.serverRef",description="the server to power on, off or cycle"
+// +kubebuilder:printcolumn:name="Operation",type="string",JSONPath=".spec.operation",description="power operation"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="phase of the action"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// PowerAction is the Schema for the poweractions API.
+//
+// PowerAction powers the server on, off or cycles its power via the BMC or the management API.
+type PowerAction struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PowerActionSpec    `json:"spec,omitempty"`
+	Status ServerActionStatus `json:"status,omitempty"`
+}
+
+// ActionSpec implements ServerAction.
+func (action *PowerAction) ActionSpec() *ServerActionSpec {
+	return &action.Spec.ServerActionSpec
+}
+
+// ActionStatus implements ServerAction.
+func (action *PowerAction) ActionStatus() *ServerActionStatus {
+	return &action.Status
+}
+
+// +kubebuilder:object:root=true
+
+// PowerActionList contains a list of PowerAction.
+type PowerActionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PowerAction `json:"items"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Server",type="string",JSONPath=".spec.serverRef",description="the server to wipe"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="phase of the action"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// WipeAction is the Schema for the wipeactions API.
+//
+// WipeAction marks the idle server as not clean, so that it's booted into the agent and wiped.
+type WipeAction struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WipeActionSpec     `json:"spec,omitempty"`
+	Status ServerActionStatus `json:"status,omitempty"`
+}
+
+// ActionSpec implements ServerAction.
+func (action *WipeAction) ActionSpec() *ServerActionSpec {
+	return &action.Spec.ServerActionSpec
+}
+
+// ActionStatus implements ServerAction.
+func (action *WipeAction) ActionStatus() *ServerActionStatus {
+	return &action.Status
+}
+
+// +kubebuilder:object:root=true
+
+// WipeActionList contains a list of WipeAction.
+type WipeActionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WipeAction `json:"items"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Server",type="string",JSONPath=".spec.serverRef",description="the server to release"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="phase of the action"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ReleaseAction is the Schema for the releaseactions API.
+//
+// ReleaseAction releases the allocated server: the Machine (or the MetalMachine without the owner Machine)
+// the server is bound to is deleted, so that the server goes through the regular release flow and is wiped.
+type ReleaseAction struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ServerActionSpec   `json:"spec,omitempty"`
+	Status ServerActionStatus `json:"status,omitempty"`
+}
+
+// ActionSpec implements ServerAction.
+func (action *ReleaseAction) ActionSpec() *ServerActionSpec {
+	return &action.Spec
+}
+
+// ActionStatus implements ServerAction.
+func (action *ReleaseAction) ActionStatus() *ServerActionStatus {
+	return &action.Status
+}
+
+// +kubebuilder:object:root=true
+
+// ReleaseActionList contains a list of ReleaseAction.
+type ReleaseActionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ReleaseAction `json:"items"`
+}
+
+// ApproveActionApproverAnnotation is the authenticated user who created the ApproveAction.
+//
+// The annotation is set by the approver webhook, and it can't be changed.
+const ApproveActionApproverAnnotation = "metal.sidero.dev/approver"
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Server",type="string",JSONPath=".spec.serverRef",description="the server to approve"
+// +kubebuilder:printcolumn:name="Approver",type="string",JSONPath=".metadata.annotations.metal\\.sidero\\.dev/approver",description="the user who approved the server"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="phase of the action"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// ApproveAction is the Schema for the approveactions API.
+//
+// ApproveAction approves the server when Sidero requires approvals (approval gate), the reason is recorded
+// as the comment of the approval. The approver is the user who created the action.
+type ApproveAction struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ServerActionSpec   `json:"spec,omitempty"`
+	Status ServerActionStatus `json:"status,omitempty"`
+}
+
+// ActionSpec implements ServerAction.
+func (action *ApproveAction) ActionSpec() *ServerActionSpec {
+	return &action.Spec
+}
+
+// ActionStatus implements ServerAction.
+func (action *ApproveAction) ActionStatus() *ServerActionStatus {
+	return &action.Status
+}
+
+// Approver returns the user who created the action, empty if it's not recorded.
+func (action *ApproveAction) Approver() string {
+	return action.Annotations[ApproveActionApproverAnnotation]
+}
+
+// +kubebuilder:object:root=true
+
+// ApproveActionList contains a list of ApproveAction.
+type ApproveActionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ApproveAction `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(
+		&ApproveAction{}, &ApproveActionList{},
+		&AcceptAction{}, &AcceptActionList{},
+		&PowerAction{}, &PowerActionList{},
+		&WipeAction{}, &WipeActionList{},
+		&ReleaseAction{}, &ReleaseActionList{},
+	)
+}
@@ -0,0 +1,197 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/checker/decls"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/google/cel-go/interpreter/functions"
+	exprpb "google.golang.org/genproto/googleapis/api/expr/v1alpha1"
+	"google.golang.org/protobuf/proto"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+var expressionEnv *cel.Env
+
+func init() {
+	var err error
+
+	expressionEnv, err = cel.NewEnv(
+		cel.Declarations(
+			decls.NewVar("spec", decls.Dyn),
+			decls.NewVar("inventory", decls.Dyn),
+			decls.NewVar("labels", decls.NewMapType(decls.String, decls.String)),
+			decls.NewFunction("quantity",
+				decls.NewOverload("quantity_string", []*exprpb.Type{decls.String}, decls.Int),
+			),
+		),
+	)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func quantity(value ref.Val) ref.Val {
+	s, ok := value.(types.String)
+	if !ok {
+		return types.MaybeNoSuchOverloadErr(value)
+	}
+
+	q, err := resource.ParseQuantity(string(s))
+	if err != nil {
+		return types.NewErr("invalid quantity %q: %s", s, err)
+	}
+
+	return types.Int(q.Value())
+}
+
+// compileExpression compiles CEL expression checking that it returns a boolean.
+func compileExpression(expression string) (cel.Program, error) {
+	ast, issues := expressionEnv.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, fmt.Errorf("expression %q: %w", expression, issues.Err())
+	}
+
+	if !proto.Equal(ast.ResultType(), decls.Bool) && !proto.Equal(ast.ResultType(), decls.Dyn) {
+		return nil, fmt.Errorf("expression %q: result type should be bool", expression)
+	}
+
+	program, err := expressionEnv.Program(ast, cel.Functions(&functions.Overload{
+		Operator: "quantity_string",
+		Unary:    quantity,
+	}))
+	if err != nil {
+		return nil, fmt.Errorf("expression %q: %w", expression, err)
+	}
+
+	return program, nil
+}
+
+// ValidateExpressions checks that all qualifier expressions compile.
+func (q *Qualifiers) ValidateExpressions() error {
+	for _, expression := range q.Expressions {
+		if _, err := compileExpression(expression); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// normalizeNumbers converts integer JSON numbers to int64, and other numbers to float64.
+//
+// CEL doesn't compare ints and doubles, and most of the hardware facts are integers.
+func normalizeNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+
+		f, _ := v.Float64() //nolint:errcheck
+
+		return f
+	case map[string]interface{}:
+		for key := range v {
+			v[key] = normalizeNumbers(v[key])
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = normalizeNumbers(v[i])
+		}
+	}
+
+	return v
+}
+
+// expressionValue converts the value to the generic JSON representation for the expressions.
+func expressionValue(v interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value map[string]interface{}
+
+	if err = decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	// e.g. the inventory is not reported yet
+	if value == nil {
+		value = map[string]interface{}{}
+	}
+
+	return normalizeNumbers(value).(map[string]interface{}), nil
+}
+
+func expressionVars(server *Server) (map[string]interface{}, error) {
+	spec, err := expressionValue(server.Spec)
+	if err != nil {
+		return nil, err
+	}
+
+	inventory, err := expressionValue(server.Status.Inventory)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := server.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+
+	return map[string]interface{}{
+		"spec":      spec,
+		"inventory": inventory,
+		"labels":    labels,
+	}, nil
+}
+
+// expressionsFilter returns a filter matching servers against any of the expressions.
+//
+// If any of the expressions is invalid, no servers are matched.
+// Evaluation errors (e.g. missing fields) are treated as no match.
+func expressionsFilter(expressions []string) func(Server) (bool, error) {
+	programs := make([]cel.Program, 0, len(expressions))
+
+	for _, expression := range expressions {
+		program, err := compileExpression(expression)
+		if err != nil {
+			return func(Server) (bool, error) { return false, nil }
+		}
+
+		programs = append(programs, program)
+	}
+
+	return func(server Server) (bool, error) {
+		vars, err := expressionVars(&server)
+		if err != nil {
+			return false, err
+		}
+
+		for _, program := range programs {
+			out, _, err := program.Eval(vars)
+			if err != nil {
+				continue
+			}
+
+			if match, ok := out.Value().(bool); ok && match {
+				return true, nil
+			}
+		}
+
+		return false, nil
+	}
+}
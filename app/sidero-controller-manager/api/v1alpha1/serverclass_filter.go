@@ -17,6 +17,15 @@ func AcceptedServerFilter(s Server) (bool, error) {
 	return s.Spec.Accepted, nil
 }
 
+// ApprovedServerFilter returns a ServerFilter that matches servers which passed the approval gate.
+//
+// Approval gate is disabled if requiredApprovals is zero.
+func ApprovedServerFilter(requiredApprovals int) func(Server) (bool, error) {
+	return func(s Server) (bool, error) {
+		return requiredApprovals == 0 || s.Status.Approval.Approved(), nil
+	}
+}
+
 // SelectorFilter returns a ServerFilter that matches servers against the
 // serverclass's selector field.
 func (sc *ServerClass) SelectorFilter() func(Server) (bool, error) {
@@ -33,6 +42,12 @@ func (sc *ServerClass) SelectorFilter() func(Server) (bool, error) {
 // QualifiersFilter returns a ServerFilter that matches servers against the
 // serverclass's qualifiers field.
 func (sc *ServerClass) QualifiersFilter() func(Server) (bool, error) {
+	var matchExpressions func(Server) (bool, error)
+
+	if len(sc.Spec.Qualifiers.Expressions) > 0 {
+		matchExpressions = expressionsFilter(sc.Spec.Qualifiers.Expressions)
+	}
+
 	return func(server Server) (bool, error) {
 		q := sc.Spec.Qualifiers
 
@@ -84,6 +99,10 @@ func (sc *ServerClass) QualifiersFilter() func(Server) (bool, error) {
 			}
 		}
 
+		if matchExpressions != nil {
+			return matchExpressions(server)
+		}
+
 		return true, nil
 	}
 }
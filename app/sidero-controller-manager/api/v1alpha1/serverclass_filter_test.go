@@ -21,6 +21,7 @@ func TestFilterAcceptedServers(t *testing.T) {
 			Labels: map[string]string{
 				"common-label": "true",
 				"zone":         "central",
+				"memory":       "64Gi",
 			},
 		},
 		Spec: metalv1alpha1.ServerSpec{
@@ -30,12 +31,21 @@ func TestFilterAcceptedServers(t *testing.T) {
 				Version:      "Intel(R) Atom(TM) CPU C3558 @ 2.20GHz",
 			},
 		},
+		Status: metalv1alpha1.ServerStatus{
+			Inventory: &metalv1alpha1.HardwareInventory{
+				Disks: []metalv1alpha1.DiskInformation{
+					{DeviceName: "/dev/nvme0n1", Type: "nvme", Size: 512110190592},
+					{DeviceName: "/dev/sda", Type: "ssd", Size: 480103981056},
+				},
+			},
+		},
 	}
 	ryzen := metalv1alpha1.Server{
 		ObjectMeta: metav1.ObjectMeta{
 			Labels: map[string]string{
 				"common-label": "true",
 				"zone":         "east",
+				"memory":       "128Gi",
 			},
 		},
 		Spec: metalv1alpha1.ServerSpec{
@@ -48,6 +58,14 @@ func TestFilterAcceptedServers(t *testing.T) {
 				Manufacturer: "QEMU",
 			},
 		},
+		Status: metalv1alpha1.ServerStatus{
+			Inventory: &metalv1alpha1.HardwareInventory{
+				Disks: []metalv1alpha1.DiskInformation{
+					{DeviceName: "/dev/nvme0n1", Type: "nvme", Size: 2000398934016},
+					{DeviceName: "/dev/nvme1n1", Type: "nvme", Size: 2000398934016},
+				},
+			},
+		},
 	}
 	notAccepted := metalv1alpha1.Server{
 		ObjectMeta: metav1.ObjectMeta{
@@ -242,6 +260,84 @@ func TestFilterAcceptedServers(t *testing.T) {
 			},
 			expected: []metalv1alpha1.Server{},
 		},
+		"expression - string functions": {
+			q: metalv1alpha1.Qualifiers{
+				Expressions: []string{
+					`spec.cpu.manufacturer.startsWith("Intel")`,
+				},
+			},
+			expected: []metalv1alpha1.Server{atom},
+		},
+		"expression - quantity comparison": {
+			q: metalv1alpha1.Qualifiers{
+				Expressions: []string{
+					`quantity(labels["memory"]) >= quantity("100Gi")`,
+				},
+			},
+			expected: []metalv1alpha1.Server{ryzen},
+		},
+		"expression - memory and nvme disks": {
+			q: metalv1alpha1.Qualifiers{
+				Expressions: []string{
+					`quantity(labels["memory"]) >= quantity("128Gi") && inventory.disks.filter(d, d.type == "nvme").size() >= 2`,
+				},
+			},
+			expected: []metalv1alpha1.Server{ryzen},
+		},
+		"expression - disk size": {
+			q: metalv1alpha1.Qualifiers{
+				Expressions: []string{
+					`inventory.disks.exists(d, d.type == "ssd" && d.size >= quantity("400Gi"))`,
+				},
+			},
+			expected: []metalv1alpha1.Server{atom},
+		},
+		"expression - any of expressions": {
+			q: metalv1alpha1.Qualifiers{
+				Expressions: []string{
+					`labels["zone"] == "central"`,
+					`spec.system.manufacturer == "QEMU"`,
+				},
+			},
+			expected: []metalv1alpha1.Server{atom, ryzen},
+		},
+		"expression - missing field": {
+			q: metalv1alpha1.Qualifiers{
+				Expressions: []string{
+					`spec.system.manufacturer == "QEMU"`,
+				},
+			},
+			expected: []metalv1alpha1.Server{ryzen},
+		},
+		"expression - combined with qualifiers": {
+			q: metalv1alpha1.Qualifiers{
+				CPU: []metalv1alpha1.CPUInformation{
+					{
+						Manufacturer: "Intel(R) Corporation",
+					},
+				},
+				Expressions: []string{
+					`spec.system.manufacturer == "QEMU"`,
+				},
+			},
+			expected: []metalv1alpha1.Server{},
+		},
+		"expression - invalid": {
+			q: metalv1alpha1.Qualifiers{
+				Expressions: []string{
+					`spec.cpu.manufacturer ==`,
+				},
+			},
+			expected: []metalv1alpha1.Server{},
+		},
+		"expression - not boolean": {
+			q: metalv1alpha1.Qualifiers{
+				Expressions: []string{
+					`labels["zone"]`,
+				},
+			},
+			expected: []metalv1alpha1.Server{},
+		},
 		metalv1alpha1.ServerClassAny: {
 			expected: []metalv1alpha1.Server{atom, ryzen},
 		},
@@ -268,3 +364,89 @@ func TestFilterAcceptedServers(t *testing.T) {
 		})
 	}
 }
+
+func TestApprovedServerFilter(t *testing.T) {
+	t.Parallel()
+
+	pending := metalv1alpha1.Server{
+		ObjectMeta: metav1.ObjectMeta{Name: "pending"},
+		Spec:       metalv1alpha1.ServerSpec{Accepted: true},
+		Status: metalv1alpha1.ServerStatus{
+			Approval: &metalv1alpha1.ApprovalStatus{State: metalv1alpha1.ApprovalStatePending},
+		},
+	}
+	approved := metalv1alpha1.Server{
+		ObjectMeta: metav1.ObjectMeta{Name: "approved"},
+		Spec:       metalv1alpha1.ServerSpec{Accepted: true},
+		Status: metalv1alpha1.ServerStatus{
+			Approval: &metalv1alpha1.ApprovalStatus{State: metalv1alpha1.ApprovalStateApproved},
+		},
+	}
+	// approval gate status is not recorded yet
+	unknown := metalv1alpha1.Server{
+		ObjectMeta: metav1.ObjectMeta{Name: "unknown"},
+		Spec:       metalv1alpha1.ServerSpec{Accepted: true},
+	}
+
+	servers := []metalv1alpha1.Server{pending, approved, unknown}
+
+	actual, err := metalv1alpha1.FilterServers(servers, metalv1alpha1.AcceptedServerFilter, metalv1alpha1.ApprovedServerFilter(2))
+	assert.NoError(t, err)
+	assert.Equal(t, []metalv1alpha1.Server{approved}, actual)
+
+	actual, err = metalv1alpha1.FilterServers(servers, metalv1alpha1.AcceptedServerFilter, metalv1alpha1.ApprovedServerFilter(0))
+	assert.NoError(t, err)
+	assert.Equal(t, []metalv1alpha1.Server{approved, pending, unknown}, actual)
+}
+
+func TestValidateExpressions(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		expressions []string
+		valid       bool
+	}{
+		"empty": {
+			valid: true,
+		},
+		"valid": {
+			expressions: []string{
+				`spec.bmc.port == 623`,
+				`quantity(labels["memory"]) > quantity("1.5Ti")`,
+			},
+			valid: true,
+		},
+		"syntax error": {
+			expressions: []string{
+				`spec.bmc.port == 623`,
+				`spec.bmc.port ==`,
+			},
+		},
+		"unknown variable": {
+			expressions: []string{
+				`status.ready`,
+			},
+		},
+		"wrong result type": {
+			expressions: []string{
+				`quantity("1Gi")`,
+			},
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			q := metalv1alpha1.Qualifiers{
+				Expressions: tc.expressions,
+			}
+
+			if tc.valid {
+				assert.NoError(t, q.ValidateExpressions())
+			} else {
+				assert.Error(t, q.ValidateExpressions())
+			}
+		})
+	}
+}
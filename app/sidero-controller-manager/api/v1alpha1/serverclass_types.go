@@ -7,6 +7,7 @@ package v1alpha1
 import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
 )
 
 // ServerClassAny is an automatically created ServerClass that includes all Servers.
@@ -16,6 +17,12 @@ type Qualifiers struct {
 	CPU               []CPUInformation    `json:"cpu,omitempty"`
 	SystemInformation []SystemInformation `json:"systemInformation,omitempty"`
 	LabelSelectors    []map[string]string `json:"labelSelectors,omitempty"`
+	// Expressions in CEL evaluated against the server, server matches if any of the expressions evaluates to true.
+	//
+	// Server spec is available as `spec`, hardware inventory as `inventory` and labels as `labels`, e.g.:
+	// `spec.cpu.manufacturer.startsWith("Intel") && inventory.disks.filter(d, d.type == "nvme").size() >= 2`.
+	// Function `quantity` converts Kubernetes quantity to an integer: `quantity(labels["memory"]) >= quantity("128Gi")`.
+	Expressions []string `json:"expressions,omitempty"`
 }
 
 // ServerClassSpec defines the desired state of ServerClass.
@@ -37,12 +44,35 @@ type ServerClassSpec struct {
 	// Set of config patches to apply to the machine configuration to the servers provisioned via this server class.
 	// +optional
 	ConfigPatches []ConfigPatches `json:"configPatches,omitempty"`
+	// Config patches stored in ConfigMaps and Secrets, applied after configPatches in the listed order.
+	// +optional
+	ConfigPatchesFrom []ConfigPatchesRef `json:"configPatchesFrom,omitempty"`
+	// Policy for wiping the disks of the servers matching this server class when they are released.
+	// +optional
+	WipePolicy *WipePolicy `json:"wipePolicy,omitempty"`
+	// Policy ordering the power on of the servers matching this server class, e.g. after the storage servers.
+	// +optional
+	PowerOnPolicy *PowerOnPolicy `json:"powerOnPolicy,omitempty"`
+	// Installer image pinned to the digest for the servers provisioned via this server class.
+	// +optional
+	InstallImage *InstallImage `json:"installImage,omitempty"`
+	// Label key the servers allocated to the same cluster are spread by, e.g. `metal.sidero.dev/chassis`.
+	//
+	// Servers in the label values with the fewest servers of the cluster are allocated first.
+	// +optional
+	SpreadBy string `json:"spreadBy,omitempty"`
 }
 
+// ConditionQualifiersValid reports whether the ServerClass qualifiers (expressions) are valid.
+const ConditionQualifiersValid clusterv1.ConditionType = "QualifiersValid"
+
 // ServerClassStatus defines the observed state of ServerClass.
 type ServerClassStatus struct {
 	ServersAvailable []string `json:"serversAvailable"`
 	ServersInUse     []string `json:"serversInUse"`
+
+	// Conditions defines current service state of the ServerClass.
+	Conditions []clusterv1.Condition `json:"conditions,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -60,6 +90,14 @@ type ServerClass struct {
 	Status ServerClassStatus `json:"status,omitempty"`
 }
 
+func (sc *ServerClass) GetConditions() clusterv1.Conditions {
+	return sc.Status.Conditions
+}
+
+func (sc *ServerClass) SetConditions(conditions clusterv1.Conditions) {
+	sc.Status.Conditions = conditions
+}
+
 // +kubebuilder:object:root=true
 
 // ServerClassList contains a list of ServerClass.
@@ -0,0 +1,193 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// TransferWindow is the daily (off-peak) time window the transfers to the site cache are allowed in.
+type TransferWindow struct {
+	// Start of the window, `HH:MM` in UTC.
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	Start string `json:"start"`
+	// End of the window, `HH:MM` in UTC, the window spans midnight if the end is not after the start.
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	End string `json:"end"`
+}
+
+// SiteCacheSpec defines the remote site cache the Environment assets are replicated to.
+type SiteCacheSpec struct {
+	// URL of the cache, the assets are uploaded to and served from `<url>/env/<environment>/<asset>`.
+	URL string `json:"url"`
+	// Source of the bearer token the uploads to the cache are authenticated with.
+	// +optional
+	TokenFrom *CredentialSource `json:"tokenFrom,omitempty"`
+	// Subnets (CIDRs) of the servers at the site, the servers PXE booting from these subnets download
+	// the kernel and initramfs from the cache once the Environment is replicated to it.
+	// +optional
+	Subnets []string `json:"subnets,omitempty"`
+	// BandwidthLimit of the transfers to the cache in bytes per second, e.g. `10Mi`, unlimited if not set.
+	// +optional
+	BandwidthLimit *resource.Quantity `json:"bandwidthLimit,omitempty"`
+	// Windows the transfers are allowed in, any time if empty.
+	//
+	// Transfers interrupted by the end of the window are resumed in the next window.
+	// +optional
+	Windows []TransferWindow `json:"windows,omitempty"`
+	// EnvironmentSelector selects the Environments replicated to the cache, all Environments if not set.
+	// +optional
+	EnvironmentSelector *metav1.LabelSelector `json:"environmentSelector,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster
+// +kubebuilder:printcolumn:name="URL",type="string",JSONPath=".spec.url",description="the URL of the cache"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// SiteCache is the Schema for the sitecaches API.
+type SiteCache struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec SiteCacheSpec `json:"spec,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// SiteCacheList contains a list of SiteCache.
+type SiteCacheList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SiteCache `json:"items"`
+}
+
+// SelectsEnvironment returns true if the Environment is replicated to the cache.
+func (cache *SiteCache) SelectsEnvironment(env *Environment) (bool, error) {
+	if cache.Spec.EnvironmentSelector == nil {
+		return true, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(cache.Spec.EnvironmentSelector)
+	if err != nil {
+		return false, fmt.Errorf("invalid environment selector: %w", err)
+	}
+
+	return selector.Matches(labels.Set(env.Labels)), nil
+}
+
+// ContainsAddress returns true if the address belongs to the subnets of the site.
+func (cache *SiteCache) ContainsAddress(ip net.IP) bool {
+	for _, subnet := range cache.Spec.Subnets {
+		_, network, err := net.ParseCIDR(subnet)
+		if err != nil {
+			continue
+		}
+
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// TransferWindow returns whether the transfers are allowed at the time.
+//
+// If the transfers are allowed, the returned time is the end of the open window (zero if the transfers are not limited to the windows),
+// otherwise it's the start of the next window.
+func (cache *SiteCache) TransferWindow(now time.Time) (bool, time.Time, error) {
+	if len(cache.Spec.Windows) == 0 {
+		return true, time.Time{}, nil
+	}
+
+	now = now.UTC()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+
+	var (
+		open      bool
+		end, next time.Time
+	)
+
+	for _, window := range cache.Spec.Windows {
+		start, err := time.Parse("15:04", window.Start)
+		if err != nil {
+			return false, time.Time{}, fmt.Errorf("invalid window start %q: %w", window.Start, err)
+		}
+
+		stop, err := time.Parse("15:04", window.End)
+		if err != nil {
+			return false, time.Time{}, fmt.Errorf("invalid window end %q: %w", window.End, err)
+		}
+
+		startOffset := time.Duration(start.Hour())*time.Hour + time.Duration(start.Minute())*time.Minute
+		length := time.Duration(stop.Hour())*time.Hour + time.Duration(stop.Minute())*time.Minute - startOffset
+
+		if length <= 0 {
+			length += 24 * time.Hour
+		}
+
+		// the window might have started yesterday and span midnight
+		for _, day := range []time.Time{today.AddDate(0, 0, -1), today, today.AddDate(0, 0, 1)} {
+			windowStart := day.Add(startOffset)
+			windowEnd := windowStart.Add(length)
+
+			switch {
+			case !now.Before(windowStart) && now.Before(windowEnd):
+				open = true
+
+				if windowEnd.After(end) {
+					end = windowEnd
+				}
+			case windowStart.After(now) && (next.IsZero() || windowStart.Before(next)):
+				next = windowStart
+			}
+		}
+	}
+
+	if open {
+		return true, end, nil
+	}
+
+	return false, next, nil
+}
+
+// SiteCacheForAddress returns the first (by name) SiteCache the address belongs to, or nil if the address is not at any site.
+func SiteCacheForAddress(ctx context.Context, reader client.Reader, ip net.IP) (*SiteCache, error) {
+	var caches SiteCacheList
+
+	if err := reader.List(ctx, &caches); err != nil {
+		// CRD is not installed yet, e.g. while the providers are being upgraded
+		if meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	sort.Slice(caches.Items, func(i, j int) bool { return caches.Items[i].Name < caches.Items[j].Name })
+
+	for i := range caches.Items {
+		if caches.Items[i].ContainsAddress(ip) {
+			return &caches.Items[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+func init() {
+	SchemeBuilder.Register(&SiteCache{}, &SiteCacheList{})
+}
@@ -0,0 +1,169 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+func TestSiteCacheTransferWindow(t *testing.T) {
+	t.Parallel()
+
+	at := func(s string) time.Time {
+		ts, err := time.Parse(time.RFC3339, s)
+		require.NoError(t, err)
+
+		return ts
+	}
+
+	for name, tc := range map[string]struct {
+		windows []v1alpha1.TransferWindow
+		now     string
+
+		expectedOpen  bool
+		expectedUntil string
+	}{
+		"any time": {
+			now:          "2021-06-01T12:00:00Z",
+			expectedOpen: true,
+		},
+		"open": {
+			windows:       []v1alpha1.TransferWindow{{Start: "01:00", End: "05:00"}},
+			now:           "2021-06-01T02:30:00Z",
+			expectedOpen:  true,
+			expectedUntil: "2021-06-01T05:00:00Z",
+		},
+		"before": {
+			windows:       []v1alpha1.TransferWindow{{Start: "01:00", End: "05:00"}},
+			now:           "2021-06-01T00:30:00Z",
+			expectedUntil: "2021-06-01T01:00:00Z",
+		},
+		"after": {
+			windows:       []v1alpha1.TransferWindow{{Start: "01:00", End: "05:00"}},
+			now:           "2021-06-01T05:00:00Z",
+			expectedUntil: "2021-06-02T01:00:00Z",
+		},
+		"spans midnight, evening": {
+			windows:       []v1alpha1.TransferWindow{{Start: "22:00", End: "06:00"}},
+			now:           "2021-06-01T23:00:00Z",
+			expectedOpen:  true,
+			expectedUntil: "2021-06-02T06:00:00Z",
+		},
+		"spans midnight, morning": {
+			windows:       []v1alpha1.TransferWindow{{Start: "22:00", End: "06:00"}},
+			now:           "2021-06-02T05:59:00Z",
+			expectedOpen:  true,
+			expectedUntil: "2021-06-02T06:00:00Z",
+		},
+		"next of several": {
+			windows:       []v1alpha1.TransferWindow{{Start: "22:00", End: "23:00"}, {Start: "12:00", End: "13:00"}},
+			now:           "2021-06-01T13:30:00Z",
+			expectedUntil: "2021-06-01T22:00:00Z",
+		},
+		"other time zone": {
+			windows:       []v1alpha1.TransferWindow{{Start: "01:00", End: "05:00"}},
+			now:           "2021-06-01T04:00:00+02:00",
+			expectedOpen:  true,
+			expectedUntil: "2021-06-01T05:00:00Z",
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cache := &v1alpha1.SiteCache{Spec: v1alpha1.SiteCacheSpec{Windows: tc.windows}}
+
+			open, until, err := cache.TransferWindow(at(tc.now))
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedOpen, open)
+
+			if tc.expectedUntil == "" {
+				assert.True(t, until.IsZero())
+			} else {
+				assert.True(t, at(tc.expectedUntil).Equal(until), "expected %s, got %s", tc.expectedUntil, until)
+			}
+		})
+	}
+}
+
+func TestSiteCacheForAddress(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+
+	c := fake.NewFakeClientWithScheme(scheme,
+		&v1alpha1.SiteCache{
+			ObjectMeta: metav1.ObjectMeta{Name: "edge-1"},
+			Spec:       v1alpha1.SiteCacheSpec{URL: "http://10.1.0.2:8081", Subnets: []string{"10.1.0.0/16", "2001:db8:1::/48"}},
+		},
+		&v1alpha1.SiteCache{
+			ObjectMeta: metav1.ObjectMeta{Name: "edge-2"},
+			Spec:       v1alpha1.SiteCacheSpec{URL: "http://10.2.0.2:8081", Subnets: []string{"10.2.0.0/16"}},
+		},
+	)
+
+	for address, expected := range map[string]string{
+		"10.1.3.4":      "edge-1",
+		"2001:db8:1::5": "edge-1",
+		"10.2.0.10":     "edge-2",
+		"10.5.0.10":     "",
+	} {
+		cache, err := v1alpha1.SiteCacheForAddress(context.Background(), c, net.ParseIP(address))
+		require.NoError(t, err)
+
+		if expected == "" {
+			assert.Nil(t, cache, address)
+
+			continue
+		}
+
+		require.NotNil(t, cache, address)
+		assert.Equal(t, expected, cache.Name, address)
+	}
+}
+
+func TestEnvironmentReplicatedTo(t *testing.T) {
+	t.Parallel()
+
+	env := &v1alpha1.Environment{
+		Spec: v1alpha1.EnvironmentSpec{
+			Kernel: v1alpha1.Kernel{Asset: v1alpha1.Asset{URL: "http://example.com/vmlinuz"}},
+			Initrd: v1alpha1.Initrd{Asset: v1alpha1.Asset{URL: "http://example.com/initramfs.xz"}},
+		},
+		Status: v1alpha1.EnvironmentStatus{
+			Conditions: []v1alpha1.AssetCondition{
+				{Asset: v1alpha1.Asset{URL: "http://example.com/vmlinuz", SHA512: "aa"}, Type: "Ready", Status: "True"},
+				{Asset: v1alpha1.Asset{URL: "http://example.com/initramfs.xz", SHA512: "bb"}, Type: "Ready", Status: "True"},
+			},
+			Replicas: []v1alpha1.SiteReplication{
+				{Site: "edge-1", Asset: v1alpha1.Asset{URL: "http://example.com/vmlinuz", SHA512: "aa"}, State: v1alpha1.ReplicationReplicated},
+				{Site: "edge-1", Asset: v1alpha1.Asset{URL: "http://example.com/initramfs.xz", SHA512: "bb"}, State: v1alpha1.ReplicationReplicated},
+				{Site: "edge-2", Asset: v1alpha1.Asset{URL: "http://example.com/vmlinuz", SHA512: "aa"}, State: v1alpha1.ReplicationReplicated},
+				{Site: "edge-2", Asset: v1alpha1.Asset{URL: "http://example.com/initramfs.xz", SHA512: "bb"}, State: v1alpha1.ReplicationTransferring},
+				{Site: "edge-3", Asset: v1alpha1.Asset{URL: "http://example.com/vmlinuz", SHA512: "aa"}, State: v1alpha1.ReplicationReplicated},
+				{Site: "edge-3", Asset: v1alpha1.Asset{URL: "http://example.com/initramfs.xz", SHA512: "old"}, State: v1alpha1.ReplicationReplicated},
+			},
+		},
+	}
+
+	assert.True(t, env.ReplicatedTo("edge-1"))
+	assert.False(t, env.ReplicatedTo("edge-2"))
+	assert.False(t, env.ReplicatedTo("edge-3"))
+	assert.False(t, env.ReplicatedTo("edge-4"))
+}
@@ -4,7 +4,11 @@
 
 package v1alpha1
 
-import apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+import (
+	"path"
+
+	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+)
 
 // nb: we use apiextensions.JSON for the value below b/c we can't use interface{} with controller-gen.
 // found this workaround here: https://github.com/kubernetes-sigs/controller-tools/pull/126#issuecomment-630769075
@@ -14,3 +18,183 @@ type ConfigPatches struct {
 	Path  string             `json:"path"`
 	Value apiextensions.JSON `json:"value,omitempty"`
 }
+
+// Config patch types.
+const (
+	// ConfigPatchTypeJSON6902 is a list of RFC6902 JSON patch operations (YAML or JSON).
+	ConfigPatchTypeJSON6902 = "json6902"
+	// ConfigPatchTypeMerge is a partial machine config merged into the machine config (RFC7386 JSON merge patch).
+	ConfigPatchTypeMerge = "merge"
+)
+
+// ConfigPatchesRef references config patches stored in a ConfigMap or a Secret.
+type ConfigPatchesRef struct {
+	// Kind of the object: ConfigMap or Secret.
+	// +kubebuilder:validation:Enum=ConfigMap;Secret
+	Kind string `json:"kind"`
+	// Namespace of the object, defaults to `default`.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the object.
+	Name string `json:"name"`
+	// Key in the object data.
+	Key string `json:"key"`
+	// Patch type: `json6902` (default) or `merge`.
+	// +kubebuilder:validation:Enum=json6902;merge
+	// +optional
+	Type string `json:"type,omitempty"`
+}
+
+// ObjectKeyRef references a key in a ConfigMap or a Secret.
+type ObjectKeyRef struct {
+	// Kind of the object: ConfigMap or Secret.
+	// +kubebuilder:validation:Enum=ConfigMap;Secret
+	Kind string `json:"kind"`
+	// Namespace of the object, defaults to `default`.
+	// +optional
+	Namespace string `json:"namespace,omitempty"`
+	// Name of the object.
+	Name string `json:"name"`
+	// Key in the object data.
+	Key string `json:"key"`
+}
+
+// InstallImage overrides the installer image in the machine configuration.
+type InstallImage struct {
+	// Installer image without the digest, e.g. `ghcr.io/talos-systems/installer:v0.12.1`.
+	// +kubebuilder:validation:Pattern=`^[^@]+$`
+	Image string `json:"image"`
+	// Digest the image is pinned to, the machine configuration installs `<image>@<digest>`.
+	// +kubebuilder:validation:Pattern=`^sha256:[a-f0-9]{64}$`
+	Digest string `json:"digest"`
+	// Verify the cosign signature of the image before serving the machine configuration.
+	// +optional
+	Verify *ImageVerification `json:"verify,omitempty"`
+}
+
+// Reference returns the image reference pinned to the digest.
+func (i *InstallImage) Reference() string {
+	return i.Image + "@" + i.Digest
+}
+
+// ImageVerification defines how the image signature is verified.
+type ImageVerification struct {
+	// Cosign public key (PEM) the image should be signed with.
+	PublicKeyFrom ObjectKeyRef `json:"publicKeyFrom"`
+}
+
+// WipeMode defines which disks are wiped by the agent.
+type WipeMode string
+
+// Wipe modes.
+const (
+	// WipeModeAll wipes all disks (default).
+	WipeModeAll WipeMode = "all"
+	// WipeModeSystemDisk wipes only the disk Talos was installed to.
+	WipeModeSystemDisk WipeMode = "system-disk"
+	// WipeModeSelected wipes only the disks matching the include selectors.
+	WipeModeSelected WipeMode = "selected"
+)
+
+// WipeConfirmation defines when the wipe should be confirmed.
+type WipeConfirmation string
+
+// Wipe confirmations.
+const (
+	// WipeConfirmationDataFound requires the confirmation if the disks to be wiped have the existing data (default).
+	WipeConfirmationDataFound WipeConfirmation = "data-found"
+	// WipeConfirmationNever wipes the disks without the confirmation.
+	WipeConfirmationNever WipeConfirmation = "never"
+)
+
+// DiskSelector matches disks by their properties.
+//
+// Every field is a shell pattern (e.g. `/dev/sd*`), empty fields match any value.
+type DiskSelector struct {
+	// Device path, e.g. `/dev/sda`.
+	// +optional
+	DeviceName string `json:"deviceName,omitempty"`
+	// Disk model.
+	// +optional
+	Model string `json:"model,omitempty"`
+	// Disk serial number.
+	// +optional
+	Serial string `json:"serial,omitempty"`
+}
+
+// Match returns true if the disk matches the selector.
+func (s *DiskSelector) Match(deviceName, model, serial string) bool {
+	for _, pair := range [][2]string{
+		{s.DeviceName, deviceName},
+		{s.Model, model},
+		{s.Serial, serial},
+	} {
+		if pair[0] == "" {
+			continue
+		}
+
+		if matched, err := path.Match(pair[0], pair[1]); err != nil || !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// WipePolicy defines the disks which are wiped when the server is released.
+type WipePolicy struct {
+	// Wipe mode: `all`, `system-disk` or `selected`.
+	// +kubebuilder:validation:Enum=all;system-disk;selected
+	// +optional
+	Mode WipeMode `json:"mode,omitempty"`
+	// Disks to wipe in the `selected` mode, disk is wiped if it matches any of the selectors.
+	// +optional
+	Include []DiskSelector `json:"include,omitempty"`
+	// Disks which are never wiped (in any mode), e.g. Ceph data disks.
+	// +optional
+	Exclude []DiskSelector `json:"exclude,omitempty"`
+	// Confirmation required before wiping the disks with the existing data (Talos, LVM, ZFS or Ceph signatures):
+	// `data-found` (default) or `never`.
+	// +kubebuilder:validation:Enum=data-found;never
+	// +optional
+	Confirmation WipeConfirmation `json:"confirmation,omitempty"`
+}
+
+// ShouldWipe checks the disk against the policy.
+//
+// If the disk shouldn't be wiped, the reason is returned.
+func (p *WipePolicy) ShouldWipe(deviceName, model, serial string, systemDisk bool) (bool, string) {
+	for _, selector := range p.Exclude {
+		if selector.Match(deviceName, model, serial) {
+			return false, "excluded by wipe policy"
+		}
+	}
+
+	switch p.Mode {
+	case WipeModeAll, "":
+		return true, ""
+	case WipeModeSystemDisk:
+		if !systemDisk {
+			return false, "not a system disk"
+		}
+
+		return true, ""
+	case WipeModeSelected:
+		for _, selector := range p.Include {
+			if selector.Match(deviceName, model, serial) {
+				return true, ""
+			}
+		}
+
+		return false, "not selected by wipe policy"
+	default:
+		// unknown mode (e.g. set by a newer version), don't risk destroying the data
+		return false, "unknown wipe mode " + string(p.Mode)
+	}
+}
+
+// SkippedDisk describes a disk which was not wiped.
+type SkippedDisk struct {
+	DeviceName string `json:"deviceName"`
+	Reason     string `json:"reason,omitempty"`
+}
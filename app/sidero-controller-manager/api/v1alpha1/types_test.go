@@ -0,0 +1,129 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package v1alpha1_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+func TestWipePolicy(t *testing.T) {
+	t.Parallel()
+
+	type disk struct {
+		deviceName string
+		model      string
+		serial     string
+		systemDisk bool
+	}
+
+	system := disk{deviceName: "/dev/nvme0n1", model: "Samsung SSD 970", serial: "S4EWNX0N", systemDisk: true}
+	ceph := disk{deviceName: "/dev/sda", model: "ST8000NM0055", serial: "ZA1ABC"}
+	scratch := disk{deviceName: "/dev/sdb", model: "INTEL SSDSC2KB48", serial: "PHYS8"}
+
+	disks := []disk{system, ceph, scratch}
+
+	for name, tc := range map[string]struct {
+		policy   metalv1alpha1.WipePolicy
+		expected []string
+	}{
+		"default": {
+			expected: []string{"/dev/nvme0n1", "/dev/sda", "/dev/sdb"},
+		},
+		"all": {
+			policy: metalv1alpha1.WipePolicy{
+				Mode: metalv1alpha1.WipeModeAll,
+			},
+			expected: []string{"/dev/nvme0n1", "/dev/sda", "/dev/sdb"},
+		},
+		"all with exclude": {
+			policy: metalv1alpha1.WipePolicy{
+				Mode: metalv1alpha1.WipeModeAll,
+				Exclude: []metalv1alpha1.DiskSelector{
+					{
+						Model: "ST8000*",
+					},
+				},
+			},
+			expected: []string{"/dev/nvme0n1", "/dev/sdb"},
+		},
+		"system disk": {
+			policy: metalv1alpha1.WipePolicy{
+				Mode: metalv1alpha1.WipeModeSystemDisk,
+			},
+			expected: []string{"/dev/nvme0n1"},
+		},
+		"selected": {
+			policy: metalv1alpha1.WipePolicy{
+				Mode: metalv1alpha1.WipeModeSelected,
+				Include: []metalv1alpha1.DiskSelector{
+					{
+						DeviceName: "/dev/sd*",
+					},
+				},
+				Exclude: []metalv1alpha1.DiskSelector{
+					{
+						DeviceName: "/dev/sda",
+						Serial:     "ZA1ABC",
+					},
+				},
+			},
+			expected: []string{"/dev/sdb"},
+		},
+		"selected with all fields": {
+			policy: metalv1alpha1.WipePolicy{
+				Mode: metalv1alpha1.WipeModeSelected,
+				Include: []metalv1alpha1.DiskSelector{
+					{
+						DeviceName: "/dev/sda",
+						Serial:     "other",
+					},
+					{
+						DeviceName: "/dev/nvme*",
+						Model:      "Samsung*",
+						Serial:     "S4EWNX0N",
+					},
+				},
+			},
+			expected: []string{"/dev/nvme0n1"},
+		},
+		"selected nothing": {
+			policy: metalv1alpha1.WipePolicy{
+				Mode: metalv1alpha1.WipeModeSelected,
+			},
+			expected: []string{},
+		},
+		"unknown mode": {
+			policy: metalv1alpha1.WipePolicy{
+				Mode: "some-disks",
+			},
+			expected: []string{},
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			wiped := []string{}
+
+			for _, d := range disks {
+				ok, reason := tc.policy.ShouldWipe(d.deviceName, d.model, d.serial, d.systemDisk)
+				if ok {
+					assert.Empty(t, reason)
+
+					wiped = append(wiped, d.deviceName)
+				} else {
+					assert.NotEmpty(t, reason)
+				}
+			}
+
+			assert.Equal(t, tc.expected, wiped)
+		})
+	}
+}
@@ -9,11 +9,171 @@
 package v1alpha1
 
 import (
-	v1 "k8s.io/api/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/cluster-api/api/v1alpha3"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AcceptAction) DeepCopyInto(out *AcceptAction) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AcceptAction.
+func (in *AcceptAction) DeepCopy() *AcceptAction {
+	if in == nil {
+		return nil
+	}
+	out := new(AcceptAction)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AcceptAction) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AcceptActionList) DeepCopyInto(out *AcceptActionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]AcceptAction, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AcceptActionList.
+func (in *AcceptActionList) DeepCopy() *AcceptActionList {
+	if in == nil {
+		return nil
+	}
+	out := new(AcceptActionList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *AcceptActionList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApprovalStatus) DeepCopyInto(out *ApprovalStatus) {
+	*out = *in
+	if in.Approvals != nil {
+		in, out := &in.Approvals, &out.Approvals
+		*out = make([]RecordedApproval, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ApprovedAt != nil {
+		in, out := &in.ApprovedAt, &out.ApprovedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApprovalStatus.
+func (in *ApprovalStatus) DeepCopy() *ApprovalStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ApprovalStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApproveAction) DeepCopyInto(out *ApproveAction) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApproveAction.
+func (in *ApproveAction) DeepCopy() *ApproveAction {
+	if in == nil {
+		return nil
+	}
+	out := new(ApproveAction)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ApproveAction) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApproveActionList) DeepCopyInto(out *ApproveActionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ApproveAction, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApproveActionList.
+func (in *ApproveActionList) DeepCopy() *ApproveActionList {
+	if in == nil {
+		return nil
+	}
+	out := new(ApproveActionList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ApproveActionList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ApproverWebhook) DeepCopyInto(out *ApproverWebhook) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ApproverWebhook.
+func (in *ApproverWebhook) DeepCopy() *ApproverWebhook {
+	if in == nil {
+		return nil
+	}
+	out := new(ApproverWebhook)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Asset) DeepCopyInto(out *Asset) {
 	*out = *in
@@ -46,83 +206,100 @@ func (in *AssetCondition) DeepCopy() *AssetCondition {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *BMC) DeepCopyInto(out *BMC) {
+func (in *AssetInformation) DeepCopyInto(out *AssetInformation) {
 	*out = *in
-	if in.UserFrom != nil {
-		in, out := &in.UserFrom, &out.UserFrom
-		*out = new(CredentialSource)
-		(*in).DeepCopyInto(*out)
+	if in.PurchaseDate != nil {
+		in, out := &in.PurchaseDate, &out.PurchaseDate
+		*out = (*in).DeepCopy()
 	}
-	if in.PassFrom != nil {
-		in, out := &in.PassFrom, &out.PassFrom
-		*out = new(CredentialSource)
-		(*in).DeepCopyInto(*out)
+	if in.WarrantyEnd != nil {
+		in, out := &in.WarrantyEnd, &out.WarrantyEnd
+		*out = (*in).DeepCopy()
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BMC.
-func (in *BMC) DeepCopy() *BMC {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AssetInformation.
+func (in *AssetInformation) DeepCopy() *AssetInformation {
 	if in == nil {
 		return nil
 	}
-	out := new(BMC)
+	out := new(AssetInformation)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CPUInformation) DeepCopyInto(out *CPUInformation) {
+func (in *BIOSInformation) DeepCopyInto(out *BIOSInformation) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CPUInformation.
-func (in *CPUInformation) DeepCopy() *CPUInformation {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BIOSInformation.
+func (in *BIOSInformation) DeepCopy() *BIOSInformation {
 	if in == nil {
 		return nil
 	}
-	out := new(CPUInformation)
+	out := new(BIOSInformation)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ConfigPatches) DeepCopyInto(out *ConfigPatches) {
+func (in *BIOSRequirement) DeepCopyInto(out *BIOSRequirement) {
 	*out = *in
-	in.Value.DeepCopyInto(&out.Value)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigPatches.
-func (in *ConfigPatches) DeepCopy() *ConfigPatches {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BIOSRequirement.
+func (in *BIOSRequirement) DeepCopy() *BIOSRequirement {
 	if in == nil {
 		return nil
 	}
-	out := new(ConfigPatches)
+	out := new(BIOSRequirement)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *CredentialSource) DeepCopyInto(out *CredentialSource) {
+func (in *BMC) DeepCopyInto(out *BMC) {
 	*out = *in
-	if in.SecretKeyRef != nil {
-		in, out := &in.SecretKeyRef, &out.SecretKeyRef
-		*out = new(SecretKeyRef)
-		**out = **in
+	if in.UserFrom != nil {
+		in, out := &in.UserFrom, &out.UserFrom
+		*out = new(CredentialSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PassFrom != nil {
+		in, out := &in.PassFrom, &out.PassFrom
+		*out = new(CredentialSource)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CredentialSource.
-func (in *CredentialSource) DeepCopy() *CredentialSource {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BMC.
+func (in *BMC) DeepCopy() *BMC {
 	if in == nil {
 		return nil
 	}
-	out := new(CredentialSource)
+	out := new(BMC)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Environment) DeepCopyInto(out *Environment) {
+func (in *CPUInformation) DeepCopyInto(out *CPUInformation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CPUInformation.
+func (in *CPUInformation) DeepCopy() *CPUInformation {
+	if in == nil {
+		return nil
+	}
+	out := new(CPUInformation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Canary) DeepCopyInto(out *Canary) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -130,18 +307,18 @@ func (in *Environment) DeepCopyInto(out *Environment) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Environment.
-func (in *Environment) DeepCopy() *Environment {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Canary.
+func (in *Canary) DeepCopy() *Canary {
 	if in == nil {
 		return nil
 	}
-	out := new(Environment)
+	out := new(Canary)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Environment) DeepCopyObject() runtime.Object {
+func (in *Canary) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -149,31 +326,31 @@ func (in *Environment) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EnvironmentList) DeepCopyInto(out *EnvironmentList) {
+func (in *CanaryList) DeepCopyInto(out *CanaryList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]Environment, len(*in))
+		*out = make([]Canary, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnvironmentList.
-func (in *EnvironmentList) DeepCopy() *EnvironmentList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryList.
+func (in *CanaryList) DeepCopy() *CanaryList {
 	if in == nil {
 		return nil
 	}
-	out := new(EnvironmentList)
+	out := new(CanaryList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *EnvironmentList) DeepCopyObject() runtime.Object {
+func (in *CanaryList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -181,104 +358,903 @@ func (in *EnvironmentList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EnvironmentSpec) DeepCopyInto(out *EnvironmentSpec) {
+func (in *CanaryRun) DeepCopyInto(out *CanaryRun) {
 	*out = *in
-	in.Kernel.DeepCopyInto(&out.Kernel)
-	out.Initrd = in.Initrd
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Steps != nil {
+		in, out := &in.Steps, &out.Steps
+		*out = make([]CanaryStep, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnvironmentSpec.
-func (in *EnvironmentSpec) DeepCopy() *EnvironmentSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryRun.
+func (in *CanaryRun) DeepCopy() *CanaryRun {
 	if in == nil {
 		return nil
 	}
-	out := new(EnvironmentSpec)
+	out := new(CanaryRun)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *EnvironmentStatus) DeepCopyInto(out *EnvironmentStatus) {
+func (in *CanarySpec) DeepCopyInto(out *CanarySpec) {
 	*out = *in
-	if in.Conditions != nil {
-		in, out := &in.Conditions, &out.Conditions
-		*out = make([]AssetCondition, len(*in))
-		copy(*out, *in)
+	if in.ServerSelector != nil {
+		in, out := &in.ServerSelector, &out.ServerSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	out.ConfigFrom = in.ConfigFrom
+	if in.EnvironmentRef != nil {
+		in, out := &in.EnvironmentRef, &out.EnvironmentRef
+		*out = new(corev1.ObjectReference)
+		**out = **in
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnvironmentStatus.
-func (in *EnvironmentStatus) DeepCopy() *EnvironmentStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanarySpec.
+func (in *CanarySpec) DeepCopy() *CanarySpec {
 	if in == nil {
 		return nil
 	}
-	out := new(EnvironmentStatus)
+	out := new(CanarySpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Initrd) DeepCopyInto(out *Initrd) {
+func (in *CanaryStatus) DeepCopyInto(out *CanaryStatus) {
 	*out = *in
-	out.Asset = in.Asset
+	if in.PhaseStartTime != nil {
+		in, out := &in.PhaseStartTime, &out.PhaseStartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.ConfigServedTime != nil {
+		in, out := &in.ConfigServedTime, &out.ConfigServedTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Run != nil {
+		in, out := &in.Run, &out.Run
+		*out = new(CanaryRun)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastRun != nil {
+		in, out := &in.LastRun, &out.LastRun
+		*out = new(CanaryRun)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.LastSuccessTime != nil {
+		in, out := &in.LastSuccessTime, &out.LastSuccessTime
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Initrd.
-func (in *Initrd) DeepCopy() *Initrd {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryStatus.
+func (in *CanaryStatus) DeepCopy() *CanaryStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(Initrd)
+	out := new(CanaryStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Kernel) DeepCopyInto(out *Kernel) {
+func (in *CanaryStep) DeepCopyInto(out *CanaryStep) {
 	*out = *in
-	out.Asset = in.Asset
-	if in.Args != nil {
-		in, out := &in.Args, &out.Args
-		*out = make([]string, len(*in))
-		copy(*out, *in)
-	}
+	out.Duration = in.Duration
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Kernel.
-func (in *Kernel) DeepCopy() *Kernel {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CanaryStep.
+func (in *CanaryStep) DeepCopy() *CanaryStep {
 	if in == nil {
 		return nil
 	}
-	out := new(Kernel)
+	out := new(CanaryStep)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *ManagementAPI) DeepCopyInto(out *ManagementAPI) {
+func (in *ChassisLocation) DeepCopyInto(out *ChassisLocation) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagementAPI.
-func (in *ManagementAPI) DeepCopy() *ManagementAPI {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ChassisLocation.
+func (in *ChassisLocation) DeepCopy() *ChassisLocation {
 	if in == nil {
 		return nil
 	}
-	out := new(ManagementAPI)
+	out := new(ChassisLocation)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Qualifiers) DeepCopyInto(out *Qualifiers) {
+func (in *ConfigPatches) DeepCopyInto(out *ConfigPatches) {
 	*out = *in
-	if in.CPU != nil {
-		in, out := &in.CPU, &out.CPU
-		*out = make([]CPUInformation, len(*in))
-		copy(*out, *in)
+	in.Value.DeepCopyInto(&out.Value)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigPatches.
+func (in *ConfigPatches) DeepCopy() *ConfigPatches {
+	if in == nil {
+		return nil
 	}
-	if in.SystemInformation != nil {
-		in, out := &in.SystemInformation, &out.SystemInformation
+	out := new(ConfigPatches)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigPatchesRef) DeepCopyInto(out *ConfigPatchesRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigPatchesRef.
+func (in *ConfigPatchesRef) DeepCopy() *ConfigPatchesRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigPatchesRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CredentialSource) DeepCopyInto(out *CredentialSource) {
+	*out = *in
+	if in.SecretKeyRef != nil {
+		in, out := &in.SecretKeyRef, &out.SecretKeyRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CredentialSource.
+func (in *CredentialSource) DeepCopy() *CredentialSource {
+	if in == nil {
+		return nil
+	}
+	out := new(CredentialSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DHCPReservation) DeepCopyInto(out *DHCPReservation) {
+	*out = *in
+	if in.DNSServers != nil {
+		in, out := &in.DNSServers, &out.DNSServers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.NTPServers != nil {
+		in, out := &in.NTPServers, &out.NTPServers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DomainSearch != nil {
+		in, out := &in.DomainSearch, &out.DomainSearch
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LeaseDuration != nil {
+		in, out := &in.LeaseDuration, &out.LeaseDuration
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DHCPReservation.
+func (in *DHCPReservation) DeepCopy() *DHCPReservation {
+	if in == nil {
+		return nil
+	}
+	out := new(DHCPReservation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskInformation) DeepCopyInto(out *DiskInformation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskInformation.
+func (in *DiskInformation) DeepCopy() *DiskInformation {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskInformation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskSelector) DeepCopyInto(out *DiskSelector) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskSelector.
+func (in *DiskSelector) DeepCopy() *DiskSelector {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskSelector)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Environment) DeepCopyInto(out *Environment) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Environment.
+func (in *Environment) DeepCopy() *Environment {
+	if in == nil {
+		return nil
+	}
+	out := new(Environment)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Environment) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EnvironmentList) DeepCopyInto(out *EnvironmentList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Environment, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnvironmentList.
+func (in *EnvironmentList) DeepCopy() *EnvironmentList {
+	if in == nil {
+		return nil
+	}
+	out := new(EnvironmentList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *EnvironmentList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EnvironmentSpec) DeepCopyInto(out *EnvironmentSpec) {
+	*out = *in
+	in.Kernel.DeepCopyInto(&out.Kernel)
+	out.Initrd = in.Initrd
+	if in.ImageFactory != nil {
+		in, out := &in.ImageFactory, &out.ImageFactory
+		*out = new(ImageFactory)
+		**out = **in
+	}
+	if in.Firmware != nil {
+		in, out := &in.Firmware, &out.Firmware
+		*out = new(FirmwareRequirements)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ConfigPatches != nil {
+		in, out := &in.ConfigPatches, &out.ConfigPatches
+		*out = make([]ConfigPatches, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.ConfigPatchesFrom != nil {
+		in, out := &in.ConfigPatchesFrom, &out.ConfigPatchesFrom
+		*out = make([]ConfigPatchesRef, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnvironmentSpec.
+func (in *EnvironmentSpec) DeepCopy() *EnvironmentSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(EnvironmentSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *EnvironmentStatus) DeepCopyInto(out *EnvironmentStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]AssetCondition, len(*in))
+		copy(*out, *in)
+	}
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = make([]SiteReplication, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new EnvironmentStatus.
+func (in *EnvironmentStatus) DeepCopy() *EnvironmentStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(EnvironmentStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalMachine) DeepCopyInto(out *ExternalMachine) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalMachine.
+func (in *ExternalMachine) DeepCopy() *ExternalMachine {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalMachine)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ExternalMachine) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalMachineList) DeepCopyInto(out *ExternalMachineList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ExternalMachine, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalMachineList.
+func (in *ExternalMachineList) DeepCopy() *ExternalMachineList {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalMachineList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ExternalMachineList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ExternalMachineSpec) DeepCopyInto(out *ExternalMachineSpec) {
+	*out = *in
+	if in.MACs != nil {
+		in, out := &in.MACs, &out.MACs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.EnvironmentRef != nil {
+		in, out := &in.EnvironmentRef, &out.EnvironmentRef
+		*out = new(corev1.ObjectReference)
+		**out = **in
+	}
+	if in.MachineRef != nil {
+		in, out := &in.MachineRef, &out.MachineRef
+		*out = new(corev1.ObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ExternalMachineSpec.
+func (in *ExternalMachineSpec) DeepCopy() *ExternalMachineSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ExternalMachineSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FirmwareRequirements) DeepCopyInto(out *FirmwareRequirements) {
+	*out = *in
+	if in.BIOS != nil {
+		in, out := &in.BIOS, &out.BIOS
+		*out = make([]BIOSRequirement, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FirmwareRequirements.
+func (in *FirmwareRequirements) DeepCopy() *FirmwareRequirements {
+	if in == nil {
+		return nil
+	}
+	out := new(FirmwareRequirements)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Freeze) DeepCopyInto(out *Freeze) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Freeze.
+func (in *Freeze) DeepCopy() *Freeze {
+	if in == nil {
+		return nil
+	}
+	out := new(Freeze)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Freeze) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FreezeList) DeepCopyInto(out *FreezeList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]Freeze, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FreezeList.
+func (in *FreezeList) DeepCopy() *FreezeList {
+	if in == nil {
+		return nil
+	}
+	out := new(FreezeList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *FreezeList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FreezeSpec) DeepCopyInto(out *FreezeSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FreezeSpec.
+func (in *FreezeSpec) DeepCopy() *FreezeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(FreezeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HardwareInventory) DeepCopyInto(out *HardwareInventory) {
+	*out = *in
+	if in.NetworkInterfaces != nil {
+		in, out := &in.NetworkInterfaces, &out.NetworkInterfaces
+		*out = make([]NetworkInterface, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.GPUs != nil {
+		in, out := &in.GPUs, &out.GPUs
+		*out = make([]PCIDevice, len(*in))
+		copy(*out, *in)
+	}
+	if in.Disks != nil {
+		in, out := &in.Disks, &out.Disks
+		*out = make([]DiskInformation, len(*in))
+		copy(*out, *in)
+	}
+	if in.Chassis != nil {
+		in, out := &in.Chassis, &out.Chassis
+		*out = new(ChassisLocation)
+		**out = **in
+	}
+	in.UpdatedAt.DeepCopyInto(&out.UpdatedAt)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareInventory.
+func (in *HardwareInventory) DeepCopy() *HardwareInventory {
+	if in == nil {
+		return nil
+	}
+	out := new(HardwareInventory)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageFactory) DeepCopyInto(out *ImageFactory) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageFactory.
+func (in *ImageFactory) DeepCopy() *ImageFactory {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageFactory)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageVerification) DeepCopyInto(out *ImageVerification) {
+	*out = *in
+	out.PublicKeyFrom = in.PublicKeyFrom
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageVerification.
+func (in *ImageVerification) DeepCopy() *ImageVerification {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageVerification)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Initrd) DeepCopyInto(out *Initrd) {
+	*out = *in
+	out.Asset = in.Asset
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Initrd.
+func (in *Initrd) DeepCopy() *Initrd {
+	if in == nil {
+		return nil
+	}
+	out := new(Initrd)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *InstallImage) DeepCopyInto(out *InstallImage) {
+	*out = *in
+	if in.Verify != nil {
+		in, out := &in.Verify, &out.Verify
+		*out = new(ImageVerification)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstallImage.
+func (in *InstallImage) DeepCopy() *InstallImage {
+	if in == nil {
+		return nil
+	}
+	out := new(InstallImage)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Kernel) DeepCopyInto(out *Kernel) {
+	*out = *in
+	out.Asset = in.Asset
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Kernel.
+func (in *Kernel) DeepCopy() *Kernel {
+	if in == nil {
+		return nil
+	}
+	out := new(Kernel)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LinkAggregation) DeepCopyInto(out *LinkAggregation) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LinkAggregation.
+func (in *LinkAggregation) DeepCopy() *LinkAggregation {
+	if in == nil {
+		return nil
+	}
+	out := new(LinkAggregation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ManagementAPI) DeepCopyInto(out *ManagementAPI) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ManagementAPI.
+func (in *ManagementAPI) DeepCopy() *ManagementAPI {
+	if in == nil {
+		return nil
+	}
+	out := new(ManagementAPI)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkInterface) DeepCopyInto(out *NetworkInterface) {
+	*out = *in
+	if in.LinkAggregation != nil {
+		in, out := &in.LinkAggregation, &out.LinkAggregation
+		*out = new(LinkAggregation)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkInterface.
+func (in *NetworkInterface) DeepCopy() *NetworkInterface {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkInterface)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ObjectKeyRef) DeepCopyInto(out *ObjectKeyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ObjectKeyRef.
+func (in *ObjectKeyRef) DeepCopy() *ObjectKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(ObjectKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PCIDevice) DeepCopyInto(out *PCIDevice) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PCIDevice.
+func (in *PCIDevice) DeepCopy() *PCIDevice {
+	if in == nil {
+		return nil
+	}
+	out := new(PCIDevice)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PendingDependency) DeepCopyInto(out *PendingDependency) {
+	*out = *in
+	out.PowerOnDependency = in.PowerOnDependency
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PendingDependency.
+func (in *PendingDependency) DeepCopy() *PendingDependency {
+	if in == nil {
+		return nil
+	}
+	out := new(PendingDependency)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PowerAction) DeepCopyInto(out *PowerAction) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PowerAction.
+func (in *PowerAction) DeepCopy() *PowerAction {
+	if in == nil {
+		return nil
+	}
+	out := new(PowerAction)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PowerAction) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PowerActionList) DeepCopyInto(out *PowerActionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]PowerAction, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PowerActionList.
+func (in *PowerActionList) DeepCopy() *PowerActionList {
+	if in == nil {
+		return nil
+	}
+	out := new(PowerActionList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *PowerActionList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PowerActionSpec) DeepCopyInto(out *PowerActionSpec) {
+	*out = *in
+	out.ServerActionSpec = in.ServerActionSpec
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PowerActionSpec.
+func (in *PowerActionSpec) DeepCopy() *PowerActionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PowerActionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PowerOnDependency) DeepCopyInto(out *PowerOnDependency) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PowerOnDependency.
+func (in *PowerOnDependency) DeepCopy() *PowerOnDependency {
+	if in == nil {
+		return nil
+	}
+	out := new(PowerOnDependency)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PowerOnPolicy) DeepCopyInto(out *PowerOnPolicy) {
+	*out = *in
+	if in.After != nil {
+		in, out := &in.After, &out.After
+		*out = make([]PowerOnDependency, len(*in))
+		copy(*out, *in)
+	}
+	if in.Timeout != nil {
+		in, out := &in.Timeout, &out.Timeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PowerOnPolicy.
+func (in *PowerOnPolicy) DeepCopy() *PowerOnPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PowerOnPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Qualifiers) DeepCopyInto(out *Qualifiers) {
+	*out = *in
+	if in.CPU != nil {
+		in, out := &in.CPU, &out.CPU
+		*out = make([]CPUInformation, len(*in))
+		copy(*out, *in)
+	}
+	if in.SystemInformation != nil {
+		in, out := &in.SystemInformation, &out.SystemInformation
 		*out = make([]SystemInformation, len(*in))
 		copy(*out, *in)
 	}
@@ -286,67 +1262,328 @@ func (in *Qualifiers) DeepCopyInto(out *Qualifiers) {
 		in, out := &in.LabelSelectors, &out.LabelSelectors
 		*out = make([]map[string]string, len(*in))
 		for i := range *in {
-			if (*in)[i] != nil {
-				in, out := &(*in)[i], &(*out)[i]
-				*out = make(map[string]string, len(*in))
-				for key, val := range *in {
-					(*out)[key] = val
-				}
-			}
+			if (*in)[i] != nil {
+				in, out := &(*in)[i], &(*out)[i]
+				*out = make(map[string]string, len(*in))
+				for key, val := range *in {
+					(*out)[key] = val
+				}
+			}
+		}
+	}
+	if in.Expressions != nil {
+		in, out := &in.Expressions, &out.Expressions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Qualifiers.
+func (in *Qualifiers) DeepCopy() *Qualifiers {
+	if in == nil {
+		return nil
+	}
+	out := new(Qualifiers)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RecordedApproval) DeepCopyInto(out *RecordedApproval) {
+	*out = *in
+	out.ServerApproval = in.ServerApproval
+	in.Time.DeepCopyInto(&out.Time)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RecordedApproval.
+func (in *RecordedApproval) DeepCopy() *RecordedApproval {
+	if in == nil {
+		return nil
+	}
+	out := new(RecordedApproval)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReleaseAction) DeepCopyInto(out *ReleaseAction) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReleaseAction.
+func (in *ReleaseAction) DeepCopy() *ReleaseAction {
+	if in == nil {
+		return nil
+	}
+	out := new(ReleaseAction)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ReleaseAction) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReleaseActionList) DeepCopyInto(out *ReleaseActionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ReleaseAction, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReleaseActionList.
+func (in *ReleaseActionList) DeepCopy() *ReleaseActionList {
+	if in == nil {
+		return nil
+	}
+	out := new(ReleaseActionList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ReleaseActionList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeyRef) DeepCopyInto(out *SecretKeyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeyRef.
+func (in *SecretKeyRef) DeepCopy() *SecretKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Server) DeepCopyInto(out *Server) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Server.
+func (in *Server) DeepCopy() *Server {
+	if in == nil {
+		return nil
+	}
+	out := new(Server)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *Server) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServerAcceptancePolicy) DeepCopyInto(out *ServerAcceptancePolicy) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerAcceptancePolicy.
+func (in *ServerAcceptancePolicy) DeepCopy() *ServerAcceptancePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(ServerAcceptancePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ServerAcceptancePolicy) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServerAcceptancePolicyList) DeepCopyInto(out *ServerAcceptancePolicyList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ServerAcceptancePolicy, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerAcceptancePolicyList.
+func (in *ServerAcceptancePolicyList) DeepCopy() *ServerAcceptancePolicyList {
+	if in == nil {
+		return nil
+	}
+	out := new(ServerAcceptancePolicyList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ServerAcceptancePolicyList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServerAcceptancePolicySpec) DeepCopyInto(out *ServerAcceptancePolicySpec) {
+	*out = *in
+	if in.MACPrefixes != nil {
+		in, out := &in.MACPrefixes, &out.MACPrefixes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Manufacturers != nil {
+		in, out := &in.Manufacturers, &out.Manufacturers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ProductNames != nil {
+		in, out := &in.ProductNames, &out.ProductNames
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SerialNumbers != nil {
+		in, out := &in.SerialNumbers, &out.SerialNumbers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Subnets != nil {
+		in, out := &in.Subnets, &out.Subnets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerAcceptancePolicySpec.
+func (in *ServerAcceptancePolicySpec) DeepCopy() *ServerAcceptancePolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ServerAcceptancePolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServerAcceptancePolicyStatus) DeepCopyInto(out *ServerAcceptancePolicyStatus) {
+	*out = *in
+	if in.AcceptedServers != nil {
+		in, out := &in.AcceptedServers, &out.AcceptedServers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.DryRunMatches != nil {
+		in, out := &in.DryRunMatches, &out.DryRunMatches
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1alpha3.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Qualifiers.
-func (in *Qualifiers) DeepCopy() *Qualifiers {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerAcceptancePolicyStatus.
+func (in *ServerAcceptancePolicyStatus) DeepCopy() *ServerAcceptancePolicyStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(Qualifiers)
+	out := new(ServerAcceptancePolicyStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SecretKeyRef) DeepCopyInto(out *SecretKeyRef) {
+func (in *ServerActionSpec) DeepCopyInto(out *ServerActionSpec) {
 	*out = *in
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeyRef.
-func (in *SecretKeyRef) DeepCopy() *SecretKeyRef {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerActionSpec.
+func (in *ServerActionSpec) DeepCopy() *ServerActionSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(SecretKeyRef)
+	out := new(ServerActionSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Server) DeepCopyInto(out *Server) {
+func (in *ServerActionStatus) DeepCopyInto(out *ServerActionStatus) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Server.
-func (in *Server) DeepCopy() *Server {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerActionStatus.
+func (in *ServerActionStatus) DeepCopy() *ServerActionStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(Server)
+	out := new(ServerActionStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *Server) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServerApproval) DeepCopyInto(out *ServerApproval) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerApproval.
+func (in *ServerApproval) DeepCopy() *ServerApproval {
+	if in == nil {
+		return nil
 	}
-	return nil
+	out := new(ServerApproval)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -413,7 +1650,7 @@ func (in *ServerClassSpec) DeepCopyInto(out *ServerClassSpec) {
 	*out = *in
 	if in.EnvironmentRef != nil {
 		in, out := &in.EnvironmentRef, &out.EnvironmentRef
-		*out = new(v1.ObjectReference)
+		*out = new(corev1.ObjectReference)
 		**out = **in
 	}
 	in.Qualifiers.DeepCopyInto(&out.Qualifiers)
@@ -425,6 +1662,26 @@ func (in *ServerClassSpec) DeepCopyInto(out *ServerClassSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.ConfigPatchesFrom != nil {
+		in, out := &in.ConfigPatchesFrom, &out.ConfigPatchesFrom
+		*out = make([]ConfigPatchesRef, len(*in))
+		copy(*out, *in)
+	}
+	if in.WipePolicy != nil {
+		in, out := &in.WipePolicy, &out.WipePolicy
+		*out = new(WipePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PowerOnPolicy != nil {
+		in, out := &in.PowerOnPolicy, &out.PowerOnPolicy
+		*out = new(PowerOnPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.InstallImage != nil {
+		in, out := &in.InstallImage, &out.InstallImage
+		*out = new(InstallImage)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerClassSpec.
@@ -450,6 +1707,13 @@ func (in *ServerClassStatus) DeepCopyInto(out *ServerClassStatus) {
 		*out = make([]string, len(*in))
 		copy(*out, *in)
 	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1alpha3.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerClassStatus.
@@ -499,7 +1763,7 @@ func (in *ServerSpec) DeepCopyInto(out *ServerSpec) {
 	*out = *in
 	if in.EnvironmentRef != nil {
 		in, out := &in.EnvironmentRef, &out.EnvironmentRef
-		*out = new(v1.ObjectReference)
+		*out = new(corev1.ObjectReference)
 		**out = **in
 	}
 	if in.SystemInformation != nil {
@@ -512,6 +1776,11 @@ func (in *ServerSpec) DeepCopyInto(out *ServerSpec) {
 		*out = new(CPUInformation)
 		**out = **in
 	}
+	if in.BIOS != nil {
+		in, out := &in.BIOS, &out.BIOS
+		*out = new(BIOSInformation)
+		**out = **in
+	}
 	if in.BMC != nil {
 		in, out := &in.BMC, &out.BMC
 		*out = new(BMC)
@@ -529,6 +1798,38 @@ func (in *ServerSpec) DeepCopyInto(out *ServerSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.ConfigPatchesFrom != nil {
+		in, out := &in.ConfigPatchesFrom, &out.ConfigPatchesFrom
+		*out = make([]ConfigPatchesRef, len(*in))
+		copy(*out, *in)
+	}
+	if in.WipePolicy != nil {
+		in, out := &in.WipePolicy, &out.WipePolicy
+		*out = new(WipePolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Asset != nil {
+		in, out := &in.Asset, &out.Asset
+		*out = new(AssetInformation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PowerOnPolicy != nil {
+		in, out := &in.PowerOnPolicy, &out.PowerOnPolicy
+		*out = new(PowerOnPolicy)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DHCPReservations != nil {
+		in, out := &in.DHCPReservations, &out.DHCPReservations
+		*out = make([]DHCPReservation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.InstallImage != nil {
+		in, out := &in.InstallImage, &out.InstallImage
+		*out = new(InstallImage)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerSpec.
@@ -553,9 +1854,24 @@ func (in *ServerStatus) DeepCopyInto(out *ServerStatus) {
 	}
 	if in.Addresses != nil {
 		in, out := &in.Addresses, &out.Addresses
-		*out = make([]v1.NodeAddress, len(*in))
+		*out = make([]corev1.NodeAddress, len(*in))
+		copy(*out, *in)
+	}
+	if in.WipeSkippedDisks != nil {
+		in, out := &in.WipeSkippedDisks, &out.WipeSkippedDisks
+		*out = make([]SkippedDisk, len(*in))
 		copy(*out, *in)
 	}
+	if in.Approval != nil {
+		in, out := &in.Approval, &out.Approval
+		*out = new(ApprovalStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Inventory != nil {
+		in, out := &in.Inventory, &out.Inventory
+		*out = new(HardwareInventory)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServerStatus.
@@ -568,6 +1884,139 @@ func (in *ServerStatus) DeepCopy() *ServerStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SiteCache) DeepCopyInto(out *SiteCache) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SiteCache.
+func (in *SiteCache) DeepCopy() *SiteCache {
+	if in == nil {
+		return nil
+	}
+	out := new(SiteCache)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SiteCache) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SiteCacheList) DeepCopyInto(out *SiteCacheList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SiteCache, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SiteCacheList.
+func (in *SiteCacheList) DeepCopy() *SiteCacheList {
+	if in == nil {
+		return nil
+	}
+	out := new(SiteCacheList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SiteCacheList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SiteCacheSpec) DeepCopyInto(out *SiteCacheSpec) {
+	*out = *in
+	if in.TokenFrom != nil {
+		in, out := &in.TokenFrom, &out.TokenFrom
+		*out = new(CredentialSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Subnets != nil {
+		in, out := &in.Subnets, &out.Subnets
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.BandwidthLimit != nil {
+		in, out := &in.BandwidthLimit, &out.BandwidthLimit
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Windows != nil {
+		in, out := &in.Windows, &out.Windows
+		*out = make([]TransferWindow, len(*in))
+		copy(*out, *in)
+	}
+	if in.EnvironmentSelector != nil {
+		in, out := &in.EnvironmentSelector, &out.EnvironmentSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SiteCacheSpec.
+func (in *SiteCacheSpec) DeepCopy() *SiteCacheSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SiteCacheSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SiteReplication) DeepCopyInto(out *SiteReplication) {
+	*out = *in
+	out.Asset = in.Asset
+	if in.LastTransferTime != nil {
+		in, out := &in.LastTransferTime, &out.LastTransferTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SiteReplication.
+func (in *SiteReplication) DeepCopy() *SiteReplication {
+	if in == nil {
+		return nil
+	}
+	out := new(SiteReplication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SkippedDisk) DeepCopyInto(out *SkippedDisk) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SkippedDisk.
+func (in *SkippedDisk) DeepCopy() *SkippedDisk {
+	if in == nil {
+		return nil
+	}
+	out := new(SkippedDisk)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SystemInformation) DeepCopyInto(out *SystemInformation) {
 	*out = *in
@@ -582,3 +2031,118 @@ func (in *SystemInformation) DeepCopy() *SystemInformation {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TransferWindow) DeepCopyInto(out *TransferWindow) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TransferWindow.
+func (in *TransferWindow) DeepCopy() *TransferWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(TransferWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WipeAction) DeepCopyInto(out *WipeAction) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	out.Spec = in.Spec
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WipeAction.
+func (in *WipeAction) DeepCopy() *WipeAction {
+	if in == nil {
+		return nil
+	}
+	out := new(WipeAction)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *WipeAction) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WipeActionList) DeepCopyInto(out *WipeActionList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]WipeAction, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WipeActionList.
+func (in *WipeActionList) DeepCopy() *WipeActionList {
+	if in == nil {
+		return nil
+	}
+	out := new(WipeActionList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *WipeActionList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WipeActionSpec) DeepCopyInto(out *WipeActionSpec) {
+	*out = *in
+	out.ServerActionSpec = in.ServerActionSpec
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WipeActionSpec.
+func (in *WipeActionSpec) DeepCopy() *WipeActionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WipeActionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WipePolicy) DeepCopyInto(out *WipePolicy) {
+	*out = *in
+	if in.Include != nil {
+		in, out := &in.Include, &out.Include
+		*out = make([]DiskSelector, len(*in))
+		copy(*out, *in)
+	}
+	if in.Exclude != nil {
+		in, out := &in.Exclude, &out.Exclude
+		*out = make([]DiskSelector, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WipePolicy.
+func (in *WipePolicy) DeepCopy() *WipePolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(WipePolicy)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -0,0 +1,281 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package main implements the first stage of the agent boot.
+//
+// The agent initramfs contains only this binary (and network firmware): it downloads
+// the squashfs root filesystem with the agent over HTTP, verifies its hash, mounts it
+// with a writable overlay and switches root to the agent.
+package main
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	kmsg "github.com/talos-systems/go-kmsg"
+	"github.com/talos-systems/go-procfs/procfs"
+	"github.com/talos-systems/go-retry/retry"
+	"golang.org/x/sys/unix"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
+)
+
+const (
+	rootfsPath  = "/rootfs.sqsh"
+	lowerPath   = "/lower"
+	overlayPath = "/overlay"
+	newRootPath = "/newroot"
+
+	agentInit = "/init"
+)
+
+func setup() error {
+	for _, dir := range []string{"/dev", "/etc", "/proc", "/sys"} {
+		if err := os.MkdirAll(dir, 0o777); err != nil {
+			return err
+		}
+	}
+
+	if err := unix.Mount("devtmpfs", "/dev", "devtmpfs", unix.MS_NOSUID, "mode=0755"); err != nil {
+		return err
+	}
+
+	if err := unix.Mount("proc", "/proc", "proc", unix.MS_NOSUID|unix.MS_NOEXEC|unix.MS_NODEV, ""); err != nil {
+		return err
+	}
+
+	if err := unix.Mount("sysfs", "/sys", "sysfs", 0, ""); err != nil {
+		return err
+	}
+
+	// kernel configures the network (ip=dhcp), resolve the endpoint via DNS servers it got
+	if err := unix.Symlink("/proc/net/pnp", "/etc/resolv.conf"); err != nil {
+		return err
+	}
+
+	return kmsg.SetupLogger(nil, "[sidero-init]", nil)
+}
+
+// errHashMismatch is not retried, the image on the server is different.
+var errHashMismatch = errors.New("rootfs hash mismatch")
+
+// download fetches the rootfs image from the first reachable URL (in the order of priority) verifying SHA512 hash on the fly.
+func download(ctx context.Context, urls []string, expectedHash string) error {
+	return retry.Constant(5*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).Retry(func() error {
+		var err error
+
+		for _, url := range urls {
+			if err = fetch(ctx, url, expectedHash); err == nil || errors.Is(err, errHashMismatch) {
+				return err
+			}
+
+			log.Printf("Failed to download rootfs from %q: %s", url, err)
+		}
+
+		return retry.ExpectedError(err)
+	})
+}
+
+// fetch downloads the rootfs image from the URL.
+func fetch(ctx context.Context, url, expectedHash string) error {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download %q: %s", url, resp.Status)
+	}
+
+	f, err := os.Create(rootfsPath)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	hash := sha512.New()
+
+	n, err := io.Copy(io.MultiWriter(f, hash), resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if actualHash := hex.EncodeToString(hash.Sum(nil)); actualHash != expectedHash {
+		return fmt.Errorf("%w: expected %q, got %q", errHashMismatch, expectedHash, actualHash)
+	}
+
+	log.Printf("Downloaded %d bytes of rootfs from %q", n, url)
+
+	return f.Close()
+}
+
+// attachLoop attaches the file to a free loop device read-only.
+func attachLoop(path string) (string, error) {
+	control, err := os.OpenFile("/dev/loop-control", os.O_RDWR, 0)
+	if err != nil {
+		return "", err
+	}
+
+	defer control.Close() //nolint:errcheck
+
+	index, err := unix.IoctlRetInt(int(control.Fd()), unix.LOOP_CTL_GET_FREE)
+	if err != nil {
+		return "", fmt.Errorf("error getting free loop device: %w", err)
+	}
+
+	device := fmt.Sprintf("/dev/loop%d", index)
+
+	loop, err := os.OpenFile(device, os.O_RDWR, 0)
+	if err != nil {
+		return "", err
+	}
+
+	defer loop.Close() //nolint:errcheck
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	// file is opened read-only, so the loop device is read-only as well
+	if err = unix.IoctlSetInt(int(loop.Fd()), unix.LOOP_SET_FD, int(f.Fd())); err != nil {
+		return "", fmt.Errorf("error attaching %q to %q: %w", path, device, err)
+	}
+
+	return device, nil
+}
+
+// mountRoot mounts squashfs image with tmpfs overlay on top of it.
+func mountRoot(device string) error {
+	for _, dir := range []string{lowerPath, overlayPath, newRootPath} {
+		if err := os.MkdirAll(dir, 0o777); err != nil {
+			return err
+		}
+	}
+
+	if err := unix.Mount(device, lowerPath, "squashfs", unix.MS_RDONLY, ""); err != nil {
+		return fmt.Errorf("error mounting rootfs: %w", err)
+	}
+
+	if err := unix.Mount("tmpfs", overlayPath, "tmpfs", 0, ""); err != nil {
+		return err
+	}
+
+	upper, work := filepath.Join(overlayPath, "upper"), filepath.Join(overlayPath, "work")
+
+	for _, dir := range []string{upper, work} {
+		if err := os.MkdirAll(dir, 0o777); err != nil {
+			return err
+		}
+	}
+
+	return unix.Mount("overlay", newRootPath, "overlay", 0, fmt.Sprintf("lowerdir=%s,upperdir=%s,workdir=%s", lowerPath, upper, work))
+}
+
+// switchRoot makes new root the root of the filesystem and executes the agent.
+//
+// Pseudo filesystems are unmounted, agent mounts them again on startup.
+func switchRoot() error {
+	for _, dir := range []string{"/sys", "/proc", "/dev"} {
+		if err := unix.Unmount(dir, unix.MNT_DETACH); err != nil {
+			return err
+		}
+	}
+
+	if err := unix.Chdir(newRootPath); err != nil {
+		return err
+	}
+
+	if err := unix.Mount(newRootPath, "/", "", unix.MS_MOVE, ""); err != nil {
+		return err
+	}
+
+	if err := unix.Chroot("."); err != nil {
+		return err
+	}
+
+	if err := unix.Chdir("/"); err != nil {
+		return err
+	}
+
+	return unix.Exec(agentInit, []string{agentInit}, os.Environ())
+}
+
+func mainFunc() error {
+	if err := setup(); err != nil {
+		return err
+	}
+
+	cmdline := procfs.ProcCmdline()
+
+	urls := cmdline.Get(constants.AgentRootfsArg).First()
+	if urls == nil {
+		return fmt.Errorf("no %s kernel argument found", constants.AgentRootfsArg)
+	}
+
+	hash := cmdline.Get(constants.AgentRootfsSHA512Arg).First()
+	if hash == nil {
+		return fmt.Errorf("no %s kernel argument found", constants.AgentRootfsSHA512Arg)
+	}
+
+	log.Printf("Downloading rootfs from %q", *urls)
+
+	if err := download(context.Background(), strings.Split(*urls, ","), *hash); err != nil {
+		return err
+	}
+
+	device, err := attachLoop(rootfsPath)
+	if err != nil {
+		return err
+	}
+
+	if err = mountRoot(device); err != nil {
+		return err
+	}
+
+	log.Println("Switching to agent rootfs")
+
+	return switchRoot()
+}
+
+func main() {
+	err := mainFunc()
+
+	// mainFunc only returns on error
+	log.Println(err)
+
+	for i := 10; i >= 0; i-- {
+		log.Printf("rebooting in %d seconds\n", i)
+		time.Sleep(1 * time.Second)
+	}
+
+	if unix.Reboot(unix.LINUX_REBOOT_CMD_RESTART) == nil {
+		select {}
+	}
+
+	os.Exit(1)
+}
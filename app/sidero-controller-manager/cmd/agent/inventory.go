@@ -0,0 +1,311 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/talos-systems/go-blockdevice/blockdevice/util/disk"
+	"github.com/talos-systems/go-retry/retry"
+	"github.com/talos-systems/go-smbios/smbios"
+	"golang.org/x/sys/unix"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/api"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/power/ipmi"
+)
+
+// pciClassDisplay is the PCI base class of display controllers (GPUs).
+const pciClassDisplay = "0x03"
+
+// inventory collects the hardware inventory and reports it.
+//
+// Failures to collect any part of the inventory are logged, and the rest is still reported.
+func inventory(ctx context.Context, client api.AgentClient, s *smbios.SMBIOS) error {
+	uuid, err := s.SystemInformation().UUID()
+	if err != nil {
+		return err
+	}
+
+	req := &api.UpdateInventoryRequest{
+		Uuid:              uuid.String(),
+		NetworkInterfaces: networkInterfaces(),
+		Gpus:              gpus(),
+		Disks:             disks(),
+		ChassisLocation:   chassisLocation(s),
+	}
+
+	if version, err := bmcFirmwareVersion(); err != nil {
+		log.Printf("failed to read BMC firmware version: %s", err)
+	} else {
+		req.BmcFirmwareVersion = version
+	}
+
+	return retry.Constant(5*time.Minute, retry.WithUnits(30*time.Second), retry.WithErrorLogging(true)).Retry(func() error {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		_, err = client.UpdateInventory(ctx, req)
+		if err != nil {
+			return retry.ExpectedError(err)
+		}
+
+		return nil
+	})
+}
+
+// physicalInterfaces lists the network interfaces backed by the devices.
+func physicalInterfaces() []net.Interface {
+	links, err := net.Interfaces()
+	if err != nil {
+		log.Printf("failed to list network interfaces: %s", err)
+
+		return nil
+	}
+
+	var physical []net.Interface
+
+	for _, link := range links {
+		if _, err := os.Stat(filepath.Join("/sys/class/net", link.Name, "device")); err != nil {
+			continue
+		}
+
+		physical = append(physical, link)
+	}
+
+	return physical
+}
+
+// networkInterfaces lists the physical network interfaces (the ones backed by a device).
+//
+// Interfaces connected to the aggregated (LACP) switch ports are detected by listening to the switch for a while.
+func networkInterfaces() []*api.NetworkInterface {
+	physical := physicalInterfaces()
+
+	aggregations := linkAggregations(physical)
+
+	result := make([]*api.NetworkInterface, 0, len(physical))
+
+	for _, link := range physical {
+		nic := &api.NetworkInterface{
+			Name: link.Name,
+			Mac:  link.HardwareAddr.String(),
+
+			LinkAggregation: aggregations[link.Name],
+		}
+
+		// speed is not available (or -1) when the link is down
+		if speed, err := strconv.Atoi(readSysfs(filepath.Join("/sys/class/net", link.Name, "speed"))); err == nil && speed > 0 {
+			nic.SpeedMbps = uint32(speed)
+		}
+
+		if info, err := ethtoolDriverInfo(link.Name); err != nil {
+			log.Printf("failed to read driver info for %s: %s", link.Name, err)
+		} else {
+			nic.Driver = cString(info.Driver[:])
+			nic.FirmwareVersion = cString(info.Fw_version[:])
+			nic.PciAddress = cString(info.Bus_info[:])
+		}
+
+		result = append(result, nic)
+	}
+
+	return result
+}
+
+func ethtoolDriverInfo(name string) (*unix.EthtoolDrvinfo, error) {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	defer unix.Close(fd) //nolint:errcheck
+
+	return unix.IoctlGetEthtoolDrvinfo(fd, name)
+}
+
+// gpus lists the display controllers on the PCI bus.
+func gpus() []*api.PCIDevice {
+	devices, err := ioutil.ReadDir("/sys/bus/pci/devices")
+	if err != nil {
+		log.Printf("failed to list PCI devices: %s", err)
+
+		return nil
+	}
+
+	var result []*api.PCIDevice
+
+	for _, device := range devices {
+		path := filepath.Join("/sys/bus/pci/devices", device.Name())
+
+		if !strings.HasPrefix(readSysfs(filepath.Join(path, "class")), pciClassDisplay) {
+			continue
+		}
+
+		gpu := &api.PCIDevice{
+			PciAddress: device.Name(),
+			VendorId:   readSysfs(filepath.Join(path, "vendor")),
+			DeviceId:   readSysfs(filepath.Join(path, "device")),
+		}
+
+		if driver, err := os.Readlink(filepath.Join(path, "driver")); err == nil {
+			gpu.Driver = filepath.Base(driver)
+		}
+
+		result = append(result, gpu)
+	}
+
+	return result
+}
+
+// disks lists the disks with their SMART health.
+func disks() []*api.Disk {
+	list, err := disk.List()
+	if err != nil {
+		log.Printf("failed to list disks: %s", err)
+
+		return nil
+	}
+
+	result := make([]*api.Disk, 0, len(list))
+
+	for _, d := range list {
+		name := filepath.Base(d.DeviceName)
+
+		firmware := readSysfs(filepath.Join("/sys/block", name, "device", "firmware_rev"))
+		if firmware == "" {
+			firmware = readSysfs(filepath.Join("/sys/block", name, "device", "rev"))
+		}
+
+		health, err := smartHealth(d.DeviceName, d.Type)
+		if err != nil {
+			log.Printf("failed to read SMART health of %s: %s", d.DeviceName, err)
+		}
+
+		result = append(result, &api.Disk{
+			DeviceName:      d.DeviceName,
+			Model:           d.Model,
+			Serial:          d.Serial,
+			Type:            d.Type.String(),
+			Size:            d.Size,
+			FirmwareVersion: firmware,
+			SmartHealth:     health,
+		})
+	}
+
+	return result
+}
+
+// smbiosPlaceholders are the values vendors leave in the SMBIOS strings which are not set.
+var smbiosPlaceholders = []string{
+	"to be filled by o.e.m.",
+	"default string",
+	"not specified",
+	"not applicable",
+	"none",
+	"n/a",
+	"0",
+}
+
+// smbiosString returns the trimmed SMBIOS string, empty if it's a placeholder.
+func smbiosString(s string) string {
+	s = strings.TrimSpace(s)
+
+	for _, placeholder := range smbiosPlaceholders {
+		if strings.EqualFold(s, placeholder) {
+			return ""
+		}
+	}
+
+	return s
+}
+
+// chassisLocation detects the location of the server in the blade enclosure or the multi-node chassis.
+//
+// The slot is read from the SMBIOS baseboard information, the chassis serial number from the SMBIOS
+// system enclosure falling back to the chassis info area of the IPMI FRU.
+// The location is not reported if the slot is not detected (the server is not in the shared chassis).
+func chassisLocation(s *smbios.SMBIOS) *api.ChassisLocation {
+	slot := smbiosString(s.BaseboardInformation().LocationInChassis())
+	if slot == "" {
+		return nil
+	}
+
+	systemSerial := smbiosString(s.SystemInformation().SerialNumber())
+
+	location := &api.ChassisLocation{
+		Slot:     slot,
+		AssetTag: smbiosString(s.SystemEnclosure().AssetTagNumber()),
+	}
+
+	// the enclosure with the serial number of the system is the node itself, not the shared chassis
+	if serial := smbiosString(s.SystemEnclosure().SerialNumber()); serial != systemSerial {
+		location.SerialNumber = serial
+	}
+
+	if location.SerialNumber == "" {
+		if serial, err := fruChassisSerialNumber(); err != nil {
+			log.Printf("failed to read IPMI FRU chassis info: %s", err)
+		} else if serial != systemSerial {
+			location.SerialNumber = serial
+		}
+	}
+
+	return location
+}
+
+func fruChassisSerialNumber() (string, error) {
+	ipmiClient, err := ipmi.NewClient(v1alpha1.BMC{
+		Interface: "open",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	chassis, err := ipmiClient.Chassis()
+	if err != nil || chassis == nil {
+		return "", err
+	}
+
+	return smbiosString(chassis.SerialNumber), nil
+}
+
+func bmcFirmwareVersion() (string, error) {
+	ipmiClient, err := ipmi.NewClient(v1alpha1.BMC{
+		Interface: "open",
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return ipmiClient.FirmwareVersion()
+}
+
+// readSysfs returns the trimmed contents of the sysfs attribute, empty string if it doesn't exist.
+func readSysfs(path string) string {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(contents))
+}
+
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+
+	return string(b)
+}
@@ -0,0 +1,187 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"errors"
+	"log"
+	"net"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/api"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/lacp"
+)
+
+// linkAggregationWindow covers the slow LACP rate and the default LLDP interval (30s).
+const linkAggregationWindow = 35 * time.Second
+
+// linkAggregations listens for the LACPDUs and LLDPDUs on the interfaces with the carrier,
+// and reports the interfaces connected to the aggregated switch ports.
+func linkAggregations(links []net.Interface) map[string]*api.LinkAggregation {
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		result = map[string]*api.LinkAggregation{}
+	)
+
+	deadline := time.Now().Add(linkAggregationWindow)
+
+	for _, link := range links {
+		link := link
+
+		if readSysfs(filepath.Join("/sys/class/net", link.Name, "carrier")) != "1" {
+			continue
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			aggregation, err := linkAggregation(link, deadline)
+			if err != nil {
+				log.Printf("failed to detect link aggregation on %s: %s", link.Name, err)
+
+				return
+			}
+
+			if aggregation == nil {
+				return
+			}
+
+			log.Printf("switch port %s of %s connected to %s is aggregated (detected via %s)", aggregation.Port, aggregation.Partner, link.Name, aggregation.Source)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			result[link.Name] = &api.LinkAggregation{
+				Source:  aggregation.Source,
+				Partner: aggregation.Partner,
+				Port:    aggregation.Port,
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return result
+}
+
+// linkAggregation returns the first aggregation detected either via LACP or LLDP before the deadline.
+func linkAggregation(link net.Interface, deadline time.Time) (*lacp.Aggregation, error) {
+	type captured struct {
+		aggregation *lacp.Aggregation
+		err         error
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	ch := make(chan captured, 2)
+
+	go func() {
+		aggregation, err := capture(link, lacp.EtherTypeSlowProtocols, lacp.SlowProtocolsMulticast, lacp.ParseLACPDU, deadline, done)
+		ch <- captured{aggregation, err}
+	}()
+
+	go func() {
+		aggregation, err := capture(link, lacp.EtherTypeLLDP, lacp.LLDPMulticast, lacp.ParseLLDPDU, deadline, done)
+		ch <- captured{aggregation, err}
+	}()
+
+	var errs []error
+
+	for i := 0; i < 2; i++ {
+		c := <-ch
+
+		if c.err != nil {
+			errs = append(errs, c.err)
+
+			continue
+		}
+
+		if c.aggregation != nil {
+			return c.aggregation, nil
+		}
+	}
+
+	if len(errs) == 2 {
+		return nil, errs[0]
+	}
+
+	return nil, nil
+}
+
+// capture receives the frames of the EtherType sent to the multicast address until the frame is parsed to an aggregation,
+// the deadline passes or the capture is cancelled.
+func capture(link net.Interface, etherType uint16, multicast net.HardwareAddr, parse func([]byte) (*lacp.Aggregation, error),
+	deadline time.Time, done <-chan struct{}) (*lacp.Aggregation, error) {
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_DGRAM, int(htons(etherType)))
+	if err != nil {
+		return nil, err
+	}
+
+	defer unix.Close(fd) //nolint:errcheck
+
+	if err = unix.Bind(fd, &unix.SockaddrLinklayer{Protocol: htons(etherType), Ifindex: link.Index}); err != nil {
+		return nil, err
+	}
+
+	mreq := unix.PacketMreq{
+		Ifindex: int32(link.Index),
+		Type:    unix.PACKET_MR_MULTICAST,
+		Alen:    uint16(len(multicast)),
+	}
+
+	copy(mreq.Address[:], multicast)
+
+	if err = unix.SetsockoptPacketMreq(fd, unix.SOL_PACKET, unix.PACKET_ADD_MEMBERSHIP, &mreq); err != nil {
+		return nil, err
+	}
+
+	// wake up periodically to check for the deadline and the cancellation
+	if err = unix.SetsockoptTimeval(fd, unix.SOL_SOCKET, unix.SO_RCVTIMEO, &unix.Timeval{Sec: 1}); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 1500)
+
+	for time.Now().Before(deadline) {
+		select {
+		case <-done:
+			return nil, nil
+		default:
+		}
+
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			if errors.Is(err, unix.EAGAIN) || errors.Is(err, unix.EINTR) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		aggregation, err := parse(buf[:n])
+		if err != nil {
+			// malformed or unsupported frame, keep listening
+			continue
+		}
+
+		if aggregation != nil {
+			return aggregation, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
@@ -14,6 +14,7 @@ import (
 	"math/big"
 	"net"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -28,17 +29,31 @@ import (
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sys/unix"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
+	"google.golang.org/grpc/status"
 
 	"github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
 	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/api"
 	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/power/ipmi"
 	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/signatures"
 )
 
 const (
 	debugAddr = ":9991"
+
+	// bootFromDiskPollInterval is how often the agent of the unexpectedly booted server checks for the boot from disk request.
+	bootFromDiskPollInterval = 30 * time.Second
+
+	// wipeConfirmationPollInterval is how often the agent checks for the confirmation to wipe the disks with the existing data.
+	wipeConfirmationPollInterval = 30 * time.Second
 )
 
+// talosPartitions are the labels of the partitions created by Talos installer on the system disk.
+var talosPartitions = []string{"BOOT", "STATE", "EPHEMERAL"}
+
 func setup() error {
 	if err := os.MkdirAll("/etc", 0o777); err != nil {
 		return err
@@ -112,6 +127,11 @@ func create(ctx context.Context, client api.AgentClient, s *smbios.SMBIOS) (*api
 			Manufacturer: s.ProcessorInformation().ProcessorManufacturer(),
 			Version:      s.ProcessorInformation().ProcessorVersion(),
 		},
+		Bios: &api.BIOSInformation{
+			Vendor:      s.BIOSInformation().Vendor(),
+			Version:     s.BIOSInformation().Version(),
+			ReleaseDate: s.BIOSInformation().ReleaseDate(),
+		},
 	}
 
 	hostname, err := os.Hostname()
@@ -121,6 +141,10 @@ func create(ctx context.Context, client api.AgentClient, s *smbios.SMBIOS) (*api
 		req.Hostname = hostname
 	}
 
+	for _, link := range physicalInterfaces() {
+		req.MacAddresses = append(req.MacAddresses, link.HardwareAddr.String())
+	}
+
 	var resp *api.CreateServerResponse
 
 	err = retry.Constant(5*time.Minute, retry.WithUnits(30*time.Second), retry.WithErrorLogging(true)).Retry(func() error {
@@ -129,6 +153,11 @@ func create(ctx context.Context, client api.AgentClient, s *smbios.SMBIOS) (*api
 
 		resp, err = client.CreateServer(ctx, req)
 		if err != nil {
+			// registration is refused by the identity webhook, retrying won't help
+			if status.Code(err) == codes.PermissionDenied {
+				return err
+			}
+
 			return retry.ExpectedError(err)
 		}
 
@@ -138,7 +167,7 @@ func create(ctx context.Context, client api.AgentClient, s *smbios.SMBIOS) (*api
 	return resp, err
 }
 
-func wipe(ctx context.Context, client api.AgentClient, s *smbios.SMBIOS) error {
+func wipe(ctx context.Context, client api.AgentClient, s *smbios.SMBIOS, duration time.Duration, wiped []*api.WipedDisk, skipped []*api.SkippedDisk) error {
 	uuid, err := s.SystemInformation().UUID()
 	if err != nil {
 		return err
@@ -148,7 +177,12 @@ func wipe(ctx context.Context, client api.AgentClient, s *smbios.SMBIOS) error {
 		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 		defer cancel()
 
-		_, err = client.MarkServerAsWiped(ctx, &api.MarkServerAsWipedRequest{Uuid: uuid.String()})
+		_, err = client.MarkServerAsWiped(ctx, &api.MarkServerAsWipedRequest{
+			Uuid:         uuid.String(),
+			WipeDuration: duration.Seconds(),
+			SkippedDisks: skipped,
+			WipedDisks:   wiped,
+		})
 		if err != nil {
 			return retry.ExpectedError(err)
 		}
@@ -157,6 +191,159 @@ func wipe(ctx context.Context, client api.AgentClient, s *smbios.SMBIOS) error {
 	})
 }
 
+// verifyWipe checks that the head of the disk reads back as zeroes, so that no partition table
+// or filesystem superblock is left after the wipe.
+func verifyWipe(bd *blockdevice.BlockDevice) (bool, error) {
+	size, err := bd.Size()
+	if err != nil {
+		return false, err
+	}
+
+	length := uint64(blockdevice.FastWipeRange)
+
+	if length > size {
+		length = size
+	}
+
+	buf := make([]byte, length)
+
+	if _, err = bd.Device().ReadAt(buf, 0); err != nil {
+		return false, err
+	}
+
+	for _, b := range buf {
+		if b != 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// wipePolicy converts the wipe policy from the API, no policy means wiping all the disks.
+func wipePolicy(in *api.WipePolicy) *v1alpha1.WipePolicy {
+	diskSelectors := func(in []*api.DiskSelector) []v1alpha1.DiskSelector {
+		result := make([]v1alpha1.DiskSelector, 0, len(in))
+
+		for _, selector := range in {
+			result = append(result, v1alpha1.DiskSelector{
+				DeviceName: selector.GetDeviceName(),
+				Model:      selector.GetModel(),
+				Serial:     selector.GetSerial(),
+			})
+		}
+
+		return result
+	}
+
+	if in == nil {
+		return &v1alpha1.WipePolicy{
+			Mode: v1alpha1.WipeModeAll,
+		}
+	}
+
+	return &v1alpha1.WipePolicy{
+		Mode:    v1alpha1.WipeMode(in.GetMode()),
+		Include: diskSelectors(in.GetInclude()),
+		Exclude: diskSelectors(in.GetExclude()),
+	}
+}
+
+// isSystemDisk checks whether the disk has Talos partitions.
+func isSystemDisk(bd *blockdevice.BlockDevice) bool {
+	for _, label := range talosPartitions {
+		if _, err := bd.GetPartition(label); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// dataSignatures detects the existing data on the disk and its partitions.
+func dataSignatures(bd *blockdevice.BlockDevice) ([]string, error) {
+	var found []string
+
+	if isSystemDisk(bd) {
+		found = append(found, signatures.Talos)
+	}
+
+	offsets := []int64{0}
+
+	if pt, err := bd.PartitionTable(); err == nil {
+		for _, partition := range pt.Partitions().Items() {
+			offsets = append(offsets, int64(partition.FirstLBA)*pt.Header().LogicalBlockSize)
+		}
+	}
+
+	detected, err := signatures.Detect(bd.Device(), offsets...)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(found, detected...), nil
+}
+
+// confirmWipe requests the confirmation to wipe the disks with the existing data and waits for it.
+func confirmWipe(ctx context.Context, client api.AgentClient, s *smbios.SMBIOS, disks []*api.DataDisk) error {
+	uuid, err := s.SystemInformation().UUID()
+	if err != nil {
+		return err
+	}
+
+	var resp *api.RequestWipeConfirmationResponse
+
+	err = retry.Constant(5*time.Minute, retry.WithUnits(30*time.Second), retry.WithErrorLogging(true)).Retry(func() error {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		resp, err = client.RequestWipeConfirmation(ctx, &api.RequestWipeConfirmationRequest{
+			Uuid:  uuid.String(),
+			Disks: disks,
+		})
+		if err != nil {
+			return retry.ExpectedError(err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if resp.GetConfirmed() {
+		return nil
+	}
+
+	log.Printf("Existing data found, waiting for the wipe to be confirmed (annotate the server with %q)", v1alpha1.ServerConfirmWipeAnnotation)
+
+	ticker := time.NewTicker(wipeConfirmationPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, wipeConfirmationPollInterval)
+
+		heartbeat, err := client.Heartbeat(callCtx, &api.HeartbeatRequest{Uuid: uuid.String()})
+
+		cancel()
+
+		switch {
+		case err != nil:
+			log.Printf("Failed to send heartbeat %s", err)
+		case heartbeat.GetWipeConfirmed():
+			log.Println("Wipe confirmed")
+
+			return nil
+		}
+	}
+}
+
 func reconcileIPs(ctx context.Context, client api.AgentClient, s *smbios.SMBIOS, ips []net.IP) error {
 	uuid, err := s.SystemInformation().UUID()
 	if err != nil {
@@ -204,11 +391,24 @@ func shutdown(err error) {
 	os.Exit(1)
 }
 
-func connect(ctx context.Context, endpoint string) (*grpc.ClientConn, error) {
+// connect dials the API endpoints in the order of priority.
+//
+// The endpoints are the addresses of a single connection (pick_first balancing),
+// so that gRPC fails over to the next endpoint whenever the current one is unreachable.
+func connect(ctx context.Context, endpoints []string) (*grpc.ClientConn, error) {
 	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
 	defer cancel()
 
-	return grpc.DialContext(ctx, endpoint, grpc.WithInsecure())
+	addresses := make([]resolver.Address, 0, len(endpoints))
+
+	for _, endpoint := range endpoints {
+		addresses = append(addresses, resolver.Address{Addr: endpoint})
+	}
+
+	r := manual.NewBuilderWithScheme("sidero")
+	r.InitialState(resolver.State{Addresses: addresses})
+
+	return grpc.DialContext(ctx, r.Scheme()+":///api", grpc.WithInsecure(), grpc.WithResolvers(r))
 }
 
 func mainFunc() error {
@@ -228,16 +428,16 @@ func mainFunc() error {
 		return err
 	}
 
-	var endpoint string
+	var endpoints []string
 	if found := procfs.ProcCmdline().Get(constants.AgentEndpointArg).First(); found != nil {
-		endpoint = *found
+		endpoints = strings.Split(*found, ",")
 	} else {
 		return fmt.Errorf("no endpoint found")
 	}
 
-	log.Printf("Using %q as API endpoint", endpoint)
+	log.Printf("Using %q as API endpoints", endpoints)
 
-	conn, err := connect(ctx, endpoint)
+	conn, err := connect(ctx, endpoints)
 	if err != nil {
 		return err
 	}
@@ -260,6 +460,10 @@ func mainFunc() error {
 
 	log.Println("Registration complete")
 
+	if createResp.GetUnexpectedBoot() {
+		return waitBootFromDisk(ctx, client, s)
+	}
+
 	if createResp.GetSetupBmc() {
 		log.Println("Attempting to automatically discover and configure BMC")
 
@@ -291,6 +495,14 @@ func mainFunc() error {
 		log.Printf("Reconciled IPs")
 	}
 
+	// nb: we don't consider failure to report inventory a hard failure,
+	//     inventory is not required to wipe or to provision the server.
+	if err = inventory(ctx, client, s); err != nil {
+		log.Printf("encountered error reporting hardware inventory: %q", err.Error())
+	} else {
+		log.Println("Reported hardware inventory")
+	}
+
 	if createResp.GetWipe() {
 		disks, err := disk.List()
 		if err != nil {
@@ -341,23 +553,84 @@ func mainFunc() error {
 			wg.Wait()
 		}()
 
-		for _, disk := range disks {
-			func(path string) {
+		wipeStart := time.Now()
+
+		policy := wipePolicy(createResp.GetWipePolicy())
+
+		var (
+			skipped   []*api.SkippedDisk
+			wiped     []*api.WipedDisk
+			wipedMu   sync.Mutex
+			toWipe    []*disk.Disk
+			devices   = map[*disk.Disk]*blockdevice.BlockDevice{}
+			dataDisks []*api.DataDisk
+		)
+
+		for _, d := range disks {
+			bd, err := blockdevice.Open(d.DeviceName)
+			if err != nil {
+				log.Printf("Skipping %s: %s", d.DeviceName, err)
+
+				skipped = append(skipped, &api.SkippedDisk{DeviceName: d.DeviceName, Reason: err.Error()})
+
+				continue
+			}
+
+			if ok, reason := policy.ShouldWipe(d.DeviceName, d.Model, d.Serial, isSystemDisk(bd)); !ok {
+				log.Printf("Skipping %s: %s", d.DeviceName, reason)
+
+				skipped = append(skipped, &api.SkippedDisk{DeviceName: d.DeviceName, Reason: reason})
+
+				bd.Close() //nolint:errcheck
+
+				continue
+			}
+
+			if createResp.GetWipeConfirmationRequired() {
+				found, err := dataSignatures(bd)
+				if err != nil {
+					shutdown(fmt.Errorf("failed detecting data on %q: %w", d.DeviceName, err))
+				}
+
+				if len(found) > 0 {
+					log.Printf("Existing data found on %s: %v", d.DeviceName, found)
+
+					dataDisks = append(dataDisks, &api.DataDisk{DeviceName: d.DeviceName, Signatures: found})
+				}
+			}
+
+			toWipe = append(toWipe, d)
+			devices[d] = bd
+		}
+
+		if len(dataDisks) > 0 {
+			if err := confirmWipe(ctx, client, s, dataDisks); err != nil {
+				shutdown(err)
+			}
+		}
+
+		for _, d := range toWipe {
+			func(d *disk.Disk, bd *blockdevice.BlockDevice) {
+				path := d.DeviceName
+
 				eg.Go(func() error {
 					log.Printf("Resetting %s", path)
 
-					bd, err := blockdevice.Open(path)
-					if err != nil {
-						log.Printf("Skipping %s: %s", path, err)
-
-						return nil
+					w := &api.WipedDisk{
+						DeviceName: path,
+						Model:      d.Model,
+						Serial:     d.Serial,
+						Size:       d.Size,
+						StartedAt:  time.Now().Unix(),
 					}
 
 					if createResp.GetInsecureWipe() {
-						if err = bd.FastWipe(); err != nil {
+						if err := bd.FastWipe(); err != nil {
 							return fmt.Errorf("failed wiping %q: %w", path, err)
 						}
 
+						w.Method = "fast"
+
 						log.Printf("Fast wiped %s", path)
 					} else {
 						method, err := bd.Wipe()
@@ -365,19 +638,36 @@ func mainFunc() error {
 							return fmt.Errorf("failed wiping %q: %w", path, err)
 						}
 
+						w.Method = method
+
 						log.Printf("Wiped %s with %s", path, method)
 					}
 
+					w.CompletedAt = time.Now().Unix()
+
+					verified, err := verifyWipe(bd)
+					if err != nil {
+						log.Printf("Failed to verify wipe of %s: %s", path, err)
+					} else if !verified {
+						log.Printf("Wipe of %s not verified: disk head is not zeroed", path)
+					}
+
+					w.Verified = verified
+
+					wipedMu.Lock()
+					wiped = append(wiped, w)
+					wipedMu.Unlock()
+
 					return bd.Close()
 				})
-			}(disk.DeviceName)
+			}(d, devices[d])
 		}
 
 		if err := eg.Wait(); err != nil {
 			shutdown(err)
 		}
 
-		if err := wipe(ctx, client, s); err != nil {
+		if err := wipe(ctx, client, s, time.Since(wipeStart), wiped, skipped); err != nil {
 			shutdown(err)
 		}
 
@@ -387,6 +677,43 @@ func mainFunc() error {
 	return nil
 }
 
+// waitBootFromDisk keeps the allocated server which booted into the agent untouched until the boot from disk is requested.
+func waitBootFromDisk(ctx context.Context, client api.AgentClient, s *smbios.SMBIOS) error {
+	uuid, err := s.SystemInformation().UUID()
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Server is allocated, but booted into the agent: disks are not wiped, waiting for the boot from disk to be requested (annotate the server with %q)",
+		v1alpha1.ServerBootFromDiskAnnotation)
+
+	ticker := time.NewTicker(bootFromDiskPollInterval)
+	defer ticker.Stop()
+
+	for {
+		callCtx, cancel := context.WithTimeout(ctx, bootFromDiskPollInterval)
+
+		resp, err := client.Heartbeat(callCtx, &api.HeartbeatRequest{Uuid: uuid.String()})
+
+		cancel()
+
+		switch {
+		case err != nil:
+			log.Printf("Failed to send heartbeat %s", err)
+		case resp.GetBootFromDisk():
+			log.Println("Boot from disk requested")
+
+			return nil
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
 func main() {
 	shutdown(mainFunc())
 }
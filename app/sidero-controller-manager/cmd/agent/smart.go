@@ -0,0 +1,191 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"unsafe"
+
+	"github.com/talos-systems/go-blockdevice/blockdevice/util/disk"
+	"golang.org/x/sys/unix"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+const (
+	// _IOWR('N', 0x41, struct nvme_admin_cmd).
+	nvmeIoctlAdminCmd   = 0xc0484e41
+	nvmeAdminGetLogPage = 0x02
+	nvmeLogSMART        = 0x02
+	nvmeLogSMARTSize    = 512
+
+	sgIO          = 0x2285
+	sgDxferNone   = -1
+	sgInterfaceID = 'S'
+
+	ataPassThrough16 = 0x85
+	ataSMART         = 0xb0
+	ataSMARTStatus   = 0xda
+)
+
+// nvmeAdminCmd is struct nvme_admin_cmd from linux/nvme_ioctl.h.
+type nvmeAdminCmd struct {
+	opcode      uint8
+	flags       uint8
+	rsvd1       uint16
+	nsid        uint32
+	cdw2        uint32
+	cdw3        uint32
+	metadata    uint64
+	addr        uint64
+	metadataLen uint32
+	dataLen     uint32
+	cdw10       uint32
+	cdw11       uint32
+	cdw12       uint32
+	cdw13       uint32
+	cdw14       uint32
+	cdw15       uint32
+	timeoutMs   uint32
+	result      uint32
+}
+
+// sgIOHdr is struct sg_io_hdr from scsi/sg.h.
+type sgIOHdr struct {
+	interfaceID    int32
+	dxferDirection int32
+	cmdLen         uint8
+	mxSbLen        uint8
+	iovecCount     uint16
+	dxferLen       uint32
+	dxferp         uintptr
+	cmdp           uintptr
+	sbp            uintptr
+	timeout        uint32
+	flags          uint32
+	packID         int32
+	usrPtr         uintptr
+	status         uint8
+	maskedStatus   uint8
+	msgStatus      uint8
+	sbLenWr        uint8
+	hostStatus     uint16
+	driverStatus   uint16
+	resid          int32
+	duration       uint32
+	info           uint32
+}
+
+// smartHealth returns the overall SMART health of the disk.
+//
+// Empty string is returned if the disk doesn't support SMART (e.g. SD cards, virtual disks).
+func smartHealth(path string, typ disk.Type) (string, error) {
+	if typ == disk.TypeNVMe {
+		return nvmeHealth(path)
+	}
+
+	if typ == disk.TypeHDD || typ == disk.TypeSSD {
+		return ataHealth(path)
+	}
+
+	return "", nil
+}
+
+// nvmeHealth reads SMART / Health Information log page, any critical warning means the disk is failing.
+func nvmeHealth(path string) (string, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	log := make([]byte, nvmeLogSMARTSize)
+
+	cmd := nvmeAdminCmd{
+		opcode:  nvmeAdminGetLogPage,
+		nsid:    0xffffffff,
+		addr:    uint64(uintptr(unsafe.Pointer(&log[0]))),
+		dataLen: nvmeLogSMARTSize,
+		// number of dwords (zero based) and log page ID
+		cdw10: (nvmeLogSMARTSize/4-1)<<16 | nvmeLogSMART,
+	}
+
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), nvmeIoctlAdminCmd, uintptr(unsafe.Pointer(&cmd)))
+
+	// the buffer is referenced only via uintptr in the command
+	runtime.KeepAlive(log)
+
+	if errno != 0 {
+		return "", errno
+	}
+
+	if log[0] != 0 {
+		return v1alpha1.SMARTHealthFailed, nil
+	}
+
+	return v1alpha1.SMARTHealthPassed, nil
+}
+
+// ataHealth issues SMART RETURN STATUS via ATA PASS-THROUGH (16).
+//
+// SCSI disks and USB bridges which don't support ATA pass-through return empty health.
+func ataHealth(path string) (string, error) {
+	f, err := os.OpenFile(path, os.O_RDONLY, 0)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	cdb := []byte{
+		0:  ataPassThrough16,
+		1:  3 << 1, // non-data protocol
+		2:  1 << 5, // CK_COND: return the ATA registers in the sense data
+		4:  ataSMARTStatus,
+		10: 0x4f, // LBA mid
+		12: 0xc2, // LBA high
+		14: ataSMART,
+		15: 0, // control
+	}
+
+	sense := make([]byte, 32)
+
+	hdr := sgIOHdr{
+		interfaceID:    sgInterfaceID,
+		dxferDirection: sgDxferNone,
+		cmdLen:         uint8(len(cdb)),
+		mxSbLen:        uint8(len(sense)),
+		cmdp:           uintptr(unsafe.Pointer(&cdb[0])),
+		sbp:            uintptr(unsafe.Pointer(&sense[0])),
+		timeout:        10000,
+	}
+
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, f.Fd(), sgIO, uintptr(unsafe.Pointer(&hdr)))
+
+	// the buffers are referenced only via uintptr in the header
+	runtime.KeepAlive(cdb)
+	runtime.KeepAlive(sense)
+
+	if errno != 0 {
+		return "", errno
+	}
+
+	// descriptor format sense data with the ATA Status Return descriptor
+	if hdr.sbLenWr < 22 || sense[0]&0x7f != 0x72 || sense[8] != 0x09 {
+		return "", nil
+	}
+
+	switch lbaMid, lbaHigh := sense[17], sense[19]; {
+	case lbaMid == 0x4f && lbaHigh == 0xc2:
+		return v1alpha1.SMARTHealthPassed, nil
+	case lbaMid == 0xf4 && lbaHigh == 0x2c:
+		return v1alpha1.SMARTHealthFailed, nil
+	default:
+		return "", fmt.Errorf("unexpected SMART status %#x/%#x", lbaMid, lbaHigh)
+	}
+}
@@ -0,0 +1,140 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/backup"
+	"github.com/talos-systems/sidero/internal/client"
+)
+
+var backupCmdFlags struct {
+	kubeconfig string
+	output     string
+}
+
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Export the Sidero state of the management cluster into a bundle.",
+	Long: `Backup exports Sidero and CAPS resources with their status, Secrets and ConfigMaps
+referenced by them, wipe certificates and the checksums of the downloaded environment assets
+into a gzipped tarball, which can be restored on a new management cluster with 'sidero restore'.
+
+The bundle contains secrets (e.g. BMC credentials), keep it safe.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		c, err := client.NewClient(&backupCmdFlags.kubeconfig)
+		if err != nil {
+			return err
+		}
+
+		bundle, err := backup.Export(context.Background(), c)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.OpenFile(backupCmdFlags.output, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+
+		if err = bundle.Write(f); err != nil {
+			f.Close() //nolint:errcheck
+
+			return err
+		}
+
+		if err = f.Close(); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "exported %d objects to %s\n", len(bundle.Objects), backupCmdFlags.output)
+
+		return nil
+	},
+}
+
+var restoreCmdFlags struct {
+	kubeconfig string
+	dryRun     bool
+	pinAssets  bool
+	keepPaused bool
+}
+
+var restoreCmd = &cobra.Command{
+	Use:   "restore [bundle]",
+	Short: "Restore the Sidero state exported with 'sidero backup' on the management cluster.",
+	Long: `Restore creates the objects from the bundle, existing objects are left untouched.
+
+Servers are restored paused, so that Sidero doesn't reset them before their bindings and status
+are restored, and they are unpaused once the restore is done (unless --keep-paused is set).
+Owner references are re-linked to the restored objects.
+
+Cluster API objects (Clusters, Machines, etc.) are not part of the bundle and should be restored
+with the rest of the workload cluster resources.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+
+		defer f.Close() //nolint:errcheck
+
+		bundle, err := backup.Read(f)
+		if err != nil {
+			return fmt.Errorf("error reading bundle: %w", err)
+		}
+
+		c, err := client.NewClient(&restoreCmdFlags.kubeconfig)
+		if err != nil {
+			return err
+		}
+
+		results, err := backup.Restore(context.Background(), c, bundle, backup.RestoreOptions{
+			DryRun:     restoreCmdFlags.dryRun,
+			PinAssets:  restoreCmdFlags.pinAssets,
+			KeepPaused: restoreCmdFlags.keepPaused,
+		})
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+		fmt.Fprintln(w, "KIND\tNAMESPACE\tNAME\tACTION\tMESSAGE")
+
+		for _, result := range results {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", result.Kind, result.Namespace, result.Name, result.Action, result.Message)
+		}
+
+		if flushErr := w.Flush(); flushErr != nil && err == nil {
+			err = flushErr
+		}
+
+		return err
+	},
+}
+
+func init() {
+	kubeconfig := os.Getenv(clientcmd.RecommendedConfigPathEnvVar)
+	if kubeconfig == "" {
+		kubeconfig = clientcmd.RecommendedHomeFile
+	}
+
+	backupCmd.Flags().StringVar(&backupCmdFlags.kubeconfig, "kubeconfig", kubeconfig, "Kubeconfig of the management cluster.")
+	backupCmd.Flags().StringVarP(&backupCmdFlags.output, "output", "o", "sidero-backup.tar.gz", "Path to write the bundle to.")
+
+	restoreCmd.Flags().StringVar(&restoreCmdFlags.kubeconfig, "kubeconfig", kubeconfig, "Kubeconfig of the management cluster.")
+	restoreCmd.Flags().BoolVar(&restoreCmdFlags.dryRun, "dry-run", false, "Print the actions without changing the cluster.")
+	restoreCmd.Flags().BoolVar(&restoreCmdFlags.pinAssets, "pin-assets", false, "Pin the checksums of the environment assets recorded in the bundle.")
+	restoreCmd.Flags().BoolVar(&restoreCmdFlags.keepPaused, "keep-paused", false, "Leave the restored servers paused.")
+
+	rootCmd.AddCommand(backupCmd, restoreCmd)
+}
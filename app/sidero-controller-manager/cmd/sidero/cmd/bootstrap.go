@@ -0,0 +1,138 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/bootstrap"
+)
+
+var bootstrapCmdFlags struct {
+	mode        string
+	name        string
+	apiEndpoint string
+	node        string
+	installDisk string
+	workDir     string
+
+	toKubeconfig string
+
+	dryRun       bool
+	retryTimeout time.Duration
+}
+
+var bootstrapCmd = &cobra.Command{
+	Use:   "bootstrap",
+	Short: "Stand up the initial Sidero management plane and pivot it onto the provisioned hardware.",
+	Long: `Bootstrap runs talosctl, kubectl and clusterctl to create a single node bootstrap cluster with Sidero,
+either in Docker on the local machine (--mode docker) or on a bare metal machine booted into Talos maintenance mode
+(--mode talos).
+
+Once the management cluster is provisioned by the bootstrap cluster, "bootstrap pivot" moves Cluster API and
+Sidero resources to it, and "bootstrap destroy" tears down the bootstrap cluster.
+
+Use --dry-run to print the commands without running them.`,
+}
+
+var bootstrapCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create the bootstrap cluster with Sidero installed.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		steps, err := bootstrap.CreatePlan(bootstrapOptions())
+		if err != nil {
+			return err
+		}
+
+		return runSteps(steps)
+	},
+}
+
+var bootstrapPivotCmd = &cobra.Command{
+	Use:   "pivot",
+	Short: "Move Cluster API and Sidero resources from the bootstrap cluster to the management cluster.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := bootstrapOptions()
+
+		steps, err := bootstrap.PivotPlan(bootstrap.PivotOptions{
+			Kubeconfig:   opts.Kubeconfig(),
+			ToKubeconfig: bootstrapCmdFlags.toKubeconfig,
+			APIEndpoint:  bootstrapCmdFlags.apiEndpoint,
+		})
+		if err != nil {
+			return err
+		}
+
+		return runSteps(steps)
+	},
+}
+
+var bootstrapDestroyCmd = &cobra.Command{
+	Use:   "destroy",
+	Short: "Tear down the bootstrap cluster.",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		steps, err := bootstrap.DestroyPlan(bootstrapOptions())
+		if err != nil {
+			return err
+		}
+
+		return runSteps(steps)
+	},
+}
+
+func bootstrapOptions() bootstrap.Options {
+	return bootstrap.Options{
+		Mode:        bootstrap.Mode(bootstrapCmdFlags.mode),
+		Name:        bootstrapCmdFlags.name,
+		APIEndpoint: bootstrapCmdFlags.apiEndpoint,
+		Node:        bootstrapCmdFlags.node,
+		InstallDisk: bootstrapCmdFlags.installDisk,
+		WorkDir:     bootstrapCmdFlags.workDir,
+	}
+}
+
+func runSteps(steps []bootstrap.Step) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	if !bootstrapCmdFlags.dryRun {
+		if err := os.MkdirAll(bootstrapCmdFlags.workDir, 0o700); err != nil {
+			return err
+		}
+	}
+
+	runner := bootstrap.Runner{
+		Out:           os.Stdout,
+		DryRun:        bootstrapCmdFlags.dryRun,
+		RetryTimeout:  bootstrapCmdFlags.retryTimeout,
+		RetryInterval: 10 * time.Second,
+	}
+
+	return runner.Run(ctx, steps)
+}
+
+func init() {
+	bootstrapCmd.PersistentFlags().StringVar(&bootstrapCmdFlags.mode, "mode", string(bootstrap.ModeDocker), "Bootstrap cluster mode: docker or talos.")
+	bootstrapCmd.PersistentFlags().StringVar(&bootstrapCmdFlags.name, "name", "sidero-bootstrap", "Bootstrap cluster name.")
+	bootstrapCmd.PersistentFlags().StringVar(&bootstrapCmdFlags.apiEndpoint, "api-endpoint", "", "IP address or hostname the servers reach Sidero at (defaults to --node in talos mode).")
+	bootstrapCmd.PersistentFlags().StringVar(&bootstrapCmdFlags.node, "node", "", "Address of the machine in Talos maintenance mode (talos mode).")
+	bootstrapCmd.PersistentFlags().StringVar(&bootstrapCmdFlags.installDisk, "install-disk", "", "Disk to install Talos to (talos mode).")
+	bootstrapCmd.PersistentFlags().StringVar(&bootstrapCmdFlags.workDir, "work-dir", "_out/bootstrap", "Directory to keep generated configs, talosconfig and kubeconfig in.")
+	bootstrapCmd.PersistentFlags().BoolVar(&bootstrapCmdFlags.dryRun, "dry-run", false, "Print the commands without running them.")
+	bootstrapCmd.PersistentFlags().DurationVar(&bootstrapCmdFlags.retryTimeout, "retry-timeout", 10*time.Minute, "How long to retry the steps waiting for the cluster to come up.")
+
+	bootstrapPivotCmd.Flags().StringVar(&bootstrapCmdFlags.toKubeconfig, "to-kubeconfig", "", "Kubeconfig of the management cluster.")
+
+	bootstrapCmd.AddCommand(bootstrapCreateCmd, bootstrapPivotCmd, bootstrapDestroyCmd)
+	rootCmd.AddCommand(bootstrapCmd)
+}
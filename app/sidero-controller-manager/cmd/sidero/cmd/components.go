@@ -0,0 +1,78 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/components"
+)
+
+var componentsCmdFlags struct {
+	config     string
+	components string
+	output     string
+}
+
+var componentsCmd = &cobra.Command{
+	Use:   "components",
+	Short: "Generate Sidero provider components for a deployment topology from a config file.",
+	Long: fmt.Sprintf(`Components substitutes the variables of the released infrastructure-components-<topology>.yaml
+(topologies: %v) from the config file, so that the components can be applied without hand-editing.
+
+Topology requirements (e.g. the load balancer address for HA) are validated, unknown variables
+and variables without defaults which are not set are reported as errors.
+
+Config file example:
+
+  topology: ha
+  apiEndpoint: sidero.example.com
+  variables:
+    SIDERO_CONTROLLER_MANAGER_AUTO_ACCEPT_SERVERS: "true"`, components.Topologies),
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if componentsCmdFlags.config == "" {
+			return fmt.Errorf("--config is required")
+		}
+
+		if componentsCmdFlags.components == "" {
+			return fmt.Errorf("--components is required")
+		}
+
+		cfg, err := components.LoadConfig(componentsCmdFlags.config)
+		if err != nil {
+			return err
+		}
+
+		in, err := os.ReadFile(componentsCmdFlags.components)
+		if err != nil {
+			return err
+		}
+
+		out, err := components.Generate(in, cfg)
+		if err != nil {
+			return err
+		}
+
+		if componentsCmdFlags.output == "" {
+			_, err = os.Stdout.Write(out)
+
+			return err
+		}
+
+		return os.WriteFile(componentsCmdFlags.output, out, 0o644)
+	},
+}
+
+func init() {
+	componentsCmd.Flags().StringVar(&componentsCmdFlags.config, "config", "", "Config file with the topology and the variables.")
+	componentsCmd.Flags().StringVar(&componentsCmdFlags.components, "components", "", "Released infrastructure-components-<topology>.yaml of the topology.")
+	componentsCmd.Flags().StringVarP(&componentsCmdFlags.output, "output", "o", "", "File to write the components to, stdout if empty.")
+
+	rootCmd.AddCommand(componentsCmd)
+}
@@ -0,0 +1,203 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/hwdiff"
+	"github.com/talos-systems/sidero/internal/client"
+)
+
+var hardwareDiffCmdFlags struct {
+	kubeconfig      string
+	namespace       string
+	includeIdentity bool
+	list            bool
+	exitCode        bool
+	output          string
+}
+
+var hardwareDiffCmd = &cobra.Command{
+	Use:   "hardware-diff <server>[@<revision>] [<server>[@<revision>]]",
+	Short: "Compare the hardware of two servers, or of a server across the recorded revisions.",
+	Long: `Hardware diff compares the normalized hardware inventories: components are matched by the slot
+(PCI address, disk device name), and the serial numbers and MAC addresses are skipped unless
+--include-identity is set, so that the servers of the same spec only differ in the hardware.
+
+Sidero records a hardware revision every time the inventory reported by the agent changes.
+Use <server>@<revision> to compare with the recorded revision (see --list), e.g. to verify that
+the RMA replacement matches the original spec:
+
+  sidero hardware-diff <server>@1 <server>
+
+If a single server is given, the previous revision is compared with the current hardware.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch hardwareDiffCmdFlags.output {
+		case "text", "json":
+		default:
+			return fmt.Errorf("unsupported output format %q", hardwareDiffCmdFlags.output)
+		}
+
+		sources := make([]hwdiff.Source, 0, len(args))
+
+		for _, arg := range args {
+			src, err := hwdiff.ParseSource(arg)
+			if err != nil {
+				return err
+			}
+
+			sources = append(sources, src)
+		}
+
+		ctx := context.Background()
+
+		c, err := client.NewClient(&hardwareDiffCmdFlags.kubeconfig)
+		if err != nil {
+			return err
+		}
+
+		if hardwareDiffCmdFlags.list {
+			if len(sources) != 1 || sources[0].Revision != 0 {
+				return fmt.Errorf("--list expects a single server name")
+			}
+
+			revisions, err := hwdiff.List(ctx, c, hardwareDiffCmdFlags.namespace, sources[0].Server)
+			if err != nil {
+				return err
+			}
+
+			return printHardwareRevisions(os.Stdout, revisions)
+		}
+
+		if len(sources) == 1 {
+			if sources[0].Revision != 0 {
+				return fmt.Errorf("a single server is compared with its previous revision, the revision can't be set")
+			}
+
+			revisions, err := hwdiff.List(ctx, c, hardwareDiffCmdFlags.namespace, sources[0].Server)
+			if err != nil {
+				return err
+			}
+
+			// the last revision is the current hardware
+			if len(revisions) < 2 {
+				return fmt.Errorf("server %q has no previous hardware revisions", sources[0].Server)
+			}
+
+			sources = []hwdiff.Source{{Server: sources[0].Server, Revision: revisions[len(revisions)-2].Revision}, sources[0]}
+		}
+
+		opts := hwdiff.Options{IncludeIdentity: hardwareDiffCmdFlags.includeIdentity}
+
+		properties := make([]hwdiff.Properties, len(sources))
+
+		for i, src := range sources {
+			hw, err := hwdiff.Load(ctx, c, hardwareDiffCmdFlags.namespace, src)
+			if err != nil {
+				return err
+			}
+
+			properties[i] = hwdiff.Normalize(hw, opts)
+		}
+
+		changes := hwdiff.Diff(properties[0], properties[1])
+
+		if hardwareDiffCmdFlags.output == "json" {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+
+			if err = enc.Encode(struct {
+				A       string          `json:"a"`
+				B       string          `json:"b"`
+				Changes []hwdiff.Change `json:"changes"`
+			}{sources[0].String(), sources[1].String(), changes}); err != nil {
+				return err
+			}
+		} else if err = printHardwareDiff(os.Stdout, sources[0], sources[1], changes); err != nil {
+			return err
+		}
+
+		if hardwareDiffCmdFlags.exitCode && len(changes) > 0 {
+			return fmt.Errorf("%d hardware properties differ", len(changes))
+		}
+
+		return nil
+	},
+}
+
+func printHardwareDiff(out io.Writer, a, b hwdiff.Source, changes []hwdiff.Change) error {
+	if len(changes) == 0 {
+		_, err := fmt.Fprintf(out, "no differences between %s and %s\n", a, b)
+
+		return err
+	}
+
+	value := func(v string) string {
+		if v == "" {
+			return "-"
+		}
+
+		return v
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "PROPERTY\t%s\t%s\n", a, b)
+
+	for _, change := range changes {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", change.Path, value(change.A), value(change.B))
+	}
+
+	return w.Flush()
+}
+
+func printHardwareRevisions(out io.Writer, revisions []hwdiff.Revision) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "REVISION\tRECORDED\tCHANGES")
+
+	for i, revision := range revisions {
+		changes := "-"
+
+		if i > 0 {
+			changes = fmt.Sprintf("%d", len(hwdiff.Diff(
+				hwdiff.Normalize(revisions[i-1].Hardware, hwdiff.Options{IncludeIdentity: true}),
+				hwdiff.Normalize(revision.Hardware, hwdiff.Options{IncludeIdentity: true}),
+			)))
+		}
+
+		fmt.Fprintf(w, "%d\t%s\t%s\n", revision.Revision, revision.RecordedAt.UTC().Format(time.RFC3339), changes)
+	}
+
+	return w.Flush()
+}
+
+func init() {
+	kubeconfig := os.Getenv(clientcmd.RecommendedConfigPathEnvVar)
+	if kubeconfig == "" {
+		kubeconfig = clientcmd.RecommendedHomeFile
+	}
+
+	hardwareDiffCmd.Flags().StringVar(&hardwareDiffCmdFlags.kubeconfig, "kubeconfig", kubeconfig, "Kubeconfig of the management cluster.")
+	hardwareDiffCmd.Flags().StringVar(&hardwareDiffCmdFlags.namespace, "namespace", constants.SideroNamespace, "Namespace the hardware revisions are stored in.")
+	hardwareDiffCmd.Flags().BoolVar(&hardwareDiffCmdFlags.includeIdentity, "include-identity", false, "Compare the serial numbers and the MAC addresses as well.")
+	hardwareDiffCmd.Flags().BoolVar(&hardwareDiffCmdFlags.list, "list", false, "List the recorded hardware revisions of the server.")
+	hardwareDiffCmd.Flags().BoolVar(&hardwareDiffCmdFlags.exitCode, "exit-code", false, "Exit with a non-zero code if the hardware differs.")
+	hardwareDiffCmd.Flags().StringVarP(&hardwareDiffCmdFlags.output, "output", "o", "text", "Output format: text or json.")
+
+	rootCmd.AddCommand(hardwareDiffCmd)
+}
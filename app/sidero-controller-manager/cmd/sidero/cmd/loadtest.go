@@ -0,0 +1,154 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/loadtest"
+	"github.com/talos-systems/sidero/internal/client"
+)
+
+var loadTestCmdFlags struct {
+	kubeconfig     string
+	endpoint       string
+	runID          string
+	servers        int
+	concurrency    int
+	stormRequests  int
+	accept         bool
+	cleanup        bool
+	bmcListenAddr  string
+	bmcEndpoint    string
+	bmcLatency     time.Duration
+	bmcLatencyP99  time.Duration
+	bmcFailureRate float64
+	soak           time.Duration
+	maxErrorRate   float64
+	maxP99         string
+	output         string
+}
+
+var loadTestCmd = &cobra.Command{
+	Use:   "load-test",
+	Short: "Simulate a fleet of servers against a Sidero deployment and check the performance envelope.",
+	Long: `Simulated servers register via the agent API, then all of them fetch the iPXE script and the metadata at once (boot storm).
+
+With --bmc-listen-addr, the servers are attached to the simulated BMCs (management API) with the log-normal latency
+distribution of the real BMCs; with --accept they are accepted, so that Sidero power manages them during the --soak period.
+The simulated servers are labeled with ` + loadtest.RunLabel + ` and deleted in the end of the run (unless --cleanup=false).
+
+The command fails if the error rate or the p99 latency of any operation exceeds the thresholds.
+Never run the load test with --accept against a management plane with auto-accept or wipe policies which touch the real servers.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if loadTestCmdFlags.endpoint == "" {
+			return fmt.Errorf("--endpoint is required")
+		}
+
+		switch loadTestCmdFlags.output {
+		case "text", "json":
+		default:
+			return fmt.Errorf("unsupported output format %q", loadTestCmdFlags.output)
+		}
+
+		maxP99, err := loadtest.ParseLatencyThresholds(loadTestCmdFlags.maxP99)
+		if err != nil {
+			return err
+		}
+
+		opts := loadtest.Options{
+			Endpoint:       loadTestCmdFlags.endpoint,
+			RunID:          loadTestCmdFlags.runID,
+			Servers:        loadTestCmdFlags.servers,
+			Concurrency:    loadTestCmdFlags.concurrency,
+			StormRequests:  loadTestCmdFlags.stormRequests,
+			Accept:         loadTestCmdFlags.accept,
+			Cleanup:        loadTestCmdFlags.cleanup,
+			BMCListenAddr:  loadTestCmdFlags.bmcListenAddr,
+			BMCEndpoint:    loadTestCmdFlags.bmcEndpoint,
+			BMCLatency:     loadtest.Latency{Median: loadTestCmdFlags.bmcLatency, P99: loadTestCmdFlags.bmcLatencyP99},
+			BMCFailureRate: loadTestCmdFlags.bmcFailureRate,
+			Soak:           loadTestCmdFlags.soak,
+			Thresholds: loadtest.Thresholds{
+				MaxErrorRate: loadTestCmdFlags.maxErrorRate,
+				MaxP99:       maxP99,
+			},
+		}
+
+		if loadTestCmdFlags.kubeconfig != "" {
+			if opts.Client, err = client.NewClient(&loadTestCmdFlags.kubeconfig); err != nil {
+				return err
+			}
+		}
+
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+
+		report, err := loadtest.Run(ctx, opts, os.Stderr)
+		if err != nil {
+			return err
+		}
+
+		if loadTestCmdFlags.output == "json" {
+			if err = json.NewEncoder(os.Stdout).Encode(report); err != nil {
+				return err
+			}
+		} else if err = report.Print(os.Stdout); err != nil {
+			return err
+		}
+
+		if !report.Passed() {
+			return fmt.Errorf("load test failed: %d thresholds exceeded", len(report.Violations))
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	kubeconfig := os.Getenv(clientcmd.RecommendedConfigPathEnvVar)
+	if kubeconfig == "" {
+		kubeconfig = clientcmd.RecommendedHomeFile
+	}
+
+	var defaultMaxP99 string
+
+	for _, operation := range []string{loadtest.OperationRegistration, loadtest.OperationIPXE, loadtest.OperationMetadata} {
+		if defaultMaxP99 != "" {
+			defaultMaxP99 += ","
+		}
+
+		defaultMaxP99 += fmt.Sprintf("%s=%s", operation, loadtest.DefaultThresholds.MaxP99[operation])
+	}
+
+	loadTestCmd.Flags().StringVar(&loadTestCmdFlags.kubeconfig, "kubeconfig", kubeconfig, "Kubeconfig of the management cluster, the simulated servers are not configured and cleaned up if empty.")
+	loadTestCmd.Flags().StringVar(&loadTestCmdFlags.endpoint, "endpoint", "", "Sidero API endpoint (host:port) the servers are booted from.")
+	loadTestCmd.Flags().StringVar(&loadTestCmdFlags.runID, "run-id", "", "ID of the run the server UUIDs are derived from, random if empty.")
+	loadTestCmd.Flags().IntVar(&loadTestCmdFlags.servers, "servers", 1000, "Number of the simulated servers.")
+	loadTestCmd.Flags().IntVar(&loadTestCmdFlags.concurrency, "concurrency", 100, "Number of the requests in flight.")
+	loadTestCmd.Flags().IntVar(&loadTestCmdFlags.stormRequests, "storm-requests", 3, "Number of the iPXE and metadata requests each server sends in the boot storm.")
+	loadTestCmd.Flags().BoolVar(&loadTestCmdFlags.accept, "accept", false, "Accept the simulated servers, so that Sidero power manages them.")
+	loadTestCmd.Flags().BoolVar(&loadTestCmdFlags.cleanup, "cleanup", true, "Delete the simulated servers in the end of the run.")
+	loadTestCmd.Flags().StringVar(&loadTestCmdFlags.bmcListenAddr, "bmc-listen-addr", "", "Address the simulated BMCs listen on, BMCs are not simulated if empty.")
+	loadTestCmd.Flags().StringVar(&loadTestCmdFlags.bmcEndpoint, "bmc-endpoint", "", "Address (host:port) the simulated BMCs are reachable at from Sidero, defaults to --bmc-listen-addr.")
+	loadTestCmd.Flags().DurationVar(&loadTestCmdFlags.bmcLatency, "bmc-latency", 200*time.Millisecond, "Median latency of the simulated BMCs.")
+	loadTestCmd.Flags().DurationVar(&loadTestCmdFlags.bmcLatencyP99, "bmc-latency-p99", 3*time.Second, "99th percentile latency of the simulated BMCs.")
+	loadTestCmd.Flags().Float64Var(&loadTestCmdFlags.bmcFailureRate, "bmc-failure-rate", 0.01, "Fraction of the simulated BMC requests which fail.")
+	loadTestCmd.Flags().DurationVar(&loadTestCmdFlags.soak, "soak", 0, "How long the simulated BMCs are served after the boot storm.")
+	loadTestCmd.Flags().Float64Var(&loadTestCmdFlags.maxErrorRate, "max-error-rate", loadtest.DefaultThresholds.MaxErrorRate, "Maximum fraction of the failed requests of any operation.")
+	loadTestCmd.Flags().StringVar(&loadTestCmdFlags.maxP99, "max-p99", defaultMaxP99, "A comma delimited list of operation=duration maximum p99 latencies (operations: registration, ipxe, metadata, bmc).")
+	loadTestCmd.Flags().StringVarP(&loadTestCmdFlags.output, "output", "o", "text", "Output format: text or json.")
+
+	rootCmd.AddCommand(loadTestCmd)
+}
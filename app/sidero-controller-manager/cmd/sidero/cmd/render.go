@@ -0,0 +1,128 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/spf13/cobra"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/render"
+)
+
+var renderCmdFlags struct {
+	server        string
+	serverClass   string
+	serverBinding string
+	metalMachine  string
+	environment   string
+	sources       string
+	config        string
+	caseDir       string
+	expected      string
+}
+
+var renderCmd = &cobra.Command{
+	Use:   "render",
+	Short: "Render boot environment and machine configuration for a server.",
+	Long: `Render resolves the environment and applies Environment, ServerClass, Server and ServerBinding
+config patches to the bootstrap machine configuration exactly like the Sidero metadata server does.
+
+ConfigMaps and Secrets referenced with configPatchesFrom are read from the --sources file.
+
+The output includes the effective kernel command line the server is booted with,
+problems with the environment kernel args are reported as warnings.
+
+Use --expected (or --case with a directory containing expected.yaml) to compare
+the result with the golden file and catch regressions before rolling out the changes.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var (
+			in           render.Input
+			err          error
+			expectedFile = renderCmdFlags.expected
+		)
+
+		if renderCmdFlags.caseDir != "" {
+			in, err = render.LoadCase(renderCmdFlags.caseDir)
+
+			if expectedFile == "" {
+				expectedFile = filepath.Join(renderCmdFlags.caseDir, render.ExpectedFile)
+			}
+		} else {
+			in, err = render.LoadInput(render.Files{
+				Server:        renderCmdFlags.server,
+				ServerClass:   renderCmdFlags.serverClass,
+				ServerBinding: renderCmdFlags.serverBinding,
+				MetalMachine:  renderCmdFlags.metalMachine,
+				Environment:   renderCmdFlags.environment,
+				Sources:       renderCmdFlags.sources,
+				Config:        renderCmdFlags.config,
+			})
+		}
+
+		if err != nil {
+			return err
+		}
+
+		out, err := render.Render(in)
+		if err != nil {
+			return err
+		}
+
+		actual, err := out.Bytes()
+		if err != nil {
+			return err
+		}
+
+		if expectedFile == "" {
+			_, err = os.Stdout.Write(actual)
+
+			return err
+		}
+
+		expected, err := os.ReadFile(expectedFile)
+		if err != nil {
+			return err
+		}
+
+		if bytes.Equal(expected, actual) {
+			return nil
+		}
+
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(expected)),
+			B:        difflib.SplitLines(string(actual)),
+			FromFile: expectedFile,
+			ToFile:   "rendered",
+			Context:  3,
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Print(diff)
+
+		return fmt.Errorf("rendered output doesn't match %q", expectedFile)
+	},
+}
+
+func init() {
+	renderCmd.Flags().StringVar(&renderCmdFlags.server, "server", "", "Server manifest.")
+	renderCmd.Flags().StringVar(&renderCmdFlags.serverClass, "class", "", "ServerClass manifest the server is allocated from.")
+	renderCmd.Flags().StringVar(&renderCmdFlags.serverBinding, "binding", "", "ServerBinding manifest of the allocated server.")
+	renderCmd.Flags().StringVar(&renderCmdFlags.metalMachine, "metalmachine", "", "MetalMachine manifest with the static addresses in the status.")
+	renderCmd.Flags().StringVar(&renderCmdFlags.environment, "environment", "", "Environment manifest.")
+	renderCmd.Flags().StringVar(&renderCmdFlags.sources, "sources", "", "Multi-document YAML with ConfigMaps and Secrets referenced by config patches.")
+	renderCmd.Flags().StringVar(&renderCmdFlags.config, "config", "", "Bootstrap machine configuration, if not set only boot environment is rendered.")
+	renderCmd.Flags().StringVar(&renderCmdFlags.caseDir, "case", "", "Directory with the manifests using golden test case layout (server.yaml, serverclass.yaml, serverbinding.yaml, metalmachine.yaml, environment.yaml, sources.yaml, config.yaml, expected.yaml).")
+	renderCmd.Flags().StringVar(&renderCmdFlags.expected, "expected", "", "Compare rendered output with the file instead of printing it.")
+
+	rootCmd.AddCommand(renderCmd)
+}
@@ -0,0 +1,28 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+var rootCmd = &cobra.Command{
+	Use:           "sidero",
+	Short:         "Sidero is a tool to work with Sidero manifests offline, to generate the provider components, to bootstrap the management plane, to fetch wipe certificates, to back up the Sidero state, to compare the server hardware and to load test it.",
+	Long:          ``,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+}
+
+// Execute root command.
+func Execute() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
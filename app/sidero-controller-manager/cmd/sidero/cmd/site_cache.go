@@ -0,0 +1,91 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/sitecache"
+)
+
+var siteCacheCmdFlags struct {
+	listenAddr string
+	dir        string
+	tokenFile  string
+}
+
+var siteCacheCmd = &cobra.Command{
+	Use:   "site-cache",
+	Short: "Run the cache Sidero replicates the Environment assets to at a remote site.",
+	Long: `The site cache receives the kernel and initramfs of the Environments from Sidero, and serves them
+to the servers at the site, so that the servers PXE booting via the DHCP relays don't download the assets over the WAN.
+
+Register the cache with a SiteCache resource in the management cluster. Uploads are resumed after
+the interruptions, and authenticated with the token read from --token-file.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// the cache serves the assets the servers boot, so it never accepts the unauthenticated uploads
+		if siteCacheCmdFlags.tokenFile == "" {
+			return errors.New("--token-file is required")
+		}
+
+		data, err := os.ReadFile(siteCacheCmdFlags.tokenFile)
+		if err != nil {
+			return err
+		}
+
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return fmt.Errorf("token file %q is empty", siteCacheCmdFlags.tokenFile)
+		}
+
+		if err = os.MkdirAll(siteCacheCmdFlags.dir, 0o755); err != nil {
+			return fmt.Errorf("error creating cache directory: %w", err)
+		}
+
+		srv := &http.Server{
+			Addr:    siteCacheCmdFlags.listenAddr,
+			Handler: sitecache.NewServer(siteCacheCmdFlags.dir, token),
+		}
+
+		ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer cancel()
+
+		go func() {
+			<-ctx.Done()
+
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer shutdownCancel()
+
+			srv.Shutdown(shutdownCtx) //nolint:errcheck
+		}()
+
+		log.Printf("serving site cache %q on %s", siteCacheCmdFlags.dir, siteCacheCmdFlags.listenAddr)
+
+		if err = srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+
+		return nil
+	},
+}
+
+func init() {
+	siteCacheCmd.Flags().StringVar(&siteCacheCmdFlags.listenAddr, "listen-addr", ":8081", "Address the cache listens on.")
+	siteCacheCmd.Flags().StringVar(&siteCacheCmdFlags.dir, "dir", "/var/lib/sidero-site-cache", "Directory the assets are stored in.")
+	siteCacheCmd.Flags().StringVar(&siteCacheCmdFlags.tokenFile, "token-file", "", "File with the token the uploads are authenticated with (required).")
+
+	rootCmd.AddCommand(siteCacheCmd)
+}
@@ -0,0 +1,175 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/wipecert"
+	"github.com/talos-systems/sidero/internal/client"
+)
+
+var wipeCertificateCmdFlags struct {
+	kubeconfig string
+	namespace  string
+	publicKey  string
+	output     string
+}
+
+var wipeCertificateCmd = &cobra.Command{
+	Use:   "wipe-certificate [server-uuid]",
+	Short: "Fetch and verify certificates of destruction issued after the servers are wiped.",
+	Long: `Wipe certificates are issued by Sidero every time the agent wipes the server disks,
+and stored as ConfigMaps next to the signing key Secret.
+
+The signature of each certificate is verified before it's printed. Export the public key
+from the sidero-wipe-certificate-key Secret, keep it outside of the cluster and pass it with
+--public-key to verify against a trusted key, otherwise the public key stored with the certificate is used.
+
+Certificates of all the servers are printed if the server UUID is not given.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var uuid string
+
+		if len(args) > 0 {
+			uuid = args[0]
+		}
+
+		var pub ed25519.PublicKey
+
+		if wipeCertificateCmdFlags.publicKey != "" {
+			data, err := os.ReadFile(wipeCertificateCmdFlags.publicKey)
+			if err != nil {
+				return err
+			}
+
+			if pub, err = wipecert.ParsePublicKey(data); err != nil {
+				return fmt.Errorf("error parsing public key: %w", err)
+			}
+		}
+
+		switch wipeCertificateCmdFlags.output {
+		case "text", "json":
+		default:
+			return fmt.Errorf("unsupported output format %q", wipeCertificateCmdFlags.output)
+		}
+
+		c, err := client.NewClient(&wipeCertificateCmdFlags.kubeconfig)
+		if err != nil {
+			return err
+		}
+
+		certificates, err := wipecert.List(context.Background(), c, wipeCertificateCmdFlags.namespace, uuid)
+		if err != nil {
+			return err
+		}
+
+		if len(certificates) == 0 {
+			return fmt.Errorf("no wipe certificates found")
+		}
+
+		for i := range certificates {
+			cm := &certificates[i]
+
+			cert, err := wipecert.VerifyConfigMap(cm, pub)
+			if err != nil {
+				return fmt.Errorf("error verifying wipe certificate %s/%s: %w", cm.Namespace, cm.Name, err)
+			}
+
+			if wipeCertificateCmdFlags.output == "json" {
+				fmt.Fprintln(os.Stdout, cm.Data[wipecert.CertificateKey])
+
+				continue
+			}
+
+			if i > 0 {
+				fmt.Fprintln(os.Stdout)
+			}
+
+			if err = printWipeCertificate(os.Stdout, cm, cert, pub != nil); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+}
+
+func printWipeCertificate(out io.Writer, cm *corev1.ConfigMap, cert *wipecert.Certificate, trusted bool) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	signature := "valid (public key stored with the certificate)"
+	if trusted {
+		signature = "valid (trusted public key)"
+	}
+
+	timestamp := func(t time.Time) string {
+		return t.UTC().Format(time.RFC3339)
+	}
+
+	fmt.Fprintln(w, "CERTIFICATE OF DATA DESTRUCTION")
+	fmt.Fprintf(w, "Certificate:\t%s/%s\n", cm.Namespace, cm.Name)
+	fmt.Fprintf(w, "Server UUID:\t%s\n", cert.Server.UUID)
+	fmt.Fprintf(w, "Manufacturer:\t%s\n", cert.Server.Manufacturer)
+	fmt.Fprintf(w, "Product Name:\t%s\n", cert.Server.ProductName)
+	fmt.Fprintf(w, "Serial Number:\t%s\n", cert.Server.SerialNumber)
+	fmt.Fprintf(w, "Operator:\t%s\n", cert.Operator)
+	fmt.Fprintf(w, "Started:\t%s\n", timestamp(cert.StartedAt))
+	fmt.Fprintf(w, "Completed:\t%s\n", timestamp(cert.CompletedAt))
+	fmt.Fprintf(w, "Issued:\t%s\n", timestamp(cert.IssuedAt))
+	fmt.Fprintf(w, "All Disks Verified:\t%t\n", cert.Verified())
+	fmt.Fprintf(w, "Signing Key:\t%s\n", cert.KeyID)
+	fmt.Fprintf(w, "Signature:\t%s\n", signature)
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, strings.Join([]string{"DEVICE", "MODEL", "SERIAL", "SIZE", "METHOD", "VERIFIED", "STARTED", "COMPLETED"}, "\t"))
+
+	for _, d := range cert.Disks {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%s\t%t\t%s\t%s\n", d.DeviceName, d.Model, d.Serial, d.Size, d.Method, d.Verified, timestamp(d.StartedAt), timestamp(d.CompletedAt))
+	}
+
+	if len(cert.SkippedDisks) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "SKIPPED DEVICE\tREASON")
+
+		for _, d := range cert.SkippedDisks {
+			fmt.Fprintf(w, "%s\t%s\n", d.DeviceName, d.Reason)
+		}
+	}
+
+	return w.Flush()
+}
+
+func init() {
+	kubeconfig := os.Getenv(clientcmd.RecommendedConfigPathEnvVar)
+	if kubeconfig == "" {
+		kubeconfig = clientcmd.RecommendedHomeFile
+	}
+
+	wipeCertificateCmd.Flags().StringVar(&wipeCertificateCmdFlags.kubeconfig, "kubeconfig", kubeconfig, "Kubeconfig of the management cluster.")
+	wipeCertificateCmd.Flags().StringVar(&wipeCertificateCmdFlags.namespace, "namespace", constants.SideroNamespace, "Namespace the certificates are stored in.")
+	wipeCertificateCmd.Flags().StringVar(&wipeCertificateCmdFlags.publicKey, "public-key", "", "PEM-encoded Ed25519 public key to verify the certificates with.")
+	wipeCertificateCmd.Flags().StringVarP(&wipeCertificateCmdFlags.output, "output", "o", "text", "Output format: text (printable) or json (signed certificate documents).")
+
+	rootCmd.AddCommand(wipeCertificateCmd)
+}
@@ -0,0 +1,600 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/tools/reference"
+	"sigs.k8s.io/cluster-api/util/annotations"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/metrics"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/render"
+)
+
+const (
+	canaryFinalizer = "metal.sidero.dev/canary"
+
+	// canaryDialTimeout is the timeout of the connection to the installed system.
+	canaryDialTimeout = 5 * time.Second
+)
+
+// CanaryReconciler periodically takes an idle server through the provisioning cycle with the Canary resources.
+//
+// The run holds the server, marks it for wipe, allocates it to the canary to install the scratch machine configuration,
+// verifies the installed system accepts the connections, and releases the server to be wiped again.
+// Powering on, PXE booting and wiping are done by the ServerReconciler as for any other server,
+// so the run goes through the same provisioning path as the allocated servers.
+type CanaryReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// RefNamespaces restricts the namespaces the configuration can be referenced from.
+	RefNamespaces render.RefNamespaces
+	// DryRun reports the server the run would be started on instead of starting it.
+	DryRun bool
+}
+
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=canaries,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=canaries/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=servers,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=servers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=freezes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=serverbindings,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=get;list;create;patch
+// +kubebuilder:rbac:groups="",resources=configmaps;secrets,verbs=get;list;watch
+
+func (r *CanaryReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, err error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("canary", req.NamespacedName)
+
+	var canary metalv1alpha1.Canary
+
+	if err = r.Get(ctx, req.NamespacedName, &canary); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	patchHelper, err := patch.NewHelper(&canary, r)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	defer func() {
+		if e := patchHelper.Patch(ctx, &canary); e != nil {
+			log.Error(e, "failed to patch canary")
+
+			if err == nil {
+				err = e
+			}
+		}
+	}()
+
+	now := time.Now()
+
+	if !canary.DeletionTimestamp.IsZero() {
+		if canary.Status.Run != nil {
+			if err = r.finish(ctx, log, &canary, now, errors.New("canary deleted")); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		controllerutil.RemoveFinalizer(&canary, canaryFinalizer)
+
+		return ctrl.Result{}, nil
+	}
+
+	controllerutil.AddFinalizer(&canary, canaryFinalizer)
+
+	if canary.Status.Run == nil {
+		return r.start(ctx, log, &canary, now)
+	}
+
+	return r.progress(ctx, log, &canary, now)
+}
+
+// start holds the next idle server and marks it for wipe, if the run is due.
+func (r *CanaryReconciler) start(ctx context.Context, log logr.Logger, canary *metalv1alpha1.Canary, now time.Time) (ctrl.Result, error) {
+	if canary.Spec.Suspend {
+		canary.Status.Message = "Canary is suspended."
+
+		return ctrl.Result{}, nil
+	}
+
+	if next := canary.NextRunTime(); now.Before(next) {
+		canary.Status.Message = ""
+
+		return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+	}
+
+	freeze, err := metalv1alpha1.ActiveFreeze(ctx, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if freeze != nil {
+		canary.Status.Message = fmt.Sprintf("Provisioning is frozen by %q.", freeze.Name)
+
+		return ctrl.Result{RequeueAfter: constants.DefaultRequeueAfter}, nil
+	}
+
+	// the configuration is checked before the server is taken, as the run would only fail once the server is wiped
+	if err = r.checkConfig(ctx, canary.Spec.ConfigFrom); err != nil {
+		canary.Status.Message = fmt.Sprintf("Failed to fetch the configuration: %s.", err)
+
+		return ctrl.Result{RequeueAfter: constants.DefaultRequeueAfter}, nil
+	}
+
+	server, err := r.nextServer(ctx, canary)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if server == nil {
+		canary.Status.Message = "No idle servers matching the selector."
+
+		return ctrl.Result{RequeueAfter: constants.DefaultRequeueAfter}, nil
+	}
+
+	serverRef, err := reference.GetReference(r.Scheme, server)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if r.DryRun {
+		log.Info("dry run: skipping canary run", "server", server.Name)
+		r.Recorder.Event(serverRef, corev1.EventTypeNormal, "Dry Run", fmt.Sprintf("Server would be taken through the provisioning cycle by canary %q.", canary.Name))
+
+		canary.Status.Message = fmt.Sprintf("Dry run: the run would be started on the server %s.", server.Name)
+
+		return ctrl.Result{RequeueAfter: canary.IntervalDuration()}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(server, r)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if server.Annotations == nil {
+		server.Annotations = map[string]string{}
+	}
+
+	server.Annotations[metalv1alpha1.ServerCanaryAnnotation] = canary.Name
+	server.Status.IsClean = false
+
+	if err = patchHelper.Patch(ctx, server); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	log.Info("started canary run", "server", server.Name)
+	r.Recorder.Event(serverRef, corev1.EventTypeNormal, "Server Canary", fmt.Sprintf("Server held by canary %q and marked for wipe.", canary.Name))
+
+	start := metav1.NewTime(now)
+
+	canary.Status.Run = &metalv1alpha1.CanaryRun{
+		ServerRef: server.Name,
+		StartTime: start,
+	}
+	canary.Status.Phase = metalv1alpha1.CanaryPhaseWiping
+	canary.Status.PhaseStartTime = &start
+	canary.Status.ConfigServedTime = nil
+	canary.Status.Message = ""
+
+	return ctrl.Result{RequeueAfter: canary.TimeoutDuration()}, nil
+}
+
+// progress moves the run in progress to the next phase once the server is done with the current one.
+//
+//nolint:gocyclo,cyclop
+func (r *CanaryReconciler) progress(ctx context.Context, log logr.Logger, canary *metalv1alpha1.Canary, now time.Time) (ctrl.Result, error) {
+	run := canary.Status.Run
+
+	var server metalv1alpha1.Server
+
+	if err := r.Get(ctx, types.NamespacedName{Name: run.ServerRef}, &server); err != nil {
+		if apierrors.IsNotFound(err) {
+			return r.finishResult(ctx, log, canary, now, fmt.Errorf("server %q not found", run.ServerRef))
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	if server.CanaryHeld() != canary.Name {
+		return r.finishResult(ctx, log, canary, now, fmt.Errorf("server %q is no longer held by the canary", server.Name))
+	}
+
+	deadline := run.StartTime.Add(canary.TimeoutDuration())
+
+	if !now.Before(deadline) {
+		return r.finishResult(ctx, log, canary, now, fmt.Errorf("run timed out in phase %s", canary.Status.Phase))
+	}
+
+	// servers are watched, the requeue is for the timeout and for the verification
+	result := ctrl.Result{RequeueAfter: deadline.Sub(now)}
+
+	switch canary.Status.Phase {
+	case metalv1alpha1.CanaryPhaseWiping:
+		if server.Status.InUse || !server.Status.IsClean {
+			return result, nil
+		}
+
+		r.completeStep(canary, metalv1alpha1.CanaryStepWipe, now)
+
+		if err := r.patchServer(ctx, &server, func() {
+			server.Annotations[metalv1alpha1.ServerCanaryInstallAnnotation] = ""
+		}); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		r.setPhase(canary, metalv1alpha1.CanaryPhaseInstalling, now)
+	case metalv1alpha1.CanaryPhaseInstalling:
+		if canary.Status.ConfigServedTime == nil {
+			return result, nil
+		}
+
+		served := canary.Status.ConfigServedTime.Time
+
+		r.completeStep(canary, metalv1alpha1.CanaryStepInstall, served)
+		r.setPhase(canary, metalv1alpha1.CanaryPhaseVerifying, served)
+
+		return ctrl.Result{Requeue: true}, nil
+	case metalv1alpha1.CanaryPhaseVerifying:
+		if err := verifyServer(&server, canary.Port()); err != nil {
+			run.Message = err.Error()
+
+			if result.RequeueAfter > constants.DefaultRequeueAfter {
+				result.RequeueAfter = constants.DefaultRequeueAfter
+			}
+
+			return result, nil
+		}
+
+		run.Message = ""
+
+		r.completeStep(canary, metalv1alpha1.CanaryStepVerify, now)
+
+		// the installed data is written by the canary, so the wipe is confirmed
+		if err := r.patchServer(ctx, &server, func() {
+			delete(server.Annotations, metalv1alpha1.ServerCanaryInstallAnnotation)
+			server.Annotations[metalv1alpha1.ServerConfirmWipeAnnotation] = ""
+		}); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		r.setPhase(canary, metalv1alpha1.CanaryPhaseReleasing, now)
+	case metalv1alpha1.CanaryPhaseReleasing:
+		if server.Status.InUse || !server.Status.IsClean {
+			return result, nil
+		}
+
+		r.completeStep(canary, metalv1alpha1.CanaryStepRelease, now)
+
+		return r.finishResult(ctx, log, canary, now, nil)
+	default:
+		return r.finishResult(ctx, log, canary, now, fmt.Errorf("unexpected phase %q", canary.Status.Phase))
+	}
+
+	return result, nil
+}
+
+func (r *CanaryReconciler) setPhase(canary *metalv1alpha1.Canary, phase string, start time.Time) {
+	startTime := metav1.NewTime(start)
+
+	canary.Status.Phase = phase
+	canary.Status.PhaseStartTime = &startTime
+}
+
+// completeStep records the step of the current phase as completed at the time.
+func (r *CanaryReconciler) completeStep(canary *metalv1alpha1.Canary, step string, completed time.Time) {
+	start := canary.Status.Run.StartTime.Time
+	if canary.Status.PhaseStartTime != nil {
+		start = canary.Status.PhaseStartTime.Time
+	}
+
+	duration := completed.Sub(start)
+
+	canary.Status.Run.Steps = append(canary.Status.Run.Steps, metalv1alpha1.CanaryStep{
+		Name:     step,
+		Duration: metav1.Duration{Duration: duration},
+	})
+
+	metrics.CanaryStepDuration.WithLabelValues(canary.Name, step).Observe(duration.Seconds())
+}
+
+func (r *CanaryReconciler) finishResult(ctx context.Context, log logr.Logger, canary *metalv1alpha1.Canary, now time.Time, runErr error) (ctrl.Result, error) {
+	if err := r.finish(ctx, log, canary, now, runErr); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: canary.NextRunTime().Sub(now)}, nil
+}
+
+// finish completes the run, the run failed if the error is not nil.
+//
+// The server of the failed run is released right away, and it's wiped by the regular flow.
+func (r *CanaryReconciler) finish(ctx context.Context, log logr.Logger, canary *metalv1alpha1.Canary, now time.Time, runErr error) error {
+	run := canary.Status.Run
+	phase := canary.Status.Phase
+
+	var server metalv1alpha1.Server
+
+	err := r.Get(ctx, types.NamespacedName{Name: run.ServerRef}, &server)
+
+	switch {
+	case apierrors.IsNotFound(err):
+	case err != nil:
+		return err
+	case server.CanaryHeld() == canary.Name:
+		if err = r.patchServer(ctx, &server, func() {
+			delete(server.Annotations, metalv1alpha1.ServerCanaryAnnotation)
+			delete(server.Annotations, metalv1alpha1.ServerCanaryInstallAnnotation)
+
+			// the server might have the scratch configuration installed
+			if runErr != nil && phase != metalv1alpha1.CanaryPhaseWiping {
+				server.Annotations[metalv1alpha1.ServerConfirmWipeAnnotation] = ""
+				server.Status.IsClean = false
+			}
+		}); err != nil {
+			return err
+		}
+	}
+
+	completion := metav1.NewTime(now)
+
+	run.CompletionTime = &completion
+
+	if runErr != nil {
+		run.Result = metalv1alpha1.CanaryResultFailed
+		run.Message = runErr.Error()
+
+		canary.Status.ConsecutiveFailures++
+
+		metrics.CanaryRuns.WithLabelValues(canary.Name, metrics.ResultFailure).Inc()
+
+		log.Info("canary run failed", "server", run.ServerRef, "error", runErr)
+	} else {
+		run.Result = metalv1alpha1.CanaryResultSucceeded
+		run.Message = ""
+
+		canary.Status.ConsecutiveFailures = 0
+		canary.Status.LastSuccessTime = &completion
+
+		metrics.CanaryRuns.WithLabelValues(canary.Name, metrics.ResultSuccess).Inc()
+		metrics.CanaryLastSuccess.WithLabelValues(canary.Name).Set(float64(now.Unix()))
+
+		log.Info("canary run succeeded", "server", run.ServerRef, "duration", now.Sub(run.StartTime.Time))
+	}
+
+	if canaryRef, err := reference.GetReference(r.Scheme, canary); err == nil {
+		if runErr != nil {
+			r.Recorder.Event(canaryRef, corev1.EventTypeWarning, "Canary", fmt.Sprintf("Run on the server %s failed: %s.", run.ServerRef, runErr))
+		} else {
+			r.Recorder.Event(canaryRef, corev1.EventTypeNormal, "Canary", fmt.Sprintf("Run on the server %s succeeded in %s.", run.ServerRef, now.Sub(run.StartTime.Time).Round(time.Second)))
+		}
+	}
+
+	canary.Status.LastRun = run
+	canary.Status.Run = nil
+	canary.Status.Phase = ""
+	canary.Status.PhaseStartTime = nil
+	canary.Status.ConfigServedTime = nil
+
+	return nil
+}
+
+// patchServer patches the server with the changes to the annotations and the status.
+func (r *CanaryReconciler) patchServer(ctx context.Context, server *metalv1alpha1.Server, mutate func()) error {
+	patchHelper, err := patch.NewHelper(server, r)
+	if err != nil {
+		return err
+	}
+
+	if server.Annotations == nil {
+		server.Annotations = map[string]string{}
+	}
+
+	mutate()
+
+	return patchHelper.Patch(ctx, server)
+}
+
+// checkConfig checks the scratch machine configuration can be fetched.
+func (r *CanaryReconciler) checkConfig(ctx context.Context, ref metalv1alpha1.ObjectKeyRef) error {
+	patchesRef := metalv1alpha1.ConfigPatchesRef{
+		Kind:      ref.Kind,
+		Namespace: ref.Namespace,
+		Name:      ref.Name,
+		Key:       ref.Key,
+	}
+
+	if err := r.RefNamespaces.Check(patchesRef); err != nil {
+		return err
+	}
+
+	key := types.NamespacedName{
+		Namespace: render.RefNamespace(patchesRef),
+		Name:      ref.Name,
+	}
+
+	var err error
+
+	switch ref.Kind {
+	case "ConfigMap":
+		var cm corev1.ConfigMap
+
+		if err = r.Get(ctx, key, &cm); err == nil {
+			_, err = render.ConfigMapKey(&cm, ref.Key)
+		}
+	case "Secret":
+		var secret corev1.Secret
+
+		if err = r.Get(ctx, key, &secret); err == nil {
+			_, err = render.SecretKey(&secret, ref.Key)
+		}
+	default:
+		err = fmt.Errorf("unsupported kind %q", ref.Kind)
+	}
+
+	return err
+}
+
+// nextServer picks the idle server matching the selector, the servers are picked in turn by name.
+func (r *CanaryReconciler) nextServer(ctx context.Context, canary *metalv1alpha1.Canary) (*metalv1alpha1.Server, error) {
+	selector := labels.Everything()
+
+	if canary.Spec.ServerSelector != nil {
+		var err error
+
+		if selector, err = metav1.LabelSelectorAsSelector(canary.Spec.ServerSelector); err != nil {
+			return nil, fmt.Errorf("invalid server selector: %w", err)
+		}
+	}
+
+	var servers metalv1alpha1.ServerList
+
+	if err := r.List(ctx, &servers, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, err
+	}
+
+	var serverBindings infrav1.ServerBindingList
+
+	if err := r.List(ctx, &serverBindings); err != nil {
+		return nil, err
+	}
+
+	bound := make(map[string]struct{}, len(serverBindings.Items))
+
+	for _, serverBinding := range serverBindings.Items {
+		bound[serverBinding.Name] = struct{}{}
+	}
+
+	var idle []*metalv1alpha1.Server
+
+	for i := range servers.Items {
+		server := &servers.Items[i]
+
+		if _, ok := bound[server.Name]; ok {
+			continue
+		}
+
+		if canaryIdle(server) {
+			idle = append(idle, server)
+		}
+	}
+
+	if len(idle) == 0 {
+		return nil, nil
+	}
+
+	sort.Slice(idle, func(i, j int) bool { return idle[i].Name < idle[j].Name })
+
+	if canary.Status.LastRun != nil {
+		for _, server := range idle {
+			if server.Name > canary.Status.LastRun.ServerRef {
+				return server, nil
+			}
+		}
+	}
+
+	return idle[0], nil
+}
+
+// canaryIdle returns true if the server can be taken through the provisioning cycle.
+func canaryIdle(server *metalv1alpha1.Server) bool {
+	if !server.Spec.Accepted || !server.Status.IsClean || server.Status.InUse {
+		return false
+	}
+
+	if server.CanaryHeld() != "" || server.ReinventoryRequested() || annotations.HasPausedAnnotation(server) {
+		return false
+	}
+
+	_, powerHold := server.Annotations[metalv1alpha1.ServerPowerHoldAnnotation]
+
+	return !powerHold
+}
+
+// verifyServer checks the installed system accepts the connections on any of the server addresses.
+func verifyServer(server *metalv1alpha1.Server, port int) error {
+	var lastErr error
+
+	for _, address := range server.Status.Addresses {
+		if address.Type != corev1.NodeInternalIP && address.Type != corev1.NodeExternalIP {
+			continue
+		}
+
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(address.Address, strconv.Itoa(port)), canaryDialTimeout)
+		if err != nil {
+			lastErr = err
+
+			continue
+		}
+
+		conn.Close() //nolint:errcheck
+
+		return nil
+	}
+
+	if lastErr == nil {
+		return errors.New("server has no addresses")
+	}
+
+	return fmt.Errorf("installed system is not reachable: %w", lastErr)
+}
+
+func (r *CanaryReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	// servers held by the canary run trigger the reconcile of the Canary
+	mapRequests := handler.ToRequestsFunc(
+		func(a handler.MapObject) []reconcile.Request {
+			name := a.Meta.GetAnnotations()[metalv1alpha1.ServerCanaryAnnotation]
+			if name == "" {
+				return nil
+			}
+
+			return []reconcile.Request{
+				{
+					NamespacedName: types.NamespacedName{Name: name},
+				},
+			}
+		},
+	)
+
+	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(options).
+		For(&metalv1alpha1.Canary{}).
+		Watches(
+			&source.Kind{Type: &metalv1alpha1.Server{}},
+			&handler.EnqueueRequestsFromMapFunc{
+				ToRequests: mapRequests,
+			},
+		).
+		Complete(r)
+}
@@ -0,0 +1,355 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/controllers"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/metrics"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/render"
+)
+
+type canaryTest struct {
+	t      *testing.T
+	ctx    context.Context
+	client client.Client
+	r      *controllers.CanaryReconciler
+	name   string
+}
+
+func newCanaryTest(t *testing.T, canary *metalv1alpha1.Canary, servers ...*metalv1alpha1.Server) *canaryTest {
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+	require.NoError(t, infrav1.AddToScheme(scheme))
+
+	canary.ResourceVersion = "1"
+	canary.Spec.ConfigFrom = metalv1alpha1.ObjectKeyRef{Kind: "Secret", Namespace: "default", Name: "canary-config", Key: "config"}
+
+	objects := []runtime.Object{
+		canary,
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "canary-config"},
+			Data:       map[string][]byte{"config": []byte("version: v1alpha1\n")},
+		},
+	}
+
+	for _, server := range servers {
+		server.ResourceVersion = "1"
+		objects = append(objects, server)
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme, objects...)
+
+	return &canaryTest{
+		t:      t,
+		ctx:    context.Background(),
+		client: c,
+		name:   canary.Name,
+		r: &controllers.CanaryReconciler{
+			Client:   c,
+			Log:      log.NullLogger{},
+			Scheme:   scheme,
+			Recorder: record.NewFakeRecorder(10),
+
+			RefNamespaces: render.RefNamespaces{"default": {}},
+		},
+	}
+}
+
+func (ct *canaryTest) reconcile() ctrl.Result {
+	result, err := ct.r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: ct.name}})
+	require.NoError(ct.t, err)
+
+	return result
+}
+
+func (ct *canaryTest) canary() *metalv1alpha1.Canary {
+	var canary metalv1alpha1.Canary
+
+	require.NoError(ct.t, ct.client.Get(ct.ctx, types.NamespacedName{Name: ct.name}, &canary))
+
+	return &canary
+}
+
+func (ct *canaryTest) server(name string) *metalv1alpha1.Server {
+	var server metalv1alpha1.Server
+
+	require.NoError(ct.t, ct.client.Get(ct.ctx, types.NamespacedName{Name: name}, &server))
+
+	return &server
+}
+
+// updateServer simulates the ServerReconciler and the agent.
+func (ct *canaryTest) updateServer(name string, inUse, isClean bool) {
+	server := ct.server(name)
+	server.Status.InUse = inUse
+	server.Status.IsClean = isClean
+
+	require.NoError(ct.t, ct.client.Status().Update(ct.ctx, server))
+}
+
+func idleServer(name string, addresses ...string) *metalv1alpha1.Server {
+	server := &metalv1alpha1.Server{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec:       metalv1alpha1.ServerSpec{Accepted: true},
+		Status:     metalv1alpha1.ServerStatus{IsClean: true},
+	}
+
+	for _, address := range addresses {
+		server.Status.Addresses = append(server.Status.Addresses, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: address})
+	}
+
+	return server
+}
+
+func TestCanaryRun(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { listener.Close() }) //nolint:errcheck
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			conn.Close() //nolint:errcheck
+		}
+	}()
+
+	allocated := idleServer("server-0")
+	allocated.Status.IsClean = false
+	allocated.Status.InUse = true
+
+	notAccepted := idleServer("server-2")
+	notAccepted.Spec.Accepted = false
+
+	ct := newCanaryTest(t,
+		&metalv1alpha1.Canary{
+			ObjectMeta: metav1.ObjectMeta{Name: "canary-run"},
+			Spec: metalv1alpha1.CanarySpec{
+				Interval:   &metav1.Duration{Duration: time.Hour},
+				VerifyPort: int32(listener.Addr().(*net.TCPAddr).Port),
+			},
+		},
+		allocated,
+		idleServer("server-1", "127.0.0.1"),
+		notAccepted,
+	)
+
+	// server is held and marked for wipe
+	ct.reconcile()
+
+	canary := ct.canary()
+	require.NotNil(t, canary.Status.Run)
+	assert.Equal(t, "server-1", canary.Status.Run.ServerRef)
+	assert.Equal(t, metalv1alpha1.CanaryPhaseWiping, canary.Status.Phase)
+	assert.Contains(t, canary.Finalizers, "metal.sidero.dev/canary")
+
+	server := ct.server("server-1")
+	assert.Equal(t, "canary-run", server.CanaryHeld())
+	assert.False(t, server.Status.IsClean)
+	assert.False(t, server.CanaryInstallRequested())
+
+	ct.reconcile()
+	assert.Equal(t, metalv1alpha1.CanaryPhaseWiping, ct.canary().Status.Phase)
+
+	// server is wiped, and allocated to the canary
+	ct.updateServer("server-1", false, true)
+	ct.reconcile()
+
+	assert.Equal(t, metalv1alpha1.CanaryPhaseInstalling, ct.canary().Status.Phase)
+	assert.True(t, ct.server("server-1").CanaryInstallRequested())
+
+	// configuration is served by the metadata server
+	ct.updateServer("server-1", true, false)
+
+	canary = ct.canary()
+	now := metav1.Now()
+	canary.Status.ConfigServedTime = &now
+	require.NoError(t, ct.client.Status().Update(ct.ctx, canary))
+
+	ct.reconcile()
+	assert.Equal(t, metalv1alpha1.CanaryPhaseVerifying, ct.canary().Status.Phase)
+
+	// installed system accepts the connections, server is released
+	ct.reconcile()
+	assert.Equal(t, metalv1alpha1.CanaryPhaseReleasing, ct.canary().Status.Phase)
+
+	server = ct.server("server-1")
+	assert.False(t, server.CanaryInstallRequested())
+	assert.Contains(t, server.Annotations, metalv1alpha1.ServerConfirmWipeAnnotation)
+
+	ct.updateServer("server-1", false, false)
+	ct.reconcile()
+	assert.Equal(t, metalv1alpha1.CanaryPhaseReleasing, ct.canary().Status.Phase)
+
+	// server is wiped after the release
+	ct.updateServer("server-1", false, true)
+	result := ct.reconcile()
+
+	canary = ct.canary()
+	assert.Empty(t, canary.Status.Phase)
+	assert.Nil(t, canary.Status.Run)
+	require.NotNil(t, canary.Status.LastRun)
+	assert.Equal(t, metalv1alpha1.CanaryResultSucceeded, canary.Status.LastRun.Result)
+	assert.NotNil(t, canary.Status.LastSuccessTime)
+
+	steps := make([]string, 0, len(canary.Status.LastRun.Steps))
+
+	for _, step := range canary.Status.LastRun.Steps {
+		steps = append(steps, step.Name)
+	}
+
+	assert.Equal(t, []string{
+		metalv1alpha1.CanaryStepWipe,
+		metalv1alpha1.CanaryStepInstall,
+		metalv1alpha1.CanaryStepVerify,
+		metalv1alpha1.CanaryStepRelease,
+	}, steps)
+
+	assert.Empty(t, ct.server("server-1").CanaryHeld())
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.CanaryRuns.WithLabelValues("canary-run", metrics.ResultSuccess)))
+
+	// next run is due after the interval
+	assert.InDelta(t, time.Hour, result.RequeueAfter, float64(time.Minute))
+
+	ct.reconcile()
+	assert.Nil(t, ct.canary().Status.Run)
+}
+
+func TestCanaryRunFailure(t *testing.T) {
+	t.Parallel()
+
+	ct := newCanaryTest(t,
+		&metalv1alpha1.Canary{
+			ObjectMeta: metav1.ObjectMeta{Name: "canary-failure"},
+			Spec: metalv1alpha1.CanarySpec{
+				Interval: &metav1.Duration{Duration: 90 * time.Minute},
+				Timeout:  &metav1.Duration{Duration: time.Hour},
+			},
+		},
+		idleServer("server-1"),
+	)
+
+	ct.reconcile()
+	ct.updateServer("server-1", false, true)
+	ct.reconcile()
+	ct.updateServer("server-1", true, false)
+
+	canary := ct.canary()
+	now := metav1.Now()
+	canary.Status.ConfigServedTime = &now
+	require.NoError(t, ct.client.Status().Update(ct.ctx, canary))
+
+	ct.reconcile()
+
+	// server has no addresses to verify
+	ct.reconcile()
+
+	canary = ct.canary()
+	assert.Equal(t, metalv1alpha1.CanaryPhaseVerifying, canary.Status.Phase)
+	assert.Equal(t, "server has no addresses", canary.Status.Run.Message)
+
+	// run times out
+	start := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	canary.Status.Run.StartTime = start
+	require.NoError(t, ct.client.Status().Update(ct.ctx, canary))
+
+	ct.reconcile()
+
+	canary = ct.canary()
+	assert.Nil(t, canary.Status.Run)
+	require.NotNil(t, canary.Status.LastRun)
+	assert.Equal(t, metalv1alpha1.CanaryResultFailed, canary.Status.LastRun.Result)
+	assert.Equal(t, "run timed out in phase Verifying", canary.Status.LastRun.Message)
+	assert.Equal(t, 1, canary.Status.ConsecutiveFailures)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.CanaryRuns.WithLabelValues("canary-failure", metrics.ResultFailure)))
+
+	// server is released right away, and it's wiped by the regular flow
+	server := ct.server("server-1")
+	assert.Empty(t, server.CanaryHeld())
+	assert.False(t, server.CanaryInstallRequested())
+	assert.False(t, server.Status.IsClean)
+	assert.Contains(t, server.Annotations, metalv1alpha1.ServerConfirmWipeAnnotation)
+
+	// server is not idle until it's wiped
+	ct.reconcile()
+	assert.Equal(t, "No idle servers matching the selector.", ct.canary().Status.Message)
+
+	// next run is due after the interval since the failed run started
+	ct.updateServer("server-1", false, true)
+	ct.reconcile()
+
+	canary = ct.canary()
+	require.NotNil(t, canary.Status.Run)
+	assert.Equal(t, "server-1", canary.Status.Run.ServerRef)
+	assert.Equal(t, metalv1alpha1.CanaryPhaseWiping, canary.Status.Phase)
+}
+
+func TestCanaryNoIdleServers(t *testing.T) {
+	t.Parallel()
+
+	ct := newCanaryTest(t,
+		&metalv1alpha1.Canary{
+			ObjectMeta: metav1.ObjectMeta{Name: "canary-idle"},
+			Spec: metalv1alpha1.CanarySpec{
+				ServerSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"canary": "true"}},
+			},
+		},
+		idleServer("server-1"),
+	)
+
+	ct.reconcile()
+
+	canary := ct.canary()
+	assert.Nil(t, canary.Status.Run)
+	assert.Equal(t, "No idle servers matching the selector.", canary.Status.Message)
+	assert.Empty(t, ct.server("server-1").CanaryHeld())
+}
+
+func TestCanaryConfigNamespace(t *testing.T) {
+	t.Parallel()
+
+	ct := newCanaryTest(t,
+		&metalv1alpha1.Canary{
+			ObjectMeta: metav1.ObjectMeta{Name: "canary-namespace"},
+		},
+		idleServer("server-1"),
+	)
+
+	ct.r.RefNamespaces = render.RefNamespaces{"sidero-system": {}}
+
+	ct.reconcile()
+
+	canary := ct.canary()
+	assert.Nil(t, canary.Status.Run)
+	assert.Equal(t, `Failed to fetch the configuration: Secret default/canary-config: references to namespace "default" are not allowed.`, canary.Status.Message)
+	assert.Empty(t, ct.server("server-1").CanaryHeld())
+}
@@ -0,0 +1,127 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/tools/reference"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/console"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/power/metal"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
+)
+
+// ConsoleReconciler captures serial consoles of the servers with console capture enabled
+// while they are wiped or provisioned.
+type ConsoleReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	Console  *console.Manager
+
+	// ProvisioningWindow is how long the console is captured after the server is PXE booted into the environment.
+	ProvisioningWindow time.Duration
+}
+
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=servers,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=get;list;create;patch
+
+func (r *ConsoleReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("server", req.NamespacedName)
+
+	var s metalv1alpha1.Server
+
+	if err := r.Get(ctx, req.NamespacedName, &s); err != nil {
+		if apierrors.IsNotFound(err) {
+			r.Console.Stop(req.Name)
+
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	serverRef, err := reference.GetReference(r.Scheme, &s)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	capture, requeueAfter := r.shouldCapture(&s, time.Now())
+
+	if !capture {
+		if r.Console.Stop(s.Name) {
+			r.Recorder.Event(serverRef, corev1.EventTypeNormal, "Console Capture", "Serial console capture stopped.")
+		}
+
+		return ctrl.Result{}, nil
+	}
+
+	bmc, err := metal.ResolveBMC(ctx, r.Client, s.Spec.BMC)
+	if err != nil {
+		log.Error(err, "failed to resolve BMC credentials")
+		r.Recorder.Event(serverRef, corev1.EventTypeWarning, "Console Capture", fmt.Sprintf("Failed to resolve BMC credentials: %s.", err))
+
+		return ctrl.Result{RequeueAfter: constants.DefaultRequeueAfter}, nil
+	}
+
+	started, err := r.Console.Capture(s.Name, bmc)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if started {
+		r.Recorder.Event(serverRef, corev1.EventTypeNormal, "Console Capture", "Serial console capture started.")
+	}
+
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// shouldCapture returns true if the console should be captured now,
+// and the time to check again if the capture window is going to end.
+func (r *ConsoleReconciler) shouldCapture(s *metalv1alpha1.Server, now time.Time) (bool, time.Duration) {
+	if !s.Spec.ConsoleCapture || s.Spec.BMC == nil || !s.Spec.Accepted || !s.DeletionTimestamp.IsZero() {
+		return false, 0
+	}
+
+	switch {
+	case !s.Status.InUse && (!s.Status.IsClean || s.ReinventoryRequested()):
+		// server is booted into the agent
+		return true, 0
+	case s.Status.InUse && !conditions.Has(s, metalv1alpha1.ConditionPXEBooted):
+		// server is going to be PXE booted into the environment
+		return true, 0
+	case s.Status.InUse:
+		// capture the install and the first boot
+		if remaining := r.ProvisioningWindow - now.Sub(conditions.GetLastTransitionTime(s, metalv1alpha1.ConditionPXEBooted).Time); remaining > 0 {
+			return true, remaining
+		}
+	}
+
+	return false, 0
+}
+
+func (r *ConsoleReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("console").
+		WithOptions(options).
+		For(&metalv1alpha1.Server{}).
+		Complete(r)
+}
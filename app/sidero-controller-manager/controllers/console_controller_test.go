@@ -0,0 +1,146 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers_test
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/controllers"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/console"
+)
+
+func TestConsoleReconcile(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	pxeBooted := func(ago time.Duration) clusterv1.Conditions {
+		return clusterv1.Conditions{
+			{
+				Type:               metalv1alpha1.ConditionPXEBooted,
+				Status:             corev1.ConditionTrue,
+				LastTransitionTime: metav1.NewTime(time.Now().Add(-ago)),
+			},
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		spec           metalv1alpha1.ServerSpec
+		status         metalv1alpha1.ServerStatus
+		active         bool
+		requeueOnStart bool
+	}{
+		"disabled": {
+			spec: metalv1alpha1.ServerSpec{Accepted: true},
+		},
+		"no bmc": {
+			spec: metalv1alpha1.ServerSpec{Accepted: true, ConsoleCapture: true},
+		},
+		"wiping": {
+			spec:   metalv1alpha1.ServerSpec{Accepted: true, ConsoleCapture: true, BMC: &metalv1alpha1.BMC{Endpoint: "10.0.0.25"}},
+			active: true,
+		},
+		"clean": {
+			spec:   metalv1alpha1.ServerSpec{Accepted: true, ConsoleCapture: true, BMC: &metalv1alpha1.BMC{Endpoint: "10.0.0.25"}},
+			status: metalv1alpha1.ServerStatus{IsClean: true},
+		},
+		"not accepted": {
+			spec: metalv1alpha1.ServerSpec{ConsoleCapture: true, BMC: &metalv1alpha1.BMC{Endpoint: "10.0.0.25"}},
+		},
+		"allocated": {
+			spec:   metalv1alpha1.ServerSpec{Accepted: true, ConsoleCapture: true, BMC: &metalv1alpha1.BMC{Endpoint: "10.0.0.25"}},
+			status: metalv1alpha1.ServerStatus{InUse: true},
+			active: true,
+		},
+		"provisioning": {
+			spec:           metalv1alpha1.ServerSpec{Accepted: true, ConsoleCapture: true, BMC: &metalv1alpha1.BMC{Endpoint: "10.0.0.25"}},
+			status:         metalv1alpha1.ServerStatus{InUse: true, Conditions: pxeBooted(10 * time.Minute)},
+			active:         true,
+			requeueOnStart: true,
+		},
+		"provisioned": {
+			spec:   metalv1alpha1.ServerSpec{Accepted: true, ConsoleCapture: true, BMC: &metalv1alpha1.BMC{Endpoint: "10.0.0.25"}},
+			status: metalv1alpha1.ServerStatus{InUse: true, Conditions: pxeBooted(time.Hour)},
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server := &metalv1alpha1.Server{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "4c4c4544-0036-4410-8052-b7c04f4e3532",
+				},
+				Spec:   tc.spec,
+				Status: tc.status,
+			}
+
+			m := console.NewManager(log.NullLogger{}, console.Options{
+				Directory: t.TempDir(),
+				Dialer: func(ctx context.Context, bmc metalv1alpha1.BMC) (io.ReadCloser, error) {
+					r, _ := io.Pipe()
+
+					return r, nil
+				},
+			})
+
+			r := &controllers.ConsoleReconciler{
+				Client:   fake.NewFakeClientWithScheme(scheme, server),
+				Log:      log.NullLogger{},
+				Scheme:   scheme,
+				Recorder: record.NewFakeRecorder(10),
+				Console:  m,
+
+				ProvisioningWindow: 30 * time.Minute,
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: server.Name}}
+
+			result, err := r.Reconcile(req)
+			require.NoError(t, err)
+
+			defer m.Stop(server.Name)
+
+			assert.Equal(t, tc.active, m.Active(server.Name))
+
+			if tc.requeueOnStart {
+				assert.InDelta(t, 20*time.Minute, result.RequeueAfter, float64(time.Minute))
+			} else {
+				assert.Zero(t, result.RequeueAfter)
+			}
+
+			if !tc.active {
+				return
+			}
+
+			// capture is stopped once the server is gone
+			require.NoError(t, r.Delete(context.Background(), server))
+
+			_, err = r.Reconcile(req)
+			require.NoError(t, err)
+
+			assert.False(t, m.Active(server.Name))
+		})
+	}
+}
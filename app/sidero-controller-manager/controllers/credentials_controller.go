@@ -0,0 +1,192 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/tools/reference"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/render"
+)
+
+// CredentialsReconciler flags the servers which run the cluster credentials different from the current ones.
+//
+// Credentials fingerprint recorded in the provenance when the config was served is compared
+// to the fingerprint of the current bootstrap data of the machine.
+type CredentialsReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=serverbindings,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=serverbindings/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metalmachines,verbs=get;list;watch
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=get;list;create;patch
+
+func (r *CredentialsReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, err error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("serverbinding", req.NamespacedName)
+
+	var serverBinding infrav1.ServerBinding
+
+	if err = r.Get(ctx, req.NamespacedName, &serverBinding); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	// config wasn't served yet, so the server doesn't run any credentials
+	if serverBinding.Status.Provenance == nil || !serverBinding.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(&serverBinding, r)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	defer func() {
+		if e := patchHelper.Patch(ctx, &serverBinding); e != nil {
+			log.Error(e, "failed to patch serverbinding")
+
+			if err == nil {
+				err = e
+			}
+		}
+	}()
+
+	if serverBinding.Status.Provenance.CredentialsSHA256 == "" {
+		conditions.MarkUnknown(&serverBinding, infrav1.ConditionCredentialsUpToDate, infrav1.CredentialsUnknownReason, "Credentials fingerprint wasn't recorded when the config was served.")
+
+		return ctrl.Result{}, nil
+	}
+
+	fingerprint, err := r.currentFingerprint(ctx, &serverBinding)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// machine is being removed or the bootstrap data is not generated yet, nothing to compare with
+	if fingerprint == "" {
+		return ctrl.Result{}, nil
+	}
+
+	if fingerprint == serverBinding.Status.Provenance.CredentialsSHA256 {
+		conditions.MarkTrue(&serverBinding, infrav1.ConditionCredentialsUpToDate)
+
+		return ctrl.Result{}, nil
+	}
+
+	if !conditions.IsFalse(&serverBinding, infrav1.ConditionCredentialsUpToDate) {
+		log.Info("server runs rotated cluster credentials")
+
+		if serverBindingRef, refErr := reference.GetReference(r.Scheme, &serverBinding); refErr == nil {
+			r.Recorder.Event(serverBindingRef, corev1.EventTypeWarning, "Credentials Rotation", "Server runs rotated cluster credentials, it should be reprovisioned.")
+		}
+	}
+
+	conditions.MarkFalse(&serverBinding, infrav1.ConditionCredentialsUpToDate, infrav1.CredentialsRotatedReason, clusterv1.ConditionSeverityWarning,
+		"Cluster credentials were rotated after the config was served in generation %d.", serverBinding.Status.Provenance.ConfigGeneration)
+
+	return ctrl.Result{}, nil
+}
+
+// currentFingerprint returns the credentials fingerprint of the current bootstrap data of the machine bound to the server.
+//
+// Empty fingerprint is returned if the bootstrap data can't be found.
+func (r *CredentialsReconciler) currentFingerprint(ctx context.Context, serverBinding *infrav1.ServerBinding) (string, error) {
+	var metalMachine infrav1.MetalMachine
+
+	if err := r.Get(ctx, types.NamespacedName{Namespace: serverBinding.Spec.MetalMachineRef.Namespace, Name: serverBinding.Spec.MetalMachineRef.Name}, &metalMachine); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+
+	machine, err := util.GetOwnerMachine(ctx, r.Client, metalMachine.ObjectMeta)
+	if err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+
+	if machine == nil || machine.Spec.Bootstrap.DataSecretName == nil {
+		return "", nil
+	}
+
+	var secret corev1.Secret
+
+	if err = r.Get(ctx, types.NamespacedName{Namespace: machine.Namespace, Name: *machine.Spec.Bootstrap.DataSecretName}, &secret); err != nil {
+		return "", client.IgnoreNotFound(err)
+	}
+
+	value, ok := secret.Data["value"]
+	if !ok {
+		return "", fmt.Errorf("value key not found in bootstrap data: %s/%s", secret.Namespace, secret.Name)
+	}
+
+	return render.CredentialsFingerprint(value)
+}
+
+func (r *CredentialsReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	// bootstrap data secrets are labeled with the cluster name, same as the serverbindings
+	mapRequests := handler.ToRequestsFunc(
+		func(a handler.MapObject) []reconcile.Request {
+			clusterName, ok := a.Meta.GetLabels()[clusterv1.ClusterLabelName]
+			if !ok {
+				return nil
+			}
+
+			var serverBindings infrav1.ServerBindingList
+
+			if err := r.List(context.Background(), &serverBindings, client.MatchingLabels{clusterv1.ClusterLabelName: clusterName}); err != nil {
+				return nil
+			}
+
+			reqs := make([]reconcile.Request, 0, len(serverBindings.Items))
+
+			for _, serverBinding := range serverBindings.Items {
+				if serverBinding.Spec.MetalMachineRef.Namespace != a.Meta.GetNamespace() {
+					continue
+				}
+
+				reqs = append(reqs, reconcile.Request{
+					NamespacedName: types.NamespacedName{
+						Name: serverBinding.Name,
+					},
+				})
+			}
+
+			return reqs
+		})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("credentials").
+		WithOptions(options).
+		For(&infrav1.ServerBinding{}).
+		Watches(
+			&source.Kind{Type: &corev1.Secret{}},
+			&handler.EnqueueRequestsFromMapFunc{
+				ToRequests: mapRequests,
+			},
+		).
+		Complete(r)
+}
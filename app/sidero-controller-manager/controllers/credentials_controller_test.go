@@ -0,0 +1,203 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/controllers"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/render"
+)
+
+func TestCredentialsReconcile(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, clusterv1.AddToScheme(scheme))
+	require.NoError(t, infrav1.AddToScheme(scheme))
+
+	oldConfig := []byte(`version: v1alpha1
+machine:
+  type: join
+  token: old.token
+`)
+	newConfig := []byte(`version: v1alpha1
+machine:
+  type: join
+  token: new.token
+`)
+
+	oldFingerprint, err := render.CredentialsFingerprint(oldConfig)
+	require.NoError(t, err)
+
+	for name, tc := range map[string]struct {
+		provenance *infrav1.Provenance
+		expected   *corev1.ConditionStatus
+	}{
+		"not served": {},
+		"up to date": {
+			provenance: &infrav1.Provenance{CredentialsSHA256: oldFingerprint},
+			expected:   conditionStatus(corev1.ConditionTrue),
+		},
+		"unknown": {
+			provenance: &infrav1.Provenance{},
+			expected:   conditionStatus(corev1.ConditionUnknown),
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := fake.NewFakeClientWithScheme(scheme, credentialsObjects(oldConfig, tc.provenance)...)
+
+			r := &controllers.CredentialsReconciler{
+				Client:   c,
+				Log:      log.NullLogger{},
+				Scheme:   scheme,
+				Recorder: record.NewFakeRecorder(10),
+			}
+
+			_, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: "server-1"}})
+			require.NoError(t, err)
+
+			var serverBinding infrav1.ServerBinding
+
+			require.NoError(t, c.Get(ctx, types.NamespacedName{Name: "server-1"}, &serverBinding))
+
+			condition := conditions.Get(&serverBinding, infrav1.ConditionCredentialsUpToDate)
+
+			if tc.expected == nil {
+				assert.Nil(t, condition)
+
+				return
+			}
+
+			require.NotNil(t, condition)
+			assert.Equal(t, *tc.expected, condition.Status)
+		})
+	}
+
+	t.Run("rotated", func(t *testing.T) {
+		t.Parallel()
+
+		c := fake.NewFakeClientWithScheme(scheme, credentialsObjects(oldConfig, &infrav1.Provenance{CredentialsSHA256: oldFingerprint})...)
+		recorder := record.NewFakeRecorder(10)
+
+		r := &controllers.CredentialsReconciler{
+			Client:   c,
+			Log:      log.NullLogger{},
+			Scheme:   scheme,
+			Recorder: recorder,
+		}
+
+		reconcile := func() *clusterv1.Condition {
+			_, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: "server-1"}})
+			require.NoError(t, err)
+
+			var serverBinding infrav1.ServerBinding
+
+			require.NoError(t, c.Get(ctx, types.NamespacedName{Name: "server-1"}, &serverBinding))
+
+			return conditions.Get(&serverBinding, infrav1.ConditionCredentialsUpToDate)
+		}
+
+		assert.Equal(t, corev1.ConditionTrue, reconcile().Status)
+
+		var secret corev1.Secret
+
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "worker-1-bootstrap"}, &secret))
+
+		secret.Data["value"] = newConfig
+
+		require.NoError(t, c.Update(ctx, &secret))
+
+		condition := reconcile()
+		assert.Equal(t, corev1.ConditionFalse, condition.Status)
+		assert.Equal(t, infrav1.CredentialsRotatedReason, condition.Reason)
+
+		// reconciling again doesn't repeat the event
+		reconcile()
+
+		assert.Len(t, recorder.Events, 1)
+	})
+}
+
+func conditionStatus(status corev1.ConditionStatus) *corev1.ConditionStatus {
+	return &status
+}
+
+func credentialsObjects(bootstrapData []byte, provenance *infrav1.Provenance) []runtime.Object {
+	secretName := "worker-1-bootstrap"
+
+	return []runtime.Object{
+		&infrav1.ServerBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "server-1",
+				Labels:          map[string]string{clusterv1.ClusterLabelName: "prod"},
+				ResourceVersion: "1",
+			},
+			Spec: infrav1.ServerBindingSpec{
+				MetalMachineRef: corev1.ObjectReference{Namespace: "default", Name: "worker-1"},
+			},
+			Status: infrav1.ServerBindingState{
+				Provenance: provenance,
+			},
+		},
+		&infrav1.MetalMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "worker-1",
+				OwnerReferences: []metav1.OwnerReference{
+					{
+						APIVersion: clusterv1.GroupVersion.String(),
+						Kind:       "Machine",
+						Name:       "worker-1",
+					},
+				},
+			},
+		},
+		&clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "worker-1",
+			},
+			Spec: clusterv1.MachineSpec{
+				ClusterName: "prod",
+				Bootstrap: clusterv1.Bootstrap{
+					DataSecretName: &secretName,
+				},
+			},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      secretName,
+				Labels:    map[string]string{clusterv1.ClusterLabelName: "prod"},
+			},
+			Data: map[string][]byte{
+				"value": bootstrapData,
+			},
+		},
+	}
+}
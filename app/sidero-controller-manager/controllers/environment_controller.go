@@ -8,10 +8,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -20,11 +21,13 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 
 	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/assets"
 	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
 )
 
@@ -36,10 +39,20 @@ type EnvironmentReconciler struct {
 	TalosRelease string
 	APIEndpoint  string
 	APIPort      uint16
+
+	// AssetEncodings lists encodings to precompress downloaded assets with, none by default.
+	AssetEncodings []assets.Encoding
+
+	// DataDirectory keeps the environment assets and the download cache, defaults to constants.DataDirectory.
+	DataDirectory string
+
+	cacheOnce sync.Once
+	cache     *assets.Cache
 }
 
 // +kubebuilder:rbac:groups=metal.sidero.dev,resources=environments,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=metal.sidero.dev,resources=environments/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=externalmachines,verbs=get;list;watch
 
 func (r *EnvironmentReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
@@ -65,193 +78,243 @@ func (r *EnvironmentReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
-	envs := filepath.Join("/var/lib/sidero/env", env.GetName())
-
-	if _, err := os.Stat(envs); os.IsNotExist(err) {
-		if err = os.MkdirAll(envs, 0o777); err != nil {
-			return ctrl.Result{}, fmt.Errorf("error creating environment directory: %w", err)
-		}
+	dataDir := r.DataDirectory
+	if dataDir == "" {
+		dataDir = constants.DataDirectory
 	}
 
-	var (
-		conditions = []metalv1alpha1.AssetCondition{}
-		wg         sync.WaitGroup
-		mu         sync.Mutex
-		result     *multierror.Error
-	)
+	r.cacheOnce.Do(func() {
+		r.cache = assets.NewCache(filepath.Join(dataDir, "cache"))
+	})
+
+	envs := filepath.Join(dataDir, "env", env.GetName())
+
+	if err := os.MkdirAll(envs, 0o777); err != nil {
+		return ctrl.Result{}, fmt.Errorf("error creating environment directory: %w", err)
+	}
 
-	for _, assetTask := range []struct {
+	tasks := []struct {
 		BaseName string
 		Asset    metalv1alpha1.Asset
 	}{
 		{
 			BaseName: constants.KernelAsset,
-			Asset:    env.Spec.Kernel.Asset,
+			Asset:    env.Spec.KernelAsset(),
 		},
 		{
 			BaseName: constants.InitrdAsset,
-			Asset:    env.Spec.Initrd.Asset,
+			Asset:    env.Spec.InitrdAsset(),
 		},
-	} {
-		assetTask := assetTask
+	}
+
+	var (
+		conditions = make([]metalv1alpha1.AssetCondition, len(tasks)+1)
+		wg         sync.WaitGroup
+		mu         sync.Mutex
+		result     *multierror.Error
+		pending    bool
+	)
+
+	// invalid kernel args are reported, but the assets are still downloaded, as the environment might be already in use
+	conditions[len(tasks)] = env.KernelArgsCondition()
+
+	if conditions[len(tasks)].Status != "True" {
+		l.Info("invalid kernel args", "error", conditions[len(tasks)].Message)
+	}
+
+	for i, assetTask := range tasks {
+		i, assetTask := i, assetTask
 
 		file := filepath.Join(envs, assetTask.BaseName)
 
-		setReady := func(ready bool) {
-			status := "False"
-			if ready {
-				status = "True"
-			}
+		conditions[i] = metalv1alpha1.AssetCondition{
+			Asset:  assetTask.Asset,
+			Status: "False",
+			Type:   "Ready",
+		}
+
+		if _, err := os.Stat(file); err == nil && env.AssetReady(assetTask.Asset) {
+			// the file was downloaded from the URL and verified, keep the recorded checksum
+			l.Info("update not required", "file", file)
 
-			condition := metalv1alpha1.AssetCondition{
-				Asset:  assetTask.Asset,
-				Status: status,
-				Type:   "Ready",
+			for _, condition := range env.Status.Conditions {
+				if condition.Type == "Ready" && condition.URL == assetTask.Asset.URL && condition.Status == "True" {
+					conditions[i] = condition
+				}
 			}
 
-			mu.Lock()
-			conditions = append(conditions, condition)
-			mu.Unlock()
+			continue
 		}
 
-		saveAsset := func(file string) {
-			wg.Add(1)
+		l.Info("saving asset", "url", assetTask.Asset.URL)
 
-			go func() {
-				defer wg.Done()
+		conditions[i].Reason = metalv1alpha1.AssetReasonDownloading
+		pending = true
 
-				if err := save(ctx, assetTask.Asset, file); err != nil {
-					setReady(false)
+		wg.Add(1)
 
-					result = multierror.Append(result, fmt.Errorf("error saving %q: %w", assetTask.Asset.URL, err))
-				}
+		go func() {
+			defer wg.Done()
 
-				setReady(true)
-				l.Info("saved asset", "url", assetTask.Asset.URL)
-			}()
-		}
+			requestContext, cancel := context.WithTimeout(ctx, 5*time.Minute)
+			defer cancel()
 
-		if _, err := os.Stat(file); os.IsNotExist(err) {
-			l.Info("saving asset", "url", assetTask.Asset.URL)
-			saveAsset(file)
+			digest, err := r.cache.Fetch(requestContext, assetTask.Asset.URL, assetTask.Asset.SHA512, file, func(downloaded, total int64) {
+				mu.Lock()
+				conditions[i].DownloadedBytes = downloaded
+				conditions[i].TotalBytes = total
+				mu.Unlock()
+			})
 
-			continue
-		}
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				conditions[i].Reason = metalv1alpha1.AssetReasonFailed
+				if errors.Is(err, assets.ErrChecksumMismatch) {
+					conditions[i].Reason = metalv1alpha1.AssetReasonChecksumMismatch
+				}
 
-		// If we reach here, the file derived from the URL exists, and now we need
-		// to update it if the URL has changed.
+				conditions[i].Message = err.Error()
 
-		l.Info("checking if update required", "file", file)
+				result = multierror.Append(result, fmt.Errorf("error saving %q: %w", assetTask.Asset.URL, err))
 
-		ready := false
+				return
+			}
 
-		for _, condition := range env.Status.Conditions {
-			if assetTask.Asset.URL == condition.URL {
-				ready = true
+			// precompressed variants are optional, the original file is served if they are missing
+			if err := assets.Precompress(file, r.AssetEncodings...); err != nil {
+				l.Error(err, "failed to precompress asset", "file", file)
 			}
-		}
 
-		if ready {
-			l.Info("update not required", "file", file)
-			setReady(true)
+			conditions[i].Status = "True"
+			conditions[i].SHA512 = digest
+			conditions[i].Message = ""
 
-			continue
-		}
+			if assetTask.Asset.SHA512 != "" {
+				conditions[i].Reason = metalv1alpha1.AssetReasonVerified
+			} else {
+				conditions[i].Reason = metalv1alpha1.AssetReasonDownloaded
+			}
+
+			l.Info("saved asset", "url", assetTask.Asset.URL, "sha512", digest)
+		}()
+	}
+
+	// report the download progress while the assets are downloaded
+	done := make(chan struct{})
+
+	var progressWg sync.WaitGroup
+
+	if pending {
+		progressWg.Add(1)
 
-		l.Info("update required", "file", file)
+		go func() {
+			defer progressWg.Done()
 
-		// At this point the file exists, but the URL for the file has changed. We
-		// need to update the file using the new URL.
+			ticker := time.NewTicker(progressInterval)
+			defer ticker.Stop()
 
-		l.Info("updating asset", "url", assetTask.Asset.URL)
-		saveAsset(file)
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+				}
+
+				mu.Lock()
+				snapshot := append([]metalv1alpha1.AssetCondition(nil), conditions...)
+				mu.Unlock()
+
+				if err := r.updateConditions(ctx, &env, snapshot); err != nil {
+					l.Error(err, "failed to report download progress")
+				}
+			}
+		}()
 	}
 
 	wg.Wait()
+	close(done)
+	progressWg.Wait()
+
+	if err := r.updateConditions(ctx, &env, conditions); err != nil {
+		return ctrl.Result{}, err
+	}
 
 	if result.ErrorOrNil() != nil {
 		return ctrl.Result{}, result.ErrorOrNil()
 	}
 
-	env.Status.Conditions = conditions
+	return ctrl.Result{}, nil
+}
 
-	if err := r.Status().Update(ctx, &env); err != nil {
-		return ctrl.Result{}, err
-	}
+// progressInterval is the interval between download progress updates in the status.
+const progressInterval = 5 * time.Second
 
-	return ctrl.Result{}, nil
+func (r *EnvironmentReconciler) updateConditions(ctx context.Context, env *metalv1alpha1.Environment, conditions []metalv1alpha1.AssetCondition) error {
+	base := env.DeepCopy()
+
+	env.Status.Conditions = conditions
+
+	return r.Status().Patch(ctx, env, client.MergeFrom(base))
 }
 
 // ReconcileEnvironmentDefault ensures that Environment "default" exist.
+//
+// If the Environment was generated by Sidero for another API endpoint (e.g. it was moved from the bootstrap cluster),
+// kernel args are updated to point to the current endpoint.
 func ReconcileEnvironmentDefault(ctx context.Context, c client.Client, talosRelease, apiEndpoint string, apiPort uint16) error {
 	key := types.NamespacedName{
 		Name: metalv1alpha1.EnvironmentDefault,
 	}
 
+	// same format as in the default Environment kernel args
+	endpoint := net.JoinHostPort(apiEndpoint, strconv.Itoa(int(apiPort)))
+
 	env := metalv1alpha1.Environment{}
 	err := c.Get(ctx, key, &env)
 
 	if apierrors.IsNotFound(err) {
 		env.Name = metalv1alpha1.EnvironmentDefault
+		env.Annotations = map[string]string{
+			metalv1alpha1.EnvironmentAPIEndpointAnnotation: endpoint,
+		}
 		env.Spec = *metalv1alpha1.EnvironmentDefaultSpec(talosRelease, apiEndpoint, apiPort)
 
-		err = c.Create(ctx, &env)
+		return c.Create(ctx, &env)
 	}
 
-	return err
-}
-
-func (r *EnvironmentReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
-	if r.TalosRelease == "" {
-		return errors.New("TalosRelease is not set")
-	}
-
-	return ctrl.NewControllerManagedBy(mgr).
-		WithOptions(options).
-		For(&metalv1alpha1.Environment{}).
-		Complete(r)
-}
-
-func save(ctx context.Context, asset metalv1alpha1.Asset, file string) error {
-	url := asset.URL
-
-	if url == "" {
-		return errors.New("missing URL")
-	}
-
-	requestContext, cancel := context.WithTimeout(ctx, 5*time.Minute)
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(requestContext, http.MethodGet, url, nil)
 	if err != nil {
 		return err
 	}
 
-	client := &http.Client{}
+	previous, ok := env.Annotations[metalv1alpha1.EnvironmentAPIEndpointAnnotation]
+	if !ok || previous == endpoint {
+		// either created by the user or up to date
+		return nil
+	}
 
-	resp, err := client.Do(req)
+	patchHelper, err := patch.NewHelper(&env, c)
 	if err != nil {
 		return err
 	}
 
-	defer resp.Body.Close()
-
-	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
-		w, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY, 0o666)
-		if err != nil {
-			return err
-		}
+	for i, arg := range env.Spec.Kernel.Args {
+		env.Spec.Kernel.Args[i] = strings.ReplaceAll(arg, "http://"+previous+"/", "http://"+endpoint+"/")
+	}
 
-		defer w.Close()
+	env.Annotations[metalv1alpha1.EnvironmentAPIEndpointAnnotation] = endpoint
 
-		r := resp.Body
+	return patchHelper.Patch(ctx, &env)
+}
 
-		if _, err := io.Copy(w, r); err != nil {
-			return err
-		}
-	} else {
-		return fmt.Errorf("failed to download asset: %d", resp.StatusCode)
+func (r *EnvironmentReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	if r.TalosRelease == "" {
+		return errors.New("TalosRelease is not set")
 	}
 
-	return nil
+	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(options).
+		For(&metalv1alpha1.Environment{}).
+		Complete(r)
 }
@@ -0,0 +1,243 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers_test
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/controllers"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
+)
+
+func TestReconcileEnvironmentDefault(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+
+	custom := &metalv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: metalv1alpha1.EnvironmentDefault,
+		},
+		Spec: *metalv1alpha1.EnvironmentDefaultSpec("v0.11.5", "10.5.0.1", 8081),
+	}
+
+	for name, tc := range map[string]struct {
+		existing     []runtime.Object
+		endpoint     string
+		expectedArg  string
+		expectedAnno string
+	}{
+		"create": {
+			expectedArg:  "talos.config=http://172.24.0.2:8081/configdata?uuid=",
+			expectedAnno: "172.24.0.2:8081",
+		},
+		"created for bootstrap cluster": {
+			existing: func() []runtime.Object {
+				env := custom.DeepCopy()
+				env.Annotations = map[string]string{
+					metalv1alpha1.EnvironmentAPIEndpointAnnotation: "10.5.0.1:8081",
+				}
+
+				return []runtime.Object{env}
+			}(),
+			expectedArg:  "talos.config=http://172.24.0.2:8081/configdata?uuid=",
+			expectedAnno: "172.24.0.2:8081",
+		},
+		"created by user": {
+			existing:    []runtime.Object{custom.DeepCopy()},
+			expectedArg: "talos.config=http://10.5.0.1:8081/configdata?uuid=",
+		},
+		"ipv6": {
+			endpoint:     "2001:db8::2",
+			expectedArg:  "talos.config=http://[2001:db8::2]:8081/configdata?uuid=",
+			expectedAnno: "[2001:db8::2]:8081",
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := fake.NewFakeClientWithScheme(scheme, tc.existing...)
+
+			if tc.endpoint == "" {
+				tc.endpoint = "172.24.0.2"
+			}
+
+			require.NoError(t, controllers.ReconcileEnvironmentDefault(ctx, c, "v0.11.5", tc.endpoint, 8081))
+
+			var env metalv1alpha1.Environment
+
+			require.NoError(t, c.Get(ctx, types.NamespacedName{Name: metalv1alpha1.EnvironmentDefault}, &env))
+
+			assert.Contains(t, env.Spec.Kernel.Args, tc.expectedArg)
+			assert.Equal(t, tc.expectedAnno, env.Annotations[metalv1alpha1.EnvironmentAPIEndpointAnnotation])
+			assert.Empty(t, env.ValidateKernelArgs())
+		})
+	}
+}
+
+func TestEnvironmentReconcile(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+
+	const schematicID = "376567988ad370138ad8b2698212367b8edcb69b5fd68c80be1f2ec7d603b4ba"
+
+	assetContents := map[string][]byte{
+		"/image/" + schematicID + "/v1.6.0/kernel-amd64":       []byte("kernel"),
+		"/image/" + schematicID + "/v1.6.0/initramfs-amd64.xz": []byte("initramfs"),
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := assetContents[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		w.Write(data) //nolint:errcheck
+	}))
+
+	// subtests are parallel, so the server is closed once they are done
+	t.Cleanup(srv.Close)
+
+	checksum := func(data []byte) string {
+		sum := sha512.Sum512(data)
+
+		return hex.EncodeToString(sum[:])
+	}
+
+	for name, tc := range map[string]struct {
+		initrdSHA512 string
+
+		expectError    bool
+		expectedReady  bool
+		expectedInitrd metalv1alpha1.AssetCondition
+	}{
+		"not pinned": {
+			expectedReady: true,
+			expectedInitrd: metalv1alpha1.AssetCondition{
+				Asset:  metalv1alpha1.Asset{URL: srv.URL + "/image/" + schematicID + "/v1.6.0/initramfs-amd64.xz", SHA512: checksum([]byte("initramfs"))},
+				Status: "True",
+				Type:   "Ready",
+				Reason: metalv1alpha1.AssetReasonDownloaded,
+
+				DownloadedBytes: 9,
+				TotalBytes:      9,
+			},
+		},
+		"verified": {
+			initrdSHA512:  checksum([]byte("initramfs")),
+			expectedReady: true,
+			expectedInitrd: metalv1alpha1.AssetCondition{
+				Asset:  metalv1alpha1.Asset{URL: srv.URL + "/image/" + schematicID + "/v1.6.0/initramfs-amd64.xz", SHA512: checksum([]byte("initramfs"))},
+				Status: "True",
+				Type:   "Ready",
+				Reason: metalv1alpha1.AssetReasonVerified,
+
+				DownloadedBytes: 9,
+				TotalBytes:      9,
+			},
+		},
+		"checksum mismatch": {
+			initrdSHA512: checksum([]byte("tampered")),
+			expectError:  true,
+			expectedInitrd: metalv1alpha1.AssetCondition{
+				Asset:   metalv1alpha1.Asset{URL: srv.URL + "/image/" + schematicID + "/v1.6.0/initramfs-amd64.xz", SHA512: checksum([]byte("tampered"))},
+				Status:  "False",
+				Type:    "Ready",
+				Reason:  metalv1alpha1.AssetReasonChecksumMismatch,
+				Message: "checksum mismatch: expected " + checksum([]byte("tampered")) + ", got " + checksum([]byte("initramfs")),
+
+				DownloadedBytes: 9,
+				TotalBytes:      9,
+			},
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			env := &metalv1alpha1.Environment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name: "factory",
+				},
+				Spec: metalv1alpha1.EnvironmentSpec{
+					Initrd: metalv1alpha1.Initrd{
+						Asset: metalv1alpha1.Asset{SHA512: tc.initrdSHA512},
+					},
+					ImageFactory: &metalv1alpha1.ImageFactory{
+						SchematicID:  schematicID,
+						TalosVersion: "v1.6.0",
+						URL:          srv.URL,
+					},
+				},
+			}
+
+			dataDir := t.TempDir()
+
+			r := &controllers.EnvironmentReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme, env),
+				Log:    log.NullLogger{},
+				Scheme: scheme,
+
+				DataDirectory: dataDir,
+			}
+
+			_, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: env.Name}})
+			if tc.expectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			require.NoError(t, r.Get(ctx, types.NamespacedName{Name: env.Name}, env))
+			require.Len(t, env.Status.Conditions, 3)
+
+			assert.Equal(t, tc.expectedInitrd, env.Status.Conditions[1])
+			assert.Equal(t, tc.expectedReady, env.IsReady())
+
+			// no kernel args, but the assets are still downloaded
+			assert.Equal(t, metalv1alpha1.ConditionKernelArgsValid, env.Status.Conditions[2].Type)
+			assert.Equal(t, "False", env.Status.Conditions[2].Status)
+			assert.Equal(t, metalv1alpha1.KernelArgsReasonInvalid, env.Status.Conditions[2].Reason)
+
+			if !tc.expectedReady {
+				return
+			}
+
+			for _, asset := range []string{constants.KernelAsset, constants.InitrdAsset} {
+				_, err = os.Stat(filepath.Join(dataDir, "env", env.Name, asset))
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// powerOnAllowed checks the power on policy of the server which is about to be powered on.
+//
+// While the dependencies are not satisfied (or the policy refers to a missing ServerClass), the server is not powered on,
+// and the PowerOnDependenciesReady condition is false.
+// The condition message lists only the ServerClasses being waited for, and the condition is recreated when the message changes,
+// so that the timeout is counted from the last transition time of the condition, i.e. since the last change of the unsatisfied dependencies.
+//
+// Server powered off with the PowerAction is not powered on until the hold is released.
+func (r *ServerReconciler) powerOnAllowed(ctx context.Context, log logr.Logger, s *metalv1alpha1.Server, serverRef *corev1.ObjectReference) (bool, error) {
+	if s.PowerHeld() {
+		log.Info("server is held powered off", "action", s.Annotations[metalv1alpha1.ServerPowerHoldAnnotation])
+
+		return false, nil
+	}
+
+	var serverClasses metalv1alpha1.ServerClassList
+
+	if err := r.List(ctx, &serverClasses); err != nil {
+		return false, err
+	}
+
+	policy, err := metalv1alpha1.ResolvePowerOnPolicy(s, serverClasses.Items)
+	if err != nil {
+		return false, err
+	}
+
+	if policy == nil || len(policy.After) == 0 {
+		conditions.Delete(s, metalv1alpha1.ConditionPowerOnDependenciesReady)
+
+		return true, nil
+	}
+
+	var servers metalv1alpha1.ServerList
+
+	if err = r.List(ctx, &servers); err != nil {
+		return false, err
+	}
+
+	var (
+		message  string
+		reason   = metalv1alpha1.WaitingForDependenciesReason
+		severity = clusterv1.ConditionSeverityInfo
+	)
+
+	pending, err := policy.Pending(s, servers.Items, serverClasses.Items)
+
+	switch {
+	case err != nil:
+		message = fmt.Sprintf("Invalid power on policy: %s.", err)
+		reason = metalv1alpha1.InvalidPowerOnPolicyReason
+		severity = clusterv1.ConditionSeverityWarning
+	case len(pending) > 0:
+		names := make([]string, 0, len(pending))
+
+		for _, dependency := range pending {
+			names = append(names, fmt.Sprintf("%q", dependency.ServerClass))
+		}
+
+		message = fmt.Sprintf("Waiting for the servers of ServerClass %s.", strings.Join(names, ", "))
+
+		log.Info("waiting for power on dependencies", "pending", fmt.Sprint(pending))
+	default:
+		if conditions.Has(s, metalv1alpha1.ConditionPowerOnDependenciesReady) && !conditions.IsTrue(s, metalv1alpha1.ConditionPowerOnDependenciesReady) {
+			r.Recorder.Event(serverRef, corev1.EventTypeNormal, "Server Power On Order", "Power on dependencies are satisfied.")
+		}
+
+		conditions.MarkTrue(s, metalv1alpha1.ConditionPowerOnDependenciesReady)
+
+		return true, nil
+	}
+
+	waiting := conditions.GetReason(s, metalv1alpha1.ConditionPowerOnDependenciesReady) == reason &&
+		conditions.GetMessage(s, metalv1alpha1.ConditionPowerOnDependenciesReady) == message
+
+	if waiting && policy.Timeout != nil {
+		waited := time.Since(conditions.GetLastTransitionTime(s, metalv1alpha1.ConditionPowerOnDependenciesReady).Time)
+
+		if waited >= policy.Timeout.Duration {
+			conditions.Set(s, &clusterv1.Condition{
+				Type:    metalv1alpha1.ConditionPowerOnDependenciesReady,
+				Status:  corev1.ConditionTrue,
+				Reason:  metalv1alpha1.DependenciesTimedOutReason,
+				Message: fmt.Sprintf("Powered on after waiting for %s: %s", policy.Timeout.Duration, message),
+			})
+
+			r.Recorder.Event(serverRef, corev1.EventTypeWarning, "Server Power On Order", fmt.Sprintf("Power on dependencies are not satisfied in %s, powering on: %s", policy.Timeout.Duration, message))
+
+			return true, nil
+		}
+	}
+
+	if !waiting {
+		eventType := corev1.EventTypeNormal
+		if reason == metalv1alpha1.InvalidPowerOnPolicyReason {
+			eventType = corev1.EventTypeWarning
+		}
+
+		r.Recorder.Event(serverRef, eventType, "Server Power On Order", fmt.Sprintf("Power on postponed: %s", message))
+
+		// the timeout is counted since the change, don't rely on MarkFalse resetting the last transition time
+		conditions.Delete(s, metalv1alpha1.ConditionPowerOnDependenciesReady)
+	}
+
+	conditions.MarkFalse(s, metalv1alpha1.ConditionPowerOnDependenciesReady, reason, severity, "%s", message)
+
+	return false, nil
+}
@@ -0,0 +1,319 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/controllers"
+)
+
+// managementAPI is the management API of the servers served at /<server name>.
+type managementAPI struct {
+	mu    sync.Mutex
+	power map[string]bool
+}
+
+func (m *managementAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	switch parts[1] {
+	case "poweron", "reboot":
+		m.power[parts[0]] = true
+	case "poweroff":
+		m.power[parts[0]] = false
+	case "status":
+		json.NewEncoder(w).Encode(struct{ PoweredOn bool }{m.power[parts[0]]}) //nolint:errcheck
+	}
+}
+
+func (m *managementAPI) poweredOn(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.power[name]
+}
+
+func TestServerPowerOnOrder(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+	require.NoError(t, infrav1.AddToScheme(scheme))
+
+	for name, tc := range map[string]struct {
+		timeout *metav1.Duration
+
+		expectedReason string
+	}{
+		"dependencies satisfied": {},
+		"timed out": {
+			timeout:        &metav1.Duration{Duration: time.Nanosecond},
+			expectedReason: metalv1alpha1.DependenciesTimedOutReason,
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			bmc := &managementAPI{power: map[string]bool{}}
+
+			srv := httptest.NewServer(bmc)
+			defer srv.Close()
+
+			server := func(name, role string) *metalv1alpha1.Server {
+				return &metalv1alpha1.Server{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            name,
+						Labels:          map[string]string{"role": role},
+						ResourceVersion: "1",
+					},
+					Spec: metalv1alpha1.ServerSpec{
+						Accepted: true,
+						ManagementAPI: &metalv1alpha1.ManagementAPI{
+							Endpoint: strings.TrimPrefix(srv.URL, "http://") + "/" + name,
+						},
+					},
+				}
+			}
+
+			c := fake.NewFakeClientWithScheme(scheme,
+				&metalv1alpha1.ServerClass{
+					ObjectMeta: metav1.ObjectMeta{Name: "storage"},
+					Spec: metalv1alpha1.ServerClassSpec{
+						Selector: metav1.LabelSelector{MatchLabels: map[string]string{"role": "storage"}},
+					},
+				},
+				&metalv1alpha1.ServerClass{
+					ObjectMeta: metav1.ObjectMeta{Name: "compute"},
+					Spec: metalv1alpha1.ServerClassSpec{
+						Selector: metav1.LabelSelector{MatchLabels: map[string]string{"role": "compute"}},
+						PowerOnPolicy: &metalv1alpha1.PowerOnPolicy{
+							After:   []metalv1alpha1.PowerOnDependency{{ServerClass: "storage", HealthCondition: "CephHealthy"}},
+							Timeout: tc.timeout,
+						},
+					},
+				},
+				server("storage-1", "storage"),
+				server("compute-1", "compute"),
+			)
+
+			recorder := record.NewFakeRecorder(10)
+
+			r := &controllers.ServerReconciler{
+				Client:    c,
+				Log:       log.NullLogger{},
+				Scheme:    scheme,
+				APIReader: c,
+				Recorder:  recorder,
+
+				RebootTimeout: time.Minute,
+			}
+
+			reconcile := func(name string) *metalv1alpha1.Server {
+				_, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: name}})
+				require.NoError(t, err)
+
+				var s metalv1alpha1.Server
+
+				require.NoError(t, c.Get(ctx, types.NamespacedName{Name: name}, &s))
+
+				return &s
+			}
+
+			compute := reconcile("compute-1")
+			assert.False(t, bmc.poweredOn("compute-1"))
+			assert.True(t, conditions.IsFalse(compute, metalv1alpha1.ConditionPowerOnDependenciesReady))
+			assert.Equal(t, metalv1alpha1.WaitingForDependenciesReason, conditions.GetReason(compute, metalv1alpha1.ConditionPowerOnDependenciesReady))
+			assert.Equal(t, `Waiting for the servers of ServerClass "storage".`, conditions.GetMessage(compute, metalv1alpha1.ConditionPowerOnDependenciesReady))
+
+			storage := reconcile("storage-1")
+			assert.True(t, bmc.poweredOn("storage-1"))
+			assert.Equal(t, "on", storage.Status.Power)
+			assert.Nil(t, conditions.Get(storage, metalv1alpha1.ConditionPowerOnDependenciesReady))
+
+			if tc.timeout == nil {
+				// powered on, but not healthy yet
+				reconcile("compute-1")
+				assert.False(t, bmc.poweredOn("compute-1"))
+
+				patch := client.MergeFrom(storage.DeepCopy())
+				conditions.Set(storage, &clusterv1.Condition{Type: "CephHealthy", Status: corev1.ConditionTrue})
+				require.NoError(t, c.Status().Patch(ctx, storage, patch))
+			}
+
+			compute = reconcile("compute-1")
+			assert.True(t, bmc.poweredOn("compute-1"))
+			assert.True(t, conditions.IsTrue(compute, metalv1alpha1.ConditionPowerOnDependenciesReady))
+			assert.Equal(t, tc.expectedReason, conditions.GetReason(compute, metalv1alpha1.ConditionPowerOnDependenciesReady))
+		})
+	}
+}
+
+func TestServerPowerOnPolicyConditions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+	require.NoError(t, infrav1.AddToScheme(scheme))
+
+	waitingFor := func(serverClass string) *clusterv1.Condition {
+		return &clusterv1.Condition{
+			Type:               metalv1alpha1.ConditionPowerOnDependenciesReady,
+			Status:             corev1.ConditionFalse,
+			Severity:           clusterv1.ConditionSeverityInfo,
+			Reason:             metalv1alpha1.WaitingForDependenciesReason,
+			Message:            `Waiting for the servers of ServerClass "` + serverClass + `".`,
+			LastTransitionTime: metav1.NewTime(time.Now().Add(-time.Hour)),
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		dependency string
+		condition  *clusterv1.Condition
+
+		expectedPoweredOn bool
+		expectedReason    string
+		expectedEvent     string
+	}{
+		"invalid policy": {
+			dependency:     "missing",
+			expectedReason: metalv1alpha1.InvalidPowerOnPolicyReason,
+			expectedEvent:  `Warning Server Power On Order Power on postponed: Invalid power on policy: power on dependency ServerClass "missing" not found.`,
+		},
+		"dependencies changed": {
+			dependency:     "storage",
+			condition:      waitingFor("database"),
+			expectedReason: metalv1alpha1.WaitingForDependenciesReason,
+			expectedEvent:  `Normal Server Power On Order Power on postponed: Waiting for the servers of ServerClass "storage".`,
+		},
+		"timed out": {
+			dependency:        "storage",
+			condition:         waitingFor("storage"),
+			expectedPoweredOn: true,
+			expectedReason:    metalv1alpha1.DependenciesTimedOutReason,
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			bmc := &managementAPI{power: map[string]bool{}}
+
+			srv := httptest.NewServer(bmc)
+			defer srv.Close()
+
+			server := func(name, role string) *metalv1alpha1.Server {
+				return &metalv1alpha1.Server{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:            name,
+						Labels:          map[string]string{"role": role},
+						ResourceVersion: "1",
+					},
+					Spec: metalv1alpha1.ServerSpec{
+						Accepted: true,
+						ManagementAPI: &metalv1alpha1.ManagementAPI{
+							Endpoint: strings.TrimPrefix(srv.URL, "http://") + "/" + name,
+						},
+					},
+				}
+			}
+
+			compute := server("compute-1", "compute")
+			compute.Spec.PowerOnPolicy = &metalv1alpha1.PowerOnPolicy{
+				After:   []metalv1alpha1.PowerOnDependency{{ServerClass: tc.dependency}},
+				Timeout: &metav1.Duration{Duration: 30 * time.Minute},
+			}
+
+			if tc.condition != nil {
+				compute.Status.Conditions = clusterv1.Conditions{*tc.condition}
+			}
+
+			c := fake.NewFakeClientWithScheme(scheme,
+				&metalv1alpha1.ServerClass{
+					ObjectMeta: metav1.ObjectMeta{Name: "storage"},
+					Spec: metalv1alpha1.ServerClassSpec{
+						Selector: metav1.LabelSelector{MatchLabels: map[string]string{"role": "storage"}},
+					},
+				},
+				server("storage-1", "storage"),
+				compute,
+			)
+
+			recorder := record.NewFakeRecorder(10)
+
+			r := &controllers.ServerReconciler{
+				Client:    c,
+				Log:       log.NullLogger{},
+				Scheme:    scheme,
+				APIReader: c,
+				Recorder:  recorder,
+
+				RebootTimeout: time.Minute,
+			}
+
+			_, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: "compute-1"}})
+			require.NoError(t, err)
+
+			var s metalv1alpha1.Server
+
+			require.NoError(t, c.Get(ctx, types.NamespacedName{Name: "compute-1"}, &s))
+
+			assert.Equal(t, tc.expectedPoweredOn, bmc.poweredOn("compute-1"))
+			assert.Equal(t, tc.expectedReason, conditions.GetReason(&s, metalv1alpha1.ConditionPowerOnDependenciesReady))
+
+			if !tc.expectedPoweredOn {
+				// the timeout is counted since the last change
+				assert.WithinDuration(t, time.Now(), conditions.GetLastTransitionTime(&s, metalv1alpha1.ConditionPowerOnDependenciesReady).Time, time.Minute)
+			}
+
+			if tc.expectedEvent != "" {
+				close(recorder.Events)
+
+				var events []string
+
+				for event := range recorder.Events {
+					events = append(events, event)
+				}
+
+				assert.Contains(t, events, tc.expectedEvent)
+			}
+		})
+	}
+}
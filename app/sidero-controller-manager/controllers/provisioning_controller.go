@@ -0,0 +1,127 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/provisioning"
+)
+
+// ProvisioningReconciler publishes the provisioning state transitions of the servers.
+//
+// The state of the servers is kept in memory: servers created before the reconciler started are
+// recorded without publishing the events, as their transitions are not known.
+type ProvisioningReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Broker *provisioning.Broker
+
+	mu      sync.Mutex
+	started time.Time
+	states  map[string]provisioning.State
+}
+
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=servers,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=serverbindings,verbs=get;list;watch
+
+func (r *ProvisioningReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("server", req.NamespacedName)
+
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.states == nil {
+		r.states = map[string]provisioning.State{}
+		r.started = now
+	}
+
+	var s metalv1alpha1.Server
+
+	if err := r.Get(ctx, req.NamespacedName, &s); err != nil {
+		if apierrors.IsNotFound(err) {
+			delete(r.states, req.Name)
+
+			return ctrl.Result{}, nil
+		}
+
+		return ctrl.Result{}, err
+	}
+
+	var binding *infrav1.ServerBinding
+
+	var serverBinding infrav1.ServerBinding
+
+	err := r.Get(ctx, types.NamespacedName{Name: s.Name}, &serverBinding)
+
+	switch {
+	case err == nil:
+		binding = &serverBinding
+	case !apierrors.IsNotFound(err):
+		return ctrl.Result{}, err
+	}
+
+	old, known := r.states[s.Name]
+
+	state := provisioning.NextState(old, &s, binding)
+	r.states[s.Name] = state
+
+	if !known && s.CreationTimestamp.Time.Before(r.started) {
+		return ctrl.Result{}, nil
+	}
+
+	events := provisioning.Transitions(s.Name, old, state)
+
+	for _, event := range events {
+		log.Info("provisioning state changed", "event", event.GetType().String())
+	}
+
+	r.Broker.Publish(now, events...)
+
+	return ctrl.Result{}, nil
+}
+
+func (r *ProvisioningReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	mapRequests := handler.ToRequestsFunc(
+		func(a handler.MapObject) []reconcile.Request {
+			// servers and serverbindings always have matching names
+			return []reconcile.Request{
+				{
+					NamespacedName: types.NamespacedName{
+						Name: a.Meta.GetName(),
+					},
+				},
+			}
+		})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("provisioning").
+		WithOptions(options).
+		For(&metalv1alpha1.Server{}).
+		Watches(
+			&source.Kind{Type: &infrav1.ServerBinding{}},
+			&handler.EnqueueRequestsFromMapFunc{
+				ToRequests: mapRequests,
+			},
+		).
+		Complete(r)
+}
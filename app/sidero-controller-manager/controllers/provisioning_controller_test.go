@@ -0,0 +1,103 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/controllers"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/provisioning"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/provisioning/api"
+)
+
+func TestProvisioningReconcile(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+	require.NoError(t, infrav1.AddToScheme(scheme))
+
+	existing := &metalv1alpha1.Server{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "existing",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-time.Hour)),
+		},
+		Spec: metalv1alpha1.ServerSpec{Accepted: true},
+	}
+
+	registered := &metalv1alpha1.Server{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "registered",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(time.Minute)),
+		},
+		Spec: metalv1alpha1.ServerSpec{Accepted: true},
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme, existing, registered)
+
+	broker := provisioning.NewBroker(0)
+
+	_, sub, err := broker.Subscribe("", 0)
+	require.NoError(t, err)
+
+	defer sub.Cancel()
+
+	r := &controllers.ProvisioningReconciler{
+		Client: c,
+		Log:    log.NullLogger{},
+		Broker: broker,
+	}
+
+	reconcile := func(name string) {
+		_, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: name}})
+		require.NoError(t, err)
+	}
+
+	reconcile("existing")
+	reconcile("registered")
+
+	require.NoError(t, c.Create(ctx, &infrav1.ServerBinding{
+		ObjectMeta: metav1.ObjectMeta{Name: "existing"},
+		Spec: infrav1.ServerBindingSpec{
+			MetalMachineRef: corev1.ObjectReference{Namespace: "default", Name: "worker-1"},
+		},
+	}))
+
+	reconcile("existing")
+
+	require.NoError(t, c.Delete(ctx, existing))
+
+	reconcile("existing")
+
+	var events []string
+
+	for len(sub.Events) > 0 {
+		event := <-sub.Events
+
+		events = append(events, event.GetServer()+" "+event.GetType().String())
+	}
+
+	// state of the server created before the reconciler started is recorded without the events
+	assert.Equal(t, []string{
+		"registered " + api.EventType_ACCEPTED.String(),
+		"existing " + api.EventType_ALLOCATED.String(),
+	}, events)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -20,6 +21,7 @@ import (
 	"k8s.io/client-go/tools/record"
 	"k8s.io/client-go/tools/reference"
 	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/annotations"
 	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -49,16 +51,23 @@ type ServerReconciler struct {
 	Recorder  record.EventRecorder
 
 	RebootTimeout time.Duration
+	// RequiredApprovals enables the approval gate if non-zero.
+	RequiredApprovals int
+	// DryRun reports the power management operations as events instead of executing them.
+	DryRun bool
 }
 
 // +kubebuilder:rbac:groups=metal.sidero.dev,resources=servers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=metal.sidero.dev,resources=servers/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=serverbindings,verbs=get;list;watch
-// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=serverbindings/status,verbs=get
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=serverbindings/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metalmachines,verbs=get;list;watch
 // +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metalmachines/status,verbs=get
-// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=freezes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=serverclasses,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=events,verbs=get;list;create;patch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch;create;delete
 
 func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
@@ -70,6 +79,13 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 
+	// paused servers are not powered on or wiped, e.g. while the management cluster is being restored
+	if annotations.HasPausedAnnotation(&s) {
+		log.Info("reconciliation is paused")
+
+		return ctrl.Result{}, nil
+	}
+
 	patchHelper, err := patch.NewHelper(&s, r)
 	if err != nil {
 		return ctrl.Result{}, err
@@ -88,6 +104,13 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return ctrl.Result{RequeueAfter: constants.DefaultRequeueAfter}, err
 	}
 
+	if r.DryRun {
+		mgmtClient = metal.NewDryRunClient(mgmtClient, func(operation string) {
+			log.Info("dry run: skipping power management operation", "operation", operation)
+			r.Recorder.Event(serverRef, corev1.EventTypeNormal, "Dry Run", fmt.Sprintf("Server would %s.", operation))
+		})
+	}
+
 	s.Status.Power = "off"
 
 	poweredOn, powerErr := mgmtClient.IsPoweredOn()
@@ -103,7 +126,14 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		s.Status.Ready = ready
 
 		if err := patchHelper.Patch(ctx, &s, patch.WithOwnedConditions{
-			Conditions: []clusterv1.ConditionType{metalv1alpha1.ConditionPowerCycle, metalv1alpha1.ConditionPXEBooted},
+			Conditions: []clusterv1.ConditionType{
+				metalv1alpha1.ConditionPowerCycle,
+				metalv1alpha1.ConditionPXEBooted,
+				metalv1alpha1.ConditionPowerOnDependenciesReady,
+				metalv1alpha1.ConditionUnexpectedAgentBoot,
+				metalv1alpha1.ConditionWipeConfirmed,
+				metalv1alpha1.ConditionWipePolicyResolved,
+			},
 		}); err != nil {
 			return result, errors.WithStack(err)
 		}
@@ -111,27 +141,45 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		return result, nil
 	}
 
-	allocated, serverBindingPresent, err := r.checkBinding(ctx, req)
+	allocated, serverBinding, err := r.checkBinding(ctx, req)
 	if err != nil {
 		return ctrl.Result{}, err
 	}
 
+	// server allocated to the canary run is provisioned as any allocated server
+	if !allocated && s.CanaryInstallRequested() {
+		allocated = true
+	}
+
 	if !allocated {
 		if s.Status.InUse {
 			// transitioning to false
 			r.Recorder.Event(serverRef, corev1.EventTypeNormal, "Server Allocation", "Server marked as unallocated.")
+
+			// the data on the disks was left by the allocation, so the wipe is not gated by the confirmation
+			s.Status.Released = true
 		}
 
 		s.Status.InUse = false
 
 		conditions.Delete(&s, metalv1alpha1.ConditionPXEBooted)
+		// released server is wiped, so booting it into the agent is expected again
+		conditions.Delete(&s, metalv1alpha1.ConditionUnexpectedAgentBoot)
 	} else {
 		s.Status.InUse = true
 		s.Status.IsClean = false
+		s.Status.Released = false
 
-		if serverBindingPresent {
+		if serverBinding != nil {
 			// clear any leftover ownerreferences, they were transferred by serverbinding controller
 			s.OwnerReferences = []v1.OwnerReference{}
+
+			// the class is recorded to pick the wipe policy once the server is released, as the binding is gone by then
+			s.Status.ServerClass = ""
+
+			if serverBinding.Spec.ServerClassRef != nil {
+				s.Status.ServerClass = serverBinding.Spec.ServerClassRef.Name
+			}
 		}
 	}
 
@@ -152,6 +200,22 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		}
 	}
 
+	// frozen provisioning plane keeps the status up to date, but servers are not powered on/off or PXE booted
+	freeze, err := metalv1alpha1.ActiveFreeze(ctx, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if freeze != nil {
+		log.Info("provisioning is frozen", "freeze", freeze.Name)
+
+		return f(s.Status.Ready, ctrl.Result{RequeueAfter: constants.DefaultRequeueAfter})
+	}
+
+	if r.RequiredApprovals > 0 {
+		r.reconcileApproval(&s, serverRef)
+	}
+
 	switch {
 	case !s.Spec.Accepted:
 		// if server is not accepted, Sidero doesn't control server lifecycle, so we can't assume that server is (still) clean
@@ -162,7 +226,7 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		log.Error(fmt.Errorf("server cannot be in use and clean"), "server is in an impossible state", "inUse", s.Status.InUse, "isClean", s.Status.IsClean)
 
 		return f(false, ctrl.Result{})
-	case !s.Status.InUse && s.Status.IsClean:
+	case !s.Status.InUse && s.Status.IsClean && !s.ReinventoryRequested():
 		if powerErr != nil {
 			log.Error(powerErr, "failed to check power state")
 			r.Recorder.Event(serverRef, corev1.EventTypeWarning, "Server Management", fmt.Sprintf("Failed to determine power status: %s.", powerErr))
@@ -194,6 +258,15 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 		}
 
 		if !poweredOn {
+			allowed, err := r.powerOnAllowed(ctx, log, &s, serverRef)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+
+			if !allowed {
+				return f(false, ctrl.Result{RequeueAfter: constants.DefaultRequeueAfter})
+			}
+
 			// it's safe to set server to PXE boot even if it's already installed, as PXE server makes sure server is PXE booted only once
 			err = mgmtClient.SetPXE()
 			if err != nil {
@@ -211,13 +284,18 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 				return f(false, ctrl.Result{RequeueAfter: constants.DefaultRequeueAfter})
 			}
 
+			// power state is refreshed on the next reconcile only, while the servers waiting for this one check it now
+			s.Status.Power = "on"
+
 			if !mgmtClient.IsFake() {
 				r.Recorder.Event(serverRef, corev1.EventTypeNormal, "Server Management", "Server powered on and set PXE boot once into the environment.")
 			}
 		}
 
 		return f(true, ctrl.Result{})
-	case !s.Status.InUse && !s.Status.IsClean:
+	case !s.Status.InUse:
+		// server is not clean (or the hardware inventory refresh was requested), so it should be booted into the agent
+		//
 		// when server is set to PXE boot to be wiped, ConditionPowerCycle is set to mark server
 		// as power cycled to avoid duplicate reboot attempts from subsequent Reconciles
 		//
@@ -237,6 +315,17 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			return f(false, ctrl.Result{RequeueAfter: constants.DefaultRequeueAfter})
 		}
 
+		if !poweredOn {
+			allowed, err := r.powerOnAllowed(ctx, log, &s, serverRef)
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+
+			if !allowed {
+				return f(false, ctrl.Result{RequeueAfter: constants.DefaultRequeueAfter})
+			}
+		}
+
 		err = mgmtClient.SetPXE()
 		if err != nil {
 			log.Error(err, "failed to set PXE")
@@ -263,6 +352,8 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			}
 		}
 
+		s.Status.Power = "on"
+
 		if !mgmtClient.IsFake() {
 			if poweredOn {
 				r.Recorder.Event(serverRef, corev1.EventTypeNormal, "Server Management", "Server power cycled and set to PXE boot once.")
@@ -271,7 +362,12 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 			}
 
 			// make sure message is updated in case condition was already set to make sure LastTransitionTime will be updated
-			conditions.MarkFalse(&s, metalv1alpha1.ConditionPowerCycle, "InProgress", clusterv1.ConditionSeverityInfo, fmt.Sprintf("Server power cycled for wiping at %s.", time.Now().Format(time.RFC3339)))
+			purpose := "wiping"
+			if s.Status.IsClean {
+				purpose = "re-inventory"
+			}
+
+			conditions.MarkFalse(&s, metalv1alpha1.ConditionPowerCycle, "InProgress", clusterv1.ConditionSeverityInfo, fmt.Sprintf("Server power cycled for %s at %s.", purpose, time.Now().Format(time.RFC3339)))
 		}
 
 		// requeue to check for wipe timeout
@@ -281,23 +377,23 @@ func (r *ServerReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	return f(false, ctrl.Result{})
 }
 
-func (r *ServerReconciler) checkBinding(ctx context.Context, req ctrl.Request) (allocated, serverBindingPresent bool, err error) {
-	var serverBinding infrav1.ServerBinding
+func (r *ServerReconciler) checkBinding(ctx context.Context, req ctrl.Request) (allocated bool, serverBinding *infrav1.ServerBinding, err error) {
+	serverBinding = &infrav1.ServerBinding{}
 
-	err = r.Get(ctx, req.NamespacedName, &serverBinding)
+	err = r.Get(ctx, req.NamespacedName, serverBinding)
 	if err == nil {
-		return true, true, nil
+		return true, serverBinding, nil
 	}
 
 	if err != nil && !apierrors.IsNotFound(err) {
-		return false, false, err
+		return false, nil, err
 	}
 
 	// double-check metalmachines to make sure we don't have a missing serverbinding
 	var metalMachineList infrav1.MetalMachineList
 
 	if err := r.List(ctx, &metalMachineList, client.MatchingFields(fields.Set{infrav1.MetalMachineServerRefField: req.Name})); err != nil {
-		return false, false, err
+		return false, nil, err
 	}
 
 	for _, metalMachine := range metalMachineList.Items {
@@ -307,12 +403,12 @@ func (r *ServerReconciler) checkBinding(ctx context.Context, req ctrl.Request) (
 
 		if metalMachine.Spec.ServerRef != nil {
 			if metalMachine.Spec.ServerRef.Namespace == req.Namespace && metalMachine.Spec.ServerRef.Name == req.Name {
-				return true, false, nil
+				return true, nil, nil
 			}
 		}
 	}
 
-	return false, false, nil
+	return false, nil, nil
 }
 
 func (r *ServerReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
@@ -352,3 +448,42 @@ func (r *ServerReconciler) SetupWithManager(mgr ctrl.Manager, options controller
 		).
 		Complete(r)
 }
+
+// reconcileApproval keeps the servers which didn't pass the approval gate not accepted.
+//
+// Servers are approved (and accepted) with the ApproveAction resources. Servers which were accepted and used
+// (allocated or ready) before Sidero required approvals are grandfathered on the first reconcile, so that
+// enabling the gate doesn't disrupt them, while the servers which were only wiped need the approvals.
+// Servers accepted without passing the approval gate are reverted back to not accepted.
+//
+// The revert is best effort (e.g. it's skipped during a freeze), the agent API and the server classes
+// check the approval themselves.
+func (r *ServerReconciler) reconcileApproval(s *metalv1alpha1.Server, serverRef *corev1.ObjectReference) {
+	if s.Status.Approval == nil && s.Spec.Accepted && (s.Status.InUse || s.Status.Ready) {
+		now := metav1.Now()
+
+		s.Status.Approval = &metalv1alpha1.ApprovalStatus{
+			State:         metalv1alpha1.ApprovalStateApproved,
+			Required:      r.RequiredApprovals,
+			ApprovedAt:    &now,
+			Grandfathered: true,
+		}
+
+		r.Recorder.Event(serverRef, corev1.EventTypeNormal, "Server Approval", "Server was accepted before approvals were required, approval grandfathered.")
+
+		return
+	}
+
+	if s.Status.Approval == nil {
+		s.Status.Approval = &metalv1alpha1.ApprovalStatus{
+			State:    metalv1alpha1.ApprovalStatePending,
+			Required: r.RequiredApprovals,
+		}
+	}
+
+	if !s.Status.Approval.Approved() && s.Spec.Accepted {
+		s.Spec.Accepted = false
+
+		r.Recorder.Event(serverRef, corev1.EventTypeWarning, "Server Approval", fmt.Sprintf("Server can't be accepted without %d approvals, acceptance reverted.", r.RequiredApprovals))
+	}
+}
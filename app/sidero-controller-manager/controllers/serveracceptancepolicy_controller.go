@@ -0,0 +1,235 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/tools/reference"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// ServerAcceptancePolicyReconciler reconciles a ServerAcceptancePolicy object.
+type ServerAcceptancePolicyReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// RequiredApprovals enables the approval gate, policies only record the matching servers then.
+	RequiredApprovals int
+}
+
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=serveracceptancepolicies,verbs=get;list;watch
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=serveracceptancepolicies/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=servers,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+
+func (r *ServerAcceptancePolicyReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+
+	l := r.Log.WithValues("serveracceptancepolicy", req.NamespacedName)
+	l.Info("reconciling")
+
+	var policy metalv1alpha1.ServerAcceptancePolicy
+
+	if err := r.Get(ctx, req.NamespacedName, &policy); err != nil {
+		if !apierrors.IsNotFound(err) {
+			l.Error(err, "failed fetching resource")
+		}
+
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	patchHelper, err := patch.NewHelper(&policy, r)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if err = policy.Validate(); err != nil {
+		l.Error(err, "invalid policy")
+
+		conditions.MarkFalse(&policy, metalv1alpha1.ConditionPolicyValid, "InvalidPolicy", clusterv1.ConditionSeverityError, "%s", err)
+	} else {
+		conditions.MarkTrue(&policy, metalv1alpha1.ConditionPolicyValid)
+
+		if err = r.reconcileServers(ctx, l, &policy); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	if err = patchHelper.Patch(ctx, &policy, patch.WithOwnedConditions{
+		Conditions: []clusterv1.ConditionType{metalv1alpha1.ConditionPolicyValid},
+	}); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// reconcileServers accepts the matching servers which were not accepted yet, and updates the policy status.
+//
+// Only servers which were never accepted by a policy are considered, so manual un-accept of a server is not overridden.
+// Servers registered before the policy was created are left to the operator, as they might be kept unaccepted deliberately.
+func (r *ServerAcceptancePolicyReconciler) reconcileServers(ctx context.Context, l logr.Logger, policy *metalv1alpha1.ServerAcceptancePolicy) error {
+	var servers metalv1alpha1.ServerList
+
+	if err := r.List(ctx, &servers); err != nil {
+		return fmt.Errorf("unable to get servers: %w", err)
+	}
+
+	dryRun := policy.Spec.DryRun || r.RequiredApprovals > 0
+
+	previousMatches := make(map[string]struct{}, len(policy.Status.DryRunMatches))
+
+	for _, name := range policy.Status.DryRunMatches {
+		previousMatches[name] = struct{}{}
+	}
+
+	accepted := []string{}
+	matches := []string{}
+
+	for i := range servers.Items {
+		server := &servers.Items[i]
+
+		if server.Annotations[metalv1alpha1.ServerAcceptedByPolicyAnnotation] == policy.Name {
+			accepted = append(accepted, server.Name)
+
+			continue
+		}
+
+		if server.Spec.Accepted || !server.DeletionTimestamp.IsZero() {
+			continue
+		}
+
+		if _, ok := server.Annotations[metalv1alpha1.ServerAcceptedByPolicyAnnotation]; ok {
+			continue
+		}
+
+		if server.CreationTimestamp.Before(&policy.CreationTimestamp) {
+			continue
+		}
+
+		if !policy.Matches(server) {
+			continue
+		}
+
+		serverRef, err := reference.GetReference(r.Scheme, server)
+		if err != nil {
+			return err
+		}
+
+		if dryRun {
+			matches = append(matches, server.Name)
+
+			if _, ok := previousMatches[server.Name]; ok {
+				continue
+			}
+
+			reason := "dry run"
+			if !policy.Spec.DryRun {
+				reason = "approval gate is enabled"
+			}
+
+			r.Recorder.Event(serverRef, corev1.EventTypeNormal, "Server Acceptance", fmt.Sprintf("Server matches policy %q, not accepted: %s.", policy.Name, reason))
+
+			continue
+		}
+
+		if err = r.accept(ctx, server, policy.Name); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+
+			return err
+		}
+
+		l.Info("server accepted", "server", server.Name)
+
+		r.Recorder.Event(serverRef, corev1.EventTypeNormal, "Server Acceptance", fmt.Sprintf("Server auto-accepted by policy %q.", policy.Name))
+
+		accepted = append(accepted, server.Name)
+	}
+
+	sort.Strings(accepted)
+	sort.Strings(matches)
+
+	policy.Status.AcceptedServers = accepted
+	policy.Status.DryRunMatches = matches
+
+	return nil
+}
+
+func (r *ServerAcceptancePolicyReconciler) accept(ctx context.Context, server *metalv1alpha1.Server, policyName string) error {
+	patchHelper, err := patch.NewHelper(server, r)
+	if err != nil {
+		return err
+	}
+
+	if server.Annotations == nil {
+		server.Annotations = map[string]string{}
+	}
+
+	server.Annotations[metalv1alpha1.ServerAcceptedByPolicyAnnotation] = policyName
+	server.Spec.Accepted = true
+
+	return patchHelper.Patch(ctx, server)
+}
+
+func (r *ServerAcceptancePolicyReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	// Servers are evaluated against all the policies on changes, as the inventory is reported after the registration.
+	mapRequests := handler.ToRequestsFunc(
+		func(a handler.MapObject) []reconcile.Request {
+			reqList := []reconcile.Request{}
+
+			policyList := &metalv1alpha1.ServerAcceptancePolicyList{}
+
+			if err := r.List(context.Background(), policyList); err != nil {
+				return reqList
+			}
+
+			for _, policy := range policyList.Items {
+				reqList = append(
+					reqList,
+					reconcile.Request{
+						NamespacedName: types.NamespacedName{
+							Name: policy.Name,
+						},
+					},
+				)
+			}
+
+			return reqList
+		})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(options).
+		For(&metalv1alpha1.ServerAcceptancePolicy{}).
+		Watches(
+			&source.Kind{Type: &metalv1alpha1.Server{}},
+			&handler.EnqueueRequestsFromMapFunc{
+				ToRequests: mapRequests,
+			},
+		).
+		Complete(r)
+}
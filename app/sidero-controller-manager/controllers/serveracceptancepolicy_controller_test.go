@@ -0,0 +1,171 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/controllers"
+)
+
+func TestServerAcceptancePolicyReconcile(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+
+	policyCreated := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+
+	newServer := func(name, address string, accepted bool, annotations map[string]string) *metalv1alpha1.Server {
+		server := &metalv1alpha1.Server{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				CreationTimestamp: metav1.NewTime(policyCreated.Add(time.Minute)),
+				Annotations:       map[string]string{metalv1alpha1.ServerRegistrationAddressAnnotation: address},
+			},
+			Spec: metalv1alpha1.ServerSpec{Accepted: accepted},
+		}
+
+		for k, v := range annotations {
+			server.Annotations[k] = v
+		}
+
+		return server
+	}
+
+	for name, tc := range map[string]struct {
+		dryRun            bool
+		requiredApprovals int
+		subnets           []string
+
+		expectedAccepted []string
+		expectedMatches  []string
+		expectedEvents   int
+		expectedValid    bool
+	}{
+		"accept": {
+			subnets:          []string{"172.24.0.0/24"},
+			expectedAccepted: []string{"matching", "previously-accepted"},
+			expectedEvents:   1,
+			expectedValid:    true,
+		},
+		"dry run": {
+			dryRun:           true,
+			subnets:          []string{"172.24.0.0/24"},
+			expectedAccepted: []string{"previously-accepted"},
+			expectedMatches:  []string{"matching"},
+			expectedEvents:   1,
+			expectedValid:    true,
+		},
+		"approval gate": {
+			requiredApprovals: 2,
+			subnets:           []string{"172.24.0.0/24"},
+			expectedAccepted:  []string{"previously-accepted"},
+			expectedMatches:   []string{"matching"},
+			expectedEvents:    1,
+			expectedValid:     true,
+		},
+		"invalid": {
+			subnets: []string{"172.24.0.0"},
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			policy := &metalv1alpha1.ServerAcceptancePolicy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              "rack-1",
+					CreationTimestamp: policyCreated,
+					// conditions are patched with the optimistic lock
+					ResourceVersion: "1",
+				},
+				Spec: metalv1alpha1.ServerAcceptancePolicySpec{
+					Subnets: tc.subnets,
+					DryRun:  tc.dryRun,
+				},
+			}
+
+			// registered before the policy was created, kept unaccepted by the operator
+			preExisting := newServer("pre-existing", "172.24.0.14", false, nil)
+			preExisting.CreationTimestamp = metav1.NewTime(policyCreated.Add(-time.Minute))
+
+			recorder := record.NewFakeRecorder(10)
+
+			r := &controllers.ServerAcceptancePolicyReconciler{
+				Client: fake.NewFakeClientWithScheme(scheme,
+					policy,
+					newServer("matching", "172.24.0.10", false, nil),
+					newServer("other-subnet", "172.25.0.10", false, nil),
+					newServer("accepted", "172.24.0.11", true, nil),
+					// un-accepted manually after the policy accepted it
+					newServer("previously-accepted", "172.24.0.12", false, map[string]string{metalv1alpha1.ServerAcceptedByPolicyAnnotation: "rack-1"}),
+					newServer("other-policy", "172.24.0.13", false, map[string]string{metalv1alpha1.ServerAcceptedByPolicyAnnotation: "rack-2"}),
+					preExisting,
+				),
+				Log:      log.NullLogger{},
+				Scheme:   scheme,
+				Recorder: recorder,
+
+				RequiredApprovals: tc.requiredApprovals,
+			}
+
+			for i := 0; i < 2; i++ {
+				_, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: policy.Name}})
+				require.NoError(t, err)
+			}
+
+			require.NoError(t, r.Get(ctx, types.NamespacedName{Name: policy.Name}, policy))
+
+			assert.Equal(t, tc.expectedValid, conditions.IsTrue(policy, metalv1alpha1.ConditionPolicyValid))
+
+			if tc.expectedAccepted != nil {
+				assert.Equal(t, tc.expectedAccepted, policy.Status.AcceptedServers)
+			} else {
+				assert.Empty(t, policy.Status.AcceptedServers)
+			}
+
+			if tc.expectedMatches != nil {
+				assert.Equal(t, tc.expectedMatches, policy.Status.DryRunMatches)
+			} else {
+				assert.Empty(t, policy.Status.DryRunMatches)
+			}
+
+			// decisions are recorded once
+			assert.Len(t, recorder.Events, tc.expectedEvents)
+
+			for _, name := range []string{"matching", "other-subnet", "previously-accepted", "other-policy", "pre-existing"} {
+				var server metalv1alpha1.Server
+
+				require.NoError(t, r.Get(ctx, types.NamespacedName{Name: name}, &server))
+
+				expected := name == "matching" && tc.expectedValid && !tc.dryRun && tc.requiredApprovals == 0
+
+				assert.Equal(t, expected, server.Spec.Accepted, name)
+
+				if expected {
+					assert.Equal(t, "rack-1", server.Annotations[metalv1alpha1.ServerAcceptedByPolicyAnnotation])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,461 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/tools/reference"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/power/metal"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
+)
+
+// errActionPending postpones the action, e.g. while the provisioning plane is frozen.
+var errActionPending = errors.New("action is pending")
+
+// actionFunc takes the action on the server, it returns the message of the succeeded action.
+//
+// The errors wrapping errActionPending postpone the action, other errors fail it.
+type actionFunc func(ctx context.Context, log logr.Logger, action metalv1alpha1.ServerAction, server *metalv1alpha1.Server, serverRef *corev1.ObjectReference) (string, error)
+
+// ServerActionReconciler takes the actions requested with the AcceptAction, ApproveAction, PowerAction, WipeAction and ReleaseAction resources.
+type ServerActionReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	// RequiredApprovals enables the approval gate if non-zero, servers are accepted with the ApproveAction instead of the AcceptAction then.
+	RequiredApprovals int
+	// DryRun reports the power management operations as events instead of executing them.
+	DryRun bool
+}
+
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=acceptactions;approveactions;poweractions;wipeactions;releaseactions,verbs=get;list;watch
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=acceptactions/status;approveactions/status;poweractions/status;wipeactions/status;releaseactions/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=servers,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=servers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=freezes,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=serverbindings,verbs=get;list;watch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=metalmachines,verbs=get;list;watch;delete
+// +kubebuilder:rbac:groups=cluster.x-k8s.io,resources=machines,verbs=get;list;watch;delete
+// +kubebuilder:rbac:groups="",resources=events,verbs=get;list;create;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+func (r *ServerActionReconciler) reconcile(req ctrl.Request, action metalv1alpha1.ServerAction, takeAction actionFunc) (ctrl.Result, error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("action", req.Name)
+
+	if err := r.Get(ctx, req.NamespacedName, action); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	status := action.ActionStatus()
+
+	// actions are taken once, the succeeded and failed actions are kept as the audit trail
+	if status.Done() {
+		return ctrl.Result{}, nil
+	}
+
+	patchHelper, err := patch.NewHelper(action, r)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var server metalv1alpha1.Server
+
+	message, err := func() (string, error) {
+		if err := r.Get(ctx, types.NamespacedName{Name: action.ActionSpec().ServerRef}, &server); err != nil {
+			if apierrors.IsNotFound(err) {
+				return "", fmt.Errorf("server %q not found", action.ActionSpec().ServerRef)
+			}
+
+			return "", err
+		}
+
+		serverRef, err := reference.GetReference(r.Scheme, &server)
+		if err != nil {
+			return "", err
+		}
+
+		return takeAction(ctx, log, action, &server, serverRef)
+	}()
+
+	result := ctrl.Result{}
+
+	switch {
+	case errors.Is(err, errActionPending):
+		status.Phase = metalv1alpha1.ActionPhasePending
+		status.Message = err.Error()
+
+		result.RequeueAfter = constants.DefaultRequeueAfter
+	case err != nil:
+		log.Error(err, "action failed")
+
+		now := metav1.Now()
+
+		status.Phase = metalv1alpha1.ActionPhaseFailed
+		status.Message = err.Error()
+		status.CompletionTime = &now
+	default:
+		log.Info("action succeeded", "server", server.Name, "message", message)
+
+		now := metav1.Now()
+
+		status.Phase = metalv1alpha1.ActionPhaseSucceeded
+		status.Message = message
+		status.CompletionTime = &now
+	}
+
+	if err = patchHelper.Patch(ctx, action); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return result, nil
+}
+
+// event records the action in the events of the server.
+func (r *ServerActionReconciler) event(action metalv1alpha1.ServerAction, serverRef *corev1.ObjectReference, message string) {
+	if reason := action.ActionSpec().Reason; reason != "" {
+		message = fmt.Sprintf("%s: %s", message, reason)
+	}
+
+	gvk, err := apiutil.GVKForObject(action, r.Scheme)
+	if err != nil {
+		gvk.Kind = "action"
+	}
+
+	r.Recorder.Event(serverRef, corev1.EventTypeNormal, "Server Action", fmt.Sprintf("%s (%s %q).", message, gvk.Kind, action.GetName()))
+}
+
+func (r *ServerActionReconciler) accept(ctx context.Context, log logr.Logger, action metalv1alpha1.ServerAction, server *metalv1alpha1.Server, serverRef *corev1.ObjectReference) (string, error) {
+	if r.RequiredApprovals > 0 {
+		return "", fmt.Errorf("servers are accepted once they get %d approvals with the ApproveAction", r.RequiredApprovals)
+	}
+
+	if server.Spec.Accepted {
+		return "server is already accepted", nil
+	}
+
+	patchHelper, err := patch.NewHelper(server, r)
+	if err != nil {
+		return "", err
+	}
+
+	server.Spec.Accepted = true
+
+	if err = patchHelper.Patch(ctx, server); err != nil {
+		return "", err
+	}
+
+	r.event(action, serverRef, "Server accepted")
+
+	return "server accepted", nil
+}
+
+func (r *ServerActionReconciler) approve(ctx context.Context, log logr.Logger, action metalv1alpha1.ServerAction, server *metalv1alpha1.Server, serverRef *corev1.ObjectReference) (string, error) {
+	if r.RequiredApprovals == 0 {
+		return "", fmt.Errorf("approvals are not required")
+	}
+
+	approval := metalv1alpha1.ServerApproval{
+		Approver: action.(*metalv1alpha1.ApproveAction).Approver(),
+		Comment:  action.ActionSpec().Reason,
+	}
+
+	wasApproved := server.Status.Approval.Approved()
+
+	patchHelper, err := patch.NewHelper(server, r)
+	if err != nil {
+		return "", err
+	}
+
+	if err = server.RecordApproval(approval, action.GetName(), r.RequiredApprovals, time.Now()); err != nil {
+		return "", err
+	}
+
+	approved := !wasApproved && server.Status.Approval.Approved()
+
+	if approved {
+		server.Spec.Accepted = true
+	}
+
+	if err = patchHelper.Patch(ctx, server); err != nil {
+		return "", err
+	}
+
+	r.event(action, serverRef, fmt.Sprintf("Server approved by %q (%d/%d)", approval.Approver, len(server.Status.Approval.Approvals), r.RequiredApprovals))
+
+	if approved {
+		r.Recorder.Event(serverRef, corev1.EventTypeNormal, "Server Approval", "Server accepted after approval.")
+
+		return "server approved and accepted", nil
+	}
+
+	return fmt.Sprintf("server approved (%d/%d)", len(server.Status.Approval.Approvals), r.RequiredApprovals), nil
+}
+
+func (r *ServerActionReconciler) power(ctx context.Context, log logr.Logger, action metalv1alpha1.ServerAction, server *metalv1alpha1.Server, serverRef *corev1.ObjectReference) (string, error) {
+	operation := action.(*metalv1alpha1.PowerAction).Spec.Operation
+
+	if err := r.checkFreeze(ctx); err != nil {
+		return "", err
+	}
+
+	mgmtClient, err := metal.NewManagementClient(ctx, r.Client, &server.Spec)
+	if err != nil {
+		return "", fmt.Errorf("failed to initialize management client: %w", err)
+	}
+
+	if mgmtClient.IsFake() {
+		return "", fmt.Errorf("server has no BMC or management API")
+	}
+
+	if r.DryRun {
+		mgmtClient = metal.NewDryRunClient(mgmtClient, func(operation string) {
+			log.Info("dry run: skipping power management operation", "operation", operation)
+			r.Recorder.Event(serverRef, corev1.EventTypeNormal, "Dry Run", fmt.Sprintf("Server would %s.", operation))
+		})
+	}
+
+	patchHelper, err := patch.NewHelper(server, r)
+	if err != nil {
+		return "", err
+	}
+
+	// the hold is set before the server is powered off, so that Sidero doesn't power it back on in between
+	if operation == metalv1alpha1.PowerOff {
+		if server.Annotations == nil {
+			server.Annotations = map[string]string{}
+		}
+
+		server.Annotations[metalv1alpha1.ServerPowerHoldAnnotation] = action.GetName()
+	} else {
+		delete(server.Annotations, metalv1alpha1.ServerPowerHoldAnnotation)
+	}
+
+	if err = patchHelper.Patch(ctx, server); err != nil {
+		return "", err
+	}
+
+	var message string
+
+	switch operation {
+	case metalv1alpha1.PowerOn:
+		err = mgmtClient.PowerOn()
+		message = "Server powered on"
+	case metalv1alpha1.PowerOff:
+		err = mgmtClient.PowerOff()
+		message = "Server powered off"
+	case metalv1alpha1.PowerCycle:
+		err = mgmtClient.PowerCycle()
+		message = "Server power cycled"
+	default:
+		return "", fmt.Errorf("unsupported power operation %q", operation)
+	}
+
+	if err != nil {
+		r.Recorder.Event(serverRef, corev1.EventTypeWarning, "Server Action", fmt.Sprintf("Failed to power %s: %s.", operation, err))
+
+		return "", fmt.Errorf("failed to power %s: %w", operation, err)
+	}
+
+	r.event(action, serverRef, message)
+
+	return fmt.Sprintf("power %s", operation), nil
+}
+
+func (r *ServerActionReconciler) wipe(ctx context.Context, log logr.Logger, action metalv1alpha1.ServerAction, server *metalv1alpha1.Server, serverRef *corev1.ObjectReference) (string, error) {
+	if !server.Spec.Accepted {
+		return "", fmt.Errorf("server is not accepted")
+	}
+
+	if err := r.checkFreeze(ctx); err != nil {
+		return "", err
+	}
+
+	allocated, err := r.allocated(ctx, server)
+	if err != nil {
+		return "", err
+	}
+
+	if allocated {
+		return "", fmt.Errorf("server is allocated, allocated servers are wiped when released")
+	}
+
+	// the annotation and the status are patched together, as the patch doesn't update the resource version of the server
+	patchHelper, err := patch.NewHelper(server, r)
+	if err != nil {
+		return "", err
+	}
+
+	if action.(*metalv1alpha1.WipeAction).Spec.Confirm && !server.WipeConfirmed(nil) {
+		if server.Annotations == nil {
+			server.Annotations = map[string]string{}
+		}
+
+		server.Annotations[metalv1alpha1.ServerConfirmWipeAnnotation] = ""
+	}
+
+	server.Status.IsClean = false
+
+	if err = patchHelper.Patch(ctx, server); err != nil {
+		return "", err
+	}
+
+	r.event(action, serverRef, "Server marked for wipe")
+
+	return "server marked for wipe", nil
+}
+
+func (r *ServerActionReconciler) release(ctx context.Context, log logr.Logger, action metalv1alpha1.ServerAction, server *metalv1alpha1.Server, serverRef *corev1.ObjectReference) (string, error) {
+	if err := r.checkFreeze(ctx); err != nil {
+		return "", err
+	}
+
+	var serverBinding infrav1.ServerBinding
+
+	if err := r.Get(ctx, types.NamespacedName{Name: server.Name}, &serverBinding); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", fmt.Errorf("server is not allocated")
+		}
+
+		return "", err
+	}
+
+	var metalMachine infrav1.MetalMachine
+
+	if err := r.Get(ctx, types.NamespacedName{Namespace: serverBinding.Spec.MetalMachineRef.Namespace, Name: serverBinding.Spec.MetalMachineRef.Name}, &metalMachine); err != nil {
+		if apierrors.IsNotFound(err) {
+			return "", fmt.Errorf("metal machine %s/%s not found", serverBinding.Spec.MetalMachineRef.Namespace, serverBinding.Spec.MetalMachineRef.Name)
+		}
+
+		return "", err
+	}
+
+	// Machine is deleted, so that the Cluster API lifecycle (drain, replacement) is respected
+	var (
+		obj  runtime.Object = &metalMachine
+		kind                = "MetalMachine"
+		name                = metalMachine.Name
+	)
+
+	if ref := metalMachine.OwnerMachineRef(); ref != nil {
+		obj = &clusterv1.Machine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: metalMachine.Namespace,
+				Name:      ref.Name,
+			},
+		}
+		kind, name = "Machine", ref.Name
+	}
+
+	if err := r.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+		return "", err
+	}
+
+	message := fmt.Sprintf("deleted %s %s/%s", kind, metalMachine.Namespace, name)
+
+	r.event(action, serverRef, fmt.Sprintf("Server released, %s", message))
+
+	return message, nil
+}
+
+// checkFreeze postpones the action while the provisioning plane is frozen.
+func (r *ServerActionReconciler) checkFreeze(ctx context.Context) error {
+	freeze, err := metalv1alpha1.ActiveFreeze(ctx, r.Client)
+	if err != nil {
+		return err
+	}
+
+	if freeze != nil {
+		return fmt.Errorf("%w: provisioning is frozen by %q", errActionPending, freeze.Name)
+	}
+
+	return nil
+}
+
+// allocated checks whether the server is allocated to a MetalMachine.
+func (r *ServerActionReconciler) allocated(ctx context.Context, server *metalv1alpha1.Server) (bool, error) {
+	if server.Status.InUse {
+		return true, nil
+	}
+
+	var serverBinding infrav1.ServerBinding
+
+	err := r.Get(ctx, types.NamespacedName{Name: server.Name}, &serverBinding)
+	if err == nil {
+		return true, nil
+	}
+
+	return false, client.IgnoreNotFound(err)
+}
+
+// ReconcileAcceptAction accepts the server.
+func (r *ServerActionReconciler) ReconcileAcceptAction(req ctrl.Request) (ctrl.Result, error) {
+	return r.reconcile(req, &metalv1alpha1.AcceptAction{}, r.accept)
+}
+
+// ReconcileApproveAction approves the server.
+func (r *ServerActionReconciler) ReconcileApproveAction(req ctrl.Request) (ctrl.Result, error) {
+	return r.reconcile(req, &metalv1alpha1.ApproveAction{}, r.approve)
+}
+
+// ReconcilePowerAction powers the server on, off or cycles its power.
+func (r *ServerActionReconciler) ReconcilePowerAction(req ctrl.Request) (ctrl.Result, error) {
+	return r.reconcile(req, &metalv1alpha1.PowerAction{}, r.power)
+}
+
+// ReconcileWipeAction marks the server for wipe.
+func (r *ServerActionReconciler) ReconcileWipeAction(req ctrl.Request) (ctrl.Result, error) {
+	return r.reconcile(req, &metalv1alpha1.WipeAction{}, r.wipe)
+}
+
+// ReconcileReleaseAction releases the server.
+func (r *ServerActionReconciler) ReconcileReleaseAction(req ctrl.Request) (ctrl.Result, error) {
+	return r.reconcile(req, &metalv1alpha1.ReleaseAction{}, r.release)
+}
+
+func (r *ServerActionReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	for _, kind := range []struct {
+		obj        runtime.Object
+		reconciler reconcile.Func
+	}{
+		{&metalv1alpha1.AcceptAction{}, r.ReconcileAcceptAction},
+		{&metalv1alpha1.ApproveAction{}, r.ReconcileApproveAction},
+		{&metalv1alpha1.PowerAction{}, r.ReconcilePowerAction},
+		{&metalv1alpha1.WipeAction{}, r.ReconcileWipeAction},
+		{&metalv1alpha1.ReleaseAction{}, r.ReconcileReleaseAction},
+	} {
+		if err := ctrl.NewControllerManagedBy(mgr).
+			WithOptions(options).
+			For(kind.obj).
+			Complete(kind.reconciler); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,487 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/controllers"
+)
+
+func TestServerActions(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+	require.NoError(t, infrav1.AddToScheme(scheme))
+	require.NoError(t, clusterv1.AddToScheme(scheme))
+
+	spec := metalv1alpha1.ServerActionSpec{ServerRef: "server-1", Reason: "maintenance"}
+
+	approveAction := func(approver string) *metalv1alpha1.ApproveAction {
+		action := &metalv1alpha1.ApproveAction{Spec: spec}
+
+		if approver != "" {
+			action.Annotations = map[string]string{metalv1alpha1.ApproveActionApproverAnnotation: approver}
+		}
+
+		return action
+	}
+
+	approvedBy := func(approver string) *metalv1alpha1.ApprovalStatus {
+		return &metalv1alpha1.ApprovalStatus{
+			State:    metalv1alpha1.ApprovalStatePending,
+			Required: 2,
+			Approvals: []metalv1alpha1.RecordedApproval{
+				{ServerApproval: metalv1alpha1.ServerApproval{Approver: approver}, Action: "action-0"},
+			},
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		action            runtime.Object
+		requiredApprovals int
+		approval          *metalv1alpha1.ApprovalStatus
+		accepted          bool
+		frozen            bool
+		allocated         bool
+		missingServer     bool
+
+		expectedPhase   string
+		expectedMessage string
+		check           func(t *testing.T, c client.Client, server *metalv1alpha1.Server)
+	}{
+		"accept": {
+			action:        &metalv1alpha1.AcceptAction{Spec: spec},
+			expectedPhase: metalv1alpha1.ActionPhaseSucceeded,
+			check: func(t *testing.T, c client.Client, server *metalv1alpha1.Server) {
+				assert.True(t, server.Spec.Accepted)
+			},
+		},
+		"accept with approval gate": {
+			action:            &metalv1alpha1.AcceptAction{Spec: spec},
+			requiredApprovals: 2,
+			expectedPhase:     metalv1alpha1.ActionPhaseFailed,
+			expectedMessage:   "servers are accepted once they get 2 approvals with the ApproveAction",
+			check: func(t *testing.T, c client.Client, server *metalv1alpha1.Server) {
+				assert.False(t, server.Spec.Accepted)
+			},
+		},
+		"approve": {
+			action:            approveAction("alice"),
+			requiredApprovals: 2,
+			expectedPhase:     metalv1alpha1.ActionPhaseSucceeded,
+			expectedMessage:   "server approved (1/2)",
+			check: func(t *testing.T, c client.Client, server *metalv1alpha1.Server) {
+				assert.False(t, server.Spec.Accepted)
+				require.NotNil(t, server.Status.Approval)
+				require.Len(t, server.Status.Approval.Approvals, 1)
+				assert.Equal(t, "alice", server.Status.Approval.Approvals[0].Approver)
+				assert.Equal(t, "maintenance", server.Status.Approval.Approvals[0].Comment)
+				assert.Equal(t, "action-1", server.Status.Approval.Approvals[0].Action)
+			},
+		},
+		"approve and accept": {
+			action:            approveAction("bob"),
+			requiredApprovals: 2,
+			approval:          approvedBy("alice"),
+			expectedPhase:     metalv1alpha1.ActionPhaseSucceeded,
+			expectedMessage:   "server approved and accepted",
+			check: func(t *testing.T, c client.Client, server *metalv1alpha1.Server) {
+				assert.True(t, server.Spec.Accepted)
+				assert.True(t, server.Status.Approval.Approved())
+			},
+		},
+		"approve twice": {
+			action:            approveAction("alice"),
+			requiredApprovals: 2,
+			approval:          approvedBy("alice"),
+			expectedPhase:     metalv1alpha1.ActionPhaseFailed,
+			expectedMessage:   `server is already approved by "alice"`,
+			check: func(t *testing.T, c client.Client, server *metalv1alpha1.Server) {
+				assert.False(t, server.Spec.Accepted)
+				assert.Len(t, server.Status.Approval.Approvals, 1)
+			},
+		},
+		"approve without approver": {
+			action:            approveAction(""),
+			requiredApprovals: 2,
+			expectedPhase:     metalv1alpha1.ActionPhaseFailed,
+			expectedMessage:   "approver is not recorded",
+		},
+		"approve without approval gate": {
+			action:          approveAction("alice"),
+			expectedPhase:   metalv1alpha1.ActionPhaseFailed,
+			expectedMessage: "approvals are not required",
+		},
+		"missing server": {
+			action:          &metalv1alpha1.AcceptAction{Spec: spec},
+			missingServer:   true,
+			expectedPhase:   metalv1alpha1.ActionPhaseFailed,
+			expectedMessage: `server "server-1" not found`,
+		},
+		"wipe": {
+			action:        &metalv1alpha1.WipeAction{Spec: metalv1alpha1.WipeActionSpec{ServerActionSpec: spec, Confirm: true}},
+			accepted:      true,
+			expectedPhase: metalv1alpha1.ActionPhaseSucceeded,
+			check: func(t *testing.T, c client.Client, server *metalv1alpha1.Server) {
+				assert.False(t, server.Status.IsClean)
+				assert.Contains(t, server.Annotations, metalv1alpha1.ServerConfirmWipeAnnotation)
+			},
+		},
+		"wipe allocated": {
+			action:          &metalv1alpha1.WipeAction{Spec: metalv1alpha1.WipeActionSpec{ServerActionSpec: spec}},
+			accepted:        true,
+			allocated:       true,
+			expectedPhase:   metalv1alpha1.ActionPhaseFailed,
+			expectedMessage: "server is allocated, allocated servers are wiped when released",
+			check: func(t *testing.T, c client.Client, server *metalv1alpha1.Server) {
+				assert.True(t, server.Status.IsClean)
+			},
+		},
+		"release": {
+			action:          &metalv1alpha1.ReleaseAction{Spec: spec},
+			accepted:        true,
+			allocated:       true,
+			expectedPhase:   metalv1alpha1.ActionPhaseSucceeded,
+			expectedMessage: "deleted Machine default/machine-1",
+			check: func(t *testing.T, c client.Client, server *metalv1alpha1.Server) {
+				err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "machine-1"}, &clusterv1.Machine{})
+				assert.True(t, apierrors.IsNotFound(err), "unexpected error %v", err)
+
+				require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "metalmachine-1"}, &infrav1.MetalMachine{}))
+			},
+		},
+		"wipe frozen": {
+			action:          &metalv1alpha1.WipeAction{Spec: metalv1alpha1.WipeActionSpec{ServerActionSpec: spec, Confirm: true}},
+			accepted:        true,
+			frozen:          true,
+			expectedPhase:   metalv1alpha1.ActionPhasePending,
+			expectedMessage: `action is pending: provisioning is frozen by "incident"`,
+			check: func(t *testing.T, c client.Client, server *metalv1alpha1.Server) {
+				assert.True(t, server.Status.IsClean)
+				assert.NotContains(t, server.Annotations, metalv1alpha1.ServerConfirmWipeAnnotation)
+			},
+		},
+		"release frozen": {
+			action:          &metalv1alpha1.ReleaseAction{Spec: spec},
+			accepted:        true,
+			allocated:       true,
+			frozen:          true,
+			expectedPhase:   metalv1alpha1.ActionPhasePending,
+			expectedMessage: `action is pending: provisioning is frozen by "incident"`,
+			check: func(t *testing.T, c client.Client, server *metalv1alpha1.Server) {
+				require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "machine-1"}, &clusterv1.Machine{}))
+			},
+		},
+		"release idle": {
+			action:          &metalv1alpha1.ReleaseAction{Spec: spec},
+			expectedPhase:   metalv1alpha1.ActionPhaseFailed,
+			expectedMessage: "server is not allocated",
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			objects := []runtime.Object{}
+
+			if !tc.missingServer {
+				objects = append(objects, &metalv1alpha1.Server{
+					ObjectMeta: metav1.ObjectMeta{Name: "server-1", ResourceVersion: "1"},
+					Spec:       metalv1alpha1.ServerSpec{Accepted: tc.accepted},
+					Status:     metalv1alpha1.ServerStatus{IsClean: true, Approval: tc.approval},
+				})
+			}
+
+			if tc.allocated {
+				objects = append(objects,
+					&infrav1.ServerBinding{
+						ObjectMeta: metav1.ObjectMeta{Name: "server-1"},
+						Spec: infrav1.ServerBindingSpec{
+							MetalMachineRef: corev1.ObjectReference{Namespace: "default", Name: "metalmachine-1"},
+						},
+					},
+					&infrav1.MetalMachine{
+						ObjectMeta: metav1.ObjectMeta{
+							Namespace: "default",
+							Name:      "metalmachine-1",
+							OwnerReferences: []metav1.OwnerReference{
+								{
+									APIVersion: clusterv1.GroupVersion.String(),
+									Kind:       "Machine",
+									Name:       "machine-1",
+								},
+							},
+						},
+					},
+					&clusterv1.Machine{
+						ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "machine-1"},
+					},
+				)
+			}
+
+			if tc.frozen {
+				objects = append(objects, &metalv1alpha1.Freeze{
+					ObjectMeta: metav1.ObjectMeta{Name: "incident"},
+					Spec:       metalv1alpha1.FreezeSpec{Frozen: true},
+				})
+			}
+
+			action := tc.action.DeepCopyObject().(metalv1alpha1.ServerAction)
+			action.SetName("action-1")
+			action.SetResourceVersion("1")
+
+			objects = append(objects, action)
+
+			c := fake.NewFakeClientWithScheme(scheme, objects...)
+
+			r := &controllers.ServerActionReconciler{
+				Client:   c,
+				Log:      log.NullLogger{},
+				Scheme:   scheme,
+				Recorder: record.NewFakeRecorder(10),
+
+				RequiredApprovals: tc.requiredApprovals,
+			}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "action-1"}}
+
+			var err error
+
+			switch action.(type) {
+			case *metalv1alpha1.AcceptAction:
+				_, err = r.ReconcileAcceptAction(req)
+			case *metalv1alpha1.ApproveAction:
+				_, err = r.ReconcileApproveAction(req)
+			case *metalv1alpha1.WipeAction:
+				_, err = r.ReconcileWipeAction(req)
+			case *metalv1alpha1.ReleaseAction:
+				_, err = r.ReconcileReleaseAction(req)
+			}
+
+			require.NoError(t, err)
+
+			current := action.DeepCopyObject().(metalv1alpha1.ServerAction)
+			require.NoError(t, c.Get(ctx, req.NamespacedName, current))
+
+			assert.Equal(t, tc.expectedPhase, current.ActionStatus().Phase)
+			assert.Equal(t, tc.expectedPhase != metalv1alpha1.ActionPhasePending, current.ActionStatus().CompletionTime != nil)
+
+			if tc.expectedMessage != "" {
+				assert.Equal(t, tc.expectedMessage, current.ActionStatus().Message)
+			}
+
+			if tc.check != nil {
+				var server metalv1alpha1.Server
+
+				require.NoError(t, c.Get(ctx, types.NamespacedName{Name: "server-1"}, &server))
+
+				tc.check(t, c, &server)
+			}
+		})
+	}
+}
+
+func TestPowerAction(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+	require.NoError(t, infrav1.AddToScheme(scheme))
+
+	bmc := &managementAPI{power: map[string]bool{"server-1": true}}
+
+	srv := httptest.NewServer(bmc)
+	defer srv.Close()
+
+	c := fake.NewFakeClientWithScheme(scheme,
+		&metalv1alpha1.Server{
+			ObjectMeta: metav1.ObjectMeta{Name: "server-1", ResourceVersion: "1"},
+			Spec: metalv1alpha1.ServerSpec{
+				Accepted: true,
+				ManagementAPI: &metalv1alpha1.ManagementAPI{
+					Endpoint: strings.TrimPrefix(srv.URL, "http://") + "/server-1",
+				},
+			},
+		},
+	)
+
+	recorder := record.NewFakeRecorder(10)
+
+	r := &controllers.ServerActionReconciler{
+		Client:   c,
+		Log:      log.NullLogger{},
+		Scheme:   scheme,
+		Recorder: recorder,
+	}
+
+	serverReconciler := &controllers.ServerReconciler{
+		Client:    c,
+		Log:       log.NullLogger{},
+		Scheme:    scheme,
+		APIReader: c,
+		Recorder:  recorder,
+
+		RebootTimeout: time.Minute,
+	}
+
+	powerAction := func(name, operation string) *metalv1alpha1.PowerAction {
+		require.NoError(t, c.Create(ctx, &metalv1alpha1.PowerAction{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: metalv1alpha1.PowerActionSpec{
+				ServerActionSpec: metalv1alpha1.ServerActionSpec{ServerRef: "server-1"},
+				Operation:        operation,
+			},
+		}))
+
+		_, err := r.ReconcilePowerAction(ctrl.Request{NamespacedName: types.NamespacedName{Name: name}})
+		require.NoError(t, err)
+
+		var action metalv1alpha1.PowerAction
+
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Name: name}, &action))
+
+		return &action
+	}
+
+	server := func() *metalv1alpha1.Server {
+		var s metalv1alpha1.Server
+
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Name: "server-1"}, &s))
+
+		return &s
+	}
+
+	action := powerAction("power-off", metalv1alpha1.PowerOff)
+	assert.Equal(t, metalv1alpha1.ActionPhaseSucceeded, action.Status.Phase)
+	assert.False(t, bmc.poweredOn("server-1"))
+	assert.True(t, server().PowerHeld())
+	assert.Equal(t, "power-off", server().Annotations[metalv1alpha1.ServerPowerHoldAnnotation])
+
+	// server is not clean, so it would be powered on to be wiped if not held
+	_, err := serverReconciler.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: "server-1"}})
+	require.NoError(t, err)
+	assert.False(t, bmc.poweredOn("server-1"))
+
+	action = powerAction("power-on", metalv1alpha1.PowerOn)
+	assert.Equal(t, metalv1alpha1.ActionPhaseSucceeded, action.Status.Phase)
+	assert.True(t, bmc.poweredOn("server-1"))
+	assert.False(t, server().PowerHeld())
+
+	// actions are taken once
+	bmc.mu.Lock()
+	bmc.power["server-1"] = false
+	bmc.mu.Unlock()
+
+	_, err = r.ReconcilePowerAction(ctrl.Request{NamespacedName: types.NamespacedName{Name: "power-on"}})
+	require.NoError(t, err)
+	assert.False(t, bmc.poweredOn("server-1"))
+}
+
+func TestServerApprovalGrandfathering(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+	require.NoError(t, infrav1.AddToScheme(scheme))
+
+	c := fake.NewFakeClientWithScheme(scheme,
+		// accepted and ready before the approval gate was enabled
+		&metalv1alpha1.Server{
+			ObjectMeta: metav1.ObjectMeta{Name: "server-1", ResourceVersion: "1"},
+			Spec:       metalv1alpha1.ServerSpec{Accepted: true},
+			Status:     metalv1alpha1.ServerStatus{Ready: true, IsClean: true},
+		},
+		// accepted and wiped only, e.g. while the gate was not enforced
+		&metalv1alpha1.Server{
+			ObjectMeta: metav1.ObjectMeta{Name: "server-3", ResourceVersion: "1"},
+			Spec:       metalv1alpha1.ServerSpec{Accepted: true},
+			Status:     metalv1alpha1.ServerStatus{IsClean: true},
+		},
+		// accepted bypassing the approval gate
+		&metalv1alpha1.Server{
+			ObjectMeta: metav1.ObjectMeta{Name: "server-2", ResourceVersion: "1"},
+			Spec:       metalv1alpha1.ServerSpec{Accepted: true},
+		},
+	)
+
+	r := &controllers.ServerReconciler{
+		Client:    c,
+		Log:       log.NullLogger{},
+		Scheme:    scheme,
+		APIReader: c,
+		Recorder:  record.NewFakeRecorder(10),
+
+		RebootTimeout:     time.Minute,
+		RequiredApprovals: 2,
+	}
+
+	reconcile := func(name string) *metalv1alpha1.Server {
+		_, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: name}})
+		require.NoError(t, err)
+
+		var s metalv1alpha1.Server
+
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Name: name}, &s))
+
+		return &s
+	}
+
+	server := reconcile("server-1")
+	assert.True(t, server.Spec.Accepted)
+	require.NotNil(t, server.Status.Approval)
+	assert.True(t, server.Status.Approval.Approved())
+	assert.True(t, server.Status.Approval.Grandfathered)
+
+	server = reconcile("server-3")
+	assert.False(t, server.Spec.Accepted)
+	assert.False(t, server.Status.IsClean)
+	require.NotNil(t, server.Status.Approval)
+	assert.Equal(t, metalv1alpha1.ApprovalStatePending, server.Status.Approval.State)
+
+	server = reconcile("server-2")
+	assert.False(t, server.Spec.Accepted)
+	require.NotNil(t, server.Status.Approval)
+	assert.Equal(t, metalv1alpha1.ApprovalStatePending, server.Status.Approval.State)
+
+	// acceptance is reverted once the grandfathering is decided
+	server.Spec.Accepted = true
+	server.Status.IsClean = true
+	require.NoError(t, c.Update(ctx, server))
+	require.NoError(t, c.Status().Update(ctx, server))
+
+	assert.False(t, reconcile("server-2").Spec.Accepted)
+}
@@ -12,6 +12,8 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
 	"sigs.k8s.io/cluster-api/util/patch"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -20,7 +22,9 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
 	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/metrics"
 )
 
 // ServerClassReconciler reconciles a ServerClass object.
@@ -28,12 +32,16 @@ type ServerClassReconciler struct {
 	client.Client
 	Log    logr.Logger
 	Scheme *runtime.Scheme
+
+	// RequiredApprovals enables the approval gate, only the approved servers are available then.
+	RequiredApprovals int
 }
 
 // +kubebuilder:rbac:groups=metal.sidero.dev,resources=serverclasses,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=metal.sidero.dev,resources=serverclasses/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=metal.sidero.dev,resources=servers,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=metal.sidero.dev,resources=servers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=infrastructure.cluster.x-k8s.io,resources=serverbindings,verbs=get;list;watch
 
 func (r *ServerClassReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
@@ -55,6 +63,12 @@ func (r *ServerClassReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 	sc := metalv1alpha1.ServerClass{}
 
 	if err := r.Get(ctx, req.NamespacedName, &sc); err != nil {
+		if apierrors.IsNotFound(err) {
+			for _, state := range []string{metrics.StateFree, metrics.StateAllocated, metrics.StateInUse} {
+				metrics.ServerClassServers.DeleteLabelValues(req.Name, state)
+			}
+		}
+
 		l.Error(err, "failed fetching resource")
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
@@ -64,6 +78,14 @@ func (r *ServerClassReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 		return ctrl.Result{}, err
 	}
 
+	if err = sc.Spec.Qualifiers.ValidateExpressions(); err != nil {
+		l.Error(err, "invalid qualifier expressions")
+
+		conditions.MarkFalse(&sc, metalv1alpha1.ConditionQualifiersValid, "InvalidExpression", clusterv1.ConditionSeverityError, err.Error())
+	} else {
+		conditions.MarkTrue(&sc, metalv1alpha1.ConditionQualifiersValid)
+	}
+
 	sl := &metalv1alpha1.ServerList{}
 
 	if err := r.List(ctx, sl); err != nil {
@@ -72,6 +94,7 @@ func (r *ServerClassReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 
 	results, err := metalv1alpha1.FilterServers(sl.Items,
 		metalv1alpha1.AcceptedServerFilter,
+		metalv1alpha1.ApprovedServerFilter(r.RequiredApprovals),
 		sc.SelectorFilter(),
 		sc.QualifiersFilter(),
 	)
@@ -79,8 +102,23 @@ func (r *ServerClassReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 		return ctrl.Result{}, fmt.Errorf("unable to filter servers: %w", err)
 	}
 
+	serverBindings := &infrav1.ServerBindingList{}
+
+	if err := r.List(ctx, serverBindings); err != nil {
+		return ctrl.Result{}, fmt.Errorf("unable to get serverbindings: %w", err)
+	}
+
+	bound := map[string]struct{}{}
+
+	for _, serverBinding := range serverBindings.Items {
+		if ref := serverBinding.Spec.ServerClassRef; ref != nil && ref.Name == sc.Name {
+			bound[serverBinding.Name] = struct{}{}
+		}
+	}
+
 	avail := []string{}
 	used := []string{}
+	allocated := 0
 
 	for _, server := range results {
 		if server.Status.InUse {
@@ -88,13 +126,28 @@ func (r *ServerClassReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error)
 			continue
 		}
 
+		// servers held by the canary run are not available until the run is done
+		if server.CanaryHeld() != "" {
+			continue
+		}
+
+		if _, ok := bound[server.Name]; ok {
+			allocated++
+		}
+
 		avail = append(avail, server.Name)
 	}
 
 	sc.Status.ServersAvailable = avail
 	sc.Status.ServersInUse = used
 
-	if err := patchHelper.Patch(ctx, &sc); err != nil {
+	metrics.ServerClassServers.WithLabelValues(sc.Name, metrics.StateFree).Set(float64(len(avail) - allocated))
+	metrics.ServerClassServers.WithLabelValues(sc.Name, metrics.StateAllocated).Set(float64(allocated))
+	metrics.ServerClassServers.WithLabelValues(sc.Name, metrics.StateInUse).Set(float64(len(used)))
+
+	if err := patchHelper.Patch(ctx, &sc, patch.WithOwnedConditions{
+		Conditions: []clusterv1.ConditionType{metalv1alpha1.ConditionQualifiersValid},
+	}); err != nil {
 		return ctrl.Result{}, err
 	}
 
@@ -132,7 +185,7 @@ func ReconcileServerClassAny(ctx context.Context, c client.Client) error {
 }
 
 func (r *ServerClassReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
-	// This mapRequests handler allows us to add a watch on server resources. Upon a server resource update,
+	// This mapRequests handler allows us to add a watch on server (and server binding) resources. Upon a server resource update,
 	// we will dump all server classes and issue a reconcile against them so that they will get updated statuses
 	// for available/in-use servers that match.
 	mapRequests := handler.ToRequestsFunc(
@@ -169,5 +222,11 @@ func (r *ServerClassReconciler) SetupWithManager(mgr ctrl.Manager, options contr
 				ToRequests: mapRequests,
 			},
 		).
+		Watches(
+			&source.Kind{Type: &infrav1.ServerBinding{}},
+			&handler.EnqueueRequestsFromMapFunc{
+				ToRequests: mapRequests,
+			},
+		).
 		Complete(r)
 }
@@ -0,0 +1,446 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	multierror "github.com/hashicorp/go-multierror"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/sitecache"
+)
+
+// SiteCacheReconciler replicates the Environment assets to the site caches.
+type SiteCacheReconciler struct {
+	client.Client
+	Log    logr.Logger
+	Scheme *runtime.Scheme
+
+	// Uploads runs the uploads to the site caches in the background.
+	Uploads *sitecache.Runner
+
+	// DataDirectory keeps the environment assets, defaults to constants.DataDirectory.
+	DataDirectory string
+	// DryRun logs the transfers instead of uploading the assets.
+	DryRun bool
+}
+
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=sitecaches,verbs=get;list;watch
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=environments,verbs=get;list;watch
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=environments/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+
+func (r *SiteCacheReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
+	ctx := context.Background()
+
+	l := r.Log.WithValues("sitecache", req.Name)
+
+	var envs metalv1alpha1.EnvironmentList
+
+	if err := r.List(ctx, &envs); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	var cache metalv1alpha1.SiteCache
+
+	if err := r.Get(ctx, req.NamespacedName, &cache); err != nil {
+		if !apierrors.IsNotFound(err) {
+			return ctrl.Result{}, err
+		}
+
+		// site cache is removed, so are the uploads and the replication status
+		r.Uploads.Cancel(req.Name + "/")
+
+		for i := range envs.Items {
+			if err = r.setReplicas(ctx, envs.Items[i].Name, req.Name, nil, nil); err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+
+		return ctrl.Result{}, nil
+	}
+
+	open, until, err := cache.TransferWindow(time.Now())
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	token, err := cache.Spec.TokenFrom.Resolve(ctx, r.Client)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	uploader := &sitecache.Uploader{
+		Token: token,
+	}
+
+	if cache.Spec.BandwidthLimit != nil {
+		uploader.BandwidthLimit = cache.Spec.BandwidthLimit.Value()
+	}
+
+	var deadline time.Time
+
+	if open {
+		// uploads are interrupted in the end of the window and resumed in the next one
+		deadline = until
+	}
+
+	var (
+		result  *multierror.Error
+		pending bool
+		retryAt time.Time
+	)
+
+	for i := range envs.Items {
+		env := &envs.Items[i]
+
+		selected, err := cache.SelectsEnvironment(env)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if !selected {
+			r.Uploads.Cancel(path.Join(cache.Name, env.Name) + "/")
+
+			if err = r.setReplicas(ctx, env.Name, cache.Name, nil, nil); err != nil {
+				return ctrl.Result{}, err
+			}
+
+			continue
+		}
+
+		envPending, envRetryAt, err := r.replicate(ctx, l, &cache, env, uploader, open, until, deadline)
+		if err != nil {
+			result = multierror.Append(result, fmt.Errorf("error replicating environment %q: %w", env.Name, err))
+		}
+
+		pending = pending || envPending
+
+		if !envRetryAt.IsZero() && (retryAt.IsZero() || envRetryAt.Before(retryAt)) {
+			retryAt = envRetryAt
+		}
+	}
+
+	if result.ErrorOrNil() != nil {
+		return ctrl.Result{}, result.ErrorOrNil()
+	}
+
+	if pending && !open {
+		l.Info("waiting for the transfer window", "start", until)
+
+		return ctrl.Result{RequeueAfter: time.Until(until)}, nil
+	}
+
+	// running uploads report the outcome in the environment status, which triggers the reconcile,
+	// failed uploads are retried with the backoff
+	if !retryAt.IsZero() {
+		return ctrl.Result{RequeueAfter: time.Until(retryAt)}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// replicate the ready assets of the environment to the cache.
+//
+// The uploads are run in the background, replicate returns true if some assets wait for the transfer window,
+// and the time the failed upload should be retried at.
+//
+//nolint:gocyclo,cyclop
+func (r *SiteCacheReconciler) replicate(ctx context.Context, l logr.Logger, cache *metalv1alpha1.SiteCache, env *metalv1alpha1.Environment,
+	uploader *sitecache.Uploader, open bool, until, deadline time.Time) (bool, time.Time, error) {
+	dataDir := r.DataDirectory
+	if dataDir == "" {
+		dataDir = constants.DataDirectory
+	}
+
+	tasks := []struct {
+		BaseName string
+		Asset    metalv1alpha1.Asset
+	}{
+		{
+			BaseName: constants.KernelAsset,
+			Asset:    env.Spec.KernelAsset(),
+		},
+		{
+			BaseName: constants.InitrdAsset,
+			Asset:    env.Spec.InitrdAsset(),
+		},
+	}
+
+	var (
+		replicas  []metalv1alpha1.SiteReplication
+		baseNames []string
+		pending   bool
+		retryAt   time.Time
+	)
+
+	for _, task := range tasks {
+		ready := env.ReadyAsset(task.Asset)
+		if ready == nil {
+			// the asset is replicated once it's downloaded
+			continue
+		}
+
+		replica := metalv1alpha1.SiteReplication{
+			Site:  cache.Name,
+			Asset: ready.Asset,
+			State: metalv1alpha1.ReplicationPending,
+		}
+
+		for _, previous := range env.Status.Replicas {
+			if previous.Site == cache.Name && previous.URL == ready.URL && strings.EqualFold(previous.SHA512, ready.SHA512) {
+				replica = previous
+			}
+		}
+
+		if replica.State != metalv1alpha1.ReplicationReplicated && !open {
+			pending = true
+
+			replica.State = metalv1alpha1.ReplicationPending
+			replica.Message = fmt.Sprintf("waiting for the transfer window at %s", until.Format(time.RFC3339))
+		}
+
+		replicas = append(replicas, replica)
+		baseNames = append(baseNames, task.BaseName)
+	}
+
+	if !open {
+		return pending, retryAt, r.setReplicas(ctx, env.Name, cache.Name, replicas, nil)
+	}
+
+	var (
+		uploads []sitecache.Upload
+		// status of the running uploads is reported by the uploads
+		running = map[string]bool{}
+	)
+
+	for i := range replicas {
+		replica := &replicas[i]
+
+		if replica.State == metalv1alpha1.ReplicationReplicated {
+			continue
+		}
+
+		key := path.Join(cache.Name, env.Name, baseNames[i])
+		file := filepath.Join(dataDir, "env", env.Name, baseNames[i])
+		url := fmt.Sprintf("%s/env/%s/%s", strings.TrimRight(cache.Spec.URL, "/"), env.Name, baseNames[i])
+
+		if r.DryRun {
+			l.Info("dry run: skipping asset replication", "url", url)
+
+			continue
+		}
+
+		if r.Uploads.Running(key, replica.SHA512) {
+			running[replica.URL] = true
+
+			continue
+		}
+
+		if at := r.Uploads.RetryAt(key, replica.SHA512); at.After(time.Now()) {
+			if retryAt.IsZero() || at.Before(retryAt) {
+				retryAt = at
+			}
+
+			continue
+		}
+
+		l.Info("replicating asset", "url", url)
+
+		replica.State = metalv1alpha1.ReplicationTransferring
+		replica.Message = ""
+
+		uploads = append(uploads, r.upload(l, key, env.Name, *replica, uploader, url, file, deadline))
+	}
+
+	// transferring state is recorded before the uploads are started, so that the uploads always find their replica in the status
+	if err := r.setReplicas(ctx, env.Name, cache.Name, replicas, running); err != nil {
+		return pending, retryAt, err
+	}
+
+	for _, upload := range uploads {
+		r.Uploads.Run(upload)
+	}
+
+	return pending, retryAt, nil
+}
+
+// upload builds the background upload of the asset reporting the progress and the outcome in the environment status.
+func (r *SiteCacheReconciler) upload(l logr.Logger, key, envName string, replica metalv1alpha1.SiteReplication,
+	uploader *sitecache.Uploader, url, file string, deadline time.Time) sitecache.Upload {
+	ctx := context.Background()
+
+	var lastReport time.Time
+
+	return sitecache.Upload{
+		Key:      key,
+		Uploader: uploader,
+		URL:      url,
+		File:     file,
+		Checksum: replica.SHA512,
+		Deadline: deadline,
+		Progress: func(transferred, total int64) {
+			replica.TransferredBytes = transferred
+			replica.TotalBytes = total
+
+			if time.Since(lastReport) < progressInterval {
+				return
+			}
+
+			lastReport = time.Now()
+
+			if err := r.setReplica(ctx, envName, replica); err != nil {
+				l.Error(err, "failed to report transfer progress")
+			}
+		},
+		Done: func(err error) {
+			switch {
+			case err == nil:
+				l.Info("replicated asset", "url", url)
+
+				now := metav1.Now()
+
+				replica.State = metalv1alpha1.ReplicationReplicated
+				replica.Message = ""
+				replica.LastTransferTime = &now
+			case errors.Is(err, context.DeadlineExceeded):
+				replica.State = metalv1alpha1.ReplicationPending
+				replica.Message = "transfer window closed, the transfer is resumed in the next window"
+			default:
+				l.Error(err, "error replicating asset", "url", url)
+
+				replica.State = metalv1alpha1.ReplicationFailed
+				replica.Message = err.Error()
+			}
+
+			if err := r.setReplica(ctx, envName, replica); err != nil {
+				l.Error(err, "failed to report transfer outcome")
+			}
+		},
+	}
+}
+
+// setReplicas replaces the replication status of the site in the environment.
+//
+// Replicas of the running uploads (by URL) are kept as reported by the uploads.
+// Several sites update the status of the same environment concurrently, so the status is updated with the optimistic locking.
+func (r *SiteCacheReconciler) setReplicas(ctx context.Context, envName, site string, replicas []metalv1alpha1.SiteReplication, running map[string]bool) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var env metalv1alpha1.Environment
+
+		if err := r.Get(ctx, types.NamespacedName{Name: envName}, &env); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+
+		updated := make([]metalv1alpha1.SiteReplication, 0, len(env.Status.Replicas)+len(replicas))
+
+		for _, replica := range env.Status.Replicas {
+			if replica.Site != site {
+				updated = append(updated, replica)
+			}
+		}
+
+		for _, replica := range replicas {
+			if running[replica.URL] {
+				for _, current := range env.Status.Replicas {
+					if current.Site == site && current.URL == replica.URL && strings.EqualFold(current.SHA512, replica.SHA512) {
+						replica = current
+					}
+				}
+			}
+
+			updated = append(updated, replica)
+		}
+
+		if len(updated) == 0 {
+			updated = nil
+		}
+
+		if apiequality.Semantic.DeepEqual(updated, env.Status.Replicas) {
+			return nil
+		}
+
+		env.Status.Replicas = updated
+
+		return r.Status().Update(ctx, &env)
+	})
+}
+
+// setReplica updates the replication status of the asset in the environment, if the status still has the asset.
+func (r *SiteCacheReconciler) setReplica(ctx context.Context, envName string, replica metalv1alpha1.SiteReplication) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var env metalv1alpha1.Environment
+
+		if err := r.Get(ctx, types.NamespacedName{Name: envName}, &env); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+
+		for i, current := range env.Status.Replicas {
+			if current.Site == replica.Site && current.URL == replica.URL && strings.EqualFold(current.SHA512, replica.SHA512) {
+				if apiequality.Semantic.DeepEqual(current, replica) {
+					return nil
+				}
+
+				env.Status.Replicas[i] = replica
+
+				return r.Status().Update(ctx, &env)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (r *SiteCacheReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	// any change of the environments (e.g. the assets are downloaded) is replicated to all site caches
+	mapRequests := handler.ToRequestsFunc(
+		func(a handler.MapObject) []reconcile.Request {
+			var caches metalv1alpha1.SiteCacheList
+
+			if err := r.List(context.Background(), &caches); err != nil {
+				return nil
+			}
+
+			reqs := make([]reconcile.Request, 0, len(caches.Items))
+
+			for _, cache := range caches.Items {
+				reqs = append(reqs, reconcile.Request{NamespacedName: types.NamespacedName{Name: cache.Name}})
+			}
+
+			return reqs
+		})
+
+	return ctrl.NewControllerManagedBy(mgr).
+		WithOptions(options).
+		For(&metalv1alpha1.SiteCache{}).
+		Watches(
+			&source.Kind{Type: &metalv1alpha1.Environment{}},
+			&handler.EnqueueRequestsFromMapFunc{
+				ToRequests: mapRequests,
+			},
+		).
+		Complete(r)
+}
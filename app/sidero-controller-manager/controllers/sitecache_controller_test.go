@@ -0,0 +1,231 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers_test
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/controllers"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/sitecache"
+)
+
+func TestSiteCacheReconcile(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+
+	checksum := func(data []byte) string {
+		sum := sha512.Sum512(data)
+
+		return hex.EncodeToString(sum[:])
+	}
+
+	assetContents := map[string][]byte{
+		constants.KernelAsset: []byte("kernel"),
+		constants.InitrdAsset: []byte("initramfs"),
+	}
+
+	dataDir := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dataDir, "env", "edge"), 0o755))
+
+	for name, data := range assetContents {
+		require.NoError(t, os.WriteFile(filepath.Join(dataDir, "env", "edge", name), data, 0o644))
+	}
+
+	newEnvironment := func() *metalv1alpha1.Environment {
+		return &metalv1alpha1.Environment{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "edge",
+				Labels:          map[string]string{"sites": "all"},
+				ResourceVersion: "1",
+			},
+			Spec: metalv1alpha1.EnvironmentSpec{
+				Kernel: metalv1alpha1.Kernel{Asset: metalv1alpha1.Asset{URL: "http://example.com/vmlinuz"}},
+				Initrd: metalv1alpha1.Initrd{Asset: metalv1alpha1.Asset{URL: "http://example.com/initramfs.xz"}},
+			},
+			Status: metalv1alpha1.EnvironmentStatus{
+				Conditions: []metalv1alpha1.AssetCondition{
+					{
+						Asset:  metalv1alpha1.Asset{URL: "http://example.com/vmlinuz", SHA512: checksum(assetContents[constants.KernelAsset])},
+						Type:   "Ready",
+						Status: "True",
+					},
+					{
+						Asset:  metalv1alpha1.Asset{URL: "http://example.com/initramfs.xz", SHA512: checksum(assetContents[constants.InitrdAsset])},
+						Type:   "Ready",
+						Status: "True",
+					},
+				},
+			},
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		windows  []metalv1alpha1.TransferWindow
+		selector *metav1.LabelSelector
+		dryRun   bool
+		token    string
+
+		expectedState   string
+		expectedReplica bool
+		expectedRequeue bool
+	}{
+		"replicated": {
+			selector:        &metav1.LabelSelector{MatchLabels: map[string]string{"sites": "all"}},
+			expectedState:   metalv1alpha1.ReplicationReplicated,
+			expectedReplica: true,
+		},
+		"outside of window": {
+			// the window which is never open at the time of the test
+			windows: []metalv1alpha1.TransferWindow{{
+				Start: time.Now().UTC().Add(2 * time.Hour).Format("15:04"),
+				End:   time.Now().UTC().Add(3 * time.Hour).Format("15:04"),
+			}},
+			expectedState:   metalv1alpha1.ReplicationPending,
+			expectedRequeue: true,
+		},
+		"dry run": {
+			dryRun:        true,
+			expectedState: metalv1alpha1.ReplicationPending,
+		},
+		"upload rejected": {
+			token:         "wrong",
+			expectedState: metalv1alpha1.ReplicationFailed,
+			// failed uploads are retried with the backoff
+			expectedRequeue: true,
+		},
+		"not selected": {
+			selector: &metav1.LabelSelector{MatchLabels: map[string]string{"sites": "some"}},
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			cacheDir := t.TempDir()
+
+			srv := httptest.NewServer(sitecache.NewServer(cacheDir, "secret"))
+			t.Cleanup(srv.Close)
+
+			cache := &metalv1alpha1.SiteCache{
+				ObjectMeta: metav1.ObjectMeta{Name: "site-1"},
+				Spec: metalv1alpha1.SiteCacheSpec{
+					URL: srv.URL,
+					TokenFrom: &metalv1alpha1.CredentialSource{
+						SecretKeyRef: &metalv1alpha1.SecretKeyRef{Namespace: "default", Name: "site-1", Key: "token"},
+					},
+					Windows:             tc.windows,
+					EnvironmentSelector: tc.selector,
+				},
+			}
+
+			token := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "site-1"},
+				Data:       map[string][]byte{"token": []byte("secret")},
+			}
+
+			if tc.token != "" {
+				token.Data["token"] = []byte(tc.token)
+			}
+
+			c := fake.NewFakeClientWithScheme(scheme, newEnvironment(), cache, token)
+
+			reconciler := &controllers.SiteCacheReconciler{
+				Client:        c,
+				Log:           log.NullLogger{},
+				Scheme:        scheme,
+				Uploads:       sitecache.NewRunner(),
+				DataDirectory: dataDir,
+				DryRun:        tc.dryRun,
+			}
+
+			_, err := reconciler.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: "site-1"}})
+			require.NoError(t, err)
+
+			var env metalv1alpha1.Environment
+
+			// uploads run in the background, and report the outcome in the status
+			require.Eventually(t, func() bool {
+				require.NoError(t, c.Get(ctx, types.NamespacedName{Name: "edge"}, &env))
+
+				for _, replica := range env.Status.Replicas {
+					if replica.State != tc.expectedState {
+						return false
+					}
+				}
+
+				return true
+			}, 10*time.Second, 10*time.Millisecond)
+
+			// the status change triggers the reconcile
+			result, err := reconciler.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: "site-1"}})
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedRequeue, result.RequeueAfter > 0)
+
+			require.NoError(t, c.Get(ctx, types.NamespacedName{Name: "edge"}, &env))
+
+			if tc.expectedState == "" {
+				assert.Empty(t, env.Status.Replicas)
+			} else {
+				require.Len(t, env.Status.Replicas, 2)
+
+				for _, replica := range env.Status.Replicas {
+					assert.Equal(t, "site-1", replica.Site)
+					assert.Equal(t, tc.expectedState, replica.State)
+				}
+			}
+
+			assert.Equal(t, tc.expectedReplica, env.ReplicatedTo("site-1"))
+
+			for name, data := range assetContents {
+				replicated, err := os.ReadFile(filepath.Join(cacheDir, "env", "edge", name))
+
+				if tc.expectedReplica {
+					require.NoError(t, err)
+					assert.Equal(t, data, replicated)
+				} else {
+					assert.True(t, os.IsNotExist(err))
+				}
+			}
+
+			// site cache is removed
+			require.NoError(t, c.Delete(ctx, cache))
+
+			_, err = reconciler.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: "site-1"}})
+			require.NoError(t, err)
+
+			var cleanedUp metalv1alpha1.Environment
+
+			require.NoError(t, c.Get(ctx, types.NamespacedName{Name: "edge"}, &cleanedUp))
+			assert.Empty(t, cleanedUp.Status.Replicas)
+		})
+	}
+}
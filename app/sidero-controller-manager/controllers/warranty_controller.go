@@ -0,0 +1,120 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/tools/reference"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/cluster-api/util/patch"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// DefaultWarrantyExpiryWindow is the default time before the warranty end the servers are flagged.
+const DefaultWarrantyExpiryWindow = 90 * 24 * time.Hour
+
+// WarrantyReconciler flags the servers whose warranty is near the expiry or has ended.
+//
+// Servers are reconciled again when the warranty state changes next, so the condition is updated without any changes to the server.
+type WarrantyReconciler struct {
+	client.Client
+	Log      logr.Logger
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+
+	ExpiryWindow time.Duration
+}
+
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=servers,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=metal.sidero.dev,resources=servers/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=get;list;create;patch
+
+func (r *WarrantyReconciler) Reconcile(req ctrl.Request) (_ ctrl.Result, err error) {
+	ctx := context.Background()
+	log := r.Log.WithValues("server", req.NamespacedName)
+
+	var server metalv1alpha1.Server
+
+	if err = r.Get(ctx, req.NamespacedName, &server); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+
+	patchHelper, err := patch.NewHelper(&server, r)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	defer func() {
+		if e := patchHelper.Patch(ctx, &server); e != nil {
+			log.Error(e, "failed to patch server")
+
+			if err == nil {
+				err = e
+			}
+		}
+	}()
+
+	now := time.Now()
+	state, next := server.Spec.Asset.Warranty(now, r.ExpiryWindow)
+
+	switch state {
+	case metalv1alpha1.WarrantyUnknown:
+		conditions.Delete(&server, metalv1alpha1.ConditionWarrantyValid)
+	case metalv1alpha1.WarrantyValid:
+		conditions.MarkTrue(&server, metalv1alpha1.ConditionWarrantyValid)
+	case metalv1alpha1.WarrantyExpiring:
+		message := fmt.Sprintf("Warranty ends on %s.", server.Spec.Asset.WarrantyEnd.Format("2006-01-02"))
+
+		if conditions.GetReason(&server, metalv1alpha1.ConditionWarrantyValid) != metalv1alpha1.WarrantyExpiringReason {
+			log.Info("server warranty is expiring", "warrantyEnd", server.Spec.Asset.WarrantyEnd)
+
+			r.event(&server, message)
+		}
+
+		conditions.MarkFalse(&server, metalv1alpha1.ConditionWarrantyValid, metalv1alpha1.WarrantyExpiringReason, clusterv1.ConditionSeverityWarning, message)
+	case metalv1alpha1.WarrantyExpired:
+		message := fmt.Sprintf("Warranty ended on %s.", server.Spec.Asset.WarrantyEnd.Format("2006-01-02"))
+
+		if conditions.GetReason(&server, metalv1alpha1.ConditionWarrantyValid) != metalv1alpha1.WarrantyExpiredReason {
+			log.Info("server warranty has ended", "warrantyEnd", server.Spec.Asset.WarrantyEnd)
+
+			r.event(&server, message)
+		}
+
+		conditions.MarkFalse(&server, metalv1alpha1.ConditionWarrantyValid, metalv1alpha1.WarrantyExpiredReason, clusterv1.ConditionSeverityError, message)
+	}
+
+	if next.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	return ctrl.Result{RequeueAfter: next.Sub(now)}, nil
+}
+
+func (r *WarrantyReconciler) event(server *metalv1alpha1.Server, message string) {
+	if serverRef, err := reference.GetReference(r.Scheme, server); err == nil {
+		r.Recorder.Event(serverRef, corev1.EventTypeWarning, "Server Warranty", message)
+	}
+}
+
+func (r *WarrantyReconciler) SetupWithManager(mgr ctrl.Manager, options controller.Options) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("warranty").
+		WithOptions(options).
+		For(&metalv1alpha1.Server{}).
+		Complete(r)
+}
@@ -0,0 +1,166 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package controllers_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/controllers"
+)
+
+func TestWarrantyReconcile(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+
+	day := 24 * time.Hour
+
+	for name, tc := range map[string]struct {
+		asset *metalv1alpha1.AssetInformation
+
+		expectedStatus   *corev1.ConditionStatus
+		expectedReason   string
+		expectedSeverity clusterv1.ConditionSeverity
+		expectedRequeue  bool
+	}{
+		"no asset": {},
+		"no warranty end": {
+			asset: &metalv1alpha1.AssetInformation{VendorCaseURL: "https://support.example.com/cases/1"},
+		},
+		"valid": {
+			asset:           &metalv1alpha1.AssetInformation{WarrantyEnd: &metav1.Time{Time: time.Now().Add(365 * day)}},
+			expectedStatus:  conditionStatus(corev1.ConditionTrue),
+			expectedRequeue: true,
+		},
+		"expiring": {
+			asset:            &metalv1alpha1.AssetInformation{WarrantyEnd: &metav1.Time{Time: time.Now().Add(10 * day)}},
+			expectedStatus:   conditionStatus(corev1.ConditionFalse),
+			expectedReason:   metalv1alpha1.WarrantyExpiringReason,
+			expectedSeverity: clusterv1.ConditionSeverityWarning,
+			expectedRequeue:  true,
+		},
+		"expired": {
+			asset:            &metalv1alpha1.AssetInformation{WarrantyEnd: &metav1.Time{Time: time.Now().Add(-day)}},
+			expectedStatus:   conditionStatus(corev1.ConditionFalse),
+			expectedReason:   metalv1alpha1.WarrantyExpiredReason,
+			expectedSeverity: clusterv1.ConditionSeverityError,
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := fake.NewFakeClientWithScheme(scheme, &metalv1alpha1.Server{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            "server-1",
+					ResourceVersion: "1",
+				},
+				Spec: metalv1alpha1.ServerSpec{
+					Asset: tc.asset,
+				},
+			})
+
+			r := &controllers.WarrantyReconciler{
+				Client:   c,
+				Log:      log.NullLogger{},
+				Scheme:   scheme,
+				Recorder: record.NewFakeRecorder(10),
+
+				ExpiryWindow: 30 * day,
+			}
+
+			result, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: "server-1"}})
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedRequeue, result.RequeueAfter > 0)
+
+			var server metalv1alpha1.Server
+
+			require.NoError(t, c.Get(ctx, types.NamespacedName{Name: "server-1"}, &server))
+
+			condition := conditions.Get(&server, metalv1alpha1.ConditionWarrantyValid)
+
+			if tc.expectedStatus == nil {
+				assert.Nil(t, condition)
+
+				return
+			}
+
+			require.NotNil(t, condition)
+			assert.Equal(t, *tc.expectedStatus, condition.Status)
+			assert.Equal(t, tc.expectedReason, condition.Reason)
+			assert.Equal(t, tc.expectedSeverity, condition.Severity)
+		})
+	}
+
+	t.Run("events", func(t *testing.T) {
+		t.Parallel()
+
+		c := fake.NewFakeClientWithScheme(scheme, &metalv1alpha1.Server{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:            "server-1",
+				ResourceVersion: "1",
+			},
+			Spec: metalv1alpha1.ServerSpec{
+				Asset: &metalv1alpha1.AssetInformation{WarrantyEnd: &metav1.Time{Time: time.Now().Add(10 * day)}},
+			},
+		})
+		recorder := record.NewFakeRecorder(10)
+
+		r := &controllers.WarrantyReconciler{
+			Client:   c,
+			Log:      log.NullLogger{},
+			Scheme:   scheme,
+			Recorder: recorder,
+
+			ExpiryWindow: 30 * day,
+		}
+
+		reconcile := func() {
+			_, err := r.Reconcile(ctrl.Request{NamespacedName: types.NamespacedName{Name: "server-1"}})
+			require.NoError(t, err)
+		}
+
+		reconcile()
+
+		// reconciling again doesn't repeat the event
+		reconcile()
+
+		assert.Len(t, recorder.Events, 1)
+
+		var server metalv1alpha1.Server
+
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Name: "server-1"}, &server))
+
+		server.Spec.Asset.WarrantyEnd = &metav1.Time{Time: time.Now().Add(-day)}
+
+		require.NoError(t, c.Update(ctx, &server))
+
+		reconcile()
+
+		assert.Len(t, recorder.Events, 2)
+	})
+}
@@ -0,0 +1,159 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package accesslog implements the structured (JSON) access log of the public HTTP endpoint.
+//
+// Every request is counted in the metrics, successful requests are logged with the configured sampling rate,
+// failed requests are always logged.
+package accesslog
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/metrics"
+)
+
+// Entry is the access log entry.
+type Entry struct {
+	Time       time.Time `json:"time"`
+	Handler    string    `json:"handler"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	DurationMs float64   `json:"duration_ms"`
+	RemoteAddr string    `json:"remote_addr"`
+	UserAgent  string    `json:"user_agent,omitempty"`
+
+	// Machine identifiers from the query parameters (iPXE and metadata requests).
+	UUID   string `json:"uuid,omitempty"`
+	MAC    string `json:"mac,omitempty"`
+	Serial string `json:"serial,omitempty"`
+}
+
+// Logger writes the access log entries.
+type Logger struct {
+	// Sink is the destination of the log entries, entries are not logged if nil (requests are still counted).
+	Sink io.Writer
+	// SampleRate is the fraction of the successful requests logged (0 to 1).
+	SampleRate float64
+
+	mu     sync.Mutex
+	random *rand.Rand
+}
+
+// Handler wraps the mux, the mux patterns are used as the handler labels.
+func (l *Logger) Handler(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := mux.Handler(r)
+		if pattern == "" {
+			pattern = "unmatched"
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		start := time.Now()
+
+		mux.ServeHTTP(recorder, r)
+
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		metrics.HTTPRequests.WithLabelValues(pattern, strconv.Itoa(status)).Inc()
+
+		if !l.sampled(status) {
+			return
+		}
+
+		query := r.URL.Query()
+
+		l.write(&Entry{
+			Time:       start.UTC(),
+			Handler:    pattern,
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			Status:     status,
+			Bytes:      recorder.bytes,
+			DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+			RemoteAddr: r.RemoteAddr,
+			UserAgent:  r.UserAgent(),
+			UUID:       query.Get("uuid"),
+			MAC:        query.Get("mac"),
+			Serial:     query.Get("serial"),
+		})
+	})
+}
+
+func (l *Logger) sampled(status int) bool {
+	if l.Sink == nil {
+		return false
+	}
+
+	if status >= http.StatusBadRequest || l.SampleRate >= 1 {
+		return true
+	}
+
+	if l.SampleRate <= 0 {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.random == nil {
+		l.random = rand.New(rand.NewSource(time.Now().UnixNano())) //nolint:gosec
+	}
+
+	return l.random.Float64() < l.SampleRate
+}
+
+func (l *Logger) write(entry *Entry) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		log.Printf("failed to marshal access log entry: %s", err)
+
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err = l.Sink.Write(append(b, '\n')); err != nil {
+		log.Printf("failed to write access log entry: %s", err)
+	}
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+
+	status int
+	bytes  int64
+}
+
+func (r *responseRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+
+	return n, err
+}
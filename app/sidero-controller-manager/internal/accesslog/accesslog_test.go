@@ -0,0 +1,114 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package accesslog_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/accesslog"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/metrics"
+)
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/configdata", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("uuid") == "" {
+			http.Error(w, "no allocated server found", http.StatusNotFound)
+
+			return
+		}
+
+		w.Write([]byte("version: v1alpha1\n")) //nolint:errcheck
+	})
+
+	for name, tc := range map[string]struct {
+		sampleRate float64
+		expected   []accesslog.Entry
+	}{
+		"all": {
+			sampleRate: 1,
+			expected: []accesslog.Entry{
+				{Handler: "/configdata", Method: http.MethodGet, Path: "/configdata", Status: http.StatusOK, Bytes: 18, UUID: "4c4c4544-0036-4410-8052-b7c04f4e3532", MAC: "d0-50-99-d3-33-60"},
+				{Handler: "/configdata", Method: http.MethodGet, Path: "/configdata", Status: http.StatusNotFound, Bytes: 26, Serial: "6DR0QR2"},
+				{Handler: "unmatched", Method: http.MethodGet, Path: "/missing", Status: http.StatusNotFound, Bytes: 19},
+			},
+		},
+		"failures only": {
+			expected: []accesslog.Entry{
+				{Handler: "/configdata", Method: http.MethodGet, Path: "/configdata", Status: http.StatusNotFound, Bytes: 26, Serial: "6DR0QR2"},
+				{Handler: "unmatched", Method: http.MethodGet, Path: "/missing", Status: http.StatusNotFound, Bytes: 19},
+			},
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var sink bytes.Buffer
+
+			handler := (&accesslog.Logger{Sink: &sink, SampleRate: tc.sampleRate}).Handler(mux)
+
+			for _, target := range []string{
+				"/configdata?uuid=4c4c4544-0036-4410-8052-b7c04f4e3532&mac=d0-50-99-d3-33-60",
+				"/configdata?serial=6DR0QR2",
+				"/missing",
+			} {
+				handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+			}
+
+			var entries []accesslog.Entry
+
+			scanner := bufio.NewScanner(&sink)
+
+			for scanner.Scan() {
+				var entry accesslog.Entry
+
+				require.NoError(t, json.Unmarshal(scanner.Bytes(), &entry))
+
+				assert.False(t, entry.Time.IsZero())
+				assert.Equal(t, "192.0.2.1:1234", entry.RemoteAddr)
+
+				entry.Time = time.Time{}
+				entry.DurationMs = 0
+				entry.RemoteAddr = ""
+
+				entries = append(entries, entry)
+			}
+
+			assert.Equal(t, tc.expected, entries)
+		})
+	}
+}
+
+func TestHandlerMetrics(t *testing.T) {
+	t.Parallel()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ipxe", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("#!ipxe")) //nolint:errcheck
+	})
+
+	// requests are counted without the sink
+	handler := (&accesslog.Logger{}).Handler(mux)
+
+	for _, target := range []string{"/ipxe", "/ipxe?uuid=4c4c4544-0036-4410-8052-b7c04f4e3532"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.HTTPRequests.WithLabelValues("/ipxe", "200")))
+}
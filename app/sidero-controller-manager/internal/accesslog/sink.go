@@ -0,0 +1,91 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package accesslog
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// OpenSink opens the access log destination: `stdout`, a file path (appended to),
+// or a `tcp://host:port` or `udp://host:port` log collector (JSON lines).
+func OpenSink(destination string) (io.WriteCloser, error) {
+	if destination == "stdout" {
+		return nopCloser{os.Stdout}, nil
+	}
+
+	if u, err := url.Parse(destination); err == nil && (u.Scheme == "tcp" || u.Scheme == "udp") {
+		if u.Host == "" {
+			return nil, fmt.Errorf("missing host in access log destination %q", destination)
+		}
+
+		return &netSink{network: u.Scheme, address: u.Host}, nil
+	}
+
+	return os.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// netSink connects to the log collector on the first write, and reconnects after a failed write.
+type netSink struct {
+	mu      sync.Mutex
+	network string
+	address string
+	conn    net.Conn
+}
+
+const netSinkTimeout = 5 * time.Second
+
+func (s *netSink) Write(b []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.address, netSinkTimeout)
+		if err != nil {
+			return 0, err
+		}
+
+		s.conn = conn
+	}
+
+	if err := s.conn.SetWriteDeadline(time.Now().Add(netSinkTimeout)); err != nil {
+		return 0, err
+	}
+
+	n, err := s.conn.Write(b)
+	if err != nil {
+		s.conn.Close() //nolint:errcheck
+		s.conn = nil
+	}
+
+	return n, err
+}
+
+func (s *netSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return nil
+	}
+
+	err := s.conn.Close()
+	s.conn = nil
+
+	return err
+}
@@ -0,0 +1,71 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package accesslog_test
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/accesslog"
+)
+
+func TestOpenSink(t *testing.T) {
+	t.Parallel()
+
+	t.Run("file", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "access.log")
+
+		for _, line := range []string{"first\n", "second\n"} {
+			sink, err := accesslog.OpenSink(path)
+			require.NoError(t, err)
+
+			_, err = sink.Write([]byte(line))
+			require.NoError(t, err)
+
+			require.NoError(t, sink.Close())
+		}
+
+		contents, err := ioutil.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "first\nsecond\n", string(contents))
+	})
+
+	t.Run("tcp", func(t *testing.T) {
+		t.Parallel()
+
+		lis, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		defer lis.Close() //nolint:errcheck
+
+		sink, err := accesslog.OpenSink("tcp://" + lis.Addr().String())
+		require.NoError(t, err)
+
+		defer sink.Close() //nolint:errcheck
+
+		_, err = sink.Write([]byte("{}\n"))
+		require.NoError(t, err)
+
+		conn, err := lis.Accept()
+		require.NoError(t, err)
+
+		defer conn.Close() //nolint:errcheck
+
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		require.NoError(t, err)
+		assert.Equal(t, "{}\n", line)
+	})
+
+	_, err := accesslog.OpenSink("udp://")
+	assert.Error(t, err)
+}
@@ -24,6 +24,8 @@ type AgentClient interface {
 	ReconcileServerAddresses(ctx context.Context, in *ReconcileServerAddressesRequest, opts ...grpc.CallOption) (*ReconcileServerAddressesResponse, error)
 	Heartbeat(ctx context.Context, in *HeartbeatRequest, opts ...grpc.CallOption) (*HeartbeatResponse, error)
 	UpdateBMCInfo(ctx context.Context, in *UpdateBMCInfoRequest, opts ...grpc.CallOption) (*UpdateBMCInfoResponse, error)
+	UpdateInventory(ctx context.Context, in *UpdateInventoryRequest, opts ...grpc.CallOption) (*UpdateInventoryResponse, error)
+	RequestWipeConfirmation(ctx context.Context, in *RequestWipeConfirmationRequest, opts ...grpc.CallOption) (*RequestWipeConfirmationResponse, error)
 }
 
 type agentClient struct {
@@ -79,6 +81,24 @@ func (c *agentClient) UpdateBMCInfo(ctx context.Context, in *UpdateBMCInfoReques
 	return out, nil
 }
 
+func (c *agentClient) UpdateInventory(ctx context.Context, in *UpdateInventoryRequest, opts ...grpc.CallOption) (*UpdateInventoryResponse, error) {
+	out := new(UpdateInventoryResponse)
+	err := c.cc.Invoke(ctx, "/api.Agent/UpdateInventory", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *agentClient) RequestWipeConfirmation(ctx context.Context, in *RequestWipeConfirmationRequest, opts ...grpc.CallOption) (*RequestWipeConfirmationResponse, error) {
+	out := new(RequestWipeConfirmationResponse)
+	err := c.cc.Invoke(ctx, "/api.Agent/RequestWipeConfirmation", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // AgentServer is the server API for Agent service.
 // All implementations must embed UnimplementedAgentServer
 // for forward compatibility
@@ -88,6 +108,8 @@ type AgentServer interface {
 	ReconcileServerAddresses(context.Context, *ReconcileServerAddressesRequest) (*ReconcileServerAddressesResponse, error)
 	Heartbeat(context.Context, *HeartbeatRequest) (*HeartbeatResponse, error)
 	UpdateBMCInfo(context.Context, *UpdateBMCInfoRequest) (*UpdateBMCInfoResponse, error)
+	UpdateInventory(context.Context, *UpdateInventoryRequest) (*UpdateInventoryResponse, error)
+	RequestWipeConfirmation(context.Context, *RequestWipeConfirmationRequest) (*RequestWipeConfirmationResponse, error)
 	mustEmbedUnimplementedAgentServer()
 }
 
@@ -113,6 +135,14 @@ func (UnimplementedAgentServer) Heartbeat(context.Context, *HeartbeatRequest) (*
 func (UnimplementedAgentServer) UpdateBMCInfo(context.Context, *UpdateBMCInfoRequest) (*UpdateBMCInfoResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method UpdateBMCInfo not implemented")
 }
+
+func (UnimplementedAgentServer) UpdateInventory(context.Context, *UpdateInventoryRequest) (*UpdateInventoryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method UpdateInventory not implemented")
+}
+
+func (UnimplementedAgentServer) RequestWipeConfirmation(context.Context, *RequestWipeConfirmationRequest) (*RequestWipeConfirmationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RequestWipeConfirmation not implemented")
+}
 func (UnimplementedAgentServer) mustEmbedUnimplementedAgentServer() {}
 
 // UnsafeAgentServer may be embedded to opt out of forward compatibility for this service.
@@ -216,6 +246,42 @@ func _Agent_UpdateBMCInfo_Handler(srv interface{}, ctx context.Context, dec func
 	return interceptor(ctx, in, info, handler)
 }
 
+func _Agent_UpdateInventory_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(UpdateInventoryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServer).UpdateInventory(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Agent/UpdateInventory",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServer).UpdateInventory(ctx, req.(*UpdateInventoryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Agent_RequestWipeConfirmation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RequestWipeConfirmationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AgentServer).RequestWipeConfirmation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/api.Agent/RequestWipeConfirmation",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AgentServer).RequestWipeConfirmation(ctx, req.(*RequestWipeConfirmationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // Agent_ServiceDesc is the grpc.ServiceDesc for Agent service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -243,6 +309,14 @@ var Agent_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "UpdateBMCInfo",
 			Handler:    _Agent_UpdateBMCInfo_Handler,
 		},
+		{
+			MethodName: "UpdateInventory",
+			Handler:    _Agent_UpdateInventory_Handler,
+		},
+		{
+			MethodName: "RequestWipeConfirmation",
+			Handler:    _Agent_RequestWipeConfirmation_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "api.proto",
@@ -0,0 +1,198 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package assets downloads environment assets and serves them with content encoding negotiation.
+package assets
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// minCompressionRatio is the maximum size of the precompressed variant relative to the original.
+//
+// Kernel and initramfs are usually compressed already, so recompressing them rarely pays off.
+const minCompressionRatio = 0.95
+
+// Options configures asset serving.
+//
+// Encodings are negotiated only with the clients sending `Accept-Encoding`: iPXE doesn't, so it always receives the original file.
+type Options struct {
+	// Encodings lists supported encodings in the order of server preference.
+	Encodings []Encoding
+	// OnTheFly enables compression of the response if there's no precompressed variant.
+	OnTheFly bool
+}
+
+type handler struct {
+	root       string
+	options    Options
+	fileServer http.Handler
+}
+
+// NewHandler returns a HTTP handler serving files from root.
+//
+// Precompressed variants (see Precompress) are served if the client accepts the encoding.
+func NewHandler(root string, options Options) http.Handler {
+	return &handler{
+		root:       root,
+		options:    options,
+		fileServer: http.FileServer(http.Dir(root)),
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := path.Clean("/" + r.URL.Path)
+	filename := filepath.Join(h.root, filepath.FromSlash(name))
+
+	st, err := os.Stat(filename)
+	if err != nil || st.IsDir() || len(h.options.Encodings) == 0 {
+		h.fileServer.ServeHTTP(w, r)
+
+		return
+	}
+
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	accepted := negotiate(r.Header.Get("Accept-Encoding"), h.options.Encodings)
+
+	for _, encoding := range accepted {
+		if h.servePrecompressed(w, r, filename, st, encoding) {
+			return
+		}
+	}
+
+	if h.options.OnTheFly && len(accepted) > 0 && r.Method != http.MethodHead && r.Header.Get("Range") == "" {
+		h.serveCompressed(w, filename, accepted[0])
+
+		return
+	}
+
+	h.fileServer.ServeHTTP(w, r)
+}
+
+func (h *handler) servePrecompressed(w http.ResponseWriter, r *http.Request, filename string, st os.FileInfo, encoding Encoding) bool {
+	f, err := os.Open(filename + encoding.Extension())
+	if err != nil {
+		return false
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	compressedSt, err := f.Stat()
+	if err != nil || compressedSt.ModTime().Before(st.ModTime()) {
+		return false
+	}
+
+	w.Header().Set("Content-Encoding", string(encoding))
+
+	http.ServeContent(w, r, filepath.Base(filename), compressedSt.ModTime(), f)
+
+	return true
+}
+
+func (h *handler) serveCompressed(w http.ResponseWriter, filename string, encoding Encoding) {
+	f, err := os.Open(filename)
+	if err != nil {
+		http.Error(w, "404 page not found", http.StatusNotFound)
+
+		return
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Encoding", string(encoding))
+
+	zw, err := encoding.NewWriter(w)
+	if err != nil {
+		log.Printf("failed to create %s writer: %s", encoding, err)
+
+		return
+	}
+
+	if _, err = io.Copy(zw, f); err != nil {
+		log.Printf("failed to serve %q: %s", filename, err)
+	}
+
+	if err = zw.Close(); err != nil {
+		log.Printf("failed to serve %q: %s", filename, err)
+	}
+}
+
+// Precompress creates compressed variants of the file for each encoding.
+//
+// Variants which are not meaningfully smaller than the original are removed, so
+// that the handler falls back to serving the original file.
+func Precompress(filename string, encodings ...Encoding) error {
+	st, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+
+	for _, encoding := range encodings {
+		size, err := compressFile(filename, filename+encoding.Extension(), encoding)
+		if err != nil {
+			return err
+		}
+
+		if float64(size) > float64(st.Size())*minCompressionRatio {
+			if err = os.Remove(filename + encoding.Extension()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func compressFile(src, dst string, encoding Encoding) (int64, error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return 0, err
+	}
+
+	defer in.Close() //nolint:errcheck
+
+	out, err := os.CreateTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".*")
+	if err != nil {
+		return 0, err
+	}
+
+	defer os.Remove(out.Name()) //nolint:errcheck
+
+	defer out.Close() //nolint:errcheck
+
+	zw, err := encoding.NewWriter(out)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err = io.Copy(zw, in); err != nil {
+		return 0, err
+	}
+
+	if err = zw.Close(); err != nil {
+		return 0, err
+	}
+
+	st, err := out.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = out.Close(); err != nil {
+		return 0, err
+	}
+
+	if err = os.Rename(out.Name(), dst); err != nil {
+		return 0, err
+	}
+
+	return st.Size(), nil
+}
@@ -0,0 +1,162 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package assets_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/assets"
+)
+
+func TestParseEncodings(t *testing.T) {
+	t.Parallel()
+
+	encodings, err := assets.ParseEncodings("zstd, gzip")
+	require.NoError(t, err)
+	assert.Equal(t, []assets.Encoding{assets.EncodingZstd, assets.EncodingGzip}, encodings)
+
+	encodings, err = assets.ParseEncodings("")
+	require.NoError(t, err)
+	assert.Empty(t, encodings)
+
+	_, err = assets.ParseEncodings("br")
+	assert.Error(t, err)
+}
+
+func decode(t *testing.T, encoding string, body []byte) []byte {
+	t.Helper()
+
+	var (
+		r   io.Reader
+		err error
+	)
+
+	switch encoding {
+	case "zstd":
+		var zr *zstd.Decoder
+
+		zr, err = zstd.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+
+		defer zr.Close()
+
+		r = zr
+	case "gzip":
+		r, err = gzip.NewReader(bytes.NewReader(body))
+		require.NoError(t, err)
+	default:
+		return body
+	}
+
+	decoded, err := io.ReadAll(r)
+	require.NoError(t, err)
+
+	return decoded
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	content := bytes.Repeat([]byte("sidero initramfs "), 4096)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "initramfs.xz"), content, 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "plain"), content, 0o644))
+	require.NoError(t, assets.Precompress(filepath.Join(dir, "initramfs.xz"), assets.EncodingZstd, assets.EncodingGzip))
+
+	for name, tc := range map[string]struct {
+		path           string
+		acceptEncoding string
+		onTheFly       bool
+
+		expectedEncoding string
+	}{
+		"identity": {
+			path: "/initramfs.xz",
+		},
+		"zstd": {
+			path:             "/initramfs.xz",
+			acceptEncoding:   "gzip, zstd",
+			expectedEncoding: "zstd",
+		},
+		"gzip preferred": {
+			path:             "/initramfs.xz",
+			acceptEncoding:   "gzip;q=1.0, zstd;q=0.5",
+			expectedEncoding: "gzip",
+		},
+		"zstd rejected": {
+			path:             "/initramfs.xz",
+			acceptEncoding:   "*, zstd;q=0",
+			expectedEncoding: "gzip",
+		},
+		"not precompressed": {
+			path:           "/plain",
+			acceptEncoding: "zstd",
+		},
+		"on the fly": {
+			path:             "/plain",
+			acceptEncoding:   "gzip",
+			onTheFly:         true,
+			expectedEncoding: "gzip",
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			handler := assets.NewHandler(dir, assets.Options{
+				Encodings: []assets.Encoding{assets.EncodingZstd, assets.EncodingGzip},
+				OnTheFly:  tc.onTheFly,
+			})
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tc.expectedEncoding, w.Header().Get("Content-Encoding"))
+			assert.Equal(t, content, decode(t, tc.expectedEncoding, w.Body.Bytes()))
+		})
+	}
+}
+
+func TestPrecompressIncompressible(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "vmlinuz")
+
+	// random data doesn't compress, just like already compressed kernel
+	content := make([]byte, 65536)
+
+	_, err := rand.New(rand.NewSource(1)).Read(content)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(filename, content, 0o644))
+	require.NoError(t, assets.Precompress(filename, assets.EncodingZstd, assets.EncodingGzip))
+
+	_, err = os.Stat(filename + assets.EncodingZstd.Extension())
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = os.Stat(filename + assets.EncodingGzip.Extension())
+	assert.True(t, os.IsNotExist(err))
+}
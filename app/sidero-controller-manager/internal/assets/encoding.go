@@ -0,0 +1,142 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package assets
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Encoding is a HTTP content encoding supported for asset delivery.
+type Encoding string
+
+// Supported encodings.
+const (
+	EncodingZstd Encoding = "zstd"
+	EncodingGzip Encoding = "gzip"
+)
+
+// Extension returns the file name suffix of the precompressed asset variant.
+func (e Encoding) Extension() string {
+	switch e {
+	case EncodingZstd:
+		return ".zst"
+	case EncodingGzip:
+		return ".gz"
+	default:
+		return ""
+	}
+}
+
+// NewWriter wraps w with the compressor for the encoding.
+func (e Encoding) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	switch e {
+	case EncodingZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+	case EncodingGzip:
+		return gzip.NewWriterLevel(w, gzip.BestCompression)
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", e)
+	}
+}
+
+// ParseEncodings parses comma-separated list of encodings.
+func ParseEncodings(s string) ([]Encoding, error) {
+	var encodings []Encoding
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+
+		switch Encoding(item) {
+		case "":
+			continue
+		case EncodingZstd, EncodingGzip:
+			encodings = append(encodings, Encoding(item))
+		default:
+			return nil, fmt.Errorf("unsupported encoding %q", item)
+		}
+	}
+
+	return encodings, nil
+}
+
+// negotiate picks the encodings acceptable for the client based on the Accept-Encoding header.
+//
+// Encodings are returned in the order of client preference (q-value), ties are resolved
+// using the order of the supported list (server preference).
+func negotiate(acceptEncoding string, supported []Encoding) []Encoding {
+	weights := map[Encoding]float64{}
+	wildcard := -1.0
+
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		parts := strings.Split(item, ";")
+
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+
+			if strings.HasPrefix(param, "q=") {
+				parsed, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64)
+				if err != nil {
+					q = 0
+
+					break
+				}
+
+				q = parsed
+			}
+		}
+
+		if name == "*" {
+			wildcard = q
+
+			continue
+		}
+
+		weights[Encoding(name)] = q
+	}
+
+	type candidate struct {
+		encoding Encoding
+		weight   float64
+	}
+
+	candidates := make([]candidate, 0, len(supported))
+
+	for _, encoding := range supported {
+		weight, ok := weights[encoding]
+		if !ok {
+			weight = wildcard
+		}
+
+		if weight <= 0 {
+			continue
+		}
+
+		candidates = append(candidates, candidate{encoding, weight})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].weight > candidates[j].weight })
+
+	result := make([]Encoding, 0, len(candidates))
+
+	for _, c := range candidates {
+		result = append(result, c.encoding)
+	}
+
+	return result
+}
@@ -0,0 +1,245 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package assets
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ErrChecksumMismatch is returned if the downloaded asset doesn't match the expected checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// Progress is called while the asset is downloaded, total is zero if the size is not known.
+type Progress func(downloaded, total int64)
+
+// Cache keeps the downloaded assets by URL, so that the environments sharing the assets
+// (e.g. built from the same Image Factory schematic) download them once.
+//
+// Assets are verified with SHA512 on download, the checksum is kept next to the cached asset.
+type Cache struct {
+	dir string
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewCache returns the cache keeping the assets in the directory.
+func NewCache(dir string) *Cache {
+	return &Cache{
+		dir:   dir,
+		locks: map[string]*sync.Mutex{},
+	}
+}
+
+func (c *Cache) lock(key string) func() {
+	c.mu.Lock()
+
+	l, ok := c.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		c.locks[key] = l
+	}
+
+	c.mu.Unlock()
+
+	l.Lock()
+
+	return l.Unlock
+}
+
+// Fetch places the asset downloaded from the URL at dest, and returns the SHA512 checksum of the asset.
+//
+// If expectedSHA512 is set, the asset is verified against it, and ErrChecksumMismatch is returned
+// if it doesn't match; cached asset with another checksum is downloaded again.
+func (c *Cache) Fetch(ctx context.Context, url, expectedSHA512, dest string, progress Progress) (string, error) {
+	if url == "" {
+		return "", errors.New("missing URL")
+	}
+
+	sum := sha256.Sum256([]byte(url))
+	key := hex.EncodeToString(sum[:])
+
+	defer c.lock(key)()
+
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return "", err
+	}
+
+	cached := filepath.Join(c.dir, key)
+
+	digest, err := c.cachedDigest(cached)
+	if err != nil {
+		return "", err
+	}
+
+	if digest == "" || (expectedSHA512 != "" && !strings.EqualFold(digest, expectedSHA512)) {
+		if digest, err = download(ctx, url, cached, progress); err != nil {
+			return "", err
+		}
+	}
+
+	if expectedSHA512 != "" && !strings.EqualFold(digest, expectedSHA512) {
+		return "", fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, strings.ToLower(expectedSHA512), digest)
+	}
+
+	if err = install(cached, dest); err != nil {
+		return "", err
+	}
+
+	return digest, nil
+}
+
+// cachedDigest returns the checksum of the cached asset, or empty string if it's not cached.
+func (c *Cache) cachedDigest(cached string) (string, error) {
+	digest, err := os.ReadFile(cached + ".sha512")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	if _, err = os.Stat(cached); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", nil
+		}
+
+		return "", err
+	}
+
+	return strings.TrimSpace(string(digest)), nil
+}
+
+// download saves the URL to the file and the checksum next to it.
+func download(ctx context.Context, url, file string, progress Progress) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("failed to download asset: %d", resp.StatusCode)
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(file), "."+filepath.Base(file)+".*")
+	if err != nil {
+		return "", err
+	}
+
+	defer os.Remove(out.Name()) //nolint:errcheck
+
+	defer out.Close() //nolint:errcheck
+
+	total := resp.ContentLength
+	if total < 0 {
+		total = 0
+	}
+
+	hash := sha512.New()
+
+	w := io.MultiWriter(out, hash)
+	if progress != nil {
+		w = &progressWriter{w: w, total: total, progress: progress}
+	}
+
+	if _, err = io.Copy(w, resp.Body); err != nil {
+		return "", err
+	}
+
+	if err = out.Close(); err != nil {
+		return "", err
+	}
+
+	digest := hex.EncodeToString(hash.Sum(nil))
+
+	// remove the stale checksum first, so that the interrupted update is not mistaken for the cached asset
+	if err = os.Remove(file + ".sha512"); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", err
+	}
+
+	if err = os.Rename(out.Name(), file); err != nil {
+		return "", err
+	}
+
+	return digest, os.WriteFile(file+".sha512", []byte(digest+"\n"), 0o644)
+}
+
+// install atomically replaces dest with the cached asset, hard linking it if possible.
+func install(cached, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(filepath.Dir(dest), "."+filepath.Base(dest)+".install")
+
+	if err := os.Remove(tmp); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if err := os.Link(cached, tmp); err != nil {
+		if err = copyFile(cached, tmp); err != nil {
+			return err
+		}
+	}
+
+	return os.Rename(tmp, dest)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer in.Close() //nolint:errcheck
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close() //nolint:errcheck
+
+		return err
+	}
+
+	return out.Close()
+}
+
+type progressWriter struct {
+	w          io.Writer
+	downloaded int64
+	total      int64
+	progress   Progress
+}
+
+func (p *progressWriter) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+
+	p.downloaded += int64(n)
+	p.progress(p.downloaded, p.total)
+
+	return n, err
+}
@@ -0,0 +1,93 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package assets_test
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/assets"
+)
+
+func TestFetch(t *testing.T) {
+	t.Parallel()
+
+	content := []byte(strings.Repeat("vmlinuz", 1024))
+
+	sum := sha512.Sum512(content)
+	digest := hex.EncodeToString(sum[:])
+
+	var requests int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		if r.URL.Path != "/vmlinuz" {
+			http.NotFound(w, r)
+
+			return
+		}
+
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Write(content) //nolint:errcheck
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	cache := assets.NewCache(filepath.Join(dir, "cache"))
+
+	ctx := context.Background()
+
+	var downloaded, total int64
+
+	got, err := cache.Fetch(ctx, srv.URL+"/vmlinuz", "", filepath.Join(dir, "env-1", "vmlinuz"), func(d, t int64) {
+		downloaded, total = d, t
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, digest, got)
+	assert.Equal(t, int64(len(content)), downloaded)
+	assert.Equal(t, int64(len(content)), total)
+
+	// cached asset is verified against the pinned checksum without downloading it again
+	got, err = cache.Fetch(ctx, srv.URL+"/vmlinuz", strings.ToUpper(digest), filepath.Join(dir, "env-2", "vmlinuz"), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, digest, got)
+	assert.EqualValues(t, 1, atomic.LoadInt32(&requests))
+
+	for _, env := range []string{"env-1", "env-2"} {
+		data, err := os.ReadFile(filepath.Join(dir, env, "vmlinuz"))
+		require.NoError(t, err)
+
+		assert.Equal(t, content, data)
+	}
+
+	// checksum mismatch downloads the asset again, and fails
+	_, err = cache.Fetch(ctx, srv.URL+"/vmlinuz", strings.Repeat("0", 128), filepath.Join(dir, "env-3", "vmlinuz"), nil)
+	require.Error(t, err)
+
+	assert.True(t, errors.Is(err, assets.ErrChecksumMismatch))
+	assert.EqualValues(t, 2, atomic.LoadInt32(&requests))
+
+	_, err = os.Stat(filepath.Join(dir, "env-3", "vmlinuz"))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = cache.Fetch(ctx, srv.URL+"/missing", "", filepath.Join(dir, "env-1", "initramfs.xz"), nil)
+	assert.EqualError(t, err, "failed to download asset: 404")
+}
@@ -0,0 +1,248 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package console captures serial consoles of the servers via IPMI Serial-over-LAN.
+//
+// Console output is appended to the log file of the server in the log directory,
+// and it is streamed to the followers over HTTP.
+package console
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// DefaultMaxSize is the default limit of the console log size per server.
+const DefaultMaxSize = 4 * 1024 * 1024
+
+// Dialer opens the console session of the server.
+//
+// Output is read until the reader returns an error, the reader is closed to terminate the session.
+type Dialer func(ctx context.Context, bmc metalv1alpha1.BMC) (io.ReadCloser, error)
+
+// Options configures the console capture.
+type Options struct {
+	// Directory to keep the console logs in.
+	Directory string
+	// MaxSize of the console log of each server, older output is discarded.
+	MaxSize int64
+	// RetryInterval between the attempts to open the session.
+	RetryInterval time.Duration
+	// Dialer opens the sessions.
+	Dialer Dialer
+}
+
+type session struct {
+	bmc    metalv1alpha1.BMC
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Manager keeps the console sessions of the servers.
+//
+// Manager implements manager.Runnable to close the sessions on shutdown.
+// Manager is started on the elected leader only, which captures the consoles and serves the logs.
+type Manager struct {
+	options Options
+	log     logr.Logger
+
+	mu       sync.Mutex
+	leading  bool
+	sessions map[string]*session
+	logs     map[string]*logFile
+}
+
+// NewManager initializes the manager.
+func NewManager(log logr.Logger, options Options) *Manager {
+	if options.MaxSize <= 0 {
+		options.MaxSize = DefaultMaxSize
+	}
+
+	if options.RetryInterval <= 0 {
+		options.RetryInterval = 30 * time.Second
+	}
+
+	return &Manager{
+		options:  options,
+		log:      log,
+		sessions: map[string]*session{},
+		logs:     map[string]*logFile{},
+	}
+}
+
+// Start implements manager.Runnable.
+func (m *Manager) Start(stop <-chan struct{}) error {
+	m.mu.Lock()
+	m.leading = true
+	m.mu.Unlock()
+
+	<-stop
+
+	m.mu.Lock()
+
+	m.leading = false
+
+	names := make([]string, 0, len(m.sessions))
+
+	for name := range m.sessions {
+		names = append(names, name)
+	}
+
+	m.mu.Unlock()
+
+	for _, name := range names {
+		m.Stop(name)
+	}
+
+	return nil
+}
+
+// Leading returns true if the manager is started, i.e. it runs on the elected leader.
+func (m *Manager) Leading() bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.leading
+}
+
+// Capture starts capturing the console of the server.
+//
+// If the session is already running with different BMC settings, it is restarted.
+// Capture returns true if the new session was started.
+func (m *Manager) Capture(name string, bmc metalv1alpha1.BMC) (bool, error) {
+	l, err := m.logFile(name)
+	if err != nil {
+		return false, err
+	}
+
+	m.mu.Lock()
+	s, ok := m.sessions[name]
+	m.mu.Unlock()
+
+	if ok {
+		if reflect.DeepEqual(s.bmc, bmc) {
+			return false, nil
+		}
+
+		m.Stop(name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok = m.sessions[name]; ok {
+		// started concurrently
+		return false, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	s = &session{
+		bmc:    bmc,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+
+	m.sessions[name] = s
+
+	go m.run(ctx, name, s, l)
+
+	return true, nil
+}
+
+// Stop capturing the console of the server.
+//
+// Stop returns true if the session was running.
+func (m *Manager) Stop(name string) bool {
+	m.mu.Lock()
+
+	s, ok := m.sessions[name]
+	delete(m.sessions, name)
+
+	m.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	s.cancel()
+	<-s.done
+
+	return true
+}
+
+// Active returns true if the console of the server is being captured.
+func (m *Manager) Active(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, ok := m.sessions[name]
+
+	return ok
+}
+
+func (m *Manager) run(ctx context.Context, name string, s *session, l *logFile) {
+	defer close(s.done)
+	defer l.close()
+
+	log := m.log.WithValues("server", name)
+
+	for {
+		if err := m.capture(ctx, s, l); err != nil && ctx.Err() == nil {
+			log.Error(err, "console session failed")
+		}
+
+		select {
+		case <-ctx.Done():
+			l.Write([]byte(fmt.Sprintf("\n--- console capture stopped at %s ---\n", time.Now().UTC().Format(time.RFC3339)))) //nolint:errcheck
+
+			return
+		case <-time.After(m.options.RetryInterval):
+		}
+	}
+}
+
+func (m *Manager) capture(ctx context.Context, s *session, l *logFile) error {
+	rc, err := m.options.Dialer(ctx, s.bmc)
+	if err != nil {
+		return err
+	}
+
+	var closeOnce sync.Once
+
+	closeSession := func() {
+		closeOnce.Do(func() {
+			rc.Close() //nolint:errcheck
+		})
+	}
+
+	defer closeSession()
+
+	stopped := make(chan struct{})
+	defer close(stopped)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			closeSession()
+		case <-stopped:
+		}
+	}()
+
+	if _, err = l.Write([]byte(fmt.Sprintf("\n--- console session opened at %s ---\n", time.Now().UTC().Format(time.RFC3339)))); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(l, rc)
+
+	return err
+}
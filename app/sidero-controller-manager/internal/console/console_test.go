@@ -0,0 +1,222 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package console_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/console"
+)
+
+// fakeDialer returns the sessions writing the lines to the console.
+func fakeDialer(lines chan string) console.Dialer {
+	return func(ctx context.Context, bmc metalv1alpha1.BMC) (io.ReadCloser, error) {
+		r, w := io.Pipe()
+
+		go func() {
+			for {
+				select {
+				case line := <-lines:
+					if _, err := w.Write([]byte(line + "\n")); err != nil {
+						return
+					}
+				case <-ctx.Done():
+					w.CloseWithError(ctx.Err())
+
+					return
+				}
+			}
+		}()
+
+		return r, nil
+	}
+}
+
+func newManager(t *testing.T, lines chan string, maxSize int64) (*console.Manager, string) {
+	dir := t.TempDir()
+
+	return console.NewManager(log.NullLogger{}, console.Options{
+		Directory:     dir,
+		MaxSize:       maxSize,
+		RetryInterval: 10 * time.Millisecond,
+		Dialer:        fakeDialer(lines),
+	}), dir
+}
+
+func TestCapture(t *testing.T) {
+	t.Parallel()
+
+	lines := make(chan string)
+	m, dir := newManager(t, lines, 0)
+
+	bmc := metalv1alpha1.BMC{Endpoint: "10.0.0.25", User: "admin", Pass: "password"}
+
+	started, err := m.Capture("server-1", bmc)
+	require.NoError(t, err)
+	assert.True(t, started)
+	assert.True(t, m.Active("server-1"))
+
+	// same settings, session keeps running
+	started, err = m.Capture("server-1", bmc)
+	require.NoError(t, err)
+	assert.False(t, started)
+
+	lines <- "iPXE initialising devices..."
+	lines <- "Kernel panic - not syncing: VFS: Unable to mount root fs"
+
+	logPath := filepath.Join(dir, "server-1.log")
+
+	require.Eventually(t, func() bool {
+		contents, err := os.ReadFile(logPath)
+
+		return err == nil && strings.Contains(string(contents), "Kernel panic")
+	}, time.Second, 10*time.Millisecond)
+
+	assert.True(t, m.Stop("server-1"))
+	assert.False(t, m.Active("server-1"))
+	assert.False(t, m.Stop("server-1"))
+
+	contents, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(contents), "--- console session opened at ")
+	assert.Contains(t, string(contents), "iPXE initialising devices...\nKernel panic")
+	assert.Contains(t, string(contents), "--- console capture stopped at ")
+
+	_, err = m.Capture("../server-1", bmc)
+	assert.Error(t, err)
+}
+
+func TestRotation(t *testing.T) {
+	t.Parallel()
+
+	lines := make(chan string)
+	m, dir := newManager(t, lines, 256)
+
+	_, err := m.Capture("server-1", metalv1alpha1.BMC{Endpoint: "10.0.0.25"})
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		lines <- strings.Repeat("x", 31)
+	}
+
+	// the last line is delivered
+	lines <- "last"
+
+	require.Eventually(t, func() bool {
+		contents, err := os.ReadFile(filepath.Join(dir, "server-1.log"))
+
+		return err == nil && strings.Contains(string(contents), "last\n")
+	}, time.Second, 10*time.Millisecond)
+
+	m.Stop("server-1")
+
+	for _, name := range []string{"server-1.log", "server-1.log.1"} {
+		st, err := os.Stat(filepath.Join(dir, name))
+		require.NoError(t, err)
+
+		assert.LessOrEqual(t, st.Size(), int64(128))
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	t.Parallel()
+
+	lines := make(chan string)
+	m, _ := newManager(t, lines, 0)
+
+	mux := http.NewServeMux()
+	m.RegisterHandler(mux)
+
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// logs are served only once the manager is started on the leader
+	resp, err := http.Get(srv.URL + "/console/server-1")
+	require.NoError(t, err)
+	resp.Body.Close() //nolint:errcheck
+
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go m.Start(stop) //nolint:errcheck
+
+	require.Eventually(t, m.Leading, time.Second, 10*time.Millisecond)
+
+	resp, err = http.Get(srv.URL + "/console/server-1")
+	require.NoError(t, err)
+	resp.Body.Close() //nolint:errcheck
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	_, err = m.Capture("server-1", metalv1alpha1.BMC{Endpoint: "10.0.0.25"})
+	require.NoError(t, err)
+
+	defer m.Stop("server-1")
+
+	lines <- "first"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// wait for the first line to be written, so that it's returned as part of the log
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(srv.URL + "/console/server-1")
+		if err != nil {
+			return false
+		}
+
+		defer resp.Body.Close() //nolint:errcheck
+
+		body, err := io.ReadAll(resp.Body)
+
+		return err == nil && strings.Contains(string(body), "first\n")
+	}, time.Second, 10*time.Millisecond)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/console/server-1?follow=true", nil)
+	require.NoError(t, err)
+
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	scanner := bufio.NewScanner(resp.Body)
+
+	var got []string
+
+	for scanner.Scan() {
+		got = append(got, scanner.Text())
+
+		if scanner.Text() == "first" {
+			// followed output
+			lines <- "second"
+		}
+
+		if scanner.Text() == "second" {
+			break
+		}
+	}
+
+	assert.Equal(t, []string{"first", "second"}, got[len(got)-2:])
+}
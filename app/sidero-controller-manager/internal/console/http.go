@@ -0,0 +1,86 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package console
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// PathPrefix is the HTTP path prefix of the console logs, followed by the server name.
+const PathPrefix = "/console/"
+
+// RegisterHandler registers the HTTP handler serving the console logs.
+//
+// `GET /console/<server>` returns the captured console log, `?follow=true` keeps streaming the new output.
+// Logs are served only by the elected leader, other replicas respond with `503 Service Unavailable`.
+func (m *Manager) RegisterHandler(mux *http.ServeMux) {
+	mux.HandleFunc(PathPrefix, m.serveHTTP)
+}
+
+func (m *Manager) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	if !m.Leading() {
+		http.Error(w, "console logs are captured and served by the elected leader only", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, PathPrefix)
+
+	follow, _ := strconv.ParseBool(r.URL.Query().Get("follow"))
+
+	l, err := m.logFile(name)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	contents, ch, err := l.snapshot(follow)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			http.Error(w, "no console log for the server", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if _, err = w.Write(contents); err != nil || ch == nil {
+		return
+	}
+
+	defer l.unsubscribe(ch)
+
+	flusher, _ := w.(http.Flusher)
+
+	for {
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case chunk := <-ch:
+			if _, err = w.Write(chunk); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package console
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// followerBuffer is the number of chunks buffered for each follower, slow followers miss the output.
+const followerBuffer = 256
+
+// logFile is the console log of the server.
+//
+// Log is rotated when it reaches half of the max size, so that the current and the rotated log
+// fit into the max size.
+type logFile struct {
+	path    string
+	maxSize int64
+
+	mu        sync.Mutex
+	f         *os.File
+	size      int64
+	followers map[chan []byte]struct{}
+}
+
+// validName checks that the server name can be used as a file name.
+func validName(name string) bool {
+	return name != "" && name != "." && name != ".." && filepath.Base(name) == name
+}
+
+func (m *Manager) logFile(name string) (*logFile, error) {
+	if !validName(name) {
+		return nil, fmt.Errorf("invalid server name %q", name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.logs[name]
+	if !ok {
+		l = &logFile{
+			path:      filepath.Join(m.options.Directory, name+".log"),
+			maxSize:   m.options.MaxSize,
+			followers: map[chan []byte]struct{}{},
+		}
+
+		m.logs[name] = l
+	}
+
+	return l, nil
+}
+
+// Write implements io.Writer.
+func (l *logFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f != nil && l.size+int64(len(p)) > l.maxSize/2 {
+		if err := l.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	if l.f == nil {
+		if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+			return 0, err
+		}
+
+		f, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return 0, err
+		}
+
+		st, err := f.Stat()
+		if err != nil {
+			f.Close() //nolint:errcheck
+
+			return 0, err
+		}
+
+		l.f = f
+		l.size = st.Size()
+	}
+
+	n, err := l.f.Write(p)
+	l.size += int64(n)
+
+	chunk := append([]byte(nil), p[:n]...)
+
+	for ch := range l.followers {
+		select {
+		case ch <- chunk:
+		default:
+		}
+	}
+
+	return n, err
+}
+
+func (l *logFile) rotate() error {
+	if err := l.f.Close(); err != nil {
+		return err
+	}
+
+	l.f = nil
+	l.size = 0
+
+	return os.Rename(l.path, l.path+".1")
+}
+
+func (l *logFile) close() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.f != nil {
+		l.f.Close() //nolint:errcheck
+
+		l.f = nil
+	}
+}
+
+// snapshot returns the log contents (rotated log first) and subscribes to the new output if follow is set.
+func (l *logFile) snapshot(follow bool) ([]byte, chan []byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var contents []byte
+
+	for _, path := range []string{l.path + ".1", l.path} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			return nil, nil, err
+		}
+
+		contents = append(contents, data...)
+	}
+
+	if contents == nil && !follow {
+		return nil, nil, os.ErrNotExist
+	}
+
+	if !follow {
+		return contents, nil, nil
+	}
+
+	ch := make(chan []byte, followerBuffer)
+	l.followers[ch] = struct{}{}
+
+	return contents, ch, nil
+}
+
+func (l *logFile) unsubscribe(ch chan []byte) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.followers, ch)
+}
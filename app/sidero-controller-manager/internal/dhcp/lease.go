@@ -0,0 +1,165 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dhcp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// DefaultLeaseDuration is the lease duration used if the reservation doesn't set it.
+const DefaultLeaseDuration = time.Hour
+
+// Lease is the reserved lease.
+type Lease struct {
+	// Server is the name of the Server the lease is reserved for.
+	Server string
+
+	Address      net.IP
+	Mask         net.IPMask
+	Gateway      net.IP
+	DNSServers   []net.IP
+	NTPServers   []net.IP
+	DomainSearch []string
+	Hostname     string
+	MTU          uint16
+	Duration     time.Duration
+}
+
+// Leases resolves the leases reserved for the clients.
+type Leases interface {
+	// Lookup returns the lease reserved for the hardware address, nil if there is none.
+	Lookup(ctx context.Context, mac net.HardwareAddr) (*Lease, error)
+}
+
+// ServerLeases resolves the leases reserved in the Servers (see DHCPReservation).
+type ServerLeases struct {
+	Reader client.Reader
+}
+
+// Lookup implements Leases.
+func (l *ServerLeases) Lookup(ctx context.Context, mac net.HardwareAddr) (*Lease, error) {
+	var servers metalv1alpha1.ServerList
+
+	if err := l.Reader.List(ctx, &servers); err != nil {
+		return nil, err
+	}
+
+	// the same interface reserved in several servers is resolved consistently
+	sort.Slice(servers.Items, func(i, j int) bool { return servers.Items[i].Name < servers.Items[j].Name })
+
+	for i := range servers.Items {
+		server := &servers.Items[i]
+
+		for j := range server.Spec.DHCPReservations {
+			reservation := &server.Spec.DHCPReservations[j]
+
+			if !reservationMatches(server, reservation, mac) {
+				continue
+			}
+
+			lease, err := NewLease(server, reservation)
+			if err != nil {
+				return nil, fmt.Errorf("server %q: %w", server.Name, err)
+			}
+
+			return lease, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// reservationMatches checks whether the reservation is for the interface with the hardware address.
+func reservationMatches(server *metalv1alpha1.Server, reservation *metalv1alpha1.DHCPReservation, mac net.HardwareAddr) bool {
+	if reserved, err := net.ParseMAC(reservation.Interface); err == nil {
+		return bytes.Equal(reserved, mac)
+	}
+
+	if server.Status.Inventory == nil {
+		return false
+	}
+
+	for _, iface := range server.Status.Inventory.NetworkInterfaces {
+		if iface.Name != reservation.Interface {
+			continue
+		}
+
+		if reported, err := net.ParseMAC(iface.MAC); err == nil && bytes.Equal(reported, mac) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// NewLease builds the lease from the reservation.
+func NewLease(server *metalv1alpha1.Server, reservation *metalv1alpha1.DHCPReservation) (*Lease, error) {
+	ip, network, err := net.ParseCIDR(reservation.Address)
+	if err != nil || ip.To4() == nil {
+		return nil, fmt.Errorf("invalid IPv4 address %q in the DHCP reservation of %q", reservation.Address, reservation.Interface)
+	}
+
+	lease := &Lease{
+		Server:       server.Name,
+		Address:      ip.To4(),
+		Mask:         network.Mask,
+		DomainSearch: reservation.DomainSearch,
+		Hostname:     server.Spec.Hostname,
+		MTU:          uint16(reservation.MTU),
+		Duration:     DefaultLeaseDuration,
+	}
+
+	if reservation.LeaseDuration != nil && reservation.LeaseDuration.Duration > 0 {
+		lease.Duration = reservation.LeaseDuration.Duration
+	}
+
+	parse := func(field, s string) (net.IP, error) {
+		ip := net.ParseIP(s)
+		if ip == nil || ip.To4() == nil {
+			return nil, fmt.Errorf("invalid IPv4 %s %q in the DHCP reservation of %q", field, s, reservation.Interface)
+		}
+
+		return ip.To4(), nil
+	}
+
+	if reservation.Gateway != "" {
+		if lease.Gateway, err = parse("gateway", reservation.Gateway); err != nil {
+			return nil, err
+		}
+
+		if !network.Contains(lease.Gateway) {
+			return nil, fmt.Errorf("gateway %s is not in the network %s in the DHCP reservation of %q", lease.Gateway, network, reservation.Interface)
+		}
+	}
+
+	for _, s := range reservation.DNSServers {
+		ip, err := parse("DNS server", s)
+		if err != nil {
+			return nil, err
+		}
+
+		lease.DNSServers = append(lease.DNSServers, ip)
+	}
+
+	for _, s := range reservation.NTPServers {
+		ip, err := parse("NTP server", s)
+		if err != nil {
+			return nil, err
+		}
+
+		lease.NTPServers = append(lease.NTPServers, ip)
+	}
+
+	return lease, nil
+}
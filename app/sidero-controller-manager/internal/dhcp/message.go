@@ -0,0 +1,315 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dhcp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Op is the BOOTP message op code.
+type Op uint8
+
+// BOOTP op codes.
+const (
+	OpRequest Op = 1
+	OpReply   Op = 2
+)
+
+// MessageType is the DHCP message type (RFC 2132, option 53).
+type MessageType uint8
+
+// Message types.
+const (
+	MessageDiscover MessageType = 1
+	MessageOffer    MessageType = 2
+	MessageRequest  MessageType = 3
+	MessageDecline  MessageType = 4
+	MessageAck      MessageType = 5
+	MessageNak      MessageType = 6
+	MessageRelease  MessageType = 7
+	MessageInform   MessageType = 8
+)
+
+// OptionCode is the DHCP option code.
+type OptionCode uint8
+
+// Options used by the server.
+const (
+	OptionPad              OptionCode = 0
+	OptionSubnetMask       OptionCode = 1
+	OptionRouter           OptionCode = 3
+	OptionDNSServers       OptionCode = 6
+	OptionHostname         OptionCode = 12
+	OptionInterfaceMTU     OptionCode = 26
+	OptionNTPServers       OptionCode = 42
+	OptionRequestedAddress OptionCode = 50
+	OptionLeaseTime        OptionCode = 51
+	OptionMessageType      OptionCode = 53
+	OptionServerID         OptionCode = 54
+	OptionMessage          OptionCode = 56
+	OptionRenewalTime      OptionCode = 58
+	OptionRebindingTime    OptionCode = 59
+	OptionVendorClass      OptionCode = 60
+	OptionClientID         OptionCode = 61
+	OptionUserClass        OptionCode = 77
+	OptionDomainSearch     OptionCode = 119
+	OptionEnd              OptionCode = 255
+)
+
+// magicCookie starts the options field (RFC 2131).
+var magicCookie = []byte{99, 130, 83, 99}
+
+// headerLength is the length of the fixed BOOTP header preceding the magic cookie.
+const headerLength = 236
+
+// minMessageLength is the minimum BOOTP message length, some relay agents drop the shorter messages.
+const minMessageLength = 300
+
+// Option is a single DHCP option.
+type Option struct {
+	Code OptionCode
+	Data []byte
+}
+
+// Options is the list of options in the order of appearance.
+type Options []Option
+
+// Get returns the data of the first option with the code.
+func (opts Options) Get(code OptionCode) ([]byte, bool) {
+	for _, opt := range opts {
+		if opt.Code == code {
+			return opt.Data, true
+		}
+	}
+
+	return nil, false
+}
+
+// Add appends the option.
+func (opts *Options) Add(code OptionCode, data []byte) {
+	*opts = append(*opts, Option{Code: code, Data: data})
+}
+
+func parseOptions(b []byte) (Options, error) {
+	var opts Options
+
+	for len(b) > 0 {
+		code := OptionCode(b[0])
+
+		switch code {
+		case OptionPad:
+			b = b[1:]
+
+			continue
+		case OptionEnd:
+			return opts, nil
+		}
+
+		if len(b) < 2 {
+			return nil, errors.New("truncated option header")
+		}
+
+		length := int(b[1])
+
+		if len(b) < 2+length {
+			return nil, fmt.Errorf("truncated option %d", code)
+		}
+
+		opts.Add(code, b[2:2+length])
+
+		b = b[2+length:]
+	}
+
+	return opts, nil
+}
+
+func (opts Options) marshal(b []byte) []byte {
+	for _, opt := range opts {
+		data := opt.Data
+
+		// long options are split into the consecutive options with the same code (RFC 3396)
+		for {
+			chunk := data
+			if len(chunk) > 255 {
+				chunk = chunk[:255]
+			}
+
+			b = append(b, byte(opt.Code), byte(len(chunk)))
+			b = append(b, chunk...)
+
+			data = data[len(chunk):]
+
+			if len(data) == 0 {
+				break
+			}
+		}
+	}
+
+	return append(b, byte(OptionEnd))
+}
+
+// Message is a DHCP message.
+type Message struct {
+	Op     Op
+	HType  uint8
+	Hops   uint8
+	XID    uint32
+	Secs   uint16
+	Flags  uint16
+	CIAddr net.IP
+	YIAddr net.IP
+	SIAddr net.IP
+	GIAddr net.IP
+	CHAddr net.HardwareAddr
+
+	Options Options
+}
+
+// ParseMessage parses the DHCP message.
+func ParseMessage(b []byte) (*Message, error) {
+	if len(b) < headerLength+len(magicCookie) {
+		return nil, errors.New("message is too short")
+	}
+
+	hlen := int(b[2])
+	if hlen > 16 {
+		return nil, fmt.Errorf("invalid hardware address length %d", hlen)
+	}
+
+	m := &Message{
+		Op:     Op(b[0]),
+		HType:  b[1],
+		Hops:   b[3],
+		XID:    binary.BigEndian.Uint32(b[4:8]),
+		Secs:   binary.BigEndian.Uint16(b[8:10]),
+		Flags:  binary.BigEndian.Uint16(b[10:12]),
+		CIAddr: net.IP(append([]byte(nil), b[12:16]...)),
+		YIAddr: net.IP(append([]byte(nil), b[16:20]...)),
+		SIAddr: net.IP(append([]byte(nil), b[20:24]...)),
+		GIAddr: net.IP(append([]byte(nil), b[24:28]...)),
+		CHAddr: net.HardwareAddr(append([]byte(nil), b[28:28+hlen]...)),
+	}
+
+	if string(b[headerLength:headerLength+len(magicCookie)]) != string(magicCookie) {
+		return nil, errors.New("magic cookie is missing")
+	}
+
+	var err error
+
+	m.Options, err = parseOptions(b[headerLength+len(magicCookie):])
+
+	return m, err
+}
+
+// Marshal encodes the message.
+func (m *Message) Marshal() []byte {
+	b := make([]byte, headerLength, minMessageLength)
+
+	b[0] = byte(m.Op)
+	b[1] = m.HType
+	b[2] = byte(len(m.CHAddr))
+	b[3] = m.Hops
+	binary.BigEndian.PutUint32(b[4:8], m.XID)
+	binary.BigEndian.PutUint16(b[8:10], m.Secs)
+	binary.BigEndian.PutUint16(b[10:12], m.Flags)
+	copy(b[12:16], m.CIAddr.To4())
+	copy(b[16:20], m.YIAddr.To4())
+	copy(b[20:24], m.SIAddr.To4())
+	copy(b[24:28], m.GIAddr.To4())
+	copy(b[28:44], m.CHAddr)
+
+	b = append(b, magicCookie...)
+	b = m.Options.marshal(b)
+
+	for len(b) < minMessageLength {
+		b = append(b, byte(OptionPad))
+	}
+
+	return b
+}
+
+// Type returns the DHCP message type, zero for the plain BOOTP messages.
+func (m *Message) Type() MessageType {
+	data, ok := m.Options.Get(OptionMessageType)
+	if !ok || len(data) != 1 {
+		return 0
+	}
+
+	return MessageType(data[0])
+}
+
+// IP returns the address option.
+func (m *Message) IP(code OptionCode) net.IP {
+	data, ok := m.Options.Get(code)
+	if !ok || len(data) != net.IPv4len {
+		return nil
+	}
+
+	return net.IP(data)
+}
+
+// NetworkBootClient returns true if the message is sent by the PXE, UEFI HTTP boot or iPXE client.
+func (m *Message) NetworkBootClient() bool {
+	if vendorClass, ok := m.Options.Get(OptionVendorClass); ok {
+		if strings.HasPrefix(string(vendorClass), "PXEClient") || strings.HasPrefix(string(vendorClass), "HTTPClient") {
+			return true
+		}
+	}
+
+	if userClass, ok := m.Options.Get(OptionUserClass); ok && strings.Contains(string(userClass), "iPXE") {
+		return true
+	}
+
+	return false
+}
+
+// ipList encodes the list of addresses.
+func ipList(ips []net.IP) []byte {
+	b := make([]byte, 0, len(ips)*net.IPv4len)
+
+	for _, ip := range ips {
+		b = append(b, ip.To4()...)
+	}
+
+	return b
+}
+
+// domainSearch encodes the domain search list (RFC 3397) without the compression.
+func domainSearch(domains []string) ([]byte, error) {
+	var b []byte
+
+	for _, domain := range domains {
+		for _, label := range strings.Split(strings.TrimSuffix(domain, "."), ".") {
+			if label == "" || len(label) > 63 {
+				return nil, fmt.Errorf("invalid domain %q", domain)
+			}
+
+			b = append(b, byte(len(label)))
+			b = append(b, label...)
+		}
+
+		b = append(b, 0)
+	}
+
+	return b, nil
+}
+
+func uint32Data(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+
+	return b
+}
+
+func uint16Data(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+
+	return b
+}
@@ -0,0 +1,375 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package dhcp implements DHCP server which serves the leases reserved for the servers.
+//
+// The server only answers the clients with the reserved leases (see DHCPReservation), so that it can run next
+// to the DHCP server of the provisioning network, and it ignores the network boot clients (PXE, UEFI HTTP boot, iPXE),
+// which are left to the DHCP server supplying the boot parameters.
+package dhcp
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"syscall"
+
+	"golang.org/x/net/ipv4"
+)
+
+// Ports of the DHCP server and clients.
+const (
+	ServerPort = 67
+	ClientPort = 68
+)
+
+// Responder builds the responses to the clients.
+type Responder struct {
+	Leases Leases
+}
+
+// Respond returns the response to the client message, or nil if the message should be ignored.
+//
+// local is the address of the interface the message was received on, it's used as the server identifier.
+func (r *Responder) Respond(ctx context.Context, req *Message, local net.IP) (*Message, error) {
+	if req.Op != OpRequest || len(req.CHAddr) == 0 {
+		return nil, nil
+	}
+
+	reqType := req.Type()
+
+	switch reqType { //nolint:exhaustive
+	case MessageDiscover, MessageRequest, MessageInform:
+	default:
+		// leases are reserved, so releases and declines don't change anything
+		return nil, nil
+	}
+
+	if req.NetworkBootClient() {
+		return nil, nil
+	}
+
+	if local = local.To4(); local == nil {
+		return nil, fmt.Errorf("no IPv4 address to use as the server identifier")
+	}
+
+	lease, err := r.Leases.Lookup(ctx, req.CHAddr)
+	if err != nil || lease == nil {
+		return nil, err
+	}
+
+	var respType MessageType
+
+	switch reqType { //nolint:exhaustive
+	case MessageDiscover:
+		respType = MessageOffer
+	case MessageRequest:
+		// client chose the offer of another server
+		if serverID := req.IP(OptionServerID); serverID != nil && !serverID.Equal(local) {
+			return nil, nil
+		}
+
+		requested := req.IP(OptionRequestedAddress)
+		if requested == nil {
+			// renewing or rebinding
+			requested = req.CIAddr
+		}
+
+		if !requested.Equal(lease.Address) {
+			return r.nak(req, local, fmt.Sprintf("address %s is not reserved for the client", requested)), nil
+		}
+
+		respType = MessageAck
+	case MessageInform:
+		respType = MessageAck
+	}
+
+	resp := r.reply(req, respType, local)
+
+	if reqType != MessageInform {
+		resp.YIAddr = lease.Address
+
+		resp.Options.Add(OptionLeaseTime, uint32Data(uint32(lease.Duration.Seconds())))
+		resp.Options.Add(OptionRenewalTime, uint32Data(uint32(lease.Duration.Seconds()/2)))
+		resp.Options.Add(OptionRebindingTime, uint32Data(uint32(lease.Duration.Seconds()*7/8)))
+	}
+
+	resp.Options.Add(OptionSubnetMask, []byte(lease.Mask))
+
+	if lease.Gateway != nil {
+		resp.Options.Add(OptionRouter, lease.Gateway.To4())
+	}
+
+	if len(lease.DNSServers) > 0 {
+		resp.Options.Add(OptionDNSServers, ipList(lease.DNSServers))
+	}
+
+	if len(lease.NTPServers) > 0 {
+		resp.Options.Add(OptionNTPServers, ipList(lease.NTPServers))
+	}
+
+	if lease.MTU > 0 {
+		resp.Options.Add(OptionInterfaceMTU, uint16Data(lease.MTU))
+	}
+
+	if lease.Hostname != "" {
+		resp.Options.Add(OptionHostname, []byte(lease.Hostname))
+	}
+
+	if len(lease.DomainSearch) > 0 {
+		data, err := domainSearch(lease.DomainSearch)
+		if err != nil {
+			return nil, fmt.Errorf("server %q: %w", lease.Server, err)
+		}
+
+		resp.Options.Add(OptionDomainSearch, data)
+	}
+
+	return resp, nil
+}
+
+func (r *Responder) reply(req *Message, respType MessageType, local net.IP) *Message {
+	resp := &Message{
+		Op:     OpReply,
+		HType:  req.HType,
+		XID:    req.XID,
+		Flags:  req.Flags,
+		GIAddr: req.GIAddr,
+		CHAddr: req.CHAddr,
+	}
+
+	if respType != MessageNak {
+		resp.CIAddr = req.CIAddr
+	}
+
+	resp.Options.Add(OptionMessageType, []byte{byte(respType)})
+	resp.Options.Add(OptionServerID, local)
+
+	// echo the client identifier (RFC 6842)
+	if clientID, ok := req.Options.Get(OptionClientID); ok {
+		resp.Options.Add(OptionClientID, clientID)
+	}
+
+	return resp
+}
+
+func (r *Responder) nak(req *Message, local net.IP, message string) *Message {
+	resp := r.reply(req, MessageNak, local)
+
+	resp.Options.Add(OptionMessage, []byte(message))
+
+	return resp
+}
+
+// Destination returns the address to send the response to.
+func Destination(req, resp *Message) *net.UDPAddr {
+	switch {
+	case !isZero(req.GIAddr):
+		return &net.UDPAddr{IP: req.GIAddr, Port: ServerPort}
+	case resp.Type() == MessageNak:
+		return &net.UDPAddr{IP: net.IPv4bcast, Port: ClientPort}
+	case !isZero(req.CIAddr):
+		return &net.UDPAddr{IP: req.CIAddr, Port: ClientPort}
+	default:
+		// the client has no address yet, and the unicast to it would require an ARP entry
+		return &net.UDPAddr{IP: net.IPv4bcast, Port: ClientPort}
+	}
+}
+
+func isZero(ip net.IP) bool {
+	return ip == nil || ip.Equal(net.IPv4zero)
+}
+
+// Handle returns the response to the encoded client message, or nil if it should be ignored.
+func Handle(ctx context.Context, responder *Responder, b []byte, local net.IP) (*Message, *Message, error) {
+	req, err := ParseMessage(b)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := responder.Respond(ctx, req, local)
+
+	return req, resp, err
+}
+
+// ServerOptions configure the DHCP server.
+type ServerOptions struct {
+	// Interfaces to listen on, all broadcast capable interfaces if empty.
+	Interfaces []string
+	Leases     Leases
+}
+
+// ServeDHCP runs the DHCP server.
+//
+// The server should run on the host network of the provisioning network, or behind the DHCP relay.
+func ServeDHCP(ctx context.Context, opts ServerOptions) error {
+	ifaces, err := interfaces(opts.Interfaces)
+	if err != nil {
+		return err
+	}
+
+	allowed := map[int]struct{}{}
+
+	for _, iface := range ifaces {
+		allowed[iface.Index] = struct{}{}
+	}
+
+	lc := net.ListenConfig{
+		Control: func(network, address string, c syscall.RawConn) error {
+			var sockErr error
+
+			if err := c.Control(func(fd uintptr) {
+				sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1)
+			}); err != nil {
+				return err
+			}
+
+			return sockErr
+		},
+	}
+
+	pc, err := lc.ListenPacket(ctx, "udp4", fmt.Sprintf(":%d", ServerPort))
+	if err != nil {
+		return err
+	}
+
+	defer pc.Close() //nolint:errcheck
+
+	conn := ipv4.NewPacketConn(pc)
+
+	if err = conn.SetControlMessage(ipv4.FlagDst|ipv4.FlagInterface, true); err != nil {
+		return err
+	}
+
+	responder := &Responder{
+		Leases: opts.Leases,
+	}
+
+	buf := make([]byte, 65536)
+
+	for {
+		n, cm, src, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		if cm == nil {
+			continue
+		}
+
+		if _, ok := allowed[cm.IfIndex]; !ok {
+			continue
+		}
+
+		req, resp, err := Handle(ctx, responder, buf[:n], localAddress(cm))
+		if err != nil {
+			log.Printf("DHCP request from %s: %s", src, err)
+
+			continue
+		}
+
+		if resp == nil {
+			continue
+		}
+
+		if _, err = conn.WriteTo(resp.Marshal(), &ipv4.ControlMessage{IfIndex: cm.IfIndex}, Destination(req, resp)); err != nil {
+			log.Printf("DHCP response to %s: %s", req.CHAddr, err)
+
+			continue
+		}
+
+		log.Printf("DHCP %s (%s) sent to %s", messageTypeName(resp.Type()), resp.YIAddr, req.CHAddr)
+	}
+}
+
+func messageTypeName(t MessageType) string {
+	switch t { //nolint:exhaustive
+	case MessageOffer:
+		return "offer"
+	case MessageAck:
+		return "ack"
+	case MessageNak:
+		return "nak"
+	default:
+		return fmt.Sprintf("message %d", t)
+	}
+}
+
+func interfaces(names []string) ([]net.Interface, error) {
+	if len(names) > 0 {
+		ifaces := make([]net.Interface, 0, len(names))
+
+		for _, name := range names {
+			iface, err := net.InterfaceByName(name)
+			if err != nil {
+				return nil, fmt.Errorf("error looking up interface %q: %w", name, err)
+			}
+
+			ifaces = append(ifaces, *iface)
+		}
+
+		return ifaces, nil
+	}
+
+	all, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var ifaces []net.Interface
+
+	for _, iface := range all {
+		if iface.Flags&net.FlagUp != 0 && iface.Flags&net.FlagBroadcast != 0 && iface.Flags&net.FlagLoopback == 0 {
+			ifaces = append(ifaces, iface)
+		}
+	}
+
+	if len(ifaces) == 0 {
+		return nil, fmt.Errorf("no broadcast capable interfaces found")
+	}
+
+	return ifaces, nil
+}
+
+// localAddress returns the address the request was received on.
+//
+// Requests forwarded by the relay agents are sent to the unicast address of the server; requests from the clients
+// on the link are sent to the broadcast address, so the first IPv4 address of the interface is used.
+func localAddress(cm *ipv4.ControlMessage) net.IP {
+	local := func(addrs []net.Addr, err error) []net.IP {
+		if err != nil {
+			return nil
+		}
+
+		var ips []net.IP
+
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.To4() != nil {
+				ips = append(ips, ipnet.IP.To4())
+			}
+		}
+
+		return ips
+	}
+
+	if cm.Dst != nil {
+		for _, ip := range local(net.InterfaceAddrs()) {
+			if ip.Equal(cm.Dst) {
+				return ip
+			}
+		}
+	}
+
+	iface, err := net.InterfaceByIndex(cm.IfIndex)
+	if err != nil {
+		return nil
+	}
+
+	if ips := local(iface.Addrs()); len(ips) > 0 {
+		return ips[0]
+	}
+
+	return nil
+}
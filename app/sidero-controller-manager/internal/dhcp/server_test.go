@@ -0,0 +1,288 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dhcp_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/dhcp"
+)
+
+func mac(s string) net.HardwareAddr {
+	addr, err := net.ParseMAC(s)
+	if err != nil {
+		panic(err)
+	}
+
+	return addr
+}
+
+func leases(t *testing.T) dhcp.Leases {
+	scheme := runtime.NewScheme()
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+
+	return &dhcp.ServerLeases{
+		Reader: fake.NewFakeClientWithScheme(scheme,
+			&metalv1alpha1.Server{
+				ObjectMeta: metav1.ObjectMeta{Name: "server-1"},
+				Spec: metalv1alpha1.ServerSpec{
+					Hostname: "worker-1",
+					DHCPReservations: []metalv1alpha1.DHCPReservation{
+						{
+							Interface:     "eth0",
+							Address:       "172.24.0.10/24",
+							Gateway:       "172.24.0.1",
+							DNSServers:    []string{"172.24.0.2", "172.24.0.3"},
+							NTPServers:    []string{"172.24.0.4"},
+							DomainSearch:  []string{"dc1.example.com"},
+							MTU:           9000,
+							LeaseDuration: &metav1.Duration{Duration: 12 * time.Hour},
+						},
+					},
+				},
+				Status: metalv1alpha1.ServerStatus{
+					Inventory: &metalv1alpha1.HardwareInventory{
+						NetworkInterfaces: []metalv1alpha1.NetworkInterface{
+							{Name: "eth0", MAC: "D0:50:99:D3:33:60"},
+							{Name: "eth1", MAC: "d0:50:99:d3:33:61"},
+						},
+					},
+				},
+			},
+			&metalv1alpha1.Server{
+				ObjectMeta: metav1.ObjectMeta{Name: "server-2"},
+				Spec: metalv1alpha1.ServerSpec{
+					DHCPReservations: []metalv1alpha1.DHCPReservation{
+						{
+							Interface: "d0:50:99:d3:33:70",
+							Address:   "172.24.0.11/24",
+						},
+					},
+				},
+			},
+		),
+	}
+}
+
+func request(mac net.HardwareAddr, msgType dhcp.MessageType, options ...dhcp.Option) *dhcp.Message {
+	return &dhcp.Message{
+		Op:      dhcp.OpRequest,
+		HType:   1,
+		XID:     0x12345678,
+		CHAddr:  mac,
+		Options: append(dhcp.Options{{Code: dhcp.OptionMessageType, Data: []byte{byte(msgType)}}}, options...),
+	}
+}
+
+func TestRespond(t *testing.T) {
+	t.Parallel()
+
+	local := net.ParseIP("172.24.0.254").To4()
+	responder := &dhcp.Responder{Leases: leases(t)}
+
+	for name, tc := range map[string]struct {
+		req *dhcp.Message
+
+		expectedType    dhcp.MessageType
+		expectedAddress net.IP
+		expectedOptions dhcp.Options
+	}{
+		"discover by interface name": {
+			req: request(mac("d0:50:99:d3:33:60"), dhcp.MessageDiscover,
+				dhcp.Option{Code: dhcp.OptionClientID, Data: []byte{1, 0xd0, 0x50, 0x99, 0xd3, 0x33, 0x60}},
+			),
+			expectedType:    dhcp.MessageOffer,
+			expectedAddress: net.ParseIP("172.24.0.10"),
+			expectedOptions: dhcp.Options{
+				{Code: dhcp.OptionMessageType, Data: []byte{byte(dhcp.MessageOffer)}},
+				{Code: dhcp.OptionServerID, Data: local},
+				{Code: dhcp.OptionClientID, Data: []byte{1, 0xd0, 0x50, 0x99, 0xd3, 0x33, 0x60}},
+				{Code: dhcp.OptionLeaseTime, Data: []byte{0, 0, 0xa8, 0xc0}},
+				{Code: dhcp.OptionRenewalTime, Data: []byte{0, 0, 0x54, 0x60}},
+				{Code: dhcp.OptionRebindingTime, Data: []byte{0, 0, 0x93, 0xa8}},
+				{Code: dhcp.OptionSubnetMask, Data: []byte{255, 255, 255, 0}},
+				{Code: dhcp.OptionRouter, Data: []byte{172, 24, 0, 1}},
+				{Code: dhcp.OptionDNSServers, Data: []byte{172, 24, 0, 2, 172, 24, 0, 3}},
+				{Code: dhcp.OptionNTPServers, Data: []byte{172, 24, 0, 4}},
+				{Code: dhcp.OptionInterfaceMTU, Data: []byte{0x23, 0x28}},
+				{Code: dhcp.OptionHostname, Data: []byte("worker-1")},
+				{Code: dhcp.OptionDomainSearch, Data: []byte("\x03dc1\x07example\x03com\x00")},
+			},
+		},
+		"request by mac": {
+			req: request(mac("d0:50:99:d3:33:70"), dhcp.MessageRequest,
+				dhcp.Option{Code: dhcp.OptionRequestedAddress, Data: []byte{172, 24, 0, 11}},
+				dhcp.Option{Code: dhcp.OptionServerID, Data: local},
+			),
+			expectedType:    dhcp.MessageAck,
+			expectedAddress: net.ParseIP("172.24.0.11"),
+			expectedOptions: dhcp.Options{
+				{Code: dhcp.OptionMessageType, Data: []byte{byte(dhcp.MessageAck)}},
+				{Code: dhcp.OptionServerID, Data: local},
+				{Code: dhcp.OptionLeaseTime, Data: []byte{0, 0, 0x0e, 0x10}},
+				{Code: dhcp.OptionRenewalTime, Data: []byte{0, 0, 0x07, 0x08}},
+				{Code: dhcp.OptionRebindingTime, Data: []byte{0, 0, 0x0c, 0x4e}},
+				{Code: dhcp.OptionSubnetMask, Data: []byte{255, 255, 255, 0}},
+			},
+		},
+		"request wrong address": {
+			req: request(mac("d0:50:99:d3:33:70"), dhcp.MessageRequest,
+				dhcp.Option{Code: dhcp.OptionRequestedAddress, Data: []byte{172, 24, 0, 99}},
+			),
+			expectedType: dhcp.MessageNak,
+			expectedOptions: dhcp.Options{
+				{Code: dhcp.OptionMessageType, Data: []byte{byte(dhcp.MessageNak)}},
+				{Code: dhcp.OptionServerID, Data: local},
+				{Code: dhcp.OptionMessage, Data: []byte("address 172.24.0.99 is not reserved for the client")},
+			},
+		},
+		"request other server": {
+			req: request(mac("d0:50:99:d3:33:70"), dhcp.MessageRequest,
+				dhcp.Option{Code: dhcp.OptionRequestedAddress, Data: []byte{172, 24, 0, 11}},
+				dhcp.Option{Code: dhcp.OptionServerID, Data: []byte{172, 24, 0, 253}},
+			),
+		},
+		"inform": {
+			req:          request(mac("d0:50:99:d3:33:70"), dhcp.MessageInform),
+			expectedType: dhcp.MessageAck,
+			expectedOptions: dhcp.Options{
+				{Code: dhcp.OptionMessageType, Data: []byte{byte(dhcp.MessageAck)}},
+				{Code: dhcp.OptionServerID, Data: local},
+				{Code: dhcp.OptionSubnetMask, Data: []byte{255, 255, 255, 0}},
+			},
+		},
+		"pxe client": {
+			req: request(mac("d0:50:99:d3:33:60"), dhcp.MessageDiscover,
+				dhcp.Option{Code: dhcp.OptionVendorClass, Data: []byte("PXEClient:Arch:00000:UNDI:002001")},
+			),
+		},
+		"ipxe client": {
+			req: request(mac("d0:50:99:d3:33:60"), dhcp.MessageDiscover,
+				dhcp.Option{Code: dhcp.OptionUserClass, Data: []byte("iPXE")},
+			),
+		},
+		"other interface": {
+			req: request(mac("d0:50:99:d3:33:61"), dhcp.MessageDiscover),
+		},
+		"unknown client": {
+			req: request(mac("d0:50:99:d3:33:99"), dhcp.MessageDiscover),
+		},
+		"release": {
+			req: request(mac("d0:50:99:d3:33:70"), dhcp.MessageRelease),
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			resp, err := responder.Respond(context.Background(), tc.req, local)
+			require.NoError(t, err)
+
+			if tc.expectedType == 0 {
+				assert.Nil(t, resp)
+
+				return
+			}
+
+			require.NotNil(t, resp)
+
+			assert.Equal(t, dhcp.OpReply, resp.Op)
+			assert.Equal(t, tc.req.XID, resp.XID)
+			assert.Equal(t, tc.req.CHAddr, resp.CHAddr)
+			assert.Equal(t, tc.expectedType, resp.Type())
+
+			if tc.expectedAddress != nil {
+				assert.True(t, tc.expectedAddress.Equal(resp.YIAddr), resp.YIAddr)
+			} else {
+				assert.Nil(t, resp.YIAddr)
+			}
+
+			assert.Equal(t, tc.expectedOptions, resp.Options)
+		})
+	}
+}
+
+func TestInvalidReservation(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		reservation metalv1alpha1.DHCPReservation
+		expected    string
+	}{
+		"ipv6 address": {
+			reservation: metalv1alpha1.DHCPReservation{Interface: "eth0", Address: "2001:db8::10/64"},
+			expected:    `invalid IPv4 address "2001:db8::10/64" in the DHCP reservation of "eth0"`,
+		},
+		"gateway outside network": {
+			reservation: metalv1alpha1.DHCPReservation{Interface: "eth0", Address: "172.24.0.10/24", Gateway: "172.25.0.1"},
+			expected:    `gateway 172.25.0.1 is not in the network 172.24.0.0/24 in the DHCP reservation of "eth0"`,
+		},
+		"ntp server name": {
+			reservation: metalv1alpha1.DHCPReservation{Interface: "eth0", Address: "172.24.0.10/24", NTPServers: []string{"pool.ntp.org"}},
+			expected:    `invalid IPv4 NTP server "pool.ntp.org" in the DHCP reservation of "eth0"`,
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := dhcp.NewLease(&metalv1alpha1.Server{}, &tc.reservation)
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	t.Parallel()
+
+	msg := request(mac("d0:50:99:d3:33:60"), dhcp.MessageRequest,
+		dhcp.Option{Code: dhcp.OptionRequestedAddress, Data: []byte{172, 24, 0, 10}},
+		// split into two options on the wire
+		dhcp.Option{Code: dhcp.OptionDomainSearch, Data: make([]byte, 300)},
+	)
+	msg.CIAddr = net.IPv4zero.To4()
+	msg.YIAddr = net.IPv4zero.To4()
+	msg.SIAddr = net.IPv4zero.To4()
+	msg.GIAddr = net.ParseIP("172.24.0.1").To4()
+
+	b := msg.Marshal()
+	assert.GreaterOrEqual(t, len(b), 300)
+
+	parsed, err := dhcp.ParseMessage(b)
+	require.NoError(t, err)
+
+	assert.Equal(t, msg.CHAddr, parsed.CHAddr)
+	assert.Equal(t, msg.GIAddr, parsed.GIAddr)
+	assert.Equal(t, dhcp.MessageRequest, parsed.Type())
+	assert.Equal(t, net.IP{172, 24, 0, 10}, parsed.IP(dhcp.OptionRequestedAddress))
+	assert.Len(t, parsed.Options, 4)
+
+	_, err = dhcp.ParseMessage(b[:200])
+	assert.Error(t, err)
+}
+
+func TestDestination(t *testing.T) {
+	t.Parallel()
+
+	ack := &dhcp.Message{Options: dhcp.Options{{Code: dhcp.OptionMessageType, Data: []byte{byte(dhcp.MessageAck)}}}}
+	nak := &dhcp.Message{Options: dhcp.Options{{Code: dhcp.OptionMessageType, Data: []byte{byte(dhcp.MessageNak)}}}}
+
+	assert.Equal(t, "172.24.0.1:67", dhcp.Destination(&dhcp.Message{GIAddr: net.ParseIP("172.24.0.1"), CIAddr: net.IPv4zero}, ack).String())
+	assert.Equal(t, "172.24.0.10:68", dhcp.Destination(&dhcp.Message{GIAddr: net.IPv4zero, CIAddr: net.ParseIP("172.24.0.10")}, ack).String())
+	assert.Equal(t, "255.255.255.255:68", dhcp.Destination(&dhcp.Message{GIAddr: net.IPv4zero, CIAddr: net.ParseIP("172.24.0.10")}, nak).String())
+	assert.Equal(t, "255.255.255.255:68", dhcp.Destination(&dhcp.Message{GIAddr: net.IPv4zero, CIAddr: net.IPv4zero}, ack).String())
+}
@@ -0,0 +1,244 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dhcpv6
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// MessageType is the DHCPv6 message type (RFC 8415).
+type MessageType uint8
+
+// Message types handled by the proxy.
+const (
+	MessageSolicit            MessageType = 1
+	MessageAdvertise          MessageType = 2
+	MessageRequest            MessageType = 3
+	MessageReply              MessageType = 7
+	MessageInformationRequest MessageType = 11
+	MessageRelayForward       MessageType = 12
+	MessageRelayReply         MessageType = 13
+)
+
+// OptionCode is the DHCPv6 option code.
+type OptionCode uint16
+
+// Options used by the proxy.
+const (
+	OptionClientID     OptionCode = 1
+	OptionServerID     OptionCode = 2
+	OptionRelayMessage OptionCode = 9
+	OptionUserClass    OptionCode = 15
+	OptionVendorClass  OptionCode = 16
+	OptionInterfaceID  OptionCode = 18
+	OptionBootFileURL  OptionCode = 59
+	OptionClientArch   OptionCode = 61
+)
+
+// Option is a single DHCPv6 option.
+type Option struct {
+	Code OptionCode
+	Data []byte
+}
+
+// Options is the list of options in the order of appearance.
+type Options []Option
+
+// Get returns the data of the first option with the code.
+func (opts Options) Get(code OptionCode) ([]byte, bool) {
+	for _, opt := range opts {
+		if opt.Code == code {
+			return opt.Data, true
+		}
+	}
+
+	return nil, false
+}
+
+// Add appends the option.
+func (opts *Options) Add(code OptionCode, data []byte) {
+	*opts = append(*opts, Option{Code: code, Data: data})
+}
+
+func parseOptions(b []byte) (Options, error) {
+	var opts Options
+
+	for len(b) > 0 {
+		if len(b) < 4 {
+			return nil, errors.New("truncated option header")
+		}
+
+		code := OptionCode(binary.BigEndian.Uint16(b))
+		length := int(binary.BigEndian.Uint16(b[2:]))
+
+		if len(b) < 4+length {
+			return nil, fmt.Errorf("truncated option %d", code)
+		}
+
+		opts.Add(code, b[4:4+length])
+
+		b = b[4+length:]
+	}
+
+	return opts, nil
+}
+
+func (opts Options) marshal(b []byte) []byte {
+	for _, opt := range opts {
+		b = appendUint16(b, uint16(opt.Code))
+		b = appendUint16(b, uint16(len(opt.Data)))
+		b = append(b, opt.Data...)
+	}
+
+	return b
+}
+
+// Message is a DHCPv6 client/server message.
+type Message struct {
+	Type          MessageType
+	TransactionID [3]byte
+	Options       Options
+}
+
+// ParseMessage parses the client/server message.
+func ParseMessage(b []byte) (*Message, error) {
+	if len(b) < 4 {
+		return nil, errors.New("message is too short")
+	}
+
+	m := &Message{Type: MessageType(b[0])}
+
+	if m.Type == MessageRelayForward || m.Type == MessageRelayReply {
+		return nil, fmt.Errorf("unexpected relay message %d", m.Type)
+	}
+
+	copy(m.TransactionID[:], b[1:4])
+
+	var err error
+
+	m.Options, err = parseOptions(b[4:])
+
+	return m, err
+}
+
+// Marshal encodes the message.
+func (m *Message) Marshal() []byte {
+	b := append([]byte{byte(m.Type)}, m.TransactionID[:]...)
+
+	return m.Options.marshal(b)
+}
+
+// RelayMessage is a DHCPv6 relay agent message.
+type RelayMessage struct {
+	Type     MessageType
+	HopCount uint8
+	LinkAddr net.IP
+	PeerAddr net.IP
+	Options  Options
+}
+
+// ParseRelayMessage parses the relay agent message.
+func ParseRelayMessage(b []byte) (*RelayMessage, error) {
+	if len(b) < 34 {
+		return nil, errors.New("relay message is too short")
+	}
+
+	m := &RelayMessage{
+		Type:     MessageType(b[0]),
+		HopCount: b[1],
+		LinkAddr: net.IP(append([]byte(nil), b[2:18]...)),
+		PeerAddr: net.IP(append([]byte(nil), b[18:34]...)),
+	}
+
+	if m.Type != MessageRelayForward && m.Type != MessageRelayReply {
+		return nil, fmt.Errorf("unexpected message %d", m.Type)
+	}
+
+	var err error
+
+	m.Options, err = parseOptions(b[34:])
+
+	return m, err
+}
+
+// Marshal encodes the relay agent message.
+func (m *RelayMessage) Marshal() []byte {
+	b := []byte{byte(m.Type), m.HopCount}
+	b = append(b, m.LinkAddr.To16()...)
+	b = append(b, m.PeerAddr.To16()...)
+
+	return m.Options.marshal(b)
+}
+
+// ClientArchitectures returns the client system architecture types (RFC 4578).
+func (m *Message) ClientArchitectures() []uint16 {
+	data, ok := m.Options.Get(OptionClientArch)
+	if !ok {
+		return nil
+	}
+
+	archs := make([]uint16, 0, len(data)/2)
+
+	for i := 0; i+1 < len(data); i += 2 {
+		archs = append(archs, binary.BigEndian.Uint16(data[i:]))
+	}
+
+	return archs
+}
+
+// UserClasses returns the user class data items.
+func (m *Message) UserClasses() []string {
+	data, _ := m.Options.Get(OptionUserClass)
+
+	return classData(data)
+}
+
+// VendorClass returns the enterprise number and the vendor class data items.
+func (m *Message) VendorClass() (uint32, []string) {
+	data, ok := m.Options.Get(OptionVendorClass)
+	if !ok || len(data) < 4 {
+		return 0, nil
+	}
+
+	return binary.BigEndian.Uint32(data), classData(data[4:])
+}
+
+// classData splits the user or vendor class data, each item prefixed with 2 bytes length.
+func classData(data []byte) []string {
+	var items []string
+
+	for len(data) >= 2 {
+		length := int(binary.BigEndian.Uint16(data))
+		if len(data) < 2+length {
+			break
+		}
+
+		items = append(items, string(data[2:2+length]))
+
+		data = data[2+length:]
+	}
+
+	return items
+}
+
+// vendorClass encodes the vendor class option data.
+func vendorClass(enterprise uint32, items ...string) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, enterprise)
+
+	for _, item := range items {
+		b = appendUint16(b, uint16(len(item)))
+		b = append(b, item...)
+	}
+
+	return b
+}
+
+func appendUint16(b []byte, v uint16) []byte {
+	return append(b, byte(v>>8), byte(v))
+}
@@ -0,0 +1,414 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package dhcpv6 implements DHCPv6 proxy which supplies the network boot parameters to the servers.
+//
+// The proxy doesn't assign addresses, addressing is left to the DHCPv6 server or SLAAC on the provisioning network,
+// it only answers the PXE, UEFI HTTP boot and iPXE clients with the Boot File URL pointing to Sidero.
+package dhcpv6
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+
+	"golang.org/x/net/ipv6"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/metrics"
+)
+
+// AllDHCPRelayAgentsAndServers is the link-scoped multicast address the clients send the requests to.
+var AllDHCPRelayAgentsAndServers = net.ParseIP("ff02::1:2")
+
+// enterprisePXE is the IANA enterprise number used in the vendor class of the PXE clients (Intel).
+const enterprisePXE = 343
+
+// Client system architecture types (RFC 4578, IANA Processor Architecture Types).
+const (
+	archEFIBC        = 0x07
+	archEFIx8664     = 0x09
+	archEFIARM64     = 0x0b
+	archEFIx8664HTTP = 0x10
+	archEFIARM64HTTP = 0x13
+)
+
+const (
+	userClassIPXE   = "iPXE"
+	vendorClassHTTP = "HTTPClient"
+	vendorClassPXE  = "PXEClient"
+)
+
+// Boot files served by the TFTP server (and over HTTP under /tftp/), and the iPXE script.
+const (
+	bootFileAMD64      = "ipxe.efi"
+	bootFileARM64      = "ipxe-arm64.efi"
+	bootScriptFileIPXE = "boot.ipxe"
+)
+
+// Responder builds the responses to the network boot clients.
+type Responder struct {
+	// ServerID is the DUID of the proxy.
+	ServerID []byte
+	// Endpoint is the hostname or IPv6 address Sidero can be reached at from the servers,
+	// if it's empty or an IPv4 address, the local address the request was received on is used.
+	Endpoint string
+	// Port is the HTTP port of Sidero.
+	Port int
+}
+
+// Respond returns the response to the client message, or nil if the message should be ignored.
+//
+// local is the global address of the interface the message was received on.
+func (r *Responder) Respond(req *Message, local net.IP) (*Message, error) {
+	var respType MessageType
+
+	clientID, hasClientID := req.Options.Get(OptionClientID)
+	serverID, hasServerID := req.Options.Get(OptionServerID)
+
+	switch req.Type { //nolint:exhaustive
+	case MessageSolicit:
+		if !hasClientID || hasServerID {
+			return nil, nil
+		}
+
+		respType = MessageAdvertise
+	case MessageRequest:
+		// requests are sent to the chosen server, only respond if it's the proxy
+		if !hasClientID || !bytes.Equal(serverID, r.ServerID) {
+			return nil, nil
+		}
+
+		respType = MessageReply
+	case MessageInformationRequest:
+		if hasServerID && !bytes.Equal(serverID, r.ServerID) {
+			return nil, nil
+		}
+
+		respType = MessageReply
+	default:
+		return nil, nil
+	}
+
+	bootFile, vendorClass, ok := r.bootFile(req)
+	if !ok {
+		return nil, nil
+	}
+
+	host, err := r.host(local)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &Message{
+		Type:          respType,
+		TransactionID: req.TransactionID,
+	}
+
+	if hasClientID {
+		resp.Options.Add(OptionClientID, clientID)
+	}
+
+	resp.Options.Add(OptionServerID, r.ServerID)
+
+	if vendorClass != "" {
+		resp.Options.Add(OptionVendorClass, vendorClassData(vendorClass))
+	}
+
+	resp.Options.Add(OptionBootFileURL, []byte(bootFile(host)))
+
+	return resp, nil
+}
+
+// bootFile returns the boot file URL builder for the client, and the vendor class to respond with.
+func (r *Responder) bootFile(req *Message) (func(host string) string, string, bool) {
+	httpURL := func(path string) func(string) string {
+		return func(host string) string {
+			return fmt.Sprintf("http://%s/%s", net.JoinHostPort(host, strconv.Itoa(r.Port)), path)
+		}
+	}
+
+	tftpURL := func(path string) func(string) string {
+		return func(host string) string {
+			return fmt.Sprintf("tftp://%s/%s", hostLiteral(host), path)
+		}
+	}
+
+	for _, userClass := range req.UserClasses() {
+		if userClass == userClassIPXE {
+			return httpURL(bootScriptFileIPXE), "", true
+		}
+	}
+
+	for _, arch := range req.ClientArchitectures() {
+		switch arch {
+		case archEFIBC, archEFIx8664:
+			return tftpURL(bootFileAMD64), vendorClassPXE, true
+		case archEFIARM64:
+			return tftpURL(bootFileARM64), vendorClassPXE, true
+		case archEFIx8664HTTP:
+			return httpURL("tftp/" + bootFileAMD64), vendorClassHTTP, true
+		case archEFIARM64HTTP:
+			return httpURL("tftp/" + bootFileARM64), vendorClassHTTP, true
+		}
+	}
+
+	// not a network boot client (or legacy BIOS, which can't PXE boot over IPv6)
+	return nil, "", false
+}
+
+func (r *Responder) host(local net.IP) (string, error) {
+	if r.Endpoint != "" {
+		if ip := net.ParseIP(r.Endpoint); ip == nil || ip.To4() == nil {
+			return r.Endpoint, nil
+		}
+	}
+
+	if local == nil {
+		return "", fmt.Errorf("no IPv6 address to serve the boot files at")
+	}
+
+	return local.String(), nil
+}
+
+// hostLiteral encloses IPv6 addresses in brackets for use in URLs.
+func hostLiteral(host string) string {
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		return "[" + host + "]"
+	}
+
+	return host
+}
+
+func vendorClassData(class string) []byte {
+	return vendorClass(enterprisePXE, class)
+}
+
+// ProxyOptions configure the DHCPv6 proxy.
+type ProxyOptions struct {
+	// Interfaces to listen on, all multicast capable interfaces if empty.
+	Interfaces []string
+	// Endpoint and Port Sidero can be reached at from the servers, see Responder.
+	Endpoint string
+	Port     int
+}
+
+// ServeDHCPv6 runs the DHCPv6 proxy.
+//
+// The proxy should run on the host network of the provisioning network, or behind the DHCPv6 relay.
+func ServeDHCPv6(opts ProxyOptions) error {
+	ifaces, err := interfaces(opts.Interfaces)
+	if err != nil {
+		return err
+	}
+
+	pc, err := net.ListenPacket("udp6", "[::]:547")
+	if err != nil {
+		return err
+	}
+
+	defer pc.Close() //nolint:errcheck
+
+	conn := ipv6.NewPacketConn(pc)
+
+	if err = conn.SetControlMessage(ipv6.FlagDst|ipv6.FlagInterface, true); err != nil {
+		return err
+	}
+
+	allowed := map[int]struct{}{}
+
+	for i := range ifaces {
+		if err = conn.JoinGroup(&ifaces[i], &net.UDPAddr{IP: AllDHCPRelayAgentsAndServers}); err != nil {
+			return fmt.Errorf("error joining DHCPv6 multicast group on %s: %w", ifaces[i].Name, err)
+		}
+
+		allowed[ifaces[i].Index] = struct{}{}
+	}
+
+	responder := &Responder{
+		ServerID: serverDUID(ifaces),
+		Endpoint: opts.Endpoint,
+		Port:     opts.Port,
+	}
+
+	buf := make([]byte, 65536)
+
+	for {
+		n, cm, src, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		if cm == nil {
+			continue
+		}
+
+		if _, ok := allowed[cm.IfIndex]; !ok {
+			continue
+		}
+
+		resp, err := Handle(responder, buf[:n], localAddress(cm))
+		if err != nil {
+			log.Printf("DHCPv6 request from %s: %s", src, err)
+			metrics.BootRequests.WithLabelValues("dhcpv6", metrics.ResultFailure).Inc()
+
+			continue
+		}
+
+		if resp == nil {
+			continue
+		}
+
+		if _, err = conn.WriteTo(resp, &ipv6.ControlMessage{IfIndex: cm.IfIndex}, src); err != nil {
+			log.Printf("DHCPv6 response to %s: %s", src, err)
+			metrics.BootRequests.WithLabelValues("dhcpv6", metrics.ResultFailure).Inc()
+
+			continue
+		}
+
+		log.Printf("DHCPv6 boot parameters sent to %s", src)
+		metrics.BootRequests.WithLabelValues("dhcpv6", metrics.ResultSuccess).Inc()
+	}
+}
+
+// Handle returns the encoded response to the client or relay agent message, or nil if it should be ignored.
+func Handle(responder *Responder, b []byte, local net.IP) ([]byte, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+
+	if MessageType(b[0]) != MessageRelayForward {
+		req, err := ParseMessage(b)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := responder.Respond(req, local)
+		if err != nil || resp == nil {
+			return nil, err
+		}
+
+		return resp.Marshal(), nil
+	}
+
+	relayed, err := ParseRelayMessage(b)
+	if err != nil {
+		return nil, err
+	}
+
+	inner, ok := relayed.Options.Get(OptionRelayMessage)
+	if !ok {
+		return nil, fmt.Errorf("relay message option is missing")
+	}
+
+	resp, err := Handle(responder, inner, local)
+	if err != nil || resp == nil {
+		return nil, err
+	}
+
+	reply := &RelayMessage{
+		Type:     MessageRelayReply,
+		HopCount: relayed.HopCount,
+		LinkAddr: relayed.LinkAddr,
+		PeerAddr: relayed.PeerAddr,
+	}
+
+	if interfaceID, ok := relayed.Options.Get(OptionInterfaceID); ok {
+		reply.Options.Add(OptionInterfaceID, interfaceID)
+	}
+
+	reply.Options.Add(OptionRelayMessage, resp)
+
+	return reply.Marshal(), nil
+}
+
+func interfaces(names []string) ([]net.Interface, error) {
+	if len(names) > 0 {
+		ifaces := make([]net.Interface, 0, len(names))
+
+		for _, name := range names {
+			iface, err := net.InterfaceByName(name)
+			if err != nil {
+				return nil, fmt.Errorf("error looking up interface %q: %w", name, err)
+			}
+
+			ifaces = append(ifaces, *iface)
+		}
+
+		return ifaces, nil
+	}
+
+	all, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	var ifaces []net.Interface
+
+	for _, iface := range all {
+		if iface.Flags&net.FlagUp != 0 && iface.Flags&net.FlagMulticast != 0 && iface.Flags&net.FlagLoopback == 0 {
+			ifaces = append(ifaces, iface)
+		}
+	}
+
+	if len(ifaces) == 0 {
+		return nil, fmt.Errorf("no multicast capable interfaces found")
+	}
+
+	return ifaces, nil
+}
+
+// serverDUID returns DUID-LL based on the first interface hardware address.
+func serverDUID(ifaces []net.Interface) []byte {
+	duid := []byte{0, 3, 0, 1}
+
+	for _, iface := range ifaces {
+		if len(iface.HardwareAddr) == 6 {
+			return append(duid, iface.HardwareAddr...)
+		}
+	}
+
+	// locally administered random address
+	addr := make([]byte, 6)
+	rand.Read(addr) //nolint:errcheck
+
+	addr[0] = (addr[0] | 0x02) &^ 0x01
+
+	return append(duid, addr...)
+}
+
+// localAddress returns the global address the request was received on.
+//
+// Requests from the clients on the link are sent to the multicast address, so the first global address
+// of the interface is used; requests forwarded by the relay agents are sent to the unicast address.
+func localAddress(cm *ipv6.ControlMessage) net.IP {
+	if cm.Dst != nil && cm.Dst.IsGlobalUnicast() {
+		return cm.Dst
+	}
+
+	iface, err := net.InterfaceByIndex(cm.IfIndex)
+	if err != nil {
+		return nil
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil
+	}
+
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+
+		if ipNet.IP.To4() == nil && ipNet.IP.IsGlobalUnicast() {
+			return ipNet.IP
+		}
+	}
+
+	return nil
+}
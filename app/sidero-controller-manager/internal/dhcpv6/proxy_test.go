@@ -0,0 +1,210 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dhcpv6_test
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/dhcpv6"
+)
+
+func classOption(items ...string) []byte {
+	var b []byte
+
+	for _, item := range items {
+		b = append(b, byte(len(item)>>8), byte(len(item)))
+		b = append(b, item...)
+	}
+
+	return b
+}
+
+func TestRespond(t *testing.T) {
+	t.Parallel()
+
+	serverID := []byte{0, 3, 0, 1, 0xd0, 0x50, 0x99, 0xd3, 0x33, 0x60}
+	clientID := []byte{0, 3, 0, 1, 0xd0, 0x50, 0x99, 0xd3, 0x33, 0x61}
+	local := net.ParseIP("2001:db8::10")
+
+	for name, tc := range map[string]struct {
+		endpoint string
+		msgType  dhcpv6.MessageType
+		options  dhcpv6.Options
+
+		expectedType        dhcpv6.MessageType
+		expectedURL         string
+		expectedVendorClass string
+	}{
+		"uefi pxe": {
+			msgType: dhcpv6.MessageSolicit,
+			options: dhcpv6.Options{
+				{Code: dhcpv6.OptionClientID, Data: clientID},
+				{Code: dhcpv6.OptionClientArch, Data: []byte{0, 7}},
+				{Code: dhcpv6.OptionVendorClass, Data: append([]byte{0, 0, 1, 0x57}, classOption("PXEClient:Arch:00007:UNDI:003016")...)},
+			},
+			expectedType:        dhcpv6.MessageAdvertise,
+			expectedURL:         "tftp://[2001:db8::10]/ipxe.efi",
+			expectedVendorClass: "PXEClient",
+		},
+		"uefi http arm64": {
+			endpoint: "sidero.example.com",
+			msgType:  dhcpv6.MessageSolicit,
+			options: dhcpv6.Options{
+				{Code: dhcpv6.OptionClientID, Data: clientID},
+				{Code: dhcpv6.OptionClientArch, Data: []byte{0, 0x13}},
+			},
+			expectedType:        dhcpv6.MessageAdvertise,
+			expectedURL:         "http://sidero.example.com:8081/tftp/ipxe-arm64.efi",
+			expectedVendorClass: "HTTPClient",
+		},
+		"ipxe": {
+			endpoint: "2001:db8::1",
+			msgType:  dhcpv6.MessageRequest,
+			options: dhcpv6.Options{
+				{Code: dhcpv6.OptionClientID, Data: clientID},
+				{Code: dhcpv6.OptionServerID, Data: serverID},
+				{Code: dhcpv6.OptionUserClass, Data: classOption("iPXE")},
+				{Code: dhcpv6.OptionClientArch, Data: []byte{0, 7}},
+			},
+			expectedType: dhcpv6.MessageReply,
+			expectedURL:  "http://[2001:db8::1]:8081/boot.ipxe",
+		},
+		"ipv4 endpoint": {
+			endpoint: "172.24.0.2",
+			msgType:  dhcpv6.MessageInformationRequest,
+			options: dhcpv6.Options{
+				{Code: dhcpv6.OptionClientArch, Data: []byte{0, 9}},
+			},
+			expectedType:        dhcpv6.MessageReply,
+			expectedURL:         "tftp://[2001:db8::10]/ipxe.efi",
+			expectedVendorClass: "PXEClient",
+		},
+		"request to another server": {
+			msgType: dhcpv6.MessageRequest,
+			options: dhcpv6.Options{
+				{Code: dhcpv6.OptionClientID, Data: clientID},
+				{Code: dhcpv6.OptionServerID, Data: []byte{0, 3, 0, 1, 1, 2, 3, 4, 5, 6}},
+				{Code: dhcpv6.OptionClientArch, Data: []byte{0, 7}},
+			},
+		},
+		"not a boot client": {
+			msgType: dhcpv6.MessageSolicit,
+			options: dhcpv6.Options{
+				{Code: dhcpv6.OptionClientID, Data: clientID},
+			},
+		},
+		"legacy bios": {
+			msgType: dhcpv6.MessageSolicit,
+			options: dhcpv6.Options{
+				{Code: dhcpv6.OptionClientID, Data: clientID},
+				{Code: dhcpv6.OptionClientArch, Data: []byte{0, 0}},
+			},
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			responder := &dhcpv6.Responder{
+				ServerID: serverID,
+				Endpoint: tc.endpoint,
+				Port:     8081,
+			}
+
+			req := &dhcpv6.Message{
+				Type:          tc.msgType,
+				TransactionID: [3]byte{1, 2, 3},
+				Options:       tc.options,
+			}
+
+			b, err := dhcpv6.Handle(responder, req.Marshal(), local)
+			require.NoError(t, err)
+
+			if tc.expectedType == 0 {
+				assert.Nil(t, b)
+
+				return
+			}
+
+			resp, err := dhcpv6.ParseMessage(b)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedType, resp.Type)
+			assert.Equal(t, req.TransactionID, resp.TransactionID)
+
+			id, _ := resp.Options.Get(dhcpv6.OptionServerID)
+			assert.Equal(t, serverID, id)
+
+			url, _ := resp.Options.Get(dhcpv6.OptionBootFileURL)
+			assert.Equal(t, tc.expectedURL, string(url))
+
+			enterprise, vendorClass := resp.VendorClass()
+			if tc.expectedVendorClass == "" {
+				assert.Empty(t, vendorClass)
+			} else {
+				assert.EqualValues(t, 343, enterprise)
+				assert.Equal(t, []string{tc.expectedVendorClass}, vendorClass)
+			}
+		})
+	}
+}
+
+func TestRelay(t *testing.T) {
+	t.Parallel()
+
+	responder := &dhcpv6.Responder{
+		ServerID: []byte{0, 3, 0, 1, 0xd0, 0x50, 0x99, 0xd3, 0x33, 0x60},
+		Port:     8081,
+	}
+
+	req := &dhcpv6.Message{
+		Type:          dhcpv6.MessageSolicit,
+		TransactionID: [3]byte{1, 2, 3},
+		Options: dhcpv6.Options{
+			{Code: dhcpv6.OptionClientID, Data: []byte{0, 3, 0, 1, 0xd0, 0x50, 0x99, 0xd3, 0x33, 0x61}},
+			{Code: dhcpv6.OptionClientArch, Data: []byte{0, 0x10}},
+		},
+	}
+
+	relayed := &dhcpv6.RelayMessage{
+		Type:     dhcpv6.MessageRelayForward,
+		HopCount: 1,
+		LinkAddr: net.ParseIP("2001:db8:1::1"),
+		PeerAddr: net.ParseIP("fe80::d250:99ff:fed3:3361"),
+		Options: dhcpv6.Options{
+			{Code: dhcpv6.OptionInterfaceID, Data: []byte("eth1")},
+			{Code: dhcpv6.OptionRelayMessage, Data: req.Marshal()},
+		},
+	}
+
+	b, err := dhcpv6.Handle(responder, relayed.Marshal(), net.ParseIP("2001:db8::10"))
+	require.NoError(t, err)
+
+	reply, err := dhcpv6.ParseRelayMessage(b)
+	require.NoError(t, err)
+
+	assert.Equal(t, dhcpv6.MessageRelayReply, reply.Type)
+	assert.EqualValues(t, 1, reply.HopCount)
+	assert.True(t, reply.PeerAddr.Equal(relayed.PeerAddr))
+
+	interfaceID, _ := reply.Options.Get(dhcpv6.OptionInterfaceID)
+	assert.Equal(t, []byte("eth1"), interfaceID)
+
+	inner, ok := reply.Options.Get(dhcpv6.OptionRelayMessage)
+	require.True(t, ok)
+
+	resp, err := dhcpv6.ParseMessage(inner)
+	require.NoError(t, err)
+
+	assert.Equal(t, dhcpv6.MessageAdvertise, resp.Type)
+
+	url, _ := resp.Options.Get(dhcpv6.OptionBootFileURL)
+	assert.Equal(t, "http://[2001:db8::10]:8081/tftp/ipxe.efi", string(url))
+}
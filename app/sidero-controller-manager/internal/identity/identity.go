@@ -0,0 +1,172 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package identity implements the identity webhook invoked when an unknown machine registers with Sidero API.
+//
+// Webhook receives the raw SMBIOS information and the MAC addresses of the machine, and decides whether the machine
+// is accepted, which labels it gets, and whether the machine is an already known server (e.g. after a motherboard replacement),
+// so that the asset databases can drive the acceptance.
+package identity
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// DefaultTimeout of the webhook request.
+const DefaultTimeout = 10 * time.Second
+
+// Decision of the webhook.
+type Decision string
+
+// Webhook decisions.
+const (
+	// DecisionDefault leaves the acceptance to Sidero (auto accept, acceptance policies).
+	DecisionDefault Decision = ""
+	// DecisionAccept accepts the server.
+	DecisionAccept Decision = "accept"
+	// DecisionReject refuses the registration of the machine.
+	DecisionReject Decision = "reject"
+)
+
+// FailurePolicy defines how the webhook errors are handled.
+type FailurePolicy string
+
+// Failure policies.
+const (
+	// FailurePolicyFail fails the registration, the agent retries it.
+	FailurePolicyFail FailurePolicy = "fail"
+	// FailurePolicyIgnore registers the machine as if there was no webhook.
+	FailurePolicyIgnore FailurePolicy = "ignore"
+)
+
+// ParseFailurePolicy parses the failure policy.
+func ParseFailurePolicy(s string) (FailurePolicy, error) {
+	switch policy := FailurePolicy(s); policy {
+	case FailurePolicyFail, FailurePolicyIgnore:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown identity webhook failure policy %q", s)
+	}
+}
+
+// Request is sent to the webhook as JSON.
+type Request struct {
+	UUID     string `json:"uuid"`
+	Hostname string `json:"hostname,omitempty"`
+	// Address the machine registered from.
+	Address           string                           `json:"address,omitempty"`
+	SystemInformation *metalv1alpha1.SystemInformation `json:"systemInformation,omitempty"`
+	CPU               *metalv1alpha1.CPUInformation    `json:"cpu,omitempty"`
+	BIOS              *metalv1alpha1.BIOSInformation   `json:"bios,omitempty"`
+	MACAddresses      []string                         `json:"macAddresses,omitempty"`
+}
+
+// Response is returned by the webhook as JSON.
+type Response struct {
+	Decision Decision `json:"decision,omitempty"`
+	// Reason of the decision, recorded in the events.
+	Reason string `json:"reason,omitempty"`
+	// Labels set on the server.
+	Labels map[string]string `json:"labels,omitempty"`
+	// ServerName is the name of the existing server the machine is merged into.
+	ServerName string `json:"serverName,omitempty"`
+}
+
+// Validate the webhook response.
+func (r *Response) Validate() error {
+	switch r.Decision {
+	case DecisionDefault, DecisionAccept, DecisionReject:
+	default:
+		return fmt.Errorf("unknown decision %q", r.Decision)
+	}
+
+	for key, value := range r.Labels {
+		if errs := validation.IsQualifiedName(key); len(errs) > 0 {
+			return fmt.Errorf("invalid label key %q: %s", key, strings.Join(errs, "; "))
+		}
+
+		if errs := validation.IsValidLabelValue(value); len(errs) > 0 {
+			return fmt.Errorf("invalid label %q value %q: %s", key, value, strings.Join(errs, "; "))
+		}
+	}
+
+	if r.ServerName != "" {
+		if errs := validation.IsDNS1123Subdomain(r.ServerName); len(errs) > 0 {
+			return fmt.Errorf("invalid server name %q: %s", r.ServerName, strings.Join(errs, "; "))
+		}
+	}
+
+	return nil
+}
+
+// Webhook calls the identity webhook.
+type Webhook struct {
+	URL           string
+	FailurePolicy FailurePolicy
+	Client        *http.Client
+}
+
+// New initializes the webhook.
+func New(url string, timeout time.Duration, failurePolicy FailurePolicy) *Webhook {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	return &Webhook{
+		URL:           url,
+		FailurePolicy: failurePolicy,
+		Client:        &http.Client{Timeout: timeout},
+	}
+}
+
+// Review sends the machine to the webhook and returns the decision.
+func (w *Webhook) Review(ctx context.Context, req *Request) (*Response, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := w.Client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("identity webhook request failed: %w", err)
+	}
+
+	defer httpResp.Body.Close() //nolint:errcheck
+
+	if httpResp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(httpResp.Body, 1024)) //nolint:errcheck
+
+		return nil, fmt.Errorf("identity webhook returned %s: %s", httpResp.Status, strings.TrimSpace(string(message)))
+	}
+
+	var resp Response
+
+	if err = json.NewDecoder(httpResp.Body).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to decode identity webhook response: %w", err)
+	}
+
+	if err = resp.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid identity webhook response: %w", err)
+	}
+
+	return &resp, nil
+}
@@ -0,0 +1,63 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package identity_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/identity"
+)
+
+func TestResponseValidate(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		response identity.Response
+
+		expectedError string
+	}{
+		"empty": {},
+		"accept": {
+			response: identity.Response{
+				Decision:   identity.DecisionAccept,
+				Labels:     map[string]string{"example.com/rack": "r1"},
+				ServerName: "server-1",
+			},
+		},
+		"unknown decision": {
+			response:      identity.Response{Decision: "maybe"},
+			expectedError: `unknown decision "maybe"`,
+		},
+		"invalid label key": {
+			response:      identity.Response{Labels: map[string]string{"rack/": "r1"}},
+			expectedError: `invalid label key "rack/"`,
+		},
+		"invalid label value": {
+			response:      identity.Response{Labels: map[string]string{"rack": "r 1"}},
+			expectedError: `invalid label "rack" value "r 1"`,
+		},
+		"invalid server name": {
+			response:      identity.Response{ServerName: "Server_1"},
+			expectedError: `invalid server name "Server_1"`,
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tc.response.Validate()
+
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedError)
+			}
+		})
+	}
+}
@@ -7,14 +7,21 @@ package ipxe
 import (
 	"bytes"
 	"context"
+	"crypto/sha512"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"text/template"
+	"time"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -28,7 +35,10 @@ import (
 
 	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
 	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/assets"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/metrics"
 	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/sitecache"
 )
 
 var (
@@ -44,17 +54,35 @@ chain ipxe?uuid=${uuid}&mac=${mac:hexhyp}&domain=${domain}&hostname=${hostname}&
 // bootTemplate is embedded into iPXE binary when that binary is sent to the node.
 //
 // bootTemplate should be kept in sync with the bootFile above.
-var bootTemplate = template.Must(template.New("iPXE embedded").Parse(`dhcp
-chain http://{{ .Endpoint }}:{{ .Port }}/ipxe?uuid=${uuid}&mac=${mac:hexhyp}&domain=${domain}&hostname=${hostname}&serial=${serial}&arch=${buildarch}
+// The endpoints are tried in the order of priority, iPXE fails over to the next one if chaining fails.
+var bootTemplate = template.Must(template.New("iPXE embedded").Parse(`{{ .Configure }}
+set query uuid=${uuid}&mac=${mac:hexhyp}&domain=${domain}&hostname=${hostname}&serial=${serial}&arch=${buildarch}
+{{ range .Hosts }}chain http://{{ . }}/ipxe?${query} ||
+{{ end }}exit 1
 `))
 
 // ipxeTemplate is returned as response to `chain` request from the bootFile/bootTemplate to boot actual OS (or Sidero agent).
+//
+// AssetBase is the URL of the site cache the environment is replicated to, the assets are downloaded from Sidero if it's empty.
 var ipxeTemplate = template.Must(template.New("iPXE config").Parse(`#!ipxe
-kernel /env/{{ .Env.Name }}/{{ .KernelAsset }} {{range $arg := .Env.Spec.Kernel.Args}} {{$arg}}{{end}}
-initrd /env/{{ .Env.Name }}/{{ .InitrdAsset }}
+kernel {{ .AssetBase }}/env/{{ .Env.Name }}/{{ .KernelAsset }} {{range $arg := .Env.Spec.Kernel.Args}} {{$arg}}{{end}}
+initrd {{ .AssetBase }}/env/{{ .Env.Name }}/{{ .InitrdAsset }}
 boot
 `))
 
+// ipxeRetryTemplate is returned while the environment assets are not downloaded and verified yet.
+var ipxeRetryTemplate = template.Must(template.New("iPXE retry").Parse(`#!ipxe
+echo Environment {{ .Env }} is not ready, retrying in {{ .Delay }} seconds
+sleep {{ .Delay }}
+chain {{ .URL }}
+`))
+
+// retryDelay is the delay in seconds before iPXE retries booting the environment which is not ready.
+const retryDelay = 30
+
+// siteCacheTimeout limits the time the site cache is checked for, the assets are served by Sidero if the cache doesn't respond.
+const siteCacheTimeout = 3 * time.Second
+
 // ipxeBootFromDiskExit script is used to skip PXE booting and boot from disk via exit.
 const ipxeBootFromDiskExit = `#!ipxe
 exit
@@ -75,10 +103,14 @@ const (
 )
 
 var (
+	agentRootfsHashes sync.Map
+
 	apiEndpoint               string
+	extraAPIEndpoints         []string
 	apiPort                   int
 	extraAgentKernelArgs      string
 	defaultBootFromDiskMethod BootFromDisk
+	externalMachines          bool
 	c                         client.Client
 )
 
@@ -116,15 +148,37 @@ func ipxeHandler(w http.ResponseWriter, r *http.Request) {
 		arch = "amd64"
 	}
 
-	server, serverBinding, err := lookupServer(uuid)
-	if err != nil {
-		log.Printf("Error looking up server: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
+	var (
+		server        *metalv1alpha1.Server
+		serverBinding *infrav1.ServerBinding
+		external      *metalv1alpha1.ExternalMachine
+		env           *metalv1alpha1.Environment
+	)
 
-		return
+	if externalMachines {
+		external, err = lookupExternalMachine(labels["mac"])
+		if err != nil {
+			log.Printf("Error looking up external machine: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	if external != nil {
+		env, err = newEnvironmentFromExternalMachine(external)
+	} else {
+		server, serverBinding, err = lookupServer(uuid)
+		if err != nil {
+			log.Printf("Error looking up server: %v", err)
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		env, err = newEnvironment(server, serverBinding, arch)
 	}
 
-	env, err := newEnvironment(server, serverBinding, arch)
 	if err != nil {
 		if errors.Is(err, ErrBootFromDisk) {
 			log.Printf("Server %q booting from disk", uuid)
@@ -153,18 +207,51 @@ func ipxeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if server != nil {
+	if !strings.HasPrefix(env.ObjectMeta.Name, "agent") && !env.IsReady() {
+		log.Printf("Environment %q is not ready, server %q is going to retry", env.Name, uuid)
+
+		if err = ipxeRetryTemplate.Execute(w, struct {
+			Env   string
+			Delay int
+			URL   string
+		}{
+			Env:   env.Name,
+			Delay: retryDelay,
+			URL:   r.URL.RequestURI(),
+		}); err != nil {
+			log.Printf("error rendering template: %v", err)
+		}
+
+		return
+	}
+
+	switch {
+	case server != nil:
 		log.Printf("Using %q environment for %q", env.Name, server.Name)
-	} else {
+	case external != nil:
+		log.Printf("Using %q environment for external machine %q", env.Name, external.Name)
+	default:
 		log.Printf("Using %q environment", env.Name)
 	}
 
+	var assetBase string
+
+	if !strings.HasPrefix(env.ObjectMeta.Name, "agent") {
+		assetBase, err = siteCacheURL(r, env)
+		if err != nil {
+			// the assets are still served by Sidero
+			log.Printf("error looking up site cache: %v", err)
+		}
+	}
+
 	args := struct {
 		Env         *metalv1alpha1.Environment
+		AssetBase   string
 		KernelAsset string
 		InitrdAsset string
 	}{
 		Env:         env,
+		AssetBase:   assetBase,
 		KernelAsset: constants.KernelAsset,
 		InitrdAsset: constants.InitrdAsset,
 	}
@@ -186,37 +273,71 @@ func ipxeHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !strings.HasPrefix(env.ObjectMeta.Name, "agent") {
+	if server != nil && !strings.HasPrefix(env.ObjectMeta.Name, "agent") {
 		if err = markAsPXEBooted(server); err != nil {
 			log.Printf("error marking server as PXE booted: %s", err)
 		}
 	}
 }
 
-func RegisterIPXE(mux *http.ServeMux, endpoint string, port int, args string, bootMethod BootFromDisk, iPXEPort int, mgrClient client.Client) error {
+// APIEndpoints returns the endpoints (host:port) Sidero is advertised at to the servers in the order of priority:
+// the endpoint followed by the extra (fallback) endpoints.
+func APIEndpoints(endpoint string, extraEndpoints []string, port int) []string {
+	endpoints := make([]string, 0, len(extraEndpoints)+1)
+
+	for _, host := range append([]string{endpoint}, extraEndpoints...) {
+		endpoints = append(endpoints, net.JoinHostPort(host, strconv.Itoa(port)))
+	}
+
+	return endpoints
+}
+
+// EmbeddedScript renders the script embedded into iPXE binaries which chains to the first reachable endpoint.
+func EmbeddedScript(endpoint string, extraEndpoints []string, iPXEPort int) ([]byte, error) {
+	// `dhcp` only configures IPv4, `ifconf` tries all the configurators (IPv6 SLAAC/DHCPv6 and DHCP)
+	configure := "dhcp"
+
+	for _, host := range append([]string{endpoint}, extraEndpoints...) {
+		if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+			configure = "ifconf"
+		}
+	}
+
+	var buf bytes.Buffer
+
+	if err := bootTemplate.Execute(&buf, map[string]interface{}{
+		"Configure": configure,
+		"Hosts":     APIEndpoints(endpoint, extraEndpoints, iPXEPort),
+	}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func RegisterIPXE(mux *http.ServeMux, endpoint string, extraEndpoints []string, port int, args string, bootMethod BootFromDisk, iPXEPort int, assetOptions assets.Options,
+	serveExternalMachines bool, mgrClient client.Client) error {
 	apiEndpoint = endpoint
+	extraAPIEndpoints = extraEndpoints
 	apiPort = port
 	extraAgentKernelArgs = args
 	defaultBootFromDiskMethod = bootMethod
+	externalMachines = serveExternalMachines
 	c = mgrClient
 
-	var embeddedScriptBuf bytes.Buffer
-
-	if err := bootTemplate.Execute(&embeddedScriptBuf, map[string]string{
-		"Endpoint": apiEndpoint,
-		"Port":     strconv.Itoa(iPXEPort),
-	}); err != nil {
+	script, err := EmbeddedScript(apiEndpoint, extraAPIEndpoints, iPXEPort)
+	if err != nil {
 		return err
 	}
 
-	if err := PatchBinaries(embeddedScriptBuf.Bytes()); err != nil {
+	if err = PatchBinaries(script); err != nil {
 		return err
 	}
 
-	mux.Handle("/boot.ipxe", logRequest(http.HandlerFunc(bootFileHandler)))
-	mux.Handle("/ipxe", logRequest(http.HandlerFunc(ipxeHandler)))
-	mux.Handle("/env/", logRequest(http.StripPrefix("/env/", http.FileServer(http.Dir("/var/lib/sidero/env")))))
-	mux.Handle("/tftp/", logRequest(http.StripPrefix("/tftp/", http.FileServer(http.Dir("/var/lib/sidero/tftp")))))
+	mux.Handle("/boot.ipxe", logRequest(metrics.InstrumentBootHandler("ipxe", http.HandlerFunc(bootFileHandler))))
+	mux.Handle("/ipxe", logRequest(metrics.InstrumentBootHandler("ipxe", http.HandlerFunc(ipxeHandler))))
+	mux.Handle("/env/", logRequest(metrics.InstrumentBootHandler("environment", http.StripPrefix("/env/", assets.NewHandler("/var/lib/sidero/env", assetOptions)))))
+	mux.Handle("/tftp/", logRequest(metrics.InstrumentBootHandler("tftp-http", http.StripPrefix("/tftp/", http.FileServer(http.Dir("/var/lib/sidero/tftp"))))))
 
 	return nil
 }
@@ -261,13 +382,8 @@ func parseMAC(s string) (net.HardwareAddr, error) {
 }
 
 func lookupServer(uuid string) (*metalv1alpha1.Server, *infrav1.ServerBinding, error) {
-	key := client.ObjectKey{
-		Name: uuid,
-	}
-
-	s := &metalv1alpha1.Server{}
-
-	if err := c.Get(context.Background(), key, s); err != nil {
+	s, err := metalv1alpha1.LookupServer(context.Background(), c, uuid)
+	if err != nil {
 		if apierrors.IsNotFound(err) {
 			return nil, nil, nil
 		}
@@ -277,7 +393,7 @@ func lookupServer(uuid string) (*metalv1alpha1.Server, *infrav1.ServerBinding, e
 
 	b := &infrav1.ServerBinding{}
 
-	if err := c.Get(context.Background(), key, b); err != nil {
+	if err := c.Get(context.Background(), client.ObjectKey{Name: s.Name}, b); err != nil {
 		if apierrors.IsNotFound(err) {
 			return s, nil, nil
 		}
@@ -288,17 +404,101 @@ func lookupServer(uuid string) (*metalv1alpha1.Server, *infrav1.ServerBinding, e
 	return s, b, nil
 }
 
+// siteCacheURL returns the URL of the site cache the request comes from, if the environment is replicated to the cache.
+func siteCacheURL(r *http.Request, env *metalv1alpha1.Environment) (string, error) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return "", nil
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "", nil
+	}
+
+	cache, err := metalv1alpha1.SiteCacheForAddress(context.Background(), c, ip)
+	if err != nil || cache == nil {
+		return "", err
+	}
+
+	if !env.ReplicatedTo(cache.Name) {
+		log.Printf("Environment %q is not replicated to site cache %q yet", env.Name, cache.Name)
+
+		return "", nil
+	}
+
+	base := strings.TrimRight(cache.Spec.URL, "/")
+
+	// iPXE doesn't verify the assets, so the cache is used only if it still stores the assets Sidero downloaded and verified
+	ctx, cancel := context.WithTimeout(context.Background(), siteCacheTimeout)
+	defer cancel()
+
+	for baseName, asset := range map[string]metalv1alpha1.Asset{
+		constants.KernelAsset: env.Spec.KernelAsset(),
+		constants.InitrdAsset: env.Spec.InitrdAsset(),
+	} {
+		expected := env.ReadyAsset(asset).SHA512
+
+		stored, err := sitecache.StoredChecksum(ctx, nil, fmt.Sprintf("%s/env/%s/%s", base, env.Name, baseName))
+		if err != nil {
+			return "", fmt.Errorf("error checking asset %q in site cache %q: %w", baseName, cache.Name, err)
+		}
+
+		if expected == "" || !strings.EqualFold(stored, expected) {
+			log.Printf("Site cache %q stores asset %q of environment %q with checksum %q, expected %q", cache.Name, baseName, env.Name, stored, expected)
+
+			return "", nil
+		}
+	}
+
+	return base, nil
+}
+
+// lookupExternalMachine returns the external machine allowlisting the MAC address, nil if there is none.
+func lookupExternalMachine(mac string) (*metalv1alpha1.ExternalMachine, error) {
+	hwAddr, err := parseMAC(mac)
+	if err != nil {
+		return nil, nil
+	}
+
+	return metalv1alpha1.LookupExternalMachine(context.Background(), c, hwAddr)
+}
+
+// newEnvironmentFromExternalMachine returns the environment of the external machine, falling back to the default one.
+//
+// External machines are booted into the environment every time, as Sidero doesn't track their state.
+func newEnvironmentFromExternalMachine(machine *metalv1alpha1.ExternalMachine) (*metalv1alpha1.Environment, error) {
+	if machine.Spec.EnvironmentRef == nil {
+		return newDefaultEnvironment()
+	}
+
+	env := &metalv1alpha1.Environment{}
+
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "", Name: machine.Spec.EnvironmentRef.Name}, env); err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
 // newEnvironment handles which env CRD we'll respect for a given server.
-// specied in the server spec overrides everything, specified in the server class overrides default, default is default :).
+// specied in the server spec overrides everything, specified in the metal machine (server binding) overrides the server class,
+// specified in the server class overrides default, default is default :).
+// Servers allocated to the canary run use the environment of the canary instead of the server binding one.
 func newEnvironment(server *metalv1alpha1.Server, serverBinding *infrav1.ServerBinding, arch string) (env *metalv1alpha1.Environment, err error) {
+	canaryInstall := server != nil && serverBinding == nil && server.CanaryInstallRequested()
+
 	// NB: The order of this switch statement is important. It defines the
 	// precedence of which environment to boot.
 	switch {
 	case server == nil:
-		return newAgentEnvironment(arch), nil
-	case serverBinding == nil && !server.Status.IsClean:
-		return newAgentEnvironment(arch), nil
-	case serverBinding == nil:
+		return newAgentEnvironment(arch)
+	case conditions.IsTrue(server, metalv1alpha1.ConditionUnexpectedAgentBoot) && !server.Spec.PXEBootAlways:
+		// server is installed, but booted into the agent unexpectedly, so it is never booted into the agent again until released
+		return nil, ErrBootFromDisk
+	case serverBinding == nil && !canaryInstall && (!server.Status.IsClean || server.ReinventoryRequested()):
+		return newAgentEnvironment(arch)
+	case serverBinding == nil && !canaryInstall:
 		return nil, ErrNotInUse
 	case conditions.Has(server, metalv1alpha1.ConditionPXEBooted) && !server.Spec.PXEBootAlways:
 		return nil, ErrBootFromDisk
@@ -307,6 +507,16 @@ func newEnvironment(server *metalv1alpha1.Server, serverBinding *infrav1.ServerB
 		if err != nil {
 			return nil, err
 		}
+	case canaryInstall:
+		env, err = newEnvironmentFromCanary(server)
+		if err != nil {
+			return nil, err
+		}
+	case serverBinding.Spec.EnvironmentRef != nil:
+		env, err = newEnvironmentFromServerBinding(serverBinding)
+		if err != nil {
+			return nil, err
+		}
 	case serverBinding.Spec.ServerClassRef != nil:
 		env, err = newEnvironmentFromServerClass(serverBinding)
 		if err != nil {
@@ -328,7 +538,12 @@ func newEnvironment(server *metalv1alpha1.Server, serverBinding *infrav1.ServerB
 	return env, nil
 }
 
-func newAgentEnvironment(arch string) *metalv1alpha1.Environment {
+// newAgentEnvironment returns the environment booting the agent.
+//
+// The agent can't boot without the rootfs, so the environment is not served if the rootfs can't be hashed.
+func newAgentEnvironment(arch string) (*metalv1alpha1.Environment, error) {
+	endpoints := APIEndpoints(apiEndpoint, extraAPIEndpoints, apiPort)
+
 	args := []string{
 		"console=tty0",
 		"console=ttyS0",
@@ -344,9 +559,26 @@ func newAgentEnvironment(arch string) *metalv1alpha1.Environment {
 		"random.trust_cpu=on",
 		"slab_nomerge=",
 		"slub_debug=P",
-		fmt.Sprintf("%s=%s:%d", constants.AgentEndpointArg, apiEndpoint, apiPort),
+		fmt.Sprintf("%s=%s", constants.AgentEndpointArg, strings.Join(endpoints, ",")),
+	}
+
+	// agent initramfs only contains the first stage, which fetches the agent rootfs via HTTP
+	hash, err := agentRootfsHash(filepath.Join(constants.DataDirectory, "env"), arch)
+	if err != nil {
+		return nil, fmt.Errorf("error hashing agent rootfs: %w", err)
 	}
 
+	urls := make([]string, 0, len(endpoints))
+
+	for _, endpoint := range endpoints {
+		urls = append(urls, fmt.Sprintf("http://%s/env/agent-%s/%s", endpoint, arch, constants.RootfsAsset))
+	}
+
+	args = append(args,
+		fmt.Sprintf("%s=%s", constants.AgentRootfsArg, strings.Join(urls, ",")),
+		fmt.Sprintf("%s=%s", constants.AgentRootfsSHA512Arg, hash),
+	)
+
 	cmdline := procfs.NewCmdline(strings.Join(args, " "))
 	extra := procfs.NewCmdline(extraAgentKernelArgs)
 
@@ -366,7 +598,37 @@ func newAgentEnvironment(arch string) *metalv1alpha1.Environment {
 		},
 	}
 
-	return env
+	return env, nil
+}
+
+// agentRootfsHash returns SHA512 hash of the agent rootfs image in the environments directory.
+//
+// Agent images are part of the Sidero image, so the hash is calculated once.
+func agentRootfsHash(dir, arch string) (string, error) {
+	path := filepath.Join(dir, "agent-"+arch, constants.RootfsAsset)
+
+	if hash, ok := agentRootfsHashes.Load(path); ok {
+		return hash.(string), nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	h := sha512.New()
+
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	hash := hex.EncodeToString(h.Sum(nil))
+
+	agentRootfsHashes.Store(path, hash)
+
+	return hash, nil
 }
 
 func newDefaultEnvironment() (env *metalv1alpha1.Environment, err error) {
@@ -389,6 +651,34 @@ func newEnvironmentFromServer(server *metalv1alpha1.Server) (env *metalv1alpha1.
 	return env, nil
 }
 
+func newEnvironmentFromServerBinding(serverBinding *infrav1.ServerBinding) (env *metalv1alpha1.Environment, err error) {
+	env = &metalv1alpha1.Environment{}
+
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "", Name: serverBinding.Spec.EnvironmentRef.Name}, env); err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+func newEnvironmentFromCanary(server *metalv1alpha1.Server) (env *metalv1alpha1.Environment, err error) {
+	canary := &metalv1alpha1.Canary{}
+
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "", Name: server.CanaryHeld()}, canary); err != nil {
+		return nil, err
+	}
+
+	if canary.Spec.EnvironmentRef != nil {
+		env = &metalv1alpha1.Environment{}
+
+		if err := c.Get(context.Background(), types.NamespacedName{Namespace: "", Name: canary.Spec.EnvironmentRef.Name}, env); err != nil {
+			return nil, err
+		}
+	}
+
+	return env, nil
+}
+
 func newEnvironmentFromServerClass(serverBinding *infrav1.ServerBinding) (env *metalv1alpha1.Environment, err error) {
 	serverClassResource := &metalv1alpha1.ServerClass{}
 
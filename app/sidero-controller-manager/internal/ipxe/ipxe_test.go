@@ -4,11 +4,105 @@
 
 package ipxe_test
 
-import "testing"
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
 
-func TestEmpty(t *testing.T) {
-	// added for accurate coverage estimation
-	//
-	// please remove it once any unit-test is added
-	// for this package
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/ipxe"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/uboot"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
+)
+
+func TestUBootScript(t *testing.T) {
+	t.Parallel()
+
+	for _, filename := range []string{ipxe.UBootScriptFile, "/" + ipxe.UBootScriptFile} {
+		image, err := ipxe.UBootScript(filename)
+		require.NoError(t, err)
+
+		_, script, err := uboot.Decode(image)
+		require.NoError(t, err)
+
+		assert.Contains(t, script, "tftpboot ${scriptaddr} uboot/${ethaddr}.scr.uimg\n")
+	}
+
+	for _, filename := range []string{
+		"ipxe.efi",
+		"uboot/not-a-mac.scr.uimg",
+		"uboot/dc:a6:32:01:02:03.txt",
+		"other/dc:a6:32:01:02:03.scr.uimg",
+	} {
+		_, err := ipxe.UBootScript(filename)
+		assert.True(t, errors.Is(err, os.ErrNotExist), filename)
+	}
+}
+
+func TestAPIEndpoints(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, []string{"172.20.0.2:8081"}, ipxe.APIEndpoints("172.20.0.2", nil, 8081))
+	assert.Equal(t,
+		[]string{"172.20.0.2:8081", "sidero.example.com:8081", "[2001:db8::2]:8081"},
+		ipxe.APIEndpoints("172.20.0.2", []string{"sidero.example.com", "2001:db8::2"}, 8081),
+	)
+}
+
+func TestEmbeddedScript(t *testing.T) {
+	t.Parallel()
+
+	script, err := ipxe.EmbeddedScript("172.20.0.2", []string{"10.5.0.2"}, 8081)
+	require.NoError(t, err)
+
+	assert.Equal(t, `dhcp
+set query uuid=${uuid}&mac=${mac:hexhyp}&domain=${domain}&hostname=${hostname}&serial=${serial}&arch=${buildarch}
+chain http://172.20.0.2:8081/ipxe?${query} ||
+chain http://10.5.0.2:8081/ipxe?${query} ||
+exit 1
+`, string(script))
+
+	script, err = ipxe.EmbeddedScript("172.20.0.2", []string{"2001:db8::2"}, 8081)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(script), "ifconf\n")
+	assert.Contains(t, string(script), "chain http://[2001:db8::2]:8081/ipxe?${query} ||\n")
+}
+
+func TestAgentRootfsHash(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "agent-amd64"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "agent-amd64", constants.RootfsAsset), []byte("rootfs"), 0o644))
+
+	// unreadable rootfs
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "agent-arm64", constants.RootfsAsset), 0o755))
+
+	hash, err := ipxe.AgentRootfsHash(dir, "amd64")
+	require.NoError(t, err)
+
+	expected := sha512.Sum512([]byte("rootfs"))
+	assert.Equal(t, hex.EncodeToString(expected[:]), hash)
+
+	_, err = ipxe.AgentRootfsHash(dir, "arm64")
+	assert.Error(t, err)
+
+	_, err = ipxe.AgentRootfsHash(t.TempDir(), "amd64")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+}
+
+func TestAgentEnvironmentMissingRootfs(t *testing.T) {
+	t.Parallel()
+
+	// agent environment is not served without the rootfs, as the agent reboots if the rootfs args are missing
+	env, err := ipxe.NewEnvironment(nil, nil, "missing")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+	assert.Nil(t, env)
 }
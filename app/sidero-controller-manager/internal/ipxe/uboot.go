@@ -0,0 +1,227 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ipxe
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/uboot"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
+)
+
+// UBootScriptFile is the boot script u-boot distro boot fetches via DHCP/TFTP (`boot_script_dhcp`).
+const UBootScriptFile = "boot.scr.uimg"
+
+// uBootScriptDirectory contains the per-server boot scripts, named after the MAC address.
+const uBootScriptDirectory = "uboot"
+
+// uBootChainScript is the generic boot script which chains to the script for the booting server.
+//
+// u-boot doesn't have the SMBIOS UUID at this stage, so the server is identified by the MAC address.
+var uBootChainScript = fmt.Sprintf(`echo Sidero: loading the boot script for ${ethaddr}
+tftpboot ${scriptaddr} %s/${ethaddr}.scr.uimg
+source ${scriptaddr}
+`, uBootScriptDirectory)
+
+// uBootTemplate boots the environment (or Sidero agent) assets fetched via TFTP.
+var uBootTemplate = template.Must(template.New("u-boot boot").Parse(`echo Sidero: booting environment {{ .Env.Name }}
+setenv bootargs '{{ .Args }}'
+tftpboot ${kernel_addr_r} env/{{ .Env.Name }}/{{ .KernelAsset }}
+tftpboot ${ramdisk_addr_r} env/{{ .Env.Name }}/{{ .InitrdAsset }}
+booti ${kernel_addr_r} ${ramdisk_addr_r}:${filesize} ${fdt_addr}
+`))
+
+// uBootRetryTemplate is returned while the environment assets are not downloaded and verified yet.
+var uBootRetryTemplate = template.Must(template.New("u-boot retry").Parse(`echo Environment {{ .Env }} is not ready, retrying in {{ .Delay }} seconds
+sleep {{ .Delay }}
+reset
+`))
+
+// uBootBootFromDisk skips network boot by running distro boot with the local boot targets only.
+const uBootBootFromDisk = `echo Sidero: booting from disk
+setenv boot_targets mmc0 mmc1 usb0 nvme0
+run distro_bootcmd
+`
+
+// UBootScript generates the u-boot boot scripts served via TFTP.
+//
+// It returns os.ErrNotExist for the files it doesn't handle and for the servers which should not network boot,
+// so that u-boot proceeds with the next boot target.
+func UBootScript(filename string) ([]byte, error) {
+	filename = strings.TrimPrefix(path.Clean("/"+filename), "/")
+
+	if filename == UBootScriptFile {
+		return uboot.Script("sidero chain", uBootChainScript), nil
+	}
+
+	if path.Dir(filename) != uBootScriptDirectory || !strings.HasSuffix(filename, ".scr.uimg") {
+		return nil, os.ErrNotExist
+	}
+
+	mac, err := net.ParseMAC(strings.TrimSuffix(path.Base(filename), ".scr.uimg"))
+	if err != nil {
+		return nil, os.ErrNotExist
+	}
+
+	if c == nil {
+		return nil, fmt.Errorf("iPXE server is not registered yet")
+	}
+
+	script, err := uBootScriptForMAC(context.Background(), mac)
+	if err != nil {
+		return nil, err
+	}
+
+	return uboot.Script("sidero boot", script), nil
+}
+
+func uBootScriptForMAC(ctx context.Context, mac net.HardwareAddr) (string, error) {
+	var (
+		server   *metalv1alpha1.Server
+		external *metalv1alpha1.ExternalMachine
+		env      *metalv1alpha1.Environment
+		err      error
+	)
+
+	if externalMachines {
+		if external, err = metalv1alpha1.LookupExternalMachine(ctx, c, mac); err != nil {
+			return "", err
+		}
+	}
+
+	name := mac.String()
+
+	if external != nil {
+		name = external.Name
+
+		env, err = newEnvironmentFromExternalMachine(external)
+	} else {
+		if server, err = lookupServerByMAC(ctx, mac); err != nil {
+			return "", err
+		}
+
+		var serverBinding *infrav1.ServerBinding
+
+		if server != nil {
+			name = server.Name
+
+			if server, serverBinding, err = lookupServer(server.Name); err != nil {
+				return "", err
+			}
+		}
+
+		// u-boot flow is only supported for arm64 boards
+		env, err = newEnvironment(server, serverBinding, "arm64")
+	}
+
+	if err != nil {
+		if errors.Is(err, ErrBootFromDisk) {
+			log.Printf("Server %q booting from disk", name)
+
+			return uBootBootFromDisk, nil
+		}
+
+		if errors.Is(err, ErrNotInUse) {
+			log.Printf("Server %q not in use, skipping", name)
+
+			return "", os.ErrNotExist
+		}
+
+		if apierrors.IsNotFound(err) {
+			log.Printf("Environment not found: %v", err)
+
+			return "", os.ErrNotExist
+		}
+
+		return "", err
+	}
+
+	var buf bytes.Buffer
+
+	if !strings.HasPrefix(env.ObjectMeta.Name, "agent") && !env.IsReady() {
+		log.Printf("Environment %q is not ready, server %q is going to retry", env.Name, name)
+
+		err = uBootRetryTemplate.Execute(&buf, struct {
+			Env   string
+			Delay int
+		}{
+			Env:   env.Name,
+			Delay: retryDelay,
+		})
+
+		return buf.String(), err
+	}
+
+	args := env.Spec.KernelCmdline()
+
+	// arguments are single-quoted to prevent the variable expansion by the u-boot shell
+	if strings.Contains(args, "'") {
+		return "", fmt.Errorf("environment %q kernel arguments can't contain single quotes", env.Name)
+	}
+
+	log.Printf("Using %q environment for %q", env.Name, name)
+
+	if err = uBootTemplate.Execute(&buf, struct {
+		Env         *metalv1alpha1.Environment
+		Args        string
+		KernelAsset string
+		InitrdAsset string
+	}{
+		Env:         env,
+		Args:        args,
+		KernelAsset: constants.KernelAsset,
+		InitrdAsset: constants.InitrdAsset,
+	}); err != nil {
+		return "", err
+	}
+
+	if server != nil && !strings.HasPrefix(env.ObjectMeta.Name, "agent") {
+		if err = markAsPXEBooted(server); err != nil {
+			log.Printf("error marking server as PXE booted: %s", err)
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// lookupServerByMAC returns the server with the network interface MAC address in the inventory.
+//
+// Servers which haven't reported the inventory yet are not found, so they boot the agent.
+func lookupServerByMAC(ctx context.Context, mac net.HardwareAddr) (*metalv1alpha1.Server, error) {
+	var servers metalv1alpha1.ServerList
+
+	if err := c.List(ctx, &servers); err != nil {
+		return nil, err
+	}
+
+	for i := range servers.Items {
+		server := &servers.Items[i]
+
+		if server.Status.Inventory == nil {
+			continue
+		}
+
+		for _, iface := range server.Status.Inventory.NetworkInterfaces {
+			if hw, err := net.ParseMAC(iface.MAC); err == nil && bytes.Equal(hw, mac) {
+				return server, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
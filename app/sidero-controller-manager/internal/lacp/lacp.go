@@ -0,0 +1,180 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package lacp detects the link aggregation configured on the switch port a network interface is connected to.
+//
+// Switch ports in the active LACP mode send LACPDUs, switch ports in any mode might advertise the aggregation
+// status in the LLDP link aggregation TLV. Servers PXE boot fine via such ports (with LACP fallback enabled on the switch),
+// but the installed system has no network unless the interfaces are bonded.
+package lacp
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+)
+
+// EtherTypes of the frames carrying the link aggregation information.
+const (
+	EtherTypeSlowProtocols = 0x8809
+	EtherTypeLLDP          = 0x88cc
+)
+
+// Multicast destination addresses of the LACPDUs and LLDPDUs.
+var (
+	SlowProtocolsMulticast = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x02}
+	LLDPMulticast          = net.HardwareAddr{0x01, 0x80, 0xc2, 0x00, 0x00, 0x0e}
+)
+
+// Sources of the detected aggregation.
+const (
+	SourceLACP = "lacp"
+	SourceLLDP = "lldp"
+)
+
+// Aggregation is the link aggregation detected on the switch port.
+type Aggregation struct {
+	// Source is SourceLACP or SourceLLDP.
+	Source string
+	// Partner is the switch (LACP system ID or LLDP system name/chassis ID).
+	Partner string
+	// Port is the switch port (LACP port number or LLDP port ID).
+	Port string
+}
+
+const (
+	slowProtocolsSubtypeLACP = 0x01
+	lacpTLVActor             = 0x01
+	lacpActorInfoLength      = 20
+)
+
+// ParseLACPDU parses the slow protocols frame payload.
+//
+// Nil is returned for the other slow protocols (e.g. marker or OAM).
+func ParseLACPDU(b []byte) (*Aggregation, error) {
+	if len(b) < 2 {
+		return nil, errors.New("LACPDU is too short")
+	}
+
+	if b[0] != slowProtocolsSubtypeLACP {
+		return nil, nil
+	}
+
+	b = b[2:]
+
+	if len(b) < lacpActorInfoLength || b[0] != lacpTLVActor || b[1] != lacpActorInfoLength {
+		return nil, errors.New("truncated LACPDU actor information")
+	}
+
+	// actor is the switch: system priority (2), system (6), key (2), port priority (2), port (2), state (1)
+	system := net.HardwareAddr(b[4:10])
+	port := binary.BigEndian.Uint16(b[14:16])
+
+	return &Aggregation{
+		Source:  SourceLACP,
+		Partner: system.String(),
+		Port:    strconv.Itoa(int(port)),
+	}, nil
+}
+
+const (
+	lldpTLVEnd         = 0
+	lldpTLVChassisID   = 1
+	lldpTLVPortID      = 2
+	lldpTLVSystemName  = 5
+	lldpTLVOrgSpecific = 127
+
+	lldpChassisIDSubtypeMAC = 4
+	lldpPortIDSubtypeMAC    = 3
+
+	// 802.1AB-2009 moved the link aggregation TLV from 802.3 to 802.1, switches send either.
+	lldpSubtype8021LinkAggregation = 7
+	lldpSubtype8023LinkAggregation = 3
+
+	lldpLinkAggregationStatusEnabled = 1 << 1
+)
+
+var (
+	oui8021 = [3]byte{0x00, 0x80, 0xc2}
+	oui8023 = [3]byte{0x00, 0x12, 0x0f}
+)
+
+// ParseLLDPDU parses the LLDP frame payload.
+//
+// Nil is returned if the switch port is not currently aggregated (aggregation capability alone is not reported).
+func ParseLLDPDU(b []byte) (*Aggregation, error) {
+	var (
+		chassis, port, systemName string
+		aggregated                bool
+	)
+
+	for len(b) > 0 {
+		if len(b) < 2 {
+			return nil, errors.New("truncated LLDP TLV header")
+		}
+
+		header := binary.BigEndian.Uint16(b)
+		typ, length := header>>9, int(header&0x1ff)
+
+		if len(b) < 2+length {
+			return nil, fmt.Errorf("truncated LLDP TLV %d", typ)
+		}
+
+		value := b[2 : 2+length]
+		b = b[2+length:]
+
+		switch typ {
+		case lldpTLVEnd:
+			b = nil
+		case lldpTLVChassisID:
+			chassis = lldpID(value, lldpChassisIDSubtypeMAC)
+		case lldpTLVPortID:
+			port = lldpID(value, lldpPortIDSubtypeMAC)
+		case lldpTLVSystemName:
+			systemName = string(value)
+		case lldpTLVOrgSpecific:
+			if len(value) < 5 {
+				continue
+			}
+
+			var oui [3]byte
+
+			copy(oui[:], value[:3])
+
+			if (oui == oui8021 && value[3] == lldpSubtype8021LinkAggregation) || (oui == oui8023 && value[3] == lldpSubtype8023LinkAggregation) {
+				aggregated = value[4]&lldpLinkAggregationStatusEnabled != 0
+			}
+		}
+	}
+
+	if !aggregated {
+		return nil, nil
+	}
+
+	partner := systemName
+	if partner == "" {
+		partner = chassis
+	}
+
+	return &Aggregation{
+		Source:  SourceLLDP,
+		Partner: partner,
+		Port:    port,
+	}, nil
+}
+
+// lldpID formats the chassis or port ID: subtype (1) followed by the ID.
+func lldpID(value []byte, macSubtype byte) string {
+	if len(value) < 2 {
+		return ""
+	}
+
+	if value[0] == macSubtype && len(value) == 7 {
+		return net.HardwareAddr(value[1:]).String()
+	}
+
+	return string(value[1:])
+}
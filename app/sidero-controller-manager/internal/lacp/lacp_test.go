@@ -0,0 +1,96 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package lacp_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/lacp"
+)
+
+func TestParseLACPDU(t *testing.T) {
+	t.Parallel()
+
+	lacpdu := []byte{
+		0x01, 0x01, // subtype LACP, version
+		0x01, 0x14, // actor information
+		0x80, 0x00, // system priority
+		0x00, 0x1c, 0x73, 0x01, 0x02, 0x03, // system
+		0x00, 0x0a, // key
+		0x80, 0x00, // port priority
+		0x00, 0x11, // port
+		0x3d,             // state
+		0x00, 0x00, 0x00, // reserved
+		0x02, 0x14, // partner information follows
+	}
+
+	aggregation, err := lacp.ParseLACPDU(lacpdu)
+	require.NoError(t, err)
+	assert.Equal(t, &lacp.Aggregation{Source: lacp.SourceLACP, Partner: "00:1c:73:01:02:03", Port: "17"}, aggregation)
+
+	// marker protocol
+	aggregation, err = lacp.ParseLACPDU([]byte{0x02, 0x01})
+	require.NoError(t, err)
+	assert.Nil(t, aggregation)
+
+	_, err = lacp.ParseLACPDU(lacpdu[:10])
+	assert.Error(t, err)
+}
+
+func TestParseLLDPDU(t *testing.T) {
+	t.Parallel()
+
+	lldpdu := func(aggregationTLV ...byte) []byte {
+		b := []byte{
+			0x02, 0x07, 0x04, 0x00, 0x1c, 0x73, 0x01, 0x02, 0x03, // chassis ID (MAC)
+			0x04, 0x0b, 0x05, 'E', 't', 'h', 'e', 'r', 'n', 'e', 't', '1', '7', // port ID (interface name)
+			0x06, 0x02, 0x00, 0x78, // TTL
+			0x0a, 0x05, 't', 'o', 'r', '-', '1', // system name
+		}
+
+		if aggregationTLV != nil {
+			b = append(b, 0xfe, byte(len(aggregationTLV)))
+			b = append(b, aggregationTLV...)
+		}
+
+		return append(b, 0x00, 0x00)
+	}
+
+	for name, tc := range map[string]struct {
+		lldpdu   []byte
+		expected *lacp.Aggregation
+	}{
+		"no aggregation TLV": {
+			lldpdu: lldpdu(),
+		},
+		"capable": {
+			lldpdu: lldpdu(0x00, 0x80, 0xc2, 0x07, 0x01, 0x00, 0x00, 0x00, 0x00),
+		},
+		"802.1 aggregated": {
+			lldpdu:   lldpdu(0x00, 0x80, 0xc2, 0x07, 0x03, 0x00, 0x00, 0x00, 0x11),
+			expected: &lacp.Aggregation{Source: lacp.SourceLLDP, Partner: "tor-1", Port: "Ethernet17"},
+		},
+		"802.3 aggregated": {
+			lldpdu:   lldpdu(0x00, 0x12, 0x0f, 0x03, 0x03, 0x00, 0x00, 0x00, 0x11),
+			expected: &lacp.Aggregation{Source: lacp.SourceLLDP, Partner: "tor-1", Port: "Ethernet17"},
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			aggregation, err := lacp.ParseLLDPDU(tc.lldpdu)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, aggregation)
+		})
+	}
+
+	_, err := lacp.ParseLLDPDU([]byte{0x02, 0x07, 0x04})
+	assert.Error(t, err)
+}
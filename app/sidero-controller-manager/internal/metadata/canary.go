@@ -0,0 +1,97 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/render"
+)
+
+// fetchCanaryConfig returns the scratch machine configuration of the canary run the server is allocated to.
+//
+// The configuration is patched with the environment config patches only, as the server is not allocated via the serverclass,
+// and the time the configuration is served is recorded in the Canary status.
+func (m *metadataConfigs) fetchCanaryConfig(ctx context.Context, w http.ResponseWriter, server *metalv1alpha1.Server) {
+	var canary metalv1alpha1.Canary
+
+	if err := m.client.Get(ctx, types.NamespacedName{Name: server.CanaryHeld()}, &canary); err != nil {
+		throwError(w, errorWithCode{http.StatusInternalServerError, fmt.Errorf("failure fetching canary %s: %w", server.CanaryHeld(), err)})
+
+		return
+	}
+
+	if canary.Status.Run == nil || canary.Status.Run.ServerRef != server.Name {
+		throwError(w, errorWithCode{http.StatusNotFound, fmt.Errorf("server %s is not in the run of canary %s", server.Name, canary.Name)})
+
+		return
+	}
+
+	ref := canary.Spec.ConfigFrom
+
+	config, err := m.source(ctx).Fetch(metalv1alpha1.ConfigPatchesRef{
+		Kind:      ref.Kind,
+		Namespace: ref.Namespace,
+		Name:      ref.Name,
+		Key:       ref.Key,
+	})
+	if err != nil {
+		throwError(w, errorWithCode{http.StatusInternalServerError, fmt.Errorf("failure fetching configuration of canary %s: %s", canary.Name, err)})
+
+		return
+	}
+
+	name := metalv1alpha1.EnvironmentDefault
+
+	switch {
+	case server.Spec.EnvironmentRef != nil:
+		name = server.Spec.EnvironmentRef.Name
+	case canary.Spec.EnvironmentRef != nil:
+		name = canary.Spec.EnvironmentRef.Name
+	}
+
+	env, ewc := m.fetchEnvironmentByName(ctx, name)
+	if ewc.errorObj != nil {
+		throwError(w, ewc)
+
+		return
+	}
+
+	decodedData, ewc := patchConfigs(config, render.Layers(env, nil, nil, nil), m.source(ctx))
+	if ewc.errorObj != nil {
+		throwError(w, ewc)
+
+		return
+	}
+
+	if canary.Status.ConfigServedTime == nil {
+		patch := runtimeclient.MergeFrom(canary.DeepCopy())
+
+		now := metav1.Now()
+		canary.Status.ConfigServedTime = &now
+
+		if err = m.client.Status().Patch(ctx, &canary, patch); err != nil {
+			throwError(w, errorWithCode{http.StatusInternalServerError, fmt.Errorf("failure recording configuration served in canary %s: %s", canary.Name, err)})
+
+			return
+		}
+	}
+
+	if _, err := w.Write(decodedData); err != nil {
+		log.Printf("failed to write data: %v", err)
+
+		return
+	}
+
+	log.Printf("successfully returned metadata for server %q in the run of canary %q", server.Name, canary.Name)
+}
@@ -0,0 +1,124 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/render"
+)
+
+// fetchExternalConfig returns the machine configuration of the external machine.
+//
+// The bootstrap data of the referenced CAPI Machine is patched with the environment config patches only,
+// as there are no server, serverclass or serverbinding for the external machine.
+func (m *metadataConfigs) fetchExternalConfig(ctx context.Context, w http.ResponseWriter, external *metalv1alpha1.ExternalMachine) {
+	log.Printf("resolved metadata request to external machine %s", external.Name)
+
+	if external.Spec.MachineRef == nil {
+		throwError(
+			w,
+			errorWithCode{
+				http.StatusNotFound,
+				fmt.Errorf("external machine %s has no machine reference", external.Name),
+			},
+		)
+
+		return
+	}
+
+	key := types.NamespacedName{
+		Namespace: external.Spec.MachineRef.Namespace,
+		Name:      external.Spec.MachineRef.Name,
+	}
+
+	if key.Namespace == "" {
+		key.Namespace = corev1.NamespaceDefault
+	}
+
+	// bootstrap data is served to the unauthenticated requests, so the machines are restricted to the trusted namespaces
+	if !m.namespaces.Allowed(key.Namespace) {
+		throwError(
+			w,
+			errorWithCode{
+				http.StatusForbidden,
+				fmt.Errorf("machine %s of external machine %s: references to namespace %q are not allowed", key, external.Name, key.Namespace),
+			},
+		)
+
+		return
+	}
+
+	var machine clusterv1.Machine
+
+	if err := m.client.Get(ctx, key, &machine); err != nil {
+		throwError(
+			w,
+			errorWithCode{
+				http.StatusInternalServerError,
+				fmt.Errorf("failure fetching machine %s of external machine %s: %w", key, external.Name, err),
+			},
+		)
+
+		return
+	}
+
+	if machine.Spec.Bootstrap.DataSecretName == nil {
+		throwError(
+			w,
+			errorWithCode{
+				http.StatusNotFound,
+				fmt.Errorf("no dataSecretName present for machine %s/%s", machine.Namespace, machine.Name),
+			},
+		)
+
+		return
+	}
+
+	bootstrapData, _, ewc := m.fetchBootstrapSecret(ctx, types.NamespacedName{
+		Namespace: machine.Namespace,
+		Name:      *machine.Spec.Bootstrap.DataSecretName,
+	})
+	if ewc.errorObj != nil {
+		throwError(w, ewc)
+
+		return
+	}
+
+	name := metalv1alpha1.EnvironmentDefault
+	if external.Spec.EnvironmentRef != nil {
+		name = external.Spec.EnvironmentRef.Name
+	}
+
+	env, ewc := m.fetchEnvironmentByName(ctx, name)
+	if ewc.errorObj != nil {
+		throwError(w, ewc)
+
+		return
+	}
+
+	decodedData, ewc := patchConfigs(bootstrapData, render.Layers(env, nil, nil, nil), m.source(ctx))
+	if ewc.errorObj != nil {
+		throwError(w, ewc)
+
+		return
+	}
+
+	if _, err := w.Write(decodedData); err != nil {
+		log.Printf("failed to write data: %v", err)
+
+		return
+	}
+
+	log.Printf("successfully returned metadata for external machine %q", external.Name)
+}
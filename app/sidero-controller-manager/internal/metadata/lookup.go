@@ -0,0 +1,423 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package metadata
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	runtimeclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// Identifier is the way the metadata server resolves the server requesting the machine configuration.
+type Identifier string
+
+// Supported identifiers.
+const (
+	// IdentifierUUID is the SMBIOS UUID from the `uuid` query parameter, matched against the server name.
+	IdentifierUUID Identifier = "uuid"
+	// IdentifierMAC is the MAC address from the `mac` query parameter (or the MAC address of the request
+	// source address in the neighbor table), matched against the network interfaces in the server inventory.
+	IdentifierMAC Identifier = "mac"
+	// IdentifierSerial is the SMBIOS serial number from the `serial` query parameter,
+	// matched against the server system information.
+	IdentifierSerial Identifier = "serial"
+)
+
+// DefaultIdentifiers is the default lookup order.
+const DefaultIdentifiers = "uuid,mac,serial"
+
+// ErrServerNotFound is returned if none of the identifiers resolve to an allocated server.
+var ErrServerNotFound = errors.New("no allocated server found")
+
+// brokenUUIDs are reported by the firmware which doesn't set the system UUID, so they are shared by many machines.
+var brokenUUIDs = map[string]struct{}{
+	"00000000-0000-0000-0000-000000000000": {},
+	"ffffffff-ffff-ffff-ffff-ffffffffffff": {},
+	"03000200-0400-0500-0006-000700080009": {},
+}
+
+// brokenSerials are placeholder serial numbers left by the vendors.
+var brokenSerials = map[string]struct{}{
+	"0":                      {},
+	"0123456789":             {},
+	"default string":         {},
+	"none":                   {},
+	"not specified":          {},
+	"system serial number":   {},
+	"to be filled by o.e.m.": {},
+}
+
+// ParseIdentifiers parses a comma delimited list of identifiers.
+func ParseIdentifiers(s string) ([]Identifier, error) {
+	var identifiers []Identifier
+
+	seen := map[Identifier]struct{}{}
+
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+
+		switch Identifier(item) {
+		case "":
+			continue
+		case IdentifierUUID, IdentifierMAC, IdentifierSerial:
+			if _, ok := seen[Identifier(item)]; ok {
+				return nil, fmt.Errorf("duplicate identifier %q", item)
+			}
+
+			seen[Identifier(item)] = struct{}{}
+
+			identifiers = append(identifiers, Identifier(item))
+		default:
+			return nil, fmt.Errorf("unsupported identifier %q", item)
+		}
+	}
+
+	if len(identifiers) == 0 {
+		return nil, errors.New("no identifiers specified")
+	}
+
+	return identifiers, nil
+}
+
+// NeighborResolver returns the MAC address of the IP address on the local network.
+type NeighborResolver func(ip net.IP) (net.HardwareAddr, error)
+
+// NeighborTable resolves the IP addresses with the kernel neighbor tables: ARP for IPv4 and NDP for IPv6.
+//
+// It only works for the servers on the same L2 network, e.g. when running with the host network.
+func NeighborTable(ip net.IP) (net.HardwareAddr, error) {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ARPTable(ip4)
+	}
+
+	return NDPTable(ip)
+}
+
+// ARPTable resolves the IPv4 addresses with the kernel ARP table.
+func ARPTable(ip net.IP) (net.HardwareAddr, error) {
+	f, err := os.Open("/proc/net/arp")
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	return ParseARPTable(f, ip)
+}
+
+// ParseARPTable looks up the IP address in the /proc/net/arp formatted table.
+func ParseARPTable(r io.Reader, ip net.IP) (net.HardwareAddr, error) {
+	scanner := bufio.NewScanner(r)
+
+	// skip the header
+	scanner.Scan()
+
+	for scanner.Scan() {
+		// IP address, HW type, Flags, HW address, Mask, Device
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+
+		if entryIP := net.ParseIP(fields[0]); entryIP == nil || !entryIP.Equal(ip) {
+			continue
+		}
+
+		// incomplete entries have zero MAC address
+		if fields[3] == "00:00:00:00:00:00" {
+			break
+		}
+
+		return net.ParseMAC(fields[3])
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, fmt.Errorf("no neighbor entry for %s", ip)
+}
+
+// NDPTable resolves the IPv6 addresses with the kernel neighbor table (via netlink).
+func NDPTable(ip net.IP) (net.HardwareAddr, error) {
+	rib, err := syscall.NetlinkRIB(syscall.RTM_GETNEIGH, syscall.AF_INET6)
+	if err != nil {
+		return nil, fmt.Errorf("error dumping neighbor table: %w", err)
+	}
+
+	msgs, err := syscall.ParseNetlinkMessage(rib)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing neighbor table: %w", err)
+	}
+
+	for _, msg := range msgs {
+		if msg.Header.Type != syscall.RTM_NEWNEIGH {
+			continue
+		}
+
+		dst, lladdr, ok := parseNeighborMessage(msg.Data)
+		if !ok || !dst.Equal(ip) {
+			continue
+		}
+
+		return lladdr, nil
+	}
+
+	return nil, fmt.Errorf("no neighbor entry for %s", ip)
+}
+
+// parseNeighborMessage parses struct ndmsg followed by the route attributes (NDA_*).
+func parseNeighborMessage(b []byte) (net.IP, net.HardwareAddr, bool) {
+	const (
+		ndmsgLen      = 12
+		ndaDst        = 1
+		ndaLLAddr     = 2
+		nudIncomplete = 0x01
+		nudFailed     = 0x20
+	)
+
+	if len(b) < ndmsgLen {
+		return nil, nil, false
+	}
+
+	state := *(*uint16)(unsafe.Pointer(&b[8])) //nolint:gosec
+
+	if state&(nudIncomplete|nudFailed) != 0 {
+		return nil, nil, false
+	}
+
+	var (
+		dst    net.IP
+		lladdr net.HardwareAddr
+	)
+
+	for attrs := b[ndmsgLen:]; len(attrs) >= syscall.SizeofRtAttr; {
+		attr := (*syscall.RtAttr)(unsafe.Pointer(&attrs[0])) //nolint:gosec
+
+		if int(attr.Len) < syscall.SizeofRtAttr || int(attr.Len) > len(attrs) {
+			break
+		}
+
+		data := attrs[syscall.SizeofRtAttr:attr.Len]
+
+		switch attr.Type {
+		case ndaDst:
+			dst = net.IP(data)
+		case ndaLLAddr:
+			lladdr = net.HardwareAddr(data)
+		}
+
+		// attributes are aligned to 4 bytes
+		next := (int(attr.Len) + syscall.RTA_ALIGNTO - 1) &^ (syscall.RTA_ALIGNTO - 1)
+		if next > len(attrs) {
+			break
+		}
+
+		attrs = attrs[next:]
+	}
+
+	return dst, lladdr, dst != nil && len(lladdr) > 0
+}
+
+// Lookup resolves the server requesting the machine configuration by the identifiers in order,
+// falling back to the next identifier if the server is not found.
+//
+// Only allocated servers are considered, so that a server with duplicate identifiers
+// (e.g. a broken UUID shared with another machine) doesn't get someone else's configuration.
+type Lookup struct {
+	Client      runtimeclient.Client
+	Identifiers []Identifier
+	Neighbors   NeighborResolver
+}
+
+// Resolve returns the server for the request and the identifier which resolved it.
+func (l *Lookup) Resolve(ctx context.Context, r *http.Request) (*metalv1alpha1.Server, Identifier, error) {
+	vals := r.URL.Query()
+
+	var reasons []string
+
+	for _, identifier := range l.Identifiers {
+		var (
+			server *metalv1alpha1.Server
+			reason string
+			err    error
+		)
+
+		switch identifier {
+		case IdentifierUUID:
+			server, reason, err = l.byUUID(ctx, vals.Get("uuid"))
+		case IdentifierMAC:
+			server, reason, err = l.byMAC(ctx, vals.Get("mac"), r.RemoteAddr)
+		case IdentifierSerial:
+			server, reason, err = l.bySerial(ctx, vals.Get("serial"))
+		}
+
+		if err != nil {
+			return nil, identifier, err
+		}
+
+		if server != nil {
+			return server, identifier, nil
+		}
+
+		reasons = append(reasons, fmt.Sprintf("%s: %s", identifier, reason))
+	}
+
+	return nil, "", fmt.Errorf("%w (%s)", ErrServerNotFound, strings.Join(reasons, ", "))
+}
+
+func (l *Lookup) byUUID(ctx context.Context, uuid string) (*metalv1alpha1.Server, string, error) {
+	uuid = strings.ToLower(uuid)
+
+	if uuid == "" {
+		return nil, "not set", nil
+	}
+
+	if _, broken := brokenUUIDs[uuid]; broken {
+		return nil, fmt.Sprintf("%s is a placeholder UUID", uuid), nil
+	}
+
+	server, err := metalv1alpha1.LookupServer(ctx, l.Client, uuid)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Sprintf("server %s not found", uuid), nil
+		}
+
+		return nil, "", fmt.Errorf("failure fetching server %s: %w", uuid, err)
+	}
+
+	if !server.Status.InUse {
+		return nil, fmt.Sprintf("server %s is not allocated", uuid), nil
+	}
+
+	return server, "", nil
+}
+
+// ResolveExternal returns the external machine allowlisting the MAC address the request was sent from (neighbor table), or nil if there is none.
+func (l *Lookup) ResolveExternal(ctx context.Context, r *http.Request) (*metalv1alpha1.ExternalMachine, error) {
+	// the `mac` query parameter is not trusted, as the bootstrap data of the external machine is served to any request
+	hwAddr, _ := l.requestMAC("", r.RemoteAddr)
+	if hwAddr == nil {
+		return nil, nil
+	}
+
+	machine, err := metalv1alpha1.LookupExternalMachine(ctx, l.Client, hwAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failure looking up external machine: %w", err)
+	}
+
+	return machine, nil
+}
+
+// requestMAC returns the MAC address from the `mac` query parameter or the neighbor table, or the reason it is not known.
+func (l *Lookup) requestMAC(mac, remoteAddr string) (net.HardwareAddr, string) {
+	if mac != "" {
+		hwAddr, err := net.ParseMAC(mac)
+		if err != nil {
+			return nil, fmt.Sprintf("invalid MAC address %q", mac)
+		}
+
+		return hwAddr, ""
+	}
+
+	if l.Neighbors == nil {
+		return nil, "not set"
+	}
+
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Sprintf("invalid source address %q", remoteAddr)
+	}
+
+	hwAddr, err := l.Neighbors(ip)
+	if err != nil {
+		return nil, err.Error()
+	}
+
+	return hwAddr, ""
+}
+
+func (l *Lookup) byMAC(ctx context.Context, mac, remoteAddr string) (*metalv1alpha1.Server, string, error) {
+	hwAddr, reason := l.requestMAC(mac, remoteAddr)
+	if hwAddr == nil {
+		return nil, reason, nil
+	}
+
+	return l.match(ctx, "MAC address "+hwAddr.String(), func(server *metalv1alpha1.Server) bool {
+		if server.Status.Inventory == nil {
+			return false
+		}
+
+		for _, iface := range server.Status.Inventory.NetworkInterfaces {
+			if ifaceAddr, err := net.ParseMAC(iface.MAC); err == nil && ifaceAddr.String() == hwAddr.String() {
+				return true
+			}
+		}
+
+		return false
+	})
+}
+
+func (l *Lookup) bySerial(ctx context.Context, serial string) (*metalv1alpha1.Server, string, error) {
+	serial = strings.TrimSpace(serial)
+
+	if serial == "" {
+		return nil, "not set", nil
+	}
+
+	if _, broken := brokenSerials[strings.ToLower(serial)]; broken {
+		return nil, fmt.Sprintf("%q is a placeholder serial number", serial), nil
+	}
+
+	return l.match(ctx, "serial number "+serial, func(server *metalv1alpha1.Server) bool {
+		return server.Spec.SystemInformation != nil && strings.TrimSpace(server.Spec.SystemInformation.SerialNumber) == serial
+	})
+}
+
+// match returns the only allocated server matching the predicate.
+func (l *Lookup) match(ctx context.Context, what string, predicate func(*metalv1alpha1.Server) bool) (*metalv1alpha1.Server, string, error) {
+	var servers metalv1alpha1.ServerList
+
+	if err := l.Client.List(ctx, &servers); err != nil {
+		return nil, "", fmt.Errorf("failure listing servers: %w", err)
+	}
+
+	var matched []*metalv1alpha1.Server
+
+	for i := range servers.Items {
+		server := &servers.Items[i]
+
+		if server.Status.InUse && predicate(server) {
+			matched = append(matched, server)
+		}
+	}
+
+	switch len(matched) {
+	case 0:
+		return nil, fmt.Sprintf("no allocated server with %s", what), nil
+	case 1:
+		return matched[0], "", nil
+	default:
+		return nil, fmt.Sprintf("%d allocated servers with %s", len(matched), what), nil
+	}
+}
@@ -0,0 +1,258 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package metadata_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/metadata"
+)
+
+func TestParseIdentifiers(t *testing.T) {
+	t.Parallel()
+
+	identifiers, err := metadata.ParseIdentifiers(metadata.DefaultIdentifiers)
+	require.NoError(t, err)
+	assert.Equal(t, []metadata.Identifier{metadata.IdentifierUUID, metadata.IdentifierMAC, metadata.IdentifierSerial}, identifiers)
+
+	identifiers, err = metadata.ParseIdentifiers(" serial, uuid ")
+	require.NoError(t, err)
+	assert.Equal(t, []metadata.Identifier{metadata.IdentifierSerial, metadata.IdentifierUUID}, identifiers)
+
+	for _, s := range []string{"", "uuid,hostname", "uuid,uuid"} {
+		_, err = metadata.ParseIdentifiers(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestParseARPTable(t *testing.T) {
+	t.Parallel()
+
+	const table = `IP address       HW type     Flags       HW address            Mask     Device
+172.24.0.11      0x1         0x2         d0:50:99:d3:33:60     *        eth0
+172.24.0.12      0x1         0x0         00:00:00:00:00:00     *        eth0
+`
+
+	mac, err := metadata.ParseARPTable(strings.NewReader(table), net.ParseIP("172.24.0.11"))
+	require.NoError(t, err)
+	assert.Equal(t, "d0:50:99:d3:33:60", mac.String())
+
+	_, err = metadata.ParseARPTable(strings.NewReader(table), net.ParseIP("172.24.0.12"))
+	assert.EqualError(t, err, "no neighbor entry for 172.24.0.12")
+
+	_, err = metadata.ParseARPTable(strings.NewReader(table), net.ParseIP("172.24.0.13"))
+	assert.Error(t, err)
+}
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+
+	server := func(name, serial, mac string, inUse bool) *metalv1alpha1.Server {
+		return &metalv1alpha1.Server{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: metalv1alpha1.ServerSpec{
+				SystemInformation: &metalv1alpha1.SystemInformation{SerialNumber: serial},
+			},
+			Status: metalv1alpha1.ServerStatus{
+				InUse: inUse,
+				Inventory: &metalv1alpha1.HardwareInventory{
+					NetworkInterfaces: []metalv1alpha1.NetworkInterface{{Name: "eth0", MAC: mac}},
+				},
+			},
+		}
+	}
+
+	c := fake.NewFakeClientWithScheme(scheme,
+		server("4c4c4544-0036-4410-8052-b7c04f4e3532", "6DR0QR2", "d0:50:99:d3:33:60", true),
+		server("4c4c4544-0036-4410-8052-b7c04f4e3533", "6DR0QR3", "d0:50:99:d3:33:61", false),
+		// same serial number on the motherboards of two different machines
+		server("4c4c4544-0036-4410-8052-b7c04f4e3534", "DUPLICATE", "d0:50:99:d3:33:62", true),
+		server("4c4c4544-0036-4410-8052-b7c04f4e3535", "DUPLICATE", "d0:50:99:d3:33:63", true),
+	)
+
+	neighbors := func(ip net.IP) (net.HardwareAddr, error) {
+		if ip.Equal(net.ParseIP("172.24.0.11")) {
+			return net.ParseMAC("d0:50:99:d3:33:62")
+		}
+
+		return nil, errors.New("no neighbor entry")
+	}
+
+	for name, tc := range map[string]struct {
+		identifiers string
+		query       string
+		remoteAddr  string
+
+		expectedServer     string
+		expectedIdentifier metadata.Identifier
+		expectedError      string
+	}{
+		"uuid": {
+			query:              "uuid=4C4C4544-0036-4410-8052-B7C04F4E3532",
+			expectedServer:     "4c4c4544-0036-4410-8052-b7c04f4e3532",
+			expectedIdentifier: metadata.IdentifierUUID,
+		},
+		"placeholder uuid": {
+			query:              "uuid=03000200-0400-0500-0006-000700080009&serial=6DR0QR2",
+			expectedServer:     "4c4c4544-0036-4410-8052-b7c04f4e3532",
+			expectedIdentifier: metadata.IdentifierSerial,
+		},
+		"not allocated": {
+			query:         "uuid=4c4c4544-0036-4410-8052-b7c04f4e3533&serial=6DR0QR3",
+			expectedError: "uuid: server 4c4c4544-0036-4410-8052-b7c04f4e3533 is not allocated, mac: no neighbor entry, serial: no allocated server with serial number 6DR0QR3",
+		},
+		"mac parameter": {
+			query:              "uuid=00000000-0000-0000-0000-000000000000&mac=D0-50-99-D3-33-63",
+			expectedServer:     "4c4c4544-0036-4410-8052-b7c04f4e3535",
+			expectedIdentifier: metadata.IdentifierMAC,
+		},
+		"source mac": {
+			query:              "uuid=&serial=DUPLICATE",
+			remoteAddr:         "172.24.0.11:43210",
+			expectedServer:     "4c4c4544-0036-4410-8052-b7c04f4e3534",
+			expectedIdentifier: metadata.IdentifierMAC,
+		},
+		"duplicate serial": {
+			identifiers:   "serial",
+			query:         "serial=DUPLICATE",
+			expectedError: "serial: 2 allocated servers with serial number DUPLICATE",
+		},
+		"placeholder serial": {
+			identifiers:   "serial,uuid",
+			query:         "serial=To%20Be%20Filled%20By%20O.E.M.",
+			expectedError: `serial: "To Be Filled By O.E.M." is a placeholder serial number, uuid: not set`,
+		},
+		"order": {
+			identifiers:        "serial,uuid",
+			query:              "uuid=4c4c4544-0036-4410-8052-b7c04f4e3532&serial=DUPLICATE",
+			expectedServer:     "4c4c4544-0036-4410-8052-b7c04f4e3532",
+			expectedIdentifier: metadata.IdentifierUUID,
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			if tc.identifiers == "" {
+				tc.identifiers = metadata.DefaultIdentifiers
+			}
+
+			identifiers, err := metadata.ParseIdentifiers(tc.identifiers)
+			require.NoError(t, err)
+
+			lookup := &metadata.Lookup{
+				Client:      c,
+				Identifiers: identifiers,
+				Neighbors:   neighbors,
+			}
+
+			req := httptest.NewRequest("GET", "/configdata?"+tc.query, nil)
+			if tc.remoteAddr != "" {
+				req.RemoteAddr = tc.remoteAddr
+			}
+
+			server, identifier, err := lookup.Resolve(context.Background(), req)
+
+			if tc.expectedError != "" {
+				require.Error(t, err)
+				assert.True(t, errors.Is(err, metadata.ErrServerNotFound))
+				assert.Contains(t, err.Error(), tc.expectedError)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedServer, server.Name)
+			assert.Equal(t, tc.expectedIdentifier, identifier)
+		})
+	}
+}
+
+func TestResolveExternal(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+
+	c := fake.NewFakeClientWithScheme(scheme,
+		&metalv1alpha1.ExternalMachine{
+			ObjectMeta: metav1.ObjectMeta{Name: "lab-vm-1"},
+			Spec:       metalv1alpha1.ExternalMachineSpec{MACs: []string{"52:54:00:12:34:56"}},
+		},
+	)
+
+	lookup := &metadata.Lookup{
+		Client: c,
+		Neighbors: func(ip net.IP) (net.HardwareAddr, error) {
+			if ip.Equal(net.ParseIP("172.24.0.21")) {
+				return net.ParseMAC("52:54:00:12:34:56")
+			}
+
+			return nil, errors.New("no neighbor entry")
+		},
+	}
+
+	for name, tc := range map[string]struct {
+		query      string
+		remoteAddr string
+		expected   string
+	}{
+		"mac parameter": {
+			query:      "mac=52-54-00-12-34-56",
+			remoteAddr: "172.24.0.22:43210",
+		},
+		"mac parameter with source mac": {
+			query:      "mac=52:54:00:12:34:57",
+			remoteAddr: "172.24.0.21:43210",
+			expected:   "lab-vm-1",
+		},
+		"source mac": {
+			remoteAddr: "172.24.0.21:43210",
+			expected:   "lab-vm-1",
+		},
+		"unknown": {
+			remoteAddr: "172.24.0.22:43210",
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest("GET", "/configdata?"+tc.query, nil)
+			if tc.remoteAddr != "" {
+				req.RemoteAddr = tc.remoteAddr
+			}
+
+			machine, err := lookup.ResolveExternal(context.Background(), req)
+			require.NoError(t, err)
+
+			if tc.expected == "" {
+				assert.Nil(t, machine)
+
+				return
+			}
+
+			require.NotNil(t, machine)
+			assert.Equal(t, tc.expected, machine.Name)
+		})
+	}
+}
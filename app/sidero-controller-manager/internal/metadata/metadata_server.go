@@ -6,15 +6,12 @@ package metadata
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"time"
 
-	jsonpatch "github.com/evanphx/json-patch"
-	"github.com/ghodss/yaml"
-	"github.com/talos-systems/talos/pkg/machinery/config/configloader"
-	"github.com/talos-systems/talos/pkg/machinery/config/types/v1alpha1"
 	v1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/types"
@@ -23,6 +20,8 @@ import (
 
 	"github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
 	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/cosign"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/render"
 )
 
 type errorWithCode struct {
@@ -31,7 +30,11 @@ type errorWithCode struct {
 }
 
 type metadataConfigs struct {
-	client runtimeclient.Client
+	client           runtimeclient.Client
+	lookup           *Lookup
+	externalMachines bool
+	verifier         *cosign.Verifier
+	namespaces       render.RefNamespaces
 }
 
 func throwError(w http.ResponseWriter, ewc errorWithCode) {
@@ -39,12 +42,24 @@ func throwError(w http.ResponseWriter, ewc errorWithCode) {
 	log.Println(ewc.errorObj)
 }
 
-func RegisterServer(mux *http.ServeMux, k8sClient runtimeclient.Client) error {
+// RegisterServer registers the metadata server resolving the servers by the identifiers in order.
+//
+// If externalMachines is set, the machines allowlisted by the ExternalMachine resources are served as well.
+func RegisterServer(mux *http.ServeMux, k8sClient runtimeclient.Client, identifiers []Identifier, externalMachines bool, namespaces render.RefNamespaces) error {
 	mm := metadataConfigs{
 		client: k8sClient,
+		lookup: &Lookup{
+			Client:      k8sClient,
+			Identifiers: identifiers,
+			Neighbors:   NeighborTable,
+		},
+		externalMachines: externalMachines,
+		verifier:         &cosign.Verifier{},
+		namespaces:       namespaces,
 	}
 
 	mux.HandleFunc("/configdata", mm.FetchConfig)
+	mux.HandleFunc(ProvenancePathPrefix, mm.FetchProvenance)
 
 	return nil
 }
@@ -53,26 +68,58 @@ func (m *metadataConfigs) FetchConfig(w http.ResponseWriter, r *http.Request) {
 	// Parse info out of incoming request
 	ctx := r.Context()
 
-	vals := r.URL.Query()
+	log.Printf("received metadata request from %s: %s", r.RemoteAddr, r.URL.RawQuery)
+
+	if m.externalMachines {
+		external, err := m.lookup.ResolveExternal(ctx, r)
+		if err != nil {
+			throwError(
+				w,
+				errorWithCode{
+					http.StatusInternalServerError,
+					err,
+				},
+			)
+
+			return
+		}
+
+		if external != nil {
+			m.fetchExternalConfig(ctx, w, external)
+
+			return
+		}
+	}
 
-	uuid := vals.Get("uuid")
+	// Resolve the server by the identifiers in the request, falling back to the next identifier.
+	serverObj, identifier, err := m.lookup.Resolve(ctx, r)
+	if err != nil {
+		code := http.StatusInternalServerError
+		if errors.Is(err, ErrServerNotFound) {
+			code = http.StatusNotFound
+		}
 
-	// Throw out requests with no uuid param
-	if len(uuid) == 0 {
 		throwError(
 			w,
 			errorWithCode{
-				http.StatusInternalServerError,
-				fmt.Errorf(
-					"received metadata request with empty uuid",
-				),
+				code,
+				err,
 			},
 		)
 
 		return
 	}
 
-	log.Printf("received metadata request for uuid: %s", uuid)
+	uuid := serverObj.Name
+
+	log.Printf("resolved metadata request to server %s by %s", uuid, identifier)
+
+	// Servers allocated to the canary run are served the scratch configuration of the Canary.
+	if serverObj.CanaryInstallRequested() {
+		m.fetchCanaryConfig(ctx, w, serverObj)
+
+		return
+	}
 
 	// Find serverBinding and metalMachine by server UUID.
 	metalMachine, serverBinding, ewc := m.findMetalMachineServerBinding(ctx, uuid)
@@ -123,7 +170,7 @@ func (m *metadataConfigs) FetchConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	decodedData, ewc := m.fetchBootstrapSecret(
+	bootstrapData, bootstrapResourceVersion, ewc := m.fetchBootstrapSecret(
 		ctx,
 		types.NamespacedName{
 			Name:      *bootstrapSecretName,
@@ -139,29 +186,12 @@ func (m *metadataConfigs) FetchConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Get the server resource by the UUID that was passed in.
-	// We do this to fetch serverclass and any configPatches in the server resource that we need to handle.
-	serverObj := &metalv1alpha1.Server{}
-
-	err = m.client.Get(
-		ctx,
-		types.NamespacedName{
-			Namespace: "",
-			Name:      uuid,
-		},
-		serverObj,
-	)
-	if err != nil {
+	// Configure static addresses claimed from IPAM pools, before patches so that they can be adjusted.
+	decodedData, ewc := staticNetwork(bootstrapData, &metalMachine)
+	if ewc.errorObj != nil {
 		throwError(
 			w,
-			errorWithCode{
-				http.StatusInternalServerError,
-				fmt.Errorf(
-					"failure fetching server %s: %s",
-					uuid,
-					err,
-				),
-			},
+			ewc,
 		)
 
 		return
@@ -169,9 +199,11 @@ func (m *metadataConfigs) FetchConfig(w http.ResponseWriter, r *http.Request) {
 
 	// Given a server object, see if it came from a serverclass (it will have an ownerref)
 	// If so, fetch the serverclass so we can use configPatches from it.
-	serverClassObj := &metalv1alpha1.ServerClass{}
+	var serverClassObj *metalv1alpha1.ServerClass
 
 	if serverBinding.Spec.ServerClassRef != nil {
+		serverClassObj = &metalv1alpha1.ServerClass{}
+
 		err = m.client.Get(
 			ctx,
 			types.NamespacedName{
@@ -197,23 +229,33 @@ func (m *metadataConfigs) FetchConfig(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	// Handle patches added to serverclass object
-	if serverClassObj != nil && len(serverClassObj.Spec.ConfigPatches) > 0 {
-		decodedData, ewc = patchConfigs(decodedData, serverClassObj.Spec.ConfigPatches)
-		if ewc.errorObj != nil {
-			throwError(
-				w,
-				ewc,
-			)
+	env, ewc := m.fetchEnvironment(ctx, serverObj, &serverBinding, serverClassObj)
+	if ewc.errorObj != nil {
+		throwError(
+			w,
+			ewc,
+		)
 
-			return
-		}
+		return
+	}
+
+	// Handle patches added to environment, serverclass, metalmachine install disk, server and serverbinding objects (in that order),
+	// then set the pinned install image of the server or the serverclass.
+	// Referenced ConfigMaps and Secrets are fetched on every request, so that changes are picked up
+	// on the next config fetch.
+	decodedData, ewc = patchConfigs(decodedData, render.Layers(env, serverClassObj, serverObj, &serverBinding), m.source(ctx))
+	if ewc.errorObj != nil {
+		throwError(
+			w,
+			ewc,
+		)
+
+		return
 	}
 
-	// Handle patches added to server object
-	if len(serverObj.Spec.ConfigPatches) > 0 {
-		decodedData, ewc = patchConfigs(decodedData, serverObj.Spec.ConfigPatches)
-		if ewc.errorObj != nil {
+	// Verify the signature of the pinned install image, the config is not served until the image is verified.
+	if installImage := render.InstallImageOverride(serverClassObj, serverObj); installImage != nil && installImage.Verify != nil {
+		if ewc = m.verifyInstallImage(ctx, installImage); ewc.errorObj != nil {
 			throwError(
 				w,
 				ewc,
@@ -235,6 +277,19 @@ func (m *metadataConfigs) FetchConfig(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Record what the server is provisioned with, failures don't block the provisioning.
+	provenance, err := BuildProvenance(serverBinding.Status.Provenance, env, &v1.ObjectReference{
+		Kind:            "Secret",
+		Namespace:       ownerMachine.Namespace,
+		Name:            *bootstrapSecretName,
+		ResourceVersion: bootstrapResourceVersion,
+	}, bootstrapData, decodedData, time.Now())
+	if err != nil {
+		log.Printf("failed to build provenance for %q: %v", uuid, err)
+	} else if err = m.recordProvenance(ctx, &serverBinding, provenance); err != nil {
+		log.Printf("failed to record provenance for %q: %v", uuid, err)
+	}
+
 	// Finally return config data
 	if _, err = w.Write(decodedData); err != nil {
 		log.Printf("failed to write data: %v", err)
@@ -244,31 +299,46 @@ func (m *metadataConfigs) FetchConfig(w http.ResponseWriter, r *http.Request) {
 	log.Printf("successfully returned metadata for %q", uuid)
 }
 
-// patchConfigs is responsible for applying a set of configPatches to the bootstrap data.
-func patchConfigs(decodedData []byte, patches []metalv1alpha1.ConfigPatches) ([]byte, errorWithCode) {
-	marshalledPatches, err := json.Marshal(patches)
+// patchConfigs is responsible for applying the layers of configPatches to the bootstrap data.
+func patchConfigs(decodedData []byte, layers []render.Layer, source render.PatchSource) ([]byte, errorWithCode) {
+	decodedData, err := render.ApplyLayers(decodedData, layers, source)
 	if err != nil {
-		return nil, errorWithCode{http.StatusInternalServerError, fmt.Errorf("failure marshaling config patches from server: %s", err)}
+		return nil, errorWithCode{http.StatusInternalServerError, err}
 	}
 
-	jsonDecodedData, err := yaml.YAMLToJSON(decodedData)
+	return decodedData, errorWithCode{}
+}
+
+// verifyInstallImage verifies the cosign signature of the install image with the referenced public key.
+func (m *metadataConfigs) verifyInstallImage(ctx context.Context, installImage *metalv1alpha1.InstallImage) errorWithCode {
+	keyRef := installImage.Verify.PublicKeyFrom
+
+	publicKey, err := m.source(ctx).Fetch(metalv1alpha1.ConfigPatchesRef{
+		Kind:      keyRef.Kind,
+		Namespace: keyRef.Namespace,
+		Name:      keyRef.Name,
+		Key:       keyRef.Key,
+	})
 	if err != nil {
-		return nil, errorWithCode{http.StatusInternalServerError, fmt.Errorf("failure converting bootstrap data to json: %s", err)}
+		return errorWithCode{http.StatusInternalServerError, fmt.Errorf("failure fetching public key of install image %q: %s", installImage.Reference(), err)}
 	}
 
-	patch, err := jsonpatch.DecodePatch(marshalledPatches)
-	if err != nil {
-		return nil, errorWithCode{http.StatusInternalServerError, fmt.Errorf("failure decoding config patches from server to rfc6902 patch: %s", err)}
+	if err = m.verifier.Verify(ctx, installImage.Reference(), publicKey); err != nil {
+		return errorWithCode{http.StatusInternalServerError, fmt.Errorf("failure verifying install image %q: %s", installImage.Reference(), err)}
 	}
 
-	jsonDecodedData, err = patch.Apply(jsonDecodedData)
-	if err != nil {
-		return nil, errorWithCode{http.StatusInternalServerError, fmt.Errorf("failure applying rfc6902 patches to machine config: %s", err)}
+	return errorWithCode{}
+}
+
+// staticNetwork is responsible for configuring the interfaces with the static addresses of the metal machine.
+func staticNetwork(decodedData []byte, metalMachine *v1alpha3.MetalMachine) ([]byte, errorWithCode) {
+	if !metalMachine.StaticAddressesAssigned() {
+		return nil, errorWithCode{http.StatusNotFound, fmt.Errorf("static addresses are not assigned yet for metalmachine %s/%s", metalMachine.Namespace, metalMachine.Name)}
 	}
 
-	decodedData, err = yaml.JSONToYAML(jsonDecodedData)
+	decodedData, err := render.StaticNetwork(decodedData, metalMachine)
 	if err != nil {
-		return nil, errorWithCode{http.StatusInternalServerError, fmt.Errorf("failure converting bootstrap data from json to yaml: %s", err)}
+		return nil, errorWithCode{http.StatusInternalServerError, err}
 	}
 
 	return decodedData, errorWithCode{}
@@ -277,46 +347,12 @@ func patchConfigs(decodedData []byte, patches []metalv1alpha1.ConfigPatches) ([]
 // labelNodes is responsible for editing the kubelet extra args such that a given
 // server gets registered with a label containing the UUID of the server resource it's actually running on.
 func labelNodes(decodedData []byte, serverName string) ([]byte, errorWithCode) {
-	configProvider, err := configloader.NewFromBytes(decodedData)
+	decodedData, err := render.LabelNode(decodedData, serverName)
 	if err != nil {
-		return nil, errorWithCode{http.StatusInternalServerError, fmt.Errorf("failure creating config struct: %s", err)}
+		return nil, errorWithCode{http.StatusInternalServerError, err}
 	}
 
-	switch configProvider.Version() {
-	case "v1alpha1":
-		config, ok := configProvider.(*v1alpha1.Config)
-		if !ok {
-			return nil, errorWithCode{http.StatusInternalServerError, fmt.Errorf("unable to case config")}
-		}
-
-		patch := metalv1alpha1.ConfigPatches{
-			Path: "/machine/kubelet/extraArgs",
-			Op:   "replace",
-		}
-
-		kubeletExtraArgs := config.MachineConfig.MachineKubelet.KubeletExtraArgs
-		if kubeletExtraArgs == nil {
-			patch.Op = "add"
-			kubeletExtraArgs = make(map[string]string)
-		}
-
-		if _, ok = kubeletExtraArgs["node-labels"]; ok {
-			kubeletExtraArgs["node-labels"] += fmt.Sprintf(",metal.sidero.dev/uuid=%s", serverName)
-		} else {
-			kubeletExtraArgs["node-labels"] = fmt.Sprintf("metal.sidero.dev/uuid=%s", serverName)
-		}
-
-		value, err := json.Marshal(kubeletExtraArgs)
-		if err != nil {
-			return nil, errorWithCode{http.StatusInternalServerError, fmt.Errorf("failure marshaling kubelet.extraArgs: %s", err)}
-		}
-
-		patch.Value.Raw = value
-
-		return patchConfigs(decodedData, []metalv1alpha1.ConfigPatches{patch})
-	default:
-		return nil, errorWithCode{http.StatusInternalServerError, fmt.Errorf("unknown config type")}
-	}
+	return decodedData, errorWithCode{}
 }
 
 // findMetalMachineServerBinding is responsible for looking up ServerBinding and MetalMachine.
@@ -346,7 +382,7 @@ func (m *metadataConfigs) findMetalMachineServerBinding(ctx context.Context, ser
 }
 
 // fetchBootstrapSecret is responsible for fetching a secret that contains the bootstrap data created by our bootstrap provider.
-func (m *metadataConfigs) fetchBootstrapSecret(ctx context.Context, secretNSN types.NamespacedName) ([]byte, errorWithCode) {
+func (m *metadataConfigs) fetchBootstrapSecret(ctx context.Context, secretNSN types.NamespacedName) ([]byte, string, errorWithCode) {
 	bootstrapSecretData := &v1.Secret{}
 
 	err := m.client.Get(
@@ -356,15 +392,96 @@ func (m *metadataConfigs) fetchBootstrapSecret(ctx context.Context, secretNSN ty
 	)
 	if err != nil {
 		if apierrors.IsNotFound(err) {
-			return nil, errorWithCode{http.StatusNotFound, fmt.Errorf("bootstrap secret %s/%s not found", secretNSN.Namespace, secretNSN.Name)}
+			return nil, "", errorWithCode{http.StatusNotFound, fmt.Errorf("bootstrap secret %s/%s not found", secretNSN.Namespace, secretNSN.Name)}
 		}
 
-		return nil, errorWithCode{http.StatusInternalServerError, fmt.Errorf("failure fetching bootstrap secret data from secret %s/%s: %s", secretNSN.Namespace, secretNSN.Name, err)}
+		return nil, "", errorWithCode{http.StatusInternalServerError, fmt.Errorf("failure fetching bootstrap secret data from secret %s/%s: %s", secretNSN.Namespace, secretNSN.Name, err)}
 	}
 
 	if _, ok := bootstrapSecretData.Data["value"]; !ok {
-		return nil, errorWithCode{http.StatusNotFound, fmt.Errorf("value key not found in bootstrap data: %s/%s", secretNSN.Namespace, secretNSN.Name)}
+		return nil, "", errorWithCode{http.StatusNotFound, fmt.Errorf("value key not found in bootstrap data: %s/%s", secretNSN.Namespace, secretNSN.Name)}
 	}
 
-	return bootstrapSecretData.Data["value"], errorWithCode{}
+	return bootstrapSecretData.Data["value"], bootstrapSecretData.ResourceVersion, errorWithCode{}
+}
+
+// fetchEnvironment is responsible for looking up the environment of the server.
+//
+// Environment is picked from the server, then from the serverbinding (MetalMachine override), then from the serverclass,
+// then the default one.
+// Missing environment means there are no environment config patches.
+func (m *metadataConfigs) fetchEnvironment(ctx context.Context, server *metalv1alpha1.Server, serverBinding *v1alpha3.ServerBinding, serverClass *metalv1alpha1.ServerClass) (*metalv1alpha1.Environment, errorWithCode) {
+	name := metalv1alpha1.EnvironmentDefault
+
+	switch {
+	case server.Spec.EnvironmentRef != nil:
+		name = server.Spec.EnvironmentRef.Name
+	case serverBinding.Spec.EnvironmentRef != nil:
+		name = serverBinding.Spec.EnvironmentRef.Name
+	case serverClass != nil && serverClass.Spec.EnvironmentRef != nil:
+		name = serverClass.Spec.EnvironmentRef.Name
+	}
+
+	return m.fetchEnvironmentByName(ctx, name)
+}
+
+// fetchEnvironmentByName returns the environment, nil if it doesn't exist.
+func (m *metadataConfigs) fetchEnvironmentByName(ctx context.Context, name string) (*metalv1alpha1.Environment, errorWithCode) {
+	var env metalv1alpha1.Environment
+
+	if err := m.client.Get(ctx, types.NamespacedName{Name: name}, &env); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, errorWithCode{}
+		}
+
+		return nil, errorWithCode{http.StatusInternalServerError, fmt.Errorf("failure fetching environment %s: %s", name, err)}
+	}
+
+	return &env, errorWithCode{}
+}
+
+// source returns the source resolving the references.
+func (m *metadataConfigs) source(ctx context.Context) *clientSource {
+	return &clientSource{ctx: ctx, client: m.client, namespaces: m.namespaces}
+}
+
+// clientSource resolves config patches references with the Kubernetes client.
+//
+// References are restricted to the allowed namespaces.
+type clientSource struct {
+	ctx        context.Context
+	client     runtimeclient.Client
+	namespaces render.RefNamespaces
+}
+
+func (s *clientSource) Fetch(ref metalv1alpha1.ConfigPatchesRef) ([]byte, error) {
+	if err := s.namespaces.Check(ref); err != nil {
+		return nil, err
+	}
+
+	key := types.NamespacedName{
+		Namespace: render.RefNamespace(ref),
+		Name:      ref.Name,
+	}
+
+	switch ref.Kind {
+	case "ConfigMap":
+		var cm v1.ConfigMap
+
+		if err := s.client.Get(s.ctx, key, &cm); err != nil {
+			return nil, err
+		}
+
+		return render.ConfigMapKey(&cm, ref.Key)
+	case "Secret":
+		var secret v1.Secret
+
+		if err := s.client.Get(s.ctx, key, &secret); err != nil {
+			return nil, err
+		}
+
+		return render.SecretKey(&secret, ref.Key)
+	default:
+		return nil, fmt.Errorf("unsupported kind %q", ref.Kind)
+	}
 }
@@ -0,0 +1,280 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package metadata
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/util/patch"
+
+	"github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/render"
+)
+
+// ProvenancePathPrefix is the HTTP path prefix of the provenance attestations, followed by the server name.
+const ProvenancePathPrefix = "/provenance/"
+
+// In-toto statement and SLSA provenance predicate types.
+const (
+	StatementType           = "https://in-toto.io/Statement/v0.1"
+	ProvenancePredicateType = "https://slsa.dev/provenance/v0.2"
+	ProvisioningBuildType   = "https://sidero.dev/provisioning/v1alpha1"
+	BuilderID               = "https://sidero.dev/sidero-controller-manager"
+)
+
+// BuildProvenance returns the provenance of the machine configuration served to the server.
+//
+// The kernel and initramfs checksums are taken from the environment status, as downloaded by Sidero.
+// The config generation is carried over from the previous provenance, and bumped if the configuration changed.
+// The credentials fingerprint is taken from the bootstrap data, so that it can be compared with the bootstrap data
+// once the credentials are rotated.
+func BuildProvenance(previous *v1alpha3.Provenance, env *metalv1alpha1.Environment, secretRef *corev1.ObjectReference, bootstrapData, config []byte, now time.Time) (*v1alpha3.Provenance, error) {
+	sum := sha256.Sum256(config)
+
+	provenance := &v1alpha3.Provenance{
+		BootstrapDataSecretRef: secretRef,
+		ConfigSHA256:           hex.EncodeToString(sum[:]),
+		ConfigGeneration:       1,
+		ConfigServedAt:         metav1.NewTime(now),
+	}
+
+	if previous != nil {
+		provenance.ConfigGeneration = previous.ConfigGeneration
+
+		if previous.ConfigSHA256 != provenance.ConfigSHA256 {
+			provenance.ConfigGeneration++
+		}
+	}
+
+	if env != nil {
+		provenance.Environment = env.Name
+		provenance.Kernel = downloadedAsset(env, env.Spec.KernelAsset())
+		provenance.Initrd = downloadedAsset(env, env.Spec.InitrdAsset())
+	}
+
+	installImage, err := render.InstallImage(config)
+	if err != nil {
+		return nil, err
+	}
+
+	provenance.InstallImage = installImage
+
+	if provenance.CredentialsSHA256, err = render.CredentialsFingerprint(bootstrapData); err != nil {
+		return nil, err
+	}
+
+	// the digest can't be resolved for tags without pulling the image
+	if idx := strings.LastIndex(installImage, "@"); idx != -1 {
+		provenance.InstallImageDigest = installImage[idx+1:]
+	}
+
+	return provenance, nil
+}
+
+// downloadedAsset returns the asset with the checksum of the downloaded file.
+func downloadedAsset(env *metalv1alpha1.Environment, asset metalv1alpha1.Asset) metalv1alpha1.Asset {
+	for _, condition := range env.Status.Conditions {
+		if condition.Type == "Ready" && condition.Status == "True" && condition.URL == asset.URL && condition.SHA512 != "" {
+			asset.SHA512 = condition.SHA512
+		}
+	}
+
+	return asset
+}
+
+// recordProvenance updates the provenance in the server binding status.
+func (m *metadataConfigs) recordProvenance(ctx context.Context, serverBinding *v1alpha3.ServerBinding, provenance *v1alpha3.Provenance) error {
+	patchHelper, err := patch.NewHelper(serverBinding, m.client)
+	if err != nil {
+		return err
+	}
+
+	serverBinding.Status.Provenance = provenance
+
+	return patchHelper.Patch(ctx, serverBinding)
+}
+
+// Statement is the in-toto attestation statement.
+type Statement struct {
+	Type          string              `json:"_type"`
+	Subject       []Subject           `json:"subject"`
+	PredicateType string              `json:"predicateType"`
+	Predicate     ProvenancePredicate `json:"predicate"`
+}
+
+// Subject is the artifact the statement is about.
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// ProvenancePredicate is the SLSA provenance predicate.
+type ProvenancePredicate struct {
+	Builder    Builder    `json:"builder"`
+	BuildType  string     `json:"buildType"`
+	Invocation Invocation `json:"invocation"`
+	Metadata   Metadata   `json:"metadata"`
+	Materials  []Material `json:"materials"`
+}
+
+// Builder of the artifact, always Sidero.
+type Builder struct {
+	ID string `json:"id"`
+}
+
+// Invocation records the resources the server was provisioned from.
+type Invocation struct {
+	Parameters map[string]string `json:"parameters"`
+}
+
+// Metadata of the provisioning.
+type Metadata struct {
+	BuildFinishedOn string `json:"buildFinishedOn"`
+}
+
+// Material is the artifact used to provision the server.
+type Material struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// BuildStatement returns the in-toto statement for the provenance recorded in the server binding.
+//
+// The subject is the machine configuration served to the server, the materials are the boot assets and the installer image.
+func BuildStatement(serverBinding *v1alpha3.ServerBinding) (*Statement, error) {
+	provenance := serverBinding.Status.Provenance
+	if provenance == nil {
+		return nil, fmt.Errorf("no provenance recorded for server %s", serverBinding.Name)
+	}
+
+	parameters := map[string]string{
+		"server":       serverBinding.Name,
+		"metalMachine": serverBinding.Spec.MetalMachineRef.Namespace + "/" + serverBinding.Spec.MetalMachineRef.Name,
+	}
+
+	if provenance.Environment != "" {
+		parameters["environment"] = provenance.Environment
+	}
+
+	if ref := provenance.BootstrapDataSecretRef; ref != nil {
+		parameters["bootstrapDataSecret"] = ref.Namespace + "/" + ref.Name
+		parameters["bootstrapDataSecretResourceVersion"] = ref.ResourceVersion
+	}
+
+	parameters["configGeneration"] = fmt.Sprintf("%d", provenance.ConfigGeneration)
+
+	var materials []Material
+
+	for _, asset := range []metalv1alpha1.Asset{provenance.Kernel, provenance.Initrd} {
+		if asset.URL == "" {
+			continue
+		}
+
+		material := Material{URI: asset.URL}
+
+		if asset.SHA512 != "" {
+			material.Digest = map[string]string{"sha512": asset.SHA512}
+		}
+
+		materials = append(materials, material)
+	}
+
+	if provenance.InstallImage != "" {
+		material := Material{URI: "docker://" + provenance.InstallImage}
+
+		if algorithm, digest, ok := splitDigest(provenance.InstallImageDigest); ok {
+			material.Digest = map[string]string{algorithm: digest}
+		}
+
+		materials = append(materials, material)
+	}
+
+	return &Statement{
+		Type: StatementType,
+		Subject: []Subject{
+			{
+				Name:   "machineconfig/" + serverBinding.Name,
+				Digest: map[string]string{"sha256": provenance.ConfigSHA256},
+			},
+		},
+		PredicateType: ProvenancePredicateType,
+		Predicate: ProvenancePredicate{
+			Builder:   Builder{ID: BuilderID},
+			BuildType: ProvisioningBuildType,
+			Invocation: Invocation{
+				Parameters: parameters,
+			},
+			Metadata: Metadata{
+				BuildFinishedOn: provenance.ConfigServedAt.UTC().Format(time.RFC3339),
+			},
+			Materials: materials,
+		},
+	}, nil
+}
+
+func splitDigest(digest string) (string, string, bool) {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
+
+// FetchProvenance serves the in-toto provenance statement for the server.
+//
+// `GET /provenance/<server>` returns the statement for the machine configuration last served to the server.
+func (m *metadataConfigs) FetchProvenance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	name := strings.TrimPrefix(r.URL.Path, ProvenancePathPrefix)
+	if name == "" || strings.Contains(name, "/") {
+		http.Error(w, "invalid server name", http.StatusBadRequest)
+
+		return
+	}
+
+	var serverBinding v1alpha3.ServerBinding
+
+	if err := m.client.Get(r.Context(), types.NamespacedName{Name: name}, &serverBinding); err != nil {
+		if apierrors.IsNotFound(err) {
+			http.Error(w, "server is not allocated", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	statement, err := BuildStatement(&serverBinding)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/vnd.in-toto+json")
+
+	if err = json.NewEncoder(w).Encode(statement); err != nil {
+		log.Printf("failed to write provenance: %v", err)
+	}
+}
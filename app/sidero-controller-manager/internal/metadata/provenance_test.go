@@ -0,0 +1,146 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package metadata_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/metadata"
+)
+
+const provenanceConfig = `version: v1alpha1
+machine:
+  type: join
+  install:
+    image: ghcr.io/talos-systems/installer:v0.11.5@sha256:0cc0c5f2d4e0fb1a5e2b6d3c1c7f1c0f6a8f4f0e1f5a2a7d8b9c0d1e2f3a4b5c
+`
+
+func TestBuildProvenance(t *testing.T) {
+	t.Parallel()
+
+	env := &metalv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "default",
+		},
+		Spec: metalv1alpha1.EnvironmentSpec{
+			Kernel: metalv1alpha1.Kernel{
+				Asset: metalv1alpha1.Asset{URL: "http://example.com/vmlinuz"},
+			},
+			Initrd: metalv1alpha1.Initrd{
+				Asset: metalv1alpha1.Asset{URL: "http://example.com/initramfs.xz"},
+			},
+		},
+		Status: metalv1alpha1.EnvironmentStatus{
+			Conditions: []metalv1alpha1.AssetCondition{
+				{
+					Asset:  metalv1alpha1.Asset{URL: "http://example.com/vmlinuz", SHA512: "aaaa"},
+					Status: "True",
+					Type:   "Ready",
+				},
+				{
+					Asset:  metalv1alpha1.Asset{URL: "http://example.com/initramfs.xz", SHA512: "bbbb"},
+					Status: "False",
+					Type:   "Ready",
+				},
+			},
+		},
+	}
+
+	secretRef := &corev1.ObjectReference{Kind: "Secret", Namespace: "default", Name: "worker-0", ResourceVersion: "42"}
+	now := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	provenance, err := metadata.BuildProvenance(nil, env, secretRef, []byte(provenanceConfig), []byte(provenanceConfig), now)
+	require.NoError(t, err)
+
+	sum := sha256.Sum256([]byte(provenanceConfig))
+
+	assert.Equal(t, "default", provenance.Environment)
+	assert.Equal(t, metalv1alpha1.Asset{URL: "http://example.com/vmlinuz", SHA512: "aaaa"}, provenance.Kernel)
+	assert.Equal(t, metalv1alpha1.Asset{URL: "http://example.com/initramfs.xz"}, provenance.Initrd)
+	assert.Equal(t, "ghcr.io/talos-systems/installer:v0.11.5@sha256:0cc0c5f2d4e0fb1a5e2b6d3c1c7f1c0f6a8f4f0e1f5a2a7d8b9c0d1e2f3a4b5c", provenance.InstallImage)
+	assert.Equal(t, "sha256:0cc0c5f2d4e0fb1a5e2b6d3c1c7f1c0f6a8f4f0e1f5a2a7d8b9c0d1e2f3a4b5c", provenance.InstallImageDigest)
+	assert.Equal(t, secretRef, provenance.BootstrapDataSecretRef)
+	assert.Equal(t, hex.EncodeToString(sum[:]), provenance.ConfigSHA256)
+	assert.EqualValues(t, 1, provenance.ConfigGeneration)
+	assert.NotEmpty(t, provenance.CredentialsSHA256)
+
+	// same config served again
+	provenance, err = metadata.BuildProvenance(provenance, env, secretRef, []byte(provenanceConfig), []byte(provenanceConfig), now.Add(time.Minute))
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, provenance.ConfigGeneration)
+	assert.Equal(t, now.Add(time.Minute), provenance.ConfigServedAt.Time)
+
+	// config changed, the image is not pinned
+	provenance, err = metadata.BuildProvenance(provenance, nil, secretRef, []byte(provenanceConfig), []byte("version: v1alpha1\nmachine:\n  install:\n    image: ghcr.io/talos-systems/installer:v0.12.0\n"), now)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, provenance.ConfigGeneration)
+	assert.Empty(t, provenance.Environment)
+	assert.Equal(t, "ghcr.io/talos-systems/installer:v0.12.0", provenance.InstallImage)
+	assert.Empty(t, provenance.InstallImageDigest)
+
+	_, err = metadata.BuildProvenance(nil, env, secretRef, []byte(provenanceConfig), []byte("not a config"), now)
+	assert.Error(t, err)
+}
+
+func TestBuildStatement(t *testing.T) {
+	t.Parallel()
+
+	serverBinding := &v1alpha3.ServerBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "4c4c4544-0035-5410-8056-c7c04f4d4d32",
+		},
+		Spec: v1alpha3.ServerBindingSpec{
+			MetalMachineRef: corev1.ObjectReference{Namespace: "default", Name: "worker-0"},
+		},
+	}
+
+	_, err := metadata.BuildStatement(serverBinding)
+	assert.Error(t, err)
+
+	serverBinding.Status.Provenance = &v1alpha3.Provenance{
+		Environment:            "default",
+		Kernel:                 metalv1alpha1.Asset{URL: "http://example.com/vmlinuz", SHA512: "aaaa"},
+		Initrd:                 metalv1alpha1.Asset{URL: "http://example.com/initramfs.xz"},
+		InstallImage:           "ghcr.io/talos-systems/installer@sha256:cccc",
+		InstallImageDigest:     "sha256:cccc",
+		BootstrapDataSecretRef: &corev1.ObjectReference{Namespace: "default", Name: "worker-0", ResourceVersion: "42"},
+		ConfigSHA256:           "dddd",
+		ConfigGeneration:       3,
+		ConfigServedAt:         metav1.NewTime(time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)),
+	}
+
+	statement, err := metadata.BuildStatement(serverBinding)
+	require.NoError(t, err)
+
+	assert.Equal(t, metadata.StatementType, statement.Type)
+	assert.Equal(t, metadata.ProvenancePredicateType, statement.PredicateType)
+	assert.Equal(t, []metadata.Subject{
+		{Name: "machineconfig/4c4c4544-0035-5410-8056-c7c04f4d4d32", Digest: map[string]string{"sha256": "dddd"}},
+	}, statement.Subject)
+	assert.Equal(t, []metadata.Material{
+		{URI: "http://example.com/vmlinuz", Digest: map[string]string{"sha512": "aaaa"}},
+		{URI: "http://example.com/initramfs.xz"},
+		{URI: "docker://ghcr.io/talos-systems/installer@sha256:cccc", Digest: map[string]string{"sha256": "cccc"}},
+	}, statement.Predicate.Materials)
+	assert.Equal(t, map[string]string{
+		"server":                             "4c4c4544-0035-5410-8056-c7c04f4d4d32",
+		"metalMachine":                       "default/worker-0",
+		"environment":                        "default",
+		"bootstrapDataSecret":                "default/worker-0",
+		"bootstrapDataSecretResourceVersion": "42",
+		"configGeneration":                   "3",
+	}, statement.Predicate.Invocation.Parameters)
+	assert.Equal(t, "2021-09-01T12:00:00Z", statement.Predicate.Metadata.BuildFinishedOn)
+}
@@ -0,0 +1,183 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package metrics defines Sidero-specific Prometheus metrics.
+//
+// Metrics are registered with the controller-runtime registry, so they are exposed
+// on the manager metrics endpoint (--metrics-addr).
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// Server states in the ServerClass.
+const (
+	StateFree      = "free"
+	StateAllocated = "allocated"
+	StateInUse     = "in-use"
+)
+
+// Boot request results.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+var (
+	// ServerClassServers is the number of servers matching the ServerClass by state.
+	ServerClassServers = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sidero_serverclass_servers",
+			Help: "Number of servers matching the ServerClass by state (free, allocated, in-use).",
+		},
+		[]string{"serverclass", "state"},
+	)
+
+	// BootRequests is the number of PXE boot requests served.
+	BootRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sidero_boot_requests_total",
+			Help: "Number of PXE boot requests (iPXE script, environment assets, TFTP) by result.",
+		},
+		[]string{"type", "result"},
+	)
+
+	// HTTPRequests is the number of requests served on the public HTTP endpoint (iPXE, metadata, environment assets).
+	HTTPRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sidero_http_requests_total",
+			Help: "Number of requests served on the public HTTP endpoint by handler and status code.",
+		},
+		[]string{"handler", "code"},
+	)
+
+	// PowerOperations is the number of power management operations.
+	PowerOperations = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sidero_power_operations_total",
+			Help: "Number of power management operations by management interface.",
+		},
+		[]string{"interface", "operation"},
+	)
+
+	// PowerOperationErrors is the number of failed power management operations.
+	PowerOperationErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sidero_power_operation_errors_total",
+			Help: "Number of failed power management operations by management interface.",
+		},
+		[]string{"interface", "operation"},
+	)
+
+	// WipeDuration is the time it takes the agent to wipe the server disks.
+	WipeDuration = prometheus.NewHistogram(
+		prometheus.HistogramOpts{
+			Name:    "sidero_agent_wipe_duration_seconds",
+			Help:    "Time it takes the agent to wipe server disks.",
+			Buckets: prometheus.ExponentialBuckets(5, 2, 12),
+		},
+	)
+
+	// UnexpectedAgentBoots is the number of allocated servers which booted into the agent.
+	UnexpectedAgentBoots = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "sidero_unexpected_agent_boots_total",
+			Help: "Number of allocated (installed) servers which booted into the agent unexpectedly.",
+		},
+	)
+
+	// CanaryRuns is the number of completed canary runs.
+	CanaryRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "sidero_canary_runs_total",
+			Help: "Number of completed canary runs by result (success, failure).",
+		},
+		[]string{"canary", "result"},
+	)
+
+	// CanaryStepDuration is the time it takes to complete the steps of the canary runs.
+	CanaryStepDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "sidero_canary_step_duration_seconds",
+			Help:    "Time it takes to complete the steps of the canary runs (wipe, install, verify, release).",
+			Buckets: prometheus.ExponentialBuckets(15, 2, 10),
+		},
+		[]string{"canary", "step"},
+	)
+
+	// CanaryLastSuccess is the completion time of the last succeeded canary run.
+	CanaryLastSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sidero_canary_last_success_timestamp_seconds",
+			Help: "Completion time of the last succeeded canary run as the Unix timestamp.",
+		},
+		[]string{"canary"},
+	)
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		ServerClassServers,
+		BootRequests,
+		HTTPRequests,
+		PowerOperations,
+		PowerOperationErrors,
+		WipeDuration,
+		UnexpectedAgentBoots,
+		CanaryRuns,
+		CanaryStepDuration,
+		CanaryLastSuccess,
+	)
+}
+
+// BootResult returns the boot request result for the HTTP status code.
+func BootResult(code int) string {
+	if code >= http.StatusBadRequest {
+		return ResultFailure
+	}
+
+	return ResultSuccess
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	if r.code == 0 {
+		r.code = code
+	}
+
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.code == 0 {
+		r.code = http.StatusOK
+	}
+
+	return r.ResponseWriter.Write(b)
+}
+
+// InstrumentBootHandler counts requests served by the handler as boot requests of the given type.
+func InstrumentBootHandler(bootType string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w}
+
+		next.ServeHTTP(recorder, r)
+
+		code := recorder.code
+		if code == 0 {
+			code = http.StatusOK
+		}
+
+		BootRequests.WithLabelValues(bootType, BootResult(code)).Inc()
+	})
+}
@@ -0,0 +1,37 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package metrics_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/metrics"
+)
+
+func TestInstrumentBootHandler(t *testing.T) {
+	t.Parallel()
+
+	handler := metrics.InstrumentBootHandler("test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+
+			return
+		}
+
+		w.Write([]byte("#!ipxe")) //nolint:errcheck
+	}))
+
+	for _, path := range []string{"/ipxe", "/ipxe", "/missing"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.BootRequests.WithLabelValues("test", metrics.ResultSuccess)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.BootRequests.WithLabelValues("test", metrics.ResultFailure)))
+}
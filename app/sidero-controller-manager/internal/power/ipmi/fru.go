@@ -0,0 +1,236 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ipmi
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	goipmi "github.com/pensando/goipmi"
+)
+
+// Link to the FRU spec: https://www.intel.com/content/dam/www/public/us/en/documents/specification-updates/ipmi-platform-mgt-fru-info-storage-def-v1-0-rev-1-3-spec-update.pdf
+
+const (
+	networkFunctionStorage = goipmi.NetworkFunction(0x0a)
+
+	commandGetFRUInventoryAreaInfo = goipmi.Command(0x10)
+	commandReadFRUData             = goipmi.Command(0x11)
+
+	// fruReadChunk is small enough for the BMCs limiting the message size.
+	fruReadChunk = 16
+	fruMaxSize   = 4096
+)
+
+// FRUChassis is the chassis info area of the FRU.
+type FRUChassis struct {
+	Type         uint8
+	PartNumber   string
+	SerialNumber string
+}
+
+type fruInventoryAreaInfoRequest struct {
+	DeviceID uint8
+}
+
+type fruInventoryAreaInfoResponse struct {
+	goipmi.CompletionCode
+	Size   uint16
+	Access uint8
+}
+
+type readFRUDataRequest struct {
+	DeviceID uint8
+	Offset   uint16
+	Count    uint8
+}
+
+type readFRUDataResponse struct {
+	goipmi.CompletionCode
+	Count uint8
+	Data  []byte
+}
+
+// UnmarshalBinary handles the variable length data.
+func (r *readFRUDataResponse) UnmarshalBinary(buf []byte) error {
+	if len(buf) < 2 {
+		return goipmi.ErrShortPacket
+	}
+
+	r.CompletionCode = goipmi.CompletionCode(buf[0])
+	r.Count = buf[1]
+	r.Data = buf[2:]
+
+	return nil
+}
+
+// FRU reads the FRU inventory of the device, 0 is the FRU of the BMC (and of the server).
+func (c *Client) FRU(deviceID uint8) ([]byte, error) {
+	info := &fruInventoryAreaInfoResponse{}
+
+	if err := c.IPMIClient.Send(&goipmi.Request{
+		NetworkFunction: networkFunctionStorage,
+		Command:         commandGetFRUInventoryAreaInfo,
+		Data:            &fruInventoryAreaInfoRequest{DeviceID: deviceID},
+	}, info); err != nil {
+		return nil, err
+	}
+
+	size := int(info.Size)
+	if size > fruMaxSize {
+		size = fruMaxSize
+	}
+
+	data := make([]byte, 0, size)
+
+	for len(data) < size {
+		count := size - len(data)
+		if count > fruReadChunk {
+			count = fruReadChunk
+		}
+
+		res := &readFRUDataResponse{}
+
+		if err := c.IPMIClient.Send(&goipmi.Request{
+			NetworkFunction: networkFunctionStorage,
+			Command:         commandReadFRUData,
+			Data:            &readFRUDataRequest{DeviceID: deviceID, Offset: uint16(len(data)), Count: uint8(count)},
+		}, res); err != nil {
+			return nil, err
+		}
+
+		if res.Count == 0 || int(res.Count) > len(res.Data) {
+			return nil, fmt.Errorf("invalid FRU data at offset %d", len(data))
+		}
+
+		data = append(data, res.Data[:res.Count]...)
+	}
+
+	return data, nil
+}
+
+// Chassis reads the chassis info area of the FRU of the server, nil if there is none.
+func (c *Client) Chassis() (*FRUChassis, error) {
+	data, err := c.FRU(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseFRUChassis(data)
+}
+
+// ParseFRUChassis parses the chassis info area of the FRU, nil if there is none.
+func ParseFRUChassis(data []byte) (*FRUChassis, error) {
+	if len(data) < 8 {
+		return nil, errors.New("FRU common header is truncated")
+	}
+
+	header := data[:8]
+
+	if header[0] != 0x01 {
+		return nil, fmt.Errorf("unsupported FRU format version %d", header[0])
+	}
+
+	if checksum(header) != 0 {
+		return nil, errors.New("invalid FRU common header checksum")
+	}
+
+	offset := int(header[2]) * 8
+	if offset == 0 {
+		return nil, nil
+	}
+
+	if len(data) < offset+2 {
+		return nil, errors.New("FRU chassis info area is truncated")
+	}
+
+	length := int(data[offset+1]) * 8
+	if length < 3 || len(data) < offset+length {
+		return nil, errors.New("FRU chassis info area is truncated")
+	}
+
+	area := data[offset : offset+length]
+
+	if checksum(area) != 0 {
+		return nil, errors.New("invalid FRU chassis info area checksum")
+	}
+
+	chassis := &FRUChassis{
+		Type: area[2],
+	}
+
+	fields := area[3:]
+
+	for _, field := range []*string{&chassis.PartNumber, &chassis.SerialNumber} {
+		value, rest, err := decodeField(fields)
+		if err != nil {
+			return nil, err
+		}
+
+		*field, fields = value, rest
+	}
+
+	return chassis, nil
+}
+
+// endOfFields is the type/length byte ending the fields of the area.
+const endOfFields = 0xc1
+
+// decodeField decodes the type/length encoded field.
+func decodeField(b []byte) (string, []byte, error) {
+	if len(b) == 0 || b[0] == endOfFields {
+		return "", b, nil
+	}
+
+	typ, length := b[0]>>6, int(b[0]&0x3f)
+
+	if len(b) < 1+length {
+		return "", nil, errors.New("FRU field is truncated")
+	}
+
+	data, rest := b[1:1+length], b[1+length:]
+
+	switch typ {
+	case 0: // binary
+		return hex.EncodeToString(data), rest, nil
+	case 1: // BCD plus
+		var sb strings.Builder
+
+		const digits = "0123456789 -.   "
+
+		for _, c := range data {
+			sb.WriteByte(digits[c>>4])
+			sb.WriteByte(digits[c&0x0f])
+		}
+
+		return strings.TrimSpace(sb.String()), rest, nil
+	case 2: // 6-bit ASCII packed
+		var sb strings.Builder
+
+		for i := 0; i+2 < len(data); i += 3 {
+			v := uint32(data[i]) | uint32(data[i+1])<<8 | uint32(data[i+2])<<16
+
+			for j := 0; j < 4; j++ {
+				sb.WriteByte(byte(v>>(6*j)&0x3f) + 0x20)
+			}
+		}
+
+		return strings.TrimSpace(sb.String()), rest, nil
+	default: // 8-bit ASCII
+		return strings.TrimSpace(strings.TrimRight(string(data), "\x00")), rest, nil
+	}
+}
+
+func checksum(b []byte) byte {
+	var sum byte
+
+	for _, c := range b {
+		sum += c
+	}
+
+	return sum
+}
@@ -0,0 +1,98 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ipmi_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/power/ipmi"
+)
+
+// withChecksum appends the zero checksum byte.
+func withChecksum(b []byte) []byte {
+	var sum byte
+
+	for _, c := range b {
+		sum += c
+	}
+
+	return append(b, -sum)
+}
+
+// fru builds the FRU with the common header and the chassis info area built from the fields.
+func fru(fields ...byte) []byte {
+	area := append([]byte{0x01, 0x00, 0x17}, fields...)
+	area = append(area, 0xc1)
+
+	for (len(area)+1)%8 != 0 {
+		area = append(area, 0x00)
+	}
+
+	area[1] = byte((len(area) + 1) / 8)
+
+	return append(withChecksum([]byte{0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00}), withChecksum(area)...)
+}
+
+func field(value string) []byte {
+	return append([]byte{0xc0 | byte(len(value))}, value...)
+}
+
+func TestParseFRUChassis(t *testing.T) {
+	t.Parallel()
+
+	t.Run("8-bit ASCII", func(t *testing.T) {
+		t.Parallel()
+
+		chassis, err := ipmi.ParseFRUChassis(fru(append(field("PN-1"), field("CHS0042 ")...)...))
+		require.NoError(t, err)
+		assert.Equal(t, &ipmi.FRUChassis{Type: 0x17, PartNumber: "PN-1", SerialNumber: "CHS0042"}, chassis)
+	})
+
+	t.Run("6-bit ASCII and BCD plus", func(t *testing.T) {
+		t.Parallel()
+
+		chassis, err := ipmi.ParseFRUChassis(fru(0x83, 0xa1, 0x38, 0x46, 0x42, 0x01, 0x2b))
+		require.NoError(t, err)
+		assert.Equal(t, &ipmi.FRUChassis{Type: 0x17, PartNumber: "ABC1", SerialNumber: "012-"}, chassis)
+	})
+
+	t.Run("no serial number", func(t *testing.T) {
+		t.Parallel()
+
+		chassis, err := ipmi.ParseFRUChassis(fru(field("PN-1")...))
+		require.NoError(t, err)
+		assert.Equal(t, &ipmi.FRUChassis{Type: 0x17, PartNumber: "PN-1"}, chassis)
+	})
+
+	t.Run("no chassis area", func(t *testing.T) {
+		t.Parallel()
+
+		chassis, err := ipmi.ParseFRUChassis(withChecksum([]byte{0x01, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00}))
+		require.NoError(t, err)
+		assert.Nil(t, chassis)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+
+		data := fru(field("PN-1")...)
+		data[len(data)-1]++
+
+		_, err := ipmi.ParseFRUChassis(data)
+		assert.EqualError(t, err, "invalid FRU chassis info area checksum")
+
+		data = fru(field("PN-1")...)
+		data[7]++
+
+		_, err = ipmi.ParseFRUChassis(data)
+		assert.EqualError(t, err, "invalid FRU common header checksum")
+
+		_, err = ipmi.ParseFRUChassis(fru(field("PN-1")...)[:12])
+		assert.EqualError(t, err, "FRU chassis info area is truncated")
+	})
+}
@@ -5,6 +5,8 @@
 package ipmi
 
 import (
+	"fmt"
+
 	goipmi "github.com/pensando/goipmi"
 
 	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
@@ -85,6 +87,17 @@ func (c *Client) SetPXE() error {
 	return c.IPMIClient.SetBootDeviceEFI(goipmi.BootDevicePxe)
 }
 
+// FirmwareVersion fetches the BMC firmware revision (see 20.1).
+func (c *Client) FirmwareVersion() (string, error) {
+	res, err := c.IPMIClient.DeviceID()
+	if err != nil {
+		return "", err
+	}
+
+	// major revision is binary (bit 7 is "device available"), minor revision is BCD
+	return fmt.Sprintf("%d.%02x", res.FirmwareRevision1&0x7f, res.FirmwareRevision2), nil
+}
+
 // GetLANConfig fetches a given param from the LAN Config. (see 23.2).
 func (c *Client) GetLANConfig(param uint8) (*goipmi.LANConfigResponse, error) {
 	req := &goipmi.Request{
@@ -0,0 +1,103 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package ipmi
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// solSession is the running `ipmitool sol activate`.
+type solSession struct {
+	*io.PipeReader
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Close terminates the session.
+func (s *solSession) Close() error {
+	s.cancel()
+	s.PipeReader.Close() //nolint:errcheck
+
+	<-s.done
+
+	return nil
+}
+
+// ActivateSOL opens Serial-over-LAN session with ipmitool and returns the console output.
+//
+// BMC credentials should be already resolved. Closing the returned reader terminates the session.
+func ActivateSOL(ctx context.Context, bmc metalv1alpha1.BMC) (io.ReadCloser, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	// BMC allows a single SOL session, and the previous one might have been left behind (e.g. on restart)
+	solCommand(ctx, bmc, "deactivate").Run() //nolint:errcheck
+
+	cmd := solCommand(ctx, bmc, "activate", "usesolkeepalive")
+
+	r, w := io.Pipe()
+	cmd.Stdout = w
+	cmd.Stderr = w
+
+	// ipmitool terminates the session on EOF, so keep stdin open
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+
+		return nil, err
+	}
+
+	if err = cmd.Start(); err != nil {
+		cancel()
+
+		return nil, err
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		err := cmd.Wait()
+		if err == nil {
+			err = io.EOF
+		}
+
+		stdin.Close() //nolint:errcheck
+		w.CloseWithError(err)
+		close(done)
+	}()
+
+	return &solSession{
+		PipeReader: r,
+		cancel:     cancel,
+		done:       done,
+	}, nil
+}
+
+func solCommand(ctx context.Context, bmc metalv1alpha1.BMC, args ...string) *exec.Cmd {
+	intf := bmc.Interface
+	if intf == "" {
+		intf = "lanplus"
+	}
+
+	// password is passed via the environment (-E) to keep it out of the process list
+	cmd := exec.CommandContext(ctx, "ipmitool", append([]string{
+		"-I", intf,
+		"-H", bmc.Endpoint,
+		"-p", strconv.Itoa(int(bmc.Port)),
+		"-U", bmc.User,
+		"-E",
+		"sol",
+	}, args...)...)
+
+	cmd.Env = append(os.Environ(), "IPMI_PASSWORD="+bmc.Pass)
+
+	return cmd
+}
@@ -0,0 +1,48 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package metal
+
+// dryRunClient reports the power management operations instead of executing them.
+type dryRunClient struct {
+	ManagementClient
+
+	report func(operation string)
+}
+
+// NewDryRunClient wraps the client so that the power management operations are reported and skipped.
+//
+// Power state is still read from the underlying client.
+func NewDryRunClient(client ManagementClient, report func(operation string)) ManagementClient {
+	return dryRunClient{client, report}
+}
+
+func (c dryRunClient) PowerOn() error {
+	c.report("power on")
+
+	return nil
+}
+
+func (c dryRunClient) PowerOff() error {
+	c.report("power off")
+
+	return nil
+}
+
+func (c dryRunClient) PowerCycle() error {
+	c.report("power cycle")
+
+	return nil
+}
+
+func (c dryRunClient) SetPXE() error {
+	c.report("set PXE boot once")
+
+	return nil
+}
+
+// IsFake returns true, as the operations have no effect on the server.
+func (c dryRunClient) IsFake() bool {
+	return true
+}
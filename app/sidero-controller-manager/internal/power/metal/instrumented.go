@@ -0,0 +1,48 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package metal
+
+import (
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/metrics"
+)
+
+// instrumentedClient records power management operations and errors as metrics.
+type instrumentedClient struct {
+	ManagementClient
+
+	iface string
+}
+
+func (c instrumentedClient) observe(operation string, err error) error {
+	metrics.PowerOperations.WithLabelValues(c.iface, operation).Inc()
+
+	if err != nil {
+		metrics.PowerOperationErrors.WithLabelValues(c.iface, operation).Inc()
+	}
+
+	return err
+}
+
+func (c instrumentedClient) PowerOn() error {
+	return c.observe("power-on", c.ManagementClient.PowerOn())
+}
+
+func (c instrumentedClient) PowerOff() error {
+	return c.observe("power-off", c.ManagementClient.PowerOff())
+}
+
+func (c instrumentedClient) PowerCycle() error {
+	return c.observe("power-cycle", c.ManagementClient.PowerCycle())
+}
+
+func (c instrumentedClient) IsPoweredOn() (bool, error) {
+	poweredOn, err := c.ManagementClient.IsPoweredOn()
+
+	return poweredOn, c.observe("status", err)
+}
+
+func (c instrumentedClient) SetPXE() error {
+	return c.observe("set-pxe", c.ManagementClient.SetPXE())
+}
@@ -30,36 +30,56 @@ type ManagementClient interface {
 func NewManagementClient(ctx context.Context, client client.Client, spec *v1alpha1.ServerSpec) (ManagementClient, error) {
 	switch {
 	case spec.BMC != nil:
-		var err error
-
-		bmcSpec := *spec.BMC
+		bmcSpec, err := ResolveBMC(ctx, client, spec.BMC)
+		if err != nil {
+			return nil, err
+		}
 
-		if bmcSpec.User == "" {
-			bmcSpec.User, err = bmcSpec.UserFrom.Resolve(ctx, client)
-			if err != nil {
-				return nil, err
-			}
+		ipmiClient, err := ipmi.NewClient(bmcSpec)
+		if err != nil {
+			return nil, err
 		}
 
-		if bmcSpec.Pass == "" {
-			bmcSpec.Pass, err = bmcSpec.PassFrom.Resolve(ctx, client)
-			if err != nil {
-				return nil, err
-			}
+		return instrumentedClient{ipmiClient, "ipmi"}, nil
+	case spec.ManagementAPI != nil:
+		apiClient, err := api.NewClient(*spec.ManagementAPI)
+		if err != nil {
+			return nil, err
 		}
 
-		if bmcSpec.Interface == "" {
-			bmcSpec.Interface = "lanplus"
+		return instrumentedClient{apiClient, "api"}, nil
+	default:
+		return fakeClient{}, nil
+	}
+}
+
+// ResolveBMC resolves the BMC credentials and fills in the defaults.
+func ResolveBMC(ctx context.Context, client client.Client, bmc *v1alpha1.BMC) (v1alpha1.BMC, error) {
+	var err error
+
+	bmcSpec := *bmc
+
+	if bmcSpec.User == "" {
+		bmcSpec.User, err = bmcSpec.UserFrom.Resolve(ctx, client)
+		if err != nil {
+			return bmcSpec, err
 		}
+	}
 
-		if bmcSpec.Port == 0 {
-			bmcSpec.Port = constants.DefaultBMCPort
+	if bmcSpec.Pass == "" {
+		bmcSpec.Pass, err = bmcSpec.PassFrom.Resolve(ctx, client)
+		if err != nil {
+			return bmcSpec, err
 		}
+	}
 
-		return ipmi.NewClient(bmcSpec)
-	case spec.ManagementAPI != nil:
-		return api.NewClient(*spec.ManagementAPI)
-	default:
-		return fakeClient{}, nil
+	if bmcSpec.Interface == "" {
+		bmcSpec.Interface = "lanplus"
+	}
+
+	if bmcSpec.Port == 0 {
+		bmcSpec.Port = constants.DefaultBMCPort
 	}
+
+	return bmcSpec, nil
 }
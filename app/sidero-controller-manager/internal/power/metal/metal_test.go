@@ -4,11 +4,38 @@
 
 package metal_test
 
-import "testing"
+import (
+	"context"
+	"testing"
 
-func TestEmpty(t *testing.T) {
-	// added for accurate coverage estimation
-	//
-	// please remove it once any unit-test is added
-	// for this package
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/power/metal"
+)
+
+func TestDryRunClient(t *testing.T) {
+	t.Parallel()
+
+	mgmtClient, err := metal.NewManagementClient(context.Background(), nil, &v1alpha1.ServerSpec{})
+	require.NoError(t, err)
+
+	var operations []string
+
+	dryRunClient := metal.NewDryRunClient(mgmtClient, func(operation string) {
+		operations = append(operations, operation)
+	})
+
+	poweredOn, err := dryRunClient.IsPoweredOn()
+	require.NoError(t, err)
+	assert.True(t, poweredOn)
+
+	require.NoError(t, dryRunClient.SetPXE())
+	require.NoError(t, dryRunClient.PowerCycle())
+	require.NoError(t, dryRunClient.PowerOff())
+	require.NoError(t, dryRunClient.PowerOn())
+
+	assert.Equal(t, []string{"set PXE boot once", "power cycle", "power off", "power on"}, operations)
+	assert.True(t, dryRunClient.IsFake())
 }
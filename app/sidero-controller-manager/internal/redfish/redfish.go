@@ -0,0 +1,220 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package redfish implements the read-only hardware inventory API with the Redfish schema.
+//
+// Every server is exposed as the ComputerSystem with the processors, the network interfaces and the drives
+// reported by the agent, and the BMC as the Manager, so that the inventory can be ingested by the tooling speaking Redfish.
+// Power and other actions are not supported.
+package redfish
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// Version of the Redfish specification the schema follows.
+const Version = "1.11.0"
+
+// RootPath is the Redfish service root.
+const RootPath = "/redfish/v1/"
+
+const (
+	systemsPath  = RootPath + "Systems"
+	managersPath = RootPath + "Managers"
+
+	// storageID is the ID of the single storage subsystem of the server, as the agent doesn't report the controllers.
+	storageID = "1"
+	// processorID is the ID of the single processor of the server, as the agent doesn't report the sockets.
+	processorID = "CPU1"
+)
+
+var errNotFound = errors.New("resource not found")
+
+// Handler serves the inventory of the servers.
+type Handler struct {
+	c client.Reader
+}
+
+// RegisterHandler registers the HTTP handler serving the inventory.
+func RegisterHandler(mux *http.ServeMux, c client.Reader) {
+	h := &Handler{c: c}
+
+	mux.Handle("/redfish", h)
+	mux.Handle("/redfish/", h)
+}
+
+// ServeHTTP implements http.Handler.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		writeError(w, http.StatusMethodNotAllowed, "Base.1.8.OperationNotAllowed", "Inventory is read-only.")
+
+		return
+	}
+
+	resource, err := h.resource(r.Context(), r.URL.Path)
+	if err != nil {
+		if apierrors.IsNotFound(err) || errors.Is(err, errNotFound) {
+			writeError(w, http.StatusNotFound, "Base.1.8.ResourceMissingAtURI", fmt.Sprintf("Resource %s is not found.", r.URL.Path))
+		} else {
+			writeError(w, http.StatusInternalServerError, "Base.1.8.InternalError", err.Error())
+		}
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("OData-Version", "4.0")
+
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	json.NewEncoder(w).Encode(resource) //nolint:errcheck
+}
+
+//nolint:gocyclo
+func (h *Handler) resource(ctx context.Context, urlPath string) (interface{}, error) {
+	switch strings.TrimSuffix(urlPath, "/") {
+	case "/redfish":
+		return map[string]string{"v1": RootPath}, nil
+	case strings.TrimSuffix(RootPath, "/"):
+		return serviceRoot(), nil
+	case systemsPath:
+		return h.collection(ctx, systemsPath, "#ComputerSystemCollection.ComputerSystemCollection", "Computer System Collection", nil)
+	case managersPath:
+		return h.collection(ctx, managersPath, "#ManagerCollection.ManagerCollection", "Manager Collection", hasManager)
+	}
+
+	var collection string
+
+	switch {
+	case strings.HasPrefix(urlPath, systemsPath+"/"):
+		collection = systemsPath
+	case strings.HasPrefix(urlPath, managersPath+"/"):
+		collection = managersPath
+	default:
+		return nil, errNotFound
+	}
+
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(urlPath, collection), "/"), "/")
+	if parts[0] == "" {
+		return nil, errNotFound
+	}
+
+	var server metalv1alpha1.Server
+
+	if err := h.c.Get(ctx, types.NamespacedName{Name: parts[0]}, &server); err != nil {
+		return nil, err
+	}
+
+	if collection == managersPath {
+		if len(parts) != 1 || !hasManager(&server) {
+			return nil, errNotFound
+		}
+
+		return manager(&server), nil
+	}
+
+	return systemResource(&server, parts[1:])
+}
+
+// systemResource returns the ComputerSystem or its subresource.
+func systemResource(server *metalv1alpha1.Server, parts []string) (interface{}, error) {
+	id := systemsPath + "/" + server.Name
+
+	inventory := server.Status.Inventory
+	if inventory == nil {
+		inventory = &metalv1alpha1.HardwareInventory{}
+	}
+
+	switch {
+	case len(parts) == 0:
+		return computerSystem(server), nil
+	case len(parts) == 1 && parts[0] == "Processors":
+		return newCollection(id+"/Processors", "#ProcessorCollection.ProcessorCollection", "Processor Collection", []string{id + "/Processors/" + processorID}), nil
+	case len(parts) == 2 && parts[0] == "Processors" && parts[1] == processorID:
+		return processor(server), nil
+	case len(parts) == 1 && parts[0] == "EthernetInterfaces":
+		members := make([]string, 0, len(inventory.NetworkInterfaces))
+
+		for _, iface := range inventory.NetworkInterfaces {
+			members = append(members, id+"/EthernetInterfaces/"+iface.Name)
+		}
+
+		return newCollection(id+"/EthernetInterfaces", "#EthernetInterfaceCollection.EthernetInterfaceCollection", "Ethernet Interface Collection", members), nil
+	case len(parts) == 2 && parts[0] == "EthernetInterfaces":
+		for _, iface := range inventory.NetworkInterfaces {
+			if iface.Name == parts[1] {
+				return ethernetInterface(id, iface), nil
+			}
+		}
+	case len(parts) == 1 && parts[0] == "Storage":
+		return newCollection(id+"/Storage", "#StorageCollection.StorageCollection", "Storage Collection", []string{id + "/Storage/" + storageID}), nil
+	case len(parts) == 2 && parts[0] == "Storage" && parts[1] == storageID:
+		return storage(id, inventory.Disks), nil
+	case len(parts) == 4 && parts[0] == "Storage" && parts[1] == storageID && parts[2] == "Drives":
+		for _, disk := range inventory.Disks {
+			if driveID(disk) == parts[3] {
+				return drive(id, disk), nil
+			}
+		}
+	}
+
+	return nil, errNotFound
+}
+
+func (h *Handler) collection(ctx context.Context, id, odataType, name string, filter func(*metalv1alpha1.Server) bool) (interface{}, error) {
+	var servers metalv1alpha1.ServerList
+
+	if err := h.c.List(ctx, &servers); err != nil {
+		return nil, err
+	}
+
+	members := make([]string, 0, len(servers.Items))
+
+	for i := range servers.Items {
+		if filter != nil && !filter(&servers.Items[i]) {
+			continue
+		}
+
+		members = append(members, id+"/"+servers.Items[i].Name)
+	}
+
+	return newCollection(id, odataType, name, members), nil
+}
+
+// hasManager checks whether the BMC of the server is known.
+func hasManager(server *metalv1alpha1.Server) bool {
+	return server.Spec.BMC != nil || (server.Status.Inventory != nil && server.Status.Inventory.BMCFirmwareVersion != "")
+}
+
+// driveID is the ID of the drive derived from the device name, e.g. `nvme0n1` for `/dev/nvme0n1`.
+func driveID(disk metalv1alpha1.DiskInformation) string {
+	return path.Base(disk.DeviceName)
+}
+
+func writeError(w http.ResponseWriter, code int, messageID, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+
+	json.NewEncoder(w).Encode(errorResponse{ //nolint:errcheck
+		Error: errorBody{
+			Code:    messageID,
+			Message: message,
+		},
+	})
+}
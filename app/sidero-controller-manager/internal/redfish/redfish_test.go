@@ -0,0 +1,234 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package redfish_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/redfish"
+)
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+
+	c := fake.NewFakeClientWithScheme(scheme,
+		&metalv1alpha1.Server{
+			ObjectMeta: metav1.ObjectMeta{Name: "4c4c4544-0039-3010-8048-b7c04f384432"},
+			Spec: metalv1alpha1.ServerSpec{
+				Hostname: "node-1",
+				SystemInformation: &metalv1alpha1.SystemInformation{
+					Manufacturer: "Dell Inc.",
+					ProductName:  "PowerEdge R640",
+					SerialNumber: "9X08HB2",
+				},
+				CPU:      &metalv1alpha1.CPUInformation{Manufacturer: "Intel(R) Corporation", Version: "Intel(R) Xeon(R) Gold 6130"},
+				BIOS:     &metalv1alpha1.BIOSInformation{Version: "2.10.2"},
+				BMC:      &metalv1alpha1.BMC{Endpoint: "10.0.0.25"},
+				Accepted: true,
+			},
+			Status: metalv1alpha1.ServerStatus{
+				InUse: true,
+				Power: "on",
+				Inventory: &metalv1alpha1.HardwareInventory{
+					NetworkInterfaces: []metalv1alpha1.NetworkInterface{
+						{Name: "eno1", MAC: "e4:43:4b:12:34:56", SpeedMbps: 10000, Driver: "i40e"},
+						{Name: "eno2", MAC: "e4:43:4b:12:34:57"},
+					},
+					Disks: []metalv1alpha1.DiskInformation{
+						{DeviceName: "/dev/nvme0n1", Model: "Dell Express Flash", Serial: "S4YNNE0N", Type: "nvme", Size: 1600321314816, SMARTHealth: "Passed"},
+						{DeviceName: "/dev/sda", Type: "hdd", SMARTHealth: "Failed"},
+					},
+					BMCFirmwareVersion: "4.40.00.00",
+					UpdatedAt:          metav1.NewTime(time.Date(2021, 9, 1, 10, 0, 0, 0, time.UTC)),
+				},
+			},
+		},
+		&metalv1alpha1.Server{
+			ObjectMeta: metav1.ObjectMeta{Name: "5d57b9f2-6c8a-4f55-9d51-2f3d4b1a7e10"},
+		},
+	)
+
+	mux := http.NewServeMux()
+	redfish.RegisterHandler(mux, c)
+
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	const system = "/redfish/v1/Systems/4c4c4544-0039-3010-8048-b7c04f384432"
+
+	for name, tc := range map[string]struct {
+		method string
+		path   string
+
+		expectedCode   int
+		expectedFields map[string]interface{}
+	}{
+		"versions": {
+			path:           "/redfish",
+			expectedFields: map[string]interface{}{"v1": "/redfish/v1/"},
+		},
+		"service root": {
+			path: "/redfish/v1",
+			expectedFields: map[string]interface{}{
+				"@odata.type": "#ServiceRoot.v1_5_0.ServiceRoot",
+				"Systems":     map[string]interface{}{"@odata.id": "/redfish/v1/Systems"},
+			},
+		},
+		"systems": {
+			path: "/redfish/v1/Systems/",
+			expectedFields: map[string]interface{}{
+				"Members@odata.count": 2.0,
+			},
+		},
+		"system": {
+			path: system,
+			expectedFields: map[string]interface{}{
+				"@odata.type":      "#ComputerSystem.v1_13_0.ComputerSystem",
+				"UUID":             "4c4c4544-0039-3010-8048-b7c04f384432",
+				"HostName":         "node-1",
+				"Manufacturer":     "Dell Inc.",
+				"Model":            "PowerEdge R640",
+				"SerialNumber":     "9X08HB2",
+				"BiosVersion":      "2.10.2",
+				"PowerState":       "On",
+				"Status":           map[string]interface{}{"State": "Enabled", "Health": "OK", "HealthRollup": "Critical"},
+				"ProcessorSummary": map[string]interface{}{"Model": "Intel(R) Xeon(R) Gold 6130"},
+				"Links": map[string]interface{}{
+					"ManagedBy": []interface{}{map[string]interface{}{"@odata.id": "/redfish/v1/Managers/4c4c4544-0039-3010-8048-b7c04f384432"}},
+				},
+				"Oem": map[string]interface{}{
+					"Sidero": map[string]interface{}{"Accepted": true, "InUse": true, "IsClean": false, "InventoryUpdatedAt": "2021-09-01T10:00:00Z"},
+				},
+			},
+		},
+		"not discovered system": {
+			path: "/redfish/v1/Systems/5d57b9f2-6c8a-4f55-9d51-2f3d4b1a7e10/EthernetInterfaces",
+			expectedFields: map[string]interface{}{
+				"Members":             []interface{}{},
+				"Members@odata.count": 0.0,
+			},
+		},
+		"ethernet interface": {
+			path: system + "/EthernetInterfaces/eno1",
+			expectedFields: map[string]interface{}{
+				"MACAddress": "E4:43:4B:12:34:56",
+				"SpeedMbps":  10000.0,
+				"LinkStatus": "LinkUp",
+				"Oem":        map[string]interface{}{"Sidero": map[string]interface{}{"Driver": "i40e"}},
+			},
+		},
+		"storage": {
+			path: system + "/Storage/1",
+			expectedFields: map[string]interface{}{
+				"Drives": []interface{}{
+					map[string]interface{}{"@odata.id": system + "/Storage/1/Drives/nvme0n1"},
+					map[string]interface{}{"@odata.id": system + "/Storage/1/Drives/sda"},
+				},
+			},
+		},
+		"drive": {
+			path: system + "/Storage/1/Drives/nvme0n1",
+			expectedFields: map[string]interface{}{
+				"Name":          "/dev/nvme0n1",
+				"CapacityBytes": 1600321314816.0,
+				"MediaType":     "SSD",
+				"Protocol":      "NVMe",
+				"Status":        map[string]interface{}{"State": "Enabled", "Health": "OK"},
+			},
+		},
+		"failed drive": {
+			path: system + "/Storage/1/Drives/sda",
+			expectedFields: map[string]interface{}{
+				"FailurePredicted": true,
+				"Status":           map[string]interface{}{"State": "Enabled", "Health": "Critical"},
+			},
+		},
+		"managers": {
+			path: "/redfish/v1/Managers",
+			expectedFields: map[string]interface{}{
+				"Members@odata.count": 1.0,
+			},
+		},
+		"manager": {
+			path: "/redfish/v1/Managers/4c4c4544-0039-3010-8048-b7c04f384432",
+			expectedFields: map[string]interface{}{
+				"ManagerType":     "BMC",
+				"FirmwareVersion": "4.40.00.00",
+			},
+		},
+		"missing manager": {
+			path:         "/redfish/v1/Managers/5d57b9f2-6c8a-4f55-9d51-2f3d4b1a7e10",
+			expectedCode: http.StatusNotFound,
+		},
+		"missing system": {
+			path:         "/redfish/v1/Systems/missing",
+			expectedCode: http.StatusNotFound,
+		},
+		"missing drive": {
+			path:         system + "/Storage/1/Drives/sdb",
+			expectedCode: http.StatusNotFound,
+		},
+		"read-only": {
+			method:       http.MethodPatch,
+			path:         system,
+			expectedCode: http.StatusMethodNotAllowed,
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			method := tc.method
+			if method == "" {
+				method = http.MethodGet
+			}
+
+			req, err := http.NewRequest(method, srv.URL+tc.path, strings.NewReader("{}"))
+			require.NoError(t, err)
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+
+			defer resp.Body.Close() //nolint:errcheck
+
+			expectedCode := tc.expectedCode
+			if expectedCode == 0 {
+				expectedCode = http.StatusOK
+			}
+
+			require.Equal(t, expectedCode, resp.StatusCode)
+
+			var body map[string]interface{}
+
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+
+			if expectedCode != http.StatusOK {
+				assert.Contains(t, body, "error")
+
+				return
+			}
+
+			for field, expected := range tc.expectedFields {
+				assert.Equal(t, expected, body[field], field)
+			}
+		})
+	}
+}
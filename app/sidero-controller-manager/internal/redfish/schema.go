@@ -0,0 +1,423 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package redfish
+
+import (
+	"strings"
+	"time"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// Health values of the resource status.
+const (
+	HealthOK       = "OK"
+	HealthCritical = "Critical"
+)
+
+type odataID struct {
+	ID string `json:"@odata.id"`
+}
+
+type resource struct {
+	ID         string `json:"@odata.id"`
+	Type       string `json:"@odata.type"`
+	ResourceID string `json:"Id,omitempty"`
+	Name       string `json:"Name"`
+}
+
+type status struct {
+	State        string `json:"State,omitempty"`
+	Health       string `json:"Health,omitempty"`
+	HealthRollup string `json:"HealthRollup,omitempty"`
+}
+
+type errorBody struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+// ServiceRoot is the Redfish service root.
+type ServiceRoot struct {
+	resource
+
+	RedfishVersion string  `json:"RedfishVersion"`
+	Systems        odataID `json:"Systems"`
+	Managers       odataID `json:"Managers"`
+}
+
+// Collection is a resource collection.
+type Collection struct {
+	resource
+
+	Members      []odataID `json:"Members"`
+	MembersCount int       `json:"Members@odata.count"`
+}
+
+// ComputerSystem is the server.
+type ComputerSystem struct {
+	resource
+
+	UUID             string            `json:"UUID"`
+	HostName         string            `json:"HostName,omitempty"`
+	SystemType       string            `json:"SystemType"`
+	Manufacturer     string            `json:"Manufacturer,omitempty"`
+	Model            string            `json:"Model,omitempty"`
+	SKU              string            `json:"SKU,omitempty"`
+	SerialNumber     string            `json:"SerialNumber,omitempty"`
+	BiosVersion      string            `json:"BiosVersion,omitempty"`
+	PowerState       string            `json:"PowerState,omitempty"`
+	Status           status            `json:"Status"`
+	ProcessorSummary *ProcessorSummary `json:"ProcessorSummary,omitempty"`
+
+	Processors         odataID `json:"Processors"`
+	EthernetInterfaces odataID `json:"EthernetInterfaces"`
+	Storage            odataID `json:"Storage"`
+
+	Links SystemLinks `json:"Links"`
+	Oem   SystemOem   `json:"Oem"`
+}
+
+// ProcessorSummary is the summary of the processors of the server.
+type ProcessorSummary struct {
+	Model string `json:"Model,omitempty"`
+}
+
+// SystemLinks are the resources related to the server.
+type SystemLinks struct {
+	ManagedBy []odataID `json:"ManagedBy,omitempty"`
+}
+
+// SystemOem is the Sidero state of the server.
+type SystemOem struct {
+	Sidero SideroSystem `json:"Sidero"`
+}
+
+// SideroSystem is the Sidero state of the server.
+type SideroSystem struct {
+	Accepted           bool   `json:"Accepted"`
+	InUse              bool   `json:"InUse"`
+	IsClean            bool   `json:"IsClean"`
+	InventoryUpdatedAt string `json:"InventoryUpdatedAt,omitempty"`
+}
+
+// Processor is the processor of the server.
+type Processor struct {
+	resource
+
+	ProcessorType string `json:"ProcessorType"`
+	Manufacturer  string `json:"Manufacturer,omitempty"`
+	Model         string `json:"Model,omitempty"`
+}
+
+// EthernetInterface is the network interface of the server.
+type EthernetInterface struct {
+	resource
+
+	MACAddress          string               `json:"MACAddress,omitempty"`
+	PermanentMACAddress string               `json:"PermanentMACAddress,omitempty"`
+	SpeedMbps           uint32               `json:"SpeedMbps"`
+	LinkStatus          string               `json:"LinkStatus"`
+	Oem                 EthernetInterfaceOem `json:"Oem"`
+}
+
+// EthernetInterfaceOem are the properties of the network interface without the Redfish equivalent.
+type EthernetInterfaceOem struct {
+	Sidero SideroEthernetInterface `json:"Sidero"`
+}
+
+// SideroEthernetInterface are the properties of the network interface without the Redfish equivalent.
+type SideroEthernetInterface struct {
+	PCIAddress      string `json:"PCIAddress,omitempty"`
+	Driver          string `json:"Driver,omitempty"`
+	FirmwareVersion string `json:"FirmwareVersion,omitempty"`
+	// LinkAggregation is the link aggregation source ("lacp" or "lldp") if the switch port is aggregated.
+	LinkAggregation string `json:"LinkAggregation,omitempty"`
+}
+
+// Storage is the storage subsystem of the server.
+type Storage struct {
+	resource
+
+	Drives      []odataID `json:"Drives"`
+	DrivesCount int       `json:"Drives@odata.count"`
+	Status      status    `json:"Status"`
+}
+
+// Drive is the disk of the server.
+type Drive struct {
+	resource
+
+	Model            string `json:"Model,omitempty"`
+	SerialNumber     string `json:"SerialNumber,omitempty"`
+	Revision         string `json:"Revision,omitempty"`
+	CapacityBytes    uint64 `json:"CapacityBytes,omitempty"`
+	MediaType        string `json:"MediaType,omitempty"`
+	Protocol         string `json:"Protocol,omitempty"`
+	FailurePredicted bool   `json:"FailurePredicted"`
+	Status           status `json:"Status"`
+}
+
+// Manager is the BMC of the server.
+type Manager struct {
+	resource
+
+	ManagerType     string       `json:"ManagerType"`
+	FirmwareVersion string       `json:"FirmwareVersion,omitempty"`
+	Links           ManagerLinks `json:"Links"`
+}
+
+// ManagerLinks are the resources managed by the BMC.
+type ManagerLinks struct {
+	ManagerForServers []odataID `json:"ManagerForServers"`
+}
+
+func newCollection(id, odataType, name string, members []string) *Collection {
+	c := &Collection{
+		resource: resource{
+			ID:   id,
+			Type: odataType,
+			Name: name,
+		},
+		Members:      make([]odataID, 0, len(members)),
+		MembersCount: len(members),
+	}
+
+	for _, member := range members {
+		c.Members = append(c.Members, odataID{member})
+	}
+
+	return c
+}
+
+func serviceRoot() *ServiceRoot {
+	return &ServiceRoot{
+		resource: resource{
+			ID:         RootPath,
+			Type:       "#ServiceRoot.v1_5_0.ServiceRoot",
+			ResourceID: "RootService",
+			Name:       "Sidero Inventory Service",
+		},
+		RedfishVersion: Version,
+		Systems:        odataID{systemsPath},
+		Managers:       odataID{managersPath},
+	}
+}
+
+func computerSystem(server *metalv1alpha1.Server) *ComputerSystem {
+	id := systemsPath + "/" + server.Name
+
+	system := &ComputerSystem{
+		resource: resource{
+			ID:         id,
+			Type:       "#ComputerSystem.v1_13_0.ComputerSystem",
+			ResourceID: server.Name,
+			Name:       server.Name,
+		},
+		UUID:       server.Name,
+		HostName:   server.Spec.Hostname,
+		SystemType: "Physical",
+		Status: status{
+			State:  "Enabled",
+			Health: HealthOK,
+		},
+		Processors:         odataID{id + "/Processors"},
+		EthernetInterfaces: odataID{id + "/EthernetInterfaces"},
+		Storage:            odataID{id + "/Storage"},
+		Oem: SystemOem{
+			Sidero: SideroSystem{
+				Accepted: server.Spec.Accepted,
+				InUse:    server.Status.InUse,
+				IsClean:  server.Status.IsClean,
+			},
+		},
+	}
+
+	if !server.Spec.Accepted {
+		system.Status.State = "Disabled"
+	}
+
+	if info := server.Spec.SystemInformation; info != nil {
+		system.Manufacturer = info.Manufacturer
+		system.Model = info.ProductName
+		system.SKU = info.SKUNumber
+		system.SerialNumber = info.SerialNumber
+	}
+
+	if server.Spec.BIOS != nil {
+		system.BiosVersion = server.Spec.BIOS.Version
+	}
+
+	if server.Spec.CPU != nil {
+		system.ProcessorSummary = &ProcessorSummary{Model: server.Spec.CPU.Version}
+	}
+
+	switch server.Status.Power {
+	case "on":
+		system.PowerState = "On"
+	case "off":
+		system.PowerState = "Off"
+	}
+
+	if inventory := server.Status.Inventory; inventory != nil {
+		system.Oem.Sidero.InventoryUpdatedAt = inventory.UpdatedAt.UTC().Format(time.RFC3339)
+		system.Status.HealthRollup = disksHealth(inventory.Disks)
+	}
+
+	if hasManager(server) {
+		system.Links.ManagedBy = []odataID{{managersPath + "/" + server.Name}}
+	}
+
+	return system
+}
+
+func processor(server *metalv1alpha1.Server) *Processor {
+	p := &Processor{
+		resource: resource{
+			ID:         systemsPath + "/" + server.Name + "/Processors/" + processorID,
+			Type:       "#Processor.v1_10_0.Processor",
+			ResourceID: processorID,
+			Name:       "Processor",
+		},
+		ProcessorType: "CPU",
+	}
+
+	if server.Spec.CPU != nil {
+		p.Manufacturer = server.Spec.CPU.Manufacturer
+		p.Model = server.Spec.CPU.Version
+	}
+
+	return p
+}
+
+func ethernetInterface(systemID string, iface metalv1alpha1.NetworkInterface) *EthernetInterface {
+	e := &EthernetInterface{
+		resource: resource{
+			ID:         systemID + "/EthernetInterfaces/" + iface.Name,
+			Type:       "#EthernetInterface.v1_6_0.EthernetInterface",
+			ResourceID: iface.Name,
+			Name:       iface.Name,
+		},
+		MACAddress:          strings.ToUpper(iface.MAC),
+		PermanentMACAddress: strings.ToUpper(iface.MAC),
+		SpeedMbps:           iface.SpeedMbps,
+		LinkStatus:          "LinkDown",
+		Oem: EthernetInterfaceOem{
+			Sidero: SideroEthernetInterface{
+				PCIAddress:      iface.PCIAddress,
+				Driver:          iface.Driver,
+				FirmwareVersion: iface.FirmwareVersion,
+			},
+		},
+	}
+
+	if iface.SpeedMbps > 0 {
+		e.LinkStatus = "LinkUp"
+	}
+
+	if iface.LinkAggregation != nil {
+		e.Oem.Sidero.LinkAggregation = iface.LinkAggregation.Source
+	}
+
+	return e
+}
+
+func storage(systemID string, disks []metalv1alpha1.DiskInformation) *Storage {
+	s := &Storage{
+		resource: resource{
+			ID:         systemID + "/Storage/" + storageID,
+			Type:       "#Storage.v1_9_0.Storage",
+			ResourceID: storageID,
+			Name:       "Storage",
+		},
+		Drives:      make([]odataID, 0, len(disks)),
+		DrivesCount: len(disks),
+		Status: status{
+			State:        "Enabled",
+			Health:       HealthOK,
+			HealthRollup: disksHealth(disks),
+		},
+	}
+
+	for _, disk := range disks {
+		s.Drives = append(s.Drives, odataID{s.ID + "/Drives/" + driveID(disk)})
+	}
+
+	return s
+}
+
+func drive(systemID string, disk metalv1alpha1.DiskInformation) *Drive {
+	d := &Drive{
+		resource: resource{
+			ID:         systemID + "/Storage/" + storageID + "/Drives/" + driveID(disk),
+			Type:       "#Drive.v1_12_0.Drive",
+			ResourceID: driveID(disk),
+			Name:       disk.DeviceName,
+		},
+		Model:            disk.Model,
+		SerialNumber:     disk.Serial,
+		Revision:         disk.FirmwareVersion,
+		CapacityBytes:    disk.Size,
+		FailurePredicted: disk.SMARTHealth == metalv1alpha1.SMARTHealthFailed,
+		Status: status{
+			State: "Enabled",
+		},
+	}
+
+	switch disk.Type {
+	case "hdd":
+		d.MediaType = "HDD"
+	case "ssd":
+		d.MediaType = "SSD"
+	case "nvme":
+		d.MediaType = "SSD"
+		d.Protocol = "NVMe"
+	}
+
+	switch disk.SMARTHealth {
+	case metalv1alpha1.SMARTHealthPassed:
+		d.Status.Health = HealthOK
+	case metalv1alpha1.SMARTHealthFailed:
+		d.Status.Health = HealthCritical
+	}
+
+	return d
+}
+
+func manager(server *metalv1alpha1.Server) *Manager {
+	m := &Manager{
+		resource: resource{
+			ID:         managersPath + "/" + server.Name,
+			Type:       "#Manager.v1_10_0.Manager",
+			ResourceID: server.Name,
+			Name:       "BMC",
+		},
+		ManagerType: "BMC",
+		Links: ManagerLinks{
+			ManagerForServers: []odataID{{systemsPath + "/" + server.Name}},
+		},
+	}
+
+	if server.Status.Inventory != nil {
+		m.FirmwareVersion = server.Status.Inventory.BMCFirmwareVersion
+	}
+
+	return m
+}
+
+// disksHealth is the worst SMART health of the disks.
+func disksHealth(disks []metalv1alpha1.DiskInformation) string {
+	for _, disk := range disks {
+		if disk.SMARTHealth == metalv1alpha1.SMARTHealthFailed {
+			return HealthCritical
+		}
+	}
+
+	return HealthOK
+}
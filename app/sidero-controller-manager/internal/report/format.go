@@ -0,0 +1,184 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package report
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Content types of the report formats.
+const (
+	OpenMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	CSVContentType         = "text/csv; charset=utf-8"
+)
+
+// CSVHeader is the header of the CSV report.
+var CSVHeader = []string{
+	"server",
+	"manufacturer",
+	"product",
+	"serial",
+	"cpu",
+	"bios_version",
+	"accepted",
+	"clean",
+	"allocated",
+	"cluster",
+	"team",
+	"registered",
+	"age_days",
+	"failures",
+}
+
+// WriteCSV writes a row per server.
+func (s *Snapshot) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(CSVHeader); err != nil {
+		return err
+	}
+
+	for _, server := range s.Servers {
+		if err := cw.Write([]string{
+			server.Name,
+			server.Manufacturer,
+			server.ProductName,
+			server.SerialNumber,
+			server.CPU,
+			server.BIOSVersion,
+			strconv.FormatBool(server.Accepted),
+			strconv.FormatBool(server.Clean),
+			strconv.FormatBool(server.Allocated),
+			server.Cluster,
+			server.Team,
+			server.Registered.UTC().Format(time.RFC3339),
+			strconv.Itoa(int(server.Age(s.Timestamp).Hours() / 24)),
+			strconv.Itoa(server.Failures),
+		}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+type sample struct {
+	labels [][2]string
+	value  float64
+}
+
+type family struct {
+	name    string
+	help    string
+	samples []sample
+}
+
+// WriteOpenMetrics writes fleet totals and per-server gauges.
+//
+// All samples carry the snapshot timestamp.
+func (s *Snapshot) WriteOpenMetrics(w io.Writer) error {
+	type hardwareKey struct {
+		manufacturer, product string
+		accepted              bool
+	}
+
+	type allocationKey struct {
+		cluster, team string
+	}
+
+	hardware := map[hardwareKey]int{}
+	allocation := map[allocationKey]int{}
+
+	age := family{
+		name: "sidero_fleet_server_age_seconds",
+		help: "Time since the server was registered.",
+	}
+
+	failures := family{
+		name: "sidero_fleet_server_failures",
+		help: "Number of warning events recorded for the server.",
+	}
+
+	for _, server := range s.Servers {
+		hardware[hardwareKey{server.Manufacturer, server.ProductName, server.Accepted}]++
+
+		if server.Allocated {
+			allocation[allocationKey{server.Cluster, server.Team}]++
+		}
+
+		age.samples = append(age.samples, sample{
+			labels: [][2]string{{"server", server.Name}, {"manufacturer", server.Manufacturer}, {"product", server.ProductName}},
+			value:  server.Age(s.Timestamp).Seconds(),
+		})
+
+		failures.samples = append(failures.samples, sample{
+			labels: [][2]string{{"server", server.Name}},
+			value:  float64(server.Failures),
+		})
+	}
+
+	servers := family{
+		name: "sidero_fleet_servers",
+		help: "Number of servers by hardware model.",
+	}
+
+	for key, count := range hardware {
+		servers.samples = append(servers.samples, sample{
+			labels: [][2]string{{"manufacturer", key.manufacturer}, {"product", key.product}, {"accepted", strconv.FormatBool(key.accepted)}},
+			value:  float64(count),
+		})
+	}
+
+	allocated := family{
+		name: "sidero_fleet_allocated_servers",
+		help: "Number of allocated servers by cluster and team.",
+	}
+
+	for key, count := range allocation {
+		allocated.samples = append(allocated.samples, sample{
+			labels: [][2]string{{"cluster", key.cluster}, {"team", key.team}},
+			value:  float64(count),
+		})
+	}
+
+	bw := bufio.NewWriter(w)
+	timestamp := strconv.FormatInt(s.Timestamp.Unix(), 10)
+
+	for _, f := range []family{servers, allocated, age, failures} {
+		sort.Slice(f.samples, func(i, j int) bool { return labelString(f.samples[i].labels) < labelString(f.samples[j].labels) })
+
+		fmt.Fprintf(bw, "# TYPE %s gauge\n", f.name)
+		fmt.Fprintf(bw, "# HELP %s %s\n", f.name, f.help)
+
+		for _, smpl := range f.samples {
+			fmt.Fprintf(bw, "%s%s %s %s\n", f.name, labelString(smpl.labels), strconv.FormatFloat(smpl.value, 'f', -1, 64), timestamp)
+		}
+	}
+
+	fmt.Fprint(bw, "# EOF\n")
+
+	return bw.Flush()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func labelString(labels [][2]string) string {
+	parts := make([]string, 0, len(labels))
+
+	for _, label := range labels {
+		parts = append(parts, fmt.Sprintf(`%s="%s"`, label[0], labelEscaper.Replace(label[1])))
+	}
+
+	return "{" + strings.Join(parts, ",") + "}"
+}
@@ -0,0 +1,290 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package report_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/report"
+)
+
+var now = time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+
+func fixtures() ([]metalv1alpha1.Server, []infrav1.ServerBinding, []corev1.Event) {
+	servers := []metalv1alpha1.Server{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "server-b",
+				CreationTimestamp: metav1.NewTime(now.Add(-48 * time.Hour)),
+			},
+			Spec: metalv1alpha1.ServerSpec{
+				Accepted: true,
+				SystemInformation: &metalv1alpha1.SystemInformation{
+					Manufacturer: "Dell Inc.",
+					ProductName:  "PowerEdge R640",
+					SerialNumber: "B",
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "server-a",
+				CreationTimestamp: metav1.NewTime(now.Add(-24 * time.Hour)),
+				Labels: map[string]string{
+					"team": "payments",
+				},
+			},
+			Spec: metalv1alpha1.ServerSpec{
+				Accepted: true,
+				SystemInformation: &metalv1alpha1.SystemInformation{
+					Manufacturer: "Dell Inc.",
+					ProductName:  "PowerEdge R640",
+					SerialNumber: "A",
+				},
+				CPU: &metalv1alpha1.CPUInformation{
+					Version: "Intel(R) Xeon(R) Gold 6130",
+				},
+				BIOS: &metalv1alpha1.BIOSInformation{
+					Version: "2.10.2",
+				},
+			},
+			Status: metalv1alpha1.ServerStatus{
+				InUse: true,
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              "server-c",
+				CreationTimestamp: metav1.NewTime(now.Add(-time.Hour)),
+			},
+			Spec: metalv1alpha1.ServerSpec{
+				SystemInformation: &metalv1alpha1.SystemInformation{
+					Manufacturer: "QEMU",
+					ProductName:  `Standard PC "Q35"`,
+				},
+			},
+			Status: metalv1alpha1.ServerStatus{
+				IsClean: true,
+			},
+		},
+	}
+
+	bindings := []infrav1.ServerBinding{
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "server-a",
+				Labels: map[string]string{
+					clusterv1.ClusterLabelName: "prod",
+				},
+			},
+		},
+		{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "server-b",
+				Labels: map[string]string{
+					clusterv1.ClusterLabelName: "prod",
+					"team":                     "search",
+				},
+			},
+		},
+	}
+
+	events := []corev1.Event{
+		{
+			ObjectMeta:     metav1.ObjectMeta{Name: "event-1", Namespace: "default"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Server", Name: "server-b"},
+			Type:           corev1.EventTypeWarning,
+			Count:          3,
+		},
+		{
+			ObjectMeta:     metav1.ObjectMeta{Name: "event-2", Namespace: "default"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Server", Name: "server-b"},
+			Type:           corev1.EventTypeWarning,
+		},
+		{
+			ObjectMeta:     metav1.ObjectMeta{Name: "event-3", Namespace: "default"},
+			InvolvedObject: corev1.ObjectReference{Kind: "Server", Name: "server-a"},
+			Type:           corev1.EventTypeNormal,
+			Count:          5,
+		},
+	}
+
+	return servers, bindings, events
+}
+
+const expectedCSV = `server,manufacturer,product,serial,cpu,bios_version,accepted,clean,allocated,cluster,team,registered,age_days,failures
+server-a,Dell Inc.,PowerEdge R640,A,Intel(R) Xeon(R) Gold 6130,2.10.2,true,false,true,prod,payments,2021-08-31T12:00:00Z,1,0
+server-b,Dell Inc.,PowerEdge R640,B,,,true,false,true,prod,search,2021-08-30T12:00:00Z,2,4
+server-c,QEMU,"Standard PC ""Q35""",,,,false,true,false,,,2021-09-01T11:00:00Z,0,0
+`
+
+const expectedOpenMetrics = `# TYPE sidero_fleet_servers gauge
+# HELP sidero_fleet_servers Number of servers by hardware model.
+sidero_fleet_servers{manufacturer="Dell Inc.",product="PowerEdge R640",accepted="true"} 2 1630497600
+sidero_fleet_servers{manufacturer="QEMU",product="Standard PC \"Q35\"",accepted="false"} 1 1630497600
+# TYPE sidero_fleet_allocated_servers gauge
+# HELP sidero_fleet_allocated_servers Number of allocated servers by cluster and team.
+sidero_fleet_allocated_servers{cluster="prod",team="payments"} 1 1630497600
+sidero_fleet_allocated_servers{cluster="prod",team="search"} 1 1630497600
+# TYPE sidero_fleet_server_age_seconds gauge
+# HELP sidero_fleet_server_age_seconds Time since the server was registered.
+sidero_fleet_server_age_seconds{server="server-a",manufacturer="Dell Inc.",product="PowerEdge R640"} 86400 1630497600
+sidero_fleet_server_age_seconds{server="server-b",manufacturer="Dell Inc.",product="PowerEdge R640"} 172800 1630497600
+sidero_fleet_server_age_seconds{server="server-c",manufacturer="QEMU",product="Standard PC \"Q35\""} 3600 1630497600
+# TYPE sidero_fleet_server_failures gauge
+# HELP sidero_fleet_server_failures Number of warning events recorded for the server.
+sidero_fleet_server_failures{server="server-a"} 0 1630497600
+sidero_fleet_server_failures{server="server-b"} 4 1630497600
+sidero_fleet_server_failures{server="server-c"} 0 1630497600
+# EOF
+`
+
+func TestSnapshot(t *testing.T) {
+	t.Parallel()
+
+	servers, bindings, events := fixtures()
+
+	snapshot := report.Build(now, servers, bindings, events, "team")
+
+	var buf bytes.Buffer
+
+	require.NoError(t, snapshot.WriteCSV(&buf))
+	assert.Equal(t, expectedCSV, buf.String())
+
+	buf.Reset()
+
+	require.NoError(t, snapshot.WriteOpenMetrics(&buf))
+	assert.Equal(t, expectedOpenMetrics, buf.String())
+}
+
+func TestSnapshotNoTeams(t *testing.T) {
+	t.Parallel()
+
+	servers, bindings, events := fixtures()
+
+	snapshot := report.Build(now, servers, bindings, events, "")
+
+	for _, server := range snapshot.Servers {
+		assert.Empty(t, server.Team)
+	}
+}
+
+// fake client ignores field selectors, but Build filters the events anyways.
+func newReader(t *testing.T) client.Reader {
+	scheme := runtime.NewScheme()
+
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+	require.NoError(t, infrav1.AddToScheme(scheme))
+
+	servers, bindings, events := fixtures()
+
+	objects := []runtime.Object{}
+
+	for i := range servers {
+		objects = append(objects, &servers[i])
+	}
+
+	for i := range bindings {
+		objects = append(objects, &bindings[i])
+	}
+
+	for i := range events {
+		objects = append(objects, &events[i])
+	}
+
+	return fake.NewFakeClientWithScheme(scheme, objects...)
+}
+
+func TestReportDirectory(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	r := report.NewReporter(newReader(t), log.NullLogger{}, report.Options{
+		Destination: dir,
+		TeamLabel:   "team",
+	})
+
+	require.NoError(t, r.Report(context.Background(), now))
+
+	csvData, err := os.ReadFile(filepath.Join(dir, "fleet-20210901T120000Z.csv"))
+	require.NoError(t, err)
+	assert.Equal(t, expectedCSV, string(csvData))
+
+	promData, err := os.ReadFile(filepath.Join(dir, "fleet-20210901T120000Z.prom"))
+	require.NoError(t, err)
+	assert.Equal(t, expectedOpenMetrics, string(promData))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	require.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestReportHTTP(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu       sync.Mutex
+		uploaded = map[string]string{}
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+
+			return
+		}
+
+		data, err := io.ReadAll(req.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		mu.Lock()
+		uploaded[req.URL.Path] = req.Header.Get("Content-Type") + "\n" + string(data)
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	r := report.NewReporter(newReader(t), log.NullLogger{}, report.Options{
+		Destination: srv.URL + "/reports/",
+		TeamLabel:   "team",
+	})
+
+	require.NoError(t, r.Report(context.Background(), now))
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assert.Equal(t, report.CSVContentType+"\n"+expectedCSV, uploaded["/reports/fleet-20210901T120000Z.csv"])
+	assert.Equal(t, report.OpenMetricsContentType+"\n"+expectedOpenMetrics, uploaded["/reports/fleet-20210901T120000Z.prom"])
+}
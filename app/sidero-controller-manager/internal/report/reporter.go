@@ -0,0 +1,197 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package report
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// Options configures the reporter.
+type Options struct {
+	// Destination is a local directory or an HTTP(S) URL, reports are uploaded to it with PUT.
+	Destination string
+	// Interval between the snapshots.
+	Interval time.Duration
+	// TeamLabel is the label key to group allocated servers by team.
+	TeamLabel string
+}
+
+// Reporter writes fleet snapshots periodically.
+//
+// Reporter implements manager.Runnable, so that it runs only on the leader.
+type Reporter struct {
+	reader  client.Reader
+	log     logr.Logger
+	options Options
+	client  *http.Client
+}
+
+// NewReporter initializes the reporter.
+//
+// Reader should be uncached (manager API reader) to avoid watching all the events.
+func NewReporter(reader client.Reader, log logr.Logger, options Options) *Reporter {
+	return &Reporter{
+		reader:  reader,
+		log:     log,
+		options: options,
+		client: &http.Client{
+			Timeout: time.Minute,
+		},
+	}
+}
+
+// Start implements manager.Runnable.
+func (r *Reporter) Start(stop <-chan struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	ticker := time.NewTicker(r.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Report(ctx, time.Now()); err != nil {
+			// failing to report shouldn't stop the manager, try again on the next tick
+			r.log.Error(err, "failed to write fleet report")
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// Report takes a snapshot and writes it to the destination.
+func (r *Reporter) Report(ctx context.Context, now time.Time) error {
+	snapshot, err := r.collect(ctx, now)
+	if err != nil {
+		return err
+	}
+
+	var promBuf, csvBuf bytes.Buffer
+
+	if err = snapshot.WriteOpenMetrics(&promBuf); err != nil {
+		return err
+	}
+
+	if err = snapshot.WriteCSV(&csvBuf); err != nil {
+		return err
+	}
+
+	base := "fleet-" + now.UTC().Format("20060102T150405Z")
+
+	for _, file := range []struct {
+		name        string
+		contentType string
+		data        []byte
+	}{
+		{base + ".prom", OpenMetricsContentType, promBuf.Bytes()},
+		{base + ".csv", CSVContentType, csvBuf.Bytes()},
+	} {
+		if err = r.write(ctx, file.name, file.contentType, file.data); err != nil {
+			return fmt.Errorf("error writing %q: %w", file.name, err)
+		}
+	}
+
+	r.log.Info("fleet report written", "destination", r.options.Destination, "name", base, "servers", len(snapshot.Servers))
+
+	return nil
+}
+
+func (r *Reporter) collect(ctx context.Context, now time.Time) (*Snapshot, error) {
+	var servers metalv1alpha1.ServerList
+
+	if err := r.reader.List(ctx, &servers); err != nil {
+		return nil, err
+	}
+
+	var bindings infrav1.ServerBindingList
+
+	if err := r.reader.List(ctx, &bindings); err != nil {
+		return nil, err
+	}
+
+	var events corev1.EventList
+
+	if err := r.reader.List(ctx, &events, client.MatchingFields{
+		"involvedObject.kind": "Server",
+		"type":                corev1.EventTypeWarning,
+	}); err != nil {
+		return nil, err
+	}
+
+	return Build(now, servers.Items, bindings.Items, events.Items, r.options.TeamLabel), nil
+}
+
+func (r *Reporter) write(ctx context.Context, name, contentType string, data []byte) error {
+	destination := r.options.Destination
+
+	if !strings.HasPrefix(destination, "http://") && !strings.HasPrefix(destination, "https://") {
+		return writeFile(filepath.Join(destination, name), data)
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return err
+	}
+
+	u.Path = path.Join(u.Path, name)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", contentType)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	io.Copy(io.Discard, resp.Body) //nolint:errcheck
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// writeFile writes the file atomically, so that partially written reports are never picked up.
+func writeFile(filename string, data []byte) error {
+	tmp := filename + ".tmp"
+
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filename)
+}
@@ -0,0 +1,128 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package report implements periodic fleet snapshots for capacity and finance reporting.
+//
+// Snapshot lists every Server with its hardware, allocation (cluster and team) and failure count,
+// and is exported both as OpenMetrics (with sample timestamps, so that the files can be backfilled
+// into Prometheus) and as CSV.
+package report
+
+import (
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// Server is a single server record in the snapshot.
+type Server struct {
+	Name         string
+	Manufacturer string
+	ProductName  string
+	SerialNumber string
+	CPU          string
+	BIOSVersion  string
+
+	Accepted  bool
+	Clean     bool
+	Allocated bool
+	Cluster   string
+	Team      string
+
+	Registered time.Time
+	// Failures is the number of warning events recorded for the server.
+	Failures int
+}
+
+// Age of the server (time since registration) at the snapshot time.
+func (s *Server) Age(now time.Time) time.Duration {
+	return now.Sub(s.Registered)
+}
+
+// Snapshot of the fleet.
+type Snapshot struct {
+	Timestamp time.Time
+	Servers   []Server
+}
+
+// Build the snapshot from the resources.
+//
+// Cluster comes from the ServerBinding labels (copied from the MetalMachine), team comes from the
+// teamLabel on the Server, falling back to the ServerBinding; empty teamLabel disables teams.
+func Build(now time.Time, servers []metalv1alpha1.Server, bindings []infrav1.ServerBinding, events []corev1.Event, teamLabel string) *Snapshot {
+	bindingsByName := make(map[string]*infrav1.ServerBinding, len(bindings))
+
+	for i := range bindings {
+		bindingsByName[bindings[i].Name] = &bindings[i]
+	}
+
+	failures := map[string]int{}
+
+	for _, event := range events {
+		if event.Type != corev1.EventTypeWarning || event.InvolvedObject.Kind != "Server" {
+			continue
+		}
+
+		count := int(event.Count)
+		if count == 0 {
+			count = 1
+		}
+
+		failures[event.InvolvedObject.Name] += count
+	}
+
+	snapshot := &Snapshot{
+		Timestamp: now,
+		Servers:   make([]Server, 0, len(servers)),
+	}
+
+	for _, server := range servers {
+		record := Server{
+			Name:       server.Name,
+			Accepted:   server.Spec.Accepted,
+			Clean:      server.Status.IsClean,
+			Allocated:  server.Status.InUse,
+			Registered: server.CreationTimestamp.Time,
+			Failures:   failures[server.Name],
+		}
+
+		if sysInfo := server.Spec.SystemInformation; sysInfo != nil {
+			record.Manufacturer = sysInfo.Manufacturer
+			record.ProductName = sysInfo.ProductName
+			record.SerialNumber = sysInfo.SerialNumber
+		}
+
+		if server.Spec.CPU != nil {
+			record.CPU = server.Spec.CPU.Version
+		}
+
+		if server.Spec.BIOS != nil {
+			record.BIOSVersion = server.Spec.BIOS.Version
+		}
+
+		if teamLabel != "" {
+			record.Team = server.Labels[teamLabel]
+		}
+
+		if binding, ok := bindingsByName[server.Name]; ok {
+			record.Allocated = true
+			record.Cluster = binding.Labels[clusterv1.ClusterLabelName]
+
+			if record.Team == "" && teamLabel != "" {
+				record.Team = binding.Labels[teamLabel]
+			}
+		}
+
+		snapshot.Servers = append(snapshot.Servers, record)
+	}
+
+	sort.Slice(snapshot.Servers, func(i, j int) bool { return snapshot.Servers[i].Name < snapshot.Servers[j].Name })
+
+	return snapshot
+}
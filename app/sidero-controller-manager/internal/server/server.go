@@ -6,11 +6,19 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"reflect"
+	"sort"
+	"strings"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -23,17 +31,32 @@ import (
 	"sigs.k8s.io/cluster-api/util/patch"
 	controllerclient "sigs.k8s.io/controller-runtime/pkg/client"
 
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
 	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
 	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/api"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/identity"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/metrics"
 	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/hwdiff"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/wipecert"
 )
 
 type server struct {
 	api.UnimplementedAgentServer
 
-	autoAccept   bool
-	insecureWipe bool
-	autoBMC      bool
+	autoAccept       bool
+	insecureWipe     bool
+	autoBMC          bool
+	wipeConfirmation bool
+	dryRun           bool
+
+	identityWebhook *identity.Webhook
+
+	// requiredApprovals enables the approval gate, servers are accepted with the ApproveAction only then.
+	requiredApprovals int
+
+	// namespace of the controller keeps the wipe certificates, the signing key and the hardware revisions.
+	namespace string
 
 	c             controllerclient.Client
 	scheme        *runtime.Scheme
@@ -43,58 +66,87 @@ type server struct {
 
 // CreateServer implements api.AgentServer.
 func (s *server) CreateServer(ctx context.Context, in *api.CreateServerRequest) (*api.CreateServerResponse, error) {
-	obj := &metalv1alpha1.Server{}
-
-	if err := s.c.Get(ctx, types.NamespacedName{Name: in.GetSystemInformation().GetUuid()}, obj); err != nil {
+	obj, err := metalv1alpha1.LookupServer(ctx, s.c, in.GetSystemInformation().GetUuid())
+	if err != nil {
 		if !apierrors.IsNotFound(err) {
 			return nil, err
 		}
 
-		obj = &metalv1alpha1.Server{
-			TypeMeta: metav1.TypeMeta{
-				Kind:       "Server",
-				APIVersion: metalv1alpha1.GroupVersion.Version,
-			},
-			ObjectMeta: metav1.ObjectMeta{
-				Name: in.GetSystemInformation().GetUuid(),
-			},
-			Spec: metalv1alpha1.ServerSpec{
-				Hostname: in.GetHostname(),
-				SystemInformation: &metalv1alpha1.SystemInformation{
-					Manufacturer: in.GetSystemInformation().GetManufacturer(),
-					ProductName:  in.GetSystemInformation().GetProductName(),
-					Version:      in.GetSystemInformation().GetVersion(),
-					SerialNumber: in.GetSystemInformation().GetSerialNumber(),
-					SKUNumber:    in.GetSystemInformation().GetSkuNumber(),
-					Family:       in.GetSystemInformation().GetFamily(),
-				},
-				CPU: &metalv1alpha1.CPUInformation{
-					Manufacturer: in.GetCpu().GetManufacturer(),
-					Version:      in.GetCpu().GetVersion(),
-				},
-				Accepted: s.autoAccept,
-			},
+		review, err := s.reviewIdentity(ctx, in)
+		if err != nil {
+			return nil, err
 		}
 
-		if err = s.c.Create(ctx, obj); err != nil {
-			return nil, err
+		if review.Decision == identity.DecisionReject {
+			log.Printf("Registration of %s is rejected by the identity webhook: %s", in.GetSystemInformation().GetUuid(), review.Reason)
+
+			return nil, status.Errorf(codes.PermissionDenied, "registration is rejected: %s", review.Reason)
 		}
 
-		ref, err := reference.GetReference(s.scheme, obj)
+		if review.ServerName != "" {
+			obj, err = s.mergeServer(ctx, in, review)
+		} else {
+			obj, err = s.registerServer(ctx, in, review)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	} else if bios := biosInformation(in.GetBios()); bios != nil && !reflect.DeepEqual(bios, obj.Spec.BIOS) {
+		// firmware might have been upgraded since the server was registered
+		patchHelper, err := patch.NewHelper(obj, s.c)
 		if err != nil {
 			return nil, err
 		}
 
-		s.recorder.Event(ref, corev1.EventTypeNormal, "Server Registration", "Server auto-registered via API.")
+		obj.Spec.BIOS = bios
+
+		if err = patchHelper.Patch(ctx, obj); err != nil {
+			return nil, err
+		}
 
-		log.Printf("Added %s", in.GetSystemInformation().GetUuid())
+		log.Printf("Updated BIOS information for %s", obj.Name)
 	}
 
 	resp := &api.CreateServerResponse{}
 
+	freeze, err := metalv1alpha1.ActiveFreeze(ctx, s.c)
+	if err != nil {
+		return nil, err
+	}
+
+	if freeze != nil {
+		log.Printf("Provisioning is frozen by %q, skipping BMC setup and wipe of %q", freeze.Name, obj.Name)
+	}
+
+	allocated, err := s.allocated(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	// allocated server is already installed, so it booted into the agent unexpectedly (e.g. the boot order was changed,
+	// or the ServerBinding was missing while the management plane was restored):
+	// disks are never wiped, and the agent waits for the boot from disk to be requested
+	if allocated {
+		if err = s.markUnexpectedBoot(ctx, obj); err != nil {
+			return nil, err
+		}
+
+		resp.UnexpectedBoot = true
+
+		return resp, nil
+	}
+
+	// acceptance is reverted by the server reconciler, which might not have run yet
+	approved := s.requiredApprovals == 0 || obj.Status.Approval.Approved()
+
+	if obj.Spec.Accepted && !approved {
+		log.Printf("Server %q is accepted, but didn't pass the approval gate, skipping BMC setup and wipe", obj.Name)
+	}
+
 	// Make BMC and wiping decisions only if server is accepted
 	// to avoid hijacking random devices that PXE boot against us.
-	if obj.Spec.Accepted {
+	if obj.Spec.Accepted && approved && freeze == nil {
 		// Respond to agent whether it should attempt bmc setup
 		// We will only tell it to attempt autoconfig if there's not already data there.
 		if obj.Spec.BMC == nil && s.autoBMC {
@@ -108,20 +160,459 @@ func (s *server) CreateServer(ctx context.Context, in *api.CreateServerRequest)
 		if !obj.Status.IsClean {
 			log.Printf("Server %q needs wipe", obj.Name)
 
+			policy, err := s.resolveWipePolicy(ctx, obj)
+			if err != nil {
+				return nil, err
+			}
+
 			resp.Wipe = true
 			resp.InsecureWipe = s.insecureWipe
 			resp.RebootTimeout = s.rebootTimeout.Seconds()
+
+			resp.WipePolicy = apiWipePolicy(policy)
+			resp.WipeConfirmationRequired = !s.wipeConfirmed(obj, policy)
+		}
+	}
+
+	if s.dryRun && (resp.SetupBmc || resp.Wipe) {
+		if err = s.reportDryRun(obj, fmt.Sprintf("Server would be instructed to set up BMC (%t) and wipe (%t).", resp.SetupBmc, resp.Wipe)); err != nil {
+			return nil, err
 		}
+
+		return &api.CreateServerResponse{}, nil
 	}
 
 	return resp, nil
 }
 
-// MarkServerAsWiped implements api.AgentServer.
-func (s *server) MarkServerAsWiped(ctx context.Context, in *api.MarkServerAsWipedRequest) (*api.MarkServerAsWipedResponse, error) {
+// reportDryRun reports the instruction to the agent skipped in the dry-run mode.
+func (s *server) reportDryRun(obj *metalv1alpha1.Server, message string) error {
+	ref, err := reference.GetReference(s.scheme, obj)
+	if err != nil {
+		return err
+	}
+
+	s.recorder.Event(ref, corev1.EventTypeNormal, "Dry Run", message)
+
+	log.Printf("Dry run: %q: %s", obj.Name, message)
+
+	return nil
+}
+
+// allocated checks whether the server is allocated to a MetalMachine.
+func (s *server) allocated(ctx context.Context, obj *metalv1alpha1.Server) (bool, error) {
+	if obj.Status.InUse {
+		return true, nil
+	}
+
+	var serverBinding infrav1.ServerBinding
+
+	err := s.c.Get(ctx, types.NamespacedName{Name: obj.Name}, &serverBinding)
+	if err == nil {
+		return true, nil
+	}
+
+	return false, controllerclient.IgnoreNotFound(err)
+}
+
+// markUnexpectedBoot sets the UnexpectedAgentBoot condition and notifies about the unexpected boot.
+func (s *server) markUnexpectedBoot(ctx context.Context, obj *metalv1alpha1.Server) error {
+	log.Printf("Server %q is allocated, but booted into the agent, keeping the disks untouched", obj.Name)
+
+	patchHelper, err := patch.NewHelper(obj, s.c)
+	if err != nil {
+		return err
+	}
+
+	conditions.Set(obj, &clusterv1.Condition{
+		Type:    metalv1alpha1.ConditionUnexpectedAgentBoot,
+		Status:  corev1.ConditionTrue,
+		Reason:  metalv1alpha1.AgentBootedReason,
+		Message: "Allocated server booted into the agent, disks are not wiped.",
+	})
+
+	if err = patchHelper.Patch(ctx, obj, patch.WithOwnedConditions{
+		Conditions: []clusterv1.ConditionType{metalv1alpha1.ConditionUnexpectedAgentBoot},
+	}); err != nil {
+		return err
+	}
+
+	metrics.UnexpectedAgentBoots.Inc()
+
+	ref, err := reference.GetReference(s.scheme, obj)
+	if err != nil {
+		return err
+	}
+
+	s.recorder.Event(ref, corev1.EventTypeWarning, "Server Agent Boot",
+		fmt.Sprintf("Allocated server booted into the agent unexpectedly, disks are not wiped. Annotate the server with %q to boot it back from disk.", metalv1alpha1.ServerBootFromDiskAnnotation))
+
+	return nil
+}
+
+// wipePolicyConflictError is returned when the wipe policy of the server can't be resolved.
+type wipePolicyConflictError struct {
+	serverClasses []string
+}
+
+func (err *wipePolicyConflictError) Error() string {
+	return fmt.Sprintf("matching ServerClasses %s have different wipe policies", strings.Join(err.serverClasses, ", "))
+}
+
+// wipePolicy resolves the wipe policy for the server.
+//
+// Server wipe policy takes precedence, then the policy of the ServerClass the server was allocated from.
+// Otherwise the policy of the matching ServerClasses (ignoring the classes without a policy) is used if they agree on it,
+// and *wipePolicyConflictError is returned if they don't.
+// If no policy is found, nil is returned, and the agent wipes all the disks.
+func (s *server) wipePolicy(ctx context.Context, obj *metalv1alpha1.Server) (*metalv1alpha1.WipePolicy, error) {
+	if obj.Spec.WipePolicy != nil {
+		return obj.Spec.WipePolicy, nil
+	}
+
+	if obj.Status.ServerClass != "" {
+		var serverClass metalv1alpha1.ServerClass
+
+		err := s.c.Get(ctx, types.NamespacedName{Name: obj.Status.ServerClass}, &serverClass)
+		if err == nil {
+			return serverClass.Spec.WipePolicy, nil
+		}
+
+		// policy of the deleted class is resolved from the matching classes
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	serverClasses := &metalv1alpha1.ServerClassList{}
+
+	if err := s.c.List(ctx, serverClasses); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(serverClasses.Items, func(i, j int) bool { return serverClasses.Items[i].Name < serverClasses.Items[j].Name })
+
+	var (
+		policy  *metalv1alpha1.WipePolicy
+		matched []string
+		agree   = true
+	)
+
+	for _, serverClass := range serverClasses.Items {
+		if serverClass.Spec.WipePolicy == nil {
+			continue
+		}
+
+		matches, err := metalv1alpha1.FilterServers([]metalv1alpha1.Server{*obj},
+			serverClass.SelectorFilter(),
+			serverClass.QualifiersFilter(),
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(matches) == 0 {
+			continue
+		}
+
+		if policy == nil {
+			policy = serverClass.Spec.WipePolicy
+		} else if !reflect.DeepEqual(policy, serverClass.Spec.WipePolicy) {
+			agree = false
+		}
+
+		matched = append(matched, serverClass.Name)
+	}
+
+	if !agree {
+		return nil, &wipePolicyConflictError{serverClasses: matched}
+	}
+
+	return policy, nil
+}
+
+// resolveWipePolicy resolves the wipe policy for the server to be wiped.
+//
+// Server is not wiped while the wipe policy can't be resolved, the reason is reported with the WipePolicyResolved condition.
+func (s *server) resolveWipePolicy(ctx context.Context, obj *metalv1alpha1.Server) (*metalv1alpha1.WipePolicy, error) {
+	policy, err := s.wipePolicy(ctx, obj)
+
+	var conflict *wipePolicyConflictError
+
+	if err != nil && !errors.As(err, &conflict) {
+		return nil, err
+	}
+
+	if conflict == nil && conditions.Get(obj, metalv1alpha1.ConditionWipePolicyResolved) == nil {
+		return policy, nil
+	}
+
+	reported := conditions.IsFalse(obj, metalv1alpha1.ConditionWipePolicyResolved) && conflict != nil &&
+		conditions.GetMessage(obj, metalv1alpha1.ConditionWipePolicyResolved) == conflict.Error()
+
+	patchHelper, err := patch.NewHelper(obj, s.c)
+	if err != nil {
+		return nil, err
+	}
+
+	if conflict != nil {
+		conditions.MarkFalse(obj, metalv1alpha1.ConditionWipePolicyResolved, metalv1alpha1.ConflictingWipePoliciesReason, clusterv1.ConditionSeverityWarning, "%s", conflict.Error())
+	} else {
+		conditions.Delete(obj, metalv1alpha1.ConditionWipePolicyResolved)
+	}
+
+	if err = patchHelper.Patch(ctx, obj, patch.WithOwnedConditions{
+		Conditions: []clusterv1.ConditionType{metalv1alpha1.ConditionWipePolicyResolved},
+	}); err != nil {
+		return nil, err
+	}
+
+	if conflict == nil {
+		return policy, nil
+	}
+
+	if !reported {
+		ref, err := reference.GetReference(s.scheme, obj)
+		if err != nil {
+			return nil, err
+		}
+
+		s.recorder.Event(ref, corev1.EventTypeWarning, "Server Wipe",
+			fmt.Sprintf("Server is not wiped: %s. Set the wipe policy of the server.", conflict))
+	}
+
+	log.Printf("Server %q is not wiped: %s", obj.Name, conflict)
+
+	return nil, status.Errorf(codes.FailedPrecondition, "server is not wiped: %s", conflict)
+}
+
+// wipeConfirmed checks whether the disks with the existing data can be wiped without waiting for the confirmation.
+func (s *server) wipeConfirmed(obj *metalv1alpha1.Server, policy *metalv1alpha1.WipePolicy) bool {
+	return !s.wipeConfirmation || obj.WipeConfirmed(policy)
+}
+
+// apiWipePolicy converts the wipe policy for the agent.
+func apiWipePolicy(policy *metalv1alpha1.WipePolicy) *api.WipePolicy {
+	if policy == nil {
+		return nil
+	}
+
+	diskSelectors := func(selectors []metalv1alpha1.DiskSelector) []*api.DiskSelector {
+		result := make([]*api.DiskSelector, 0, len(selectors))
+
+		for _, selector := range selectors {
+			result = append(result, &api.DiskSelector{
+				DeviceName: selector.DeviceName,
+				Model:      selector.Model,
+				Serial:     selector.Serial,
+			})
+		}
+
+		return result
+	}
+
+	return &api.WipePolicy{
+		Mode:    string(policy.Mode),
+		Include: diskSelectors(policy.Include),
+		Exclude: diskSelectors(policy.Exclude),
+	}
+}
+
+// registerServer creates the server for the unknown machine.
+func (s *server) registerServer(ctx context.Context, in *api.CreateServerRequest, review *identity.Response) (*metalv1alpha1.Server, error) {
+	obj := &metalv1alpha1.Server{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Server",
+			APIVersion: metalv1alpha1.GroupVersion.Version,
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   in.GetSystemInformation().GetUuid(),
+			Labels: review.Labels,
+		},
+		Spec: metalv1alpha1.ServerSpec{
+			Hostname:          in.GetHostname(),
+			SystemInformation: systemInformation(in.GetSystemInformation()),
+			CPU:               cpuInformation(in.GetCpu()),
+			BIOS:              biosInformation(in.GetBios()),
+			Accepted:          s.autoAccept || s.acceptedByWebhook(in, review),
+		},
+	}
+
+	// record the address for the acceptance policies
+	if address := peerAddress(ctx); address != "" {
+		obj.Annotations = map[string]string{
+			metalv1alpha1.ServerRegistrationAddressAnnotation: address,
+		}
+	}
+
+	if err := s.c.Create(ctx, obj); err != nil {
+		return nil, err
+	}
+
+	ref, err := reference.GetReference(s.scheme, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recorder.Event(ref, corev1.EventTypeNormal, "Server Registration", "Server auto-registered via API.")
+
+	log.Printf("Added %s", in.GetSystemInformation().GetUuid())
+
+	return obj, nil
+}
+
+// mergeServer merges the unknown machine into the existing server picked by the identity webhook,
+// e.g. when the motherboard of the server was replaced.
+func (s *server) mergeServer(ctx context.Context, in *api.CreateServerRequest, review *identity.Response) (*metalv1alpha1.Server, error) {
 	obj := &metalv1alpha1.Server{}
 
-	if err := s.c.Get(ctx, types.NamespacedName{Name: in.GetUuid()}, obj); err != nil {
+	if err := s.c.Get(ctx, types.NamespacedName{Name: review.ServerName}, obj); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, status.Errorf(codes.FailedPrecondition, "server %q picked by the identity webhook is not found", review.ServerName)
+		}
+
+		return nil, err
+	}
+
+	// hardware information of the server allocated to a MetalMachine describes the running machine
+	allocated, err := s.allocated(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	if allocated {
+		return nil, status.Errorf(codes.FailedPrecondition, "server %q picked by the identity webhook is allocated", review.ServerName)
+	}
+
+	patchHelper, err := patch.NewHelper(obj, s.c)
+	if err != nil {
+		return nil, err
+	}
+
+	if obj.Labels == nil {
+		obj.Labels = map[string]string{}
+	}
+
+	for key, value := range review.Labels {
+		obj.Labels[key] = value
+	}
+
+	obj.Labels[metalv1alpha1.ServerUUIDLabel] = in.GetSystemInformation().GetUuid()
+
+	if address := peerAddress(ctx); address != "" {
+		if obj.Annotations == nil {
+			obj.Annotations = map[string]string{}
+		}
+
+		obj.Annotations[metalv1alpha1.ServerRegistrationAddressAnnotation] = address
+	}
+
+	// hardware information is of the new machine
+	obj.Spec.SystemInformation = systemInformation(in.GetSystemInformation())
+	obj.Spec.CPU = cpuInformation(in.GetCpu())
+	obj.Spec.BIOS = biosInformation(in.GetBios())
+
+	if s.acceptedByWebhook(in, review) {
+		obj.Spec.Accepted = true
+	}
+
+	if err = patchHelper.Patch(ctx, obj); err != nil {
+		return nil, err
+	}
+
+	ref, err := reference.GetReference(s.scheme, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recorder.Eventf(ref, corev1.EventTypeNormal, "Server Registration", "Machine %s merged into the server by the identity webhook.", in.GetSystemInformation().GetUuid())
+
+	log.Printf("Merged %s into %s", in.GetSystemInformation().GetUuid(), obj.Name)
+
+	return obj, nil
+}
+
+// acceptedByWebhook checks whether the machine is accepted by the identity webhook decision.
+//
+// With the approval gate enabled, the servers are accepted with the ApproveAction only, so the decision is ignored.
+func (s *server) acceptedByWebhook(in *api.CreateServerRequest, review *identity.Response) bool {
+	if review.Decision != identity.DecisionAccept {
+		return false
+	}
+
+	if s.requiredApprovals > 0 {
+		log.Printf("Ignoring identity webhook acceptance of %s: servers require %d approvals", in.GetSystemInformation().GetUuid(), s.requiredApprovals)
+
+		return false
+	}
+
+	return true
+}
+
+// reviewIdentity consults the identity webhook about the unknown machine.
+func (s *server) reviewIdentity(ctx context.Context, in *api.CreateServerRequest) (*identity.Response, error) {
+	if s.identityWebhook == nil {
+		return &identity.Response{}, nil
+	}
+
+	review, err := s.identityWebhook.Review(ctx, &identity.Request{
+		UUID:              in.GetSystemInformation().GetUuid(),
+		Hostname:          in.GetHostname(),
+		Address:           peerAddress(ctx),
+		SystemInformation: systemInformation(in.GetSystemInformation()),
+		CPU:               cpuInformation(in.GetCpu()),
+		BIOS:              biosInformation(in.GetBios()),
+		MACAddresses:      in.GetMacAddresses(),
+	})
+	if err != nil {
+		if s.identityWebhook.FailurePolicy == identity.FailurePolicyIgnore {
+			log.Printf("Ignoring identity webhook failure for %s: %s", in.GetSystemInformation().GetUuid(), err)
+
+			return &identity.Response{}, nil
+		}
+
+		// agent retries the registration
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	return review, nil
+}
+
+func systemInformation(in *api.SystemInformation) *metalv1alpha1.SystemInformation {
+	return &metalv1alpha1.SystemInformation{
+		Manufacturer: in.GetManufacturer(),
+		ProductName:  in.GetProductName(),
+		Version:      in.GetVersion(),
+		SerialNumber: in.GetSerialNumber(),
+		SKUNumber:    in.GetSkuNumber(),
+		Family:       in.GetFamily(),
+	}
+}
+
+func cpuInformation(in *api.CPU) *metalv1alpha1.CPUInformation {
+	return &metalv1alpha1.CPUInformation{
+		Manufacturer: in.GetManufacturer(),
+		Version:      in.GetVersion(),
+	}
+}
+
+// biosInformation converts BIOS information sent by the agent, older agents don't send it.
+func biosInformation(in *api.BIOSInformation) *metalv1alpha1.BIOSInformation {
+	if in == nil {
+		return nil
+	}
+
+	return &metalv1alpha1.BIOSInformation{
+		Vendor:      in.GetVendor(),
+		Version:     in.GetVersion(),
+		ReleaseDate: in.GetReleaseDate(),
+	}
+}
+
+// MarkServerAsWiped implements api.AgentServer.
+func (s *server) MarkServerAsWiped(ctx context.Context, in *api.MarkServerAsWipedRequest) (*api.MarkServerAsWipedResponse, error) {
+	obj, err := metalv1alpha1.LookupServer(ctx, s.c, in.GetUuid())
+	if err != nil {
 		return nil, err
 	}
 
@@ -131,11 +622,25 @@ func (s *server) MarkServerAsWiped(ctx context.Context, in *api.MarkServerAsWipe
 	}
 
 	obj.Status.IsClean = true
+	obj.Status.WipeSkippedDisks = nil
+	obj.Status.ServerClass = ""
+	obj.Status.Released = false
+
+	for _, disk := range in.GetSkippedDisks() {
+		obj.Status.WipeSkippedDisks = append(obj.Status.WipeSkippedDisks, metalv1alpha1.SkippedDisk{
+			DeviceName: disk.GetDeviceName(),
+			Reason:     disk.GetReason(),
+		})
+	}
 
 	conditions.MarkTrue(obj, metalv1alpha1.ConditionPowerCycle)
 
+	// wipe confirmation is valid only for a single wipe
+	delete(obj.Annotations, metalv1alpha1.ServerConfirmWipeAnnotation)
+	conditions.Delete(obj, metalv1alpha1.ConditionWipeConfirmed)
+
 	if err := patchHelper.Patch(ctx, obj, patch.WithOwnedConditions{
-		Conditions: []clusterv1.ConditionType{metalv1alpha1.ConditionPowerCycle},
+		Conditions: []clusterv1.ConditionType{metalv1alpha1.ConditionPowerCycle, metalv1alpha1.ConditionWipeConfirmed},
 	}); err != nil {
 		return nil, err
 	}
@@ -145,18 +650,92 @@ func (s *server) MarkServerAsWiped(ctx context.Context, in *api.MarkServerAsWipe
 		return nil, err
 	}
 
-	s.recorder.Event(ref, corev1.EventTypeNormal, "Server Wipe", "Server wiped via agent.")
+	if len(obj.Status.WipeSkippedDisks) > 0 {
+		s.recorder.Event(ref, corev1.EventTypeNormal, "Server Wipe", fmt.Sprintf("Server wiped via agent, %d disk(s) skipped.", len(obj.Status.WipeSkippedDisks)))
+	} else {
+		s.recorder.Event(ref, corev1.EventTypeNormal, "Server Wipe", "Server wiped via agent.")
+	}
+
+	// older agents don't report wipe duration
+	if in.GetWipeDuration() > 0 {
+		metrics.WipeDuration.Observe(in.GetWipeDuration())
+	}
+
+	// older agents don't report wiped disks, so there is nothing to certify
+	if len(in.GetWipedDisks()) > 0 {
+		cm, err := wipecert.Issue(ctx, s.c, s.namespace, WipeCertificate(obj, in, time.Now()))
+		if err != nil {
+			// the wipe itself succeeded, so the server is not held back by the certificate
+			log.Printf("failed to issue wipe certificate for %s: %s", obj.Name, err)
+
+			s.recorder.Event(ref, corev1.EventTypeWarning, "Server Wipe", fmt.Sprintf("Failed to issue wipe certificate: %s.", err))
+		} else {
+			s.recorder.Event(ref, corev1.EventTypeNormal, "Server Wipe", fmt.Sprintf("Wipe certificate issued as ConfigMap %s/%s.", cm.Namespace, cm.Name))
+		}
+	}
 
 	resp := &api.MarkServerAsWipedResponse{}
 
 	return resp, nil
 }
 
+// WipeCertificate builds the certificate of destruction from the wipe reported by the agent.
+func WipeCertificate(obj *metalv1alpha1.Server, in *api.MarkServerAsWipedRequest, now time.Time) *wipecert.Certificate {
+	cert := &wipecert.Certificate{
+		Server: wipecert.Server{
+			UUID: obj.Name,
+		},
+		Operator: obj.Annotations[wipecert.OperatorAnnotation],
+		IssuedAt: now.UTC(),
+	}
+
+	if cert.Operator == "" {
+		cert.Operator = wipecert.DefaultOperator
+	}
+
+	if obj.Spec.SystemInformation != nil {
+		cert.Server.Manufacturer = obj.Spec.SystemInformation.Manufacturer
+		cert.Server.ProductName = obj.Spec.SystemInformation.ProductName
+		cert.Server.SerialNumber = obj.Spec.SystemInformation.SerialNumber
+	}
+
+	for _, disk := range in.GetWipedDisks() {
+		d := wipecert.Disk{
+			DeviceName:  disk.GetDeviceName(),
+			Model:       disk.GetModel(),
+			Serial:      disk.GetSerial(),
+			Size:        disk.GetSize(),
+			Method:      disk.GetMethod(),
+			Verified:    disk.GetVerified(),
+			StartedAt:   time.Unix(disk.GetStartedAt(), 0).UTC(),
+			CompletedAt: time.Unix(disk.GetCompletedAt(), 0).UTC(),
+		}
+
+		if cert.StartedAt.IsZero() || d.StartedAt.Before(cert.StartedAt) {
+			cert.StartedAt = d.StartedAt
+		}
+
+		if d.CompletedAt.After(cert.CompletedAt) {
+			cert.CompletedAt = d.CompletedAt
+		}
+
+		cert.Disks = append(cert.Disks, d)
+	}
+
+	for _, disk := range in.GetSkippedDisks() {
+		cert.SkippedDisks = append(cert.SkippedDisks, wipecert.SkippedDisk{
+			DeviceName: disk.GetDeviceName(),
+			Reason:     disk.GetReason(),
+		})
+	}
+
+	return cert
+}
+
 // ReconcileServerAddresses implements api.AgentServer.
 func (s *server) ReconcileServerAddresses(ctx context.Context, in *api.ReconcileServerAddressesRequest) (*api.ReconcileServerAddressesResponse, error) {
-	obj := &metalv1alpha1.Server{}
-
-	if err := s.c.Get(ctx, types.NamespacedName{Name: in.GetUuid()}, obj); err != nil {
+	obj, err := metalv1alpha1.LookupServer(ctx, s.c, in.GetUuid())
+	if err != nil {
 		return nil, err
 	}
 
@@ -240,12 +819,16 @@ func (s *server) ReconcileServerAddresses(ctx context.Context, in *api.Reconcile
 
 // Heartbeat implements api.AgentServer.
 func (s *server) Heartbeat(ctx context.Context, in *api.HeartbeatRequest) (*api.HeartbeatResponse, error) {
-	obj := &metalv1alpha1.Server{}
-
-	if err := s.c.Get(ctx, types.NamespacedName{Name: in.GetUuid()}, obj); err != nil {
+	obj, err := metalv1alpha1.LookupServer(ctx, s.c, in.GetUuid())
+	if err != nil {
 		return nil, err
 	}
 
+	// agent of the unexpectedly booted server doesn't wipe the disks, it waits for the boot from disk to be requested
+	if conditions.IsTrue(obj, metalv1alpha1.ConditionUnexpectedAgentBoot) {
+		return s.bootFromDisk(ctx, obj)
+	}
+
 	patchHelper, err := patch.NewHelper(obj, s.c)
 	if err != nil {
 		return nil, err
@@ -263,16 +846,117 @@ func (s *server) Heartbeat(ctx context.Context, in *api.HeartbeatRequest) (*api.
 
 	resp := &api.HeartbeatResponse{}
 
+	// agent waiting for the wipe confirmation polls for it with the heartbeats
+	if conditions.IsFalse(obj, metalv1alpha1.ConditionWipeConfirmed) {
+		policy, err := s.wipePolicy(ctx, obj)
+		if err != nil {
+			return nil, err
+		}
+
+		resp.WipeConfirmed = s.wipeConfirmed(obj, policy)
+	}
+
 	return resp, nil
 }
 
+// RequestWipeConfirmation implements api.AgentServer.
+//
+// Agent requests the confirmation when the disks to be wiped have the existing data, e.g. when the server UUID
+// got mixed up with another server, the wipe would destroy the data of the server which was never released.
+func (s *server) RequestWipeConfirmation(ctx context.Context, in *api.RequestWipeConfirmationRequest) (*api.RequestWipeConfirmationResponse, error) {
+	obj, err := metalv1alpha1.LookupServer(ctx, s.c, in.GetUuid())
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := s.wipePolicy(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.wipeConfirmed(obj, policy) {
+		return &api.RequestWipeConfirmationResponse{Confirmed: true}, nil
+	}
+
+	disks := make([]string, 0, len(in.GetDisks()))
+
+	for _, disk := range in.GetDisks() {
+		disks = append(disks, fmt.Sprintf("%s (%s)", disk.GetDeviceName(), strings.Join(disk.GetSignatures(), ", ")))
+	}
+
+	message := fmt.Sprintf("Existing data found on %s.", strings.Join(disks, ", "))
+
+	waiting := conditions.IsFalse(obj, metalv1alpha1.ConditionWipeConfirmed) && conditions.GetMessage(obj, metalv1alpha1.ConditionWipeConfirmed) == message
+
+	patchHelper, err := patch.NewHelper(obj, s.c)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions.MarkFalse(obj, metalv1alpha1.ConditionWipeConfirmed, metalv1alpha1.DataFoundReason, clusterv1.ConditionSeverityWarning, "%s", message)
+
+	if err = patchHelper.Patch(ctx, obj, patch.WithOwnedConditions{
+		Conditions: []clusterv1.ConditionType{metalv1alpha1.ConditionWipeConfirmed},
+	}); err != nil {
+		return nil, err
+	}
+
+	if !waiting {
+		ref, err := reference.GetReference(s.scheme, obj)
+		if err != nil {
+			return nil, err
+		}
+
+		s.recorder.Event(ref, corev1.EventTypeWarning, "Server Wipe",
+			fmt.Sprintf("%s Annotate the server with %q to wipe the disks.", message, metalv1alpha1.ServerConfirmWipeAnnotation))
+	}
+
+	log.Printf("Server %q wipe is waiting for the confirmation: %s", obj.Name, message)
+
+	return &api.RequestWipeConfirmationResponse{}, nil
+}
+
+// bootFromDisk instructs the agent to reboot once the boot from disk is requested.
+//
+// Server which booted into the agent unexpectedly is always booted from disk by the iPXE server.
+func (s *server) bootFromDisk(ctx context.Context, obj *metalv1alpha1.Server) (*api.HeartbeatResponse, error) {
+	if !obj.BootFromDiskRequested() {
+		return &api.HeartbeatResponse{}, nil
+	}
+
+	if s.dryRun {
+		return &api.HeartbeatResponse{}, s.reportDryRun(obj, "Server would be rebooted to boot from disk.")
+	}
+
+	patchHelper, err := patch.NewHelper(obj, s.c)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(obj.Annotations, metalv1alpha1.ServerBootFromDiskAnnotation)
+
+	if err = patchHelper.Patch(ctx, obj); err != nil {
+		return nil, err
+	}
+
+	ref, err := reference.GetReference(s.scheme, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recorder.Event(ref, corev1.EventTypeNormal, "Server Agent Boot", "Boot from disk requested, rebooting the server.")
+
+	log.Printf("Server %q is rebooted to boot from disk", obj.Name)
+
+	return &api.HeartbeatResponse{BootFromDisk: true}, nil
+}
+
 func (s *server) UpdateBMCInfo(ctx context.Context, in *api.UpdateBMCInfoRequest) (*api.UpdateBMCInfoResponse, error) {
 	bmcInfo := in.GetBmcInfo()
 
 	// Fetch corresponding server
-	obj := &metalv1alpha1.Server{}
-
-	if err := s.c.Get(ctx, types.NamespacedName{Name: in.GetUuid()}, obj); err != nil {
+	obj, err := metalv1alpha1.LookupServer(ctx, s.c, in.GetUuid())
+	if err != nil {
 		return nil, err
 	}
 
@@ -373,18 +1057,218 @@ func (s *server) UpdateBMCInfo(ctx context.Context, in *api.UpdateBMCInfoRequest
 	return resp, nil
 }
 
-func CreateServer(c controllerclient.Client, recorder record.EventRecorder, scheme *runtime.Scheme, autoAccept, insecureWipe, autoBMC bool, rebootTimeout time.Duration) *grpc.Server {
+// UpdateInventory implements api.AgentServer.
+func (s *server) UpdateInventory(ctx context.Context, in *api.UpdateInventoryRequest) (*api.UpdateInventoryResponse, error) {
+	obj, err := metalv1alpha1.LookupServer(ctx, s.c, in.GetUuid())
+	if err != nil {
+		return nil, err
+	}
+
+	patchHelper, err := patch.NewHelper(obj, s.c)
+	if err != nil {
+		return nil, err
+	}
+
+	previouslyAggregated := AggregatedInterfaces(obj.Status.Inventory)
+
+	obj.Status.Inventory = HardwareInventory(in, time.Now())
+
+	aggregated := AggregatedInterfaces(obj.Status.Inventory)
+
+	relabeled := setLocationLabels(obj)
+
+	reinventory := obj.ReinventoryRequested()
+
+	if reinventory {
+		delete(obj.Annotations, metalv1alpha1.ServerReinventoryAnnotation)
+
+		// server was booted into the agent only to refresh the inventory, so it's done with the power cycle
+		if obj.Status.IsClean {
+			conditions.MarkTrue(obj, metalv1alpha1.ConditionPowerCycle)
+		}
+	}
+
+	if err := patchHelper.Patch(ctx, obj, patch.WithOwnedConditions{
+		Conditions: []clusterv1.ConditionType{metalv1alpha1.ConditionPowerCycle},
+	}); err != nil {
+		return nil, err
+	}
+
+	ref, err := reference.GetReference(s.scheme, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	if reinventory {
+		s.recorder.Event(ref, corev1.EventTypeNormal, "Server Inventory", "Hardware inventory refreshed via agent.")
+	}
+
+	if relabeled {
+		s.recorder.Event(ref, corev1.EventTypeNormal, "Server Location", "Location labels updated from the chassis location detected by the agent.")
+	}
+
+	// PXE boot works via LACP fallback, but the installed system has no network without a bond
+	if len(aggregated) > 0 && !reflect.DeepEqual(aggregated, previouslyAggregated) {
+		s.recorder.Event(ref, corev1.EventTypeWarning, "Server Network",
+			fmt.Sprintf("Switch ports of the interfaces %s are aggregated (LACP), the machine configuration should bond them.", strings.Join(aggregated, ", ")))
+	}
+
+	revision, err := hwdiff.Record(ctx, s.c, s.namespace, obj, time.Now(), hwdiff.DefaultMaxRevisions)
+	if err != nil {
+		// the inventory itself was updated, so the agent is not held back by the history
+		log.Printf("failed to record hardware revision for %s: %s", obj.Name, err)
+	} else if revision != nil && revision.Revision > 1 {
+		s.recorder.Event(ref, corev1.EventTypeNormal, "Server Inventory",
+			fmt.Sprintf("Hardware changed since the previous revision, recorded as revision %d.", revision.Revision))
+	}
+
+	log.Printf("Updated hardware inventory for %s", obj.Name)
+
+	resp := &api.UpdateInventoryResponse{}
+
+	return resp, nil
+}
+
+// AggregatedInterfaces returns the names of the network interfaces connected to the aggregated switch ports.
+func AggregatedInterfaces(inventory *metalv1alpha1.HardwareInventory) []string {
+	if inventory == nil {
+		return nil
+	}
+
+	var names []string
+
+	for _, nic := range inventory.NetworkInterfaces {
+		if nic.LinkAggregation != nil {
+			names = append(names, nic.Name)
+		}
+	}
+
+	return names
+}
+
+// setLocationLabels sets the location labels from the detected chassis location, returns true if any label was changed.
+//
+// Labels are left as is if the location is not detected, so they can be set manually.
+func setLocationLabels(obj *metalv1alpha1.Server) bool {
+	changed := false
+
+	for key, value := range obj.Status.Inventory.Chassis.Labels() {
+		if current, ok := obj.Labels[key]; ok && current == value {
+			continue
+		}
+
+		if obj.Labels == nil {
+			obj.Labels = map[string]string{}
+		}
+
+		obj.Labels[key] = value
+		changed = true
+	}
+
+	return changed
+}
+
+// HardwareInventory converts the inventory reported by the agent.
+func HardwareInventory(in *api.UpdateInventoryRequest, now time.Time) *metalv1alpha1.HardwareInventory {
+	inventory := &metalv1alpha1.HardwareInventory{
+		BMCFirmwareVersion: in.GetBmcFirmwareVersion(),
+		UpdatedAt:          metav1.NewTime(now),
+	}
+
+	for _, nic := range in.GetNetworkInterfaces() {
+		var aggregation *metalv1alpha1.LinkAggregation
+
+		if nic.GetLinkAggregation() != nil {
+			aggregation = &metalv1alpha1.LinkAggregation{
+				Source:  nic.GetLinkAggregation().GetSource(),
+				Partner: nic.GetLinkAggregation().GetPartner(),
+				Port:    nic.GetLinkAggregation().GetPort(),
+			}
+		}
+
+		inventory.NetworkInterfaces = append(inventory.NetworkInterfaces, metalv1alpha1.NetworkInterface{
+			Name:            nic.GetName(),
+			MAC:             nic.GetMac(),
+			SpeedMbps:       nic.GetSpeedMbps(),
+			PCIAddress:      nic.GetPciAddress(),
+			Driver:          nic.GetDriver(),
+			FirmwareVersion: nic.GetFirmwareVersion(),
+			LinkAggregation: aggregation,
+		})
+	}
+
+	for _, gpu := range in.GetGpus() {
+		inventory.GPUs = append(inventory.GPUs, metalv1alpha1.PCIDevice{
+			PCIAddress: gpu.GetPciAddress(),
+			VendorID:   gpu.GetVendorId(),
+			DeviceID:   gpu.GetDeviceId(),
+			Driver:     gpu.GetDriver(),
+		})
+	}
+
+	for _, disk := range in.GetDisks() {
+		health := disk.GetSmartHealth()
+
+		switch health {
+		case metalv1alpha1.SMARTHealthPassed, metalv1alpha1.SMARTHealthFailed:
+		default:
+			health = ""
+		}
+
+		inventory.Disks = append(inventory.Disks, metalv1alpha1.DiskInformation{
+			DeviceName:      disk.GetDeviceName(),
+			Model:           disk.GetModel(),
+			Serial:          disk.GetSerial(),
+			Type:            disk.GetType(),
+			Size:            disk.GetSize(),
+			FirmwareVersion: disk.GetFirmwareVersion(),
+			SMARTHealth:     health,
+		})
+	}
+
+	if location := in.GetChassisLocation(); location != nil {
+		inventory.Chassis = &metalv1alpha1.ChassisLocation{
+			SerialNumber: location.GetSerialNumber(),
+			AssetTag:     location.GetAssetTag(),
+			Slot:         location.GetSlot(),
+		}
+	}
+
+	return inventory
+}
+
+func CreateServer(c controllerclient.Client, recorder record.EventRecorder, scheme *runtime.Scheme, autoAccept, insecureWipe, autoBMC, wipeConfirmation, dryRun bool, identityWebhook *identity.Webhook, requiredApprovals int, namespace string, rebootTimeout time.Duration) *grpc.Server {
 	s := grpc.NewServer()
 
 	api.RegisterAgentServer(s, &server{
-		autoAccept:    autoAccept,
-		insecureWipe:  insecureWipe,
-		autoBMC:       autoBMC,
-		c:             c,
-		scheme:        scheme,
-		recorder:      recorder,
-		rebootTimeout: rebootTimeout,
+		autoAccept:        autoAccept,
+		insecureWipe:      insecureWipe,
+		autoBMC:           autoBMC,
+		wipeConfirmation:  wipeConfirmation,
+		dryRun:            dryRun,
+		identityWebhook:   identityWebhook,
+		requiredApprovals: requiredApprovals,
+		namespace:         namespace,
+		c:                 c,
+		scheme:            scheme,
+		recorder:          recorder,
+		rebootTimeout:     rebootTimeout,
 	})
 
 	return s
 }
+
+// peerAddress returns the IP address of the agent, or empty string if it's not known.
+func peerAddress(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return ""
+	}
+
+	return host
+}
@@ -4,11 +4,761 @@
 
 package server_test
 
-import "testing"
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
 
-func TestEmpty(t *testing.T) {
-	// added for accurate coverage estimation
-	//
-	// please remove it once any unit-test is added
-	// for this package
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/cluster-api/util/conditions"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/api"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/identity"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/server"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/hwdiff"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/wipecert"
+)
+
+func TestHardwareInventory(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	inventory := server.HardwareInventory(&api.UpdateInventoryRequest{
+		Uuid: "4c4c4544-0036-4410-8052-b7c04f4e3532",
+		NetworkInterfaces: []*api.NetworkInterface{
+			{Name: "eth0", Mac: "d0:50:99:d3:33:60", SpeedMbps: 10000, PciAddress: "0000:3b:00.0", Driver: "ixgbe", FirmwareVersion: "0x800003df"},
+			{Name: "eth1", Mac: "d0:50:99:d3:33:61", LinkAggregation: &api.LinkAggregation{Source: "lacp", Partner: "00:1c:73:01:02:03", Port: "17"}},
+		},
+		Gpus: []*api.PCIDevice{
+			{PciAddress: "0000:af:00.0", VendorId: "0x10de", DeviceId: "0x1eb8", Driver: "nouveau"},
+		},
+		Disks: []*api.Disk{
+			{DeviceName: "/dev/nvme0n1", Model: "Samsung SSD 970", Type: "nvme", Size: 512110190592, FirmwareVersion: "2B2QEXM7", SmartHealth: metalv1alpha1.SMARTHealthPassed},
+			{DeviceName: "/dev/sda", Type: "hdd", SmartHealth: "unexpected"},
+		},
+		BmcFirmwareVersion: "2.61",
+		ChassisLocation:    &api.ChassisLocation{SerialNumber: "CHS0042", Slot: "Node 2"},
+	}, now)
+
+	assert.Equal(t, &metalv1alpha1.HardwareInventory{
+		NetworkInterfaces: []metalv1alpha1.NetworkInterface{
+			{Name: "eth0", MAC: "d0:50:99:d3:33:60", SpeedMbps: 10000, PCIAddress: "0000:3b:00.0", Driver: "ixgbe", FirmwareVersion: "0x800003df"},
+			{Name: "eth1", MAC: "d0:50:99:d3:33:61", LinkAggregation: &metalv1alpha1.LinkAggregation{Source: "lacp", Partner: "00:1c:73:01:02:03", Port: "17"}},
+		},
+		GPUs: []metalv1alpha1.PCIDevice{
+			{PCIAddress: "0000:af:00.0", VendorID: "0x10de", DeviceID: "0x1eb8", Driver: "nouveau"},
+		},
+		Disks: []metalv1alpha1.DiskInformation{
+			{DeviceName: "/dev/nvme0n1", Model: "Samsung SSD 970", Type: "nvme", Size: 512110190592, FirmwareVersion: "2B2QEXM7", SMARTHealth: metalv1alpha1.SMARTHealthPassed},
+			{DeviceName: "/dev/sda", Type: "hdd"},
+		},
+		BMCFirmwareVersion: "2.61",
+		Chassis:            &metalv1alpha1.ChassisLocation{SerialNumber: "CHS0042", Slot: "Node 2"},
+		UpdatedAt:          metav1.NewTime(now),
+	}, inventory)
+
+	assert.Equal(t, []string{"eth1"}, server.AggregatedInterfaces(inventory))
+	assert.Nil(t, server.AggregatedInterfaces(nil))
+}
+
+func TestWipeCertificate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	obj := &metalv1alpha1.Server{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "4c4c4544-0036-4410-8052-b7c04f4e3532",
+			Annotations: map[string]string{wipecert.OperatorAnnotation: "jane@example.com"},
+		},
+		Spec: metalv1alpha1.ServerSpec{
+			SystemInformation: &metalv1alpha1.SystemInformation{
+				Manufacturer: "Dell Inc.",
+				ProductName:  "PowerEdge R640",
+				SerialNumber: "6DR0QR2",
+			},
+		},
+	}
+
+	cert := server.WipeCertificate(obj, &api.MarkServerAsWipedRequest{
+		Uuid: obj.Name,
+		WipedDisks: []*api.WipedDisk{
+			{DeviceName: "/dev/sda", Model: "PERC H730P", Serial: "0012345", Size: 479559942144, Method: "zeroes", Verified: true, StartedAt: now.Add(-time.Hour).Unix(), CompletedAt: now.Add(-time.Minute).Unix()},
+			{DeviceName: "/dev/nvme0n1", Method: "blkdiscard", StartedAt: now.Add(-2 * time.Hour).Unix(), CompletedAt: now.Add(-2 * time.Minute).Unix()},
+		},
+		SkippedDisks: []*api.SkippedDisk{
+			{DeviceName: "/dev/sdb", Reason: "excluded by the wipe policy"},
+		},
+	}, now)
+
+	assert.Equal(t, &wipecert.Certificate{
+		Server: wipecert.Server{
+			UUID:         obj.Name,
+			Manufacturer: "Dell Inc.",
+			ProductName:  "PowerEdge R640",
+			SerialNumber: "6DR0QR2",
+		},
+		Operator:    "jane@example.com",
+		StartedAt:   now.Add(-2 * time.Hour),
+		CompletedAt: now.Add(-time.Minute),
+		Disks: []wipecert.Disk{
+			{DeviceName: "/dev/sda", Model: "PERC H730P", Serial: "0012345", Size: 479559942144, Method: "zeroes", Verified: true, StartedAt: now.Add(-time.Hour), CompletedAt: now.Add(-time.Minute)},
+			{DeviceName: "/dev/nvme0n1", Method: "blkdiscard", StartedAt: now.Add(-2 * time.Hour), CompletedAt: now.Add(-2 * time.Minute)},
+		},
+		SkippedDisks: []wipecert.SkippedDisk{
+			{DeviceName: "/dev/sdb", Reason: "excluded by the wipe policy"},
+		},
+		IssuedAt: now,
+	}, cert)
+
+	assert.False(t, cert.Verified())
+
+	obj.Annotations = nil
+
+	assert.Equal(t, wipecert.DefaultOperator, server.WipeCertificate(obj, &api.MarkServerAsWipedRequest{}, now).Operator)
+}
+
+// startAgentServer serves the agent API on the loopback until the test is done.
+func startAgentServer(t *testing.T, c client.Client, scheme *runtime.Scheme, identityWebhook *identity.Webhook, requiredApprovals int, dryRun bool) api.AgentClient {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	grpcServer := server.CreateServer(c, record.NewFakeRecorder(10), scheme, false, false, true, true, dryRun, identityWebhook, requiredApprovals, constants.SideroNamespace, time.Minute)
+
+	go grpcServer.Serve(listener) //nolint:errcheck
+
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+
+	t.Cleanup(func() { conn.Close() }) //nolint:errcheck
+
+	return api.NewAgentClient(conn)
+}
+
+func TestUnexpectedAgentBoot(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+	require.NoError(t, infrav1.AddToScheme(scheme))
+
+	for name, tc := range map[string]struct {
+		objects []runtime.Object
+
+		expectedUnexpectedBoot bool
+	}{
+		"in use": {
+			objects: []runtime.Object{
+				&metalv1alpha1.Server{
+					ObjectMeta: metav1.ObjectMeta{Name: "server-1", ResourceVersion: "1"},
+					Spec:       metalv1alpha1.ServerSpec{Accepted: true},
+					Status:     metalv1alpha1.ServerStatus{InUse: true},
+				},
+			},
+			expectedUnexpectedBoot: true,
+		},
+		"server binding": {
+			objects: []runtime.Object{
+				&metalv1alpha1.Server{
+					ObjectMeta: metav1.ObjectMeta{Name: "server-1", ResourceVersion: "1"},
+					Spec:       metalv1alpha1.ServerSpec{Accepted: true},
+				},
+				&infrav1.ServerBinding{
+					ObjectMeta: metav1.ObjectMeta{Name: "server-1"},
+				},
+			},
+			expectedUnexpectedBoot: true,
+		},
+		"released": {
+			objects: []runtime.Object{
+				&metalv1alpha1.Server{
+					ObjectMeta: metav1.ObjectMeta{Name: "server-1", ResourceVersion: "1"},
+					Spec:       metalv1alpha1.ServerSpec{Accepted: true},
+				},
+			},
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := fake.NewFakeClientWithScheme(scheme, tc.objects...)
+
+			agent := startAgentServer(t, c, scheme, nil, 0, false)
+
+			resp, err := agent.CreateServer(ctx, &api.CreateServerRequest{
+				SystemInformation: &api.SystemInformation{Uuid: "server-1"},
+			})
+			require.NoError(t, err)
+
+			getServer := func() *metalv1alpha1.Server {
+				var obj metalv1alpha1.Server
+
+				require.NoError(t, c.Get(ctx, types.NamespacedName{Name: "server-1"}, &obj))
+
+				return &obj
+			}
+
+			assert.Equal(t, tc.expectedUnexpectedBoot, resp.GetUnexpectedBoot())
+			assert.Equal(t, tc.expectedUnexpectedBoot, conditions.IsTrue(getServer(), metalv1alpha1.ConditionUnexpectedAgentBoot))
+
+			if !tc.expectedUnexpectedBoot {
+				assert.True(t, resp.GetWipe())
+				assert.True(t, resp.GetSetupBmc())
+
+				return
+			}
+
+			assert.False(t, resp.GetWipe())
+			assert.False(t, resp.GetSetupBmc())
+
+			heartbeat, err := agent.Heartbeat(ctx, &api.HeartbeatRequest{Uuid: "server-1"})
+			require.NoError(t, err)
+
+			assert.False(t, heartbeat.GetBootFromDisk())
+			assert.False(t, conditions.Has(getServer(), metalv1alpha1.ConditionPowerCycle))
+
+			obj := getServer()
+			patch := client.MergeFrom(obj.DeepCopy())
+			obj.Annotations = map[string]string{metalv1alpha1.ServerBootFromDiskAnnotation: ""}
+			require.NoError(t, c.Patch(ctx, obj, patch))
+
+			heartbeat, err = agent.Heartbeat(ctx, &api.HeartbeatRequest{Uuid: "server-1"})
+			require.NoError(t, err)
+
+			assert.True(t, heartbeat.GetBootFromDisk())
+			assert.False(t, getServer().BootFromDiskRequested())
+		})
+	}
+}
+
+func TestLocationLabels(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+	require.NoError(t, infrav1.AddToScheme(scheme))
+
+	c := fake.NewFakeClientWithScheme(scheme, &metalv1alpha1.Server{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "server-1",
+			ResourceVersion: "1",
+			Labels:          map[string]string{"rack": "r1"},
+		},
+		Spec: metalv1alpha1.ServerSpec{Accepted: true},
+	})
+
+	agent := startAgentServer(t, c, scheme, nil, 0, false)
+
+	labels := func() map[string]string {
+		var obj metalv1alpha1.Server
+
+		require.NoError(t, c.Get(ctx, types.NamespacedName{Name: "server-1"}, &obj))
+
+		return obj.Labels
+	}
+
+	_, err := agent.UpdateInventory(ctx, &api.UpdateInventoryRequest{
+		Uuid:            "server-1",
+		ChassisLocation: &api.ChassisLocation{SerialNumber: "CHS0042", Slot: "Node 2"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]string{
+		"rack":                         "r1",
+		metalv1alpha1.ChassisLabel:     "CHS0042",
+		metalv1alpha1.ChassisSlotLabel: "Node-2",
+	}, labels())
+
+	// labels are kept when the location is not detected
+	_, err = agent.UpdateInventory(ctx, &api.UpdateInventoryRequest{
+		Uuid: "server-1",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, "Node-2", labels()[metalv1alpha1.ChassisSlotLabel])
+
+	// hardware revisions are kept in the Sidero namespace
+	revisions, err := hwdiff.List(ctx, c, constants.SideroNamespace, "server-1")
+	require.NoError(t, err)
+	assert.NotEmpty(t, revisions)
+}
+
+func TestDryRun(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+	require.NoError(t, infrav1.AddToScheme(scheme))
+
+	c := fake.NewFakeClientWithScheme(scheme, &metalv1alpha1.Server{
+		ObjectMeta: metav1.ObjectMeta{Name: "server-1", ResourceVersion: "1"},
+		Spec:       metalv1alpha1.ServerSpec{Accepted: true},
+	})
+
+	agent := startAgentServer(t, c, scheme, nil, 0, true)
+
+	resp, err := agent.CreateServer(ctx, &api.CreateServerRequest{
+		SystemInformation: &api.SystemInformation{Uuid: "server-1"},
+	})
+	require.NoError(t, err)
+
+	assert.False(t, resp.GetWipe())
+	assert.False(t, resp.GetSetupBmc())
+}
+
+func TestApprovalGate(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+	require.NoError(t, infrav1.AddToScheme(scheme))
+
+	c := fake.NewFakeClientWithScheme(scheme,
+		// accepted before the server reconciler reverted the acceptance
+		&metalv1alpha1.Server{
+			ObjectMeta: metav1.ObjectMeta{Name: "server-pending", ResourceVersion: "1"},
+			Spec:       metalv1alpha1.ServerSpec{Accepted: true},
+			Status: metalv1alpha1.ServerStatus{
+				Approval: &metalv1alpha1.ApprovalStatus{State: metalv1alpha1.ApprovalStatePending, Required: 2},
+			},
+		},
+		&metalv1alpha1.Server{
+			ObjectMeta: metav1.ObjectMeta{Name: "server-approved", ResourceVersion: "1"},
+			Spec:       metalv1alpha1.ServerSpec{Accepted: true},
+			Status: metalv1alpha1.ServerStatus{
+				Approval: &metalv1alpha1.ApprovalStatus{State: metalv1alpha1.ApprovalStateApproved, Required: 2},
+			},
+		},
+	)
+
+	agent := startAgentServer(t, c, scheme, nil, 2, false)
+
+	resp, err := agent.CreateServer(ctx, &api.CreateServerRequest{
+		SystemInformation: &api.SystemInformation{Uuid: "server-pending"},
+	})
+	require.NoError(t, err)
+
+	assert.False(t, resp.GetWipe())
+	assert.False(t, resp.GetSetupBmc())
+
+	resp, err = agent.CreateServer(ctx, &api.CreateServerRequest{
+		SystemInformation: &api.SystemInformation{Uuid: "server-approved"},
+	})
+	require.NoError(t, err)
+
+	assert.True(t, resp.GetWipe())
+	assert.True(t, resp.GetSetupBmc())
+}
+
+func TestWipeConfirmation(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+	require.NoError(t, infrav1.AddToScheme(scheme))
+
+	for name, tc := range map[string]struct {
+		annotations map[string]string
+		policy      *metalv1alpha1.WipePolicy
+		released    bool
+
+		expectedRequired bool
+	}{
+		"default": {
+			expectedRequired: true,
+		},
+		"reusable": {
+			annotations: map[string]string{metalv1alpha1.ServerReusableAnnotation: ""},
+		},
+		"released": {
+			released: true,
+		},
+		"confirmed": {
+			annotations: map[string]string{metalv1alpha1.ServerConfirmWipeAnnotation: ""},
+		},
+		"policy": {
+			policy: &metalv1alpha1.WipePolicy{Confirmation: metalv1alpha1.WipeConfirmationNever},
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := fake.NewFakeClientWithScheme(scheme, &metalv1alpha1.Server{
+				ObjectMeta: metav1.ObjectMeta{Name: "server-1", Annotations: tc.annotations, ResourceVersion: "1"},
+				Spec:       metalv1alpha1.ServerSpec{Accepted: true, WipePolicy: tc.policy},
+				Status:     metalv1alpha1.ServerStatus{Released: tc.released},
+			})
+
+			agent := startAgentServer(t, c, scheme, nil, 0, false)
+
+			resp, err := agent.CreateServer(ctx, &api.CreateServerRequest{
+				SystemInformation: &api.SystemInformation{Uuid: "server-1"},
+			})
+			require.NoError(t, err)
+
+			assert.True(t, resp.GetWipe())
+			assert.Equal(t, tc.expectedRequired, resp.GetWipeConfirmationRequired())
+
+			confirmation, err := agent.RequestWipeConfirmation(ctx, &api.RequestWipeConfirmationRequest{
+				Uuid:  "server-1",
+				Disks: []*api.DataDisk{{DeviceName: "/dev/sda", Signatures: []string{"talos", "lvm"}}},
+			})
+			require.NoError(t, err)
+
+			assert.Equal(t, !tc.expectedRequired, confirmation.GetConfirmed())
+
+			getServer := func() *metalv1alpha1.Server {
+				var obj metalv1alpha1.Server
+
+				require.NoError(t, c.Get(ctx, types.NamespacedName{Name: "server-1"}, &obj))
+
+				return &obj
+			}
+
+			if tc.expectedRequired {
+				obj := getServer()
+
+				assert.True(t, conditions.IsFalse(obj, metalv1alpha1.ConditionWipeConfirmed))
+				assert.Equal(t, metalv1alpha1.DataFoundReason, conditions.GetReason(obj, metalv1alpha1.ConditionWipeConfirmed))
+				assert.Equal(t, "Existing data found on /dev/sda (talos, lvm).", conditions.GetMessage(obj, metalv1alpha1.ConditionWipeConfirmed))
+
+				heartbeat, err := agent.Heartbeat(ctx, &api.HeartbeatRequest{Uuid: "server-1"})
+				require.NoError(t, err)
+
+				assert.False(t, heartbeat.GetWipeConfirmed())
+
+				obj = getServer()
+				patch := client.MergeFrom(obj.DeepCopy())
+				obj.Annotations = map[string]string{metalv1alpha1.ServerConfirmWipeAnnotation: ""}
+				require.NoError(t, c.Patch(ctx, obj, patch))
+
+				heartbeat, err = agent.Heartbeat(ctx, &api.HeartbeatRequest{Uuid: "server-1"})
+				require.NoError(t, err)
+
+				assert.True(t, heartbeat.GetWipeConfirmed())
+			}
+
+			_, err = agent.MarkServerAsWiped(ctx, &api.MarkServerAsWipedRequest{Uuid: "server-1"})
+			require.NoError(t, err)
+
+			obj := getServer()
+
+			assert.True(t, obj.Status.IsClean)
+			assert.False(t, obj.Status.Released)
+			assert.False(t, conditions.Has(obj, metalv1alpha1.ConditionWipeConfirmed))
+			assert.NotContains(t, obj.Annotations, metalv1alpha1.ServerConfirmWipeAnnotation)
+		})
+	}
+}
+
+func TestIdentityWebhook(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+	require.NoError(t, infrav1.AddToScheme(scheme))
+
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req identity.Request
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+
+			return
+		}
+
+		var resp identity.Response
+
+		switch req.SystemInformation.SerialNumber {
+		case "accepted":
+			resp = identity.Response{Decision: identity.DecisionAccept, Labels: map[string]string{"rack": "r1", "mac": strings.ReplaceAll(req.MACAddresses[0], ":", "")}}
+		case "rejected":
+			resp = identity.Response{Decision: identity.DecisionReject, Reason: "not in the asset database"}
+		case "replaced":
+			resp = identity.Response{ServerName: "server-old", Labels: map[string]string{"rack": "r2"}}
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+
+			return
+		}
+
+		json.NewEncoder(w).Encode(resp) //nolint:errcheck
+	}))
+
+	t.Cleanup(webhook.Close)
+
+	for name, tc := range map[string]struct {
+		serial            string
+		failurePolicy     identity.FailurePolicy
+		requiredApprovals int
+		inUse             bool
+
+		expectedCode     codes.Code
+		expectedServer   string
+		expectedAccepted bool
+		expectedLabels   map[string]string
+	}{
+		"accepted": {
+			serial:           "accepted",
+			expectedServer:   "server-new",
+			expectedAccepted: true,
+			expectedLabels:   map[string]string{"rack": "r1", "mac": "d05099d33360"},
+		},
+		"accepted with approvals": {
+			serial:            "accepted",
+			requiredApprovals: 2,
+			expectedServer:    "server-new",
+			expectedLabels:    map[string]string{"rack": "r1", "mac": "d05099d33360"},
+		},
+		"rejected": {
+			serial:       "rejected",
+			expectedCode: codes.PermissionDenied,
+		},
+		"merged": {
+			serial:           "replaced",
+			expectedServer:   "server-old",
+			expectedAccepted: true,
+			expectedLabels:   map[string]string{"rack": "r2", metalv1alpha1.ServerUUIDLabel: "server-new"},
+		},
+		"merged in use": {
+			serial:       "replaced",
+			inUse:        true,
+			expectedCode: codes.FailedPrecondition,
+		},
+		"default": {
+			serial:         "unknown",
+			expectedServer: "server-new",
+		},
+		"failure": {
+			serial:       "broken",
+			expectedCode: codes.Unavailable,
+		},
+		"failure ignored": {
+			serial:         "broken",
+			failurePolicy:  identity.FailurePolicyIgnore,
+			expectedServer: "server-new",
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := fake.NewFakeClientWithScheme(scheme, &metalv1alpha1.Server{
+				ObjectMeta: metav1.ObjectMeta{Name: "server-old", ResourceVersion: "1"},
+				Spec:       metalv1alpha1.ServerSpec{Accepted: true},
+				Status:     metalv1alpha1.ServerStatus{InUse: tc.inUse},
+			})
+
+			failurePolicy := tc.failurePolicy
+			if failurePolicy == "" {
+				failurePolicy = identity.FailurePolicyFail
+			}
+
+			agent := startAgentServer(t, c, scheme, identity.New(webhook.URL, time.Second, failurePolicy), tc.requiredApprovals, false)
+
+			_, err := agent.CreateServer(ctx, &api.CreateServerRequest{
+				SystemInformation: &api.SystemInformation{Uuid: "server-new", SerialNumber: tc.serial},
+				MacAddresses:      []string{"d0:50:99:d3:33:60"},
+			})
+
+			if tc.expectedCode != codes.OK {
+				assert.Equal(t, tc.expectedCode, status.Code(err))
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			obj, err := metalv1alpha1.LookupServer(ctx, c, "server-new")
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedServer, obj.Name)
+			assert.Equal(t, tc.expectedAccepted, obj.Spec.Accepted)
+			assert.Equal(t, tc.expectedLabels, obj.Labels)
+			assert.Equal(t, tc.serial, obj.Spec.SystemInformation.SerialNumber)
+
+			// merged machine is found by the UUID on the next boot
+			_, err = agent.Heartbeat(ctx, &api.HeartbeatRequest{Uuid: "server-new"})
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestWipePolicy(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+	require.NoError(t, infrav1.AddToScheme(scheme))
+
+	serverClass := func(name, mode string) *metalv1alpha1.ServerClass {
+		sc := &metalv1alpha1.ServerClass{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: metalv1alpha1.ServerClassSpec{
+				Selector: metav1.LabelSelector{MatchLabels: map[string]string{"role": "storage"}},
+			},
+		}
+
+		if mode != "" {
+			sc.Spec.WipePolicy = &metalv1alpha1.WipePolicy{Mode: metalv1alpha1.WipeMode(mode)}
+		}
+
+		return sc
+	}
+
+	for name, tc := range map[string]struct {
+		serverClass   string
+		policy        *metalv1alpha1.WipePolicy
+		serverClasses []*metalv1alpha1.ServerClass
+
+		expectedMode     string
+		expectedConflict bool
+	}{
+		"no classes": {},
+		"server policy": {
+			policy:        &metalv1alpha1.WipePolicy{Mode: metalv1alpha1.WipeModeSystemDisk},
+			serverClasses: []*metalv1alpha1.ServerClass{serverClass("a", "all"), serverClass("b", "selected")},
+			expectedMode:  "system-disk",
+		},
+		"allocating class": {
+			serverClass:   "b",
+			serverClasses: []*metalv1alpha1.ServerClass{serverClass("a", "all"), serverClass("b", "selected")},
+			expectedMode:  "selected",
+		},
+		"allocating class without policy": {
+			serverClass:   "b",
+			serverClasses: []*metalv1alpha1.ServerClass{serverClass("a", "selected"), serverClass("b", "")},
+		},
+		"allocating class deleted": {
+			serverClass:   "c",
+			serverClasses: []*metalv1alpha1.ServerClass{serverClass("a", "selected"), serverClass("b", "")},
+			expectedMode:  "selected",
+		},
+		"matching classes agree": {
+			serverClasses: []*metalv1alpha1.ServerClass{serverClass("a", "selected"), serverClass("b", "selected"), serverClass("c", "")},
+			expectedMode:  "selected",
+		},
+		"matching classes disagree": {
+			serverClasses:    []*metalv1alpha1.ServerClass{serverClass("a", "all"), serverClass("b", "selected")},
+			expectedConflict: true,
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			objects := []runtime.Object{
+				&metalv1alpha1.Server{
+					ObjectMeta: metav1.ObjectMeta{Name: "server-1", Labels: map[string]string{"role": "storage"}, ResourceVersion: "1"},
+					Spec:       metalv1alpha1.ServerSpec{Accepted: true, WipePolicy: tc.policy},
+					Status:     metalv1alpha1.ServerStatus{ServerClass: tc.serverClass},
+				},
+			}
+
+			for _, sc := range tc.serverClasses {
+				objects = append(objects, sc)
+			}
+
+			c := fake.NewFakeClientWithScheme(scheme, objects...)
+
+			agent := startAgentServer(t, c, scheme, nil, 0, false)
+
+			getServer := func() *metalv1alpha1.Server {
+				var obj metalv1alpha1.Server
+
+				require.NoError(t, c.Get(ctx, types.NamespacedName{Name: "server-1"}, &obj))
+
+				return &obj
+			}
+
+			resp, err := agent.CreateServer(ctx, &api.CreateServerRequest{
+				SystemInformation: &api.SystemInformation{Uuid: "server-1"},
+			})
+
+			if tc.expectedConflict {
+				require.Error(t, err)
+				assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+				obj := getServer()
+				assert.True(t, conditions.IsFalse(obj, metalv1alpha1.ConditionWipePolicyResolved))
+				assert.Equal(t, metalv1alpha1.ConflictingWipePoliciesReason, conditions.GetReason(obj, metalv1alpha1.ConditionWipePolicyResolved))
+				assert.Equal(t, "matching ServerClasses a, b have different wipe policies", conditions.GetMessage(obj, metalv1alpha1.ConditionWipePolicyResolved))
+
+				// server is wiped once the policy is set on the server
+				patch := client.MergeFrom(obj.DeepCopy())
+				obj.Spec.WipePolicy = &metalv1alpha1.WipePolicy{Mode: metalv1alpha1.WipeModeAll}
+				require.NoError(t, c.Patch(ctx, obj, patch))
+
+				resp, err = agent.CreateServer(ctx, &api.CreateServerRequest{
+					SystemInformation: &api.SystemInformation{Uuid: "server-1"},
+				})
+				require.NoError(t, err)
+
+				assert.True(t, resp.GetWipe())
+				assert.Equal(t, "all", resp.GetWipePolicy().GetMode())
+				assert.False(t, conditions.Has(getServer(), metalv1alpha1.ConditionWipePolicyResolved))
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			assert.True(t, resp.GetWipe())
+			assert.Equal(t, tc.expectedMode, resp.GetWipePolicy().GetMode())
+
+			_, err = agent.MarkServerAsWiped(ctx, &api.MarkServerAsWipedRequest{Uuid: "server-1"})
+			require.NoError(t, err)
+
+			assert.Empty(t, getServer().Status.ServerClass)
+		})
+	}
 }
@@ -5,13 +5,21 @@
 package tftp
 
 import (
+	"bytes"
+	"encoding/hex"
+	"errors"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/pin/tftp"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/metrics"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
 )
 
 // cleanPath makes a path safe for use with filepath.Join. This is done by not
@@ -43,37 +51,144 @@ func cleanPath(path string) string {
 	return filepath.Clean(path)
 }
 
-// readHandler is called when client starts file download from server.
-func readHandler(filename string, rf io.ReaderFrom) error {
-	filename = filepath.Join("/var/lib/sidero/tftp", cleanPath(filename))
+// RPiDirectory is the directory (relative to the TFTP root) with the Raspberry Pi firmware and u-boot
+// shared by all the boards.
+const RPiDirectory = "rpi"
+
+// rpiConfig is served as the Raspberry Pi firmware config.txt if it's not on disk: firmware chainloads u-boot.
+const rpiConfig = `arm_64bit=1
+enable_uart=1
+kernel=u-boot.bin
+`
+
+// Generator returns the contents of the file which is not stored on disk, e.g. the generated boot scripts.
+//
+// Generator returns os.ErrNotExist if it doesn't handle the file.
+type Generator func(filename string) ([]byte, error)
+
+// Resolve returns the path on disk to serve the requested filename from.
+//
+// Environment assets are served from the `env/` prefix, so that the boot loaders without HTTP support
+// (u-boot) can fetch them.
+// Raspberry Pi EEPROM requests the files with the board serial number prefix: per-board directory is used if it exists,
+// otherwise the files are served from the shared RPiDirectory.
+func Resolve(dataDirectory, filename string) string {
+	filename = cleanPath(filename)
+
+	if rest := strings.TrimPrefix(filename, "env"+string(os.PathSeparator)); rest != filename {
+		return filepath.Join(dataDirectory, "env", rest)
+	}
 
-	file, err := os.Open(filename)
-	if err != nil {
-		log.Printf("%v", err)
+	path := filepath.Join(dataDirectory, "tftp", filename)
 
-		return err
+	if _, err := os.Stat(path); err == nil {
+		return path
+	}
+
+	if serial, rest, ok := splitRPiSerial(filename); ok {
+		if _, err := os.Stat(filepath.Join(dataDirectory, "tftp", serial)); err != nil {
+			return filepath.Join(dataDirectory, "tftp", RPiDirectory, rest)
+		}
+	}
+
+	return path
+}
+
+// splitRPiSerial splits the Raspberry Pi serial number prefix (8 hex digits) off the filename.
+func splitRPiSerial(filename string) (serial, rest string, ok bool) {
+	parts := strings.SplitN(filename, string(os.PathSeparator), 2)
+	if len(parts) != 2 || len(parts[0]) != 8 || parts[1] == "" {
+		return "", "", false
+	}
+
+	if _, err := hex.DecodeString(parts[0]); err != nil {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
+
+// isRPiConfig returns true if the filename is the Raspberry Pi firmware config.txt.
+func isRPiConfig(filename string) bool {
+	filename = cleanPath(filename)
+
+	if _, rest, ok := splitRPiSerial(filename); ok {
+		filename = rest
 	}
 
-	defer file.Close()
+	return filename == "config.txt"
+}
+
+// newReadHandler returns the handler which is called when client starts file download from server.
+func newReadHandler(dataDirectory string, generators []Generator) func(filename string, rf io.ReaderFrom) error {
+	return func(filename string, rf io.ReaderFrom) error {
+		r, size, err := open(dataDirectory, generators, filename)
+		if err != nil {
+			log.Printf("%v", err)
+			metrics.BootRequests.WithLabelValues("tftp", metrics.ResultFailure).Inc()
+
+			return err
+		}
+
+		defer r.Close() //nolint:errcheck
+
+		if t, ok := rf.(tftp.OutgoingTransfer); ok && size >= 0 {
+			t.SetSize(size)
+		}
 
-	n, err := rf.ReadFrom(file)
+		n, err := rf.ReadFrom(r)
+		if err != nil {
+			log.Printf("%v", err)
+			metrics.BootRequests.WithLabelValues("tftp", metrics.ResultFailure).Inc()
+
+			return err
+		}
+
+		log.Printf("%d bytes sent", n)
+		metrics.BootRequests.WithLabelValues("tftp", metrics.ResultSuccess).Inc()
+
+		return nil
+	}
+}
+
+// open returns the contents of the requested file and its size (-1 if unknown).
+func open(dataDirectory string, generators []Generator, filename string) (io.ReadCloser, int64, error) {
+	for _, generate := range generators {
+		data, err := generate(filename)
+		if err == nil {
+			return ioutil.NopCloser(bytes.NewReader(data)), int64(len(data)), nil
+		}
+
+		if !errors.Is(err, os.ErrNotExist) {
+			return nil, 0, err
+		}
+	}
+
+	file, err := os.Open(Resolve(dataDirectory, filename))
 	if err != nil {
-		log.Printf("%v", err)
+		if errors.Is(err, os.ErrNotExist) && isRPiConfig(filename) {
+			return ioutil.NopCloser(strings.NewReader(rpiConfig)), int64(len(rpiConfig)), nil
+		}
 
-		return err
+		return nil, 0, err
 	}
 
-	log.Printf("%d bytes sent", n)
+	size := int64(-1)
 
-	return nil
+	if st, err := file.Stat(); err == nil {
+		size = st.Size()
+	}
+
+	return file, size, nil
 }
 
-func ServeTFTP() error {
-	if err := os.MkdirAll("/var/lib/sidero/tftp", 0o777); err != nil {
+// ServeTFTP serves the files from the TFTP root directory, the environment assets and the files returned by the generators.
+func ServeTFTP(generators ...Generator) error {
+	if err := os.MkdirAll(filepath.Join(constants.DataDirectory, "tftp"), 0o777); err != nil {
 		return err
 	}
 
-	s := tftp.NewServer(readHandler, nil)
+	s := tftp.NewServer(newReadHandler(constants.DataDirectory, generators), nil)
 
 	// A standard TFTP server implementation receives requests on port 69 and
 	// allocates a new high port (over 1024) dedicated to that request. In single
@@ -84,6 +199,7 @@ func ServeTFTP() error {
 	s.EnableSinglePort()
 	s.SetTimeout(5 * time.Second)
 
+	// wildcard address listens on a dual-stack socket, so that both IPv4 and IPv6 clients are served
 	if err := s.ListenAndServe(":69"); err != nil {
 		return err
 	}
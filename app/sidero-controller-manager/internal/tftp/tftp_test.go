@@ -4,11 +4,52 @@
 
 package tftp_test
 
-import "testing"
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
 
-func TestEmpty(t *testing.T) {
-	// added for accurate coverage estimation
-	//
-	// please remove it once any unit-test is added
-	// for this package
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/tftp"
+)
+
+func TestResolve(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "sidero-tftp")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { os.RemoveAll(dir) }) //nolint:errcheck
+
+	for _, path := range []string{
+		"tftp/ipxe.efi",
+		"tftp/rpi/start4.elf",
+		// per-board firmware (UEFI) takes precedence over the shared one
+		"tftp/0123abcd/start4.elf",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0o755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, path), nil, 0o644))
+	}
+
+	for filename, expected := range map[string]string{
+		"ipxe.efi":                    "tftp/ipxe.efi",
+		"/ipxe.efi":                   "tftp/ipxe.efi",
+		"../../ipxe.efi":              "tftp/ipxe.efi",
+		"env/agent-arm64/vmlinuz":     "env/agent-arm64/vmlinuz",
+		"env/../../etc/passwd":        "tftp/etc/passwd",
+		"0123abcd/start4.elf":         "tftp/0123abcd/start4.elf",
+		"0123abcd/config.txt":         "tftp/0123abcd/config.txt",
+		"dca63201/start4.elf":         "tftp/rpi/start4.elf",
+		"dca63201/overlays/a.dtbo":    "tftp/rpi/overlays/a.dtbo",
+		"dca63201/../../etc/passwd":   "tftp/etc/passwd",
+		"rpi/start4.elf":              "tftp/rpi/start4.elf",
+		"not-a-serial/start4.elf":     "tftp/not-a-serial/start4.elf",
+		"dca6320x/start4.elf":         "tftp/dca6320x/start4.elf",
+		"uboot/dc:a6:32:01:02:03.scr": "tftp/uboot/dc:a6:32:01:02:03.scr",
+	} {
+		assert.Equal(t, filepath.Join(dir, expected), tftp.Resolve(dir, filename), filename)
+	}
 }
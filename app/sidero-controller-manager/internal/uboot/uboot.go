@@ -0,0 +1,134 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package uboot encodes the u-boot scripts in the legacy image format, as `mkimage -T script` does.
+package uboot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// Legacy image header constants (see u-boot include/image.h).
+const (
+	Magic = 0x27051956
+
+	OSLinux    = 5
+	ArchARM64  = 22
+	TypeScript = 6
+	CompNone   = 0
+
+	headerSize = 64
+	nameSize   = 32
+)
+
+// Header is the u-boot legacy image header.
+type Header struct {
+	Magic     uint32
+	HeaderCRC uint32
+	Time      uint32
+	Size      uint32
+	Load      uint32
+	Entry     uint32
+	DataCRC   uint32
+	OS        uint8
+	Arch      uint8
+	Type      uint8
+	Comp      uint8
+	Name      [nameSize]byte
+}
+
+// Script encodes the script source as the u-boot script image, which can be run with `source`.
+//
+// Image timestamp is zero, so that the image is the same for the same script.
+func Script(name, script string) []byte {
+	var data bytes.Buffer
+
+	// script images are multi-file images with a single file: the size table terminated by zero, then the file
+	binary.Write(&data, binary.BigEndian, uint32(len(script))) //nolint:errcheck
+	binary.Write(&data, binary.BigEndian, uint32(0))           //nolint:errcheck
+	data.WriteString(script)
+
+	header := Header{
+		Magic:   Magic,
+		Size:    uint32(data.Len()),
+		DataCRC: crc32.ChecksumIEEE(data.Bytes()),
+		OS:      OSLinux,
+		Arch:    ArchARM64,
+		Type:    TypeScript,
+		Comp:    CompNone,
+	}
+
+	// name is NUL-terminated
+	copy(header.Name[:nameSize-1], name)
+
+	var buf bytes.Buffer
+
+	binary.Write(&buf, binary.BigEndian, &header) //nolint:errcheck
+
+	header.HeaderCRC = crc32.ChecksumIEEE(buf.Bytes())
+
+	buf.Reset()
+
+	binary.Write(&buf, binary.BigEndian, &header) //nolint:errcheck
+	buf.Write(data.Bytes())
+
+	return buf.Bytes()
+}
+
+// Decode returns the header and the script source of the script image.
+func Decode(image []byte) (*Header, string, error) {
+	if len(image) < headerSize {
+		return nil, "", fmt.Errorf("image is too short: %d bytes", len(image))
+	}
+
+	var header Header
+
+	if err := binary.Read(bytes.NewReader(image[:headerSize]), binary.BigEndian, &header); err != nil {
+		return nil, "", err
+	}
+
+	if header.Magic != Magic {
+		return nil, "", fmt.Errorf("invalid magic %#x", header.Magic)
+	}
+
+	zeroed := header
+	zeroed.HeaderCRC = 0
+
+	var buf bytes.Buffer
+
+	binary.Write(&buf, binary.BigEndian, &zeroed) //nolint:errcheck
+
+	if crc := crc32.ChecksumIEEE(buf.Bytes()); crc != header.HeaderCRC {
+		return nil, "", fmt.Errorf("header checksum mismatch: %#x != %#x", crc, header.HeaderCRC)
+	}
+
+	data := image[headerSize:]
+
+	if uint32(len(data)) != header.Size {
+		return nil, "", fmt.Errorf("data size mismatch: %d != %d", len(data), header.Size)
+	}
+
+	if crc := crc32.ChecksumIEEE(data); crc != header.DataCRC {
+		return nil, "", fmt.Errorf("data checksum mismatch: %#x != %#x", crc, header.DataCRC)
+	}
+
+	if header.Type != TypeScript {
+		return nil, "", fmt.Errorf("unexpected image type %d", header.Type)
+	}
+
+	if len(data) < 8 || binary.BigEndian.Uint32(data[4:8]) != 0 {
+		return nil, "", fmt.Errorf("expected a single file in the script image")
+	}
+
+	size := binary.BigEndian.Uint32(data[:4])
+
+	if uint32(len(data)-8) < size {
+		return nil, "", fmt.Errorf("script is truncated")
+	}
+
+	return &header, string(data[8 : 8+size]), nil
+}
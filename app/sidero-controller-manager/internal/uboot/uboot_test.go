@@ -0,0 +1,70 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package uboot_test
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/uboot"
+)
+
+func TestScript(t *testing.T) {
+	t.Parallel()
+
+	const script = "echo hello\nboot\n"
+
+	image := uboot.Script("sidero boot script", script)
+
+	require.Len(t, image, 64+8+len(script))
+
+	assert.Equal(t, uint32(uboot.Magic), binary.BigEndian.Uint32(image[0:4]))
+	assert.Equal(t, uint32(8+len(script)), binary.BigEndian.Uint32(image[12:16]))
+	assert.Equal(t, []byte{uboot.OSLinux, uboot.ArchARM64, uboot.TypeScript, uboot.CompNone}, image[28:32])
+	assert.Equal(t, "sidero boot script", strings.TrimRight(string(image[32:64]), "\x00"))
+
+	// reproducible
+	assert.Equal(t, image, uboot.Script("sidero boot script", script))
+
+	header, decoded, err := uboot.Decode(image)
+	require.NoError(t, err)
+
+	assert.Equal(t, script, decoded)
+	assert.EqualValues(t, uboot.TypeScript, header.Type)
+
+	// name is truncated, keeping the NUL terminator
+	image = uboot.Script(strings.Repeat("x", 40), script)
+	assert.Equal(t, byte(0), image[63])
+
+	_, _, err = uboot.Decode(image)
+	require.NoError(t, err)
+}
+
+func TestDecodeCorrupted(t *testing.T) {
+	t.Parallel()
+
+	image := uboot.Script("test", "boot\n")
+
+	for name, corrupt := range map[string]func([]byte) []byte{
+		"short":     func(b []byte) []byte { return b[:32] },
+		"magic":     func(b []byte) []byte { b[0] = 0; return b },
+		"header":    func(b []byte) []byte { b[40] = 'x'; return b },
+		"data":      func(b []byte) []byte { b[len(b)-1] = 'x'; return b },
+		"truncated": func(b []byte) []byte { return b[:len(b)-1] },
+	} {
+		corrupt := corrupt
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, _, err := uboot.Decode(corrupt(append([]byte(nil), image...)))
+			assert.Error(t, err)
+		})
+	}
+}
@@ -8,12 +8,14 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"strings"
 	"time"
 
 	debug "github.com/talos-systems/go-debug"
+	"github.com/talos-systems/go-retry/retry"
 	"golang.org/x/net/http2"
 	"golang.org/x/net/http2/h2c"
 	"golang.org/x/sync/errgroup"
@@ -32,13 +34,26 @@ import (
 	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
 	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
 	"github.com/talos-systems/sidero/app/sidero-controller-manager/controllers"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/accesslog"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/assets"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/console"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/dhcp"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/dhcpv6"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/identity"
 	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/ipxe"
 	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/metadata"
 	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/power/api"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/power/ipmi"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/redfish"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/report"
 	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/server"
 	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/tftp"
 	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/provisioning"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/render"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/sitecache"
 	"github.com/talos-systems/sidero/internal/client"
+	"github.com/talos-systems/sidero/internal/dryrun"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -70,29 +85,85 @@ func main() {
 	var (
 		metricsAddr          string
 		apiEndpoint          string
+		extraAPIEndpoints    string
 		apiPort              int
 		extraAgentKernelArgs string
 		bootFromDiskMethod   string
 		enableLeaderElection bool
 		autoAcceptServers    bool
+		serverApprovals      int
 		insecureWipe         bool
+		wipeConfirmation     bool
 		autoBMCSetup         bool
 		serverRebootTimeout  time.Duration
+		warrantyWindow       time.Duration
+		assetEncodings       string
+		assetOnTheFly        bool
+		fleetReportDest      string
+		fleetReportInterval  time.Duration
+		fleetReportTeamLabel string
+		consoleAddr          string
+		consoleLogDir        string
+		consoleWindow        time.Duration
+		metadataLookup       string
+		patchesNamespaces    string
+		externalMachines     bool
+		accessLogDest        string
+		accessLogSampleRate  float64
+		dhcpv6Proxy          bool
+		dhcpv6Interfaces     string
+		dhcpServer           bool
+		dhcpInterfaces       string
+		webhookPort          int
+		provisioningAPIAddr  string
+		inventoryAPIAddr     string
+		identityWebhookURL   string
+		identityTimeout      time.Duration
+		identityFailure      string
+		dryRun               bool
 
 		testPowerSimulatedExplicitFailureProb float64
 		testPowerSimulatedSilentFailureProb   float64
 	)
 
 	flag.StringVar(&apiEndpoint, "api-endpoint", "", "The endpoint (hostname or IP address) Sidero can be reached at from the servers.")
+	flag.StringVar(&extraAPIEndpoints, "extra-api-endpoints", "", "A comma delimited list of fallback endpoints (hostnames or IP addresses) advertised to the servers after the api-endpoint, in the order of priority.")
 	flag.IntVar(&apiPort, "api-port", httpPort, "The TCP port Sidero components can be reached at from the servers.")
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8081", "The address the metric endpoint binds to.")
 	flag.StringVar(&extraAgentKernelArgs, "extra-agent-kernel-args", "", "A comma delimited list of key-value pairs to be added to the agent environment kernel parameters.")
 	flag.StringVar(&bootFromDiskMethod, "boot-from-disk-method", string(ipxe.BootIPXEExit), "Default method to use to boot server from disk if it hits iPXE endpoint after install.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", true, "Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
 	flag.BoolVar(&autoAcceptServers, "auto-accept-servers", false, "Add servers as 'accepted' when they register with Sidero API.")
+	flag.IntVar(&serverApprovals, "server-approvals", 0, "Number of distinct approvals required to accept a server (approval gate), disabled if zero.")
 	flag.BoolVar(&insecureWipe, "insecure-wipe", true, "Wipe head of the disk only (if false, wipe whole disk).")
+	flag.BoolVar(&wipeConfirmation, "wipe-confirmation", false, "Require the confirmation to wipe the disks with the existing data, unless the server was released by Sidero or is marked reusable.")
 	flag.BoolVar(&autoBMCSetup, "auto-bmc-setup", true, "Attempt to setup BMC info automatically when agent boots.")
 	flag.DurationVar(&serverRebootTimeout, "server-reboot-timeout", constants.DefaultServerRebootTimeout, "Timeout to wait for the server to restart and start wipe.")
+	flag.DurationVar(&warrantyWindow, "warranty-expiry-window", controllers.DefaultWarrantyExpiryWindow, "Time before the server warranty end the server is flagged as expiring warranty.")
+	flag.StringVar(&assetEncodings, "asset-encodings", "", "A comma delimited list of content encodings to precompress environment assets with, in the order of preference, disabled if empty.")
+	flag.BoolVar(&assetOnTheFly, "asset-on-the-fly-compression", false, "Compress environment assets on the fly if there's no matching precompressed variant.")
+	flag.StringVar(&fleetReportDest, "fleet-report-destination", "", "A directory or an HTTP(S) URL to write fleet reports (OpenMetrics and CSV) to, reports are disabled if empty.")
+	flag.DurationVar(&fleetReportInterval, "fleet-report-interval", time.Hour, "Interval between fleet reports.")
+	flag.StringVar(&fleetReportTeamLabel, "fleet-report-team-label", "", "Server or MetalMachine label to group allocated servers by team in fleet reports.")
+	flag.StringVar(&consoleAddr, "console-addr", "127.0.0.1:8082", "The address the console log endpoint binds to, disabled if empty.")
+	flag.StringVar(&consoleLogDir, "console-log-dir", "/var/lib/sidero/console", "Directory to keep the serial console logs of the servers in.")
+	flag.DurationVar(&consoleWindow, "console-provisioning-window", 30*time.Minute, "How long to capture the serial console after the server is PXE booted into the environment.")
+	flag.StringVar(&metadataLookup, "metadata-lookup", metadata.DefaultIdentifiers, "A comma delimited list of identifiers (uuid, mac, serial) the metadata server resolves servers by, in the order of fallback.")
+	flag.StringVar(&patchesNamespaces, "config-patches-namespaces", "", "A comma delimited list of namespaces the config patches, the install image keys, the canary configuration and the external machine Machines can be referenced from, the default namespace and the Sidero namespace if empty.")
+	flag.BoolVar(&externalMachines, "external-machines", false, "Serve the environments and the machine configuration to the machines not managed by Sidero allowlisted by MAC address with ExternalMachine resources.")
+	flag.StringVar(&accessLogDest, "access-log-destination", "", "Destination of the JSON access log of the iPXE, metadata and asset endpoints: stdout, a file path, or tcp:// or udp:// log collector address, disabled if empty.")
+	flag.Float64Var(&accessLogSampleRate, "access-log-sample-rate", 1, "Fraction of the successful requests written to the access log (failed requests are always written).")
+	flag.BoolVar(&dhcpv6Proxy, "dhcpv6-proxy", false, "Run DHCPv6 proxy supplying the network boot parameters to the servers on IPv6 provisioning networks (requires host network).")
+	flag.StringVar(&dhcpv6Interfaces, "dhcpv6-interfaces", "", "A comma delimited list of interfaces the DHCPv6 proxy listens on, all multicast capable interfaces if empty.")
+	flag.BoolVar(&dhcpServer, "dhcp-server", false, "Run DHCP server serving the leases reserved in the Servers to the installed nodes (requires host network).")
+	flag.StringVar(&dhcpInterfaces, "dhcp-interfaces", "", "A comma delimited list of interfaces the DHCP server listens on, all broadcast capable interfaces if empty.")
+	flag.IntVar(&webhookPort, "webhook-port", 0, "Webhook Server port, disabled by default.")
+	flag.StringVar(&provisioningAPIAddr, "provisioning-api-addr", "", "The address the provisioning events gRPC API (plaintext, unauthenticated, served by the leader) binds to, disabled if empty.")
+	flag.StringVar(&inventoryAPIAddr, "inventory-api-addr", "", "The address the Redfish hardware inventory API binds to, disabled if empty.")
+	flag.StringVar(&identityWebhookURL, "identity-webhook-url", "", "URL of the webhook consulted when an unknown machine registers with Sidero API, disabled if empty.")
+	flag.DurationVar(&identityTimeout, "identity-webhook-timeout", identity.DefaultTimeout, "Timeout of the identity webhook request.")
+	flag.StringVar(&identityFailure, "identity-webhook-failure-policy", string(identity.FailurePolicyFail), "How identity webhook failures are handled: fail (the machine retries the registration) or ignore (the machine registers as if there was no webhook).")
+	flag.BoolVar(&dryRun, "dry-run", false, "Log (and record as events) the actions the controllers would take (power management, wipes, changes to the resources) without executing them.")
 	flag.Float64Var(&testPowerSimulatedExplicitFailureProb, "test-power-simulated-explicit-failure-prob", 0, "Test failure simulation setting.")
 	flag.Float64Var(&testPowerSimulatedSilentFailureProb, "test-power-simulated-silent-failure-prob", 0, "Test failure simulation setting.")
 
@@ -112,6 +183,106 @@ func main() {
 		apiEndpoint = ""
 	}
 
+	if extraAPIEndpoints == "-" {
+		extraAPIEndpoints = ""
+	}
+
+	if assetEncodings == "-" {
+		assetEncodings = ""
+	}
+
+	if fleetReportDest == "-" {
+		fleetReportDest = ""
+	}
+
+	if fleetReportTeamLabel == "-" {
+		fleetReportTeamLabel = ""
+	}
+
+	if consoleAddr == "-" {
+		consoleAddr = ""
+	}
+
+	if dhcpv6Interfaces == "-" {
+		dhcpv6Interfaces = ""
+	}
+
+	if dhcpInterfaces == "-" {
+		dhcpInterfaces = ""
+	}
+
+	if accessLogDest == "-" {
+		accessLogDest = ""
+	}
+
+	if provisioningAPIAddr == "-" {
+		provisioningAPIAddr = ""
+	}
+
+	if inventoryAPIAddr == "-" {
+		inventoryAPIAddr = ""
+	}
+
+	if identityWebhookURL == "-" {
+		identityWebhookURL = ""
+	}
+
+	if autoAcceptServers && serverApprovals > 0 {
+		setupLog.Error(fmt.Errorf("--auto-accept-servers and --server-approvals are mutually exclusive"), "")
+		os.Exit(1)
+	}
+
+	// approvers are recorded by the ApproveAction webhook
+	if serverApprovals > 0 && webhookPort == 0 {
+		setupLog.Error(fmt.Errorf("--server-approvals requires --webhook-port"), "")
+		os.Exit(1)
+	}
+
+	encodings, err := assets.ParseEncodings(assetEncodings)
+	if err != nil {
+		setupLog.Error(err, "invalid asset encodings")
+		os.Exit(1)
+	}
+
+	lookupIdentifiers, err := metadata.ParseIdentifiers(metadataLookup)
+	if err != nil {
+		setupLog.Error(err, "invalid metadata lookup identifiers")
+		os.Exit(1)
+	}
+
+	// wipe certificates and their signing key are kept in the controller namespace
+	controllerNamespace := constants.SideroNamespace
+	if namespace, ok := os.LookupEnv("POD_NAMESPACE"); ok {
+		controllerNamespace = namespace
+	}
+
+	// references are resolved with the Sidero credentials, so they are restricted to the trusted namespaces
+	if patchesNamespaces == "" {
+		patchesNamespaces = corev1.NamespaceDefault
+
+		if namespace, ok := os.LookupEnv("POD_NAMESPACE"); ok {
+			patchesNamespaces += "," + namespace
+		}
+	}
+
+	refNamespaces, err := render.ParseRefNamespaces(patchesNamespaces)
+	if err != nil {
+		setupLog.Error(err, "invalid config patches namespaces")
+		os.Exit(1)
+	}
+
+	var identityWebhook *identity.Webhook
+
+	if identityWebhookURL != "" {
+		failurePolicy, err := identity.ParseFailurePolicy(identityFailure)
+		if err != nil {
+			setupLog.Error(err, "invalid identity webhook failure policy")
+			os.Exit(1)
+		}
+
+		identityWebhook = identity.New(identityWebhookURL, identityTimeout, failurePolicy)
+	}
+
 	if apiEndpoint == "" {
 		if endpoint, ok := os.LookupEnv("API_ENDPOINT"); ok {
 			apiEndpoint = endpoint
@@ -121,6 +292,14 @@ func main() {
 		}
 	}
 
+	var fallbackEndpoints []string
+
+	for _, endpoint := range strings.Split(extraAPIEndpoints, ",") {
+		if endpoint = strings.TrimSpace(endpoint); endpoint != "" {
+			fallbackEndpoints = append(fallbackEndpoints, endpoint)
+		}
+	}
+
 	ctrl.SetLogger(zap.New(func(o *zap.Options) {
 		o.Development = true
 	}))
@@ -138,13 +317,21 @@ func main() {
 	// only for testing, doesn't affect production, default values simulate no failures
 	api.DefaultDice = api.NewFailureDice(testPowerSimulatedExplicitFailureProb, testPowerSimulatedSilentFailureProb)
 
-	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
+	options := ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
 		LeaderElection:     enableLeaderElection,
 		LeaderElectionID:   "controller-leader-election-sidero-controller-manager",
-		Port:               9443,
-	})
+		Port:               webhookPort,
+	}
+
+	if dryRun {
+		setupLog.Info("running in the dry-run mode, no changes are made")
+
+		options.NewClient = dryrun.NewClientFunc(ctrl.Log.WithName("dry-run"))
+	}
+
+	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
@@ -173,11 +360,31 @@ func main() {
 		TalosRelease: TalosRelease,
 		APIEndpoint:  apiEndpoint,
 		APIPort:      uint16(apiPort),
+
+		AssetEncodings: encodings,
 	}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: defaultMaxConcurrentReconciles}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Environment")
 		os.Exit(1)
 	}
 
+	siteCacheUploads := sitecache.NewRunner()
+
+	if err = mgr.Add(siteCacheUploads); err != nil {
+		setupLog.Error(err, "unable to create site cache uploads runner")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.SiteCacheReconciler{
+		Client:  mgr.GetClient(),
+		Log:     ctrl.Log.WithName("controllers").WithName("SiteCache"),
+		Scheme:  mgr.GetScheme(),
+		Uploads: siteCacheUploads,
+		DryRun:  dryRun,
+	}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: defaultMaxConcurrentReconciles}); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "SiteCache")
+		os.Exit(1)
+	}
+
 	if err = (&controllers.ServerReconciler{
 		Client:        mgr.GetClient(),
 		Log:           ctrl.Log.WithName("controllers").WithName("Server"),
@@ -185,6 +392,9 @@ func main() {
 		APIReader:     mgr.GetAPIReader(),
 		Recorder:      recorder,
 		RebootTimeout: serverRebootTimeout,
+
+		RequiredApprovals: serverApprovals,
+		DryRun:            dryRun,
 	}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: defaultMaxConcurrentReconciles}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Server")
 		os.Exit(1)
@@ -194,33 +404,223 @@ func main() {
 		Client: mgr.GetClient(),
 		Log:    ctrl.Log.WithName("controllers").WithName("ServerClass"),
 		Scheme: mgr.GetScheme(),
+
+		RequiredApprovals: serverApprovals,
 	}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: defaultMaxConcurrentReconciles}); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ServerClass")
 		os.Exit(1)
 	}
+
+	if err = (&controllers.ServerAcceptancePolicyReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("ServerAcceptancePolicy"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: recorder,
+
+		RequiredApprovals: serverApprovals,
+	}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: 1}); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ServerAcceptancePolicy")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.ServerActionReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("ServerAction"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: recorder,
+
+		RequiredApprovals: serverApprovals,
+		DryRun:            dryRun,
+	}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: defaultMaxConcurrentReconciles}); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "ServerAction")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.CanaryReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("Canary"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: recorder,
+
+		RefNamespaces: refNamespaces,
+		DryRun:        dryRun,
+	}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: 1}); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Canary")
+		os.Exit(1)
+	}
+
+	consoleManager := console.NewManager(ctrl.Log.WithName("console"), console.Options{
+		Directory: consoleLogDir,
+		Dialer:    ipmi.ActivateSOL,
+	})
+
+	if err = mgr.Add(consoleManager); err != nil {
+		setupLog.Error(err, "unable to create console manager")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.ConsoleReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("Console"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: recorder,
+		Console:  consoleManager,
+
+		ProvisioningWindow: consoleWindow,
+	}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: defaultMaxConcurrentReconciles}); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Console")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.CredentialsReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("Credentials"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: recorder,
+	}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: defaultMaxConcurrentReconciles}); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Credentials")
+		os.Exit(1)
+	}
+
+	if err = (&controllers.WarrantyReconciler{
+		Client:   mgr.GetClient(),
+		Log:      ctrl.Log.WithName("controllers").WithName("Warranty"),
+		Scheme:   mgr.GetScheme(),
+		Recorder: recorder,
+
+		ExpiryWindow: warrantyWindow,
+	}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: defaultMaxConcurrentReconciles}); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "Warranty")
+		os.Exit(1)
+	}
+
+	provisioningBroker := provisioning.NewBroker(provisioning.DefaultHistorySize)
+
+	if provisioningAPIAddr != "" {
+		if err = (&controllers.ProvisioningReconciler{
+			Client: mgr.GetClient(),
+			Log:    ctrl.Log.WithName("controllers").WithName("Provisioning"),
+			Broker: provisioningBroker,
+		}).SetupWithManager(mgr, controller.Options{MaxConcurrentReconciles: 1}); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "Provisioning")
+			os.Exit(1)
+		}
+
+		// the broker is started on the leader only, the events are not served by the other replicas
+		if err = mgr.Add(provisioningBroker); err != nil {
+			setupLog.Error(err, "unable to create provisioning broker")
+			os.Exit(1)
+		}
+	}
+
+	if webhookPort != 0 {
+		if err = (&metalv1alpha1.Environment{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Environment")
+			os.Exit(1)
+		}
+
+		metalv1alpha1.SetupApproverWebhookWithManager(mgr)
+	}
 	// +kubebuilder:scaffold:builder
 
+	if fleetReportDest != "" {
+		if err = mgr.Add(report.NewReporter(mgr.GetAPIReader(), ctrl.Log.WithName("report"), report.Options{
+			Destination: fleetReportDest,
+			Interval:    fleetReportInterval,
+			TeamLabel:   fleetReportTeamLabel,
+		})); err != nil {
+			setupLog.Error(err, "unable to create fleet reporter")
+			os.Exit(1)
+		}
+	}
+
 	setupLog.Info("starting TFTP server")
 
 	go func() {
-		if err := tftp.ServeTFTP(); err != nil {
+		if err := tftp.ServeTFTP(ipxe.UBootScript); err != nil {
 			setupLog.Error(err, "unable to start TFTP server", "controller", "Environment")
 			os.Exit(1)
 		}
 	}()
 
+	if dhcpv6Proxy {
+		var interfaces []string
+
+		for _, iface := range strings.Split(dhcpv6Interfaces, ",") {
+			if iface = strings.TrimSpace(iface); iface != "" {
+				interfaces = append(interfaces, iface)
+			}
+		}
+
+		setupLog.Info("starting DHCPv6 proxy")
+
+		go func() {
+			if err := dhcpv6.ServeDHCPv6(dhcpv6.ProxyOptions{
+				Interfaces: interfaces,
+				Endpoint:   apiEndpoint,
+				Port:       apiPort,
+			}); err != nil {
+				setupLog.Error(err, "unable to start DHCPv6 proxy")
+				os.Exit(1)
+			}
+		}()
+	}
+
+	if dhcpServer {
+		var interfaces []string
+
+		for _, iface := range strings.Split(dhcpInterfaces, ",") {
+			if iface = strings.TrimSpace(iface); iface != "" {
+				interfaces = append(interfaces, iface)
+			}
+		}
+
+		setupLog.Info("starting DHCP server")
+
+		go func() {
+			if err := dhcp.ServeDHCP(context.Background(), dhcp.ServerOptions{
+				Interfaces: interfaces,
+				Leases:     &dhcp.ServerLeases{Reader: mgr.GetClient()},
+			}); err != nil {
+				setupLog.Error(err, "unable to start DHCP server")
+				os.Exit(1)
+			}
+		}()
+	}
+
 	httpMux := http.NewServeMux()
 
+	accessLogger := &accesslog.Logger{
+		SampleRate: accessLogSampleRate,
+	}
+
+	if accessLogDest != "" {
+		sink, err := accesslog.OpenSink(accessLogDest)
+		if err != nil {
+			setupLog.Error(err, "unable to open access log destination")
+			os.Exit(1)
+		}
+
+		defer sink.Close() //nolint:errcheck
+
+		accessLogger.Sink = sink
+	}
+
+	httpHandler := accessLogger.Handler(httpMux)
+
 	setupLog.Info("starting iPXE server")
 
-	if err := ipxe.RegisterIPXE(httpMux, apiEndpoint, apiPort, extraAgentKernelArgs, ipxe.BootFromDisk(bootFromDiskMethod), apiPort, mgr.GetClient()); err != nil {
+	if err := ipxe.RegisterIPXE(httpMux, apiEndpoint, fallbackEndpoints, apiPort, extraAgentKernelArgs, ipxe.BootFromDisk(bootFromDiskMethod), apiPort, assets.Options{
+		Encodings: encodings,
+		OnTheFly:  assetOnTheFly,
+	}, externalMachines, mgr.GetClient()); err != nil {
 		setupLog.Error(err, "unable to start iPXE server", "controller", "Environment")
 		os.Exit(1)
 	}
 
 	setupLog.Info("starting metadata server")
 
-	if err := metadata.RegisterServer(httpMux, mgr.GetClient()); err != nil {
+	if err := metadata.RegisterServer(httpMux, mgr.GetClient(), lookupIdentifiers, externalMachines, refNamespaces); err != nil {
 		setupLog.Error(err, "unable to start metadata server", "controller", "Environment")
 		os.Exit(1)
 	}
@@ -231,7 +631,7 @@ func main() {
 		mgr.GetScheme(),
 		corev1.EventSource{Component: "sidero-server"})
 
-	grpcServer := server.CreateServer(mgr.GetClient(), apiRecorder, mgr.GetScheme(), autoAcceptServers, insecureWipe, autoBMCSetup, serverRebootTimeout)
+	grpcServer := server.CreateServer(mgr.GetClient(), apiRecorder, mgr.GetScheme(), autoAcceptServers, insecureWipe, autoBMCSetup, wipeConfirmation, dryRun, identityWebhook, serverApprovals, controllerNamespace, serverRebootTimeout)
 
 	k8sClient, err := client.NewClient(nil)
 	if err != nil {
@@ -239,20 +639,43 @@ func main() {
 		os.Exit(1)
 	}
 
+	if dryRun {
+		k8sClient = dryrun.NewClient(k8sClient, scheme, ctrl.Log.WithName("dry-run"))
+	}
+
 	if err = controllers.ReconcileServerClassAny(context.TODO(), k8sClient); err != nil {
 		setupLog.Error(err, `failed to reconcile ServerClass "any"`)
 		os.Exit(1)
 	}
 
-	if err = controllers.ReconcileEnvironmentDefault(context.TODO(), k8sClient, TalosRelease, apiEndpoint, uint16(apiPort)); err != nil {
-		setupLog.Error(err, `failed to reconcile Environment "default"`)
-		os.Exit(1)
+	reconcileEnvironmentDefault := func() error {
+		return controllers.ReconcileEnvironmentDefault(context.TODO(), k8sClient, TalosRelease, apiEndpoint, uint16(apiPort))
+	}
+
+	// Environment writes are rejected until the validating webhook is served by the manager
+	if webhookPort == 0 {
+		if err = reconcileEnvironmentDefault(); err != nil {
+			setupLog.Error(err, `failed to reconcile Environment "default"`)
+			os.Exit(1)
+		}
 	}
 
 	setupLog.Info("starting manager and HTTP server")
 
 	var eg errgroup.Group
 
+	if webhookPort != 0 {
+		go func() {
+			err := retry.Constant(time.Minute, retry.WithUnits(time.Second), retry.WithErrorLogging(true)).Retry(func() error {
+				return retry.ExpectedError(reconcileEnvironmentDefault())
+			})
+			if err != nil {
+				setupLog.Error(err, `failed to reconcile Environment "default"`)
+				os.Exit(1)
+			}
+		}()
+	}
+
 	eg.Go(func() error {
 		err := mgr.Start(ctrl.SetupSignalHandler())
 		if err != nil {
@@ -280,7 +703,7 @@ func main() {
 			}
 
 			// httpMux contains iPXE server and metadata server handlers
-			httpMux.ServeHTTP(w, req)
+			httpHandler.ServeHTTP(w, req)
 		})
 
 		err := http.ListenAndServe(fmt.Sprintf(":%d", httpPort), h2c.NewHandler(grpcHandler, h2s))
@@ -291,6 +714,57 @@ func main() {
 		return err
 	})
 
+	if consoleAddr != "" {
+		consoleMux := http.NewServeMux()
+		consoleManager.RegisterHandler(consoleMux)
+
+		eg.Go(func() error {
+			// console logs are not exposed on the public HTTP endpoint, as they might contain sensitive information
+			err := http.ListenAndServe(consoleAddr, consoleMux)
+			if err != nil {
+				setupLog.Error(err, "problem running console HTTP server")
+			}
+
+			return err
+		})
+	}
+
+	if inventoryAPIAddr != "" {
+		inventoryMux := http.NewServeMux()
+		redfish.RegisterHandler(inventoryMux, mgr.GetClient())
+
+		eg.Go(func() error {
+			// inventory is served without authentication, so it's not exposed on the public HTTP endpoint
+			err := http.ListenAndServe(inventoryAPIAddr, inventoryMux)
+			if err != nil {
+				setupLog.Error(err, "problem running inventory HTTP server")
+			}
+
+			return err
+		})
+	}
+
+	if provisioningAPIAddr != "" {
+		provisioningServer := provisioning.CreateServer(provisioningBroker)
+
+		eg.Go(func() error {
+			lis, err := net.Listen("tcp", provisioningAPIAddr)
+			if err != nil {
+				setupLog.Error(err, "problem listening for provisioning API")
+
+				return err
+			}
+
+			// provisioning API is not exposed on the public HTTP endpoint, as it's not meant to be accessed by the servers
+			err = provisioningServer.Serve(lis)
+			if err != nil {
+				setupLog.Error(err, "problem running provisioning API server")
+			}
+
+			return err
+		})
+	}
+
 	if err := eg.Wait(); err != nil {
 		os.Exit(1)
 	}
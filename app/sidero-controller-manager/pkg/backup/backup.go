@@ -0,0 +1,285 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package backup implements export and import of the Sidero state for the management cluster disaster recovery.
+//
+// Bundle contains Sidero and CAPS resources (with the status), Secrets and ConfigMaps referenced by them
+// and the checksums of the downloaded environment assets. Restoring the bundle on a new management cluster
+// re-links the servers to their bindings, so servers in use are not wiped and reprovisioned.
+package backup
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/hwdiff"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/render"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/wipecert"
+)
+
+// Version of the bundle format.
+const Version = "v1"
+
+// Metadata describes the bundle.
+type Metadata struct {
+	Version   string        `json:"version"`
+	CreatedAt time.Time     `json:"createdAt"`
+	Assets    []AssetDigest `json:"assets,omitempty"`
+}
+
+// AssetDigest is the checksum of the environment asset downloaded by Sidero.
+type AssetDigest struct {
+	Environment string `json:"environment"`
+	URL         string `json:"url"`
+	SHA512      string `json:"sha512"`
+}
+
+// Bundle is the exported Sidero state.
+type Bundle struct {
+	Metadata Metadata
+	// Objects in the restore order.
+	Objects []runtime.Object
+}
+
+var scheme = runtime.NewScheme()
+
+func init() {
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(metalv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(infrav1.AddToScheme(scheme))
+}
+
+// lists of the exported resources in the restore order.
+//
+// Servers are restored last, so that they are already bound when Sidero starts to reconcile them.
+func lists() []runtime.Object {
+	return []runtime.Object{
+		&metalv1alpha1.EnvironmentList{},
+		&metalv1alpha1.ServerClassList{},
+		&metalv1alpha1.ServerAcceptancePolicyList{},
+		&infrav1.MetalClusterList{},
+		&infrav1.MetalMachineTemplateList{},
+		&infrav1.MetalMachineList{},
+		&infrav1.ServerBindingList{},
+		&metalv1alpha1.ServerList{},
+	}
+}
+
+// Export the Sidero state from the management cluster.
+func Export(ctx context.Context, c client.Client) (*Bundle, error) {
+	bundle := &Bundle{
+		Metadata: Metadata{
+			Version:   Version,
+			CreatedAt: time.Now().UTC(),
+		},
+	}
+
+	var (
+		objects    []runtime.Object
+		secrets    = map[types.NamespacedName]struct{}{}
+		configMaps = map[types.NamespacedName]struct{}{}
+	)
+
+	addPatchRefs := func(refs []metalv1alpha1.ConfigPatchesRef) {
+		for _, ref := range refs {
+			key := types.NamespacedName{Namespace: render.RefNamespace(ref), Name: ref.Name}
+
+			if ref.Kind == "Secret" {
+				secrets[key] = struct{}{}
+			} else {
+				configMaps[key] = struct{}{}
+			}
+		}
+	}
+
+	addCredentialRef := func(source *metalv1alpha1.CredentialSource) {
+		if source != nil && source.SecretKeyRef != nil {
+			secrets[types.NamespacedName{Namespace: source.SecretKeyRef.Namespace, Name: source.SecretKeyRef.Name}] = struct{}{}
+		}
+	}
+
+	for _, list := range lists() {
+		if err := c.List(ctx, list); err != nil {
+			return nil, err
+		}
+
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, item := range items {
+			switch obj := item.(type) {
+			case *metalv1alpha1.Environment:
+				addPatchRefs(obj.Spec.ConfigPatchesFrom)
+
+				bundle.Metadata.Assets = append(bundle.Metadata.Assets, assetDigests(obj)...)
+			case *metalv1alpha1.ServerClass:
+				addPatchRefs(obj.Spec.ConfigPatchesFrom)
+			case *metalv1alpha1.Server:
+				addPatchRefs(obj.Spec.ConfigPatchesFrom)
+
+				if obj.Spec.BMC != nil {
+					addCredentialRef(obj.Spec.BMC.UserFrom)
+					addCredentialRef(obj.Spec.BMC.PassFrom)
+				}
+			case *infrav1.ServerBinding:
+				addPatchRefs(obj.Spec.ConfigPatchesFrom)
+			}
+
+			objects = append(objects, item)
+		}
+	}
+
+	// wipe certificates can be verified on the new cluster with the same key
+	secrets[types.NamespacedName{Namespace: constants.SideroNamespace, Name: wipecert.KeySecretName}] = struct{}{}
+
+	for _, key := range sortedKeys(secrets) {
+		var secret corev1.Secret
+
+		if err := get(ctx, c, key, &secret); err != nil {
+			return nil, err
+		}
+
+		if secret.Name != "" {
+			bundle.Objects = append(bundle.Objects, &secret)
+		}
+	}
+
+	for _, key := range sortedKeys(configMaps) {
+		var cm corev1.ConfigMap
+
+		if err := get(ctx, c, key, &cm); err != nil {
+			return nil, err
+		}
+
+		if cm.Name != "" {
+			bundle.Objects = append(bundle.Objects, &cm)
+		}
+	}
+
+	// wipe certificates and hardware revisions are the history of the servers
+	for _, selector := range []client.ListOption{
+		client.MatchingLabels{wipecert.CertificateLabel: "true"},
+		client.HasLabels{hwdiff.RevisionLabel},
+	} {
+		var history corev1.ConfigMapList
+
+		if err := c.List(ctx, &history, selector); err != nil {
+			return nil, err
+		}
+
+		for i := range history.Items {
+			if _, ok := configMaps[types.NamespacedName{Namespace: history.Items[i].Namespace, Name: history.Items[i].Name}]; !ok {
+				bundle.Objects = append(bundle.Objects, &history.Items[i])
+			}
+		}
+	}
+
+	bundle.Objects = append(bundle.Objects, objects...)
+
+	filtered := bundle.Objects[:0]
+
+	for _, obj := range bundle.Objects {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		// objects being deleted are not restored
+		if accessor.GetDeletionTimestamp() != nil {
+			continue
+		}
+
+		if err = sanitize(obj); err != nil {
+			return nil, err
+		}
+
+		filtered = append(filtered, obj)
+	}
+
+	bundle.Objects = filtered
+
+	return bundle, nil
+}
+
+// get the object, missing objects are skipped, as the references are already broken.
+func get(ctx context.Context, c client.Client, key types.NamespacedName, obj runtime.Object) error {
+	if err := c.Get(ctx, key, obj); err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("error getting %s: %w", key, err)
+	}
+
+	return nil
+}
+
+func sortedKeys(m map[types.NamespacedName]struct{}) []types.NamespacedName {
+	keys := make([]types.NamespacedName, 0, len(m))
+
+	for key := range m {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	return keys
+}
+
+// assetDigests returns the checksums of the assets downloaded and verified by Sidero.
+func assetDigests(env *metalv1alpha1.Environment) []AssetDigest {
+	var digests []AssetDigest
+
+	for _, condition := range env.Status.Conditions {
+		if condition.Type == "Ready" && condition.Status == "True" && condition.URL != "" && condition.SHA512 != "" {
+			digests = append(digests, AssetDigest{
+				Environment: env.Name,
+				URL:         condition.URL,
+				SHA512:      condition.SHA512,
+			})
+		}
+	}
+
+	return digests
+}
+
+// sanitize removes the fields set by the API server, and sets the object kind.
+func sanitize(obj runtime.Object) error {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return err
+	}
+
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+
+	accessor.SetUID("")
+	accessor.SetResourceVersion("")
+	accessor.SetSelfLink("")
+	accessor.SetGeneration(0)
+	accessor.SetCreationTimestamp(metav1.Time{})
+	accessor.SetManagedFields(nil)
+
+	return nil
+}
@@ -0,0 +1,299 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package backup_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/backup"
+)
+
+const serverUUID = "4c4c4544-0039-3010-8048-b7c04f384432"
+
+func newScheme(t *testing.T) *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+	require.NoError(t, infrav1.AddToScheme(scheme))
+
+	return scheme
+}
+
+func sourceObjects() []runtime.Object {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: corev1.NamespaceDefault,
+			Name:      serverUUID + "-bmc",
+			UID:       "old-secret",
+			OwnerReferences: []metav1.OwnerReference{
+				{
+					APIVersion: metalv1alpha1.GroupVersion.String(),
+					Kind:       "Server",
+					Name:       serverUUID,
+					UID:        "old-server",
+				},
+			},
+		},
+		Data: map[string][]byte{"user": []byte("admin"), "pass": []byte("secret")},
+	}
+
+	patches := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "metal",
+			Name:      "patches",
+		},
+		Data: map[string]string{"patches": "[]"},
+	}
+
+	unrelated := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: corev1.NamespaceDefault,
+			Name:      "unrelated",
+		},
+	}
+
+	env := &metalv1alpha1.Environment{
+		ObjectMeta: metav1.ObjectMeta{Name: "default"},
+		Spec: metalv1alpha1.EnvironmentSpec{
+			Kernel: metalv1alpha1.Kernel{
+				Asset: metalv1alpha1.Asset{URL: "https://example.com/vmlinuz"},
+			},
+			Initrd: metalv1alpha1.Initrd{
+				Asset: metalv1alpha1.Asset{URL: "https://example.com/initramfs.xz"},
+			},
+		},
+		Status: metalv1alpha1.EnvironmentStatus{
+			Conditions: []metalv1alpha1.AssetCondition{
+				{Asset: metalv1alpha1.Asset{URL: "https://example.com/vmlinuz", SHA512: "kernelsha"}, Type: "Ready", Status: "True"},
+				{Asset: metalv1alpha1.Asset{URL: "https://example.com/initramfs.xz", SHA512: "initrdsha"}, Type: "Ready", Status: "True"},
+			},
+		},
+	}
+
+	server := &metalv1alpha1.Server{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: serverUUID,
+			UID:  "old-server",
+		},
+		Spec: metalv1alpha1.ServerSpec{
+			Accepted: true,
+			BMC: &metalv1alpha1.BMC{
+				Endpoint: "10.5.0.10",
+				UserFrom: &metalv1alpha1.CredentialSource{
+					SecretKeyRef: &metalv1alpha1.SecretKeyRef{Namespace: corev1.NamespaceDefault, Name: serverUUID + "-bmc", Key: "user"},
+				},
+				PassFrom: &metalv1alpha1.CredentialSource{
+					SecretKeyRef: &metalv1alpha1.SecretKeyRef{Namespace: corev1.NamespaceDefault, Name: serverUUID + "-bmc", Key: "pass"},
+				},
+			},
+		},
+		Status: metalv1alpha1.ServerStatus{
+			InUse: true,
+			Power: "on",
+		},
+	}
+
+	metalMachine := &infrav1.MetalMachine{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "metal",
+			Name:      "worker-1",
+			UID:       "old-metalmachine",
+		},
+		Spec: infrav1.MetalMachineSpec{
+			ServerRef: &corev1.ObjectReference{Kind: "Server", Name: serverUUID},
+		},
+		Status: infrav1.MetalMachineStatus{
+			Ready: true,
+		},
+	}
+
+	binding := &infrav1.ServerBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: serverUUID,
+		},
+		Spec: infrav1.ServerBindingSpec{
+			MetalMachineRef: corev1.ObjectReference{
+				Kind:      "MetalMachine",
+				Namespace: "metal",
+				Name:      "worker-1",
+				UID:       "old-metalmachine",
+			},
+			ConfigPatchesFrom: []metalv1alpha1.ConfigPatchesRef{
+				{Kind: "ConfigMap", Namespace: "metal", Name: "patches"},
+			},
+		},
+		Status: infrav1.ServerBindingState{
+			Ready: true,
+		},
+	}
+
+	return []runtime.Object{secret, patches, unrelated, env, server, metalMachine, binding}
+}
+
+func TestExportRestore(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	scheme := newScheme(t)
+
+	source := fake.NewFakeClientWithScheme(scheme, sourceObjects()...)
+
+	exported, err := backup.Export(ctx, source)
+	require.NoError(t, err)
+
+	assert.Equal(t, []backup.AssetDigest{
+		{Environment: "default", URL: "https://example.com/vmlinuz", SHA512: "kernelsha"},
+		{Environment: "default", URL: "https://example.com/initramfs.xz", SHA512: "initrdsha"},
+	}, exported.Metadata.Assets)
+
+	var buf bytes.Buffer
+
+	require.NoError(t, exported.Write(&buf))
+
+	bundle, err := backup.Read(&buf)
+	require.NoError(t, err)
+
+	kinds := make([]string, 0, len(bundle.Objects))
+
+	for _, obj := range bundle.Objects {
+		kinds = append(kinds, obj.GetObjectKind().GroupVersionKind().Kind)
+	}
+
+	assert.Equal(t, []string{"Secret", "ConfigMap", "Environment", "MetalMachine", "ServerBinding", "Server"}, kinds)
+
+	t.Run("dry run", func(t *testing.T) {
+		t.Parallel()
+
+		target := fake.NewFakeClientWithScheme(scheme)
+
+		results, err := backup.Restore(ctx, target, bundle, backup.RestoreOptions{DryRun: true})
+		require.NoError(t, err)
+
+		require.Len(t, results, len(bundle.Objects))
+
+		for _, result := range results {
+			assert.Equal(t, backup.ActionCreated, result.Action)
+		}
+
+		var servers metalv1alpha1.ServerList
+
+		require.NoError(t, target.List(ctx, &servers))
+		assert.Empty(t, servers.Items)
+	})
+
+	t.Run("restore", func(t *testing.T) {
+		t.Parallel()
+
+		existing := &metalv1alpha1.Environment{
+			ObjectMeta: metav1.ObjectMeta{Name: "default"},
+			Spec: metalv1alpha1.EnvironmentSpec{
+				Kernel: metalv1alpha1.Kernel{
+					Asset: metalv1alpha1.Asset{URL: "https://example.com/other"},
+				},
+			},
+		}
+
+		// e.g. moved with the Cluster API objects
+		movedMetalMachine := &infrav1.MetalMachine{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "metal",
+				Name:      "worker-1",
+				UID:       "new-metalmachine",
+			},
+			Status: infrav1.MetalMachineStatus{
+				Ready: true,
+			},
+		}
+
+		target := fake.NewFakeClientWithScheme(scheme, existing, movedMetalMachine)
+
+		results, err := backup.Restore(ctx, target, bundle, backup.RestoreOptions{})
+		require.NoError(t, err)
+
+		require.Len(t, results, len(bundle.Objects))
+		assert.Equal(t, backup.ActionExists, results[2].Action)
+		assert.Equal(t, backup.ActionExists, results[3].Action)
+
+		var env metalv1alpha1.Environment
+
+		require.NoError(t, target.Get(ctx, types.NamespacedName{Name: "default"}, &env))
+		assert.Equal(t, "https://example.com/other", env.Spec.Kernel.URL, "existing objects are not updated")
+
+		var server metalv1alpha1.Server
+
+		require.NoError(t, target.Get(ctx, types.NamespacedName{Name: serverUUID}, &server))
+		assert.True(t, server.Status.InUse)
+		assert.NotContains(t, server.Annotations, clusterv1.PausedAnnotation)
+
+		var metalMachine infrav1.MetalMachine
+
+		require.NoError(t, target.Get(ctx, types.NamespacedName{Namespace: "metal", Name: "worker-1"}, &metalMachine))
+		assert.True(t, metalMachine.Status.Ready)
+
+		var binding infrav1.ServerBinding
+
+		require.NoError(t, target.Get(ctx, types.NamespacedName{Name: serverUUID}, &binding))
+		assert.True(t, binding.Status.Ready)
+		assert.EqualValues(t, "new-metalmachine", binding.Spec.MetalMachineRef.UID)
+
+		var secret corev1.Secret
+
+		require.NoError(t, target.Get(ctx, types.NamespacedName{Namespace: corev1.NamespaceDefault, Name: serverUUID + "-bmc"}, &secret))
+		require.Len(t, secret.OwnerReferences, 1)
+		assert.Equal(t, server.UID, secret.OwnerReferences[0].UID)
+		assert.NotEqualValues(t, "old-server", secret.OwnerReferences[0].UID)
+
+		var ns corev1.Namespace
+
+		assert.NoError(t, target.Get(ctx, types.NamespacedName{Name: "metal"}, &ns))
+	})
+
+	t.Run("keep paused and pin assets", func(t *testing.T) {
+		t.Parallel()
+
+		target := fake.NewFakeClientWithScheme(scheme)
+
+		_, err := backup.Restore(ctx, target, bundle, backup.RestoreOptions{KeepPaused: true, PinAssets: true})
+		require.NoError(t, err)
+
+		var server metalv1alpha1.Server
+
+		require.NoError(t, target.Get(ctx, types.NamespacedName{Name: serverUUID}, &server))
+		assert.Equal(t, "true", server.Annotations[clusterv1.PausedAnnotation])
+
+		var env metalv1alpha1.Environment
+
+		require.NoError(t, target.Get(ctx, types.NamespacedName{Name: "default"}, &env))
+		assert.Equal(t, "kernelsha", env.Spec.Kernel.SHA512)
+		assert.Equal(t, "initrdsha", env.Spec.Initrd.SHA512)
+	})
+}
+
+func TestReadInvalid(t *testing.T) {
+	t.Parallel()
+
+	bundle := &backup.Bundle{Metadata: backup.Metadata{Version: "v0"}}
+
+	var buf bytes.Buffer
+
+	require.NoError(t, bundle.Write(&buf))
+
+	_, err := backup.Read(&buf)
+	assert.EqualError(t, err, `unsupported bundle version "v0"`)
+}
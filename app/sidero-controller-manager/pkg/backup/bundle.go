@@ -0,0 +1,146 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+)
+
+// Names of the files in the bundle tarball.
+const (
+	MetadataFile       = "metadata.yaml"
+	ResourcesDirectory = "resources"
+)
+
+// Write the bundle as a gzipped tarball.
+//
+// Resources are stored as YAML manifests prefixed with the index to keep the restore order.
+func (bundle *Bundle) Write(w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	writeFile := func(name string, data []byte) error {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0o600,
+			Size:    int64(len(data)),
+			ModTime: bundle.Metadata.CreatedAt,
+		}); err != nil {
+			return err
+		}
+
+		_, err := tw.Write(data)
+
+		return err
+	}
+
+	data, err := yaml.Marshal(bundle.Metadata)
+	if err != nil {
+		return err
+	}
+
+	if err = writeFile(MetadataFile, data); err != nil {
+		return err
+	}
+
+	for i, obj := range bundle.Objects {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+
+		name := accessor.GetName()
+		if accessor.GetNamespace() != "" {
+			name = accessor.GetNamespace() + "-" + name
+		}
+
+		if data, err = yaml.Marshal(obj); err != nil {
+			return err
+		}
+
+		if err = writeFile(path.Join(ResourcesDirectory, fmt.Sprintf("%04d-%s-%s.yaml", i, strings.ToLower(obj.GetObjectKind().GroupVersionKind().Kind), name)), data); err != nil {
+			return err
+		}
+	}
+
+	if err = tw.Close(); err != nil {
+		return err
+	}
+
+	return gz.Close()
+}
+
+// Read the bundle written with Write.
+func Read(r io.Reader) (*Bundle, error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	defer gz.Close() //nolint:errcheck
+
+	var (
+		bundle       Bundle
+		metadataSeen bool
+		decoder      = serializer.NewCodecFactory(scheme).UniversalDeserializer()
+		tr           = tar.NewReader(gz)
+	)
+
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case header.Name == MetadataFile:
+			if err = yaml.Unmarshal(data, &bundle.Metadata); err != nil {
+				return nil, fmt.Errorf("error decoding %s: %w", header.Name, err)
+			}
+
+			if bundle.Metadata.Version != Version {
+				return nil, fmt.Errorf("unsupported bundle version %q", bundle.Metadata.Version)
+			}
+
+			metadataSeen = true
+		case path.Dir(header.Name) == ResourcesDirectory:
+			obj, _, err := decoder.Decode(bytes.TrimSpace(data), nil, nil)
+			if err != nil {
+				return nil, fmt.Errorf("error decoding %s: %w", header.Name, err)
+			}
+
+			bundle.Objects = append(bundle.Objects, obj)
+		default:
+			return nil, fmt.Errorf("unexpected file %q in the bundle", header.Name)
+		}
+	}
+
+	if !metadataSeen {
+		return nil, fmt.Errorf("%s is missing in the bundle", MetadataFile)
+	}
+
+	return &bundle, nil
+}
@@ -0,0 +1,443 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package backup
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// RestoreOptions control the restore.
+type RestoreOptions struct {
+	// DryRun reports the actions without changing anything.
+	DryRun bool
+	// PinAssets sets the checksums of the environment assets recorded in the bundle, if they are not pinned in the spec.
+	//
+	// New management cluster downloads the assets again, so pinning the checksums makes sure servers boot the same assets.
+	PinAssets bool
+	// KeepPaused leaves the restored servers paused, so that they can be checked before Sidero starts managing them.
+	KeepPaused bool
+}
+
+// Action taken for the object.
+type Action string
+
+// Restore actions.
+const (
+	// ActionCreated is reported for the objects created (or to be created in the dry run mode).
+	ActionCreated Action = "created"
+	// ActionExists is reported for the objects which already exist, they are not updated.
+	ActionExists Action = "exists"
+)
+
+// Result of restoring an object.
+type Result struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Action    Action
+	// Message with the details, e.g. dropped owner references.
+	Message string
+}
+
+// objectID identifies the object in the bundle to resolve the owner references.
+type objectID struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// Restore the bundle on the management cluster.
+//
+// Existing objects are not modified. Servers are created with the Cluster API paused annotation,
+// so that Sidero doesn't wipe them before the status and bindings are restored, and are unpaused once
+// all the objects are restored (unless KeepPaused is set).
+//
+// Owner references are re-linked to the owners by name, as the UIDs change on the new cluster;
+// references to the owners which are not in the cluster and not in the bundle are dropped.
+func Restore(ctx context.Context, c client.Client, bundle *Bundle, options RestoreOptions) ([]Result, error) {
+	r := restorer{
+		c:          c,
+		options:    options,
+		namespaces: map[string]struct{}{},
+		bundled:    map[objectID]struct{}{},
+		digests:    map[string]string{},
+	}
+
+	for _, digest := range bundle.Metadata.Assets {
+		r.digests[digest.URL] = digest.SHA512
+	}
+
+	for _, obj := range bundle.Objects {
+		id, err := idOf(obj)
+		if err != nil {
+			return nil, err
+		}
+
+		r.bundled[id] = struct{}{}
+	}
+
+	results := make([]Result, 0, len(bundle.Objects))
+
+	for _, obj := range bundle.Objects {
+		result, err := r.restore(ctx, obj.DeepCopyObject())
+		if err != nil {
+			return results, err
+		}
+
+		results = append(results, result)
+	}
+
+	if options.DryRun {
+		return results, nil
+	}
+
+	for _, obj := range r.deferred {
+		if err := r.relinkOwners(ctx, obj); err != nil {
+			return results, err
+		}
+	}
+
+	if options.KeepPaused {
+		return results, nil
+	}
+
+	for _, obj := range r.paused {
+		if err := r.unpause(ctx, obj); err != nil {
+			return results, err
+		}
+	}
+
+	return results, nil
+}
+
+type restorer struct {
+	c       client.Client
+	options RestoreOptions
+
+	namespaces map[string]struct{}
+	bundled    map[objectID]struct{}
+	digests    map[string]string
+
+	// objects with the owners restored after them
+	deferred []runtime.Object
+	// servers created with the paused annotation
+	paused []*metalv1alpha1.Server
+}
+
+func idOf(obj runtime.Object) (objectID, error) {
+	gvk, err := apiutil.GVKForObject(obj, scheme)
+	if err != nil {
+		return objectID{}, err
+	}
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return objectID{}, err
+	}
+
+	return objectID{Kind: gvk.Kind, Namespace: accessor.GetNamespace(), Name: accessor.GetName()}, nil
+}
+
+func (r *restorer) restore(ctx context.Context, obj runtime.Object) (Result, error) {
+	id, err := idOf(obj)
+	if err != nil {
+		return Result{}, err
+	}
+
+	result := Result{
+		Kind:      id.Kind,
+		Namespace: id.Namespace,
+		Name:      id.Name,
+	}
+
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return result, err
+	}
+
+	existing := obj.DeepCopyObject()
+
+	err = r.c.Get(ctx, types.NamespacedName{Namespace: id.Namespace, Name: id.Name}, existing)
+	if err == nil {
+		result.Action = ActionExists
+
+		return result, nil
+	}
+
+	if !apierrors.IsNotFound(err) {
+		return result, err
+	}
+
+	result.Action = ActionCreated
+
+	var messages []string
+
+	owners, deferred, dropped, err := r.resolveOwners(ctx, id.Namespace, accessor.GetOwnerReferences())
+	if err != nil {
+		return result, err
+	}
+
+	accessor.SetOwnerReferences(owners)
+
+	for _, ref := range dropped {
+		messages = append(messages, fmt.Sprintf("owner %s %q is not found, reference dropped", ref.Kind, ref.Name))
+	}
+
+	switch typed := obj.(type) {
+	case *metalv1alpha1.Environment:
+		if r.options.PinAssets {
+			r.pinAssets(typed)
+		}
+	case *infrav1.ServerBinding:
+		var metalMachine infrav1.MetalMachine
+
+		ref := typed.Spec.MetalMachineRef
+
+		if err = r.c.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, &metalMachine); err == nil {
+			typed.Spec.MetalMachineRef.UID = metalMachine.UID
+		} else if !apierrors.IsNotFound(err) {
+			return result, err
+		}
+	case *metalv1alpha1.Server:
+		annotations := typed.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+
+		annotations[clusterv1.PausedAnnotation] = "true"
+		typed.SetAnnotations(annotations)
+	}
+
+	result.Message = strings.Join(messages, ", ")
+
+	if r.options.DryRun {
+		return result, nil
+	}
+
+	if err = r.ensureNamespace(ctx, id.Namespace); err != nil {
+		return result, err
+	}
+
+	status, err := statusOf(obj)
+	if err != nil {
+		return result, err
+	}
+
+	if err = r.c.Create(ctx, obj); err != nil {
+		return result, fmt.Errorf("error creating %s %s: %w", id.Kind, types.NamespacedName{Namespace: id.Namespace, Name: id.Name}, err)
+	}
+
+	if status != nil {
+		if err = setStatus(obj, status); err != nil {
+			return result, err
+		}
+
+		if err = r.c.Status().Update(ctx, obj); err != nil {
+			return result, fmt.Errorf("error restoring %s %s status: %w", id.Kind, types.NamespacedName{Namespace: id.Namespace, Name: id.Name}, err)
+		}
+	}
+
+	if deferred {
+		r.deferred = append(r.deferred, obj)
+	}
+
+	if server, ok := obj.(*metalv1alpha1.Server); ok {
+		r.paused = append(r.paused, server)
+	}
+
+	return result, nil
+}
+
+// resolveOwners updates the owner references UIDs.
+//
+// Owners which are going to be restored later are kept as is, and the object is re-linked once they are restored.
+func (r *restorer) resolveOwners(ctx context.Context, namespace string, refs []metav1.OwnerReference) (owners []metav1.OwnerReference, deferred bool, dropped []metav1.OwnerReference, err error) {
+	for _, ref := range refs {
+		uid, found, err := r.ownerUID(ctx, namespace, ref)
+		if err != nil {
+			return nil, false, nil, err
+		}
+
+		switch {
+		case found:
+			ref.UID = uid
+		case r.isBundled(namespace, ref):
+			deferred = true
+		default:
+			dropped = append(dropped, ref)
+
+			continue
+		}
+
+		owners = append(owners, ref)
+	}
+
+	return owners, deferred, dropped, nil
+}
+
+func (r *restorer) isBundled(namespace string, ref metav1.OwnerReference) bool {
+	for _, ns := range []string{namespace, ""} {
+		if _, ok := r.bundled[objectID{Kind: ref.Kind, Namespace: ns, Name: ref.Name}]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ownerUID looks up the owner by the name.
+//
+// Cluster-scoped owners (e.g. ServerClass of the Server) are looked up without the namespace.
+func (r *restorer) ownerUID(ctx context.Context, namespace string, ref metav1.OwnerReference) (types.UID, bool, error) {
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return "", false, err
+	}
+
+	for _, ns := range []string{namespace, ""} {
+		owner := &unstructured.Unstructured{}
+		owner.SetGroupVersionKind(gv.WithKind(ref.Kind))
+
+		err = r.c.Get(ctx, types.NamespacedName{Namespace: ns, Name: ref.Name}, owner)
+		if err == nil {
+			return owner.GetUID(), true, nil
+		}
+
+		if !apierrors.IsNotFound(err) && !meta.IsNoMatchError(err) {
+			return "", false, err
+		}
+
+		if namespace == "" {
+			break
+		}
+	}
+
+	return "", false, nil
+}
+
+// relinkOwners updates the owner references of the object once the owners are restored.
+func (r *restorer) relinkOwners(ctx context.Context, obj runtime.Object) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+
+	if err = r.c.Get(ctx, types.NamespacedName{Namespace: accessor.GetNamespace(), Name: accessor.GetName()}, obj); err != nil {
+		return err
+	}
+
+	var owners []metav1.OwnerReference
+
+	for _, ref := range accessor.GetOwnerReferences() {
+		uid, found, err := r.ownerUID(ctx, accessor.GetNamespace(), ref)
+		if err != nil {
+			return err
+		}
+
+		if !found {
+			continue
+		}
+
+		ref.UID = uid
+		owners = append(owners, ref)
+	}
+
+	accessor.SetOwnerReferences(owners)
+
+	return r.c.Update(ctx, obj)
+}
+
+func (r *restorer) unpause(ctx context.Context, server *metalv1alpha1.Server) error {
+	if err := r.c.Get(ctx, types.NamespacedName{Name: server.Name}, server); err != nil {
+		return err
+	}
+
+	patch := client.MergeFrom(server.DeepCopy())
+
+	delete(server.Annotations, clusterv1.PausedAnnotation)
+
+	return r.c.Patch(ctx, server, patch)
+}
+
+func (r *restorer) pinAssets(env *metalv1alpha1.Environment) {
+	if env.Spec.Kernel.SHA512 == "" {
+		env.Spec.Kernel.SHA512 = r.digests[env.Spec.KernelAsset().URL]
+	}
+
+	if env.Spec.Initrd.SHA512 == "" {
+		env.Spec.Initrd.SHA512 = r.digests[env.Spec.InitrdAsset().URL]
+	}
+}
+
+func (r *restorer) ensureNamespace(ctx context.Context, namespace string) error {
+	if namespace == "" {
+		return nil
+	}
+
+	if _, ok := r.namespaces[namespace]; ok {
+		return nil
+	}
+
+	var ns corev1.Namespace
+
+	err := r.c.Get(ctx, types.NamespacedName{Name: namespace}, &ns)
+	if apierrors.IsNotFound(err) {
+		ns.Name = namespace
+
+		err = r.c.Create(ctx, &ns)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	r.namespaces[namespace] = struct{}{}
+
+	return nil
+}
+
+// statusOf returns the status of the object, as it's not set on create.
+func statusOf(obj runtime.Object) (map[string]interface{}, error) {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	status, _ := u["status"].(map[string]interface{}) //nolint:errcheck
+
+	if len(status) == 0 {
+		return nil, nil
+	}
+
+	return status, nil
+}
+
+func setStatus(obj runtime.Object, status map[string]interface{}) error {
+	u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return err
+	}
+
+	u["status"] = status
+
+	return runtime.DefaultUnstructuredConverter.FromUnstructured(u, obj)
+}
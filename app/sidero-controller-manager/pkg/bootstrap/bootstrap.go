@@ -0,0 +1,239 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package bootstrap implements the steps to stand up the initial Sidero management plane
+// and to pivot it onto the hardware it provisions.
+//
+// Steps are plain invocations of talosctl, kubectl and clusterctl, so that they can be reviewed (dry run)
+// and repeated manually.
+package bootstrap
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// Mode is the way the bootstrap cluster is created.
+type Mode string
+
+// Bootstrap modes.
+const (
+	// ModeDocker runs single node Talos cluster in Docker on the local machine.
+	ModeDocker Mode = "docker"
+	// ModeTalos installs single node Talos cluster on the machine booted into Talos maintenance mode (from ISO or PXE).
+	ModeTalos Mode = "talos"
+)
+
+// Step is a single command to run.
+type Step struct {
+	// Name of the step for the progress output.
+	Name string
+	// Command and arguments.
+	Command []string
+	// Env is the additional environment.
+	Env []string
+	// Retry the command until it succeeds (e.g. while waiting for the API to come up).
+	Retry bool
+}
+
+// Options configures the bootstrap cluster.
+type Options struct {
+	Mode Mode
+	// Name of the bootstrap cluster.
+	Name string
+	// APIEndpoint is the IP address the servers reach Sidero at.
+	APIEndpoint string
+	// Node is the address of the machine in maintenance mode (ModeTalos).
+	Node string
+	// InstallDisk is the disk to install Talos to (ModeTalos).
+	InstallDisk string
+	// WorkDir keeps the generated configs, talosconfig and kubeconfig.
+	WorkDir string
+}
+
+// Kubeconfig returns the path to the bootstrap cluster kubeconfig.
+func (o *Options) Kubeconfig() string {
+	return filepath.Join(o.WorkDir, "kubeconfig")
+}
+
+// Talosconfig returns the path to the bootstrap cluster talosconfig.
+func (o *Options) Talosconfig() string {
+	return filepath.Join(o.WorkDir, "talosconfig")
+}
+
+func (o *Options) validate() error {
+	if o.Name == "" {
+		return fmt.Errorf("cluster name is required")
+	}
+
+	if o.WorkDir == "" {
+		return fmt.Errorf("work directory is required")
+	}
+
+	switch o.Mode {
+	case ModeDocker:
+	case ModeTalos:
+		if o.Node == "" {
+			return fmt.Errorf("node address is required in %q mode", o.Mode)
+		}
+	default:
+		return fmt.Errorf("unsupported mode %q", o.Mode)
+	}
+
+	return nil
+}
+
+// InstallSideroStep installs Cluster API with Talos and Sidero providers.
+//
+// Sidero uses host network, so that the servers can reach it at the API endpoint.
+func InstallSideroStep(kubeconfig, apiEndpoint string) Step {
+	return Step{
+		Name:    "install Cluster API and Sidero",
+		Command: []string{"clusterctl", "init", "--kubeconfig", kubeconfig, "-b", "talos", "-c", "talos", "-i", "sidero"},
+		Env: []string{
+			"SIDERO_CONTROLLER_MANAGER_HOST_NETWORK=true",
+			"SIDERO_CONTROLLER_MANAGER_API_ENDPOINT=" + apiEndpoint,
+		},
+	}
+}
+
+// CreatePlan returns the steps to create the bootstrap cluster with Sidero installed.
+func CreatePlan(opts Options) ([]Step, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	if opts.APIEndpoint == "" {
+		if opts.Mode != ModeTalos {
+			return nil, fmt.Errorf("API endpoint is required")
+		}
+
+		opts.APIEndpoint = opts.Node
+	}
+
+	var steps []Step
+
+	switch opts.Mode {
+	case ModeDocker:
+		steps = append(steps,
+			Step{
+				Name: "create Talos cluster in Docker",
+				Command: []string{
+					"talosctl", "cluster", "create",
+					"--name", opts.Name,
+					"--talosconfig", opts.Talosconfig(),
+					"--workers", "0",
+					"--endpoint", opts.APIEndpoint,
+					// TFTP and Sidero HTTP (iPXE, metadata, agent API)
+					"-p", "69:69/udp,8081:8081/tcp",
+				},
+			},
+			Step{
+				Name:    "fetch kubeconfig",
+				Command: []string{"talosctl", "--talosconfig", opts.Talosconfig(), "kubeconfig", opts.Kubeconfig(), "--nodes", "10.5.0.2", "--force"},
+			},
+		)
+	case ModeTalos:
+		genArgs := []string{
+			"talosctl", "gen", "config", opts.Name, fmt.Sprintf("https://%s:6443", opts.Node),
+			"--output-dir", opts.WorkDir,
+		}
+
+		if opts.InstallDisk != "" {
+			genArgs = append(genArgs, "--install-disk", opts.InstallDisk)
+		}
+
+		steps = append(steps,
+			Step{
+				Name:    "generate Talos config",
+				Command: genArgs,
+			},
+			Step{
+				Name:    "apply Talos config to the machine in maintenance mode",
+				Command: []string{"talosctl", "apply-config", "--insecure", "--nodes", opts.Node, "--file", filepath.Join(opts.WorkDir, "controlplane.yaml")},
+			},
+			Step{
+				Name:    "bootstrap etcd",
+				Command: []string{"talosctl", "--talosconfig", opts.Talosconfig(), "bootstrap", "--nodes", opts.Node, "--endpoints", opts.Node},
+				Retry:   true,
+			},
+			Step{
+				Name:    "fetch kubeconfig",
+				Command: []string{"talosctl", "--talosconfig", opts.Talosconfig(), "kubeconfig", opts.Kubeconfig(), "--nodes", opts.Node, "--endpoints", opts.Node, "--force"},
+				Retry:   true,
+			},
+		)
+	}
+
+	steps = append(steps,
+		Step{
+			Name: "allow workloads on the control plane node",
+			// there's a single node, so Sidero has to run on the control plane
+			Command: []string{"kubectl", "--kubeconfig", opts.Kubeconfig(), "taint", "nodes", "--all", "node-role.kubernetes.io/master:NoSchedule-"},
+			Retry:   true,
+		},
+		InstallSideroStep(opts.Kubeconfig(), opts.APIEndpoint),
+	)
+
+	return steps, nil
+}
+
+// PivotOptions configures the pivot from the bootstrap cluster to the management cluster.
+type PivotOptions struct {
+	// Kubeconfig of the bootstrap cluster.
+	Kubeconfig string
+	// ToKubeconfig is the kubeconfig of the management cluster provisioned by the bootstrap cluster.
+	ToKubeconfig string
+	// APIEndpoint is the IP address the servers reach Sidero at in the management cluster.
+	APIEndpoint string
+}
+
+// PivotPlan returns the steps to move Cluster API and Sidero resources to the management cluster.
+//
+// Sidero in the management cluster updates the default Environment to the new API endpoint on startup.
+func PivotPlan(opts PivotOptions) ([]Step, error) {
+	if opts.Kubeconfig == "" || opts.ToKubeconfig == "" {
+		return nil, fmt.Errorf("both bootstrap and management cluster kubeconfigs are required")
+	}
+
+	if opts.APIEndpoint == "" {
+		return nil, fmt.Errorf("API endpoint is required")
+	}
+
+	return []Step{
+		InstallSideroStep(opts.ToKubeconfig, opts.APIEndpoint),
+		{
+			Name:    "move Cluster API and Sidero resources",
+			Command: []string{"clusterctl", "move", "--kubeconfig", opts.Kubeconfig, "--to-kubeconfig", opts.ToKubeconfig},
+			// providers in the management cluster might still be starting up
+			Retry: true,
+		},
+	}, nil
+}
+
+// DestroyPlan returns the steps to tear down the bootstrap cluster.
+func DestroyPlan(opts Options) ([]Step, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	switch opts.Mode {
+	case ModeDocker:
+		return []Step{
+			{
+				Name:    "destroy Talos cluster in Docker",
+				Command: []string{"talosctl", "cluster", "destroy", "--name", opts.Name, "--talosconfig", opts.Talosconfig()},
+			},
+		}, nil
+	case ModeTalos:
+		return []Step{
+			{
+				Name:    "reset the machine",
+				Command: []string{"talosctl", "--talosconfig", opts.Talosconfig(), "reset", "--graceful=false", "--reboot", "--nodes", opts.Node, "--endpoints", opts.Node},
+			},
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported mode %q", opts.Mode)
+}
@@ -0,0 +1,158 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package bootstrap_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/bootstrap"
+)
+
+func commands(steps []bootstrap.Step) []string {
+	result := make([]string, 0, len(steps))
+
+	for _, step := range steps {
+		result = append(result, step.String())
+	}
+
+	return result
+}
+
+func TestCreatePlan(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		opts     bootstrap.Options
+		expected []string
+	}{
+		"docker": {
+			opts: bootstrap.Options{
+				Mode:        bootstrap.ModeDocker,
+				Name:        "sidero-demo",
+				APIEndpoint: "192.168.1.150",
+				WorkDir:     "/tmp/sidero",
+			},
+			expected: []string{
+				"talosctl cluster create --name sidero-demo --talosconfig /tmp/sidero/talosconfig --workers 0 --endpoint 192.168.1.150 -p 69:69/udp,8081:8081/tcp",
+				"talosctl --talosconfig /tmp/sidero/talosconfig kubeconfig /tmp/sidero/kubeconfig --nodes 10.5.0.2 --force",
+				"kubectl --kubeconfig /tmp/sidero/kubeconfig taint nodes --all node-role.kubernetes.io/master:NoSchedule-",
+				"SIDERO_CONTROLLER_MANAGER_HOST_NETWORK=true SIDERO_CONTROLLER_MANAGER_API_ENDPOINT=192.168.1.150 clusterctl init --kubeconfig /tmp/sidero/kubeconfig -b talos -c talos -i sidero",
+			},
+		},
+		"talos": {
+			opts: bootstrap.Options{
+				Mode:        bootstrap.ModeTalos,
+				Name:        "sidero-demo",
+				Node:        "192.168.254.2",
+				InstallDisk: "/dev/nvme0n1",
+				WorkDir:     "/tmp/sidero",
+			},
+			expected: []string{
+				"talosctl gen config sidero-demo https://192.168.254.2:6443 --output-dir /tmp/sidero --install-disk /dev/nvme0n1",
+				"talosctl apply-config --insecure --nodes 192.168.254.2 --file /tmp/sidero/controlplane.yaml",
+				"talosctl --talosconfig /tmp/sidero/talosconfig bootstrap --nodes 192.168.254.2 --endpoints 192.168.254.2",
+				"talosctl --talosconfig /tmp/sidero/talosconfig kubeconfig /tmp/sidero/kubeconfig --nodes 192.168.254.2 --endpoints 192.168.254.2 --force",
+				"kubectl --kubeconfig /tmp/sidero/kubeconfig taint nodes --all node-role.kubernetes.io/master:NoSchedule-",
+				"SIDERO_CONTROLLER_MANAGER_HOST_NETWORK=true SIDERO_CONTROLLER_MANAGER_API_ENDPOINT=192.168.254.2 clusterctl init --kubeconfig /tmp/sidero/kubeconfig -b talos -c talos -i sidero",
+			},
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			steps, err := bootstrap.CreatePlan(tc.opts)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, commands(steps))
+		})
+	}
+}
+
+func TestPlanErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := bootstrap.CreatePlan(bootstrap.Options{Mode: bootstrap.ModeDocker, Name: "sidero-demo", WorkDir: "/tmp"})
+	assert.EqualError(t, err, "API endpoint is required")
+
+	_, err = bootstrap.CreatePlan(bootstrap.Options{Mode: bootstrap.ModeTalos, Name: "sidero-demo", WorkDir: "/tmp"})
+	assert.EqualError(t, err, `node address is required in "talos" mode`)
+
+	_, err = bootstrap.DestroyPlan(bootstrap.Options{Mode: "kind", Name: "sidero-demo", WorkDir: "/tmp"})
+	assert.EqualError(t, err, `unsupported mode "kind"`)
+
+	_, err = bootstrap.PivotPlan(bootstrap.PivotOptions{Kubeconfig: "bootstrap"})
+	assert.EqualError(t, err, "both bootstrap and management cluster kubeconfigs are required")
+}
+
+func TestPivotPlan(t *testing.T) {
+	t.Parallel()
+
+	steps, err := bootstrap.PivotPlan(bootstrap.PivotOptions{
+		Kubeconfig:   "/tmp/sidero/kubeconfig",
+		ToKubeconfig: "/tmp/management/kubeconfig",
+		APIEndpoint:  "sidero.example.com",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"SIDERO_CONTROLLER_MANAGER_HOST_NETWORK=true SIDERO_CONTROLLER_MANAGER_API_ENDPOINT=sidero.example.com clusterctl init --kubeconfig /tmp/management/kubeconfig -b talos -c talos -i sidero",
+		"clusterctl move --kubeconfig /tmp/sidero/kubeconfig --to-kubeconfig /tmp/management/kubeconfig",
+	}, commands(steps))
+}
+
+func TestRunner(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+
+	r := bootstrap.Runner{
+		Out:           &out,
+		RetryTimeout:  100 * time.Millisecond,
+		RetryInterval: 10 * time.Millisecond,
+	}
+
+	require.NoError(t, r.Run(context.Background(), []bootstrap.Step{
+		{
+			Name:    "print",
+			Command: []string{"sh", "-c", "echo $GREETING"},
+			Env:     []string{"GREETING=hello"},
+		},
+	}))
+
+	assert.Equal(t, "[1/1] print\n  $ GREETING=hello sh -c \"echo $GREETING\"\nhello\n", out.String())
+
+	out.Reset()
+
+	err := r.Run(context.Background(), []bootstrap.Step{
+		{
+			Name:    "fail",
+			Command: []string{"false"},
+			Retry:   true,
+		},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `step "fail" failed`)
+	assert.Contains(t, out.String(), "retrying in 10ms")
+
+	out.Reset()
+
+	r.DryRun = true
+
+	require.NoError(t, r.Run(context.Background(), []bootstrap.Step{
+		{
+			Name:    "fail",
+			Command: []string{"false"},
+		},
+	}))
+	assert.Equal(t, "[1/1] fail\n  $ false\n", out.String())
+}
@@ -0,0 +1,84 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package bootstrap
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Runner executes the steps.
+type Runner struct {
+	// Out receives the progress and the command output.
+	Out io.Writer
+	// DryRun prints the commands without running them.
+	DryRun bool
+	// RetryTimeout limits retries of the steps with Retry set.
+	RetryTimeout time.Duration
+	// RetryInterval is the delay between the retries.
+	RetryInterval time.Duration
+}
+
+// Run the steps in order, stopping on the first failure.
+func (r *Runner) Run(ctx context.Context, steps []Step) error {
+	for i, step := range steps {
+		fmt.Fprintf(r.Out, "[%d/%d] %s\n", i+1, len(steps), step.Name)
+		fmt.Fprintf(r.Out, "  $ %s\n", step.String())
+
+		if r.DryRun {
+			continue
+		}
+
+		if err := r.run(ctx, step); err != nil {
+			return fmt.Errorf("step %q failed: %w", step.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Runner) run(ctx context.Context, step Step) error {
+	deadline := time.Now().Add(r.RetryTimeout)
+
+	for {
+		cmd := exec.CommandContext(ctx, step.Command[0], step.Command[1:]...)
+		cmd.Env = append(os.Environ(), step.Env...)
+		cmd.Stdout = r.Out
+		cmd.Stderr = r.Out
+
+		err := cmd.Run()
+		if err == nil || !step.Retry || time.Now().After(deadline) {
+			return err
+		}
+
+		fmt.Fprintf(r.Out, "  retrying in %s: %s\n", r.RetryInterval, err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(r.RetryInterval):
+		}
+	}
+}
+
+// String returns the step as a shell command.
+func (s Step) String() string {
+	parts := append([]string{}, s.Env...)
+
+	for _, arg := range s.Command {
+		if strings.ContainsAny(arg, " \t\"'$") {
+			arg = fmt.Sprintf("%q", arg)
+		}
+
+		parts = append(parts, arg)
+	}
+
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,256 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package components generates the Sidero provider components for the documented deployment topologies.
+//
+// Each topology is a kustomize overlay (config/topologies) released as infrastructure-components-<topology>.yaml,
+// the generator substitutes the variables of the released components from a small config file,
+// so that the components are not edited by hand.
+package components
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+// TopologyAnnotation is set by the topology overlays on all the components.
+const TopologyAnnotation = "sidero.dev/topology"
+
+// Topology is the documented way to deploy Sidero.
+type Topology string
+
+// Topologies.
+const (
+	// TopologySingleNode runs Sidero on the host network of a single-node management cluster.
+	TopologySingleNode Topology = "single-node"
+	// TopologyHA exposes Sidero via load balancer Services of a highly available management cluster.
+	TopologyHA Topology = "ha"
+	// TopologyAirGapped runs Sidero on the host network with the images pulled from the local registry.
+	TopologyAirGapped Topology = "air-gapped"
+	// TopologyMultiSite runs Sidero on the host network, the remote sites reach it via DHCP relays.
+	TopologyMultiSite Topology = "multi-site"
+)
+
+// Topologies lists all the supported topologies.
+var Topologies = []Topology{TopologySingleNode, TopologyHA, TopologyAirGapped, TopologyMultiSite}
+
+// Variables set from the Config fields.
+const (
+	VariableAPIEndpoint       = "SIDERO_CONTROLLER_MANAGER_API_ENDPOINT"
+	VariableAPIPort           = "SIDERO_CONTROLLER_MANAGER_API_PORT"
+	VariableExtraAPIEndpoints = "SIDERO_CONTROLLER_MANAGER_EXTRA_API_ENDPOINTS"
+)
+
+// Config is the deployment config the components are generated from.
+type Config struct {
+	Topology Topology `json:"topology"`
+	// APIEndpoint is the address the servers reach Sidero at (defaults to the node IP with the host network).
+	APIEndpoint string `json:"apiEndpoint,omitempty"`
+	// APIPort is the port of the Sidero API, iPXE and metadata server.
+	APIPort int `json:"apiPort,omitempty"`
+	// ExtraAPIEndpoints are the fallback endpoints (e.g. routable addresses of Sidero for the remote sites).
+	ExtraAPIEndpoints []string `json:"extraAPIEndpoints,omitempty"`
+	// Registry replaces the registry (and the repository path) of the controller images, e.g. registry.local:5000/sidero.
+	Registry string `json:"registry,omitempty"`
+	// Variables are the other variables of the components (e.g. SIDERO_CONTROLLER_MANAGER_AUTO_ACCEPT_SERVERS).
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// LoadConfig reads the config file.
+func LoadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = yaml.YAMLToJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding %q: %w", path, err)
+	}
+
+	var cfg Config
+
+	// reject the unknown (e.g. misspelled) fields, otherwise they are silently ignored
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err = decoder.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("error decoding %q: %w", path, err)
+	}
+
+	return &cfg, nil
+}
+
+func (cfg *Config) validate() error {
+	switch cfg.Topology {
+	case TopologySingleNode:
+	case TopologyHA:
+		if cfg.APIEndpoint == "" {
+			return fmt.Errorf("apiEndpoint (load balancer address) is required in %q topology", cfg.Topology)
+		}
+	case TopologyAirGapped:
+		if cfg.Registry == "" {
+			return fmt.Errorf("registry is required in %q topology", cfg.Topology)
+		}
+	case TopologyMultiSite:
+		if cfg.APIEndpoint == "" {
+			return fmt.Errorf("apiEndpoint is required in %q topology", cfg.Topology)
+		}
+
+		if len(cfg.ExtraAPIEndpoints) == 0 {
+			return fmt.Errorf("extraAPIEndpoints (endpoints of the remote sites) are required in %q topology", cfg.Topology)
+		}
+	case "":
+		return fmt.Errorf("topology is required")
+	default:
+		return fmt.Errorf("unknown topology %q", cfg.Topology)
+	}
+
+	if cfg.APIPort < 0 || cfg.APIPort > 65535 {
+		return fmt.Errorf("invalid apiPort %d", cfg.APIPort)
+	}
+
+	return nil
+}
+
+// variables merges the config fields into the variables.
+func (cfg *Config) variables() (map[string]string, error) {
+	vars := make(map[string]string, len(cfg.Variables)+3)
+
+	for name, value := range cfg.Variables {
+		vars[name] = value
+	}
+
+	set := func(name, field, value string) error {
+		if _, ok := vars[name]; ok {
+			return fmt.Errorf("variable %s conflicts with %s, set %s only", name, field, field)
+		}
+
+		vars[name] = value
+
+		return nil
+	}
+
+	if cfg.APIEndpoint != "" {
+		if err := set(VariableAPIEndpoint, "apiEndpoint", cfg.APIEndpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.APIPort != 0 {
+		if err := set(VariableAPIPort, "apiPort", strconv.Itoa(cfg.APIPort)); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(cfg.ExtraAPIEndpoints) > 0 {
+		if err := set(VariableExtraAPIEndpoints, "extraAPIEndpoints", strings.Join(cfg.ExtraAPIEndpoints, ",")); err != nil {
+			return nil, err
+		}
+	}
+
+	return vars, nil
+}
+
+var (
+	// variableRe matches ${NAME}, ${NAME:=default} and ${NAME:-default} the same way clusterctl does.
+	variableRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::[=-]([^}]*))?\}`)
+	imageRe    = regexp.MustCompile(`(?m)^([ \t]*(?:- )?image:[ \t]*)(\S+)[ \t]*$`)
+	topologyRe = regexp.MustCompile(`(?m)^[ \t]*` + regexp.QuoteMeta(TopologyAnnotation) + `:[ \t]*(\S+)[ \t]*$`)
+)
+
+// Generate substitutes the variables of the released topology components from the config.
+//
+// Unknown variables (e.g. typos) and variables without the defaults which are not set are reported as errors.
+func Generate(components []byte, cfg *Config) ([]byte, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	match := topologyRe.FindSubmatch(components)
+	if match == nil {
+		return nil, fmt.Errorf("components are not built for a topology, use infrastructure-components-%s.yaml", cfg.Topology)
+	}
+
+	if topology := Topology(strings.Trim(string(match[1]), `"'`)); topology != cfg.Topology {
+		return nil, fmt.Errorf("components are built for %q topology, use infrastructure-components-%s.yaml", topology, cfg.Topology)
+	}
+
+	vars, err := cfg.variables()
+	if err != nil {
+		return nil, err
+	}
+
+	// known variables, true if the variable has no default
+	known := map[string]bool{}
+
+	for _, m := range variableRe.FindAllSubmatch(components, -1) {
+		known[string(m[1])] = known[string(m[1])] || m[2] == nil
+	}
+
+	var unknown, missing []string
+
+	for name := range vars {
+		if _, ok := known[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+
+	for name, required := range known {
+		if _, ok := vars[name]; required && !ok {
+			missing = append(missing, name)
+		}
+	}
+
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+
+		return nil, fmt.Errorf("unknown variables: %s", strings.Join(unknown, ", "))
+	}
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+
+		return nil, fmt.Errorf("variables without defaults are not set: %s", strings.Join(missing, ", "))
+	}
+
+	result := variableRe.ReplaceAllFunc(components, func(s []byte) []byte {
+		m := variableRe.FindSubmatch(s)
+
+		if value, ok := vars[string(m[1])]; ok {
+			return []byte(value)
+		}
+
+		return m[2]
+	})
+
+	if cfg.Registry != "" {
+		result = imageRe.ReplaceAllFunc(result, func(s []byte) []byte {
+			m := imageRe.FindSubmatch(s)
+
+			return append(append([]byte(nil), m[1]...), rewriteRegistry(string(m[2]), cfg.Registry)...)
+		})
+	}
+
+	return result, nil
+}
+
+// rewriteRegistry replaces everything but the image name, tag and digest.
+func rewriteRegistry(image, registry string) string {
+	name := image
+
+	if idx := strings.LastIndex(image, "/"); idx >= 0 {
+		name = image[idx+1:]
+	}
+
+	return strings.TrimSuffix(registry, "/") + "/" + name
+}
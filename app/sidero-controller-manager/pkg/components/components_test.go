@@ -0,0 +1,202 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package components_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/components"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	ha, err := os.ReadFile("testdata/infrastructure-components-ha.yaml")
+	require.NoError(t, err)
+
+	airGapped := []byte(strings.ReplaceAll(string(ha), "sidero.dev/topology: ha", "sidero.dev/topology: air-gapped"))
+
+	for name, tc := range map[string]struct {
+		components []byte
+		cfg        components.Config
+
+		expected    []string
+		notExpected []string
+		err         string
+	}{
+		"ha": {
+			components: ha,
+			cfg: components.Config{
+				Topology:    components.TopologyHA,
+				APIEndpoint: "sidero.example.com",
+				APIPort:     8082,
+				Variables: map[string]string{
+					"CLUSTER_NAME": "management",
+				},
+			},
+			expected: []string{
+				"- port: 8082\n",
+				"--api-endpoint=sidero.example.com\n",
+				"--extra-api-endpoints=-\n",
+				"--api-port=8082\n",
+				"--auto-accept-servers=false\n",
+				"--cluster-name=management\n",
+				"image: ghcr.io/talos-systems/sidero-controller-manager:v0.4.0\n",
+			},
+			notExpected: []string{"${"},
+		},
+		"air-gapped": {
+			components: airGapped,
+			cfg: components.Config{
+				Topology:          components.TopologyAirGapped,
+				ExtraAPIEndpoints: []string{"192.168.1.10", "192.168.1.11"},
+				Registry:          "registry.local:5000/mirror/",
+				Variables: map[string]string{
+					"CLUSTER_NAME": "management",
+					"SIDERO_CONTROLLER_MANAGER_AUTO_ACCEPT_SERVERS": "true",
+				},
+			},
+			expected: []string{
+				"--api-endpoint=-\n",
+				"--extra-api-endpoints=192.168.1.10,192.168.1.11\n",
+				"--api-port=8081\n",
+				"--auto-accept-servers=true\n",
+				"        image: registry.local:5000/mirror/sidero-controller-manager:v0.4.0\n        imagePullPolicy: Always\n",
+			},
+		},
+		"wrong topology": {
+			components: ha,
+			cfg: components.Config{
+				Topology:    components.TopologyMultiSite,
+				APIEndpoint: "192.168.1.10",
+				ExtraAPIEndpoints: []string{
+					"10.5.0.10",
+				},
+			},
+			err: `components are built for "ha" topology, use infrastructure-components-multi-site.yaml`,
+		},
+		"no topology": {
+			components: []byte("image: controller:latest\n"),
+			cfg: components.Config{
+				Topology: components.TopologySingleNode,
+			},
+			err: "components are not built for a topology, use infrastructure-components-single-node.yaml",
+		},
+		"missing endpoint": {
+			components: ha,
+			cfg: components.Config{
+				Topology: components.TopologyHA,
+			},
+			err: `apiEndpoint (load balancer address) is required in "ha" topology`,
+		},
+		"missing registry": {
+			components: airGapped,
+			cfg: components.Config{
+				Topology: components.TopologyAirGapped,
+			},
+			err: `registry is required in "air-gapped" topology`,
+		},
+		"unknown topology": {
+			components: ha,
+			cfg: components.Config{
+				Topology: "cloud",
+			},
+			err: `unknown topology "cloud"`,
+		},
+		"unknown variable": {
+			components: ha,
+			cfg: components.Config{
+				Topology:    components.TopologyHA,
+				APIEndpoint: "sidero.example.com",
+				Variables: map[string]string{
+					"CLUSTER_NAME": "management",
+					"SIDERO_CONTROLLER_MANAGER_AUTO_ACCEPT_SERVER": "true",
+				},
+			},
+			err: "unknown variables: SIDERO_CONTROLLER_MANAGER_AUTO_ACCEPT_SERVER",
+		},
+		"conflicting variable": {
+			components: ha,
+			cfg: components.Config{
+				Topology:    components.TopologyHA,
+				APIEndpoint: "sidero.example.com",
+				Variables: map[string]string{
+					"SIDERO_CONTROLLER_MANAGER_API_ENDPOINT": "192.168.1.10",
+				},
+			},
+			err: "variable SIDERO_CONTROLLER_MANAGER_API_ENDPOINT conflicts with apiEndpoint, set apiEndpoint only",
+		},
+		"missing variable": {
+			components: ha,
+			cfg: components.Config{
+				Topology:    components.TopologyHA,
+				APIEndpoint: "sidero.example.com",
+			},
+			err: "variables without defaults are not set: CLUSTER_NAME",
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			out, err := components.Generate(tc.components, &tc.cfg)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			for _, s := range tc.expected {
+				assert.Contains(t, string(out), s)
+			}
+
+			for _, s := range tc.notExpected {
+				assert.NotContains(t, string(out), s)
+			}
+		})
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "sidero.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`topology: multi-site
+apiEndpoint: 192.168.1.10
+extraAPIEndpoints:
+  - 10.5.0.10
+  - 10.6.0.10
+variables:
+  SIDERO_CONTROLLER_MANAGER_AUTO_ACCEPT_SERVERS: "true"
+`), 0o644))
+
+	cfg, err := components.LoadConfig(path)
+	require.NoError(t, err)
+
+	assert.Equal(t, &components.Config{
+		Topology:          components.TopologyMultiSite,
+		APIEndpoint:       "192.168.1.10",
+		ExtraAPIEndpoints: []string{"10.5.0.10", "10.6.0.10"},
+		Variables: map[string]string{
+			"SIDERO_CONTROLLER_MANAGER_AUTO_ACCEPT_SERVERS": "true",
+		},
+	}, cfg)
+
+	require.NoError(t, os.WriteFile(path, []byte("topology: ha\napiEndpont: 192.168.1.10\n"), 0o644))
+
+	_, err = components.LoadConfig(path)
+	assert.Error(t, err)
+}
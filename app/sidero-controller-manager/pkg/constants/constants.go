@@ -10,12 +10,19 @@ const (
 	DataDirectory    = "/var/lib/sidero"
 	AgentEndpointArg = "sidero.endpoint"
 
+	AgentRootfsArg       = "sidero.rootfs"
+	AgentRootfsSHA512Arg = "sidero.rootfs.sha512"
+
 	KernelAsset = "vmlinuz"
 	InitrdAsset = "initramfs.xz"
+	RootfsAsset = "rootfs.sqsh"
 
 	DefaultRequeueAfter = time.Second * 20
 
 	DefaultServerRebootTimeout = time.Minute * 20
 
 	DefaultBMCPort = uint32(623)
+
+	// SideroNamespace is the namespace Sidero is installed to.
+	SideroNamespace = "sidero-system"
 )
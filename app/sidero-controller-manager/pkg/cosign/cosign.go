@@ -0,0 +1,490 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package cosign verifies the cosign signatures of the container images.
+//
+// Only the signatures made with a key pair (`cosign sign --key`) are supported: the signature image
+// `<repository>:sha256-<hex>.sig` is fetched from the registry of the image, and the simple signing payload
+// of every signature layer is verified with the public key.
+// Registries are accessed anonymously (with the anonymous bearer token if the registry requires it).
+package cosign
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// SignatureAnnotation is the annotation of the signature layer holding the base64 encoded signature.
+const SignatureAnnotation = "dev.cosignproject.cosign/signature"
+
+// SignatureType is the type of the simple signing payload.
+const SignatureType = "cosign container image signature"
+
+const (
+	maxManifestSize = 4 * 1024 * 1024
+	maxPayloadSize  = 1024 * 1024
+)
+
+var digestRe = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+
+// ErrNotSigned is returned if the registry has no signatures for the image.
+var ErrNotSigned = errors.New("image is not signed")
+
+// Reference is the image reference pinned to the digest.
+type Reference struct {
+	// Registry host, e.g. `ghcr.io`.
+	Registry string
+	// Repository path, e.g. `talos-systems/installer`.
+	Repository string
+	// Digest, e.g. `sha256:...`.
+	Digest string
+}
+
+// ParseReference parses `[<registry>/]<repository>[:<tag>]@<digest>`.
+//
+// Images without the registry are pulled from Docker Hub, like with Docker.
+func ParseReference(image string) (Reference, error) {
+	idx := strings.LastIndex(image, "@")
+	if idx == -1 {
+		return Reference{}, fmt.Errorf("image %q is not pinned to the digest", image)
+	}
+
+	name, digest := image[:idx], image[idx+1:]
+
+	if !digestRe.MatchString(digest) {
+		return Reference{}, fmt.Errorf("invalid digest %q of image %q", digest, image)
+	}
+
+	// the tag is ignored, the digest identifies the image
+	if slash, colon := strings.LastIndex(name, "/"), strings.LastIndex(name, ":"); colon > slash {
+		name = name[:colon]
+	}
+
+	ref := Reference{
+		Registry:   "docker.io",
+		Repository: name,
+		Digest:     digest,
+	}
+
+	if parts := strings.SplitN(name, "/", 2); len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		ref.Registry, ref.Repository = parts[0], parts[1]
+	} else if !strings.Contains(name, "/") {
+		ref.Repository = "library/" + name
+	}
+
+	if ref.Repository == "" {
+		return Reference{}, fmt.Errorf("invalid image %q", image)
+	}
+
+	return ref, nil
+}
+
+// String returns the reference without the tag.
+func (ref Reference) String() string {
+	return ref.Registry + "/" + ref.Repository + "@" + ref.Digest
+}
+
+// SignatureTag returns the tag of the signature image.
+func (ref Reference) SignatureTag() string {
+	return strings.Replace(ref.Digest, ":", "-", 1) + ".sig"
+}
+
+func (ref Reference) host() string {
+	if ref.Registry == "docker.io" {
+		return "registry-1.docker.io"
+	}
+
+	return ref.Registry
+}
+
+// Payload is the simple signing payload.
+type Payload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+}
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type manifest struct {
+	Layers []descriptor `json:"layers"`
+}
+
+// Verifier verifies the image signatures.
+//
+// Successful verifications are cached, as the signatures of the digest don't change.
+type Verifier struct {
+	// Client is used to access the registries, http.DefaultClient if nil.
+	Client *http.Client
+
+	mu       sync.Mutex
+	verified map[string]struct{}
+}
+
+// Verify checks that the image pinned to the digest is signed with the public key (PEM).
+func (v *Verifier) Verify(ctx context.Context, image string, publicKey []byte) error {
+	ref, err := ParseReference(image)
+	if err != nil {
+		return err
+	}
+
+	key, err := ParsePublicKey(publicKey)
+	if err != nil {
+		return err
+	}
+
+	keyHash := sha256.Sum256(publicKey)
+	cacheKey := ref.String() + "/" + hex.EncodeToString(keyHash[:])
+
+	v.mu.Lock()
+	_, ok := v.verified[cacheKey]
+	v.mu.Unlock()
+
+	if ok {
+		return nil
+	}
+
+	s := &session{
+		client: v.Client,
+		ref:    ref,
+	}
+
+	if s.client == nil {
+		s.client = http.DefaultClient
+	}
+
+	if err = s.verify(ctx, key); err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.verified == nil {
+		v.verified = map[string]struct{}{}
+	}
+
+	v.verified[cacheKey] = struct{}{}
+
+	return nil
+}
+
+// ParsePublicKey parses the PEM encoded ECDSA or RSA public key.
+func ParsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("public key is not PEM encoded")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing public key: %w", err)
+	}
+
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+// VerifySignature verifies the signature of the payload.
+func VerifySignature(key crypto.PublicKey, payload, signature []byte) error {
+	digest := sha256.Sum256(payload)
+
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, digest[:], signature) {
+			return errors.New("invalid signature")
+		}
+
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature)
+	default:
+		return fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+// session is a series of requests to the registry sharing the token.
+type session struct {
+	client *http.Client
+	ref    Reference
+	token  string
+}
+
+func (s *session) verify(ctx context.Context, key crypto.PublicKey) error {
+	data, err := s.get(ctx, "/manifests/"+s.ref.SignatureTag(), maxManifestSize,
+		"application/vnd.oci.image.manifest.v1+json", "application/vnd.docker.distribution.manifest.v2+json")
+	if err != nil {
+		return err
+	}
+
+	var m manifest
+
+	if err = json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("error decoding signature manifest of %s: %w", s.ref, err)
+	}
+
+	var reasons []string
+
+	for _, layer := range m.Layers {
+		signature, ok := layer.Annotations[SignatureAnnotation]
+		if !ok {
+			continue
+		}
+
+		if err = s.verifyLayer(ctx, key, layer, signature); err != nil {
+			reasons = append(reasons, fmt.Sprintf("%s: %s", layer.Digest, err))
+
+			continue
+		}
+
+		return nil
+	}
+
+	if len(reasons) == 0 {
+		return fmt.Errorf("%s: %w", s.ref, ErrNotSigned)
+	}
+
+	return fmt.Errorf("no valid signatures of %s: %s", s.ref, strings.Join(reasons, "; "))
+}
+
+func (s *session) verifyLayer(ctx context.Context, key crypto.PublicKey, layer descriptor, signature string) error {
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("error decoding signature: %w", err)
+	}
+
+	if !digestRe.MatchString(layer.Digest) {
+		return fmt.Errorf("unsupported digest")
+	}
+
+	payload, err := s.get(ctx, "/blobs/"+layer.Digest, maxPayloadSize)
+	if err != nil {
+		return err
+	}
+
+	if digest := sha256.Sum256(payload); "sha256:"+hex.EncodeToString(digest[:]) != layer.Digest {
+		return fmt.Errorf("payload digest mismatch")
+	}
+
+	if err = VerifySignature(key, payload, sig); err != nil {
+		return err
+	}
+
+	var p Payload
+
+	if err = json.Unmarshal(payload, &p); err != nil {
+		return fmt.Errorf("error decoding payload: %w", err)
+	}
+
+	if p.Critical.Type != SignatureType {
+		return fmt.Errorf("unexpected payload type %q", p.Critical.Type)
+	}
+
+	if p.Critical.Image.DockerManifestDigest != s.ref.Digest {
+		return fmt.Errorf("signature is for digest %q", p.Critical.Image.DockerManifestDigest)
+	}
+
+	return nil
+}
+
+func (s *session) get(ctx context.Context, path string, limit int64, accept ...string) ([]byte, error) {
+	u := "https://" + s.ref.host() + "/v2/" + s.ref.Repository + path
+
+	resp, err := s.do(ctx, u, accept)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusUnauthorized && s.token == "" {
+		challenge := resp.Header.Get("WWW-Authenticate")
+
+		resp.Body.Close() //nolint:errcheck
+
+		if s.token, err = s.fetchToken(ctx, challenge); err != nil {
+			return nil, err
+		}
+
+		if resp, err = s.do(ctx, u, accept); err != nil {
+			return nil, err
+		}
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		if strings.HasPrefix(path, "/manifests/") {
+			return nil, fmt.Errorf("%s: %w", s.ref, ErrNotSigned)
+		}
+
+		fallthrough
+	default:
+		return nil, fmt.Errorf("error fetching %s: unexpected status %s", u, resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching %s: %w", u, err)
+	}
+
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("error fetching %s: response is too large", u)
+	}
+
+	return data, nil
+}
+
+func (s *session) do(ctx context.Context, u string, accept []string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, mediaType := range accept {
+		req.Header.Add("Accept", mediaType)
+	}
+
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	return s.client.Do(req)
+}
+
+// fetchToken fetches the anonymous pull token following the bearer challenge.
+func (s *session) fetchToken(ctx context.Context, challenge string) (string, error) {
+	params, ok := parseBearerChallenge(challenge)
+	if !ok || params["realm"] == "" {
+		return "", fmt.Errorf("registry %s requires unsupported authentication %q", s.ref.Registry, challenge)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil {
+		return "", fmt.Errorf("invalid token realm %q: %w", params["realm"], err)
+	}
+
+	query := realm.Query()
+
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + s.ref.Repository + ":pull"
+	}
+
+	query.Set("scope", scope)
+
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error fetching token of registry %s: unexpected status %s", s.ref.Registry, resp.Status)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+
+	if err = json.NewDecoder(io.LimitReader(resp.Body, maxPayloadSize)).Decode(&token); err != nil {
+		return "", fmt.Errorf("error decoding token of registry %s: %w", s.ref.Registry, err)
+	}
+
+	if token.Token != "" {
+		return token.Token, nil
+	}
+
+	if token.AccessToken != "" {
+		return token.AccessToken, nil
+	}
+
+	return "", fmt.Errorf("registry %s returned empty token", s.ref.Registry)
+}
+
+// parseBearerChallenge parses `Bearer realm="...",service="...",scope="..."`.
+func parseBearerChallenge(challenge string) (map[string]string, bool) {
+	const scheme = "bearer "
+
+	if len(challenge) < len(scheme) || !strings.EqualFold(challenge[:len(scheme)], scheme) {
+		return nil, false
+	}
+
+	params := map[string]string{}
+	rest := strings.TrimSpace(challenge[len(scheme):])
+
+	for rest != "" {
+		eq := strings.IndexByte(rest, '=')
+		if eq == -1 {
+			return nil, false
+		}
+
+		name := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+
+		var value string
+
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end == -1 {
+				return nil, false
+			}
+
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else if comma := strings.IndexByte(rest, ','); comma != -1 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+
+		params[name] = strings.TrimSpace(value)
+		rest = strings.TrimLeft(rest, ", ")
+	}
+
+	return params, true
+}
@@ -0,0 +1,235 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cosign_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/cosign"
+)
+
+const (
+	imageDigest = "sha256:0cc0c5f2d4e0fb1a5e2b6d3c1c7f1c0f6a8f4f0e1f5a2a7d8b9c0d1e2f3a4b5c"
+	otherDigest = "sha256:1111111111111111111111111111111111111111111111111111111111111111"
+)
+
+func TestParseReference(t *testing.T) {
+	t.Parallel()
+
+	for image, expected := range map[string]cosign.Reference{
+		"ghcr.io/talos-systems/installer:v0.12.1@" + imageDigest: {Registry: "ghcr.io", Repository: "talos-systems/installer", Digest: imageDigest},
+		"localhost:5000/installer@" + imageDigest:                {Registry: "localhost:5000", Repository: "installer", Digest: imageDigest},
+		"talos/installer:v0.12.1@" + imageDigest:                 {Registry: "docker.io", Repository: "talos/installer", Digest: imageDigest},
+		"installer@" + imageDigest:                               {Registry: "docker.io", Repository: "library/installer", Digest: imageDigest},
+	} {
+		ref, err := cosign.ParseReference(image)
+		require.NoError(t, err, image)
+		assert.Equal(t, expected, ref, image)
+	}
+
+	ref, err := cosign.ParseReference("ghcr.io/talos-systems/installer@" + imageDigest)
+	require.NoError(t, err)
+	assert.Equal(t, "sha256-0cc0c5f2d4e0fb1a5e2b6d3c1c7f1c0f6a8f4f0e1f5a2a7d8b9c0d1e2f3a4b5c.sig", ref.SignatureTag())
+
+	for _, image := range []string{
+		"ghcr.io/talos-systems/installer:v0.12.1",
+		"ghcr.io/talos-systems/installer@sha256:abc",
+	} {
+		_, err := cosign.ParseReference(image)
+		assert.Error(t, err, image)
+	}
+}
+
+type registry struct {
+	*httptest.Server
+
+	manifest []byte
+	blobs    map[string][]byte
+	requests int32
+}
+
+func newRegistry(t *testing.T) *registry {
+	r := &registry{
+		blobs: map[string][]byte{},
+	}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("scope") != "repository:talos-systems/installer:pull" {
+			http.Error(w, "invalid scope", http.StatusBadRequest)
+
+			return
+		}
+
+		w.Write([]byte(`{"token":"anonymous"}`)) //nolint:errcheck
+	})
+
+	mux.HandleFunc("/v2/talos-systems/installer/", func(w http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&r.requests, 1)
+
+		if req.Header.Get("Authorization") != "Bearer anonymous" {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry",scope="repository:talos-systems/installer:pull"`, r.URL))
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		path := strings.TrimPrefix(req.URL.Path, "/v2/talos-systems/installer")
+
+		switch {
+		case path == "/manifests/"+strings.Replace(imageDigest, ":", "-", 1)+".sig" && r.manifest != nil:
+			w.Write(r.manifest) //nolint:errcheck
+		case strings.HasPrefix(path, "/blobs/") && r.blobs[strings.TrimPrefix(path, "/blobs/")] != nil:
+			w.Write(r.blobs[strings.TrimPrefix(path, "/blobs/")]) //nolint:errcheck
+		default:
+			http.NotFound(w, req)
+		}
+	})
+
+	r.Server = httptest.NewTLSServer(mux)
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+// sign adds the signature layers for the digests.
+func (r *registry) sign(t *testing.T, key *ecdsa.PrivateKey, digests ...string) {
+	type layer struct {
+		MediaType   string            `json:"mediaType"`
+		Digest      string            `json:"digest"`
+		Size        int               `json:"size"`
+		Annotations map[string]string `json:"annotations"`
+	}
+
+	var layers []layer
+
+	for _, digest := range digests {
+		var payload cosign.Payload
+
+		payload.Critical.Identity.DockerReference = "ghcr.io/talos-systems/installer"
+		payload.Critical.Image.DockerManifestDigest = digest
+		payload.Critical.Type = cosign.SignatureType
+
+		data, err := json.Marshal(payload)
+		require.NoError(t, err)
+
+		hash := sha256.Sum256(data)
+
+		signature, err := ecdsa.SignASN1(rand.Reader, key, hash[:])
+		require.NoError(t, err)
+
+		blobDigest := "sha256:" + hex.EncodeToString(hash[:])
+		r.blobs[blobDigest] = data
+
+		layers = append(layers, layer{
+			MediaType:   "application/vnd.dev.cosign.simplesigning.v1+json",
+			Digest:      blobDigest,
+			Size:        len(data),
+			Annotations: map[string]string{cosign.SignatureAnnotation: base64.StdEncoding.EncodeToString(signature)},
+		})
+	}
+
+	var err error
+
+	r.manifest, err = json.Marshal(map[string]interface{}{
+		"schemaVersion": 2,
+		"mediaType":     "application/vnd.oci.image.manifest.v1+json",
+		"layers":        layers,
+	})
+	require.NoError(t, err)
+}
+
+func (r *registry) image() string {
+	return strings.TrimPrefix(r.URL, "https://") + "/talos-systems/installer:v0.12.1@" + imageDigest
+}
+
+func generateKey(t *testing.T) (*ecdsa.PrivateKey, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	return key, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	key, publicKey := generateKey(t)
+	_, otherPublicKey := generateKey(t)
+
+	t.Run("signed", func(t *testing.T) {
+		t.Parallel()
+
+		r := newRegistry(t)
+		r.sign(t, key, otherDigest, imageDigest)
+
+		verifier := &cosign.Verifier{Client: r.Client()}
+
+		require.NoError(t, verifier.Verify(ctx, r.image(), publicKey))
+
+		requests := atomic.LoadInt32(&r.requests)
+
+		// verification is cached
+		require.NoError(t, verifier.Verify(ctx, r.image(), publicKey))
+		assert.Equal(t, requests, atomic.LoadInt32(&r.requests))
+
+		err := verifier.Verify(ctx, r.image(), otherPublicKey)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "invalid signature")
+	})
+
+	t.Run("other digest", func(t *testing.T) {
+		t.Parallel()
+
+		r := newRegistry(t)
+		r.sign(t, key, otherDigest)
+
+		err := (&cosign.Verifier{Client: r.Client()}).Verify(ctx, r.image(), publicKey)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), fmt.Sprintf("signature is for digest %q", otherDigest))
+	})
+
+	t.Run("not signed", func(t *testing.T) {
+		t.Parallel()
+
+		r := newRegistry(t)
+
+		err := (&cosign.Verifier{Client: r.Client()}).Verify(ctx, r.image(), publicKey)
+		assert.ErrorIs(t, err, cosign.ErrNotSigned)
+	})
+
+	t.Run("invalid key", func(t *testing.T) {
+		t.Parallel()
+
+		r := newRegistry(t)
+
+		err := (&cosign.Verifier{Client: r.Client()}).Verify(ctx, r.image(), []byte("not a key"))
+		assert.EqualError(t, err, "public key is not PEM encoded")
+		assert.Zero(t, atomic.LoadInt32(&r.requests))
+	})
+}
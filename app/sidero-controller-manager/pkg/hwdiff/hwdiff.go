@@ -0,0 +1,207 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package hwdiff compares the hardware of the servers, or of a single server across the recorded revisions.
+//
+// Hardware is normalized into a flat set of properties before it's compared: the components are keyed by
+// the slot (PCI address, device name) rather than by the order they were reported in, the values are trimmed
+// and the hex identifiers are lowercased, and the per-unit identity (serial numbers, MAC addresses) is skipped
+// unless requested, so that the servers of the same spec (or the RMA replacement and the original) match.
+package hwdiff
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// Hardware of the server: the information reported on registration, and the inventory reported by the agent.
+type Hardware struct {
+	System    *metalv1alpha1.SystemInformation `json:"system,omitempty"`
+	CPU       *metalv1alpha1.CPUInformation    `json:"cpu,omitempty"`
+	BIOS      *metalv1alpha1.BIOSInformation   `json:"bios,omitempty"`
+	Inventory *metalv1alpha1.HardwareInventory `json:"inventory,omitempty"`
+}
+
+// FromServer returns the current hardware of the server.
+func FromServer(server *metalv1alpha1.Server) *Hardware {
+	return &Hardware{
+		System:    server.Spec.SystemInformation,
+		CPU:       server.Spec.CPU,
+		BIOS:      server.Spec.BIOS,
+		Inventory: server.Status.Inventory,
+	}
+}
+
+// Options of the normalization.
+type Options struct {
+	// IncludeIdentity includes the serial numbers and the MAC addresses, which differ for every unit.
+	IncludeIdentity bool
+}
+
+// Properties are the normalized hardware properties, keyed by the path (e.g. "disk[sda].model").
+type Properties map[string]string
+
+// Paths returns the sorted paths of the properties.
+func (p Properties) Paths() []string {
+	paths := make([]string, 0, len(p))
+
+	for path := range p {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+
+	return paths
+}
+
+// Normalize the hardware into the flat properties, empty values are skipped.
+func Normalize(hw *Hardware, opts Options) Properties {
+	p := Properties{}
+
+	set := func(path, value string) {
+		if value = normalizeValue(value); value != "" {
+			p[path] = value
+		}
+	}
+
+	setHex := func(path, value string) {
+		set(path, strings.ToLower(value))
+	}
+
+	setUint := func(path string, value uint64) {
+		if value != 0 {
+			p[path] = strconv.FormatUint(value, 10)
+		}
+	}
+
+	if hw.System != nil {
+		set("system.manufacturer", hw.System.Manufacturer)
+		set("system.productName", hw.System.ProductName)
+		set("system.version", hw.System.Version)
+		set("system.skuNumber", hw.System.SKUNumber)
+		set("system.family", hw.System.Family)
+
+		if opts.IncludeIdentity {
+			set("system.serialNumber", hw.System.SerialNumber)
+		}
+	}
+
+	if hw.CPU != nil {
+		set("cpu.manufacturer", hw.CPU.Manufacturer)
+		set("cpu.version", hw.CPU.Version)
+	}
+
+	if hw.BIOS != nil {
+		set("bios.vendor", hw.BIOS.Vendor)
+		set("bios.version", hw.BIOS.Version)
+		set("bios.releaseDate", hw.BIOS.ReleaseDate)
+	}
+
+	if hw.Inventory == nil {
+		return p
+	}
+
+	set("bmc.firmwareVersion", hw.Inventory.BMCFirmwareVersion)
+
+	for _, nic := range hw.Inventory.NetworkInterfaces {
+		// interface names depend on the kernel and the udev rules, PCI address is the slot of the card
+		key := fmt.Sprintf("nic[%s]", slot(nic.PCIAddress, nic.Name))
+
+		set(key+".name", nic.Name)
+		set(key+".driver", nic.Driver)
+		set(key+".firmwareVersion", nic.FirmwareVersion)
+		setUint(key+".speedMbps", uint64(nic.SpeedMbps))
+
+		if nic.LinkAggregation != nil {
+			set(key+".linkAggregation", nic.LinkAggregation.Source)
+		}
+
+		if opts.IncludeIdentity {
+			setHex(key+".mac", nic.MAC)
+		}
+	}
+
+	for _, gpu := range hw.Inventory.GPUs {
+		key := fmt.Sprintf("gpu[%s]", slot(gpu.PCIAddress, ""))
+
+		setHex(key+".vendorId", gpu.VendorID)
+		setHex(key+".deviceId", gpu.DeviceID)
+		set(key+".driver", gpu.Driver)
+	}
+
+	for _, disk := range hw.Inventory.Disks {
+		key := fmt.Sprintf("disk[%s]", disk.DeviceName)
+
+		set(key+".model", disk.Model)
+		set(key+".type", disk.Type)
+		setUint(key+".size", disk.Size)
+		set(key+".firmwareVersion", disk.FirmwareVersion)
+		set(key+".smartHealth", disk.SMARTHealth)
+
+		if opts.IncludeIdentity {
+			set(key+".serial", disk.Serial)
+		}
+	}
+
+	return p
+}
+
+// slot returns the normalized PCI address, or the fallback if the address is not known.
+func slot(pciAddress, fallback string) string {
+	pciAddress = strings.ToLower(strings.TrimSpace(pciAddress))
+
+	if pciAddress == "" {
+		return fallback
+	}
+
+	// domain is omitted by some tools
+	if strings.Count(pciAddress, ":") == 1 {
+		pciAddress = "0000:" + pciAddress
+	}
+
+	return pciAddress
+}
+
+// normalizeValue trims the value and collapses the whitespace, DMI strings are often padded.
+func normalizeValue(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+// Change is a property which differs between the two hardware sets.
+type Change struct {
+	Path string `json:"path"`
+	// A is the value in the first hardware set, empty if the property is missing.
+	A string `json:"a,omitempty"`
+	// B is the value in the second hardware set, empty if the property is missing.
+	B string `json:"b,omitempty"`
+}
+
+// Diff returns the properties which differ, sorted by the path.
+func Diff(a, b Properties) []Change {
+	paths := map[string]struct{}{}
+
+	for path := range a {
+		paths[path] = struct{}{}
+	}
+
+	for path := range b {
+		paths[path] = struct{}{}
+	}
+
+	changes := []Change{}
+
+	for path := range paths {
+		if a[path] != b[path] {
+			changes = append(changes, Change{Path: path, A: a[path], B: b[path]})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+
+	return changes
+}
@@ -0,0 +1,184 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hwdiff_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/constants"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/hwdiff"
+)
+
+func server(name, serial, diskModel string) *metalv1alpha1.Server {
+	return &metalv1alpha1.Server{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: metalv1alpha1.ServerSpec{
+			SystemInformation: &metalv1alpha1.SystemInformation{
+				Manufacturer: "Dell Inc. ",
+				ProductName:  "PowerEdge  R640",
+				SerialNumber: serial,
+			},
+			BIOS: &metalv1alpha1.BIOSInformation{Vendor: "Dell Inc.", Version: "2.10.2"},
+		},
+		Status: metalv1alpha1.ServerStatus{
+			Inventory: &metalv1alpha1.HardwareInventory{
+				NetworkInterfaces: []metalv1alpha1.NetworkInterface{
+					{Name: "eno1", MAC: serial + ":01", PCIAddress: "19:00.0", SpeedMbps: 10000, Driver: "i40e"},
+				},
+				GPUs: []metalv1alpha1.PCIDevice{
+					{PCIAddress: "0000:03:00.0", VendorID: "102B", DeviceID: "0536"},
+				},
+				Disks: []metalv1alpha1.DiskInformation{
+					{DeviceName: "sda", Model: diskModel, Serial: serial + "-disk", Type: "ssd", Size: 480103981056},
+				},
+				UpdatedAt: metav1.Now(),
+			},
+		},
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, hwdiff.Properties{
+		"system.manufacturer":         "Dell Inc.",
+		"system.productName":          "PowerEdge R640",
+		"bios.vendor":                 "Dell Inc.",
+		"bios.version":                "2.10.2",
+		"nic[0000:19:00.0].name":      "eno1",
+		"nic[0000:19:00.0].driver":    "i40e",
+		"nic[0000:19:00.0].speedMbps": "10000",
+		"gpu[0000:03:00.0].vendorId":  "102b",
+		"gpu[0000:03:00.0].deviceId":  "0536",
+		"disk[sda].model":             "SSDSC2KB480G8",
+		"disk[sda].type":              "ssd",
+		"disk[sda].size":              "480103981056",
+	}, hwdiff.Normalize(hwdiff.FromServer(server("server-1", "ABC", "SSDSC2KB480G8")), hwdiff.Options{}))
+
+	properties := hwdiff.Normalize(hwdiff.FromServer(server("server-1", "ABC", "SSDSC2KB480G8")), hwdiff.Options{IncludeIdentity: true})
+	assert.Equal(t, "ABC", properties["system.serialNumber"])
+	assert.Equal(t, "abc:01", properties["nic[0000:19:00.0].mac"])
+	assert.Equal(t, "ABC-disk", properties["disk[sda].serial"])
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	a := hwdiff.Normalize(hwdiff.FromServer(server("server-1", "ABC", "SSDSC2KB480G8")), hwdiff.Options{})
+
+	// the replacement of the same spec
+	b := hwdiff.Normalize(hwdiff.FromServer(server("server-2", "DEF", "SSDSC2KB480G8")), hwdiff.Options{})
+	assert.Empty(t, hwdiff.Diff(a, b))
+
+	replacement := server("server-2", "DEF", "MZ7LH480HAHQ")
+	replacement.Status.Inventory.GPUs = nil
+	replacement.Status.Inventory.NetworkInterfaces[0].SpeedMbps = 1000
+
+	b = hwdiff.Normalize(hwdiff.FromServer(replacement), hwdiff.Options{})
+
+	assert.Equal(t, []hwdiff.Change{
+		{Path: "disk[sda].model", A: "SSDSC2KB480G8", B: "MZ7LH480HAHQ"},
+		{Path: "gpu[0000:03:00.0].deviceId", A: "0536"},
+		{Path: "gpu[0000:03:00.0].vendorId", A: "102b"},
+		{Path: "nic[0000:19:00.0].speedMbps", A: "10000", B: "1000"},
+	}, hwdiff.Diff(a, b))
+}
+
+func TestParseSource(t *testing.T) {
+	t.Parallel()
+
+	for s, expected := range map[string]hwdiff.Source{
+		"server-1":   {Server: "server-1"},
+		"server-1@3": {Server: "server-1", Revision: 3},
+	} {
+		src, err := hwdiff.ParseSource(s)
+		require.NoError(t, err)
+		assert.Equal(t, expected, src)
+		assert.Equal(t, s, src.String())
+	}
+
+	for _, s := range []string{"@1", "server-1@0", "server-1@latest"} {
+		_, err := hwdiff.ParseSource(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestRecord(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+
+	s := server("server-1", "ABC", "SSDSC2KB480G8")
+
+	c := fake.NewFakeClientWithScheme(scheme, s,
+		// wipe certificate of the same server
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: constants.SideroNamespace,
+				Name:      "wipe-certificate",
+				Labels:    map[string]string{hwdiff.ServerLabel: "server-1"},
+			},
+		},
+	)
+
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+
+	revision, err := hwdiff.Record(ctx, c, constants.SideroNamespace, s, now, 2)
+	require.NoError(t, err)
+	require.NotNil(t, revision)
+	assert.Equal(t, 1, revision.Revision)
+
+	// inventory is reported again with the same hardware
+	s.Status.Inventory.UpdatedAt = metav1.NewTime(now.Add(time.Hour))
+
+	revision, err = hwdiff.Record(ctx, c, constants.SideroNamespace, s, now.Add(time.Hour), 2)
+	require.NoError(t, err)
+	assert.Nil(t, revision)
+
+	for i, model := range []string{"MZ7LH480HAHQ", "SSDSC2KB960G8"} {
+		s.Status.Inventory.Disks[0].Model = model
+
+		revision, err = hwdiff.Record(ctx, c, constants.SideroNamespace, s, now.Add(time.Duration(i+2)*time.Hour), 2)
+		require.NoError(t, err)
+		require.NotNil(t, revision)
+		assert.Equal(t, i+2, revision.Revision)
+	}
+
+	// the oldest revision is pruned
+	revisions, err := hwdiff.List(ctx, c, constants.SideroNamespace, "server-1")
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, 2, revisions[0].Revision)
+	assert.Equal(t, now.Add(2*time.Hour), revisions[0].RecordedAt)
+	assert.Equal(t, "MZ7LH480HAHQ", revisions[0].Hardware.Inventory.Disks[0].Model)
+	assert.Equal(t, 3, revisions[1].Revision)
+
+	_, err = hwdiff.Load(ctx, c, constants.SideroNamespace, hwdiff.Source{Server: "server-1", Revision: 1})
+	assert.EqualError(t, err, `revision 1 of server "server-1" not found`)
+
+	hw, err := hwdiff.Load(ctx, c, constants.SideroNamespace, hwdiff.Source{Server: "server-1", Revision: 2})
+	require.NoError(t, err)
+
+	current, err := hwdiff.Load(ctx, c, constants.SideroNamespace, hwdiff.Source{Server: "server-1"})
+	require.NoError(t, err)
+
+	assert.Equal(t, []hwdiff.Change{
+		{Path: "disk[sda].model", A: "MZ7LH480HAHQ", B: "SSDSC2KB480G8"},
+	}, hwdiff.Diff(hwdiff.Normalize(hw, hwdiff.Options{}), hwdiff.Normalize(current, hwdiff.Options{})))
+}
@@ -0,0 +1,272 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package hwdiff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// Labels and keys of the ConfigMaps the hardware revisions are stored in.
+const (
+	// ServerLabel is set on the revision ConfigMaps to the server name.
+	ServerLabel = "metal.sidero.dev/server"
+	// RevisionLabel is the revision number, revisions of each server are numbered from 1.
+	RevisionLabel = "metal.sidero.dev/hardware-revision"
+
+	// HardwareKey holds the JSON-encoded Hardware.
+	HardwareKey = "hardware.json"
+	// RecordedAtKey holds the time the revision was recorded (RFC 3339).
+	RecordedAtKey = "recordedAt"
+)
+
+// DefaultMaxRevisions is the default number of the revisions kept for each server.
+const DefaultMaxRevisions = 10
+
+// Revision is the hardware of the server recorded when the hardware changed.
+type Revision struct {
+	Server     string    `json:"server"`
+	Revision   int       `json:"revision"`
+	RecordedAt time.Time `json:"recordedAt"`
+	Hardware   *Hardware `json:"hardware"`
+}
+
+// revisionName returns the name of the revision ConfigMap.
+func revisionName(server string, revision int) string {
+	return fmt.Sprintf("%s-hardware-%d", server, revision)
+}
+
+// Record the current hardware of the server as the new revision, if it differs from the last revision.
+//
+// Only the last maxRevisions revisions are kept. Record returns the new revision, or nil if the hardware didn't change.
+func Record(ctx context.Context, c client.Client, namespace string, server *metalv1alpha1.Server, now time.Time, maxRevisions int) (*Revision, error) {
+	if maxRevisions <= 0 {
+		maxRevisions = DefaultMaxRevisions
+	}
+
+	revisions, err := List(ctx, c, namespace, server.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	hw := FromServer(server)
+
+	// inventory timestamp is updated on every report
+	compared := func(hw *Hardware) Properties {
+		return Normalize(hw, Options{IncludeIdentity: true})
+	}
+
+	if len(revisions) > 0 && reflect.DeepEqual(compared(revisions[len(revisions)-1].Hardware), compared(hw)) {
+		return nil, nil
+	}
+
+	revision := &Revision{
+		Server:     server.Name,
+		Revision:   1,
+		RecordedAt: now.UTC().Truncate(time.Second),
+		Hardware:   hw,
+	}
+
+	if len(revisions) > 0 {
+		revision.Revision = revisions[len(revisions)-1].Revision + 1
+	}
+
+	data, err := json.Marshal(revision.Hardware)
+	if err != nil {
+		return nil, err
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      revisionName(server.Name, revision.Revision),
+			Labels: map[string]string{
+				ServerLabel:   server.Name,
+				RevisionLabel: strconv.Itoa(revision.Revision),
+			},
+		},
+		Data: map[string]string{
+			HardwareKey:   string(data),
+			RecordedAtKey: revision.RecordedAt.Format(time.RFC3339),
+		},
+	}
+
+	if err = c.Create(ctx, cm); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			// the agent retried reporting the same inventory
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	revisions = append(revisions, *revision)
+
+	for _, old := range revisions[:len(revisions)-minInt(len(revisions), maxRevisions)] {
+		if err = c.Delete(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      revisionName(old.Server, old.Revision),
+			},
+		}); err != nil && !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	return revision, nil
+}
+
+// List returns the recorded revisions of the server, oldest first.
+func List(ctx context.Context, c client.Client, namespace, server string) ([]Revision, error) {
+	var list corev1.ConfigMapList
+
+	if err := c.List(ctx, &list, client.InNamespace(namespace), client.MatchingLabels{ServerLabel: server}); err != nil {
+		return nil, err
+	}
+
+	revisions := make([]Revision, 0, len(list.Items))
+
+	for i := range list.Items {
+		// wipe certificates are labeled with the server as well
+		if _, ok := list.Items[i].Labels[RevisionLabel]; !ok {
+			continue
+		}
+
+		revision, err := FromConfigMap(&list.Items[i])
+		if err != nil {
+			return nil, fmt.Errorf("error decoding hardware revision %s/%s: %w", list.Items[i].Namespace, list.Items[i].Name, err)
+		}
+
+		revisions = append(revisions, *revision)
+	}
+
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].Revision < revisions[j].Revision })
+
+	return revisions, nil
+}
+
+// Get returns the revision of the server.
+func Get(ctx context.Context, c client.Client, namespace, server string, revision int) (*Revision, error) {
+	var cm corev1.ConfigMap
+
+	if err := c.Get(ctx, client.ObjectKey{Namespace: namespace, Name: revisionName(server, revision)}, &cm); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("revision %d of server %q not found", revision, server)
+		}
+
+		return nil, err
+	}
+
+	return FromConfigMap(&cm)
+}
+
+// FromConfigMap decodes the revision stored in the ConfigMap.
+func FromConfigMap(cm *corev1.ConfigMap) (*Revision, error) {
+	revision := &Revision{
+		Server:   cm.Labels[ServerLabel],
+		Hardware: &Hardware{},
+	}
+
+	var err error
+
+	if revision.Revision, err = strconv.Atoi(cm.Labels[RevisionLabel]); err != nil {
+		return nil, fmt.Errorf("invalid revision: %w", err)
+	}
+
+	if revision.RecordedAt, err = time.Parse(time.RFC3339, cm.Data[RecordedAtKey]); err != nil {
+		return nil, fmt.Errorf("invalid recording time: %w", err)
+	}
+
+	if err = json.Unmarshal([]byte(cm.Data[HardwareKey]), revision.Hardware); err != nil {
+		return nil, err
+	}
+
+	return revision, nil
+}
+
+// Source of the hardware to compare: the current hardware of the server, or the recorded revision.
+type Source struct {
+	Server string
+	// Revision is zero for the current hardware.
+	Revision int
+}
+
+// ParseSource parses the source in the "<server>[@<revision>]" format.
+func ParseSource(s string) (Source, error) {
+	server, revision := s, ""
+
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		server, revision = s[:i], s[i+1:]
+	}
+
+	if server == "" {
+		return Source{}, fmt.Errorf("server name is missing in %q", s)
+	}
+
+	src := Source{Server: server}
+
+	if revision != "" {
+		var err error
+
+		if src.Revision, err = strconv.Atoi(revision); err != nil || src.Revision <= 0 {
+			return Source{}, fmt.Errorf("invalid revision in %q, revisions are numbered from 1", s)
+		}
+	}
+
+	return src, nil
+}
+
+func (src Source) String() string {
+	if src.Revision == 0 {
+		return src.Server
+	}
+
+	return fmt.Sprintf("%s@%d", src.Server, src.Revision)
+}
+
+// Load the hardware from the source.
+func Load(ctx context.Context, c client.Client, namespace string, src Source) (*Hardware, error) {
+	if src.Revision > 0 {
+		revision, err := Get(ctx, c, namespace, src.Server, src.Revision)
+		if err != nil {
+			return nil, err
+		}
+
+		return revision.Hardware, nil
+	}
+
+	var server metalv1alpha1.Server
+
+	if err := c.Get(ctx, client.ObjectKey{Name: src.Server}, &server); err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, fmt.Errorf("server %q not found", src.Server)
+		}
+
+		return nil, err
+	}
+
+	return FromServer(&server), nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
@@ -0,0 +1,115 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package loadtest
+
+import (
+	"encoding/json"
+	"math"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// z99 is the 99th percentile of the standard normal distribution.
+const z99 = 2.3263
+
+// Latency is the log-normal latency distribution defined by the median and the 99th percentile,
+// which matches the long tail of the BMC response times.
+type Latency struct {
+	Median time.Duration
+	P99    time.Duration
+}
+
+// Sample the latency.
+func (l Latency) Sample(r *rand.Rand) time.Duration {
+	if l.Median <= 0 {
+		return 0
+	}
+
+	if l.P99 <= l.Median {
+		return l.Median
+	}
+
+	mu := math.Log(float64(l.Median))
+	sigma := (math.Log(float64(l.P99)) - mu) / z99
+
+	return time.Duration(math.Exp(mu + sigma*r.NormFloat64()))
+}
+
+// BMCSimulator serves the management API (see ManagementAPI of the Server spec) of the simulated servers.
+//
+// Each server is served at /<uuid>, requests are delayed according to the latency distribution,
+// and fail with the failure rate.
+type BMCSimulator struct {
+	Latency     Latency
+	FailureRate float64
+
+	recorder *recorder
+
+	mu    sync.Mutex
+	rand  *rand.Rand
+	power map[string]bool
+}
+
+func (s *BMCSimulator) roll() (time.Duration, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.rand == nil {
+		s.rand = rand.New(rand.NewSource(time.Now().UnixNano())) //nolint:gosec
+	}
+
+	return s.Latency.Sample(s.rand), s.rand.Float64() < s.FailureRate
+}
+
+func (s *BMCSimulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	uuid, action := parts[0], parts[1]
+
+	latency, failed := s.roll()
+
+	select {
+	case <-time.After(latency):
+	case <-r.Context().Done():
+		return
+	}
+
+	if s.recorder != nil {
+		s.recorder.record(OperationBMC, latency, nil)
+	}
+
+	if failed {
+		http.Error(w, "simulated BMC failure", http.StatusInternalServerError)
+
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.power == nil {
+		s.power = map[string]bool{}
+	}
+
+	switch action {
+	case "poweron", "reboot":
+		s.power[uuid] = true
+	case "poweroff":
+		s.power[uuid] = false
+	case "pxeboot":
+	case "status":
+		json.NewEncoder(w).Encode(struct{ PoweredOn bool }{s.power[uuid]}) //nolint:errcheck
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,380 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package loadtest simulates a fleet of servers against a Sidero deployment and checks the performance envelope.
+//
+// Simulated servers register via the agent API, then all of them hit the iPXE and metadata endpoints at once (boot storm).
+// Optionally the servers are attached to the simulated BMCs (management API) with the latency distribution
+// of the real BMCs and accepted, so that Sidero power manages them while the latencies are measured.
+package loadtest
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/api"
+)
+
+// RunLabel is set on the simulated servers to the load test run ID.
+const RunLabel = "loadtest.sidero.dev/run"
+
+// Options configures the load test.
+type Options struct {
+	// Endpoint is the address (host:port) of the Sidero API (agent gRPC API, iPXE and metadata).
+	Endpoint string
+	// RunID identifies the simulated servers of the run, random if empty.
+	RunID string
+	// Servers is the number of the simulated servers.
+	Servers int
+	// Concurrency is the number of the requests in flight.
+	Concurrency int
+	// StormRequests is the number of iPXE and metadata requests each server sends in the boot storm.
+	StormRequests int
+
+	// Client is the management cluster client, servers are not labeled, attached to the BMCs and cleaned up if nil.
+	Client client.Client
+	// Accept the simulated servers, Sidero starts power managing them.
+	Accept bool
+	// Cleanup deletes the simulated servers in the end of the run.
+	Cleanup bool
+
+	// BMCListenAddr is the address the simulated BMCs listen on, BMCs are not simulated if empty.
+	BMCListenAddr string
+	// BMCEndpoint is the address (host:port) the simulated BMCs are reachable at from Sidero, defaults to BMCListenAddr.
+	BMCEndpoint string
+	// BMCLatency is the latency distribution of the simulated BMCs.
+	BMCLatency Latency
+	// BMCFailureRate is the fraction of the simulated BMC requests which fail.
+	BMCFailureRate float64
+	// Soak is how long the simulated BMCs are served after the boot storm.
+	Soak time.Duration
+
+	Thresholds Thresholds
+}
+
+// Run the load test.
+//
+// Error is only returned if the load test can't be run, exceeded thresholds are reported as violations in the report.
+func Run(ctx context.Context, opts Options, log io.Writer) (*Report, error) {
+	if opts.Servers <= 0 {
+		return nil, fmt.Errorf("number of servers should be positive")
+	}
+
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 1
+	}
+
+	if opts.RunID == "" {
+		opts.RunID = fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+
+	uuids := make([]string, opts.Servers)
+	for i := range uuids {
+		uuids[i] = serverUUID(opts.RunID, i)
+	}
+
+	rec := &recorder{}
+	start := time.Now()
+
+	if opts.BMCListenAddr != "" {
+		stop, err := serveBMCs(opts, rec)
+		if err != nil {
+			return nil, err
+		}
+
+		defer stop()
+	}
+
+	conn, err := grpc.DialContext(ctx, opts.Endpoint, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close() //nolint:errcheck
+
+	fmt.Fprintf(log, "registering %d servers (run %s)\n", opts.Servers, opts.RunID)
+
+	agent := api.NewAgentClient(conn)
+
+	registered := parallel(ctx, opts.Concurrency, uuids, func(ctx context.Context, uuid string) error {
+		start := time.Now()
+
+		_, err := agent.CreateServer(ctx, &api.CreateServerRequest{
+			Hostname: "loadtest-" + uuid[len(uuid)-8:],
+			SystemInformation: &api.SystemInformation{
+				Uuid:         uuid,
+				Manufacturer: "Sidero",
+				ProductName:  "Load Test",
+				SerialNumber: uuid[len(uuid)-12:],
+			},
+			Cpu: &api.CPU{
+				Manufacturer: "Sidero",
+				Version:      "Simulated CPU",
+			},
+		})
+
+		rec.record(OperationRegistration, time.Since(start), err)
+
+		return err
+	})
+
+	if opts.Cleanup && opts.Client != nil {
+		defer func() {
+			fmt.Fprintf(log, "deleting %d servers\n", len(registered))
+
+			cleanup(opts.Client, registered, log)
+		}()
+	}
+
+	if opts.Client != nil {
+		fmt.Fprintf(log, "configuring %d servers\n", len(registered))
+
+		if err = configureServers(ctx, opts, registered); err != nil {
+			return nil, err
+		}
+	}
+
+	fmt.Fprintf(log, "sending %d iPXE and metadata requests\n", 2*len(registered)*opts.StormRequests)
+
+	storm(ctx, opts, registered, rec)
+
+	if opts.BMCListenAddr != "" && opts.Soak > 0 {
+		fmt.Fprintf(log, "serving simulated BMCs for %s\n", opts.Soak)
+
+		select {
+		case <-time.After(opts.Soak):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	report := &Report{
+		Servers:    opts.Servers,
+		Duration:   time.Since(start),
+		Operations: rec.stats(),
+	}
+
+	report.check(opts.Thresholds)
+
+	return report, nil
+}
+
+// serverUUID generates the stable UUID of the simulated server, so that the same run can be cleaned up later.
+func serverUUID(runID string, i int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%d", runID, i)))
+
+	// version 4, RFC 4122 variant
+	sum[6] = (sum[6] & 0x0f) | 0x40
+	sum[8] = (sum[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// parallel runs the function for the items with the concurrency, the items the function succeeded for are returned.
+func parallel(ctx context.Context, concurrency int, items []string, f func(context.Context, string) error) []string {
+	var (
+		mu        sync.Mutex
+		succeeded []string
+	)
+
+	ch := make(chan string)
+
+	var eg errgroup.Group
+
+	for i := 0; i < concurrency; i++ {
+		eg.Go(func() error {
+			for item := range ch {
+				if f(ctx, item) == nil {
+					mu.Lock()
+					succeeded = append(succeeded, item)
+					mu.Unlock()
+				}
+			}
+
+			return nil
+		})
+	}
+
+	for _, item := range items {
+		select {
+		case ch <- item:
+		case <-ctx.Done():
+		}
+	}
+
+	close(ch)
+
+	eg.Wait() //nolint:errcheck
+
+	return succeeded
+}
+
+func serveBMCs(opts Options, rec *recorder) (func(), error) {
+	listener, err := net.Listen("tcp", opts.BMCListenAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &http.Server{
+		Handler: &BMCSimulator{
+			Latency:     opts.BMCLatency,
+			FailureRate: opts.BMCFailureRate,
+			recorder:    rec,
+		},
+	}
+
+	go srv.Serve(listener) //nolint:errcheck
+
+	return func() {
+		srv.Close() //nolint:errcheck
+	}, nil
+}
+
+// configureServers labels the servers with the run ID, attaches them to the simulated BMCs and accepts them.
+func configureServers(ctx context.Context, opts Options, uuids []string) error {
+	bmcEndpoint := opts.BMCEndpoint
+	if bmcEndpoint == "" {
+		bmcEndpoint = opts.BMCListenAddr
+	}
+
+	var (
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	parallel(ctx, opts.Concurrency, uuids, func(ctx context.Context, uuid string) error {
+		err := configureServer(ctx, opts, bmcEndpoint, uuid)
+		if err != nil {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if firstErr == nil {
+				firstErr = fmt.Errorf("error configuring server %s: %w", uuid, err)
+			}
+		}
+
+		return err
+	})
+
+	return firstErr
+}
+
+func configureServer(ctx context.Context, opts Options, bmcEndpoint, uuid string) error {
+	var server metalv1alpha1.Server
+
+	if err := opts.Client.Get(ctx, types.NamespacedName{Name: uuid}, &server); err != nil {
+		return err
+	}
+
+	patch := client.MergeFrom(server.DeepCopy())
+
+	if server.Labels == nil {
+		server.Labels = map[string]string{}
+	}
+
+	server.Labels[RunLabel] = opts.RunID
+
+	if opts.BMCListenAddr != "" {
+		server.Spec.ManagementAPI = &metalv1alpha1.ManagementAPI{
+			Endpoint: bmcEndpoint + "/" + uuid,
+		}
+	}
+
+	if opts.Accept {
+		server.Spec.Accepted = true
+	}
+
+	return opts.Client.Patch(ctx, &server, patch)
+}
+
+// storm sends the iPXE and metadata requests of all the servers at once.
+func storm(ctx context.Context, opts Options, uuids []string, rec *recorder) {
+	httpClient := &http.Client{Timeout: 30 * time.Second}
+
+	get := func(ctx context.Context, operation, u string) {
+		start := time.Now()
+
+		err := func() error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+			if err != nil {
+				return err
+			}
+
+			resp, err := httpClient.Do(req)
+			if err != nil {
+				return err
+			}
+
+			defer resp.Body.Close() //nolint:errcheck
+
+			if _, err = io.Copy(ioutil.Discard, resp.Body); err != nil {
+				return err
+			}
+
+			// simulated servers are not allocated, so 4xx responses (e.g. no config) are expected
+			if resp.StatusCode >= http.StatusInternalServerError {
+				return fmt.Errorf("%s: %s", u, resp.Status)
+			}
+
+			return nil
+		}()
+
+		rec.record(operation, time.Since(start), err)
+	}
+
+	requests := make([]string, 0, len(uuids)*opts.StormRequests)
+
+	for i := 0; i < opts.StormRequests; i++ {
+		requests = append(requests, uuids...)
+	}
+
+	parallel(ctx, opts.Concurrency, requests, func(ctx context.Context, uuid string) error {
+		query := url.Values{
+			"uuid": {uuid},
+			"mac":  {macFromUUID(uuid)},
+			"arch": {"x86_64"},
+		}.Encode()
+
+		get(ctx, OperationIPXE, fmt.Sprintf("http://%s/ipxe?%s", opts.Endpoint, query))
+		get(ctx, OperationMetadata, fmt.Sprintf("http://%s/configdata?%s", opts.Endpoint, query))
+
+		return nil
+	})
+}
+
+// macFromUUID returns the locally administered MAC address of the simulated server.
+func macFromUUID(uuid string) string {
+	tail := uuid[len(uuid)-10:]
+
+	return "02-" + tail[0:2] + "-" + tail[2:4] + "-" + tail[4:6] + "-" + tail[6:8] + "-" + tail[8:10]
+}
+
+func cleanup(c client.Client, uuids []string, log io.Writer) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	for _, uuid := range uuids {
+		server := &metalv1alpha1.Server{}
+		server.Name = uuid
+
+		if err := c.Delete(ctx, server); err != nil && !apierrors.IsNotFound(err) {
+			fmt.Fprintf(log, "error deleting server %s: %s\n", uuid, err)
+		}
+	}
+}
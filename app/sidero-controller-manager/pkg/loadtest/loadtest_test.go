@@ -0,0 +1,283 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package loadtest_test
+
+import (
+	"bytes"
+	"context"
+	"math/rand"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"google.golang.org/grpc"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/internal/api"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/loadtest"
+)
+
+type agentServer struct {
+	api.UnimplementedAgentServer
+
+	c client.Client
+}
+
+func (s *agentServer) CreateServer(ctx context.Context, in *api.CreateServerRequest) (*api.CreateServerResponse, error) {
+	err := s.c.Create(ctx, &metalv1alpha1.Server{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: in.GetSystemInformation().GetUuid(),
+		},
+		Spec: metalv1alpha1.ServerSpec{
+			Hostname: in.GetHostname(),
+		},
+	})
+
+	return &api.CreateServerResponse{}, err
+}
+
+// serveSidero serves the agent API, iPXE and metadata endpoints on the single port, as Sidero does.
+func serveSidero(t *testing.T, c client.Client, metadataStatus int) (string, *int64) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	grpcServer := grpc.NewServer()
+	api.RegisterAgentServer(grpcServer, &agentServer{c: c})
+
+	var httpRequests int64
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ipxe", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&httpRequests, 1)
+
+		w.Write([]byte("#!ipxe\n")) //nolint:errcheck
+	})
+	mux.HandleFunc("/configdata", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&httpRequests, 1)
+
+		http.Error(w, "server is not allocated", metadataStatus)
+	})
+
+	srv := &http.Server{
+		Handler: h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ProtoMajor == 2 && strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+				grpcServer.ServeHTTP(w, r)
+
+				return
+			}
+
+			mux.ServeHTTP(w, r)
+		}), &http2.Server{}),
+	}
+
+	go srv.Serve(listener) //nolint:errcheck
+
+	t.Cleanup(func() {
+		srv.Close() //nolint:errcheck
+	})
+
+	return listener.Addr().String(), &httpRequests
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+
+	for name, tc := range map[string]struct {
+		metadataStatus int
+		expectedPassed bool
+	}{
+		"not allocated": {
+			metadataStatus: http.StatusNotFound,
+			expectedPassed: true,
+		},
+		"metadata failures": {
+			metadataStatus: http.StatusInternalServerError,
+			expectedPassed: false,
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			c := fake.NewFakeClientWithScheme(scheme)
+			endpoint, httpRequests := serveSidero(t, c, tc.metadataStatus)
+
+			var log bytes.Buffer
+
+			report, err := loadtest.Run(context.Background(), loadtest.Options{
+				Endpoint:      endpoint,
+				RunID:         "test",
+				Servers:       20,
+				Concurrency:   5,
+				StormRequests: 2,
+				Client:        c,
+				Accept:        true,
+				BMCListenAddr: "127.0.0.1:0",
+				BMCEndpoint:   "192.0.2.1:8080",
+				Thresholds:    loadtest.DefaultThresholds,
+			}, &log)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedPassed, report.Passed(), report.Violations)
+			assert.EqualValues(t, 20*2*2, atomic.LoadInt64(httpRequests))
+
+			counts := map[string]int{}
+
+			for _, s := range report.Operations {
+				counts[s.Operation] = s.Count
+			}
+
+			assert.Equal(t, map[string]int{
+				loadtest.OperationRegistration: 20,
+				loadtest.OperationIPXE:         40,
+				loadtest.OperationMetadata:     40,
+			}, counts)
+
+			var servers metalv1alpha1.ServerList
+
+			require.NoError(t, c.List(context.Background(), &servers))
+			require.Len(t, servers.Items, 20)
+
+			for _, server := range servers.Items {
+				assert.True(t, server.Spec.Accepted)
+				assert.Equal(t, "test", server.Labels[loadtest.RunLabel])
+				assert.Equal(t, "192.0.2.1:8080/"+server.Name, server.Spec.ManagementAPI.Endpoint)
+			}
+		})
+	}
+}
+
+func TestRunCleanup(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+
+	c := fake.NewFakeClientWithScheme(scheme)
+	endpoint, _ := serveSidero(t, c, http.StatusNotFound)
+
+	var log bytes.Buffer
+
+	report, err := loadtest.Run(context.Background(), loadtest.Options{
+		Endpoint:      endpoint,
+		Servers:       5,
+		StormRequests: 1,
+		Client:        c,
+		Cleanup:       true,
+		Thresholds:    loadtest.DefaultThresholds,
+	}, &log)
+	require.NoError(t, err)
+
+	assert.True(t, report.Passed(), report.Violations)
+
+	var servers metalv1alpha1.ServerList
+
+	require.NoError(t, c.List(context.Background(), &servers))
+	assert.Empty(t, servers.Items)
+}
+
+func TestBMCSimulator(t *testing.T) {
+	t.Parallel()
+
+	bmc := &loadtest.BMCSimulator{}
+
+	srv := &http.Server{Handler: bmc}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go srv.Serve(listener) //nolint:errcheck
+
+	defer srv.Close() //nolint:errcheck
+
+	endpoint := "http://" + listener.Addr().String() + "/00000000-0000-0000-0000-000000000001"
+
+	status := func() string {
+		resp, err := http.Get(endpoint + "/status") //nolint:noctx
+		require.NoError(t, err)
+
+		defer resp.Body.Close() //nolint:errcheck
+
+		var buf bytes.Buffer
+
+		_, err = buf.ReadFrom(resp.Body)
+		require.NoError(t, err)
+
+		return strings.TrimSpace(buf.String())
+	}
+
+	post := func(action string) {
+		resp, err := http.Post(endpoint+"/"+action, "", nil) //nolint:noctx
+		require.NoError(t, err)
+
+		resp.Body.Close() //nolint:errcheck
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	assert.Equal(t, `{"PoweredOn":false}`, status())
+
+	post("pxeboot")
+	post("poweron")
+
+	assert.Equal(t, `{"PoweredOn":true}`, status())
+
+	post("poweroff")
+
+	assert.Equal(t, `{"PoweredOn":false}`, status())
+}
+
+func TestLatency(t *testing.T) {
+	t.Parallel()
+
+	l := loadtest.Latency{Median: 200 * time.Millisecond, P99: 2 * time.Second}
+	r := rand.New(rand.NewSource(1)) //nolint:gosec
+
+	samples := make([]time.Duration, 10000)
+	for i := range samples {
+		samples[i] = l.Sample(r)
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	assert.InDelta(t, float64(l.Median), float64(samples[len(samples)/2]), float64(20*time.Millisecond))
+	assert.InDelta(t, float64(l.P99), float64(samples[len(samples)*99/100]), float64(400*time.Millisecond))
+
+	assert.Equal(t, 100*time.Millisecond, loadtest.Latency{Median: 100 * time.Millisecond}.Sample(r))
+	assert.Zero(t, loadtest.Latency{}.Sample(r))
+}
+
+func TestParseLatencyThresholds(t *testing.T) {
+	t.Parallel()
+
+	thresholds, err := loadtest.ParseLatencyThresholds("registration=2s, metadata=500ms")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]time.Duration{
+		loadtest.OperationRegistration: 2 * time.Second,
+		loadtest.OperationMetadata:     500 * time.Millisecond,
+	}, thresholds)
+
+	for _, s := range []string{"registration", "allocation=1s", "metadata=fast"} {
+		_, err = loadtest.ParseLatencyThresholds(s)
+		assert.Error(t, err, s)
+	}
+}
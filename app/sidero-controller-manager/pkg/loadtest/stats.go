@@ -0,0 +1,228 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package loadtest
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// Operations measured by the load test.
+const (
+	OperationRegistration = "registration"
+	OperationIPXE         = "ipxe"
+	OperationMetadata     = "metadata"
+	OperationBMC          = "bmc"
+)
+
+// recorder collects the latencies of the operations.
+type recorder struct {
+	mu         sync.Mutex
+	operations map[string]*samples
+}
+
+type samples struct {
+	latencies []time.Duration
+	errors    int
+}
+
+func (r *recorder) record(operation string, latency time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.operations == nil {
+		r.operations = map[string]*samples{}
+	}
+
+	s, ok := r.operations[operation]
+	if !ok {
+		s = &samples{}
+		r.operations[operation] = s
+	}
+
+	if err != nil {
+		s.errors++
+
+		return
+	}
+
+	s.latencies = append(s.latencies, latency)
+}
+
+// OperationStats is the summary of the operation latencies.
+//
+// Latencies are calculated over the successful requests only.
+type OperationStats struct {
+	Operation string        `json:"operation"`
+	Count     int           `json:"count"`
+	Errors    int           `json:"errors"`
+	P50       time.Duration `json:"p50"`
+	P95       time.Duration `json:"p95"`
+	P99       time.Duration `json:"p99"`
+	Max       time.Duration `json:"max"`
+}
+
+// ErrorRate returns the fraction of the failed requests.
+func (s *OperationStats) ErrorRate() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+
+	return float64(s.Errors) / float64(s.Count)
+}
+
+func (r *recorder) stats() []OperationStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]OperationStats, 0, len(r.operations))
+
+	for operation, s := range r.operations {
+		latencies := append([]time.Duration(nil), s.latencies...)
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		stats := OperationStats{
+			Operation: operation,
+			Count:     len(latencies) + s.errors,
+			Errors:    s.errors,
+		}
+
+		if len(latencies) > 0 {
+			stats.P50 = percentile(latencies, 0.50)
+			stats.P95 = percentile(latencies, 0.95)
+			stats.P99 = percentile(latencies, 0.99)
+			stats.Max = latencies[len(latencies)-1]
+		}
+
+		result = append(result, stats)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Operation < result[j].Operation })
+
+	return result
+}
+
+// percentile of the sorted latencies (nearest rank).
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(p*float64(len(sorted))+0.5) - 1
+
+	switch {
+	case rank < 0:
+		rank = 0
+	case rank >= len(sorted):
+		rank = len(sorted) - 1
+	}
+
+	return sorted[rank]
+}
+
+// Thresholds are the pass/fail criteria of the load test (the performance envelope).
+type Thresholds struct {
+	// MaxErrorRate is the maximum fraction of the failed requests of any operation.
+	MaxErrorRate float64
+	// MaxP99 is the maximum 99th percentile latency per operation, operations not listed are not checked.
+	MaxP99 map[string]time.Duration
+}
+
+// DefaultThresholds is the performance envelope the load test checks by default.
+var DefaultThresholds = Thresholds{
+	MaxErrorRate: 0.01,
+	MaxP99: map[string]time.Duration{
+		OperationRegistration: 2 * time.Second,
+		OperationIPXE:         time.Second,
+		OperationMetadata:     time.Second,
+	},
+}
+
+// ParseLatencyThresholds parses the comma delimited list of operation=duration pairs.
+func ParseLatencyThresholds(s string) (map[string]time.Duration, error) {
+	result := map[string]time.Duration{}
+
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid latency threshold %q, expected operation=duration", pair)
+		}
+
+		switch parts[0] {
+		case OperationRegistration, OperationIPXE, OperationMetadata, OperationBMC:
+		default:
+			return nil, fmt.Errorf("unknown operation %q", parts[0])
+		}
+
+		d, err := time.ParseDuration(parts[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid latency threshold %q: %w", pair, err)
+		}
+
+		result[parts[0]] = d
+	}
+
+	return result, nil
+}
+
+// Report is the result of the load test.
+type Report struct {
+	Servers    int              `json:"servers"`
+	Duration   time.Duration    `json:"duration"`
+	Operations []OperationStats `json:"operations"`
+	// Violations lists the thresholds which were exceeded, the load test fails if there are any.
+	Violations []string `json:"violations,omitempty"`
+}
+
+// Passed returns true if no threshold was exceeded.
+func (r *Report) Passed() bool {
+	return len(r.Violations) == 0
+}
+
+// check the operations against the thresholds.
+func (r *Report) check(thresholds Thresholds) {
+	for _, s := range r.Operations {
+		if rate := s.ErrorRate(); rate > thresholds.MaxErrorRate {
+			r.Violations = append(r.Violations, fmt.Sprintf("%s: error rate %.2f%% exceeds %.2f%%", s.Operation, rate*100, thresholds.MaxErrorRate*100))
+		}
+
+		if max, ok := thresholds.MaxP99[s.Operation]; ok && s.P99 > max {
+			r.Violations = append(r.Violations, fmt.Sprintf("%s: p99 latency %s exceeds %s", s.Operation, s.P99, max))
+		}
+	}
+}
+
+// Print the report as a table.
+func (r *Report) Print(out io.Writer) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Simulated %d servers in %s\n\n", r.Servers, r.Duration.Round(time.Millisecond))
+	fmt.Fprintln(w, strings.Join([]string{"OPERATION", "REQUESTS", "ERRORS", "P50", "P95", "P99", "MAX"}, "\t"))
+
+	for _, s := range r.Operations {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%s\t%s\t%s\n", s.Operation, s.Count, s.Errors,
+			s.P50.Round(time.Microsecond), s.P95.Round(time.Microsecond), s.P99.Round(time.Microsecond), s.Max.Round(time.Microsecond))
+	}
+
+	fmt.Fprintln(w)
+
+	if r.Passed() {
+		fmt.Fprintln(w, "PASS")
+	} else {
+		fmt.Fprintln(w, "FAIL")
+
+		for _, v := range r.Violations {
+			fmt.Fprintf(w, "  %s\n", v)
+		}
+	}
+
+	return w.Flush()
+}
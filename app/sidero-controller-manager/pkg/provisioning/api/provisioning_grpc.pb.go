@@ -0,0 +1,128 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package api
+
+import (
+	context "context"
+
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+// ProvisioningClient is the client API for Provisioning service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ProvisioningClient interface {
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Provisioning_WatchClient, error)
+}
+
+type provisioningClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewProvisioningClient(cc grpc.ClientConnInterface) ProvisioningClient {
+	return &provisioningClient{cc}
+}
+
+func (c *provisioningClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (Provisioning_WatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Provisioning_ServiceDesc.Streams[0], "/provisioning.Provisioning/Watch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &provisioningWatchClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Provisioning_WatchClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type provisioningWatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *provisioningWatchClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ProvisioningServer is the server API for Provisioning service.
+// All implementations must embed UnimplementedProvisioningServer
+// for forward compatibility
+type ProvisioningServer interface {
+	Watch(*WatchRequest, Provisioning_WatchServer) error
+	mustEmbedUnimplementedProvisioningServer()
+}
+
+// UnimplementedProvisioningServer must be embedded to have forward compatible implementations.
+type UnimplementedProvisioningServer struct{}
+
+func (UnimplementedProvisioningServer) Watch(*WatchRequest, Provisioning_WatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedProvisioningServer) mustEmbedUnimplementedProvisioningServer() {}
+
+// UnsafeProvisioningServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ProvisioningServer will
+// result in compilation errors.
+type UnsafeProvisioningServer interface {
+	mustEmbedUnimplementedProvisioningServer()
+}
+
+func RegisterProvisioningServer(s grpc.ServiceRegistrar, srv ProvisioningServer) {
+	s.RegisterService(&Provisioning_ServiceDesc, srv)
+}
+
+func _Provisioning_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ProvisioningServer).Watch(m, &provisioningWatchServer{stream})
+}
+
+type Provisioning_WatchServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type provisioningWatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *provisioningWatchServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Provisioning_ServiceDesc is the grpc.ServiceDesc for Provisioning service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Provisioning_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "provisioning.Provisioning",
+	HandlerType: (*ProvisioningServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Watch",
+			Handler:       _Provisioning_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "provisioning.proto",
+}
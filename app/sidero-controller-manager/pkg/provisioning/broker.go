@@ -0,0 +1,175 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package provisioning
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/provisioning/api"
+)
+
+// DefaultHistorySize is the default number of the events kept in memory to resume the watches.
+const DefaultHistorySize = 1024
+
+// subscriberBuffer is the number of the events buffered for each subscriber.
+const subscriberBuffer = 256
+
+// ErrOutOfRange is returned when the watch can't be resumed without missing the events.
+var ErrOutOfRange = errors.New("events since the sequence are not available")
+
+// Subscription delivers the events to a subscriber.
+type Subscription struct {
+	// Events are closed when the subscription is cancelled, or when the subscriber falls behind.
+	Events <-chan *api.Event
+
+	broker *Broker
+	ch     chan *api.Event
+}
+
+// Cancel the subscription.
+func (sub *Subscription) Cancel() {
+	sub.broker.unsubscribe(sub)
+}
+
+// Broker fans out the events to the subscribers, and keeps the recent events to resume the watches.
+//
+// Sequence numbers are assigned within the epoch, a random ID of the broker: the epoch changes when Sidero restarts
+// or another replica is elected as the leader, and the watches of another epoch can't be resumed.
+//
+// Broker implements manager.Runnable, it's started on the elected leader only.
+type Broker struct {
+	mu          sync.Mutex
+	epoch       string
+	leading     bool
+	sequence    uint64
+	history     []*api.Event
+	historySize int
+	subscribers map[*Subscription]struct{}
+}
+
+// NewBroker initializes the broker.
+func NewBroker(historySize int) *Broker {
+	if historySize <= 0 {
+		historySize = DefaultHistorySize
+	}
+
+	var epoch [8]byte
+
+	rand.Read(epoch[:]) //nolint:errcheck
+
+	return &Broker{
+		epoch:       hex.EncodeToString(epoch[:]),
+		historySize: historySize,
+		subscribers: map[*Subscription]struct{}{},
+	}
+}
+
+// Epoch returns the epoch of the events.
+func (b *Broker) Epoch() string {
+	return b.epoch
+}
+
+// Start implements manager.Runnable.
+func (b *Broker) Start(stop <-chan struct{}) error {
+	b.mu.Lock()
+	b.leading = true
+	b.mu.Unlock()
+
+	<-stop
+
+	b.mu.Lock()
+	b.leading = false
+	b.mu.Unlock()
+
+	return nil
+}
+
+// Leading returns true if the broker is started, i.e. it runs on the elected leader which publishes the events.
+func (b *Broker) Leading() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.leading
+}
+
+// Publish the events, the epoch, sequence and timestamp are assigned by the broker.
+//
+// Publish doesn't block: subscribers which fall behind are disconnected, and they can resume the watch with the last seen sequence.
+func (b *Broker) Publish(now time.Time, events ...*api.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, event := range events {
+		b.sequence++
+
+		event.Epoch = b.epoch
+		event.Sequence = b.sequence
+		event.Timestamp = now.Unix()
+
+		b.history = append(b.history, event)
+
+		if len(b.history) > b.historySize {
+			b.history = b.history[len(b.history)-b.historySize:]
+		}
+
+		for sub := range b.subscribers {
+			select {
+			case sub.ch <- event:
+			default:
+				delete(b.subscribers, sub)
+				close(sub.ch)
+			}
+		}
+	}
+}
+
+// Subscribe to the events.
+//
+// Events kept in memory with the sequence greater than since are returned to be replayed, if since is non-zero.
+// ErrOutOfRange is returned if since is of another epoch, or the events after it are no longer kept in memory.
+func (b *Broker) Subscribe(sinceEpoch string, since uint64) ([]*api.Event, *Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var replay []*api.Event
+
+	if since > 0 {
+		if sinceEpoch != b.epoch || since > b.sequence || (len(b.history) > 0 && b.history[0].Sequence > since+1) {
+			return nil, nil, ErrOutOfRange
+		}
+
+		for _, event := range b.history {
+			if event.Sequence > since {
+				replay = append(replay, event)
+			}
+		}
+	}
+
+	ch := make(chan *api.Event, subscriberBuffer)
+
+	sub := &Subscription{
+		Events: ch,
+		broker: b,
+		ch:     ch,
+	}
+
+	b.subscribers[sub] = struct{}{}
+
+	return replay, sub, nil
+}
+
+func (b *Broker) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.ch)
+	}
+}
@@ -0,0 +1,116 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package provisioning streams the provisioning state transitions of the servers to the external systems
+// (e.g. CMDB or billing) over gRPC, so that they don't have to poll the Kubernetes API.
+package provisioning
+
+import (
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/provisioning/api"
+)
+
+// State is the provisioning state of the server.
+type State struct {
+	Accepted  bool
+	Allocated bool
+	Installed bool
+
+	Hostname              string
+	SerialNumber          string
+	Cluster               string
+	MetalMachineNamespace string
+	MetalMachineName      string
+	ServerClass           string
+	Environment           string
+}
+
+// NextState returns the provisioning state of the server, binding is nil if the server is not bound.
+//
+// Server stays in use for a while after the binding is removed, so the allocation details are kept from the old state.
+func NextState(old State, server *metalv1alpha1.Server, binding *infrav1.ServerBinding) State {
+	state := State{
+		Accepted:  server.Spec.Accepted,
+		Allocated: server.Status.InUse,
+		Hostname:  server.Spec.Hostname,
+	}
+
+	if server.Spec.SystemInformation != nil {
+		state.SerialNumber = server.Spec.SystemInformation.SerialNumber
+	}
+
+	if binding == nil {
+		if state.Allocated && old.Allocated {
+			state.Installed = old.Installed
+			state.Cluster = old.Cluster
+			state.MetalMachineNamespace = old.MetalMachineNamespace
+			state.MetalMachineName = old.MetalMachineName
+			state.ServerClass = old.ServerClass
+			state.Environment = old.Environment
+		}
+
+		return state
+	}
+
+	state.Allocated = true
+	state.Cluster = binding.Labels[clusterv1.ClusterLabelName]
+	state.MetalMachineNamespace = binding.Spec.MetalMachineRef.Namespace
+	state.MetalMachineName = binding.Spec.MetalMachineRef.Name
+
+	if binding.Spec.ServerClassRef != nil {
+		state.ServerClass = binding.Spec.ServerClassRef.Name
+	}
+
+	if binding.Status.Provenance != nil {
+		state.Installed = true
+		state.Environment = binding.Status.Provenance.Environment
+	}
+
+	return state
+}
+
+// Transitions returns the events for the state transitions of the server, in the order they happened.
+//
+// Released event carries the allocation details of the old state, as they are gone once the server is released.
+func Transitions(server string, old, new State) []*api.Event {
+	var events []*api.Event
+
+	event := func(typ api.EventType, state State) *api.Event {
+		return &api.Event{
+			Type:                  typ,
+			Server:                server,
+			Hostname:              state.Hostname,
+			SerialNumber:          state.SerialNumber,
+			Cluster:               state.Cluster,
+			MetalMachineNamespace: state.MetalMachineNamespace,
+			MetalMachineName:      state.MetalMachineName,
+			ServerClass:           state.ServerClass,
+			Environment:           state.Environment,
+		}
+	}
+
+	if new.Accepted && !old.Accepted {
+		events = append(events, event(api.EventType_ACCEPTED, new))
+	}
+
+	reallocated := old.Allocated && new.Allocated && old.MetalMachineName != "" && new.MetalMachineName != "" &&
+		(old.MetalMachineNamespace != new.MetalMachineNamespace || old.MetalMachineName != new.MetalMachineName)
+
+	if old.Allocated && (!new.Allocated || reallocated) {
+		events = append(events, event(api.EventType_RELEASED, old))
+	}
+
+	if new.Allocated && (!old.Allocated || reallocated) {
+		events = append(events, event(api.EventType_ALLOCATED, new))
+	}
+
+	if new.Installed && (!old.Installed || reallocated) {
+		events = append(events, event(api.EventType_INSTALLED, new))
+	}
+
+	return events
+}
@@ -0,0 +1,267 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package provisioning_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clusterv1 "sigs.k8s.io/cluster-api/api/v1alpha3"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/provisioning"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/provisioning/api"
+)
+
+func TestTransitions(t *testing.T) {
+	t.Parallel()
+
+	const uuid = "4c4c4544-0039-3010-8048-b7c04f384432"
+
+	newServer := func(accepted, inUse bool) *metalv1alpha1.Server {
+		return &metalv1alpha1.Server{
+			ObjectMeta: metav1.ObjectMeta{Name: uuid},
+			Spec: metalv1alpha1.ServerSpec{
+				Hostname: "node-1",
+				Accepted: accepted,
+				SystemInformation: &metalv1alpha1.SystemInformation{
+					SerialNumber: "6DR0QR2",
+				},
+			},
+			Status: metalv1alpha1.ServerStatus{InUse: inUse},
+		}
+	}
+
+	newBinding := func(metalMachine string, installed bool) *infrav1.ServerBinding {
+		binding := &infrav1.ServerBinding{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:   uuid,
+				Labels: map[string]string{clusterv1.ClusterLabelName: "prod"},
+			},
+			Spec: infrav1.ServerBindingSpec{
+				ServerClassRef:  &corev1.ObjectReference{Name: "workers"},
+				MetalMachineRef: corev1.ObjectReference{Namespace: "default", Name: metalMachine},
+			},
+		}
+
+		if installed {
+			binding.Status.Provenance = &infrav1.Provenance{Environment: "default"}
+		}
+
+		return binding
+	}
+
+	type step struct {
+		server  *metalv1alpha1.Server
+		binding *infrav1.ServerBinding
+	}
+
+	for name, tc := range map[string]struct {
+		steps    []step
+		expected []*api.Event
+	}{
+		"lifecycle": {
+			steps: []step{
+				{server: newServer(false, false)},
+				{server: newServer(true, false)},
+				{server: newServer(true, false), binding: newBinding("worker-1", false)},
+				{server: newServer(true, true), binding: newBinding("worker-1", false)},
+				{server: newServer(true, true), binding: newBinding("worker-1", true)},
+				// binding is removed before the server is marked as not in use
+				{server: newServer(true, true)},
+				{server: newServer(true, false)},
+			},
+			expected: []*api.Event{
+				{Type: api.EventType_ACCEPTED, Server: uuid, Hostname: "node-1", SerialNumber: "6DR0QR2"},
+				{
+					Type: api.EventType_ALLOCATED, Server: uuid, Hostname: "node-1", SerialNumber: "6DR0QR2",
+					Cluster: "prod", MetalMachineNamespace: "default", MetalMachineName: "worker-1", ServerClass: "workers",
+				},
+				{
+					Type: api.EventType_INSTALLED, Server: uuid, Hostname: "node-1", SerialNumber: "6DR0QR2",
+					Cluster: "prod", MetalMachineNamespace: "default", MetalMachineName: "worker-1", ServerClass: "workers", Environment: "default",
+				},
+				{
+					Type: api.EventType_RELEASED, Server: uuid, Hostname: "node-1", SerialNumber: "6DR0QR2",
+					Cluster: "prod", MetalMachineNamespace: "default", MetalMachineName: "worker-1", ServerClass: "workers", Environment: "default",
+				},
+			},
+		},
+		"reallocated": {
+			steps: []step{
+				{server: newServer(true, true), binding: newBinding("worker-1", true)},
+				{server: newServer(true, true), binding: newBinding("worker-2", false)},
+			},
+			expected: []*api.Event{
+				{Type: api.EventType_ACCEPTED, Server: uuid, Hostname: "node-1", SerialNumber: "6DR0QR2", Cluster: "prod", MetalMachineNamespace: "default", MetalMachineName: "worker-1", ServerClass: "workers", Environment: "default"},
+				{Type: api.EventType_ALLOCATED, Server: uuid, Hostname: "node-1", SerialNumber: "6DR0QR2", Cluster: "prod", MetalMachineNamespace: "default", MetalMachineName: "worker-1", ServerClass: "workers", Environment: "default"},
+				{Type: api.EventType_INSTALLED, Server: uuid, Hostname: "node-1", SerialNumber: "6DR0QR2", Cluster: "prod", MetalMachineNamespace: "default", MetalMachineName: "worker-1", ServerClass: "workers", Environment: "default"},
+				{Type: api.EventType_RELEASED, Server: uuid, Hostname: "node-1", SerialNumber: "6DR0QR2", Cluster: "prod", MetalMachineNamespace: "default", MetalMachineName: "worker-1", ServerClass: "workers", Environment: "default"},
+				{Type: api.EventType_ALLOCATED, Server: uuid, Hostname: "node-1", SerialNumber: "6DR0QR2", Cluster: "prod", MetalMachineNamespace: "default", MetalMachineName: "worker-2", ServerClass: "workers"},
+			},
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				state  provisioning.State
+				events []*api.Event
+			)
+
+			for _, step := range tc.steps {
+				next := provisioning.NextState(state, step.server, step.binding)
+				events = append(events, provisioning.Transitions(uuid, state, next)...)
+				state = next
+			}
+
+			assert.Equal(t, tc.expected, events)
+		})
+	}
+}
+
+func TestWatch(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	broker := provisioning.NewBroker(2)
+	now := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	broker.Publish(now,
+		&api.Event{Type: api.EventType_ACCEPTED, Server: "a"},
+		&api.Event{Type: api.EventType_ACCEPTED, Server: "b"},
+		&api.Event{Type: api.EventType_ALLOCATED, Server: "a"},
+	)
+
+	lis := bufconn.Listen(1024 * 1024)
+	srv := provisioning.CreateServer(broker)
+
+	go srv.Serve(lis) //nolint:errcheck
+
+	defer srv.Stop()
+
+	conn, err := grpc.DialContext(ctx, "bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return lis.Dial()
+	}))
+	require.NoError(t, err)
+
+	defer conn.Close() //nolint:errcheck
+
+	client := api.NewProvisioningClient(conn)
+
+	// events are served only once the broker is started on the leader
+	stream, err := client.Watch(ctx, &api.WatchRequest{})
+	require.NoError(t, err)
+
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go broker.Start(stop) //nolint:errcheck
+
+	require.Eventually(t, broker.Leading, 5*time.Second, 10*time.Millisecond)
+
+	// history keeps only 2 last events
+	stream, err = client.Watch(ctx, &api.WatchRequest{Server: "a", SinceEpoch: broker.Epoch(), SinceSequence: 1})
+	require.NoError(t, err)
+
+	event, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), event.GetSequence())
+	assert.Equal(t, broker.Epoch(), event.GetEpoch())
+	assert.Equal(t, api.EventType_ALLOCATED, event.GetType())
+	assert.Equal(t, now.Unix(), event.GetTimestamp())
+
+	// the subscription is registered asynchronously, so the event is received either replayed or live
+	filtered, err := client.Watch(ctx, &api.WatchRequest{Types: []api.EventType{api.EventType_RELEASED}, SinceEpoch: broker.Epoch(), SinceSequence: 3})
+	require.NoError(t, err)
+
+	broker.Publish(now, &api.Event{Type: api.EventType_INSTALLED, Server: "b"})
+	broker.Publish(now, &api.Event{Type: api.EventType_RELEASED, Server: "a"})
+
+	event, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, api.EventType_RELEASED, event.GetType())
+	assert.Equal(t, uint64(5), event.GetSequence())
+
+	event, err = filtered.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, api.EventType_RELEASED, event.GetType())
+	assert.Equal(t, "a", event.GetServer())
+
+	for name, req := range map[string]*api.WatchRequest{
+		"evicted":       {SinceEpoch: broker.Epoch(), SinceSequence: 2},
+		"future":        {SinceEpoch: broker.Epoch(), SinceSequence: 6},
+		"another epoch": {SinceEpoch: "0123456789abcdef", SinceSequence: 5},
+		"no epoch":      {SinceSequence: 5},
+	} {
+		stream, err = client.Watch(ctx, req)
+		require.NoError(t, err)
+
+		_, err = stream.Recv()
+		assert.Equal(t, codes.OutOfRange, status.Code(err), name)
+	}
+}
+
+func TestBrokerEpoch(t *testing.T) {
+	t.Parallel()
+
+	a, b := provisioning.NewBroker(0), provisioning.NewBroker(0)
+
+	assert.NotEmpty(t, a.Epoch())
+	assert.NotEqual(t, a.Epoch(), b.Epoch())
+
+	_, _, err := a.Subscribe(b.Epoch(), 1)
+	assert.ErrorIs(t, err, provisioning.ErrOutOfRange)
+
+	a.Publish(time.Now(), &api.Event{Type: api.EventType_ACCEPTED})
+
+	replay, sub, err := a.Subscribe(a.Epoch(), 1)
+	require.NoError(t, err)
+	assert.Empty(t, replay)
+
+	sub.Cancel()
+}
+
+func TestBrokerSlowSubscriber(t *testing.T) {
+	t.Parallel()
+
+	broker := provisioning.NewBroker(0)
+
+	_, sub, err := broker.Subscribe("", 0)
+	require.NoError(t, err)
+
+	for i := 0; i < 1000; i++ {
+		broker.Publish(time.Now(), &api.Event{Type: api.EventType_ACCEPTED})
+	}
+
+	var received int
+
+	for range sub.Events {
+		received++
+	}
+
+	assert.Less(t, received, 1000, "slow subscriber is disconnected")
+
+	// cancelling disconnected subscription is a no-op
+	sub.Cancel()
+}
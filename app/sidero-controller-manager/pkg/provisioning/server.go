@@ -0,0 +1,96 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package provisioning
+
+import (
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/provisioning/api"
+)
+
+type server struct {
+	api.UnimplementedProvisioningServer
+
+	broker *Broker
+}
+
+// CreateServer creates the Provisioning gRPC API server streaming the events published to the broker.
+//
+// The server has no TLS or authentication, and the events are streamed only while the broker runs on the elected leader.
+func CreateServer(broker *Broker) *grpc.Server {
+	s := grpc.NewServer()
+
+	api.RegisterProvisioningServer(s, &server{
+		broker: broker,
+	})
+
+	return s
+}
+
+// Watch implements api.ProvisioningServer.
+func (s *server) Watch(in *api.WatchRequest, stream api.Provisioning_WatchServer) error {
+	types := map[api.EventType]struct{}{}
+
+	for _, typ := range in.GetTypes() {
+		types[typ] = struct{}{}
+	}
+
+	matches := func(event *api.Event) bool {
+		if in.GetServer() != "" && event.GetServer() != in.GetServer() {
+			return false
+		}
+
+		if len(types) == 0 {
+			return true
+		}
+
+		_, ok := types[event.GetType()]
+
+		return ok
+	}
+
+	if !s.broker.Leading() {
+		return status.Error(codes.Unavailable, "events are served by the elected leader only")
+	}
+
+	replay, sub, err := s.broker.Subscribe(in.GetSinceEpoch(), in.GetSinceSequence())
+	if err != nil {
+		return status.Errorf(codes.OutOfRange, "watch can't be resumed from sequence %d of epoch %q (current epoch %q): reconcile the state and watch from the start",
+			in.GetSinceSequence(), in.GetSinceEpoch(), s.broker.Epoch())
+	}
+
+	defer sub.Cancel()
+
+	for _, event := range replay {
+		if !matches(event) {
+			continue
+		}
+
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return stream.Context().Err()
+		case event, ok := <-sub.Events:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watch fell behind, resume with the last received sequence")
+			}
+
+			if !matches(event) {
+				continue
+			}
+
+			if err := stream.Send(event); err != nil {
+				return err
+			}
+		}
+	}
+}
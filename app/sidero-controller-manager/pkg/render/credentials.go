@@ -0,0 +1,74 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package render
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/talos-systems/crypto/x509"
+	"github.com/talos-systems/talos/pkg/machinery/config/configloader"
+	"github.com/talos-systems/talos/pkg/machinery/config/types/v1alpha1"
+)
+
+// CredentialsFingerprint returns the checksum of the credentials in the machine configuration:
+// machine and cluster CAs, tokens, encryption and service account keys.
+//
+// Fingerprint changes when the cluster credentials are rotated, and it doesn't depend on the rest of the configuration.
+func CredentialsFingerprint(decodedData []byte) (string, error) {
+	configProvider, err := configloader.NewFromBytes(decodedData)
+	if err != nil {
+		return "", fmt.Errorf("failure creating config struct: %s", err)
+	}
+
+	switch configProvider.Version() {
+	case "v1alpha1":
+		config, ok := configProvider.(*v1alpha1.Config)
+		if !ok {
+			return "", fmt.Errorf("unable to case config")
+		}
+
+		h := sha256.New()
+
+		add := func(name string, value []byte) {
+			fmt.Fprintf(h, "%s:%d:", name, len(value))
+			h.Write(value) //nolint:errcheck
+		}
+
+		addCertificateAndKey := func(name string, pem *x509.PEMEncodedCertificateAndKey) {
+			if pem == nil {
+				pem = &x509.PEMEncodedCertificateAndKey{}
+			}
+
+			add(name+".crt", pem.Crt)
+			add(name+".key", pem.Key)
+		}
+
+		if machine := config.MachineConfig; machine != nil {
+			add("machine.token", []byte(machine.MachineToken))
+			addCertificateAndKey("machine.ca", machine.MachineCA)
+		}
+
+		if cluster := config.ClusterConfig; cluster != nil {
+			add("cluster.token", []byte(cluster.BootstrapToken))
+			add("cluster.aescbcEncryptionSecret", []byte(cluster.ClusterAESCBCEncryptionSecret))
+			addCertificateAndKey("cluster.ca", cluster.ClusterCA)
+			addCertificateAndKey("cluster.aggregatorCA", cluster.ClusterAggregatorCA)
+
+			if cluster.ClusterServiceAccount != nil {
+				add("cluster.serviceAccount.key", cluster.ClusterServiceAccount.Key)
+			}
+
+			if cluster.EtcdConfig != nil {
+				addCertificateAndKey("cluster.etcd.ca", cluster.EtcdConfig.RootCA)
+			}
+		}
+
+		return hex.EncodeToString(h.Sum(nil)), nil
+	default:
+		return "", fmt.Errorf("unknown config type")
+	}
+}
@@ -0,0 +1,235 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// Names of the files in the golden test case directory.
+const (
+	ServerFile        = "server.yaml"
+	ServerClassFile   = "serverclass.yaml"
+	ServerBindingFile = "serverbinding.yaml"
+	MetalMachineFile  = "metalmachine.yaml"
+	EnvironmentFile   = "environment.yaml"
+	SourcesFile       = "sources.yaml"
+	ConfigFile        = "config.yaml"
+	ExpectedFile      = "expected.yaml"
+)
+
+// Files is the set of manifest files to load the input from, empty file names are skipped.
+type Files struct {
+	Server        string
+	ServerClass   string
+	ServerBinding string
+	MetalMachine  string
+	Environment   string
+	// Sources is a multi-document YAML with the ConfigMaps and Secrets referenced by config patches.
+	Sources string
+	Config  string
+}
+
+// Decode strictly decodes YAML manifest of the expected kind.
+//
+// Unknown fields are rejected, as they are most likely typos which would be silently dropped by the API server.
+func Decode(data []byte, kind string, obj runtime.Object) error {
+	return decodeAs(data, metalv1alpha1.GroupVersion.WithKind(kind), obj)
+}
+
+func decodeAs(data []byte, expected schema.GroupVersionKind, obj runtime.Object) error {
+	kind := expected.Kind
+
+	jsonData, err := yaml.YAMLToJSON(data)
+	if err != nil {
+		return err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(jsonData))
+	decoder.DisallowUnknownFields()
+
+	if err = decoder.Decode(obj); err != nil {
+		return fmt.Errorf("error decoding %s: %w", kind, err)
+	}
+
+	gvk := obj.GetObjectKind().GroupVersionKind()
+
+	if gvk != expected {
+		return fmt.Errorf("expected %s %s, got %s", expected.GroupVersion(), kind, gvk)
+	}
+
+	return nil
+}
+
+// LoadInput reads the input from the set of files.
+func LoadInput(files Files) (Input, error) {
+	var in Input
+
+	if files.Server == "" {
+		return in, fmt.Errorf("server manifest is required")
+	}
+
+	data, err := os.ReadFile(files.Server)
+	if err != nil {
+		return in, err
+	}
+
+	in.Server = &metalv1alpha1.Server{}
+
+	if err = Decode(data, "Server", in.Server); err != nil {
+		return in, fmt.Errorf("%s: %w", files.Server, err)
+	}
+
+	if files.ServerClass != "" {
+		if data, err = os.ReadFile(files.ServerClass); err != nil {
+			return in, err
+		}
+
+		in.ServerClass = &metalv1alpha1.ServerClass{}
+
+		if err = Decode(data, "ServerClass", in.ServerClass); err != nil {
+			return in, fmt.Errorf("%s: %w", files.ServerClass, err)
+		}
+	}
+
+	if files.ServerBinding != "" {
+		if data, err = os.ReadFile(files.ServerBinding); err != nil {
+			return in, err
+		}
+
+		in.ServerBinding = &infrav1.ServerBinding{}
+
+		if err = decodeAs(data, infrav1.GroupVersion.WithKind("ServerBinding"), in.ServerBinding); err != nil {
+			return in, fmt.Errorf("%s: %w", files.ServerBinding, err)
+		}
+	}
+
+	if files.MetalMachine != "" {
+		if data, err = os.ReadFile(files.MetalMachine); err != nil {
+			return in, err
+		}
+
+		in.MetalMachine = &infrav1.MetalMachine{}
+
+		if err = decodeAs(data, infrav1.GroupVersion.WithKind("MetalMachine"), in.MetalMachine); err != nil {
+			return in, fmt.Errorf("%s: %w", files.MetalMachine, err)
+		}
+	}
+
+	if files.Environment != "" {
+		if data, err = os.ReadFile(files.Environment); err != nil {
+			return in, err
+		}
+
+		env := &metalv1alpha1.Environment{}
+
+		if err = Decode(data, "Environment", env); err != nil {
+			return in, fmt.Errorf("%s: %w", files.Environment, err)
+		}
+
+		in.Environments = append(in.Environments, env)
+	}
+
+	if files.Sources != "" {
+		if data, err = os.ReadFile(files.Sources); err != nil {
+			return in, err
+		}
+
+		var sources *ObjectsSource
+
+		if sources, err = decodeSources(data); err != nil {
+			return in, fmt.Errorf("%s: %w", files.Sources, err)
+		}
+
+		in.Sources = sources
+	}
+
+	if files.Config != "" {
+		if in.Config, err = os.ReadFile(files.Config); err != nil {
+			return in, err
+		}
+	}
+
+	return in, nil
+}
+
+// decodeSources decodes multi-document YAML with ConfigMaps and Secrets.
+func decodeSources(data []byte) (*ObjectsSource, error) {
+	sources := &ObjectsSource{}
+
+	for _, doc := range bytes.Split(data, []byte("\n---")) {
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		var meta struct {
+			Kind string `json:"kind"`
+		}
+
+		if err := yaml.Unmarshal(doc, &meta); err != nil {
+			return nil, err
+		}
+
+		switch meta.Kind {
+		case "ConfigMap":
+			cm := &corev1.ConfigMap{}
+
+			if err := decodeAs(doc, corev1.SchemeGroupVersion.WithKind(meta.Kind), cm); err != nil {
+				return nil, err
+			}
+
+			sources.ConfigMaps = append(sources.ConfigMaps, cm)
+		case "Secret":
+			secret := &corev1.Secret{}
+
+			if err := decodeAs(doc, corev1.SchemeGroupVersion.WithKind(meta.Kind), secret); err != nil {
+				return nil, err
+			}
+
+			sources.Secrets = append(sources.Secrets, secret)
+		default:
+			return nil, fmt.Errorf("expected ConfigMap or Secret, got %q", meta.Kind)
+		}
+	}
+
+	return sources, nil
+}
+
+// LoadCase reads the input from the golden test case directory.
+//
+// Only the server manifest is required, other files are optional.
+func LoadCase(dir string) (Input, error) {
+	optional := func(name string) string {
+		path := filepath.Join(dir, name)
+
+		if _, err := os.Stat(path); err != nil {
+			return ""
+		}
+
+		return path
+	}
+
+	return LoadInput(Files{
+		Server:        filepath.Join(dir, ServerFile),
+		ServerClass:   optional(ServerClassFile),
+		ServerBinding: optional(ServerBindingFile),
+		MetalMachine:  optional(MetalMachineFile),
+		Environment:   optional(EnvironmentFile),
+		Sources:       optional(SourcesFile),
+		Config:        optional(ConfigFile),
+	})
+}
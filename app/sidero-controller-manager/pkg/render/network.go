@@ -0,0 +1,125 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package render
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/ghodss/yaml"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+)
+
+// StaticNetwork configures the interfaces with the static addresses claimed for the MetalMachine.
+//
+// Existing interface settings are preserved, DHCP is disabled and the default route via the gateway is added.
+func StaticNetwork(decodedData []byte, metalMachine *infrav1.MetalMachine) ([]byte, error) {
+	if metalMachine.Spec.Network == nil {
+		return decodedData, nil
+	}
+
+	if !metalMachine.StaticAddressesAssigned() {
+		return nil, fmt.Errorf("static addresses are not assigned yet for metalmachine %s/%s", metalMachine.Namespace, metalMachine.Name)
+	}
+
+	var config map[string]interface{}
+
+	if err := yaml.Unmarshal(decodedData, &config); err != nil {
+		return nil, fmt.Errorf("failure decoding machine config: %s", err)
+	}
+
+	if config == nil {
+		config = map[string]interface{}{}
+	}
+
+	network := childMap(childMap(config, "machine"), "network")
+
+	var interfaces []interface{}
+
+	if existing, ok := network["interfaces"].([]interface{}); ok {
+		interfaces = existing
+	}
+
+	for _, address := range metalMachine.Status.StaticAddresses {
+		ip := net.ParseIP(address.Address)
+		if ip == nil {
+			return nil, fmt.Errorf("interface %q: invalid address %q", address.Interface, address.Address)
+		}
+
+		var device map[string]interface{}
+
+		for _, iface := range interfaces {
+			if d, ok := iface.(map[string]interface{}); ok && d["interface"] == address.Interface {
+				device = d
+
+				break
+			}
+		}
+
+		if device == nil {
+			device = map[string]interface{}{
+				"interface": address.Interface,
+			}
+
+			interfaces = append(interfaces, device)
+		}
+
+		device["cidr"] = fmt.Sprintf("%s/%d", ip, address.Prefix)
+		device["dhcp"] = false
+
+		if address.Gateway != "" {
+			defaultNetwork := "0.0.0.0/0"
+			if ip.To4() == nil {
+				defaultNetwork = "::/0"
+			}
+
+			var routes []interface{}
+
+			for _, route := range asList(device["routes"]) {
+				if r, ok := route.(map[string]interface{}); ok && r["network"] == defaultNetwork {
+					continue
+				}
+
+				routes = append(routes, route)
+			}
+
+			device["routes"] = append(routes, map[string]interface{}{
+				"network": defaultNetwork,
+				"gateway": address.Gateway,
+			})
+		}
+	}
+
+	network["interfaces"] = interfaces
+
+	if len(metalMachine.Spec.Network.Nameservers) > 0 {
+		network["nameservers"] = metalMachine.Spec.Network.Nameservers
+	}
+
+	decodedData, err := yaml.Marshal(config)
+	if err != nil {
+		return nil, fmt.Errorf("failure encoding machine config: %s", err)
+	}
+
+	return decodedData, nil
+}
+
+func childMap(parent map[string]interface{}, key string) map[string]interface{} {
+	if child, ok := parent[key].(map[string]interface{}); ok {
+		return child
+	}
+
+	child := map[string]interface{}{}
+	parent[key] = child
+
+	return child
+}
+
+func asList(v interface{}) []interface{} {
+	list, _ := v.([]interface{})
+
+	return list
+}
@@ -0,0 +1,316 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package render
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	apiextensions "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// Layer is a set of config patches coming from a single resource.
+type Layer struct {
+	// Name of the resource, e.g. `serverclass "xeon"`.
+	Name        string
+	Patches     []metalv1alpha1.ConfigPatches
+	PatchesFrom []metalv1alpha1.ConfigPatchesRef
+}
+
+// Layers returns the patch layers in the merge order: Environment, ServerClass, MetalMachine install disk, Server, ServerBinding,
+// pinned install image.
+//
+// Install image is applied last, so that the image verified by the metadata server is not overridden by the patches.
+//
+// Any of the resources might be nil.
+func Layers(env *metalv1alpha1.Environment, serverClass *metalv1alpha1.ServerClass, server *metalv1alpha1.Server, serverBinding *infrav1.ServerBinding) []Layer {
+	var layers []Layer
+
+	if env != nil {
+		layers = append(layers, Layer{fmt.Sprintf("environment %q", env.Name), env.Spec.ConfigPatches, env.Spec.ConfigPatchesFrom})
+	}
+
+	if serverClass != nil {
+		layers = append(layers, Layer{fmt.Sprintf("serverclass %q", serverClass.Name), serverClass.Spec.ConfigPatches, serverClass.Spec.ConfigPatchesFrom})
+	}
+
+	if serverBinding != nil && serverBinding.Spec.InstallDisk != "" {
+		layers = append(layers, Layer{fmt.Sprintf("metalmachine %q install disk", serverBinding.Spec.MetalMachineRef.Name), InstallDiskPatches(serverBinding.Spec.InstallDisk), nil})
+	}
+
+	if server != nil {
+		layers = append(layers, Layer{fmt.Sprintf("server %q", server.Name), server.Spec.ConfigPatches, server.Spec.ConfigPatchesFrom})
+	}
+
+	if serverBinding != nil {
+		layers = append(layers, Layer{fmt.Sprintf("serverbinding %q", serverBinding.Name), serverBinding.Spec.ConfigPatches, serverBinding.Spec.ConfigPatchesFrom})
+	}
+
+	if installImage := InstallImageOverride(serverClass, server); installImage != nil {
+		layers = append(layers, Layer{fmt.Sprintf("install image %q", installImage.Reference()), InstallImagePatches(installImage.Reference()), nil})
+	}
+
+	return layers
+}
+
+// InstallImageOverride returns the pinned install image of the server, falling back to the server class.
+//
+// Any of the resources might be nil.
+func InstallImageOverride(serverClass *metalv1alpha1.ServerClass, server *metalv1alpha1.Server) *metalv1alpha1.InstallImage {
+	if server != nil && server.Spec.InstallImage != nil {
+		return server.Spec.InstallImage
+	}
+
+	if serverClass != nil {
+		return serverClass.Spec.InstallImage
+	}
+
+	return nil
+}
+
+// InstallDiskPatches returns the config patches setting the install disk.
+func InstallDiskPatches(disk string) []metalv1alpha1.ConfigPatches {
+	value, _ := json.Marshal(disk) //nolint:errcheck
+
+	return []metalv1alpha1.ConfigPatches{
+		{
+			Op:    "add",
+			Path:  "/machine/install/disk",
+			Value: apiextensions.JSON{Raw: value},
+		},
+	}
+}
+
+// InstallImagePatches returns the config patches setting the install image.
+func InstallImagePatches(image string) []metalv1alpha1.ConfigPatches {
+	value, _ := json.Marshal(image) //nolint:errcheck
+
+	return []metalv1alpha1.ConfigPatches{
+		{
+			Op:    "add",
+			Path:  "/machine/install/image",
+			Value: apiextensions.JSON{Raw: value},
+		},
+	}
+}
+
+// PatchSource resolves the config patches references.
+type PatchSource interface {
+	// Fetch returns the data of the referenced key.
+	Fetch(ref metalv1alpha1.ConfigPatchesRef) ([]byte, error)
+}
+
+// PatchSourceFunc is a function adapter for PatchSource.
+type PatchSourceFunc func(ref metalv1alpha1.ConfigPatchesRef) ([]byte, error)
+
+// Fetch implements PatchSource.
+func (f PatchSourceFunc) Fetch(ref metalv1alpha1.ConfigPatchesRef) ([]byte, error) {
+	return f(ref)
+}
+
+// RefNamespace returns the namespace of the reference, defaulting to `default`.
+func RefNamespace(ref metalv1alpha1.ConfigPatchesRef) string {
+	return namespaceOrDefault(ref.Namespace)
+}
+
+// RefNamespaces is the set of namespaces the ConfigMaps and Secrets can be referenced from.
+//
+// References are resolved with the Sidero credentials and served by the unauthenticated metadata server,
+// so the unrestricted references would expose any Secret of the cluster to the user who can edit a Server or an Environment.
+type RefNamespaces map[string]struct{}
+
+// ParseRefNamespaces parses a comma delimited list of namespaces.
+func ParseRefNamespaces(s string) (RefNamespaces, error) {
+	namespaces := RefNamespaces{}
+
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			namespaces[item] = struct{}{}
+		}
+	}
+
+	if len(namespaces) == 0 {
+		return nil, errors.New("no namespaces specified")
+	}
+
+	return namespaces, nil
+}
+
+// Allowed checks whether the objects can be referenced from the namespace.
+func (namespaces RefNamespaces) Allowed(namespace string) bool {
+	_, ok := namespaces[namespaceOrDefault(namespace)]
+
+	return ok
+}
+
+// Check returns an error if the reference points to the namespace which is not allowed.
+func (namespaces RefNamespaces) Check(ref metalv1alpha1.ConfigPatchesRef) error {
+	if !namespaces.Allowed(RefNamespace(ref)) {
+		return fmt.Errorf("%s %s/%s: references to namespace %q are not allowed", ref.Kind, RefNamespace(ref), ref.Name, RefNamespace(ref))
+	}
+
+	return nil
+}
+
+func namespaceOrDefault(namespace string) string {
+	if namespace == "" {
+		return corev1.NamespaceDefault
+	}
+
+	return namespace
+}
+
+// ObjectsSource resolves references against the set of ConfigMaps and Secrets.
+type ObjectsSource struct {
+	ConfigMaps []*corev1.ConfigMap
+	Secrets    []*corev1.Secret
+}
+
+// Fetch implements PatchSource.
+func (s *ObjectsSource) Fetch(ref metalv1alpha1.ConfigPatchesRef) ([]byte, error) {
+	namespace := RefNamespace(ref)
+
+	switch ref.Kind {
+	case "ConfigMap":
+		for _, cm := range s.ConfigMaps {
+			if cm.Name == ref.Name && namespaceOrDefault(cm.Namespace) == namespace {
+				return ConfigMapKey(cm, ref.Key)
+			}
+		}
+	case "Secret":
+		for _, secret := range s.Secrets {
+			if secret.Name == ref.Name && namespaceOrDefault(secret.Namespace) == namespace {
+				return SecretKey(secret, ref.Key)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported kind %q", ref.Kind)
+	}
+
+	return nil, fmt.Errorf("%s %s/%s is not found", ref.Kind, namespace, ref.Name)
+}
+
+// ConfigMapKey returns the value of the key in the ConfigMap.
+func ConfigMapKey(cm *corev1.ConfigMap, key string) ([]byte, error) {
+	if data, ok := cm.Data[key]; ok {
+		return []byte(data), nil
+	}
+
+	if data, ok := cm.BinaryData[key]; ok {
+		return data, nil
+	}
+
+	return nil, fmt.Errorf("key %q is not found in ConfigMap %s/%s", key, cm.Namespace, cm.Name)
+}
+
+// SecretKey returns the value of the key in the Secret.
+//
+// StringData is checked as well, as it is only converted to Data by the API server.
+func SecretKey(secret *corev1.Secret, key string) ([]byte, error) {
+	if data, ok := secret.Data[key]; ok {
+		return data, nil
+	}
+
+	if data, ok := secret.StringData[key]; ok {
+		return []byte(data), nil
+	}
+
+	return nil, fmt.Errorf("key %q is not found in Secret %s/%s", key, secret.Namespace, secret.Name)
+}
+
+// ApplyLayers applies the layers to the machine config in order.
+//
+// Within a layer inline patches are applied first, then referenced patches in the listed order.
+func ApplyLayers(config []byte, layers []Layer, source PatchSource) ([]byte, error) {
+	var err error
+
+	for _, layer := range layers {
+		if len(layer.Patches) > 0 {
+			if config, err = PatchConfig(config, layer.Patches); err != nil {
+				return nil, fmt.Errorf("%s: %w", layer.Name, err)
+			}
+		}
+
+		for _, ref := range layer.PatchesFrom {
+			if config, err = applyRef(config, ref, source); err != nil {
+				return nil, fmt.Errorf("%s: %s %s/%s key %q: %w", layer.Name, ref.Kind, RefNamespace(ref), ref.Name, ref.Key, err)
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// ValidateLayers checks that the inline patches are well-formed.
+func ValidateLayers(layers []Layer) error {
+	for _, layer := range layers {
+		if _, err := decodePatch(layer.Patches); err != nil {
+			return fmt.Errorf("%s: %w", layer.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func applyRef(config []byte, ref metalv1alpha1.ConfigPatchesRef, source PatchSource) ([]byte, error) {
+	if source == nil {
+		return nil, fmt.Errorf("config patches references can't be resolved")
+	}
+
+	data, err := source.Fetch(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ref.Type {
+	case metalv1alpha1.ConfigPatchTypeJSON6902, "":
+		var patches []metalv1alpha1.ConfigPatches
+
+		if err = yaml.Unmarshal(data, &patches); err != nil {
+			return nil, fmt.Errorf("failure decoding rfc6902 patches: %s", err)
+		}
+
+		return PatchConfig(config, patches)
+	case metalv1alpha1.ConfigPatchTypeMerge:
+		return MergeConfig(config, data)
+	default:
+		return nil, fmt.Errorf("unsupported patch type %q", ref.Type)
+	}
+}
+
+// MergeConfig merges the partial machine config (YAML) into the machine config.
+//
+// Merge follows RFC7386: maps are merged recursively, lists are replaced, `null` removes the key.
+func MergeConfig(decodedData, partial []byte) ([]byte, error) {
+	jsonDecodedData, err := yaml.YAMLToJSON(decodedData)
+	if err != nil {
+		return nil, fmt.Errorf("failure converting bootstrap data to json: %s", err)
+	}
+
+	jsonPatch, err := yaml.YAMLToJSON(partial)
+	if err != nil {
+		return nil, fmt.Errorf("failure converting merge patch to json: %s", err)
+	}
+
+	jsonDecodedData, err = jsonpatch.MergePatch(jsonDecodedData, jsonPatch)
+	if err != nil {
+		return nil, fmt.Errorf("failure applying merge patch to machine config: %s", err)
+	}
+
+	decodedData, err = yaml.JSONToYAML(jsonDecodedData)
+	if err != nil {
+		return nil, fmt.Errorf("failure converting bootstrap data from json to yaml: %s", err)
+	}
+
+	return decodedData, nil
+}
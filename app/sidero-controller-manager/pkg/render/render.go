@@ -0,0 +1,321 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package render implements rendering of the machine configuration and boot environment for a server.
+//
+// The same code is used by the metadata server, so rendering the manifests offline
+// shows what the server is going to receive.
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/ghodss/yaml"
+	"github.com/talos-systems/talos/pkg/machinery/config/configloader"
+	"github.com/talos-systems/talos/pkg/machinery/config/types/v1alpha1"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// Input is the set of resources to render the configuration from.
+type Input struct {
+	// Server is required.
+	Server *metalv1alpha1.Server
+	// ServerClass the server is allocated from, optional.
+	ServerClass *metalv1alpha1.ServerClass
+	// ServerBinding of the allocated server, optional.
+	ServerBinding *infrav1.ServerBinding
+	// MetalMachine the server is allocated to, optional.
+	//
+	// Static addresses from the status are configured in the machine config.
+	MetalMachine *infrav1.MetalMachine
+	// Environments available for the server, optional.
+	Environments []*metalv1alpha1.Environment
+	// Sources resolves ConfigMap and Secret config patches references, optional.
+	Sources PatchSource
+	// Config is the bootstrap machine configuration, optional.
+	//
+	// If not set, only boot environment is rendered.
+	Config []byte
+}
+
+// Boot describes the environment the server is going to be booted with.
+type Boot struct {
+	Server      string                `json:"server"`
+	ServerClass string                `json:"serverClass,omitempty"`
+	Environment string                `json:"environment"`
+	Kernel      *metalv1alpha1.Kernel `json:"kernel,omitempty"`
+	Initrd      *metalv1alpha1.Initrd `json:"initrd,omitempty"`
+	// Cmdline is the effective kernel command line the server is booted with.
+	Cmdline  string   `json:"cmdline,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// Output is the rendering result.
+type Output struct {
+	Boot   Boot
+	Config []byte
+}
+
+// Bytes returns the output as a multi-document YAML.
+func (o *Output) Bytes() ([]byte, error) {
+	boot, err := yaml.Marshal(o.Boot)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	buf.Write(boot)
+
+	if o.Config != nil {
+		buf.WriteString("---\n")
+		buf.Write(o.Config)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Render the boot environment and the machine configuration for the server.
+//
+// Precedence and order of patches match the iPXE and metadata servers:
+// environment is picked from the server, then from the MetalMachine (server binding), then from the server class,
+// then the default one; static addresses of the MetalMachine are configured first, then patches are applied
+// in the Environment, ServerClass, MetalMachine install disk, Server, ServerBinding order, and the pinned install image
+// of the Server (or the ServerClass) is set last.
+func Render(in Input) (*Output, error) {
+	if in.Server == nil {
+		return nil, fmt.Errorf("server is required")
+	}
+
+	out := &Output{
+		Boot: Boot{
+			Server: in.Server.Name,
+		},
+	}
+
+	if !in.Server.Spec.Accepted {
+		out.Boot.Warnings = append(out.Boot.Warnings, "server is not accepted")
+	}
+
+	if in.ServerClass != nil {
+		out.Boot.ServerClass = in.ServerClass.Name
+
+		if err := validateServerClass(in.Server, in.ServerClass); err != nil {
+			return nil, err
+		}
+	}
+
+	env, err := renderEnvironment(in, out)
+	if err != nil {
+		return nil, err
+	}
+
+	layers := Layers(env, in.ServerClass, in.Server, in.ServerBinding)
+
+	if in.Config == nil {
+		// still make sure patches are well-formed
+		if err = ValidateLayers(layers); err != nil {
+			return nil, err
+		}
+
+		return out, nil
+	}
+
+	config := in.Config
+
+	if in.MetalMachine != nil {
+		if config, err = StaticNetwork(config, in.MetalMachine); err != nil {
+			return nil, err
+		}
+	}
+
+	if config, err = ApplyLayers(config, layers, in.Sources); err != nil {
+		return nil, err
+	}
+
+	if out.Config, err = LabelNode(config, in.Server.Name); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func validateServerClass(server *metalv1alpha1.Server, serverClass *metalv1alpha1.ServerClass) error {
+	matches, err := metalv1alpha1.FilterServers([]metalv1alpha1.Server{*server}, serverClass.SelectorFilter(), serverClass.QualifiersFilter())
+	if err != nil {
+		return err
+	}
+
+	if len(matches) == 0 {
+		return fmt.Errorf("server %q doesn't match serverclass %q selector and qualifiers", server.Name, serverClass.Name)
+	}
+
+	return nil
+}
+
+func renderEnvironment(in Input, out *Output) (*metalv1alpha1.Environment, error) {
+	name := metalv1alpha1.EnvironmentDefault
+
+	switch {
+	case in.Server.Spec.EnvironmentRef != nil:
+		name = in.Server.Spec.EnvironmentRef.Name
+	case in.ServerBinding != nil && in.ServerBinding.Spec.EnvironmentRef != nil:
+		name = in.ServerBinding.Spec.EnvironmentRef.Name
+	case in.ServerClass != nil && in.ServerClass.Spec.EnvironmentRef != nil:
+		name = in.ServerClass.Spec.EnvironmentRef.Name
+	}
+
+	out.Boot.Environment = name
+
+	for _, env := range in.Environments {
+		if env.Name != name {
+			continue
+		}
+
+		if env.Spec.Kernel.URL == "" || env.Spec.Initrd.URL == "" {
+			return nil, fmt.Errorf("environment %q: kernel and initrd URLs are required", name)
+		}
+
+		if err := env.Spec.Firmware.Check(in.Server); err != nil {
+			out.Boot.Warnings = append(out.Boot.Warnings, fmt.Sprintf("environment %q firmware requirements are not met: %s", name, err))
+		}
+
+		for _, err := range env.ValidateKernelArgs() {
+			out.Boot.Warnings = append(out.Boot.Warnings, fmt.Sprintf("environment %q kernel args are invalid: %s", name, err))
+		}
+
+		kernel, initrd := env.Spec.Kernel, env.Spec.Initrd
+
+		out.Boot.Kernel = &kernel
+		out.Boot.Initrd = &initrd
+		out.Boot.Cmdline = env.Spec.KernelCmdline()
+
+		return env, nil
+	}
+
+	if name != metalv1alpha1.EnvironmentDefault {
+		return nil, fmt.Errorf("environment %q is not found", name)
+	}
+
+	out.Boot.Warnings = append(out.Boot.Warnings, fmt.Sprintf("environment %q is not provided, it is created by Sidero on startup", name))
+
+	return nil, nil
+}
+
+func decodePatch(patches []metalv1alpha1.ConfigPatches) (jsonpatch.Patch, error) {
+	marshalledPatches, err := json.Marshal(patches)
+	if err != nil {
+		return nil, fmt.Errorf("failure marshaling config patches from server: %s", err)
+	}
+
+	patch, err := jsonpatch.DecodePatch(marshalledPatches)
+	if err != nil {
+		return nil, fmt.Errorf("failure decoding config patches from server to rfc6902 patch: %s", err)
+	}
+
+	return patch, nil
+}
+
+// PatchConfig applies a set of configPatches to the bootstrap data.
+func PatchConfig(decodedData []byte, patches []metalv1alpha1.ConfigPatches) ([]byte, error) {
+	patch, err := decodePatch(patches)
+	if err != nil {
+		return nil, err
+	}
+
+	jsonDecodedData, err := yaml.YAMLToJSON(decodedData)
+	if err != nil {
+		return nil, fmt.Errorf("failure converting bootstrap data to json: %s", err)
+	}
+
+	jsonDecodedData, err = patch.Apply(jsonDecodedData)
+	if err != nil {
+		return nil, fmt.Errorf("failure applying rfc6902 patches to machine config: %s", err)
+	}
+
+	decodedData, err = yaml.JSONToYAML(jsonDecodedData)
+	if err != nil {
+		return nil, fmt.Errorf("failure converting bootstrap data from json to yaml: %s", err)
+	}
+
+	return decodedData, nil
+}
+
+// LabelNode edits the kubelet extra args such that a given server gets registered
+// with a label containing the UUID of the server resource it's actually running on.
+func LabelNode(decodedData []byte, serverName string) ([]byte, error) {
+	configProvider, err := configloader.NewFromBytes(decodedData)
+	if err != nil {
+		return nil, fmt.Errorf("failure creating config struct: %s", err)
+	}
+
+	switch configProvider.Version() {
+	case "v1alpha1":
+		config, ok := configProvider.(*v1alpha1.Config)
+		if !ok {
+			return nil, fmt.Errorf("unable to case config")
+		}
+
+		if config.MachineConfig == nil || config.MachineConfig.MachineKubelet == nil {
+			return nil, fmt.Errorf("machine config is missing kubelet section")
+		}
+
+		patch := metalv1alpha1.ConfigPatches{
+			Path: "/machine/kubelet/extraArgs",
+			Op:   "replace",
+		}
+
+		kubeletExtraArgs := config.MachineConfig.MachineKubelet.KubeletExtraArgs
+		if kubeletExtraArgs == nil {
+			patch.Op = "add"
+			kubeletExtraArgs = make(map[string]string)
+		}
+
+		if _, ok = kubeletExtraArgs["node-labels"]; ok {
+			kubeletExtraArgs["node-labels"] += fmt.Sprintf(",metal.sidero.dev/uuid=%s", serverName)
+		} else {
+			kubeletExtraArgs["node-labels"] = fmt.Sprintf("metal.sidero.dev/uuid=%s", serverName)
+		}
+
+		value, err := json.Marshal(kubeletExtraArgs)
+		if err != nil {
+			return nil, fmt.Errorf("failure marshaling kubelet.extraArgs: %s", err)
+		}
+
+		patch.Value.Raw = value
+
+		return PatchConfig(decodedData, []metalv1alpha1.ConfigPatches{patch})
+	default:
+		return nil, fmt.Errorf("unknown config type")
+	}
+}
+
+// InstallImage returns the installer image from the machine configuration.
+func InstallImage(decodedData []byte) (string, error) {
+	configProvider, err := configloader.NewFromBytes(decodedData)
+	if err != nil {
+		return "", fmt.Errorf("failure creating config struct: %s", err)
+	}
+
+	switch configProvider.Version() {
+	case "v1alpha1":
+		config, ok := configProvider.(*v1alpha1.Config)
+		if !ok {
+			return "", fmt.Errorf("unable to case config")
+		}
+
+		if config.MachineConfig == nil || config.MachineConfig.MachineInstall == nil {
+			return "", nil
+		}
+
+		return config.MachineConfig.MachineInstall.InstallImage, nil
+	default:
+		return "", fmt.Errorf("unknown config type")
+	}
+}
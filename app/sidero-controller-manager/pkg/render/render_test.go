@@ -0,0 +1,320 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package render_test
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	infrav1 "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/render"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+func TestGolden(t *testing.T) {
+	t.Parallel()
+
+	dirs, err := filepath.Glob("testdata/golden/*")
+	require.NoError(t, err)
+	require.NotEmpty(t, dirs)
+
+	for _, dir := range dirs {
+		dir := dir
+
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			t.Parallel()
+
+			in, err := render.LoadCase(dir)
+			require.NoError(t, err)
+
+			out, err := render.Render(in)
+			require.NoError(t, err)
+
+			actual, err := out.Bytes()
+			require.NoError(t, err)
+
+			expectedFile := filepath.Join(dir, render.ExpectedFile)
+
+			if *update {
+				require.NoError(t, os.WriteFile(expectedFile, actual, 0o644))
+			}
+
+			expected, err := os.ReadFile(expectedFile)
+			require.NoError(t, err)
+
+			assert.Equal(t, string(expected), string(actual))
+		})
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	t.Parallel()
+
+	server := func() *metalv1alpha1.Server {
+		return &metalv1alpha1.Server{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: "server",
+				Labels: map[string]string{
+					"zone": "central",
+				},
+			},
+			Spec: metalv1alpha1.ServerSpec{
+				Accepted: true,
+			},
+		}
+	}
+
+	for name, tc := range map[string]struct {
+		in       render.Input
+		expected string
+	}{
+		"no server": {
+			in:       render.Input{},
+			expected: "server is required",
+		},
+		"qualifiers mismatch": {
+			in: render.Input{
+				Server: server(),
+				ServerClass: &metalv1alpha1.ServerClass{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "east",
+					},
+					Spec: metalv1alpha1.ServerClassSpec{
+						Selector: metav1.LabelSelector{
+							MatchLabels: map[string]string{
+								"zone": "east",
+							},
+						},
+					},
+				},
+			},
+			expected: `server "server" doesn't match serverclass "east" selector and qualifiers`,
+		},
+		"missing environment": {
+			in: render.Input{
+				Server: func() *metalv1alpha1.Server {
+					s := server()
+					s.Spec.EnvironmentRef = &corev1.ObjectReference{Name: "missing"}
+
+					return s
+				}(),
+			},
+			expected: `environment "missing" is not found`,
+		},
+		"invalid patch": {
+			in: render.Input{
+				Server: func() *metalv1alpha1.Server {
+					s := server()
+					s.Spec.ConfigPatches = []metalv1alpha1.ConfigPatches{
+						{
+							Op:   "merge",
+							Path: "/machine",
+						},
+					}
+
+					return s
+				}(),
+				Config: []byte("version: v1alpha1\nmachine:\n  kubelet: {}\n"),
+			},
+			expected: `server "server": failure applying rfc6902 patches to machine config: Unexpected kind: merge`,
+		},
+		"missing patches source": {
+			in: render.Input{
+				Server: func() *metalv1alpha1.Server {
+					s := server()
+					s.Spec.ConfigPatchesFrom = []metalv1alpha1.ConfigPatchesRef{
+						{
+							Kind: "ConfigMap",
+							Name: "missing",
+							Key:  "patch.yaml",
+						},
+					}
+
+					return s
+				}(),
+				Sources: &render.ObjectsSource{},
+				Config:  []byte("version: v1alpha1\nmachine:\n  kubelet: {}\n"),
+			},
+			expected: `server "server": ConfigMap default/missing key "patch.yaml": ConfigMap default/missing is not found`,
+		},
+		"static addresses not assigned": {
+			in: render.Input{
+				Server: server(),
+				MetalMachine: &infrav1.MetalMachine{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      "machine",
+						Namespace: "default",
+					},
+					Spec: infrav1.MetalMachineSpec{
+						Network: &infrav1.MetalMachineNetwork{
+							Interfaces: []infrav1.StaticInterface{
+								{
+									Name: "eth0",
+									AddressFromPool: corev1.TypedLocalObjectReference{
+										Kind: "InClusterIPPool",
+										Name: "production",
+									},
+								},
+							},
+						},
+					},
+				},
+				Config: []byte("version: v1alpha1\nmachine:\n  kubelet: {}\n"),
+			},
+			expected: `static addresses are not assigned yet for metalmachine default/machine`,
+		},
+		"missing patches key": {
+			in: render.Input{
+				Server: server(),
+				ServerBinding: &infrav1.ServerBinding{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "server",
+					},
+					Spec: infrav1.ServerBindingSpec{
+						ConfigPatchesFrom: []metalv1alpha1.ConfigPatchesRef{
+							{
+								Kind:      "Secret",
+								Namespace: "management",
+								Name:      "patches",
+								Key:       "patch.yaml",
+								Type:      metalv1alpha1.ConfigPatchTypeMerge,
+							},
+						},
+					},
+				},
+				Sources: &render.ObjectsSource{
+					Secrets: []*corev1.Secret{
+						{
+							ObjectMeta: metav1.ObjectMeta{
+								Name:      "patches",
+								Namespace: "management",
+							},
+						},
+					},
+				},
+				Config: []byte("version: v1alpha1\nmachine:\n  kubelet: {}\n"),
+			},
+			expected: `serverbinding "server": Secret management/patches key "patch.yaml": key "patch.yaml" is not found in Secret management/patches`,
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := render.Render(tc.in)
+			require.Error(t, err)
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	t.Parallel()
+
+	var server metalv1alpha1.Server
+
+	assert.Error(t, render.Decode([]byte(`apiVersion: metal.sidero.dev/v1alpha1
+kind: Server
+metadata:
+  name: server
+spec:
+  acepted: true
+`), "Server", &server))
+
+	assert.Error(t, render.Decode([]byte(`apiVersion: metal.sidero.dev/v1alpha1
+kind: ServerClass
+metadata:
+  name: any
+`), "Server", &server))
+}
+
+func TestMergeConfig(t *testing.T) {
+	t.Parallel()
+
+	config := []byte(`version: v1alpha1
+machine:
+  install:
+    disk: /dev/sda
+    extraKernelArgs:
+      - console=tty0
+  kubelet: {}
+`)
+
+	merged, err := render.MergeConfig(config, []byte(`machine:
+  install:
+    disk: null
+    extraKernelArgs:
+      - console=ttyS1
+    wipe: true
+`))
+	require.NoError(t, err)
+
+	assert.Equal(t, `machine:
+  install:
+    extraKernelArgs:
+    - console=ttyS1
+    wipe: true
+  kubelet: {}
+version: v1alpha1
+`, string(merged))
+}
+
+func TestCredentialsFingerprint(t *testing.T) {
+	t.Parallel()
+
+	config := `version: v1alpha1
+machine:
+  type: %s
+  token: %s
+cluster:
+  token: abcdef.0123456789abcdef
+`
+
+	fingerprint := func(machineType, token string) string {
+		sum, err := render.CredentialsFingerprint([]byte(fmt.Sprintf(config, machineType, token)))
+		require.NoError(t, err)
+
+		return sum
+	}
+
+	// changes outside of the credentials don't affect the fingerprint
+	assert.Equal(t, fingerprint("join", "token.a"), fingerprint("controlplane", "token.a"))
+	assert.NotEqual(t, fingerprint("join", "token.a"), fingerprint("join", "token.b"))
+
+	_, err := render.CredentialsFingerprint([]byte("not a config"))
+	assert.Error(t, err)
+}
+
+func TestRefNamespaces(t *testing.T) {
+	t.Parallel()
+
+	namespaces, err := render.ParseRefNamespaces(" default, sidero-system ")
+	require.NoError(t, err)
+
+	assert.NoError(t, namespaces.Check(metalv1alpha1.ConfigPatchesRef{Kind: "Secret", Name: "registry-auth"}))
+	assert.NoError(t, namespaces.Check(metalv1alpha1.ConfigPatchesRef{Kind: "Secret", Namespace: "sidero-system", Name: "registry-auth"}))
+	assert.EqualError(t,
+		namespaces.Check(metalv1alpha1.ConfigPatchesRef{Kind: "Secret", Namespace: "kube-system", Name: "bootstrap-token"}),
+		`Secret kube-system/bootstrap-token: references to namespace "kube-system" are not allowed`,
+	)
+
+	assert.True(t, namespaces.Allowed(""))
+	assert.True(t, namespaces.Allowed("sidero-system"))
+	assert.False(t, namespaces.Allowed("kube-system"))
+
+	_, err = render.ParseRefNamespaces(" , ")
+	assert.EqualError(t, err, "no namespaces specified")
+}
@@ -0,0 +1,114 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package signatures detects the on-disk signatures of the storage stacks which indicate that the disk still holds data.
+//
+// Detection only reads the well-known locations of the labels, so it's fast enough to run on every disk before the wipe.
+package signatures
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Signatures of the existing data.
+const (
+	// Talos partitions are detected by the partition labels, so it's not reported by Detect.
+	Talos = "talos"
+	LVM   = "lvm"
+	ZFS   = "zfs"
+	Ceph  = "ceph"
+)
+
+const (
+	sectorSize = 512
+
+	// LVM2 physical volume label is in one of the first four sectors.
+	lvmLabelSectors = 4
+
+	// ZFS vdev label is 256 KiB with the uberblock ring in the second half.
+	zfsUberblockOffset = 128 * 1024
+	zfsUberblockSize   = 1024
+	zfsLabelSize       = 256 * 1024
+	zfsUberblockMagic  = 0x00bab10c
+)
+
+var (
+	lvmLabel      = []byte("LABELONE")
+	lvmType       = []byte("LVM2 001")
+	cephBluestore = []byte("bluestore block device")
+)
+
+// Detect checks the device for the storage signatures, each offset is the start of the device or a partition.
+//
+// Signatures are returned in the order of detection without duplicates.
+func Detect(r io.ReaderAt, offsets ...int64) ([]string, error) {
+	var found []string
+
+	add := func(signature string) {
+		for _, s := range found {
+			if s == signature {
+				return
+			}
+		}
+
+		found = append(found, signature)
+	}
+
+	for _, offset := range offsets {
+		buf := make([]byte, zfsLabelSize)
+
+		n, err := r.ReadAt(buf, offset)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		buf = buf[:n]
+
+		if isLVM(buf) {
+			add(LVM)
+		}
+
+		if isZFS(buf) {
+			add(ZFS)
+		}
+
+		if bytes.HasPrefix(buf, cephBluestore) {
+			add(Ceph)
+		}
+	}
+
+	return found, nil
+}
+
+func isLVM(buf []byte) bool {
+	for sector := 0; sector < lvmLabelSectors; sector++ {
+		start := sector * sectorSize
+
+		if len(buf) < start+sectorSize {
+			return false
+		}
+
+		label := buf[start : start+sectorSize]
+
+		if bytes.HasPrefix(label, lvmLabel) && bytes.Equal(label[24:32], lvmType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isZFS(buf []byte) bool {
+	for offset := zfsUberblockOffset; offset+8 <= len(buf); offset += zfsUberblockSize {
+		// uberblock is written in the native byte order of the host
+		if binary.LittleEndian.Uint64(buf[offset:]) == zfsUberblockMagic || binary.BigEndian.Uint64(buf[offset:]) == zfsUberblockMagic {
+			return true
+		}
+	}
+
+	return false
+}
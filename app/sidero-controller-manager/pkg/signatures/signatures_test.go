@@ -0,0 +1,94 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package signatures_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/signatures"
+)
+
+func TestDetect(t *testing.T) {
+	t.Parallel()
+
+	const partitionOffset = 1024 * 1024
+
+	disk := func(write func(buf []byte)) []byte {
+		buf := make([]byte, 4*1024*1024)
+
+		write(buf)
+
+		return buf
+	}
+
+	for name, tc := range map[string]struct {
+		disk    []byte
+		offsets []int64
+
+		expected []string
+	}{
+		"empty": {
+			disk:    disk(func([]byte) {}),
+			offsets: []int64{0, partitionOffset},
+		},
+		"lvm": {
+			disk: disk(func(buf []byte) {
+				copy(buf[512:], "LABELONE")
+				copy(buf[512+24:], "LVM2 001")
+			}),
+			offsets:  []int64{0},
+			expected: []string{signatures.LVM},
+		},
+		"lvm label without type": {
+			disk: disk(func(buf []byte) {
+				copy(buf[512:], "LABELONE")
+			}),
+			offsets: []int64{0},
+		},
+		"zfs on partition": {
+			disk: disk(func(buf []byte) {
+				binary.LittleEndian.PutUint64(buf[partitionOffset+128*1024+3*1024:], 0x00bab10c)
+			}),
+			offsets:  []int64{0, partitionOffset},
+			expected: []string{signatures.ZFS},
+		},
+		"zfs partition not checked": {
+			disk: disk(func(buf []byte) {
+				binary.BigEndian.PutUint64(buf[partitionOffset+128*1024:], 0x00bab10c)
+			}),
+			offsets: []int64{0},
+		},
+		"ceph and lvm": {
+			disk: disk(func(buf []byte) {
+				copy(buf, "bluestore block device\n")
+				copy(buf[partitionOffset+512:], "LABELONE")
+				copy(buf[partitionOffset+512+24:], "LVM2 001")
+			}),
+			offsets:  []int64{0, partitionOffset},
+			expected: []string{signatures.Ceph, signatures.LVM},
+		},
+		"small disk": {
+			disk:     []byte("bluestore block device\n"),
+			offsets:  []int64{0},
+			expected: []string{signatures.Ceph},
+		},
+	} {
+		tc := tc
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			found, err := signatures.Detect(bytes.NewReader(tc.disk), tc.offsets...)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, found)
+		})
+	}
+}
@@ -0,0 +1,230 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package sitecache
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	retryInterval    = 10 * time.Second
+	maxRetryInterval = 10 * time.Minute
+)
+
+// Upload is the upload of the asset run in the background.
+type Upload struct {
+	// Key identifies the upload of the asset, e.g. `<site>/<environment>/<asset>`.
+	Key      string
+	Uploader *Uploader
+	URL      string
+	File     string
+	Checksum string
+	// Deadline interrupts the upload, e.g. in the end of the transfer window.
+	Deadline time.Time
+	// Progress is called with the number of the bytes the cache has.
+	Progress func(transferred, total int64)
+	// Done is called once the upload is complete, failed or interrupted by the deadline.
+	// Done is not called if the upload is canceled.
+	Done func(err error)
+}
+
+type upload struct {
+	checksum string
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+type failure struct {
+	checksum string
+	count    int
+	retryAt  time.Time
+}
+
+// Runner runs the uploads in the background, so that the slow (bandwidth-limited) uploads don't block the reconcilers.
+//
+// Runner implements manager.Runnable to cancel the uploads on shutdown.
+type Runner struct {
+	mu       sync.Mutex
+	uploads  map[string]*upload
+	failures map[string]*failure
+}
+
+// NewRunner initializes the runner.
+func NewRunner() *Runner {
+	return &Runner{
+		uploads:  map[string]*upload{},
+		failures: map[string]*failure{},
+	}
+}
+
+// Start implements manager.Runnable.
+func (r *Runner) Start(stop <-chan struct{}) error {
+	<-stop
+
+	r.Cancel("")
+
+	return nil
+}
+
+// Run starts the upload, unless the upload of the same asset (key and checksum) is already running.
+//
+// The upload of another version of the asset is canceled.
+// Run returns true if the upload was started.
+func (r *Runner) Run(u Upload) bool {
+	r.mu.Lock()
+	running, ok := r.uploads[u.Key]
+	r.mu.Unlock()
+
+	if ok {
+		if running.checksum == u.Checksum {
+			return false
+		}
+
+		r.cancel(u.Key, running)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok = r.uploads[u.Key]; ok {
+		// started concurrently
+		return false
+	}
+
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+
+	if u.Deadline.IsZero() {
+		ctx, cancel = context.WithCancel(context.Background())
+	} else {
+		ctx, cancel = context.WithDeadline(context.Background(), u.Deadline)
+	}
+
+	running = &upload{
+		checksum: u.Checksum,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+
+	r.uploads[u.Key] = running
+
+	go r.run(ctx, u, running)
+
+	return true
+}
+
+// Running returns true if the upload of the asset is running.
+func (r *Runner) Running(key, checksum string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	running, ok := r.uploads[key]
+
+	return ok && running.checksum == checksum
+}
+
+// RetryAt returns the time the failed upload of the asset should be retried at, zero if it didn't fail.
+//
+// Failed uploads are retried with the exponential backoff.
+func (r *Runner) RetryAt(key, checksum string) time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	f, ok := r.failures[key]
+	if !ok || f.checksum != checksum {
+		return time.Time{}
+	}
+
+	return f.retryAt
+}
+
+// Cancel the uploads with the key prefix, and waits for them to stop.
+func (r *Runner) Cancel(prefix string) {
+	r.mu.Lock()
+
+	canceled := map[string]*upload{}
+
+	for key, running := range r.uploads {
+		if strings.HasPrefix(key, prefix) {
+			canceled[key] = running
+		}
+	}
+
+	for key := range r.failures {
+		if strings.HasPrefix(key, prefix) {
+			delete(r.failures, key)
+		}
+	}
+
+	r.mu.Unlock()
+
+	for key, running := range canceled {
+		r.cancel(key, running)
+	}
+}
+
+func (r *Runner) cancel(key string, running *upload) {
+	running.cancel()
+	<-running.done
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.uploads[key] == running {
+		delete(r.uploads, key)
+	}
+}
+
+func (r *Runner) run(ctx context.Context, u Upload, running *upload) {
+	defer close(running.done)
+	defer running.cancel()
+
+	err := u.Uploader.Upload(ctx, u.URL, u.File, u.Checksum, u.Progress)
+
+	if ctx.Err() == context.Canceled {
+		return
+	}
+
+	r.mu.Lock()
+
+	switch {
+	case err == nil:
+		delete(r.failures, u.Key)
+	case ctx.Err() == nil:
+		f, ok := r.failures[u.Key]
+		if !ok || f.checksum != u.Checksum {
+			f = &failure{checksum: u.Checksum}
+			r.failures[u.Key] = f
+		}
+
+		f.count++
+
+		delay := retryInterval << (f.count - 1)
+		if delay > maxRetryInterval || delay <= 0 {
+			delay = maxRetryInterval
+		}
+
+		f.retryAt = time.Now().Add(delay)
+	}
+
+	r.mu.Unlock()
+
+	if u.Done != nil {
+		u.Done(err)
+	}
+
+	// the upload is removed once the outcome is reported, so that the reconcilers don't restart the upload before that
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.uploads[u.Key] == running {
+		delete(r.uploads, u.Key)
+	}
+}
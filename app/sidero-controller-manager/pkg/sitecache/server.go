@@ -0,0 +1,299 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package sitecache
+
+import (
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server is the site cache: it stores the uploaded assets in the directory and serves them to the servers at the site.
+type Server struct {
+	dir   string
+	token string
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewServer initializes the site cache storing the assets in the directory.
+//
+// Uploads should be authenticated with the token as the bearer token, all uploads are rejected if the token is empty.
+func NewServer(dir, token string) *Server {
+	return &Server{
+		dir:   dir,
+		token: token,
+		locks: map[string]*sync.Mutex{},
+	}
+}
+
+// lock serializes the uploads of the asset, the uploads take long on the slow links, so the other assets are not blocked.
+func (s *Server) lock(name string) func() {
+	s.mu.Lock()
+
+	l, ok := s.locks[name]
+	if !ok {
+		l = &sync.Mutex{}
+		s.locks[name] = l
+	}
+
+	s.mu.Unlock()
+
+	l.Lock()
+
+	return l.Unlock
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name, ok := s.assetPath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		s.serve(w, r, name)
+	case http.MethodPut:
+		if !s.authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+
+			return
+		}
+
+		s.upload(w, r, name)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	if s.token == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.token)) == 1
+}
+
+// assetPath maps `/env/<environment>/<asset>` to the file in the directory.
+func (s *Server) assetPath(urlPath string) (string, bool) {
+	parts := strings.Split(strings.TrimPrefix(path.Clean(urlPath), "/"), "/")
+
+	if len(parts) != 3 || parts[0] != "env" {
+		return "", false
+	}
+
+	for _, part := range parts[1:] {
+		if part == "" || part == "." || part == ".." || strings.HasSuffix(part, ".partial") || strings.HasSuffix(part, ".sha512") {
+			return "", false
+		}
+	}
+
+	return filepath.Join(s.dir, parts[0], parts[1], parts[2]), true
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request, name string) {
+	// assets are replaced with a rename, so they are served without waiting for the uploads
+	checksum := readChecksum(name)
+	offset := s.partialOffset(name, r.Header.Get(ChecksumHeader))
+
+	if offset > 0 {
+		w.Header().Set(OffsetHeader, strconv.FormatInt(offset, 10))
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	st, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	if checksum != "" {
+		w.Header().Set(ChecksumHeader, checksum)
+	}
+
+	http.ServeContent(w, r, filepath.Base(name), st.ModTime(), f)
+}
+
+// partialOffset returns the size of the partial upload with the checksum.
+func (s *Server) partialOffset(name, checksum string) int64 {
+	if checksum == "" || !strings.EqualFold(readChecksum(name+".partial"), checksum) {
+		return 0
+	}
+
+	st, err := os.Stat(name + ".partial")
+	if err != nil {
+		return 0
+	}
+
+	return st.Size()
+}
+
+func (s *Server) upload(w http.ResponseWriter, r *http.Request, name string) {
+	checksum := strings.ToLower(r.Header.Get(ChecksumHeader))
+	if _, err := hex.DecodeString(checksum); err != nil || len(checksum) != sha512.Size*2 {
+		http.Error(w, "invalid checksum", http.StatusBadRequest)
+
+		return
+	}
+
+	start, end, size, err := parseContentRange(r.Header.Get("Content-Range"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	defer s.lock(name)()
+
+	if readChecksum(name) == checksum {
+		w.WriteHeader(http.StatusOK)
+
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	partial := name + ".partial"
+
+	// upload of another version of the asset is discarded
+	if !strings.EqualFold(readChecksum(partial), checksum) {
+		if err = os.WriteFile(partial, nil, 0o644); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		if err = os.WriteFile(partial+".sha512", []byte(checksum), 0o644); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+	}
+
+	offset := s.partialOffset(name, checksum)
+
+	if start != offset {
+		w.Header().Set(OffsetHeader, strconv.FormatInt(offset, 10))
+		http.Error(w, fmt.Sprintf("upload should resume at %d", offset), http.StatusRequestedRangeNotSatisfiable)
+
+		return
+	}
+
+	f, err := os.OpenFile(partial, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	// bytes received before the connection is interrupted are kept, so that the upload is resumed
+	written, copyErr := io.Copy(f, io.LimitReader(r.Body, end-start+1))
+
+	if err = f.Close(); err != nil && copyErr == nil {
+		copyErr = err
+	}
+
+	offset += written
+
+	if copyErr != nil {
+		w.Header().Set(OffsetHeader, strconv.FormatInt(offset, 10))
+		http.Error(w, copyErr.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	if offset < size {
+		w.Header().Set(OffsetHeader, strconv.FormatInt(offset, 10))
+		w.WriteHeader(http.StatusAccepted)
+
+		return
+	}
+
+	digest, err := fileChecksum(partial)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	if digest != checksum {
+		os.Remove(partial)             //nolint:errcheck
+		os.Remove(partial + ".sha512") //nolint:errcheck
+
+		http.Error(w, fmt.Sprintf("%s: expected %s, got %s", ErrChecksumMismatch, checksum, digest), http.StatusUnprocessableEntity)
+
+		return
+	}
+
+	// checksum is dropped before the asset is replaced, so that the old checksum never describes the new asset
+	os.Remove(name + ".sha512") //nolint:errcheck
+
+	if err = os.Rename(partial, name); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	if err = os.Rename(partial+".sha512", name+".sha512"); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func readChecksum(name string) string {
+	data, err := os.ReadFile(name + ".sha512")
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(data))
+}
+
+func fileChecksum(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	h := sha512.New()
+
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,57 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package sitecache implements the resumable replication of the Environment assets to the remote site caches.
+//
+// The protocol is plain HTTP on top of the asset URLs (`<cache>/env/<environment>/<asset>`):
+//
+//   - HEAD returns the checksum of the stored asset in the X-Sidero-Sha512 header, and the size of
+//     the partial upload of the asset with the checksum from the X-Sidero-Sha512 request header in the X-Sidero-Upload-Offset header;
+//   - PUT with the Content-Range and X-Sidero-Sha512 headers appends the range to the partial upload, the asset is stored
+//     once the upload is complete and the checksum matches;
+//   - GET serves the stored asset to the servers at the site.
+package sitecache
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+const (
+	// ChecksumHeader is the SHA512 checksum of the asset (hex).
+	ChecksumHeader = "X-Sidero-Sha512"
+	// OffsetHeader is the size of the partial upload of the asset.
+	OffsetHeader = "X-Sidero-Upload-Offset"
+)
+
+// ErrChecksumMismatch is returned when the uploaded asset doesn't match the checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+var contentRangeRe = regexp.MustCompile(`^bytes (\d+)-(\d+)/(\d+)$`)
+
+// parseContentRange parses `bytes <start>-<end>/<size>`.
+func parseContentRange(s string) (start, end, size int64, err error) {
+	matches := contentRangeRe.FindStringSubmatch(s)
+	if matches == nil {
+		return 0, 0, 0, fmt.Errorf("invalid content range %q", s)
+	}
+
+	values := make([]int64, 3)
+
+	for i := range values {
+		if values[i], err = strconv.ParseInt(matches[i+1], 10, 64); err != nil {
+			return 0, 0, 0, fmt.Errorf("invalid content range %q: %w", s, err)
+		}
+	}
+
+	start, end, size = values[0], values[1], values[2]
+
+	if start > end || end >= size {
+		return 0, 0, 0, fmt.Errorf("invalid content range %q", s)
+	}
+
+	return start, end, size, nil
+}
@@ -0,0 +1,279 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package sitecache_test
+
+import (
+	"context"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/sitecache"
+)
+
+const token = "secret"
+
+func checksum(data []byte) string {
+	sum := sha512.Sum512(data)
+
+	return hex.EncodeToString(sum[:])
+}
+
+func writeAsset(t *testing.T, data []byte) string {
+	file := filepath.Join(t.TempDir(), "vmlinuz")
+
+	require.NoError(t, os.WriteFile(file, data, 0o644))
+
+	return file
+}
+
+func get(t *testing.T, url string) []byte {
+	resp, err := http.Get(url) //nolint:gosec,noctx
+	require.NoError(t, err)
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return data
+}
+
+func TestUpload(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	srv := httptest.NewServer(sitecache.NewServer(t.TempDir(), token))
+	t.Cleanup(srv.Close)
+
+	data := []byte("kernel v1")
+	file := writeAsset(t, data)
+	url := srv.URL + "/env/default/vmlinuz"
+
+	var transferred, total int64
+
+	uploader := &sitecache.Uploader{Token: token}
+
+	require.NoError(t, uploader.Upload(ctx, url, file, checksum(data), func(n, size int64) { transferred, total = n, size }))
+
+	assert.Equal(t, data, get(t, url))
+	assert.EqualValues(t, len(data), transferred)
+	assert.EqualValues(t, len(data), total)
+
+	// already replicated
+	transferred = 0
+
+	require.NoError(t, uploader.Upload(ctx, url, file, checksum(data), func(n, size int64) { transferred = n }))
+	assert.EqualValues(t, len(data), transferred)
+
+	// new version replaces the asset
+	updated := []byte("kernel v2")
+
+	require.NoError(t, uploader.Upload(ctx, url, writeAsset(t, updated), checksum(updated), func(int64, int64) {}))
+	assert.Equal(t, updated, get(t, url))
+}
+
+func TestUploadResume(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	cache := sitecache.NewServer(t.TempDir(), token)
+
+	var received int64
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			atomic.AddInt64(&received, r.ContentLength)
+		}
+
+		cache.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+
+	data := make([]byte, 1<<20)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	file := writeAsset(t, data)
+	url := srv.URL + "/env/default/initramfs.xz"
+
+	// the first transfer is interrupted half way
+	interruptedCtx, cancel := context.WithCancel(ctx)
+
+	uploader := &sitecache.Uploader{Token: token, BandwidthLimit: 4 << 20}
+
+	err := uploader.Upload(interruptedCtx, url, file, checksum(data), func(n, _ int64) {
+		if n >= int64(len(data))/2 {
+			cancel()
+		}
+	})
+	require.Error(t, err)
+
+	var resumedAt int64 = -1
+
+	require.NoError(t, (&sitecache.Uploader{Token: token}).Upload(ctx, url, file, checksum(data), func(n, _ int64) {
+		if resumedAt < 0 {
+			resumedAt = n
+		}
+	}))
+
+	assert.Equal(t, data, get(t, url))
+	assert.Greater(t, resumedAt, int64(0))
+	assert.Less(t, atomic.LoadInt64(&received), 2*int64(len(data)))
+}
+
+func TestUploadChecksumMismatch(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(sitecache.NewServer(t.TempDir(), token))
+	t.Cleanup(srv.Close)
+
+	err := (&sitecache.Uploader{Token: token}).Upload(context.Background(), srv.URL+"/env/default/vmlinuz", writeAsset(t, []byte("tampered")), checksum([]byte("kernel")), func(int64, int64) {})
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, sitecache.ErrChecksumMismatch))
+
+	resp, err := http.Get(srv.URL + "/env/default/vmlinuz") //nolint:gosec,noctx
+	require.NoError(t, err)
+	resp.Body.Close() //nolint:errcheck
+
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestServerPaths(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(sitecache.NewServer(t.TempDir(), token))
+	t.Cleanup(srv.Close)
+
+	for _, path := range []string{"/env/edge/../../etc/passwd", "/env/edge/vmlinuz.partial", "/env/edge/vmlinuz.sha512", "/vmlinuz"} {
+		resp, err := http.Get(srv.URL + path) //nolint:gosec,noctx
+		require.NoError(t, err)
+		resp.Body.Close() //nolint:errcheck
+
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+	}
+}
+
+func TestUploadToken(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(sitecache.NewServer(t.TempDir(), token))
+	t.Cleanup(srv.Close)
+
+	data := []byte("kernel")
+	file := writeAsset(t, data)
+	url := srv.URL + "/env/default/vmlinuz"
+
+	err := (&sitecache.Uploader{}).Upload(context.Background(), url, file, checksum(data), func(int64, int64) {})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401 Unauthorized")
+
+	err = (&sitecache.Uploader{Token: "wrong"}).Upload(context.Background(), url, file, checksum(data), func(int64, int64) {})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401 Unauthorized")
+
+	require.NoError(t, (&sitecache.Uploader{Token: token}).Upload(context.Background(), url, file, checksum(data), func(int64, int64) {}))
+	assert.Equal(t, data, get(t, url))
+}
+
+func TestUploadNoToken(t *testing.T) {
+	t.Parallel()
+
+	// the cache without the token rejects all uploads
+	srv := httptest.NewServer(sitecache.NewServer(t.TempDir(), ""))
+	t.Cleanup(srv.Close)
+
+	data := []byte("kernel")
+
+	for _, uploader := range []*sitecache.Uploader{{}, {Token: token}} {
+		err := uploader.Upload(context.Background(), srv.URL+"/env/default/vmlinuz", writeAsset(t, data), checksum(data), func(int64, int64) {})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "401 Unauthorized")
+	}
+}
+
+func TestStoredChecksum(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	srv := httptest.NewServer(sitecache.NewServer(t.TempDir(), token))
+	t.Cleanup(srv.Close)
+
+	url := srv.URL + "/env/default/vmlinuz"
+
+	stored, err := sitecache.StoredChecksum(ctx, nil, url)
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+
+	data := []byte("kernel")
+
+	require.NoError(t, (&sitecache.Uploader{Token: token}).Upload(ctx, url, writeAsset(t, data), checksum(data), func(int64, int64) {}))
+
+	stored, err = sitecache.StoredChecksum(ctx, nil, url)
+	require.NoError(t, err)
+	assert.Equal(t, checksum(data), stored)
+}
+
+func TestRunner(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(sitecache.NewServer(t.TempDir(), token))
+	t.Cleanup(srv.Close)
+
+	runner := sitecache.NewRunner()
+
+	data := []byte("kernel")
+	url := srv.URL + "/env/default/vmlinuz"
+
+	done := make(chan error, 1)
+
+	upload := sitecache.Upload{
+		Key:      "site-1/default/vmlinuz",
+		Uploader: &sitecache.Uploader{Token: token},
+		URL:      url,
+		File:     writeAsset(t, data),
+		Checksum: checksum(data),
+		Progress: func(int64, int64) {},
+		Done:     func(err error) { done <- err },
+	}
+
+	require.True(t, runner.Run(upload))
+	require.NoError(t, <-done)
+
+	assert.Equal(t, data, get(t, url))
+	assert.True(t, runner.RetryAt(upload.Key, upload.Checksum).IsZero())
+
+	// failed upload is retried with the backoff
+	upload.Checksum = checksum([]byte("other"))
+
+	require.True(t, runner.Run(upload))
+
+	err := <-done
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, sitecache.ErrChecksumMismatch))
+
+	assert.Eventually(t, func() bool { return !runner.Running(upload.Key, upload.Checksum) }, time.Second, 10*time.Millisecond)
+	assert.True(t, runner.RetryAt(upload.Key, upload.Checksum).After(time.Now()))
+
+	runner.Cancel("site-1/")
+	assert.True(t, runner.RetryAt(upload.Key, upload.Checksum).IsZero())
+}
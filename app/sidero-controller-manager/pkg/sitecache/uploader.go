@@ -0,0 +1,257 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package sitecache
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Uploader replicates the assets to a site cache.
+type Uploader struct {
+	Client *http.Client
+	// Token the uploads are authenticated with.
+	Token string
+	// BandwidthLimit of the uploads in bytes per second, unlimited if zero.
+	BandwidthLimit int64
+}
+
+// Upload replicates the file to the URL, resuming the previous interrupted upload of the same asset (checksum).
+//
+// Progress is called with the number of the bytes the cache has.
+func (u *Uploader) Upload(ctx context.Context, url, file, checksum string, progress func(transferred, total int64)) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close() //nolint:errcheck
+
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	size := st.Size()
+	if size == 0 {
+		return fmt.Errorf("asset %q is empty", file)
+	}
+
+	offset, done, err := u.head(ctx, url, checksum)
+	if err != nil {
+		return err
+	}
+
+	if done {
+		progress(size, size)
+
+		return nil
+	}
+
+	if offset > size {
+		offset = 0
+	}
+
+	// the offset is re-synchronized at most once, e.g. if the cache lost the partial upload
+	for attempt := 0; ; attempt++ {
+		progress(offset, size)
+
+		var resync bool
+
+		offset, resync, err = u.put(ctx, url, f, checksum, offset, size, progress)
+		if err != nil || !resync || attempt > 0 {
+			return err
+		}
+	}
+}
+
+func (u *Uploader) head(ctx context.Context, url, checksum string) (offset int64, done bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, false, err
+	}
+
+	req.Header.Set(ChecksumHeader, checksum)
+	u.authorize(req)
+
+	resp, err := u.client().Do(req)
+	if err != nil {
+		return 0, false, err
+	}
+
+	resp.Body.Close() //nolint:errcheck
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNotFound:
+	default:
+		return 0, false, fmt.Errorf("site cache returned %s", resp.Status)
+	}
+
+	if resp.StatusCode == http.StatusOK && strings.EqualFold(resp.Header.Get(ChecksumHeader), checksum) {
+		return 0, true, nil
+	}
+
+	return parseOffset(resp.Header), false, nil
+}
+
+// StoredChecksum returns the checksum of the asset stored in the site cache, empty if the cache doesn't have the asset.
+func StoredChecksum(ctx context.Context, client *http.Client, url string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	resp.Body.Close() //nolint:errcheck
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return strings.ToLower(resp.Header.Get(ChecksumHeader)), nil
+	case http.StatusNotFound:
+		return "", nil
+	default:
+		return "", fmt.Errorf("site cache returned %s", resp.Status)
+	}
+}
+
+func (u *Uploader) put(ctx context.Context, url string, f *os.File, checksum string, offset, size int64, progress func(transferred, total int64)) (int64, bool, error) {
+	body := &progressReader{
+		r:        throttle(ctx, io.NewSectionReader(f, offset, size-offset), u.BandwidthLimit),
+		offset:   offset,
+		total:    size,
+		progress: progress,
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, url, body)
+	if err != nil {
+		return offset, false, err
+	}
+
+	req.ContentLength = size - offset
+	req.Header.Set(ChecksumHeader, checksum)
+	req.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, size-1, size))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	u.authorize(req)
+
+	resp, err := u.client().Do(req)
+	if err != nil {
+		return body.offset, false, err
+	}
+
+	defer resp.Body.Close() //nolint:errcheck
+
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024)) //nolint:errcheck
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		progress(size, size)
+
+		return size, false, nil
+	case http.StatusRequestedRangeNotSatisfiable:
+		return parseOffset(resp.Header), true, nil
+	case http.StatusUnprocessableEntity:
+		return 0, false, fmt.Errorf("%w: %s", ErrChecksumMismatch, strings.TrimSpace(string(message)))
+	default:
+		return parseOffset(resp.Header), false, fmt.Errorf("site cache returned %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+}
+
+func (u *Uploader) authorize(req *http.Request) {
+	if u.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+u.Token)
+	}
+}
+
+func (u *Uploader) client() *http.Client {
+	if u.Client != nil {
+		return u.Client
+	}
+
+	return http.DefaultClient
+}
+
+func parseOffset(header http.Header) int64 {
+	offset, err := strconv.ParseInt(header.Get(OffsetHeader), 10, 64)
+	if err != nil || offset < 0 {
+		return 0
+	}
+
+	return offset
+}
+
+type progressReader struct {
+	r        io.Reader
+	offset   int64
+	total    int64
+	progress func(transferred, total int64)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+
+	r.offset += int64(n)
+	r.progress(r.offset, r.total)
+
+	return n, err
+}
+
+// throttledReader limits the average read rate to the limit (bytes per second).
+type throttledReader struct {
+	ctx   context.Context
+	r     io.Reader
+	limit int64
+	start time.Time
+	read  int64
+}
+
+func throttle(ctx context.Context, r io.Reader, limit int64) io.Reader {
+	if limit <= 0 {
+		return r
+	}
+
+	return &throttledReader{
+		ctx:   ctx,
+		r:     r,
+		limit: limit,
+		start: time.Now(),
+	}
+}
+
+func (r *throttledReader) Read(p []byte) (int, error) {
+	// read at most 1/10 of the second worth of the data at once to keep the rate smooth
+	if chunk := r.limit / 10; chunk > 0 && int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+
+	n, err := r.r.Read(p)
+	r.read += int64(n)
+
+	if delay := time.Duration(float64(r.read)/float64(r.limit)*float64(time.Second)) - time.Since(r.start); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+
+		select {
+		case <-r.ctx.Done():
+			return n, r.ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	return n, err
+}
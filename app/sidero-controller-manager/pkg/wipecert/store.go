@@ -0,0 +1,144 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package wipecert
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// SigningKey returns the signing key from the key Secret, generating the key if the Secret doesn't exist.
+func SigningKey(ctx context.Context, c client.Client, namespace string) (ed25519.PrivateKey, error) {
+	var secret corev1.Secret
+
+	err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: KeySecretName}, &secret)
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return nil, err
+		}
+
+		privateKey, publicKey, err := GenerateKey()
+		if err != nil {
+			return nil, err
+		}
+
+		secret = corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: namespace,
+				Name:      KeySecretName,
+			},
+			Data: map[string][]byte{
+				PrivateKeyKey: privateKey,
+				PublicKeyKey:  publicKey,
+			},
+		}
+
+		if err = c.Create(ctx, &secret); err != nil {
+			if !apierrors.IsAlreadyExists(err) {
+				return nil, err
+			}
+
+			// lost the race with another issuer, use the key it created
+			return SigningKey(ctx, c, namespace)
+		}
+	}
+
+	return ParsePrivateKey(secret.Data[PrivateKeyKey])
+}
+
+// Issue signs the certificate and stores it in a ConfigMap.
+func Issue(ctx context.Context, c client.Client, namespace string, cert *Certificate) (*corev1.ConfigMap, error) {
+	priv, err := SigningKey(ctx, c, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("error getting signing key: %w", err)
+	}
+
+	document, signature, err := Sign(cert, priv)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey, err := MarshalPublicKey(priv.Public().(ed25519.PublicKey))
+	if err != nil {
+		return nil, err
+	}
+
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: namespace,
+			Name:      cert.Name(),
+			Labels: map[string]string{
+				ServerLabel:      cert.Server.UUID,
+				CertificateLabel: "true",
+			},
+		},
+		Data: map[string]string{
+			CertificateKey: string(document),
+			SignatureKey:   string(signature),
+			PublicKeyKey:   string(publicKey),
+		},
+	}
+
+	if err = c.Create(ctx, cm); err != nil {
+		if !apierrors.IsAlreadyExists(err) {
+			return nil, err
+		}
+
+		// the agent retried reporting the same wipe, keep the certificate issued first
+		if err = c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: cm.Name}, cm); err != nil {
+			return nil, err
+		}
+	}
+
+	return cm, nil
+}
+
+// List returns the certificate ConfigMaps for the server, oldest first.
+//
+// Certificates of all the servers are returned if the uuid is empty.
+func List(ctx context.Context, c client.Client, namespace, uuid string) ([]corev1.ConfigMap, error) {
+	labels := client.MatchingLabels{CertificateLabel: "true"}
+
+	if uuid != "" {
+		labels[ServerLabel] = uuid
+	}
+
+	var list corev1.ConfigMapList
+
+	if err := c.List(ctx, &list, client.InNamespace(namespace), labels); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(list.Items, func(i, j int) bool {
+		return list.Items[i].CreationTimestamp.Before(&list.Items[j].CreationTimestamp) ||
+			(list.Items[i].CreationTimestamp.Equal(&list.Items[j].CreationTimestamp) && list.Items[i].Name < list.Items[j].Name)
+	})
+
+	return list.Items, nil
+}
+
+// VerifyConfigMap verifies the certificate stored in the ConfigMap.
+//
+// If pub is nil, the public key stored along with the certificate is used, which only proves
+// the certificate was not modified after being issued with that key.
+func VerifyConfigMap(cm *corev1.ConfigMap, pub ed25519.PublicKey) (*Certificate, error) {
+	if pub == nil {
+		var err error
+
+		if pub, err = ParsePublicKey([]byte(cm.Data[PublicKeyKey])); err != nil {
+			return nil, err
+		}
+	}
+
+	return Verify([]byte(cm.Data[CertificateKey]), []byte(cm.Data[SignatureKey]), pub)
+}
@@ -0,0 +1,232 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package wipecert implements signed certificates of destruction issued after the server disks are wiped.
+//
+// Certificate is a JSON document signed with an Ed25519 key, it's stored in a ConfigMap together
+// with the signature and the public key, so that it can be verified offline.
+package wipecert
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Version of the certificate document format.
+const Version = "v1"
+
+// Names of the resources the certificates are stored in.
+const (
+	// KeySecretName is the Secret with the signing key, it's generated on the first use if missing.
+	KeySecretName = "sidero-wipe-certificate-key"
+
+	// ServerLabel is set on the certificate ConfigMaps to the server UUID.
+	ServerLabel = "metal.sidero.dev/server"
+	// CertificateLabel marks the ConfigMaps holding the certificates.
+	CertificateLabel = "metal.sidero.dev/wipe-certificate"
+
+	// OperatorAnnotation on the Server records the person responsible for the wipe in the certificate.
+	OperatorAnnotation = "metal.sidero.dev/wipe-operator"
+	// DefaultOperator is used when the operator annotation is not set.
+	DefaultOperator = "sidero-controller-manager"
+)
+
+// Keys of the Secret and ConfigMap data.
+const (
+	PrivateKeyKey  = "ed25519.key"
+	PublicKeyKey   = "ed25519.pub"
+	CertificateKey = "certificate.json"
+	SignatureKey   = "certificate.json.sig"
+)
+
+// Server identifies the wiped server.
+type Server struct {
+	UUID         string `json:"uuid"`
+	Manufacturer string `json:"manufacturer,omitempty"`
+	ProductName  string `json:"productName,omitempty"`
+	SerialNumber string `json:"serialNumber,omitempty"`
+}
+
+// Disk is the wiped disk.
+type Disk struct {
+	DeviceName string `json:"deviceName"`
+	Model      string `json:"model,omitempty"`
+	Serial     string `json:"serial,omitempty"`
+	Size       uint64 `json:"size,omitempty"`
+	// Method is either "fast" (head of the disk only) or the method reported by the block device wipe (e.g. "blkdiscard", "zeroes").
+	Method string `json:"method"`
+	// Verified is set if the agent checked the disk has no partition table left after the wipe.
+	Verified    bool      `json:"verified"`
+	StartedAt   time.Time `json:"startedAt"`
+	CompletedAt time.Time `json:"completedAt"`
+}
+
+// SkippedDisk is the disk which was not wiped.
+type SkippedDisk struct {
+	DeviceName string `json:"deviceName"`
+	Reason     string `json:"reason"`
+}
+
+// Certificate is the certificate of destruction.
+type Certificate struct {
+	Version      string        `json:"version"`
+	Server       Server        `json:"server"`
+	Operator     string        `json:"operator"`
+	StartedAt    time.Time     `json:"startedAt"`
+	CompletedAt  time.Time     `json:"completedAt"`
+	Disks        []Disk        `json:"disks"`
+	SkippedDisks []SkippedDisk `json:"skippedDisks,omitempty"`
+	IssuedAt     time.Time     `json:"issuedAt"`
+	// KeyID is the fingerprint of the public key the certificate is signed with.
+	KeyID string `json:"keyID"`
+}
+
+// Verified returns true if all the disks in the certificate were verified after the wipe.
+func (c *Certificate) Verified() bool {
+	for _, d := range c.Disks {
+		if !d.Verified {
+			return false
+		}
+	}
+
+	return len(c.Disks) > 0
+}
+
+// Name returns the ConfigMap name for the certificate.
+func (c *Certificate) Name() string {
+	return fmt.Sprintf("wipe-%s-%s", c.Server.UUID, strconv.FormatInt(c.CompletedAt.Unix(), 10))
+}
+
+// GenerateKey generates the PEM-encoded signing key pair.
+func GenerateKey() (privateKey, publicKey []byte, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	publicKey, err = MarshalPublicKey(pub)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), publicKey, nil
+}
+
+// MarshalPublicKey returns the PEM-encoded public key.
+func MarshalPublicKey(pub ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// ParsePrivateKey parses the PEM-encoded Ed25519 private key.
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found in the private key")
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T, expected Ed25519", key)
+	}
+
+	return priv, nil
+}
+
+// ParsePublicKey parses the PEM-encoded Ed25519 public key.
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found in the public key")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported public key type %T, expected Ed25519", key)
+	}
+
+	return pub, nil
+}
+
+// KeyID returns the fingerprint of the public key.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Sign fills in the key ID, and returns the certificate document and the base64-encoded signature.
+func Sign(c *Certificate, priv ed25519.PrivateKey) (document, signature []byte, err error) {
+	c.Version = Version
+	c.KeyID = KeyID(priv.Public().(ed25519.PublicKey))
+
+	document, err = json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sig := ed25519.Sign(priv, document)
+
+	signature = make([]byte, base64.StdEncoding.EncodedLen(len(sig)))
+	base64.StdEncoding.Encode(signature, sig)
+
+	return document, signature, nil
+}
+
+// Verify checks the signature of the certificate document and returns the parsed certificate.
+func Verify(document, signature []byte, pub ed25519.PublicKey) (*Certificate, error) {
+	sig, err := base64.StdEncoding.DecodeString(string(signature))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding signature: %w", err)
+	}
+
+	if !ed25519.Verify(pub, document, sig) {
+		return nil, errors.New("certificate signature is invalid")
+	}
+
+	var c Certificate
+
+	if err = json.Unmarshal(document, &c); err != nil {
+		return nil, fmt.Errorf("error decoding certificate: %w", err)
+	}
+
+	if c.Version != Version {
+		return nil, fmt.Errorf("unsupported certificate version %q", c.Version)
+	}
+
+	if c.KeyID != KeyID(pub) {
+		return nil, fmt.Errorf("certificate is signed with key %q, verified with %q", c.KeyID, KeyID(pub))
+	}
+
+	return &c, nil
+}
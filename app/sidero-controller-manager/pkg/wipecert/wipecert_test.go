@@ -0,0 +1,134 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package wipecert_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/pkg/wipecert"
+)
+
+func certificate(uuid string, completedAt time.Time) *wipecert.Certificate {
+	return &wipecert.Certificate{
+		Server:      wipecert.Server{UUID: uuid, SerialNumber: "6DR0QR2"},
+		Operator:    wipecert.DefaultOperator,
+		StartedAt:   completedAt.Add(-time.Hour),
+		CompletedAt: completedAt,
+		Disks: []wipecert.Disk{
+			{DeviceName: "/dev/sda", Method: "zeroes", Verified: true, StartedAt: completedAt.Add(-time.Hour), CompletedAt: completedAt},
+		},
+		IssuedAt: completedAt,
+	}
+}
+
+func TestSignVerify(t *testing.T) {
+	t.Parallel()
+
+	privPEM, pubPEM, err := wipecert.GenerateKey()
+	require.NoError(t, err)
+
+	priv, err := wipecert.ParsePrivateKey(privPEM)
+	require.NoError(t, err)
+
+	pub, err := wipecert.ParsePublicKey(pubPEM)
+	require.NoError(t, err)
+
+	cert := certificate("4c4c4544-0036-4410-8052-b7c04f4e3532", time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC))
+
+	document, signature, err := wipecert.Sign(cert, priv)
+	require.NoError(t, err)
+
+	verified, err := wipecert.Verify(document, signature, pub)
+	require.NoError(t, err)
+
+	assert.Equal(t, cert, verified)
+	assert.Equal(t, wipecert.Version, verified.Version)
+	assert.Equal(t, wipecert.KeyID(pub), verified.KeyID)
+	assert.True(t, verified.Verified())
+
+	_, err = wipecert.Verify([]byte(strings.Replace(string(document), "6DR0QR2", "6DR0QR3", 1)), signature, pub)
+	assert.EqualError(t, err, "certificate signature is invalid")
+
+	_, otherPEM, err := wipecert.GenerateKey()
+	require.NoError(t, err)
+
+	other, err := wipecert.ParsePublicKey(otherPEM)
+	require.NoError(t, err)
+
+	_, err = wipecert.Verify(document, signature, other)
+	assert.EqualError(t, err, "certificate signature is invalid")
+
+	_, err = wipecert.ParsePrivateKey(pubPEM)
+	assert.Error(t, err)
+}
+
+func TestIssue(t *testing.T) {
+	t.Parallel()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, corev1.AddToScheme(scheme))
+
+	ctx := context.Background()
+	c := fake.NewFakeClientWithScheme(scheme)
+
+	completedAt := time.Date(2021, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	first, err := wipecert.Issue(ctx, c, corev1.NamespaceDefault, certificate("server-1", completedAt))
+	require.NoError(t, err)
+
+	assert.Equal(t, "wipe-server-1-1630497600", first.Name)
+
+	// the signing key is generated once
+	var secret corev1.Secret
+
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Namespace: corev1.NamespaceDefault, Name: wipecert.KeySecretName}, &secret))
+
+	pub, err := wipecert.ParsePublicKey(secret.Data[wipecert.PublicKeyKey])
+	require.NoError(t, err)
+
+	// retried report of the same wipe
+	retried, err := wipecert.Issue(ctx, c, corev1.NamespaceDefault, certificate("server-1", completedAt))
+	require.NoError(t, err)
+
+	assert.Equal(t, first.Data, retried.Data)
+
+	_, err = wipecert.Issue(ctx, c, corev1.NamespaceDefault, certificate("server-1", completedAt.Add(time.Hour)))
+	require.NoError(t, err)
+
+	_, err = wipecert.Issue(ctx, c, corev1.NamespaceDefault, certificate("server-2", completedAt))
+	require.NoError(t, err)
+
+	list, err := wipecert.List(ctx, c, corev1.NamespaceDefault, "server-1")
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+
+	for i := range list {
+		cert, err := wipecert.VerifyConfigMap(&list[i], pub)
+		require.NoError(t, err)
+
+		assert.Equal(t, "server-1", cert.Server.UUID)
+	}
+
+	list, err = wipecert.List(ctx, c, corev1.NamespaceDefault, "")
+	require.NoError(t, err)
+	assert.Len(t, list, 3)
+
+	// modified certificate fails verification with the public key stored along with it
+	tampered := list[0].DeepCopy()
+	tampered.Data[wipecert.CertificateKey] = strings.Replace(tampered.Data[wipecert.CertificateKey], "6DR0QR2", "6DR0QR3", 1)
+
+	_, err = wipecert.VerifyConfigMap(tampered, nil)
+	assert.Error(t, err)
+}
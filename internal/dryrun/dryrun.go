@@ -0,0 +1,160 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package dryrun implements the dry-run (audit) mode of the controller managers.
+//
+// In the dry-run mode the changes to the resources are logged and sent to the API server with the dry-run option,
+// so that they are validated (including the admission webhooks), but never persisted.
+package dryrun
+
+import (
+	"context"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+// Client wraps the client so that the writes are logged and not persisted.
+type Client struct {
+	client.Client
+
+	log    logr.Logger
+	scheme *runtime.Scheme
+}
+
+// NewClient wraps the client.
+func NewClient(c client.Client, scheme *runtime.Scheme, log logr.Logger) *Client {
+	return &Client{
+		Client: c,
+		log:    log,
+		scheme: scheme,
+	}
+}
+
+// NewClientFunc builds the manager client which reads from the cache, and sends the writes with the dry-run option.
+func NewClientFunc(log logr.Logger) manager.NewClientFunc {
+	return func(cache cache.Cache, config *rest.Config, options client.Options) (client.Client, error) {
+		c, err := client.New(config, options)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewClient(&client.DelegatingClient{
+			Reader: &client.DelegatingReader{
+				CacheReader:  cache,
+				ClientReader: c,
+			},
+			Writer:       c,
+			StatusClient: c,
+		}, options.Scheme, log), nil
+	}
+}
+
+// Create implements client.Writer.
+func (c *Client) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	c.report("create", obj)
+
+	return c.Client.Create(ctx, obj, append(opts, client.DryRunAll)...)
+}
+
+// Update implements client.Writer.
+func (c *Client) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	c.report("update", obj)
+
+	return c.Client.Update(ctx, obj, append(opts, client.DryRunAll)...)
+}
+
+// Patch implements client.Writer.
+func (c *Client) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	c.reportPatch("patch", obj, patch)
+
+	return c.Client.Patch(ctx, obj, patch, append(opts, client.DryRunAll)...)
+}
+
+// Delete implements client.Writer.
+func (c *Client) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	c.report("delete", obj)
+
+	return c.Client.Delete(ctx, obj, append(opts, client.DryRunAll)...)
+}
+
+// DeleteAllOf implements client.Writer.
+func (c *Client) DeleteAllOf(ctx context.Context, obj runtime.Object, opts ...client.DeleteAllOfOption) error {
+	c.report("delete all of", obj)
+
+	// client.DryRunAll doesn't apply to DeleteAllOf
+	return c.Client.DeleteAllOf(ctx, obj, append(opts, &client.DeleteAllOfOptions{
+		DeleteOptions: client.DeleteOptions{DryRun: []string{metav1.DryRunAll}},
+	})...)
+}
+
+// Status implements client.StatusClient.
+func (c *Client) Status() client.StatusWriter {
+	return &statusWriter{
+		StatusWriter: c.Client.Status(),
+		c:            c,
+	}
+}
+
+func (c *Client) report(operation string, obj runtime.Object) {
+	c.log.Info("dry run: skipping "+operation, c.keysAndValues(obj)...)
+}
+
+func (c *Client) reportPatch(operation string, obj runtime.Object, patch client.Patch) {
+	keysAndValues := c.keysAndValues(obj)
+
+	if data, err := patch.Data(obj); err == nil {
+		keysAndValues = append(keysAndValues, "patch", string(data))
+	}
+
+	c.log.Info("dry run: skipping "+operation, keysAndValues...)
+}
+
+func (c *Client) keysAndValues(obj runtime.Object) []interface{} {
+	var gvk schema.GroupVersionKind
+
+	if c.scheme != nil {
+		gvk, _ = apiutil.GVKForObject(obj, c.scheme) //nolint:errcheck
+	}
+
+	keysAndValues := []interface{}{"kind", gvk.Kind}
+
+	if accessor, err := meta.Accessor(obj); err == nil {
+		if accessor.GetNamespace() != "" {
+			keysAndValues = append(keysAndValues, "namespace", accessor.GetNamespace())
+		}
+
+		keysAndValues = append(keysAndValues, "name", accessor.GetName())
+	}
+
+	return keysAndValues
+}
+
+type statusWriter struct {
+	client.StatusWriter
+
+	c *Client
+}
+
+// Update implements client.StatusWriter.
+func (w *statusWriter) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	w.c.report("status update", obj)
+
+	return w.StatusWriter.Update(ctx, obj, append(opts, client.DryRunAll)...)
+}
+
+// Patch implements client.StatusWriter.
+func (w *statusWriter) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	w.c.reportPatch("status patch", obj, patch)
+
+	return w.StatusWriter.Patch(ctx, obj, patch, append(opts, client.DryRunAll)...)
+}
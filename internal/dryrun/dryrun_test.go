@@ -0,0 +1,78 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package dryrun_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	metalv1alpha1 "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/internal/dryrun"
+)
+
+func TestClient(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	scheme := runtime.NewScheme()
+	require.NoError(t, metalv1alpha1.AddToScheme(scheme))
+
+	c := fake.NewFakeClientWithScheme(scheme, &metalv1alpha1.Server{
+		ObjectMeta: metav1.ObjectMeta{Name: "server-1", ResourceVersion: "1"},
+		Spec:       metalv1alpha1.ServerSpec{Accepted: true},
+	})
+
+	dryRunClient := dryrun.NewClient(c, scheme, log.NullLogger{})
+
+	// create
+	require.NoError(t, dryRunClient.Create(ctx, &metalv1alpha1.Server{
+		ObjectMeta: metav1.ObjectMeta{Name: "server-2"},
+	}))
+
+	err := c.Get(ctx, types.NamespacedName{Name: "server-2"}, &metalv1alpha1.Server{})
+	assert.True(t, apierrors.IsNotFound(err), "unexpected error %v", err)
+
+	// update
+	var server metalv1alpha1.Server
+
+	require.NoError(t, dryRunClient.Get(ctx, types.NamespacedName{Name: "server-1"}, &server))
+
+	server.Spec.Accepted = false
+
+	require.NoError(t, dryRunClient.Update(ctx, &server))
+
+	var updated metalv1alpha1.Server
+
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Name: "server-1"}, &updated))
+	assert.True(t, updated.Spec.Accepted)
+
+	// patch
+	patched := updated.DeepCopy()
+	patched.Spec.Hostname = "worker-1"
+
+	require.NoError(t, dryRunClient.Patch(ctx, patched, client.MergeFrom(&updated)))
+
+	// status
+	updated.Status.InUse = true
+
+	require.NoError(t, dryRunClient.Status().Update(ctx, &updated))
+
+	var current metalv1alpha1.Server
+
+	require.NoError(t, c.Get(ctx, types.NamespacedName{Name: "server-1"}, &current))
+	assert.Empty(t, current.Spec.Hostname)
+	assert.False(t, current.Status.InUse)
+}
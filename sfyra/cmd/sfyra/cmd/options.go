@@ -46,6 +46,14 @@ type Options struct {
 
 	PowerSimulatedExplicitFailureProb float64
 	PowerSimulatedSilentFailureProb   float64
+
+	UpgradeFrom string
+	UpgradeTo   string
+
+	ExistingKubeconfig        string
+	ExistingKubeconfigContext string
+	ExistingServerSelector    string
+	ExistingBridgeIP          string
 }
 
 // TalosRelease and KubernetesVersion are set as build arguments.
@@ -0,0 +1,104 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/talos-systems/talos/pkg/cli"
+	"k8s.io/client-go/tools/clientcmd"
+	capiclient "sigs.k8s.io/cluster-api/cmd/clusterctl/client"
+
+	"github.com/talos-systems/sidero/sfyra/pkg/capi"
+	"github.com/talos-systems/sidero/sfyra/pkg/existing"
+	"github.com/talos-systems/sidero/sfyra/pkg/tests"
+)
+
+var testExistingCmd = &cobra.Command{
+	Use:   "existing",
+	Short: "Run provisioning tests against the existing Sidero management cluster and servers.",
+	Long: `Run provisioning, scale and reset tests against the existing management cluster with Sidero installed,
+using the registered and accepted servers selected by the label selector (e.g. physical machines in the lab).
+
+QEMU bootstrap cluster and VMs are not created, servers should have the power management configured.
+Tests which require more servers than selected are skipped.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cli.WithContext(context.Background(), func(ctx context.Context) error {
+			bridgeIP := net.ParseIP(options.ExistingBridgeIP)
+			if bridgeIP == nil {
+				return fmt.Errorf("invalid bridge IP %q", options.ExistingBridgeIP)
+			}
+
+			kubeconfig := options.ExistingKubeconfig
+			if kubeconfig == "" {
+				kubeconfig = os.Getenv(clientcmd.RecommendedConfigPathEnvVar)
+			}
+
+			if kubeconfig == "" {
+				kubeconfig = clientcmd.RecommendedHomeFile
+			}
+
+			cluster, err := existing.NewCluster(existing.Options{
+				Kubeconfig: kubeconfig,
+				Context:    options.ExistingKubeconfigContext,
+
+				BridgeIP: bridgeIP,
+			})
+			if err != nil {
+				return err
+			}
+
+			clusterAPI, err := capi.NewManager(ctx, cluster, capi.Options{
+				ClusterctlConfigPath: options.ClusterctlConfigPath,
+
+				Kubeconfig: capiclient.Kubeconfig{
+					Path:    kubeconfig,
+					Context: options.ExistingKubeconfigContext,
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			// hacky hack
+			os.Args = append(os.Args[0:1], "-test.v")
+
+			if ok := tests.RunExisting(ctx, cluster, cluster, clusterAPI, tests.ExistingOptions{
+				Options: tests.Options{
+					RunTestPattern: runTestPattern,
+
+					TalosRelease:      TalosRelease,
+					KubernetesVersion: KubernetesVersion,
+				},
+
+				ServerSelector: options.ExistingServerSelector,
+				SkipTeardown:   options.SkipTeardown,
+			}); !ok {
+				return fmt.Errorf("test failure")
+			}
+
+			return nil
+		})
+	},
+}
+
+func init() {
+	testCmd.AddCommand(testExistingCmd)
+
+	testExistingCmd.Flags().BoolVar(&options.SkipTeardown, "skip-teardown", options.SkipTeardown, "skip deleting the provisioned cluster")
+	testExistingCmd.Flags().StringVar(&options.ExistingKubeconfig, "kubeconfig", options.ExistingKubeconfig, "path to the management cluster kubeconfig (defaults to $KUBECONFIG or ~/.kube/config)")
+	testExistingCmd.Flags().StringVar(&options.ExistingKubeconfigContext, "context", options.ExistingKubeconfigContext, "kubeconfig context to use (defaults to the current context)")
+	testExistingCmd.Flags().StringVar(&options.ExistingServerSelector, "server-selector", options.ExistingServerSelector, "label selector for the servers to provision (required)")
+	testExistingCmd.Flags().StringVar(&options.ExistingBridgeIP, "bridge-ip", options.ExistingBridgeIP, "IP of this host on the servers network, the control plane load balancer listens on it (required)")
+	testExistingCmd.Flags().StringVar(&options.ClusterctlConfigPath, "clusterctl-config", options.ClusterctlConfigPath, "path to the clusterctl config file")
+	testExistingCmd.Flags().StringVar(&runTestPattern, "test.run", "", "tests to run (regular expression)")
+
+	Should(testExistingCmd.MarkFlagRequired("server-selector"))
+	Should(testExistingCmd.MarkFlagRequired("bridge-ip"))
+}
@@ -0,0 +1,155 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/talos-systems/talos/pkg/cli"
+
+	"github.com/talos-systems/sidero/sfyra/pkg/bootstrap"
+	"github.com/talos-systems/sidero/sfyra/pkg/capi"
+	"github.com/talos-systems/sidero/sfyra/pkg/tests"
+	"github.com/talos-systems/sidero/sfyra/pkg/vm"
+)
+
+var testUpgradeCmd = &cobra.Command{
+	Use:   "upgrade",
+	Short: "Run upgrade test against Sidero.",
+	Long:  ``,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cli.WithContext(context.Background(), func(ctx context.Context) error {
+			bootstrapCluster, err := bootstrap.NewCluster(ctx, bootstrap.Options{
+				Name: options.BootstrapClusterName,
+				CIDR: options.BootstrapCIDR,
+
+				Vmlinuz:        options.BootstrapTalosVmlinuz,
+				Initramfs:      options.BootstrapTalosInitramfs,
+				InstallerImage: options.BootstrapTalosInstaller,
+				CNIBundleURL:   options.BootstrapCNIBundleURL,
+
+				TalosctlPath: options.TalosctlPath,
+
+				RegistryMirrors: options.RegistryMirrors,
+
+				CPUs:   options.BootstrapCPUs,
+				MemMB:  options.BootstrapMemMB,
+				DiskGB: options.BootstrapDiskGB,
+			})
+			if err != nil {
+				return err
+			}
+
+			if !options.SkipTeardown {
+				defer bootstrapCluster.TearDown(ctx) //nolint:errcheck
+			}
+
+			if err = bootstrapCluster.Setup(ctx); err != nil {
+				return err
+			}
+
+			managementSet, err := vm.NewSet(ctx, vm.Options{
+				Name:       options.ManagementSetName,
+				Nodes:      options.ManagementNodes,
+				BootSource: bootstrapCluster.SideroComponentsIP(),
+				CIDR:       options.ManagementCIDR,
+
+				CNIBundleURL: options.BootstrapCNIBundleURL,
+				TalosctlPath: options.TalosctlPath,
+
+				CPUs:   options.ManagementCPUs,
+				MemMB:  options.ManagementMemMB,
+				DiskGB: options.ManagementDiskGB,
+
+				DefaultBootOrder: options.DefaultBootOrder,
+			})
+			if err != nil {
+				return err
+			}
+
+			if !options.SkipTeardown {
+				defer managementSet.TearDown(ctx) //nolint:errcheck
+			}
+
+			if err = managementSet.Setup(ctx); err != nil {
+				return err
+			}
+
+			if options.UpgradeFrom == "" || options.UpgradeTo == "" {
+				return fmt.Errorf("both --upgrade-from and --upgrade-to should be set")
+			}
+
+			clusterAPI, err := capi.NewManager(ctx, bootstrapCluster, capi.Options{
+				ClusterctlConfigPath:    options.ClusterctlConfigPath,
+				CoreProvider:            options.CoreProvider,
+				BootstrapProviders:      options.BootstrapProviders,
+				InfrastructureProviders: []string{fmt.Sprintf("sidero:%s", options.UpgradeFrom)},
+				ControlPlaneProviders:   options.ControlPlaneProviders,
+
+				PowerSimulatedExplicitFailureProb: options.PowerSimulatedExplicitFailureProb,
+				PowerSimulatedSilentFailureProb:   options.PowerSimulatedSilentFailureProb,
+			})
+			if err != nil {
+				return err
+			}
+
+			if err = clusterAPI.Install(ctx); err != nil {
+				return err
+			}
+
+			// hacky hack
+			os.Args = append(os.Args[0:1], "-test.v")
+
+			if ok := tests.RunUpgrade(ctx, bootstrapCluster, managementSet, clusterAPI, tests.UpgradeOptions{
+				Options: tests.Options{
+					KernelURL: options.TalosKernelURL,
+					InitrdURL: options.TalosInitrdURL,
+
+					RegistryMirrors: options.RegistryMirrors,
+
+					RunTestPattern: runTestPattern,
+
+					TalosRelease:      TalosRelease,
+					KubernetesVersion: KubernetesVersion,
+				},
+
+				UpgradeTo: options.UpgradeTo,
+			}); !ok {
+				return fmt.Errorf("test failure")
+			}
+
+			return nil
+		})
+	},
+}
+
+func init() {
+	testCmd.AddCommand(testUpgradeCmd)
+
+	testUpgradeCmd.Flags().BoolVar(&options.SkipTeardown, "skip-teardown", options.SkipTeardown, "skip tearing down cluster")
+	testUpgradeCmd.Flags().StringVar(&options.BootstrapClusterName, "bootstrap-cluster-name", options.BootstrapClusterName, "bootstrap cluster name")
+	testUpgradeCmd.Flags().StringVar(&options.BootstrapTalosVmlinuz, "bootstrap-vmlinuz", options.BootstrapTalosVmlinuz, "Talos kernel image for bootstrap cluster")
+	testUpgradeCmd.Flags().StringVar(&options.BootstrapTalosInitramfs, "bootstrap-initramfs", options.BootstrapTalosInitramfs, "Talos initramfs image for bootstrap cluster")
+	testUpgradeCmd.Flags().StringVar(&options.BootstrapTalosInstaller, "bootstrap-installer", options.BootstrapTalosInstaller, "Talos install image for bootstrap cluster")
+	testUpgradeCmd.Flags().StringVar(&options.BootstrapCIDR, "bootstrap-cidr", options.BootstrapCIDR, "bootstrap cluster network CIDR")
+	testUpgradeCmd.Flags().StringVar(&options.ManagementCIDR, "management-cidr", options.ManagementCIDR, "management cluster network CIDR")
+	testUpgradeCmd.Flags().IntVar(&options.ManagementNodes, "management-nodes", options.ManagementNodes, "number of PXE nodes to create for the management rack")
+	testUpgradeCmd.Flags().StringVar(&options.TalosctlPath, "talosctl-path", options.TalosctlPath, "path to the talosctl (for the QEMU provisioner)")
+	testUpgradeCmd.Flags().StringSliceVar(&options.RegistryMirrors, "registry-mirror", options.RegistryMirrors, "registry mirrors to use")
+	testUpgradeCmd.Flags().StringSliceVar(&options.RegistryMirrors, "registry-mirrors", options.RegistryMirrors, "registry mirrors to use")
+	Should(testUpgradeCmd.Flags().MarkDeprecated("registry-mirrors", "please use --registry-mirror (singular) instead"))
+	testUpgradeCmd.Flags().StringVar(&options.TalosKernelURL, "talos-kernel-url", options.TalosKernelURL, "Talos kernel image URL for Cluster API Environment")
+	testUpgradeCmd.Flags().StringVar(&options.TalosInitrdURL, "talos-initrd-url", options.TalosInitrdURL, "Talos initramfs image URL for Cluster API Environment")
+	testUpgradeCmd.Flags().StringVar(&options.ClusterctlConfigPath, "clusterctl-config", options.ClusterctlConfigPath, "path to the clusterctl config file")
+	testUpgradeCmd.Flags().StringVar(&options.DefaultBootOrder, "default-boot-order", options.DefaultBootOrder, "QEMU default boot order")
+	testUpgradeCmd.Flags().Float64Var(&options.PowerSimulatedExplicitFailureProb, "power-simulated-explicit-failure-prob", options.PowerSimulatedExplicitFailureProb, "simulated power management explicit failure probability")
+	testUpgradeCmd.Flags().Float64Var(&options.PowerSimulatedSilentFailureProb, "power-simulated-silent-failure-prob", options.PowerSimulatedSilentFailureProb, "simulated power management silent failure probability")
+	testUpgradeCmd.Flags().StringVar(&options.UpgradeFrom, "upgrade-from", options.UpgradeFrom, "Sidero version to install before the upgrade")
+	testUpgradeCmd.Flags().StringVar(&options.UpgradeTo, "upgrade-to", options.UpgradeTo, "Sidero version to upgrade to")
+	testUpgradeCmd.Flags().StringVar(&runTestPattern, "test.run", "", "tests to run (regular expression)")
+}
@@ -41,13 +41,17 @@ type Options struct {
 
 	PowerSimulatedExplicitFailureProb float64
 	PowerSimulatedSilentFailureProb   float64
+
+	// Kubeconfig of the existing management cluster, by default kubeconfig is fetched from the cluster.
+	Kubeconfig client.Kubeconfig
 }
 
 // NewManager creates new Manager object.
 func NewManager(ctx context.Context, cluster talos.Cluster, options Options) (*Manager, error) {
 	clusterAPI := &Manager{
-		options: options,
-		cluster: cluster,
+		options:    options,
+		cluster:    cluster,
+		kubeconfig: options.Kubeconfig,
 	}
 
 	var err error
@@ -113,6 +117,15 @@ func (clusterAPI *Manager) GetMetalClient(ctx context.Context) (runtimeclient.Cl
 	return clusterAPI.runtimeClient, err
 }
 
+// setTemplateVariables sets the environment variables for the provider components templates.
+func (clusterAPI *Manager) setTemplateVariables() {
+	os.Setenv("SIDERO_CONTROLLER_MANAGER_HOST_NETWORK", "true")
+	os.Setenv("SIDERO_CONTROLLER_MANAGER_API_ENDPOINT", clusterAPI.cluster.SideroComponentsIP().String())
+	os.Setenv("SIDERO_CONTROLLER_MANAGER_SERVER_REBOOT_TIMEOUT", "30s") // wiping/reboot is fast in the test environment
+	os.Setenv("SIDERO_CONTROLLER_MANAGER_TEST_POWER_EXPLICIT_FAILURE", fmt.Sprintf("%f", clusterAPI.options.PowerSimulatedExplicitFailureProb))
+	os.Setenv("SIDERO_CONTROLLER_MANAGER_TEST_POWER_SILENT_FAILURE", fmt.Sprintf("%f", clusterAPI.options.PowerSimulatedSilentFailureProb))
+}
+
 // Install the Manager components and wait for them to be ready.
 func (clusterAPI *Manager) Install(ctx context.Context) error {
 	kubeconfig, err := clusterAPI.GetKubeconfig(ctx)
@@ -120,12 +133,7 @@ func (clusterAPI *Manager) Install(ctx context.Context) error {
 		return err
 	}
 
-	// set template environment variables
-	os.Setenv("SIDERO_CONTROLLER_MANAGER_HOST_NETWORK", "true")
-	os.Setenv("SIDERO_CONTROLLER_MANAGER_API_ENDPOINT", clusterAPI.cluster.SideroComponentsIP().String())
-	os.Setenv("SIDERO_CONTROLLER_MANAGER_SERVER_REBOOT_TIMEOUT", "30s") // wiping/reboot is fast in the test environment
-	os.Setenv("SIDERO_CONTROLLER_MANAGER_TEST_POWER_EXPLICIT_FAILURE", fmt.Sprintf("%f", clusterAPI.options.PowerSimulatedExplicitFailureProb))
-	os.Setenv("SIDERO_CONTROLLER_MANAGER_TEST_POWER_SILENT_FAILURE", fmt.Sprintf("%f", clusterAPI.options.PowerSimulatedSilentFailureProb))
+	clusterAPI.setTemplateVariables()
 
 	options := client.InitOptions{
 		Kubeconfig:              kubeconfig,
@@ -0,0 +1,78 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package capi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/talos-systems/go-retry/retry"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/cluster-api/cmd/clusterctl/client"
+)
+
+const (
+	sideroNamespace = "sidero-system"
+	sideroProvider  = "sidero"
+)
+
+// sideroDeployments are the deployments of the Sidero infrastructure provider.
+var sideroDeployments = []string{"caps-controller-manager", "sidero-controller-manager"}
+
+// Upgrade Sidero in place to the version (e.g. v0.4.0) with clusterctl, and wait for the new version to be rolled out.
+func (clusterAPI *Manager) Upgrade(ctx context.Context, version string) error {
+	kubeconfig, err := clusterAPI.GetKubeconfig(ctx)
+	if err != nil {
+		return err
+	}
+
+	clusterAPI.setTemplateVariables()
+
+	if err = clusterAPI.client.ApplyUpgrade(client.ApplyUpgradeOptions{
+		Kubeconfig:              kubeconfig,
+		ManagementGroup:         "capi-system/cluster-api",
+		InfrastructureProviders: []string{fmt.Sprintf("%s/%s:%s", sideroNamespace, sideroProvider, version)},
+	}); err != nil {
+		return err
+	}
+
+	return retry.Constant(5*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).Retry(func() error {
+		return clusterAPI.CheckSideroRolledOut(ctx, version)
+	})
+}
+
+// CheckSideroRolledOut verifies that all Sidero deployments run the version, and they are updated and available.
+func (clusterAPI *Manager) CheckSideroRolledOut(ctx context.Context, version string) error {
+	for _, name := range sideroDeployments {
+		deployment, err := clusterAPI.clientset.AppsV1().Deployments(sideroNamespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return retry.ExpectedError(err)
+		}
+
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			if container.Name == "manager" && !strings.HasSuffix(container.Image, ":"+version) {
+				return retry.ExpectedError(fmt.Errorf("deployment %q runs image %q, expected version %s", name, container.Image, version))
+			}
+		}
+
+		replicas := int32(1)
+		if deployment.Spec.Replicas != nil {
+			replicas = *deployment.Spec.Replicas
+		}
+
+		if deployment.Status.ObservedGeneration < deployment.Generation {
+			return retry.ExpectedError(fmt.Errorf("deployment %q is not observed yet", name))
+		}
+
+		if deployment.Status.UpdatedReplicas != replicas || deployment.Status.AvailableReplicas != replicas || deployment.Status.Replicas != replicas {
+			return retry.ExpectedError(fmt.Errorf("deployment %q is not rolled out yet: %d/%d updated, %d/%d available",
+				name, deployment.Status.UpdatedReplicas, replicas, deployment.Status.AvailableReplicas, replicas))
+		}
+	}
+
+	return nil
+}
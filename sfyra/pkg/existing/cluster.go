@@ -0,0 +1,143 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Package existing provides talos.Cluster for the already provisioned management cluster with Sidero installed.
+package existing
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+
+	taloscluster "github.com/talos-systems/talos/pkg/cluster"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// Options configure the access to the existing cluster.
+type Options struct {
+	// Kubeconfig is the path to the kubeconfig of the management cluster.
+	Kubeconfig string
+	// Context in the kubeconfig, current context is used if empty.
+	Context string
+
+	// BridgeIP is the IP of the host running sfyra on the servers network.
+	BridgeIP net.IP
+	// SideroComponentsIP is the IP Sidero components (TFTP, iPXE, etc.) are served on.
+	SideroComponentsIP net.IP
+}
+
+// Cluster attaches to the existing management cluster via kubeconfig.
+type Cluster struct {
+	options Options
+
+	name string
+
+	k8sProvider *kubeconfigProvider
+}
+
+// NewCluster loads the kubeconfig of the existing cluster.
+func NewCluster(options Options) (*Cluster, error) {
+	if options.BridgeIP == nil {
+		return nil, fmt.Errorf("bridge IP is required")
+	}
+
+	kubeconfig, err := ioutil.ReadFile(options.Kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	clientConfig, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, err
+	}
+
+	contextName := options.Context
+	if contextName == "" {
+		contextName = clientConfig.CurrentContext
+	}
+
+	kubeContext, ok := clientConfig.Contexts[contextName]
+	if !ok {
+		return nil, fmt.Errorf("context %q is not found in %q", contextName, options.Kubeconfig)
+	}
+
+	restConfig, err := clientcmd.NewNonInteractiveClientConfig(*clientConfig, contextName, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Cluster{
+		options: options,
+		name:    kubeContext.Cluster,
+		k8sProvider: &kubeconfigProvider{
+			kubeconfig: kubeconfig,
+			restConfig: restConfig,
+		},
+	}, nil
+}
+
+// Name of the cluster.
+func (cluster *Cluster) Name() string {
+	return cluster.name
+}
+
+// BridgeIP returns IP of the bridge which controls the cluster.
+func (cluster *Cluster) BridgeIP() net.IP {
+	return cluster.options.BridgeIP
+}
+
+// SideroComponentsIP returns the IP for the Sidero components (TFTP, iPXE, etc.).
+func (cluster *Cluster) SideroComponentsIP() net.IP {
+	return cluster.options.SideroComponentsIP
+}
+
+// KubernetesClient provides K8s client source.
+func (cluster *Cluster) KubernetesClient() taloscluster.K8sProvider {
+	return cluster.k8sProvider
+}
+
+// kubeconfigProvider implements K8sProvider with the static kubeconfig instead of fetching it via Talos API.
+//
+// Only the kubeconfig based methods are implemented, the rest of the interface is never used by sfyra.
+type kubeconfigProvider struct {
+	taloscluster.K8sProvider
+
+	kubeconfig []byte
+	restConfig *rest.Config
+	clientset  *kubernetes.Clientset
+}
+
+// Kubeconfig returns the kubeconfig contents.
+func (provider *kubeconfigProvider) Kubeconfig(context.Context) ([]byte, error) {
+	return provider.kubeconfig, nil
+}
+
+// K8sRestConfig returns the client config for the kubeconfig context.
+func (provider *kubeconfigProvider) K8sRestConfig(context.Context) (*rest.Config, error) {
+	return provider.restConfig, nil
+}
+
+// K8sClient returns the Kubernetes client.
+func (provider *kubeconfigProvider) K8sClient(context.Context) (*kubernetes.Clientset, error) {
+	if provider.clientset != nil {
+		return provider.clientset, nil
+	}
+
+	clientset, err := kubernetes.NewForConfig(provider.restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	provider.clientset = clientset
+
+	return clientset, nil
+}
+
+// K8sClose releases the resources.
+func (provider *kubeconfigProvider) K8sClose() error {
+	return nil
+}
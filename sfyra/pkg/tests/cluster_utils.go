@@ -29,10 +29,9 @@ import (
 	"github.com/talos-systems/sidero/sfyra/pkg/capi"
 	"github.com/talos-systems/sidero/sfyra/pkg/loadbalancer"
 	"github.com/talos-systems/sidero/sfyra/pkg/talos"
-	"github.com/talos-systems/sidero/sfyra/pkg/vm"
 )
 
-func deployCluster(ctx context.Context, t *testing.T, metalClient client.Client, capiCluster talos.Cluster, vmSet *vm.Set,
+func deployCluster(ctx context.Context, t *testing.T, metalClient client.Client, capiCluster talos.Cluster, network Network,
 	capiManager *capi.Manager, clusterName, serverClassName string, loadbalancerPort int, controlPlaneNodes, workerNodes int64, talosVersion, kubernetesVersion string) (*loadbalancer.ControlPlane, *capi.Cluster) {
 	t.Logf("deploying cluster %q from server class %q with loadbalancer port %d", clusterName, serverClassName, loadbalancerPort)
 
@@ -44,10 +43,10 @@ func deployCluster(ctx context.Context, t *testing.T, metalClient client.Client,
 
 	capiClient := capiManager.GetManagerClient()
 
-	loadbalancer, err := loadbalancer.NewControlPlane(metalClient, vmSet.BridgeIP(), loadbalancerPort, "default", clusterName, false)
+	loadbalancer, err := loadbalancer.NewControlPlane(metalClient, network.BridgeIP(), loadbalancerPort, "default", clusterName, false)
 	require.NoError(t, err)
 
-	os.Setenv("CONTROL_PLANE_ENDPOINT", vmSet.BridgeIP().String())
+	os.Setenv("CONTROL_PLANE_ENDPOINT", network.BridgeIP().String())
 	os.Setenv("CONTROL_PLANE_PORT", strconv.Itoa(loadbalancerPort))
 	os.Setenv("CONTROL_PLANE_SERVERCLASS", serverClassName)
 	os.Setenv("WORKER_SERVERCLASS", serverClassName)
@@ -118,7 +117,7 @@ func deployCluster(ctx context.Context, t *testing.T, metalClient client.Client,
 
 	t.Log("verifying cluster health")
 
-	deployedCluster, err := capi.NewCluster(ctx, metalClient, clusterName, vmSet.BridgeIP())
+	deployedCluster, err := capi.NewCluster(ctx, metalClient, clusterName, network.BridgeIP())
 	require.NoError(t, err)
 
 	require.NoError(t, deployedCluster.Health(ctx))
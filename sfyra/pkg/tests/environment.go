@@ -41,6 +41,22 @@ func isEnvironmentReady(env *v1alpha1.Environment) bool {
 	return len(assetURLs) == 0
 }
 
+// environmentKernelArgs returns the kernel args to boot Talos in the test environments.
+func environmentKernelArgs(cluster talos.Cluster) []string {
+	cmdline := procfs.NewCmdline("")
+	cmdline.SetAll(kernel.DefaultArgs)
+
+	cmdline.Append("console", "ttyS0")
+	cmdline.Append("reboot", "k")
+	cmdline.Append("panic", "1")
+	cmdline.Append("talos.platform", "metal")
+	cmdline.Append("talos.shutdown", "halt")
+	cmdline.Append("talos.config", fmt.Sprintf("http://%s:8081/configdata?uuid=", cluster.SideroComponentsIP()))
+	cmdline.Append("initrd", "initramfs.xz")
+
+	return cmdline.Strings()
+}
+
 // TestEnvironmentDefault verifies environment "default".
 func TestEnvironmentDefault(ctx context.Context, metalClient client.Client, cluster talos.Cluster, kernelURL, initrdURL string) TestFunc {
 	return func(t *testing.T) {
@@ -83,22 +99,11 @@ func TestEnvironmentCreate(ctx context.Context, metalClient client.Client, clust
 				require.NoError(t, err)
 			}
 
-			cmdline := procfs.NewCmdline("")
-			cmdline.SetAll(kernel.DefaultArgs)
-
-			cmdline.Append("console", "ttyS0")
-			cmdline.Append("reboot", "k")
-			cmdline.Append("panic", "1")
-			cmdline.Append("talos.platform", "metal")
-			cmdline.Append("talos.shutdown", "halt")
-			cmdline.Append("talos.config", fmt.Sprintf("http://%s:8081/configdata?uuid=", cluster.SideroComponentsIP()))
-			cmdline.Append("initrd", "initramfs.xz")
-
 			environment.APIVersion = constants.SideroAPIVersion
 			environment.Name = environmentName
 			environment.Spec.Kernel.URL = kernelURL
 			environment.Spec.Kernel.SHA512 = "" // TODO: add a test
-			environment.Spec.Kernel.Args = cmdline.Strings()
+			environment.Spec.Kernel.Args = environmentKernelArgs(cluster)
 			environment.Spec.Initrd.URL = initrdURL
 			environment.Spec.Initrd.SHA512 = "" // TODO: add a test
 
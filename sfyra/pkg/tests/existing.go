@@ -0,0 +1,124 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package tests
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/talos-systems/go-retry/retry"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+)
+
+// existingServers records the number of the servers selected for the tests against the existing hardware.
+type existingServers struct {
+	count int
+}
+
+// require skips the test if there are not enough servers selected.
+func (servers *existingServers) require(count int, test TestFunc) TestFunc {
+	return func(t *testing.T) {
+		if servers.count < count {
+			t.Skipf("test requires %d servers, %d selected", count, servers.count)
+		}
+
+		test(t)
+	}
+}
+
+// TestExistingServers verifies that the selected servers are ready to be provisioned, and creates the server class for them.
+func TestExistingServers(ctx context.Context, metalClient client.Client, serverSelector string, selected *existingServers) TestFunc {
+	return func(t *testing.T) {
+		selector, err := labels.Parse(serverSelector)
+		require.NoError(t, err)
+
+		require.False(t, selector.Empty(), "server selector is required")
+
+		classSelector, err := metav1.ParseToLabelSelector(serverSelector)
+		require.NoError(t, err)
+
+		var servers v1alpha1.ServerList
+
+		// servers might be still wiping after the previous run
+		require.NoError(t, retry.Constant(10*time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).Retry(func() error {
+			if err = metalClient.List(ctx, &servers, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+				return err
+			}
+
+			if len(servers.Items) == 0 {
+				return retry.ExpectedError(fmt.Errorf("no servers match %q", serverSelector))
+			}
+
+			for _, server := range servers.Items {
+				switch {
+				case !server.Spec.Accepted:
+					return fmt.Errorf("server %q is not accepted", server.Name)
+				case server.Status.InUse:
+					return fmt.Errorf("server %q is in use", server.Name)
+				case !server.Status.IsClean:
+					return retry.ExpectedError(fmt.Errorf("server %q is not clean", server.Name))
+				}
+			}
+
+			return nil
+		}))
+
+		selected.count = len(servers.Items)
+
+		t.Logf("%d servers selected with %q", selected.count, serverSelector)
+
+		var serverClass v1alpha1.ServerClass
+
+		err = metalClient.Get(ctx, types.NamespacedName{Name: defaultServerClassName}, &serverClass)
+		if err == nil {
+			// don't take over the server class created by someone else
+			require.True(t, reflect.DeepEqual(*classSelector, serverClass.Spec.Selector), "server class %q already exists with a different selector", defaultServerClassName)
+
+			return
+		}
+
+		require.True(t, apierrors.IsNotFound(err), "%s", err)
+
+		_, err = createServerClass(ctx, metalClient, defaultServerClassName, v1alpha1.ServerClassSpec{
+			Selector: *classSelector,
+		})
+		require.NoError(t, err)
+	}
+}
+
+// TestExistingTeardown deletes the management cluster and the server class, so that the servers are released.
+func TestExistingTeardown(ctx context.Context, metalClient client.Client) TestFunc {
+	return func(t *testing.T) {
+		var cluster v1alpha3.Cluster
+
+		err := metalClient.Get(ctx, types.NamespacedName{Namespace: "default", Name: managementClusterName}, &cluster)
+		if err == nil {
+			deleteCluster(ctx, t, metalClient, managementClusterName)
+		} else {
+			require.True(t, apierrors.IsNotFound(err), "%s", err)
+		}
+
+		var serverClass v1alpha1.ServerClass
+
+		err = metalClient.Get(ctx, types.NamespacedName{Name: defaultServerClassName}, &serverClass)
+		if apierrors.IsNotFound(err) {
+			return
+		}
+
+		require.NoError(t, err)
+		require.NoError(t, metalClient.Delete(ctx, &serverClass))
+	}
+}
@@ -8,6 +8,7 @@ package tests
 import (
 	"context"
 	"log"
+	"net"
 	"regexp"
 	"testing"
 
@@ -19,6 +20,14 @@ import (
 // TestFunc is a testing function prototype.
 type TestFunc func(t *testing.T)
 
+// Network is the network the servers under test are attached to.
+//
+// It is provided by the QEMU VM set, or configured explicitly for the existing hardware.
+type Network interface {
+	// IP on the servers network the control plane load balancers listen on.
+	BridgeIP() net.IP
+}
+
 // Options for the test.
 type Options struct {
 	KernelURL, InitrdURL string
@@ -135,12 +144,173 @@ func Run(ctx context.Context, cluster talos.Cluster, vmSet *vm.Set, capiManager
 		},
 	}
 
+	return runTests(testList, options.RunTestPattern)
+}
+
+// UpgradeOptions for the upgrade test.
+type UpgradeOptions struct {
+	Options
+
+	// UpgradeTo is the Sidero version to upgrade to.
+	UpgradeTo string
+}
+
+// RunUpgrade runs the upgrade test: a cluster is provisioned with the previous Sidero release installed,
+// then Sidero is upgraded in place and the provisioned servers are verified to keep working,
+// while the new servers are provisioned by the new version.
+func RunUpgrade(ctx context.Context, cluster talos.Cluster, vmSet *vm.Set, capiManager *capi.Manager, options UpgradeOptions) (ok bool) {
+	metalClient, err := capiManager.GetMetalClient(ctx)
+	if err != nil {
+		log.Printf("error creating metalClient: %s", err)
+
+		return false
+	}
+
+	snapshot := &upgradeSnapshot{}
+
+	// tests before the upgrade should only rely on the features of the previous release
+	testList := []testing.InternalTest{
+		{
+			"TestServerRegistration",
+			TestServerRegistration(ctx, metalClient, vmSet),
+		},
+		{
+			"TestServerAcceptance",
+			TestServerAcceptance(ctx, metalClient, vmSet),
+		},
+		{
+			"TestServersReady",
+			TestServersReady(ctx, metalClient),
+		},
+		{
+			"TestEnvironmentCreate",
+			TestEnvironmentCreate(ctx, metalClient, cluster, options.KernelURL, options.InitrdURL),
+		},
+		{
+			"TestServerClassAny",
+			TestServerClassAny(ctx, metalClient, vmSet),
+		},
+		{
+			"TestServerClassCreate",
+			TestServerClassCreate(ctx, metalClient, vmSet),
+		},
+		{
+			"TestManagementCluster",
+			TestManagementCluster(ctx, metalClient, cluster, vmSet, capiManager, options.TalosRelease, options.KubernetesVersion),
+		},
+		{
+			"TestUpgradeSidero",
+			TestUpgradeSidero(ctx, metalClient, capiManager, options.UpgradeTo, snapshot),
+		},
+		{
+			"TestUpgradeBindings",
+			TestUpgradeBindings(ctx, metalClient, vmSet, snapshot),
+		},
+		{
+			"TestMatchServersMetalMachines",
+			TestMatchServersMetalMachines(ctx, metalClient),
+		},
+		{
+			"TestUpgradeFeatures",
+			TestUpgradeFeatures(ctx, metalClient, cluster, options.KernelURL, options.InitrdURL),
+		},
+		{
+			"TestScaleWorkersUp",
+			TestScaleWorkersUp(ctx, metalClient, vmSet),
+		},
+		{
+			"TestScaleWorkersDown",
+			TestScaleWorkersDown(ctx, metalClient, vmSet),
+		},
+		{
+			"TestServerReset",
+			TestServerReset(ctx, metalClient),
+		},
+	}
+
+	return runTests(testList, options.RunTestPattern)
+}
+
+// ExistingOptions for the tests against the existing management cluster.
+type ExistingOptions struct {
+	Options
+
+	// ServerSelector selects the servers to provision.
+	ServerSelector string
+
+	// SkipTeardown keeps the provisioned cluster and the server class.
+	SkipTeardown bool
+}
+
+// RunExisting runs the provisioning, scale and reset tests against the existing management cluster with Sidero installed,
+// using the servers selected by the label selector (e.g. the physical machines in the lab).
+//
+// Tests which require more servers than selected are skipped.
+func RunExisting(ctx context.Context, cluster talos.Cluster, network Network, capiManager *capi.Manager, options ExistingOptions) (ok bool) {
+	metalClient, err := capiManager.GetMetalClient(ctx)
+	if err != nil {
+		log.Printf("error creating metalClient: %s", err)
+
+		return false
+	}
+
+	selected := &existingServers{}
+
+	testList := []testing.InternalTest{
+		{
+			"TestExistingServers",
+			TestExistingServers(ctx, metalClient, options.ServerSelector, selected),
+		},
+		{
+			"TestManagementCluster",
+			selected.require(2, TestManagementCluster(ctx, metalClient, cluster, network, capiManager, options.TalosRelease, options.KubernetesVersion)),
+		},
+		{
+			"TestMatchServersMetalMachines",
+			TestMatchServersMetalMachines(ctx, metalClient),
+		},
+		{
+			"TestScaleWorkersUp",
+			selected.require(4, TestScaleWorkersUp(ctx, metalClient, network)),
+		},
+		{
+			"TestScaleWorkersDown",
+			selected.require(4, TestScaleWorkersDown(ctx, metalClient, network)),
+		},
+		{
+			"TestScaleControlPlaneUp",
+			selected.require(4, TestScaleControlPlaneUp(ctx, metalClient, network)),
+		},
+		{
+			"TestScaleControlPlaneDown",
+			selected.require(4, TestScaleControlPlaneDown(ctx, metalClient, network)),
+		},
+		{
+			"TestServerReset",
+			selected.require(2, TestServerReset(ctx, metalClient)),
+		},
+	}
+
+	if !options.SkipTeardown {
+		testList = append(testList, testing.InternalTest{
+			"TestExistingTeardown",
+			TestExistingTeardown(ctx, metalClient),
+		})
+	}
+
+	return runTests(testList, options.RunTestPattern)
+}
+
+func runTests(testList []testing.InternalTest, runTestPattern string) bool {
 	testsToRun := []testing.InternalTest{}
 
-	var re *regexp.Regexp
+	var (
+		re  *regexp.Regexp
+		err error
+	)
 
-	if options.RunTestPattern != "" {
-		if re, err = regexp.Compile(options.RunTestPattern); err != nil {
+	if runTestPattern != "" {
+		if re, err = regexp.Compile(runTestPattern); err != nil {
 			log.Printf("run test pattern parse error: %s", err)
 
 			return false
@@ -0,0 +1,191 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package tests
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/talos-systems/go-retry/retry"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/cluster-api/api/v1alpha3"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	sidero "github.com/talos-systems/sidero/app/caps-controller-manager/api/v1alpha3"
+	metal "github.com/talos-systems/sidero/app/sidero-controller-manager/api/v1alpha1"
+	"github.com/talos-systems/sidero/sfyra/pkg/capi"
+	"github.com/talos-systems/sidero/sfyra/pkg/constants"
+	"github.com/talos-systems/sidero/sfyra/pkg/talos"
+)
+
+const upgradeEnvironmentName = "sfyra-upgrade"
+
+// upgradeSnapshot is the state of the provisioned servers recorded before the upgrade.
+type upgradeSnapshot struct {
+	// bindings by server name
+	bindings map[string]sidero.ServerBinding
+	// machine provider IDs by machine name
+	machines map[string]string
+}
+
+// TestUpgradeSidero records the state of the provisioned servers and upgrades Sidero in place.
+func TestUpgradeSidero(ctx context.Context, metalClient client.Client, capiManager *capi.Manager, version string, snapshot *upgradeSnapshot) TestFunc {
+	return func(t *testing.T) {
+		var serverBindingList sidero.ServerBindingList
+
+		require.NoError(t, metalClient.List(ctx, &serverBindingList))
+		require.NotEmpty(t, serverBindingList.Items, "no servers provisioned before the upgrade")
+
+		snapshot.bindings = map[string]sidero.ServerBinding{}
+
+		for _, serverBinding := range serverBindingList.Items {
+			snapshot.bindings[serverBinding.Name] = serverBinding
+		}
+
+		var machines v1alpha3.MachineList
+
+		require.NoError(t, metalClient.List(ctx, &machines))
+
+		snapshot.machines = map[string]string{}
+
+		for _, machine := range machines.Items {
+			if machine.Spec.ProviderID != nil {
+				snapshot.machines[machine.Name] = *machine.Spec.ProviderID
+			}
+		}
+
+		t.Logf("upgrading Sidero to %s with %d servers provisioned", version, len(snapshot.bindings))
+
+		require.NoError(t, capiManager.Upgrade(ctx, version))
+	}
+}
+
+// TestUpgradeBindings verifies that the servers provisioned before the upgrade are still bound to the same machines,
+// and they are not reprovisioned by the new version.
+func TestUpgradeBindings(ctx context.Context, metalClient client.Client, network Network, snapshot *upgradeSnapshot) TestFunc {
+	return func(t *testing.T) {
+		require.NotEmpty(t, snapshot.bindings, "upgrade snapshot is missing")
+
+		// give the new version some time to reconcile everything
+		start := time.Now()
+
+		for time.Since(start) < time.Minute {
+			for name, previous := range snapshot.bindings {
+				var serverBinding sidero.ServerBinding
+
+				require.NoError(t, metalClient.Get(ctx, types.NamespacedName{Name: name}, &serverBinding))
+
+				assert.Equal(t, previous.UID, serverBinding.UID, "server binding %q was re-created", name)
+				assert.Equal(t, previous.Spec.MetalMachineRef, serverBinding.Spec.MetalMachineRef)
+
+				var server metal.Server
+
+				require.NoError(t, metalClient.Get(ctx, types.NamespacedName{Name: name}, &server))
+
+				require.True(t, server.Status.InUse, "server %q is not in use", name)
+				require.False(t, server.Status.IsClean, "server %q was wiped", name)
+			}
+
+			time.Sleep(10 * time.Second)
+		}
+
+		var machines v1alpha3.MachineList
+
+		require.NoError(t, metalClient.List(ctx, &machines))
+
+		for name, providerID := range snapshot.machines {
+			found := false
+
+			for _, machine := range machines.Items {
+				if machine.Name != name {
+					continue
+				}
+
+				found = true
+
+				require.NotNil(t, machine.Spec.ProviderID)
+				assert.Equal(t, providerID, *machine.Spec.ProviderID)
+			}
+
+			assert.True(t, found, "machine %q was replaced", name)
+		}
+
+		require.NoError(t, retry.Constant(time.Minute, retry.WithUnits(10*time.Second)).Retry(func() error {
+			return capi.CheckClusterReady(ctx, metalClient, managementClusterName)
+		}))
+
+		require.NoError(t, verifyClusterHealth(ctx, metalClient, network, t))
+	}
+}
+
+// TestUpgradeFeatures verifies that the features of the new version are active after the upgrade.
+func TestUpgradeFeatures(ctx context.Context, metalClient client.Client, cluster talos.Cluster, kernelURL, initrdURL string) TestFunc {
+	return func(t *testing.T) {
+		var environment metal.Environment
+
+		err := metalClient.Get(ctx, types.NamespacedName{Name: upgradeEnvironmentName}, &environment)
+		if err == nil {
+			require.NoError(t, metalClient.Delete(ctx, &environment))
+		} else if !apierrors.IsNotFound(err) {
+			require.NoError(t, err)
+		}
+
+		environment = metal.Environment{}
+		environment.APIVersion = constants.SideroAPIVersion
+		environment.Name = upgradeEnvironmentName
+		environment.Spec.Kernel.URL = kernelURL
+		environment.Spec.Kernel.Args = environmentKernelArgs(cluster)
+		environment.Spec.Initrd.URL = initrdURL
+		// explicit asset URLs take precedence over the schematic, so the assets are downloaded from the same place
+		environment.Spec.ImageFactory = &metal.ImageFactory{
+			SchematicID:  strings.Repeat("0", 64),
+			TalosVersion: "v0.0.0",
+		}
+
+		require.NoError(t, retry.Constant(time.Minute, retry.WithUnits(10*time.Second), retry.WithErrorLogging(true)).Retry(func() error {
+			// webhooks and CRDs might be still updating
+			if err := metalClient.Create(ctx, &environment); err != nil && !apierrors.IsAlreadyExists(err) {
+				return retry.ExpectedError(err)
+			}
+
+			return nil
+		}))
+
+		defer metalClient.Delete(ctx, &environment) //nolint:errcheck
+
+		// new fields are pruned if the CRDs were not upgraded
+		require.NoError(t, metalClient.Get(ctx, types.NamespacedName{Name: upgradeEnvironmentName}, &environment))
+		require.NotNil(t, environment.Spec.ImageFactory, "Environment CRD was not upgraded")
+
+		// the new controller reports the download result and records the checksums
+		require.NoError(t, retry.Constant(5*time.Minute, retry.WithUnits(10*time.Second)).Retry(func() error {
+			if err := metalClient.Get(ctx, types.NamespacedName{Name: upgradeEnvironmentName}, &environment); err != nil {
+				return err
+			}
+
+			if !isEnvironmentReady(&environment) {
+				return retry.ExpectedErrorf("some assets are not ready")
+			}
+
+			for _, cond := range environment.Status.Conditions {
+				if cond.Type != "Ready" {
+					continue
+				}
+
+				if cond.Reason != metal.AssetReasonDownloaded || cond.SHA512 == "" {
+					return fmt.Errorf("asset %q condition is not reported by the new version: reason %q", cond.URL, cond.Reason)
+				}
+			}
+
+			return nil
+		}))
+	}
+}
@@ -12,7 +12,6 @@ import (
 
 	"github.com/talos-systems/sidero/sfyra/pkg/capi"
 	"github.com/talos-systems/sidero/sfyra/pkg/talos"
-	"github.com/talos-systems/sidero/sfyra/pkg/vm"
 )
 
 const (
@@ -21,9 +20,9 @@ const (
 )
 
 // TestWorkloadCluster deploys and destroys the workload cluster via CAPI.
-func TestWorkloadCluster(ctx context.Context, metalClient client.Client, cluster talos.Cluster, vmSet *vm.Set, capiManager *capi.Manager, talosRelease, kubernetesVersion string) TestFunc {
+func TestWorkloadCluster(ctx context.Context, metalClient client.Client, cluster talos.Cluster, network Network, capiManager *capi.Manager, talosRelease, kubernetesVersion string) TestFunc {
 	return func(t *testing.T) {
-		loadbalancer, _ := deployCluster(ctx, t, metalClient, cluster, vmSet, capiManager, workloadClusterName, workloadServerClassName, workloadClusterLBPort, 1, 0, talosRelease, kubernetesVersion)
+		loadbalancer, _ := deployCluster(ctx, t, metalClient, cluster, network, capiManager, workloadClusterName, workloadServerClassName, workloadClusterLBPort, 1, 0, talosRelease, kubernetesVersion)
 		defer loadbalancer.Close()
 
 		deleteCluster(ctx, t, metalClient, workloadClusterName)